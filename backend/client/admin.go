@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// GetSLO returns the rolling success rate and latency percentiles of each route
+func (c *Client) GetSLO(ctx context.Context) (*SLO, error) {
+	var slo SLO
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/slo"}, &slo)
+	return &slo, err
+}
+
+// GetCluster returns the live replicas and which one is the leader
+func (c *Client) GetCluster(ctx context.Context) (*ClusterStatus, error) {
+	var status ClusterStatus
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/cluster"}, &status)
+	return &status, err
+}
+
+// GetDiagnostics downloads the diagnostics bundle, a zip, with a CPU profile of cpuSeconds
+// when it is not 0
+func (c *Client) GetDiagnostics(ctx context.Context, cpuSeconds int) ([]byte, error) {
+	query := url.Values{}
+	if cpuSeconds > 0 {
+		query.Set("cpu_seconds", strconv.Itoa(cpuSeconds))
+	}
+	var bundle []byte
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/diagnostics", query: query}, &bundle)
+	return bundle, err
+}
+
+func deadLetterPath(kind string, id int) string {
+	return "/admin/dead-letters/" + url.PathEscape(kind) + "/" + strconv.Itoa(id)
+}
+
+// ListDeadLetters returns the dead letters of the kinds, all when none is given, most
+// recently failed first and without payloads. limit 0 uses the server's default.
+func (c *Client) ListDeadLetters(ctx context.Context, limit int, kinds ...string) ([]DeadLetter, error) {
+	query := url.Values{}
+	if len(kinds) > 0 {
+		query.Set("kind", strings.Join(kinds, ","))
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var letters []DeadLetter
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/dead-letters", query: query}, &letters)
+	return letters, err
+}
+
+// GetDeadLetter returns a dead letter with its payload
+func (c *Client) GetDeadLetter(ctx context.Context, kind string, id int) (*DeadLetter, error) {
+	var letter DeadLetter
+	err := c.do(ctx, request{method: http.MethodGet, path: deadLetterPath(kind, id)}, &letter)
+	return &letter, err
+}
+
+// DeleteDeadLetter discards a dead letter
+func (c *Client) DeleteDeadLetter(ctx context.Context, kind string, id int) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: deadLetterPath(kind, id)}, nil)
+}
+
+// ReplayDeadLetter sends a dead letter again. The replay runs in the background.
+func (c *Client) ReplayDeadLetter(ctx context.Context, kind string, id int) error {
+	return c.do(ctx, request{method: http.MethodPost, path: deadLetterPath(kind, id) + "/replay"}, nil)
+}
+
+// ListEmailTemplates returns the names of the email templates
+func (c *Client) ListEmailTemplates(ctx context.Context) ([]string, error) {
+	var names []string
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/emails"}, &names)
+	return names, err
+}
+
+// PreviewEmail renders an email template with sample data, as HTML or, with text, as
+// plain text
+func (c *Client) PreviewEmail(ctx context.Context, name string, text bool) (string, error) {
+	query := url.Values{}
+	if text {
+		query.Set("format", "text")
+	}
+	var email []byte
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/emails/" + url.PathEscape(name), query: query}, &email)
+	return string(email), err
+}
+
+// ListBackups returns the server's database backups, newest first
+func (c *Client) ListBackups(ctx context.Context) ([]Backup, error) {
+	var backups []Backup
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/backups"}, &backups)
+	return backups, err
+}
+
+// CreateBackup backs up the server's database
+func (c *Client) CreateBackup(ctx context.Context) (*Backup, error) {
+	var backup Backup
+	err := c.do(ctx, request{method: http.MethodPost, path: "/admin/backups"}, &backup)
+	return &backup, err
+}
+
+// RestoreBackup replaces the server's data with the backup called name
+func (c *Client) RestoreBackup(ctx context.Context, name string) error {
+	return c.do(ctx, request{method: http.MethodPost, path: "/admin/backups/" + url.PathEscape(name) + "/restore"}, nil)
+}
+
+// ListJobs returns the background jobs with their last and next run on the replica that
+// answers
+func (c *Client) ListJobs(ctx context.Context) ([]Job, error) {
+	var jobs []Job
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/jobs"}, &jobs)
+	return jobs, err
+}
+
+// RunJob runs the job called name now and returns its status once the run is over
+func (c *Client) RunJob(ctx context.Context, name string) (*Job, error) {
+	var job Job
+	err := c.do(ctx, request{method: http.MethodPost, path: "/admin/jobs/" + url.PathEscape(name) + "/run"}, &job)
+	return &job, err
+}
+
+// RenameTag renames the tag from to to on every task and notification rule. It fails with a
+// 409 when a task already carries to; MergeTags those instead.
+func (c *Client) RenameTag(ctx context.Context, from, to string) (*TagOperation, error) {
+	var op TagOperation
+	err := c.do(ctx, request{method: http.MethodPost, path: "/admin/tags/rename", body: map[string]string{"from": from, "to": to}}, &op)
+	return &op, err
+}
+
+// MergeTags replaces the tag from with into on every task and notification rule
+func (c *Client) MergeTags(ctx context.Context, from, into string) (*TagOperation, error) {
+	var op TagOperation
+	err := c.do(ctx, request{method: http.MethodPost, path: "/admin/tags/merge", body: map[string]string{"from": from, "into": into}}, &op)
+	return &op, err
+}
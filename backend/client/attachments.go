@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+func attachmentPath(ref TaskRef, id int, sub ...string) string {
+	return taskPath(ref, append([]string{"attachments", strconv.Itoa(id)}, sub...)...)
+}
+
+// ListAttachments returns the files attached to a task, oldest first
+func (c *Client) ListAttachments(ctx context.Context, ref TaskRef) ([]Attachment, error) {
+	var attachments []Attachment
+	err := c.do(ctx, request{method: http.MethodGet, path: taskPath(ref, "attachments")}, &attachments)
+	return attachments, err
+}
+
+// UploadAttachment attaches content to a task as name. An empty contentType stores it as
+// application/octet-stream.
+func (c *Client) UploadAttachment(ctx context.Context, ref TaskRef, name, contentType string, content []byte) (*Attachment, error) {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	var attachment Attachment
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   taskPath(ref, "attachments"),
+		query:  url.Values{"name": {name}},
+		header: http.Header{"Content-Type": {contentType}},
+		body:   content,
+	}, &attachment)
+	return &attachment, err
+}
+
+func (c *Client) GetAttachment(ctx context.Context, ref TaskRef, id int) (*Attachment, error) {
+	var attachment Attachment
+	err := c.do(ctx, request{method: http.MethodGet, path: attachmentPath(ref, id)}, &attachment)
+	return &attachment, err
+}
+
+// DownloadAttachment returns the content of an attachment, following the redirect to the
+// bucket when the server stores attachments in the cloud
+func (c *Client) DownloadAttachment(ctx context.Context, ref TaskRef, id int) ([]byte, error) {
+	var content []byte
+	err := c.do(ctx, request{method: http.MethodGet, path: attachmentPath(ref, id, "download")}, &content)
+	return content, err
+}
+
+// DeleteAttachment deletes an attachment and its content
+func (c *Client) DeleteAttachment(ctx context.Context, ref TaskRef, id int) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: attachmentPath(ref, id)}, nil)
+}
+
+// uploadChunkSize is how much of a file UploadAttachmentResumable sends per request
+const uploadChunkSize = 8 << 20
+
+// CreateUpload starts a chunked upload of a size byte file to a task
+func (c *Client) CreateUpload(ctx context.Context, ref TaskRef, name, contentType string, size int64) (*Upload, error) {
+	var upload Upload
+	err := c.do(ctx, request{method: http.MethodPost, path: taskPath(ref, "uploads"), body: map[string]any{
+		"name": name, "content_type": contentType, "size": size,
+	}}, &upload)
+	return &upload, err
+}
+
+// GetUpload returns an upload with how much of it the server has
+func (c *Client) GetUpload(ctx context.Context, ref TaskRef, id string) (*Upload, error) {
+	var upload Upload
+	err := c.do(ctx, request{method: http.MethodGet, path: taskPath(ref, "uploads", url.PathEscape(id))}, &upload)
+	return &upload, err
+}
+
+// UploadChunk sends chunk at offset. It returns the attachment once the chunk completes
+// the file, and nil before that.
+func (c *Client) UploadChunk(ctx context.Context, ref TaskRef, id string, offset int64, chunk []byte) (*Attachment, error) {
+	var attachment Attachment
+	err := c.do(ctx, request{
+		method: http.MethodPatch,
+		path:   taskPath(ref, "uploads", url.PathEscape(id)),
+		header: http.Header{"Content-Type": {"application/offset+octet-stream"}, "Upload-Offset": {strconv.FormatInt(offset, 10)}},
+		body:   chunk,
+	}, &attachment)
+	if err != nil || attachment.ID == 0 {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// CancelUpload discards an upload and what the server received of it
+func (c *Client) CancelUpload(ctx context.Context, ref TaskRef, id string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: taskPath(ref, "uploads", url.PathEscape(id))}, nil)
+}
+
+// UploadAttachmentResumable attaches a size byte file to a task in chunks. When a chunk
+// fails it asks the server how much arrived and carries on from there, giving up after as
+// many failures in a row as WithRetries allows.
+func (c *Client) UploadAttachmentResumable(ctx context.Context, ref TaskRef, name, contentType string, content io.ReaderAt, size int64) (*Attachment, error) {
+	upload, err := c.CreateUpload(ctx, ref, name, contentType, size)
+	if err != nil {
+		return nil, err
+	}
+	chunk := make([]byte, min(size, uploadChunkSize))
+	offset, failures := int64(0), 0
+	wait := c.backoff
+	for {
+		n, err := content.ReadAt(chunk[:min(size-offset, int64(len(chunk)))], offset)
+		if err != nil && !(errors.Is(err, io.EOF) && int64(n) == size-offset) {
+			return nil, err
+		}
+		attachment, err := c.UploadChunk(ctx, ref, upload.ID, offset, chunk[:n])
+		if attachment != nil {
+			return attachment, nil
+		}
+		if err == nil {
+			offset += int64(n)
+			failures, wait = 0, c.backoff
+			continue
+		}
+		var apiErr *Error
+		if ctx.Err() != nil || (!temporary(err) && !(errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict)) {
+			return nil, err
+		}
+		if failures++; failures > c.retries {
+			return nil, err
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		wait *= 2
+		if upload, err = c.GetUpload(ctx, ref, upload.ID); err != nil {
+			return nil, err
+		}
+		offset = upload.Received
+	}
+}
@@ -0,0 +1,303 @@
+// Package client is a Go client for the TODO app's REST API. Every method takes a context,
+// which also carries the trace: requests are traced with otelhttp, so their spans join the
+// caller's trace and the server's spans join them through the traceparent header.
+//
+//	c, err := client.New("http://localhost:8082", client.WithUserID("alice"))
+//	...
+//	task, err := c.CreateTask(ctx, client.NewTask{Title: "Buy milk"})
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Client calls the API of one TODO app server. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	userID     string
+	signing    *signingKey
+	retries    int
+	backoff    time.Duration
+}
+
+// signingKey is a machine-to-machine client's shared secret, see WithSigning
+type signingKey struct {
+	clientID string
+	secret   []byte
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sends requests with httpClient instead of http.DefaultClient. Its transport
+// is wrapped for tracing.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		copied := *httpClient
+		c.httpClient = &copied
+	}
+}
+
+// WithUserID makes requests act for userID, sent in X-User-ID
+func WithUserID(userID string) Option {
+	return func(c *Client) { c.userID = userID }
+}
+
+// WithSigning signs every request with the shared secret of a client in the server's
+// TODO_SIGNING_CLIENTS. Signed requests act as that client's user.
+func WithSigning(clientID, secret string) Option {
+	return func(c *Client) { c.signing = &signingKey{clientID: clientID, secret: []byte(secret)} }
+}
+
+// WithRetries sets how many times a request that failed on the network, or with 429, 502,
+// 503 or 504, is retried, waiting backoff before the first retry and twice as long before
+// each next one, or what Retry-After asks. Only requests that are safe to repeat are
+// retried: those with an idempotent method, and creating a task, which carries an
+// Idempotency-Key. The default is 3 retries from 200ms; 0 turns retries off.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) { c.retries, c.backoff = retries, backoff }
+}
+
+// New returns a client for the server at baseURL, e.g. "http://localhost:8082"
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q, expected http:// or https://", baseURL)
+	}
+	c := &Client{
+		baseURL:    u,
+		httpClient: &http.Client{},
+		retries:    3,
+		backoff:    200 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	transport := c.httpClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	c.httpClient.Transport = otelhttp.NewTransport(transport)
+	return c, nil
+}
+
+// Error is a response with an error status. The API answers errors with RFC 7807 problem
+// details, whose detail is the Message; TraceID is the trace the request was served in, to
+// quote when reporting a problem.
+type Error struct {
+	StatusCode int
+	Message    string
+	TraceID    string
+	body       []byte
+}
+
+func (e *Error) Error() string {
+	if e.TraceID != "" {
+		return fmt.Sprintf("%d %s: %s (trace %s)", e.StatusCode, http.StatusText(e.StatusCode), e.Message, e.TraceID)
+	}
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// IsNotFound reports whether err is a 404 or 410 response: the thing does not exist, or
+// not anymore
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusGone)
+}
+
+// IsConflict reports whether err is a 409 response, e.g. a task claimed by another user
+func IsConflict(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict
+}
+
+// IsModified reports whether err is a 412 response: a conditional update found the task
+// changed since the version it was made against
+func IsModified(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusPreconditionFailed
+}
+
+// request is one API call
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	// body is encoded as JSON unless it is a []byte, sent as is with the Content-Type header
+	body any
+	// idempotent marks a non-idempotent method as safe to retry, e.g. with an Idempotency-Key
+	idempotent bool
+}
+
+// do sends req and decodes a 2xx JSON response into out, when out is not nil. A []byte out
+// receives the raw body.
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if raw, ok := out.(*[]byte); ok {
+		*raw, err = io.ReadAll(resp.Body)
+		return err
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding %s %s response: %w", req.method, req.path, err)
+	}
+	return nil
+}
+
+// send sends req, retrying as WithRetries describes, and returns a 2xx response or an error
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	var body []byte
+	switch b := req.body.(type) {
+	case nil:
+	case []byte:
+		body = b
+	default:
+		var err error
+		if body, err = json.Marshal(b); err != nil {
+			return nil, err
+		}
+	}
+	retryable := req.idempotent || req.method == http.MethodGet || req.method == http.MethodPut ||
+		req.method == http.MethodDelete
+
+	wait := c.backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.attempt(ctx, req, body)
+		if err == nil && resp.StatusCode < 300 {
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err == nil {
+			err = errorFrom(resp)
+			if retryAfter, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil && retryAfter > 0 {
+				wait = time.Duration(retryAfter) * time.Second
+			}
+		}
+		if !retryable || attempt >= c.retries || !temporary(err) {
+			return nil, err
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		wait *= 2
+	}
+}
+
+// attempt sends req once
+func (c *Client) attempt(ctx context.Context, req request, body []byte) (*http.Response, error) {
+	u := *c.baseURL
+	u.Path += req.path
+	u.RawQuery = req.query.Encode()
+	r, err := http.NewRequestWithContext(ctx, req.method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range req.header {
+		r.Header[name] = values
+	}
+	if body != nil && r.Header.Get("Content-Type") == "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if c.userID != "" {
+		r.Header.Set("X-User-ID", c.userID)
+	}
+	if c.signing != nil {
+		if err := c.signing.sign(r, body); err != nil {
+			return nil, err
+		}
+	}
+	return c.httpClient.Do(r)
+}
+
+// sign adds the request signature headers the server verifies: an HMAC-SHA256 of the method,
+// the path with query string, the timestamp, a fresh nonce and the hex SHA-256 of the body,
+// one per line
+func (k *signingKey) sign(r *http.Request, body []byte) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	sum := sha256.Sum256(body)
+	bodyHash := hex.EncodeToString(sum[:])
+	mac := hmac.New(sha256.New, k.secret)
+	io.WriteString(mac, strings.Join([]string{r.Method, r.URL.RequestURI(), timestamp, hex.EncodeToString(nonce), bodyHash}, "\n"))
+
+	r.Header.Set("X-Client-ID", k.clientID)
+	r.Header.Set("X-Signature-Timestamp", timestamp)
+	r.Header.Set("X-Signature-Nonce", hex.EncodeToString(nonce))
+	r.Header.Set("X-Content-SHA256", bodyHash)
+	r.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+	return nil
+}
+
+// errorFrom reads an error response and closes it
+func errorFrom(resp *http.Response) error {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	apiErr := &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body)), TraceID: resp.Header.Get("X-Trace-ID"), body: body}
+	var problem struct {
+		Detail  string `json:"detail"`
+		TraceID string `json:"trace_id"`
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/problem+json") && json.Unmarshal(body, &problem) == nil {
+		apiErr.Message = problem.Detail
+		if problem.TraceID != "" {
+			apiErr.TraceID = problem.TraceID
+		}
+	}
+	return apiErr
+}
+
+// temporary reports whether err may go away on a retry: a network error, or a response
+// saying the server is busy or briefly unavailable
+func temporary(err error) bool {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// newIdempotencyKey returns a random key for a request that should take effect once
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
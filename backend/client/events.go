@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"golang.org/x/net/websocket"
+)
+
+// Task event types, for WatchEvents
+const (
+	EventTaskCreated     = "task.created"
+	EventTaskCompleted   = "task.completed"
+	EventTaskUncompleted = "task.uncompleted"
+	EventTaskDeleted     = "task.deleted"
+)
+
+// ErrResync is returned by WatchEvents when the client fell behind and events were dropped.
+// Catch up with GetTaskDelta and watch again.
+var ErrResync = errors.New("fell behind the task events, resync and watch again")
+
+// WatchEvents calls fn with each task event of the types, all of them when none is given,
+// until ctx is done, fn returns an error or the server closes the connection. It returns
+// ctx.Err() when ctx is done, and ErrResync when events were missed.
+func (c *Client) WatchEvents(ctx context.Context, fn func(Event) error, types ...string) error {
+	u := *c.baseURL
+	u.Scheme = map[string]string{"http": "ws", "https": "wss"}[u.Scheme]
+	u.Path += "/ws"
+	if len(types) > 0 {
+		u.RawQuery = url.Values{"events": {strings.Join(types, ",")}}.Encode()
+	}
+	config, err := websocket.NewConfig(u.String(), c.baseURL.String())
+	if err != nil {
+		return err
+	}
+
+	// Sign and trace the handshake as any other request
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	if c.userID != "" {
+		r.Header.Set("X-User-ID", c.userID)
+	}
+	if c.signing != nil {
+		if err := c.signing.sign(r, nil); err != nil {
+			return err
+		}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(r.Header))
+	config.Header = r.Header
+
+	conn, err := config.DialContext(ctx)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", u.Redacted(), err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	for {
+		var msg json.RawMessage
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		var event Event
+		if err := json.Unmarshal(msg, &event); err != nil {
+			return fmt.Errorf("decoding task event: %w", err)
+		}
+		switch event.Type {
+		case "ready", "heartbeat":
+			continue
+		case "resync":
+			return ErrResync
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+func listPath(id int) string {
+	return "/lists/" + strconv.Itoa(id)
+}
+
+// ListLists returns every list with its task count
+func (c *Client) ListLists(ctx context.Context) ([]List, error) {
+	var lists []List
+	err := c.do(ctx, request{method: http.MethodGet, path: "/lists"}, &lists)
+	return lists, err
+}
+
+func (c *Client) CreateList(ctx context.Context, name string) (*List, error) {
+	var list List
+	err := c.do(ctx, request{method: http.MethodPost, path: "/lists", body: map[string]string{"name": name}}, &list)
+	return &list, err
+}
+
+func (c *Client) GetList(ctx context.Context, id int) (*List, error) {
+	var list List
+	err := c.do(ctx, request{method: http.MethodGet, path: listPath(id)}, &list)
+	return &list, err
+}
+
+func (c *Client) RenameList(ctx context.Context, id int, name string) (*List, error) {
+	var list List
+	err := c.do(ctx, request{method: http.MethodPatch, path: listPath(id), body: map[string]string{"name": name}}, &list)
+	return &list, err
+}
+
+// DeleteList deletes a list and moves its tasks to the default list
+func (c *Client) DeleteList(ctx context.Context, id int) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: listPath(id)}, nil)
+}
+
+// GetListSettings returns the defaults applied to new tasks in a list
+func (c *Client) GetListSettings(ctx context.Context, id int) (*ListSettings, error) {
+	var settings ListSettings
+	err := c.do(ctx, request{method: http.MethodGet, path: listPath(id) + "/settings"}, &settings)
+	return &settings, err
+}
+
+// PutListSettings replaces the defaults applied to new tasks in a list
+func (c *Client) PutListSettings(ctx context.Context, id int, settings ListSettings) (*ListSettings, error) {
+	var saved ListSettings
+	err := c.do(ctx, request{method: http.MethodPut, path: listPath(id) + "/settings", body: settings}, &saved)
+	return &saved, err
+}
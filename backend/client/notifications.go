@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// GetNotifiers returns the notifiers and events notification rules may use
+func (c *Client) GetNotifiers(ctx context.Context) (*Notifiers, error) {
+	var notifiers Notifiers
+	err := c.do(ctx, request{method: http.MethodGet, path: "/notifiers"}, &notifiers)
+	return &notifiers, err
+}
+
+// ListNotificationRules returns the client's user's notification rules
+func (c *Client) ListNotificationRules(ctx context.Context) ([]NotificationRule, error) {
+	var rules []NotificationRule
+	err := c.do(ctx, request{method: http.MethodGet, path: "/notification-rules"}, &rules)
+	return rules, err
+}
+
+// CreateNotificationRule creates a rule for the client's user; ID, UserID and CreatedAt
+// are ignored
+func (c *Client) CreateNotificationRule(ctx context.Context, rule NotificationRule) (*NotificationRule, error) {
+	var created NotificationRule
+	err := c.do(ctx, request{method: http.MethodPost, path: "/notification-rules", body: rule}, &created)
+	return &created, err
+}
+
+func (c *Client) DeleteNotificationRule(ctx context.Context, id int) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/notification-rules/" + strconv.Itoa(id)}, nil)
+}
+
+// GetNotificationSettings returns the client's user's quiet hours and batch window
+func (c *Client) GetNotificationSettings(ctx context.Context) (*NotificationSettings, error) {
+	var settings NotificationSettings
+	err := c.do(ctx, request{method: http.MethodGet, path: "/notification-settings"}, &settings)
+	return &settings, err
+}
+
+// PutNotificationSettings replaces the client's user's quiet hours and batch window
+func (c *Client) PutNotificationSettings(ctx context.Context, settings NotificationSettings) (*NotificationSettings, error) {
+	var saved NotificationSettings
+	err := c.do(ctx, request{method: http.MethodPut, path: "/notification-settings", body: settings}, &saved)
+	return &saved, err
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+func snapshotPath(id int) string {
+	return "/snapshots/" + strconv.Itoa(id)
+}
+
+func (c *Client) ListSnapshots(ctx context.Context) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	err := c.do(ctx, request{method: http.MethodGet, path: "/snapshots"}, &snapshots)
+	return snapshots, err
+}
+
+// CreateSnapshot saves a named snapshot of all tasks
+func (c *Client) CreateSnapshot(ctx context.Context, name string) (*Snapshot, error) {
+	var snapshot Snapshot
+	err := c.do(ctx, request{method: http.MethodPost, path: "/snapshots", body: map[string]string{"name": name}}, &snapshot)
+	return &snapshot, err
+}
+
+func (c *Client) DeleteSnapshot(ctx context.Context, id int) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: snapshotPath(id)}, nil)
+}
+
+// DiffSnapshot returns the tasks added, removed and changed since a snapshot
+func (c *Client) DiffSnapshot(ctx context.Context, id int) (*SnapshotDiff, error) {
+	var diff SnapshotDiff
+	err := c.do(ctx, request{method: http.MethodGet, path: snapshotPath(id) + "/diff"}, &diff)
+	return &diff, err
+}
+
+// RestoreSnapshot makes the tasks match a snapshot again and returns what that changed
+func (c *Client) RestoreSnapshot(ctx context.Context, id int) (*SnapshotDiff, error) {
+	var diff SnapshotDiff
+	err := c.do(ctx, request{method: http.MethodPost, path: snapshotPath(id) + "/restore"}, &diff)
+	return &diff, err
+}
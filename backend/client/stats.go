@@ -0,0 +1,30 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// GetUserStats returns the client's user's completion totals and streak
+func (c *Client) GetUserStats(ctx context.Context) (*UserStats, error) {
+	var stats UserStats
+	err := c.do(ctx, request{method: http.MethodGet, path: "/me/stats"}, &stats)
+	return &stats, err
+}
+
+// GetHeatmap returns the completions per day of year, in the time zone tz. year 0 is the
+// current year and tz "" the time zone of the client's user's notification settings.
+func (c *Client) GetHeatmap(ctx context.Context, year int, tz string) (*Heatmap, error) {
+	query := url.Values{}
+	if year != 0 {
+		query.Set("year", strconv.Itoa(year))
+	}
+	if tz != "" {
+		query.Set("tz", tz)
+	}
+	var heatmap Heatmap
+	err := c.do(ctx, request{method: http.MethodGet, path: "/stats/heatmap", query: query}, &heatmap)
+	return &heatmap, err
+}
@@ -0,0 +1,221 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// taskPath is the path of a task, or of one of its sub-resources
+func taskPath(ref TaskRef, sub ...string) string {
+	path := "/tasks/" + url.PathEscape(string(ref))
+	for _, s := range sub {
+		path += "/" + s
+	}
+	return path
+}
+
+// ListTasks returns the tasks not in the trash that match q
+func (c *Client) ListTasks(ctx context.Context, q TaskQuery) ([]Task, error) {
+	query := url.Values{}
+	if q.Search != "" {
+		query.Set("q", q.Search)
+	}
+	if q.Sort != "" {
+		query.Set("sort", q.Sort)
+	}
+	if q.Locale != "" {
+		query.Set("locale", q.Locale)
+	}
+	if q.ListID != nil {
+		query.Set("list", strconv.Itoa(*q.ListID))
+	}
+	if q.Tag != "" {
+		query.Set("tag", q.Tag)
+	}
+	if q.Stale {
+		query.Set("view", "stale")
+	}
+	if q.Offset > 0 {
+		query.Set("offset", strconv.Itoa(q.Offset))
+	}
+	if q.Limit > 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+	var tasks []Task
+	err := c.do(ctx, request{method: http.MethodGet, path: "/tasks", query: query}, &tasks)
+	return tasks, err
+}
+
+// ListTaskChanges returns the tasks changed after the change sequence sinceSeq, and the
+// sequence to pass next time
+func (c *Client) ListTaskChanges(ctx context.Context, sinceSeq int64) (*TaskChanges, error) {
+	var changes TaskChanges
+	err := c.do(ctx, request{method: http.MethodGet, path: "/tasks",
+		query: url.Values{"since_seq": {strconv.FormatInt(sinceSeq, 10)}}}, &changes)
+	return &changes, err
+}
+
+// GetTaskDelta returns the tasks created, updated and deleted since, for offline sync
+func (c *Client) GetTaskDelta(ctx context.Context, since time.Time) (*TaskDelta, error) {
+	var delta TaskDelta
+	err := c.do(ctx, request{method: http.MethodGet, path: "/tasks/changes",
+		query: url.Values{"since": {since.UTC().Format(time.RFC3339Nano)}}}, &delta)
+	return &delta, err
+}
+
+// CreateTask creates a task. The request carries a fresh Idempotency-Key, so a retry after
+// a lost response returns the task created the first time instead of creating another.
+func (c *Client) CreateTask(ctx context.Context, task NewTask) (*Task, error) {
+	var created Task
+	err := c.do(ctx, request{method: http.MethodPost, path: "/tasks", body: task,
+		header: http.Header{"Idempotency-Key": {newIdempotencyKey()}}, idempotent: true}, &created)
+	return &created, err
+}
+
+// BulkTasks applies the operations in one transaction. When one fails, none is applied:
+// the error is an *Error with status 422, and the response says which operation failed.
+func (c *Client) BulkTasks(ctx context.Context, operations []BulkOperation) (*BulkResponse, error) {
+	var resp BulkResponse
+	err := c.do(ctx, request{method: http.MethodPost, path: "/tasks/bulk", body: map[string]any{"operations": operations}}, &resp)
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnprocessableEntity {
+		json.Unmarshal(apiErr.body, &resp)
+	}
+	return &resp, err
+}
+
+// MergeTasks merges tasks into the task into and returns it
+func (c *Client) MergeTasks(ctx context.Context, into TaskRef, tasks ...TaskRef) (*Task, error) {
+	var merged Task
+	err := c.do(ctx, request{method: http.MethodPost, path: "/tasks/merge", body: map[string]any{"into": into, "tasks": tasks}}, &merged)
+	return &merged, err
+}
+
+func (c *Client) GetTask(ctx context.Context, ref TaskRef) (*Task, error) {
+	var task Task
+	err := c.do(ctx, request{method: http.MethodGet, path: taskPath(ref)}, &task)
+	return &task, err
+}
+
+// UpdateTask changes the fields set in update and returns the task
+func (c *Client) UpdateTask(ctx context.Context, ref TaskRef, update TaskUpdate) (*Task, error) {
+	req := request{method: http.MethodPatch, path: taskPath(ref), body: update}
+	if update.IfUpdatedAt != nil {
+		// The server's ETag for a task is its updated_at in nanoseconds, base 36
+		etag := `"` + strconv.FormatInt(update.IfUpdatedAt.UnixNano(), 36) + `"`
+		req.header = http.Header{"If-Match": {etag}}
+	}
+	var task Task
+	err := c.do(ctx, req, &task)
+	return &task, err
+}
+
+// DeleteTask moves a task to the trash
+func (c *Client) DeleteTask(ctx context.Context, ref TaskRef) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: taskPath(ref)}, nil)
+}
+
+func (c *Client) CompleteTask(ctx context.Context, ref TaskRef) (*Task, error) {
+	var task Task
+	err := c.do(ctx, request{method: http.MethodPost, path: taskPath(ref, "complete")}, &task)
+	return &task, err
+}
+
+func (c *Client) UncompleteTask(ctx context.Context, ref TaskRef) (*Task, error) {
+	var task Task
+	err := c.do(ctx, request{method: http.MethodPost, path: taskPath(ref, "uncomplete")}, &task)
+	return &task, err
+}
+
+// ListTrash returns the deleted tasks that can still be restored
+func (c *Client) ListTrash(ctx context.Context) ([]Task, error) {
+	var tasks []Task
+	err := c.do(ctx, request{method: http.MethodGet, path: "/tasks/trash"}, &tasks)
+	return tasks, err
+}
+
+// RestoreTask takes a task out of the trash
+func (c *Client) RestoreTask(ctx context.Context, ref TaskRef) (*Task, error) {
+	var task Task
+	err := c.do(ctx, request{method: http.MethodPost, path: taskPath(ref, "restore")}, &task)
+	return &task, err
+}
+
+// MoveTask puts a task at the zero-based position in the manual order
+func (c *Client) MoveTask(ctx context.Context, ref TaskRef, position int) (*Task, error) {
+	var task Task
+	err := c.do(ctx, request{method: http.MethodPost, path: taskPath(ref, "move"), body: map[string]int{"position": position}}, &task)
+	return &task, err
+}
+
+// GetTaskHistory returns a task's audit trail, oldest first
+func (c *Client) GetTaskHistory(ctx context.Context, ref TaskRef) ([]TaskEvent, error) {
+	var events []TaskEvent
+	err := c.do(ctx, request{method: http.MethodGet, path: taskPath(ref, "history")}, &events)
+	return events, err
+}
+
+// ClaimTask claims a task for the client's user for ttl, or the server's default when 0
+func (c *Client) ClaimTask(ctx context.Context, ref TaskRef, ttl time.Duration) (*Task, error) {
+	var task Task
+	err := c.do(ctx, request{method: http.MethodPost, path: taskPath(ref, "claim"),
+		body: map[string]int{"ttl_seconds": int(ttl.Seconds())}}, &task)
+	return &task, err
+}
+
+// ReleaseTask releases the client's user's claim on a task
+func (c *Client) ReleaseTask(ctx context.Context, ref TaskRef) (*Task, error) {
+	var task Task
+	err := c.do(ctx, request{method: http.MethodDelete, path: taskPath(ref, "claim")}, &task)
+	return &task, err
+}
+
+// GetTaskDependencies returns the tasks blocking a task and those it blocks
+func (c *Client) GetTaskDependencies(ctx context.Context, ref TaskRef) (*TaskDependencies, error) {
+	var deps TaskDependencies
+	err := c.do(ctx, request{method: http.MethodGet, path: taskPath(ref, "dependencies")}, &deps)
+	return &deps, err
+}
+
+// AddTaskDependency declares a task blocked by blocker and returns it with its blockers
+func (c *Client) AddTaskDependency(ctx context.Context, ref, blocker TaskRef) (*Task, error) {
+	var task Task
+	err := c.do(ctx, request{method: http.MethodPost, path: taskPath(ref, "dependencies"),
+		body: map[string]TaskRef{"blocked_by": blocker}}, &task)
+	return &task, err
+}
+
+// RemoveTaskDependency removes the blocked-by relationship of a task on blocker
+func (c *Client) RemoveTaskDependency(ctx context.Context, ref, blocker TaskRef) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: taskPath(ref, "dependencies", url.PathEscape(string(blocker)))}, nil)
+}
+
+// ExportMarkdown returns the tasks as a markdown checklist, only those in the list listID
+// when it is not nil
+func (c *Client) ExportMarkdown(ctx context.Context, listID *int) (string, error) {
+	query := url.Values{"format": {"markdown"}}
+	if listID != nil {
+		query.Set("list", strconv.Itoa(*listID))
+	}
+	var markdown []byte
+	err := c.do(ctx, request{method: http.MethodGet, path: "/tasks/export", query: query}, &markdown)
+	return string(markdown), err
+}
+
+// ImportMarkdown creates tasks from a markdown checklist, in the list listID or the
+// default list when it is nil
+func (c *Client) ImportMarkdown(ctx context.Context, markdown string, listID *int) (*ImportSummary, error) {
+	query := url.Values{}
+	if listID != nil {
+		query.Set("list", strconv.Itoa(*listID))
+	}
+	var summary ImportSummary
+	err := c.do(ctx, request{method: http.MethodPost, path: "/import/markdown", query: query, body: []byte(markdown),
+		header: http.Header{"Content-Type": {"text/markdown"}}}, &summary)
+	return &summary, err
+}
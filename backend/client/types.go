@@ -0,0 +1,476 @@
+package client
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// TaskRef names a task in a path or body: its numeric ID, see TaskID, or its UUID
+type TaskRef string
+
+// TaskID is the TaskRef of the task with numeric ID id
+func TaskID(id int) TaskRef {
+	return TaskRef(strconv.Itoa(id))
+}
+
+type Task struct {
+	ID          int        `json:"id"`
+	UUID        string     `json:"uuid"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DueAt       *time.Time `json:"due_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	Position    int        `json:"position"`
+	ParentID    *int       `json:"parent_id"`
+	ListID      int        `json:"list_id"`
+	Tags        []string   `json:"tags"`
+	// UpdatedAt is when the task last changed; pass it as TaskUpdate.IfUpdatedAt to update
+	// only the version read
+	UpdatedAt time.Time `json:"updated_at"`
+	// ClaimedBy is the user working on the task until ClaimExpiresAt, if anyone
+	ClaimedBy      *string    `json:"claimed_by"`
+	ClaimExpiresAt *time.Time `json:"claim_expires_at"`
+	// BlockedBy lists the incomplete tasks this one waits for; Blocked is set while there are any
+	BlockedBy []int `json:"blocked_by,omitempty"`
+	Blocked   bool  `json:"blocked"`
+	// AgeDays is how many whole days the task has been open, up to its completion; Stale is
+	// set on an open task unchanged for the server's stale threshold
+	AgeDays int  `json:"age_days"`
+	Stale   bool `json:"stale"`
+}
+
+// NewTask holds the fields of a task being created
+type NewTask struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	ListID      *int       `json:"list_id,omitempty"` // default list when nil
+	Tags        []string   `json:"tags,omitempty"`
+}
+
+// TaskUpdate describes a partial update; nil fields are left unchanged. A due date is
+// removed with ClearDueAt.
+type TaskUpdate struct {
+	Title       *string
+	Description *string
+	Completed   *bool
+	DueAt       *time.Time
+	ClearDueAt  bool
+	ListID      *int
+	Tags        *[]string
+	// IfUpdatedAt makes the update conditional: it fails with a 412 (see IsModified) if the
+	// task has changed since it had this UpdatedAt
+	IfUpdatedAt *time.Time
+}
+
+// MarshalJSON sends only the fields to change, and due_at as null to clear it
+func (u TaskUpdate) MarshalJSON() ([]byte, error) {
+	fields := map[string]any{}
+	if u.Title != nil {
+		fields["title"] = *u.Title
+	}
+	if u.Description != nil {
+		fields["description"] = *u.Description
+	}
+	if u.Completed != nil {
+		fields["completed"] = *u.Completed
+	}
+	if u.ClearDueAt {
+		fields["due_at"] = nil
+	} else if u.DueAt != nil {
+		fields["due_at"] = *u.DueAt
+	}
+	if u.ListID != nil {
+		fields["list_id"] = *u.ListID
+	}
+	if u.Tags != nil {
+		fields["tags"] = *u.Tags
+	}
+	return json.Marshal(fields)
+}
+
+// TaskQuery filters and orders ListTasks; zero fields are left out
+type TaskQuery struct {
+	Search string // case- and diacritic-insensitive title substring
+	Sort   string // "created_at" (default), "title" or "position"
+	Locale string // BCP 47 tag for title collation
+	ListID *int
+	Tag    string
+	Stale  bool // only open tasks unchanged for the server's stale threshold
+	Offset int  // tasks to skip
+	Limit  int  // most tasks to return; the server returns at most TODO_MAX_LIST_ROWS however large
+}
+
+// TaskChanges are the tasks changed after a change sequence, see ListTaskChanges
+type TaskChanges struct {
+	Seq     int64  `json:"seq"`
+	Tasks   []Task `json:"tasks"`
+	Deleted []int  `json:"deleted"`
+}
+
+// TaskDelta is what changed since a time, see GetTaskDelta. Pass ServerTime as the next since.
+type TaskDelta struct {
+	Since      time.Time       `json:"since"`
+	ServerTime time.Time       `json:"server_time"`
+	Created    []Task          `json:"created"`
+	Updated    []Task          `json:"updated"`
+	Deleted    []TaskTombstone `json:"deleted"`
+}
+
+// TaskTombstone is a task deleted for good
+type TaskTombstone struct {
+	ID        int       `json:"id"`
+	UUID      string    `json:"uuid"`
+	DeletedAt time.Time `json:"deleted_at"`
+	// MergedInto is the task this one was merged into, if it was
+	MergedInto *int `json:"merged_into,omitempty"`
+}
+
+// Bulk operations
+const (
+	BulkCreate   = "create"
+	BulkComplete = "complete"
+	BulkDelete   = "delete"
+)
+
+// BulkOperation is one step of BulkTasks: create with the NewTask fields, or complete or
+// delete the task ID
+type BulkOperation struct {
+	Op string `json:"op"`
+	ID int    `json:"id,omitempty"`
+	NewTask
+}
+
+// BulkResponse says whether BulkTasks applied every operation, and how each went
+type BulkResponse struct {
+	Committed bool         `json:"committed"`
+	Results   []BulkResult `json:"results"`
+}
+
+type BulkResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	ID     int    `json:"id,omitempty"`
+	Status int    `json:"status"`
+	Task   *Task  `json:"task,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// TaskEvent is one entry of a task's history
+type TaskEvent struct {
+	ID        int                    `json:"id"`
+	TaskID    int                    `json:"task_id"`
+	Event     string                 `json:"event"`
+	Changes   map[string]FieldChange `json:"changes,omitempty"`
+	Actor     string                 `json:"actor"`
+	TraceID   string                 `json:"trace_id,omitempty"`
+	SpanID    string                 `json:"span_id,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+type FieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+type TaskDependencies struct {
+	BlockedBy []Task `json:"blocked_by"`
+	Blocking  []Task `json:"blocking"`
+}
+
+// Attachment is a file attached to a task
+type Attachment struct {
+	ID          int       `json:"id"`
+	TaskID      int       `json:"task_id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Upload is a file being sent to a task in chunks, Received bytes of Size so far
+type Upload struct {
+	ID          string    `json:"id"`
+	TaskID      int       `json:"task_id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Received    int64     `json:"received"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// ImportSummary is what ImportMarkdown created
+type ImportSummary struct {
+	Created      int    `json:"created"`
+	Completed    int    `json:"completed"`
+	Subtasks     int    `json:"subtasks"`
+	SkippedLines []int  `json:"skipped_lines"`
+	Tasks        []Task `json:"tasks"`
+}
+
+type List struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	IsDefault   bool      `json:"is_default"`
+	CreatedAt   time.Time `json:"created_at"`
+	TaskCount   int       `json:"task_count"`
+	WorkspaceID int       `json:"workspace_id"`
+}
+
+// ListSettings are the defaults applied to the tasks created in a list: DefaultTags are added
+// to their tags, and with ReminderOffsetSeconds they are reminded about that long before they
+// are due
+type ListSettings struct {
+	ListID                int       `json:"list_id,omitempty"`
+	DefaultTags           []string  `json:"default_tags"`
+	ReminderOffsetSeconds *int      `json:"reminder_offset_seconds"`
+	UpdatedAt             time.Time `json:"updated_at,omitzero"`
+}
+
+// Workspace groups lists; every list belongs to one
+type Workspace struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	IsDefault bool      `json:"is_default"`
+	CreatedAt time.Time `json:"created_at"`
+	ListCount int       `json:"list_count"`
+}
+
+// WorkspaceMember is a user's role in a workspace, "owner" or "member"
+type WorkspaceMember struct {
+	WorkspaceID int       `json:"workspace_id,omitempty"`
+	UserID      string    `json:"user_id,omitempty"`
+	Role        string    `json:"role"`
+	CreatedAt   time.Time `json:"created_at,omitzero"`
+}
+
+// WorkspaceSettings are the defaults applied to the tasks created in a workspace's lists,
+// under those of the list itself
+type WorkspaceSettings struct {
+	WorkspaceID           int       `json:"workspace_id,omitempty"`
+	DefaultTags           []string  `json:"default_tags"`
+	ReminderOffsetSeconds *int      `json:"reminder_offset_seconds"`
+	UpdatedAt             time.Time `json:"updated_at,omitzero"`
+}
+
+// Notifiers are the notifiers and events notification rules may use
+type Notifiers struct {
+	Notifiers []string `json:"notifiers"`
+	Events    []string `json:"events"`
+}
+
+type NotificationRule struct {
+	ID        int       `json:"id,omitempty"`
+	UserID    string    `json:"user_id,omitempty"`
+	Event     string    `json:"event"`
+	ListID    *int      `json:"list_id"`
+	Tag       *string   `json:"tag"`
+	Notifier  string    `json:"notifier"`
+	Target    string    `json:"target"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+}
+
+// NotificationSettings are a user's quiet hours, as "HH:MM" in Timezone, and batch window
+type NotificationSettings struct {
+	UserID             string    `json:"user_id,omitempty"`
+	QuietHoursStart    string    `json:"quiet_hours_start"`
+	QuietHoursEnd      string    `json:"quiet_hours_end"`
+	Timezone           string    `json:"timezone"`
+	BatchWindowSeconds int       `json:"batch_window_seconds"`
+	UpdatedAt          time.Time `json:"updated_at,omitzero"`
+}
+
+// UserStats are the client's user's completion totals and streak. Days are counted in the
+// time zone of the user's notification settings.
+type UserStats struct {
+	UserID          string `json:"user_id"`
+	CompletedTotal  int    `json:"completed_total"`
+	CompletedToday  int    `json:"completed_today"`
+	CurrentStreak   int    `json:"current_streak"`
+	LongestStreak   int    `json:"longest_streak"`
+	LastCompletedOn string `json:"last_completed_on"` // YYYY-MM-DD, "" for none
+	Timezone        string `json:"timezone"`
+}
+
+// Heatmap is a year of completions per day, every day of the year included
+type Heatmap struct {
+	Year     int          `json:"year"`
+	Timezone string       `json:"timezone"`
+	Total    int          `json:"total"`
+	Max      int          `json:"max"`
+	Days     []HeatmapDay `json:"days"`
+}
+
+// HeatmapDay is the number of completions on one day
+type HeatmapDay struct {
+	Date  string `json:"date"` // YYYY-MM-DD
+	Count int    `json:"count"`
+}
+
+// Webhook posts task events to URL. Secret is only returned by CreateWebhook.
+type Webhook struct {
+	ID        int       `json:"id,omitempty"`
+	UserID    string    `json:"user_id,omitempty"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+}
+
+type WebhookDelivery struct {
+	ID             int             `json:"id"`
+	WebhookID      int             `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code"`
+	LastError      *string         `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+	FailedAt       *time.Time      `json:"failed_at"`
+}
+
+// HookResponse is what an inbound hook delivery did
+type HookResponse struct {
+	Provider   string       `json:"provider"`
+	Event      string       `json:"event"`
+	DeliveryID string       `json:"delivery_id"`
+	Duplicate  bool         `json:"duplicate,omitempty"`
+	Results    []HookResult `json:"results"`
+}
+
+type HookResult struct {
+	Op         string `json:"op"`
+	ExternalID string `json:"external_id"`
+	TaskID     int    `json:"task_id,omitempty"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+}
+
+type Snapshot struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	TaskCount int       `json:"task_count"`
+}
+
+// SnapshotDiff compares the tasks now with a snapshot, or says what restoring it changed
+type SnapshotDiff struct {
+	Added   []Task       `json:"added"`
+	Removed []Task       `json:"removed"`
+	Changed []TaskChange `json:"changed"`
+}
+
+type TaskChange struct {
+	ID     int      `json:"id"`
+	Fields []string `json:"fields"`
+	Before Task     `json:"before"`
+	After  Task     `json:"after"`
+}
+
+// SLO is the rolling success rate and latency of each route
+type SLO struct {
+	WindowSeconds int        `json:"window_seconds"`
+	Routes        []RouteSLO `json:"routes"`
+}
+
+type RouteSLO struct {
+	Route       string  `json:"route"`
+	Requests    int     `json:"requests"`
+	Errors      int     `json:"errors"`
+	SuccessRate float64 `json:"success_rate"`
+	P50Ms       float64 `json:"p50_ms"`
+	P90Ms       float64 `json:"p90_ms"`
+	P95Ms       float64 `json:"p95_ms"`
+	P99Ms       float64 `json:"p99_ms"`
+}
+
+type ClusterStatus struct {
+	Enabled    bool            `json:"enabled"`
+	InstanceID string          `json:"instance_id"`
+	Leader     bool            `json:"leader"`
+	Members    []ClusterMember `json:"members"`
+}
+
+type ClusterMember struct {
+	InstanceID string    `json:"instance_id"`
+	Hostname   string    `json:"hostname"`
+	StartedAt  time.Time `json:"started_at"`
+	LastSeen   time.Time `json:"last_seen"`
+	Leader     bool      `json:"leader"`
+}
+
+// Backup is a database backup on the server
+type Backup struct {
+	Name      string    `json:"name"`
+	SizeBytes int64     `json:"size_bytes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Job is a background job on one replica. LastOutcome is "success" or "error".
+type Job struct {
+	Name            string     `json:"name"`
+	IntervalSeconds float64    `json:"interval_seconds"`
+	EveryReplica    bool       `json:"every_replica"`
+	Running         bool       `json:"running"`
+	Runs            int        `json:"runs"`
+	LastRunAt       *time.Time `json:"last_run_at"`
+	LastDurationMs  *float64   `json:"last_duration_ms"`
+	LastOutcome     string     `json:"last_outcome,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+	LastTrigger     string     `json:"last_trigger,omitempty"`
+	NextRunAt       *time.Time `json:"next_run_at"`
+}
+
+// Dead letter kinds
+const (
+	DeadLetterWebhook      = "webhook"
+	DeadLetterNotification = "notification"
+)
+
+// TagOperation is what RenameTag or MergeTags changed
+type TagOperation struct {
+	Operation         string `json:"operation"` // "rename" or "merge"
+	From              string `json:"from"`
+	To                string `json:"to"`
+	TaskIDs           []int  `json:"task_ids"` // trashed tasks included
+	NotificationRules int64  `json:"notification_rules"`
+}
+
+// DeadLetter is a webhook delivery or notification that failed for good. Payload is only
+// returned by GetDeadLetter.
+type DeadLetter struct {
+	Kind     string          `json:"kind"`
+	ID       int             `json:"id"`
+	UserID   string          `json:"user_id"`
+	Channel  string          `json:"channel"`
+	Target   string          `json:"target"`
+	Event    string          `json:"event"`
+	Error    string          `json:"error"`
+	Attempts int             `json:"attempts"`
+	FailedAt time.Time       `json:"failed_at"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+}
+
+// Event is a task event pushed by WatchEvents
+type Event struct {
+	Type     string    `json:"type"`
+	TaskID   int       `json:"task_id"`
+	TaskUUID string    `json:"task_uuid,omitempty"`
+	Task     *Task     `json:"task,omitempty"`
+	Seq      int64     `json:"seq,omitempty"`
+	Time     time.Time `json:"time"`
+	Actor    string    `json:"actor,omitempty"`
+	TraceID  string    `json:"trace_id,omitempty"`
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+func webhookPath(id int) string {
+	return "/webhooks/" + strconv.Itoa(id)
+}
+
+// ListWebhooks returns the client's user's webhooks, without their secrets
+func (c *Client) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	var webhooks []Webhook
+	err := c.do(ctx, request{method: http.MethodGet, path: "/webhooks"}, &webhooks)
+	return webhooks, err
+}
+
+// CreateWebhook registers a webhook posting the events, or all of them when empty, to
+// webhookURL. The returned webhook carries the secret its deliveries are signed with,
+// generated when secret is empty.
+func (c *Client) CreateWebhook(ctx context.Context, webhookURL string, events []string, secret string) (*Webhook, error) {
+	var webhook Webhook
+	err := c.do(ctx, request{method: http.MethodPost, path: "/webhooks",
+		body: Webhook{URL: webhookURL, Events: events, Secret: secret}}, &webhook)
+	return &webhook, err
+}
+
+// DeleteWebhook deletes a webhook and its deliveries
+func (c *Client) DeleteWebhook(ctx context.Context, id int) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: webhookPath(id)}, nil)
+}
+
+// ListWebhookDeliveries returns a webhook's most recent deliveries, newest first
+func (c *Client) ListWebhookDeliveries(ctx context.Context, id int) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	err := c.do(ctx, request{method: http.MethodGet, path: webhookPath(id) + "/deliveries"}, &deliveries)
+	return deliveries, err
+}
+
+// SendHook delivers an external system's event to the hook of provider, e.g. "github".
+// header carries the provider's event and signature headers.
+func (c *Client) SendHook(ctx context.Context, provider string, payload []byte, header http.Header) (*HookResponse, error) {
+	header = header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "application/json")
+	}
+	var resp HookResponse
+	err := c.do(ctx, request{method: http.MethodPost, path: "/hooks/" + url.PathEscape(provider), body: payload, header: header}, &resp)
+	return &resp, err
+}
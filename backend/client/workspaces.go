@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+func workspacePath(id int) string {
+	return "/workspaces/" + strconv.Itoa(id)
+}
+
+// ListWorkspaces returns every workspace with its list count
+func (c *Client) ListWorkspaces(ctx context.Context) ([]Workspace, error) {
+	var workspaces []Workspace
+	err := c.do(ctx, request{method: http.MethodGet, path: "/workspaces"}, &workspaces)
+	return workspaces, err
+}
+
+// CreateWorkspace creates a workspace owned by the client's user
+func (c *Client) CreateWorkspace(ctx context.Context, name string) (*Workspace, error) {
+	var workspace Workspace
+	err := c.do(ctx, request{method: http.MethodPost, path: "/workspaces", body: map[string]string{"name": name}}, &workspace)
+	return &workspace, err
+}
+
+func (c *Client) GetWorkspace(ctx context.Context, id int) (*Workspace, error) {
+	var workspace Workspace
+	err := c.do(ctx, request{method: http.MethodGet, path: workspacePath(id)}, &workspace)
+	return &workspace, err
+}
+
+func (c *Client) RenameWorkspace(ctx context.Context, id int, name string) (*Workspace, error) {
+	var workspace Workspace
+	err := c.do(ctx, request{method: http.MethodPatch, path: workspacePath(id), body: map[string]string{"name": name}}, &workspace)
+	return &workspace, err
+}
+
+// DeleteWorkspace deletes a workspace that has no lists left
+func (c *Client) DeleteWorkspace(ctx context.Context, id int) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: workspacePath(id)}, nil)
+}
+
+// ListWorkspaceLists returns the lists in a workspace
+func (c *Client) ListWorkspaceLists(ctx context.Context, id int) ([]List, error) {
+	var lists []List
+	err := c.do(ctx, request{method: http.MethodGet, path: workspacePath(id) + "/lists"}, &lists)
+	return lists, err
+}
+
+// CreateWorkspaceList creates a list in a workspace
+func (c *Client) CreateWorkspaceList(ctx context.Context, id int, name string) (*List, error) {
+	var list List
+	err := c.do(ctx, request{method: http.MethodPost, path: workspacePath(id) + "/lists", body: map[string]string{"name": name}}, &list)
+	return &list, err
+}
+
+func (c *Client) ListWorkspaceMembers(ctx context.Context, id int) ([]WorkspaceMember, error) {
+	var members []WorkspaceMember
+	err := c.do(ctx, request{method: http.MethodGet, path: workspacePath(id) + "/members"}, &members)
+	return members, err
+}
+
+// PutWorkspaceMember adds a user to a workspace with a role, or changes their role
+func (c *Client) PutWorkspaceMember(ctx context.Context, id int, userID, role string) (*WorkspaceMember, error) {
+	var member WorkspaceMember
+	err := c.do(ctx, request{method: http.MethodPut, path: workspacePath(id) + "/members/" + url.PathEscape(userID),
+		body: map[string]string{"role": role}}, &member)
+	return &member, err
+}
+
+func (c *Client) RemoveWorkspaceMember(ctx context.Context, id int, userID string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: workspacePath(id) + "/members/" + url.PathEscape(userID)}, nil)
+}
+
+// GetWorkspaceSettings returns the defaults applied to new tasks in a workspace's lists
+func (c *Client) GetWorkspaceSettings(ctx context.Context, id int) (*WorkspaceSettings, error) {
+	var settings WorkspaceSettings
+	err := c.do(ctx, request{method: http.MethodGet, path: workspacePath(id) + "/settings"}, &settings)
+	return &settings, err
+}
+
+// PutWorkspaceSettings replaces the defaults applied to new tasks in a workspace's lists
+func (c *Client) PutWorkspaceSettings(ctx context.Context, id int, settings WorkspaceSettings) (*WorkspaceSettings, error) {
+	var saved WorkspaceSettings
+	err := c.do(ctx, request{method: http.MethodPut, path: workspacePath(id) + "/settings", body: settings}, &saved)
+	return &saved, err
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"todo-app/internal/config"
+	"todo-app/internal/store"
+)
+
+// openBackups opens the database in TODO_DATABASE_URL for a backup subcommand
+func openBackups(ctx context.Context) (*store.Backups, func(), error) {
+	db, err := store.NewDB(ctx, config.EnvString("TODO_DATABASE_URL", "./tasks.db"))
+	if err != nil {
+		return nil, nil, err
+	}
+	backups, err := store.NewBackups(db)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return backups, func() { db.Close() }, nil
+}
+
+// runBackup writes a backup of the database, the way POST /admin/backups does
+func runBackup(ctx context.Context) int {
+	backups, closeDB, err := openBackups(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Backup failed:", err)
+		return 1
+	}
+	defer closeDB()
+
+	backup, err := backups.Create(ctx, store.BackupTriggerCLI)
+	if backup == nil {
+		fmt.Fprintln(os.Stderr, "Backup failed:", err)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Warning:", err)
+	}
+	fmt.Printf("Backed up the database to %s (%d bytes)\n", backup.Name, backup.SizeBytes)
+	return 0
+}
+
+// runRestore restores the database from a backup named in TODO_BACKUP_DIR, or from the
+// backup file at a path. A running server may keep serving meanwhile.
+func runRestore(ctx context.Context, nameOrPath string) int {
+	backups, closeDB, err := openBackups(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Restore failed:", err)
+		return 1
+	}
+	defer closeDB()
+
+	path, err := backups.Path(nameOrPath)
+	if errors.Is(err, store.ErrBackupNotFound) {
+		path = nameOrPath
+	}
+	if err := backups.Restore(ctx, path, store.BackupTriggerCLI); err != nil {
+		fmt.Fprintln(os.Stderr, "Restore failed:", err)
+		return 1
+	}
+	fmt.Printf("Restored the database from %s\n", path)
+	return 0
+}
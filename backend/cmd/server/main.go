@@ -0,0 +1,368 @@
+// Command server runs the TODO app: the HTTP and gRPC APIs, the frontend and the background
+// jobs, configured from the environment.
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"todo-app/internal/api"
+	"todo-app/internal/config"
+	"todo-app/internal/integrations"
+	"todo-app/internal/scheduler"
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+
+	"google.golang.org/grpc"
+)
+
+const PORT = ":8082"
+
+func main() {
+	ctx := context.Background()
+
+	// Subcommands run instead of the server
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(ctx, os.Args[1:]))
+	}
+
+	// Initialize telemetry
+	shutdown, err := telemetry.InitTelemetry(ctx)
+	if err != nil {
+		log.Fatal("Failed to initialize telemetry:", err)
+	}
+	defer func() {
+		// Bound the final flush so an unreachable collector cannot hang the exit
+		flushCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if err := shutdown(flushCtx); err != nil {
+			slog.Error("Failed to shutdown telemetry", "error", err)
+		}
+	}()
+
+	slog.Info("Starting TODO app with OpenTelemetry instrumentation")
+
+	// Listen right away so the probes can report startup progress; requests other than
+	// /healthz and /readyz wait until the server is ready
+	readiness := api.NewReadiness(api.StageDatabase, api.StageMigrations, api.StageWarmup)
+	srv := &http.Server{
+		Addr:         PORT,
+		Handler:      readiness.Handler(),
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	// Start server in a goroutine
+	go func() {
+		slog.Info("Server starting", "port", PORT)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("Server failed to start", "error", err)
+			log.Fatal("Server failed to start:", err)
+		}
+	}()
+
+	// pprof gets its own loopback listener, started with the main listener so a slow startup
+	// can be profiled too
+	var pprofServer *http.Server
+	if api.PprofAddr != "" {
+		lis, err := api.NewPprofListener(api.PprofAddr)
+		if err != nil {
+			slog.Error("Failed to listen for pprof", "error", err)
+			log.Fatal("Failed to listen for pprof:", err)
+		}
+		pprofServer = api.NewPprofServer()
+		go func() {
+			slog.Info("pprof server starting", "addr", lis.Addr().String())
+			if err := pprofServer.Serve(lis); err != nil && err != http.ErrServerClosed {
+				slog.Error("pprof server failed", "error", err)
+			}
+		}()
+	}
+
+	// With the memory store, the features beyond the task lifecycle get a database in memory
+	// too, so the server runs without files
+	databaseURL := config.EnvString("TODO_DATABASE_URL", "./tasks.db")
+	storeKind := config.EnvString("TODO_STORE", store.StoreSQL)
+	if !store.IsRegisteredStore(storeKind) {
+		log.Fatalf("Unknown TODO_STORE %q, expected one of %s", storeKind, strings.Join(store.StoreNames(), ", "))
+	}
+	if storeKind == store.StoreMemory {
+		databaseURL = store.MemoryDatabaseURL
+	}
+
+	db, err := store.NewDB(ctx, databaseURL)
+	if err != nil {
+		slog.Error("Failed to connect to database", "error", err)
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer db.Close()
+	readiness.Done(ctx, api.StageDatabase)
+
+	if err := db.Setup(ctx); err != nil {
+		slog.Error("Failed to set up database", "error", err)
+		log.Fatal("Failed to set up database:", err)
+	}
+	readiness.Done(ctx, api.StageMigrations)
+
+	tasks, err := store.OpenStore(ctx, storeKind, db)
+	if err != nil {
+		slog.Error("Failed to open the task store", "store", storeKind, "error", err)
+		log.Fatal("Failed to open the task store:", err)
+	}
+	if tasks != store.TaskStore(db) {
+		defer tasks.Close()
+	}
+
+	rdb, err := store.NewRedis(ctx)
+	if err != nil {
+		slog.Error("Failed to connect to Redis", "error", err)
+		log.Fatal("Failed to connect to Redis:", err)
+	}
+	if rdb != nil {
+		defer rdb.Close()
+	}
+
+	events := store.NewEventBus()
+	cluster := scheduler.NewCluster(db, events)
+	if err := cluster.ConfigureLock(rdb); err != nil {
+		slog.Error("Invalid cluster configuration", "error", err)
+		log.Fatal("Invalid cluster configuration:", err)
+	}
+
+	if err := store.RegisterTaskGauges(db, cluster.IsLeader); err != nil {
+		slog.Error("Failed to register task gauges", "error", err)
+	}
+
+	emails, err := integrations.LoadEmailTemplates(config.EnvString("TODO_EMAIL_TEMPLATE_DIR", ""), integrations.LoadEmailTheme())
+	if err != nil {
+		slog.Error("Failed to load email templates", "error", err)
+		log.Fatal("Failed to load email templates:", err)
+	}
+	mailer := integrations.NewMailer(emails)
+	external, err := integrations.LoadExternalAPIConfig()
+	if err != nil {
+		slog.Error("Invalid external API configuration", "error", err)
+		log.Fatal("Invalid external API configuration:", err)
+	}
+	notifiers := integrations.NewDefaultNotifierRegistry(integrations.NewHTTPClient(), mailer, external)
+	notifications := integrations.NewNotificationDispatcher(db, notifiers)
+	webhooks := integrations.NewWebhookDispatcher(db, integrations.NewHTTPClient())
+	events.OnPublish(webhooks.Enqueue)
+	integrations.RegisterIntegrationHealthGauges()
+
+	siem, err := integrations.NewSIEMExporter(db)
+	if err != nil {
+		slog.Error("Invalid SIEM configuration", "error", err)
+		log.Fatal("Invalid SIEM configuration:", err)
+	}
+	replicator, err := integrations.NewReplicator(db)
+	if err != nil {
+		slog.Error("Invalid replication configuration", "error", err)
+		log.Fatal("Invalid replication configuration:", err)
+	}
+	attachments, err := integrations.NewAttachmentStore()
+	if err != nil {
+		slog.Error("Invalid attachment storage configuration", "error", err)
+		log.Fatal("Invalid attachment storage configuration:", err)
+	}
+	// Outbound calls are stubbed in demo mode, so object storage would accept what it is
+	// given and return nothing: a demo instance keeps its data local instead
+	if config.DemoMode && replicator != nil {
+		slog.Info("Demo mode, replication to TODO_REPLICA_URL not started")
+		replicator = nil
+	}
+	if config.DemoMode && attachments.Name() != integrations.AttachmentStorageLocal {
+		slog.Info("Demo mode, attachments kept in TODO_ATTACHMENT_DIR instead of remote storage", "storage", attachments.Name())
+		if attachments, err = integrations.NewLocalAttachmentStore(); err != nil {
+			slog.Error("Invalid attachment storage configuration", "error", err)
+			log.Fatal("Invalid attachment storage configuration:", err)
+		}
+	}
+	uploads, err := integrations.NewUploadStaging()
+	if err != nil {
+		slog.Error("Invalid upload staging configuration", "error", err)
+		log.Fatal("Invalid upload staging configuration:", err)
+	}
+	hooks, err := integrations.NewHookReceiver(integrations.HookSecrets)
+	if err != nil {
+		slog.Error("Invalid TODO_HOOK_SECRETS", "error", err)
+		log.Fatal("Invalid TODO_HOOK_SECRETS:", err)
+	}
+	if err := config.CheckFeatures(config.EnabledFeatures); err != nil {
+		slog.Error("Invalid TODO_FEATURES", "error", err)
+		log.Fatal("Invalid TODO_FEATURES:", err)
+	}
+	if err := api.SetJSONFormats(api.JSONFormats); err != nil {
+		slog.Error("Invalid TODO_JSON_FORMATS", "error", err)
+		log.Fatal("Invalid TODO_JSON_FORMATS:", err)
+	}
+	accessLog, err := api.NewAccessLogMiddleware(api.AccessLog, os.Stdout)
+	if err != nil {
+		slog.Error("Invalid TODO_ACCESS_LOG", "error", err)
+		log.Fatal("Invalid TODO_ACCESS_LOG:", err)
+	}
+
+	outbound := integrations.NewOutboundQueue(integrations.OutboundQueueSize, integrations.OutboundRate, integrations.OutboundBurst, integrations.OutboundWorkers)
+	cluster.OnMembersChange(outbound.SetReplicas)
+	outbound.Start(ctx)
+	defer outbound.Stop()
+
+	// Join the cluster before the scheduler starts so only the leader runs jobs from the outset
+	if err := cluster.Start(ctx); err != nil {
+		slog.Error("Failed to join cluster", "error", err)
+		log.Fatal("Failed to join cluster:", err)
+	}
+	defer cluster.Stop()
+
+	jobs := scheduler.NewScheduler()
+	jobs.SetLeader(cluster.IsLeader)
+	if siem != nil {
+		integrations.AuditExporter = siem
+		siem.SetLeader(cluster.IsLeader)
+		jobs.Add(siem.Job())
+		defer siem.Close()
+	}
+	jobs.Add(scheduler.NewTrashPurgeJob(db))
+	if store.RetentionDays > 0 {
+		jobs.Add(scheduler.NewRetentionJob(db))
+	}
+	jobs.Add(scheduler.NewIdempotencyPurgeJob(db))
+	jobs.Add(scheduler.NewHookDeliveryPurgeJob(db))
+	jobs.Add(integrations.NewAttachmentCleanupJob(db, attachments))
+	jobs.Add(integrations.NewUploadCleanupJob(db, uploads))
+	jobs.Add(integrations.NewReminderJob(db, integrations.NewNotifiers(config.EnvString("TODO_REMINDER_NOTIFIERS", "external"), notifiers), notifications))
+	jobs.Add(integrations.NewNotificationDigestJob(notifications))
+	if staleNotifiers := config.EnvString("TODO_STALE_NOTIFIERS", ""); staleNotifiers != "" && store.StaleDays > 0 {
+		jobs.Add(integrations.NewStaleReportJob(db, integrations.NewNotifiers(staleNotifiers, notifiers)))
+	}
+	jobs.Add(integrations.NewWebhookDeliveryJob(webhooks))
+	jobs.Add(integrations.NewNotificationOutboxJob(integrations.NewNotificationOutbox(db, notifications, outbound, cluster.IsLeader)))
+	if len(integrations.HealthReportTo) > 0 {
+		jobs.Add(integrations.NewHealthReportJob(telemetry.Health, mailer, integrations.HealthReportTo))
+	}
+	// Backups copy the database, so there are none when the tasks are elsewhere
+	var backups *store.Backups
+	if storeKind == store.StoreSQL {
+		backups, err = store.NewBackups(db)
+		if err != nil && !errors.Is(err, store.ErrBackupUnsupported) {
+			slog.Error("Failed to set up backups", "error", err)
+			log.Fatal("Failed to set up backups:", err)
+		}
+	}
+	if backups != nil && store.BackupInterval > 0 {
+		jobs.Add(scheduler.NewBackupJob(backups))
+	}
+	if replicator != nil {
+		jobs.Add(replicator.Job())
+		readiness.AddCheck("replication", replicator.Check)
+	}
+	if config.DemoMode {
+		jobs.Add(integrations.NewDemoResetJob(db, tasks, events))
+	}
+	if job, ok, err := api.NewProfileExportJob(); err != nil {
+		slog.Error("Invalid continuous profiling configuration", "error", err)
+		log.Fatal("Invalid continuous profiling configuration:", err)
+	} else if ok {
+		jobs.Add(job)
+	}
+	jobs.Start(ctx)
+	defer jobs.Stop()
+
+	handlers := api.NewHandlers(db, emails, notifications, webhooks, hooks, outbound, events, cluster)
+	handlers.UseTaskStore(tasks)
+	handlers.UseScheduler(jobs)
+	handlers.UseAttachments(attachments)
+	handlers.UseUploads(uploads)
+	if backups != nil {
+		handlers.UseBackups(backups)
+	}
+	nonces := cluster.NonceStore(integrations.SignatureMaxSkew)
+	if rdb != nil {
+		handlers.UseRedis(rdb)
+		nonces = api.NewRedisNonceStore(rdb, integrations.SignatureMaxSkew)
+	}
+	limiter := api.NewRateLimiter(rdb)
+	demo := api.NewDemoGuard(rdb)
+
+	verifier, err := api.NewRequestVerifier(nonces)
+	if err != nil {
+		slog.Error("Invalid TODO_SIGNING_CLIENTS", "error", err)
+		log.Fatal("Invalid TODO_SIGNING_CLIENTS:", err)
+	}
+	readiness.SetHandler(ctx, api.RequestContextMiddleware(accessLog(verifier.Middleware(api.UserMiddleware(demo.Middleware(limiter.Middleware(api.ChangeSeqMiddleware(api.NewRouter(handlers)))))))))
+
+	if err := db.Warm(ctx); err != nil {
+		// A cold cache only makes the first requests slower
+		slog.Warn("Failed to warm up database", "error", err)
+	}
+	readiness.Done(ctx, api.StageWarmup)
+
+	// The demo limits apply to HTTP only, so a demo instance does not serve gRPC
+	var grpcServer *grpc.Server
+	if config.DemoMode && api.GRPCAddr != "" {
+		slog.Info("Demo mode, gRPC server not started")
+	} else if api.GRPCAddr != "" {
+		lis, err := net.Listen("tcp", api.GRPCAddr)
+		if err != nil {
+			slog.Error("Failed to listen for gRPC", "error", err)
+			log.Fatal("Failed to listen for gRPC:", err)
+		}
+		grpcServer = api.NewGRPCServer(handlers, verifier, limiter)
+		go func() {
+			slog.Info("gRPC server starting", "addr", api.GRPCAddr)
+			if err := grpcServer.Serve(lis); err != nil {
+				slog.Error("gRPC server failed", "error", err)
+			}
+		}()
+	}
+
+	// Wait for interrupt signal to gracefully shutdown
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	slog.Info("Shutting down server...")
+	shutdownCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// Shutdown does not wait for hijacked WebSocket connections; ending their
+	// subscriptions closes them, and ends gRPC Watch streams
+	events.Close()
+	if grpcServer != nil {
+		stopGRPC(shutdownCtx, grpcServer)
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Server forced to shutdown", "error", err)
+	}
+	if pprofServer != nil {
+		// A CPU profile or trace in progress would hold up the exit for its whole duration
+		pprofServer.Close()
+	}
+
+	slog.Info("Server exited")
+}
+
+// stopGRPC lets in-flight gRPC calls finish, or cuts them off once ctx is done
+func stopGRPC(ctx context.Context, s *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		slog.Error("gRPC server forced to shutdown")
+		s.Stop()
+	}
+}
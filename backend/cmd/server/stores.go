@@ -0,0 +1,7 @@
+package main
+
+// Storage backends beyond the built-in sql and memory stores register themselves with
+// store.RegisterStore when their package is imported. Compile one in by importing it here,
+// then select it with TODO_STORE:
+//
+//	import _ "todo-app/internal/store/firestore"
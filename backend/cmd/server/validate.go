@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"todo-app/internal/api"
+	"todo-app/internal/config"
+	"todo-app/internal/integrations"
+	"todo-app/internal/store"
+)
+
+// validateTimeout bounds each connectivity check
+const validateTimeout = 5 * time.Second
+
+// Outcomes of a configuration check
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "FAIL"
+	checkSkip = "skip"
+)
+
+// checkResult is one line of the validation report
+type checkResult struct {
+	name   string
+	status string
+	detail string
+}
+
+// runCommand runs a subcommand instead of the server and returns the exit code
+func runCommand(ctx context.Context, args []string) int {
+	switch {
+	case len(args) == 2 && args[0] == "config" && args[1] == "validate":
+		return validateConfig(ctx, os.Stdout)
+	case len(args) == 1 && args[0] == "backup":
+		return runBackup(ctx)
+	case len(args) == 2 && args[0] == "restore":
+		return runRestore(ctx, args[1])
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\nUsage:\n"+
+		"  todo-app                    run the server\n"+
+		"  todo-app config validate    check the configuration and connectivity without starting\n"+
+		"  todo-app backup             back up the SQLite database to TODO_BACKUP_DIR\n"+
+		"  todo-app restore NAME|PATH  restore the database from a backup\n",
+		strings.Join(args, " "))
+	return 2
+}
+
+// validateConfig checks the configuration in the environment the way the server reads it at
+// startup, and that the database, Redis, OTLP collector and SMTP server it names answer, without
+// migrating, writing or sending anything. It prints a report to w and returns 1 when a check
+// failed, so a deploy pipeline can stop before rolling out a broken configuration.
+func validateConfig(ctx context.Context, w io.Writer) int {
+	checks := []func(context.Context) checkResult{
+		checkDatabase,
+		checkRedis,
+		checkOTLP,
+		checkSMTP,
+		func(context.Context) checkResult {
+			return checkParse("features", config.CheckFeatures(config.EnabledFeatures))
+		},
+		func(context.Context) checkResult {
+			return checkParse("JSON formats", api.SetJSONFormats(api.JSONFormats))
+		},
+		func(context.Context) checkResult {
+			_, err := api.NewAccessLogMiddleware(api.AccessLog, io.Discard)
+			return checkParse("access log", err)
+		},
+		func(context.Context) checkResult {
+			_, err := integrations.LoadEmailTemplates(config.EnvString("TODO_EMAIL_TEMPLATE_DIR", ""), integrations.LoadEmailTheme())
+			return checkParse("email templates", err)
+		},
+		func(context.Context) checkResult {
+			_, err := integrations.LoadExternalAPIConfig()
+			return checkParse("external API", err)
+		},
+		func(context.Context) checkResult {
+			_, err := integrations.NewHookReceiver(integrations.HookSecrets)
+			return checkParse("hook secrets", err)
+		},
+		func(context.Context) checkResult {
+			_, err := integrations.NewSIEMExporter(nil)
+			return checkParse("SIEM", err)
+		},
+		func(context.Context) checkResult {
+			_, err := api.NewRequestVerifier(nil)
+			return checkParse("signing clients", err)
+		},
+		func(context.Context) checkResult {
+			if api.PprofAddr == "" {
+				return checkResult{"pprof", checkSkip, "TODO_PPROF_ADDR not set"}
+			}
+			return checkParse("pprof", api.CheckPprofAddr(api.PprofAddr))
+		},
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	failed := 0
+	for _, check := range checks {
+		result := check(ctx)
+		if result.status == checkFail {
+			failed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", result.status, result.name, result.detail)
+	}
+	tw.Flush()
+
+	if failed > 0 {
+		fmt.Fprintf(w, "\n%d check(s) failed\n", failed)
+		return 1
+	}
+	fmt.Fprintln(w, "\nConfiguration is valid")
+	return 0
+}
+
+// checkParse reports a setting that is only parsed, not connected to
+func checkParse(name string, err error) checkResult {
+	if err != nil {
+		return checkResult{name, checkFail, err.Error()}
+	}
+	return checkResult{name, checkOK, "valid"}
+}
+
+// checkDatabase opens the database as the server would and reads its schema version. A SQLite
+// file that does not exist yet is not created: its directory must exist instead.
+func checkDatabase(ctx context.Context) checkResult {
+	databaseURL := config.EnvString("TODO_DATABASE_URL", "./tasks.db")
+	kind := config.EnvString("TODO_STORE", store.StoreSQL)
+	if !store.IsRegisteredStore(kind) {
+		return checkResult{"database", checkFail, fmt.Sprintf("unknown TODO_STORE %q, expected one of %s", kind, strings.Join(store.StoreNames(), ", "))}
+	}
+	if kind == store.StoreMemory {
+		databaseURL = store.MemoryDatabaseURL
+	}
+
+	sqlite := !strings.Contains(databaseURL, "://")
+	if sqlite && databaseURL != store.MemoryDatabaseURL {
+		path, _, _ := strings.Cut(strings.TrimPrefix(databaseURL, "file:"), "?")
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			dir := filepath.Dir(path)
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				return checkResult{"database", checkFail, fmt.Sprintf("%s does not exist and its directory %s is missing", path, dir)}
+			}
+			return checkResult{"database", checkOK, fmt.Sprintf("%s will be created on first start", path)}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, validateTimeout)
+	defer cancel()
+	db, err := store.NewDB(ctx, databaseURL)
+	if err != nil {
+		return checkResult{"database", checkFail, err.Error()}
+	}
+	defer db.Close()
+	version, err := db.SchemaVersion(ctx)
+	if err != nil {
+		return checkResult{"database", checkOK, "connected; not migrated yet"}
+	}
+	if err := db.CheckSchema(ctx); err != nil {
+		return checkResult{"database", checkFail, err.Error()}
+	}
+	return checkResult{"database", checkOK, fmt.Sprintf("connected; schema version %d", version)}
+}
+
+func checkRedis(ctx context.Context) checkResult {
+	if config.EnvString("TODO_REDIS_URL", "") == "" {
+		if config.EnvString("TODO_CLUSTER_LOCK", "database") == "redis" {
+			return checkResult{"redis", checkFail, "TODO_CLUSTER_LOCK=redis needs TODO_REDIS_URL"}
+		}
+		return checkResult{"redis", checkSkip, "TODO_REDIS_URL not set"}
+	}
+	rdb, err := store.NewRedis(ctx)
+	if err != nil {
+		return checkResult{"redis", checkFail, err.Error()}
+	}
+	rdb.Close()
+	return checkResult{"redis", checkOK, "connected"}
+}
+
+// checkOTLP checks that the collector accepts connections. Without one, telemetry goes to the
+// console, which is not an error.
+func checkOTLP(ctx context.Context) checkResult {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	if endpoint == "" {
+		return checkResult{"otlp", checkSkip, "OTEL_EXPORTER_OTLP_ENDPOINT not set; telemetry goes to the console"}
+	}
+	dialer := net.Dialer{Timeout: validateTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", endpoint)
+	if err != nil {
+		return checkResult{"otlp", checkFail, err.Error()}
+	}
+	conn.Close()
+	return checkResult{"otlp", checkOK, "collector reachable at " + endpoint}
+}
+
+// checkSMTP greets the SMTP server and, with TODO_SMTP_USERNAME, logs in, but sends nothing
+func checkSMTP(ctx context.Context) checkResult {
+	addr := config.EnvString("TODO_SMTP_ADDR", "")
+	if addr == "" {
+		return checkResult{"smtp", checkSkip, "TODO_SMTP_ADDR not set; emails are not sent"}
+	}
+	dialer := net.Dialer{Timeout: validateTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return checkResult{"smtp", checkFail, err.Error()}
+	}
+	conn.SetDeadline(time.Now().Add(validateTimeout))
+	host, _, _ := strings.Cut(addr, ":")
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return checkResult{"smtp", checkFail, err.Error()}
+	}
+	defer c.Close()
+	if err := c.Hello("localhost"); err != nil {
+		return checkResult{"smtp", checkFail, err.Error()}
+	}
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return checkResult{"smtp", checkFail, err.Error()}
+		}
+	}
+	if user := config.EnvString("TODO_SMTP_USERNAME", ""); user != "" {
+		if err := c.Auth(smtp.PlainAuth("", user, config.EnvString("TODO_SMTP_PASSWORD", ""), host)); err != nil {
+			return checkResult{"smtp", checkFail, "authentication failed: " + err.Error()}
+		}
+	}
+	c.Quit()
+	if config.EnvString("TODO_EMAIL_TO", "") == "" {
+		return checkResult{"smtp", checkWarn, "connected, but TODO_EMAIL_TO is not set, so emails have no recipients"}
+	}
+	return checkResult{"smtp", checkOK, "connected"}
+}
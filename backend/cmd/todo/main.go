@@ -0,0 +1,207 @@
+// Command todo manages tasks from the terminal through the API, using the client package.
+//
+//	todo add -due 2025-07-01 -tags work Write the report
+//	todo list -tag work
+//	todo complete 12
+//	todo -o json search report
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"todo-app/client"
+	"todo-app/internal/config"
+)
+
+const usage = `Usage: todo [flags] <command> [arguments]
+
+Commands:
+  list [-list ID] [-tag TAG] [-sort created_at|title|position]   list tasks
+  add [-description TEXT] [-due DATE] [-tags A,B] [-list ID] TITLE  create a task
+  complete ID...                                                mark tasks complete
+  delete ID...                                                  move tasks to the trash
+  search [-list ID] [-tag TAG] QUERY                            list tasks whose title matches
+
+IDs are numeric task IDs or task UUIDs. DATE is YYYY-MM-DD or RFC 3339. list -stale
+only lists the open tasks that have not changed in a while.
+
+Flags:
+`
+
+func main() {
+	flags := flag.NewFlagSet("todo", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	baseURL := flags.String("url", config.EnvString("TODO_URL", "http://localhost:8082"), "API base URL ($TODO_URL)")
+	userID := flags.String("user", config.EnvString("TODO_USER", ""), "user to act for, sent in X-User-ID ($TODO_USER)")
+	output := flags.String("o", config.EnvString("TODO_OUTPUT", outputTable), "output format: table or json ($TODO_OUTPUT)")
+	timeout := flags.Duration("timeout", 30*time.Second, "give up on a command after this long")
+	flags.Parse(os.Args[1:])
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *output != outputTable && *output != outputJSON {
+		fmt.Fprintf(os.Stderr, "todo: invalid output format %q, expected table or json\n", *output)
+		os.Exit(2)
+	}
+
+	opts := []client.Option{client.WithUserID(*userID)}
+	// Signing clients in TODO_SIGNING_CLIENTS authenticate with their shared secret instead
+	if id := config.EnvString("TODO_CLIENT_ID", ""); id != "" {
+		opts = append(opts, client.WithSigning(id, config.EnvString("TODO_CLIENT_SECRET", "")))
+	}
+	c, err := client.New(*baseURL, opts...)
+	if err != nil {
+		fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	cli := &cli{client: c, out: newPrinter(os.Stdout, *output)}
+	command, args := flags.Arg(0), flags.Args()[1:]
+	switch command {
+	case "list", "ls":
+		err = cli.list(ctx, args, false)
+	case "search":
+		err = cli.list(ctx, args, true)
+	case "add":
+		err = cli.add(ctx, args)
+	case "complete", "done":
+		err = cli.complete(ctx, args)
+	case "delete", "rm":
+		err = cli.delete(ctx, args)
+	default:
+		fmt.Fprintf(os.Stderr, "todo: unknown command %q\n\n", command)
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "todo:", err)
+	os.Exit(1)
+}
+
+type cli struct {
+	client *client.Client
+	out    *printer
+}
+
+// list lists tasks, those whose title matches the arguments when search is set
+func (c *cli) list(ctx context.Context, args []string, search bool) error {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	listID := flags.Int("list", 0, "only tasks in the list with this ID")
+	tag := flags.String("tag", "", "only tasks carrying this tag")
+	sort := flags.String("sort", "", "created_at (default), title or position")
+	stale := flags.Bool("stale", false, "only open tasks that have not changed in a while")
+	flags.Parse(args)
+
+	q := client.TaskQuery{Tag: *tag, Sort: *sort, Stale: *stale}
+	if *listID != 0 {
+		q.ListID = listID
+	}
+	if search {
+		q.Search = strings.Join(flags.Args(), " ")
+		if q.Search == "" {
+			return fmt.Errorf("search needs a query")
+		}
+	} else if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %q", flags.Args())
+	}
+
+	tasks, err := c.client.ListTasks(ctx, q)
+	if err != nil {
+		return err
+	}
+	return c.out.tasks(tasks)
+}
+
+func (c *cli) add(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("add", flag.ExitOnError)
+	description := flags.String("description", "", "task description, markdown")
+	due := flags.String("due", "", "due date, YYYY-MM-DD (end of that day, local time) or RFC 3339")
+	tags := flags.String("tags", "", "comma-separated tags")
+	listID := flags.Int("list", 0, "list to add the task to; the default list otherwise")
+	flags.Parse(args)
+
+	task := client.NewTask{Title: strings.Join(flags.Args(), " "), Description: *description}
+	if task.Title == "" {
+		return fmt.Errorf("add needs a title")
+	}
+	if *due != "" {
+		dueAt, err := parseDue(*due)
+		if err != nil {
+			return err
+		}
+		task.DueAt = &dueAt
+	}
+	if *tags != "" {
+		task.Tags = config.SplitList(*tags)
+	}
+	if *listID != 0 {
+		task.ListID = listID
+	}
+
+	created, err := c.client.CreateTask(ctx, task)
+	if err != nil {
+		return err
+	}
+	return c.out.task(created)
+}
+
+// parseDue parses a due date given as a day, due at its end in local time, or as RFC 3339
+func parseDue(s string) (time.Time, error) {
+	if day, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return day.Add(24*time.Hour - time.Second), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid due date %q, expected YYYY-MM-DD or RFC 3339", s)
+	}
+	return t, nil
+}
+
+func (c *cli) complete(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("complete needs task IDs")
+	}
+	var tasks []client.Task
+	for _, ref := range args {
+		task, err := c.client.CompleteTask(ctx, client.TaskRef(ref))
+		if err != nil {
+			return fmt.Errorf("completing task %s: %w", ref, err)
+		}
+		tasks = append(tasks, *task)
+	}
+	return c.out.tasks(tasks)
+}
+
+func (c *cli) delete(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("delete needs task IDs")
+	}
+	var deleted []string
+	for _, ref := range args {
+		if err := c.client.DeleteTask(ctx, client.TaskRef(ref)); err != nil {
+			return fmt.Errorf("deleting task %s: %w", ref, err)
+		}
+		deleted = append(deleted, ref)
+	}
+	return c.out.deleted(deleted)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"todo-app/client"
+)
+
+// Output formats
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// printer writes command results as an aligned table for people or as JSON for scripts
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func newPrinter(w io.Writer, format string) *printer {
+	return &printer{w: w, format: format}
+}
+
+func (p *printer) task(task *client.Task) error {
+	if p.format == outputJSON {
+		return p.json(task)
+	}
+	return p.tasks([]client.Task{*task})
+}
+
+func (p *printer) tasks(tasks []client.Task) error {
+	if p.format == outputJSON {
+		if tasks == nil {
+			tasks = []client.Task{}
+		}
+		return p.json(tasks)
+	}
+	if len(tasks) == 0 {
+		_, err := fmt.Fprintln(p.w, "No tasks")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tDONE\tTITLE\tDUE\tTAGS")
+	for _, task := range tasks {
+		done := ""
+		if task.Completed {
+			done = "x"
+		}
+		due := ""
+		if task.DueAt != nil {
+			due = task.DueAt.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", task.ID, done, oneLine(task.Title), due, strings.Join(task.Tags, ","))
+	}
+	return tw.Flush()
+}
+
+func (p *printer) deleted(refs []string) error {
+	if p.format == outputJSON {
+		return p.json(map[string][]string{"deleted": refs})
+	}
+	_, err := fmt.Fprintf(p.w, "Deleted %s\n", strings.Join(refs, ", "))
+	return err
+}
+
+func (p *printer) json(v any) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// oneLine keeps a title from breaking the table: tabs and newlines become spaces
+func oneLine(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return ' '
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AccessLog is the format of the access log written to stdout, one line per request: json,
+// text or off
+var AccessLog = config.EnvString("TODO_ACCESS_LOG", telemetry.AccessLogJSON)
+
+type accessLogKey struct{}
+
+// accessLogEntry collects what the access log line of a request learns inside the route,
+// where the request is matched and traced
+type accessLogEntry struct {
+	route   string
+	traceID string
+	spanID  string
+}
+
+// noteAccessLogRoute records the route pattern and span of r, once routed, for its access
+// log line
+func noteAccessLogRoute(r *http.Request) {
+	entry, ok := r.Context().Value(accessLogKey{}).(*accessLogEntry)
+	if !ok {
+		return
+	}
+	entry.route = r.Pattern
+	if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+		entry.traceID, entry.spanID = sc.TraceID().String(), sc.SpanID().String()
+	}
+}
+
+// accessLogWriter counts the status and body bytes of a response
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *accessLogWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *accessLogWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *accessLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush and Hijack keep streaming responses and the WebSocket upgrade working through the wrapper
+
+func (w *accessLogWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *accessLogWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// NewAccessLogMiddleware returns middleware writing one access log line per request to out,
+// in format (see AccessLog), with the method, route pattern, path, status, latency, body
+// bytes, client IP, user agent and the trace and span of the request. Requests refused
+// before routing have no route or trace. It runs just inside RequestContextMiddleware, so
+// the line carries the request ID.
+func NewAccessLogMiddleware(format string, out io.Writer) (func(http.Handler) http.Handler, error) {
+	logger, err := telemetry.NewAccessLogger(format, out)
+	if err != nil || logger == nil {
+		return func(next http.Handler) http.Handler { return next }, err
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			entry := &accessLogEntry{}
+			ctx := context.WithValue(r.Context(), accessLogKey{}, entry)
+			lw := &accessLogWriter{ResponseWriter: w}
+			defer func() {
+				if lw.status == 0 {
+					lw.status = http.StatusOK
+				}
+				logger.LogAttrs(ctx, slog.LevelInfo, "HTTP request",
+					slog.String("method", r.Method),
+					slog.String("route", entry.route),
+					slog.String("path", r.URL.Path),
+					slog.Int("status", lw.status),
+					slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+					slog.Int64("bytes", lw.bytes),
+					slog.String("remote_ip", clientIP(r)),
+					slog.String("user_agent", r.UserAgent()),
+					slog.String("trace_id", entry.traceID),
+					slog.String("span_id", entry.spanID),
+				)
+			}()
+			next.ServeHTTP(lw, r.WithContext(ctx))
+		})
+	}, nil
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/integrations"
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// adminEventsPollInterval is how often GET /admin/events checks the task history for new
+// audit events
+var adminEventsPollInterval = config.EnvDuration("TODO_ADMIN_EVENTS_POLL_INTERVAL", time.Second)
+
+// adminEventsBatch bounds the audit events read per poll; a backlog is read on the
+// following polls
+const adminEventsBatch = 500
+
+// Server-sent event types on /admin/events besides the task events of the bus
+const (
+	sseEventReady    = "ready"
+	sseEventAudit    = "audit"
+	sseEventSecurity = "security"
+	sseEventResync   = "resync"
+)
+
+// AdminEvents serves GET /admin/events, a server-sent event stream of task events as they
+// are published, audit events as they are recorded in the task history and this replica's
+// security events. Audit events carry their history position as
+// the event ID, so a client reconnecting with Last-Event-ID misses none of them.
+func (h *Handlers) AdminEvents(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	method, endpoint := "GET", "/admin/events"
+
+	var position int64
+	var err error
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		position, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || position < 0 {
+			http.Error(w, "Invalid Last-Event-ID, expected an audit event ID", http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+			return
+		}
+	} else if position, err = h.db.LatestAuditPosition(ctx); err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error reading audit position", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusInternalServerError)
+		return
+	}
+
+	sub := h.events.Subscribe(wsBuffer)
+	defer sub.Close()
+	security, stopSecurity := integrations.ListenSecurityEvents(wsBuffer)
+	defer stopSecurity()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusOK)
+
+	// The server's write timeout is for regular responses; each event gets its own instead
+	rc := http.NewResponseController(w)
+	send := func(id, event string, v any) error {
+		data, err := json.Marshal(formatJSON(ctx, v))
+		if err != nil {
+			return err
+		}
+		rc.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		if id != "" {
+			fmt.Fprintf(w, "id: %s\n", id)
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	slog.InfoContext(ctx, "Admin event stream connected", "position", position)
+
+	poll := time.NewTicker(adminEventsPollInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(wsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	sent := 0
+	reason := ""
+	err = send("", sseEventReady, wsControlMessage{Type: sseEventReady, Time: time.Now().UTC()})
+	for err == nil && reason == "" {
+		select {
+		case <-ctx.Done():
+			reason = "client_closed"
+		case event, ok := <-sub.C:
+			if !ok {
+				reason = "shutdown"
+				if sub.Lagged() {
+					reason = "lagged"
+					err = send("", sseEventResync, wsControlMessage{Type: sseEventResync, Time: time.Now().UTC()})
+				}
+				break
+			}
+			if err = send("", event.Type, event); err == nil {
+				sent++
+			}
+		case event := <-security:
+			if err = send("", sseEventSecurity, event); err == nil {
+				sent++
+			}
+		case <-poll.C:
+			var events []store.AuditEvent
+			position, events, err = h.db.AuditEventsAfter(ctx, position, adminEventsBatch)
+			for _, event := range events {
+				if err = send(strconv.FormatInt(event.Position, 10), sseEventAudit, event); err != nil {
+					break
+				}
+				sent++
+			}
+		case <-heartbeat.C:
+			rc.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if _, err = fmt.Fprint(w, ": heartbeat\n\n"); err == nil {
+				err = rc.Flush()
+			}
+		}
+	}
+	switch {
+	case err != nil && ctx.Err() != nil:
+		reason = "client_closed"
+	case err != nil:
+		reason = "error"
+		span.RecordError(err)
+	}
+
+	span.SetAttributes(
+		attribute.String("sse.close_reason", reason),
+		attribute.Int("sse.events_sent", sent),
+	)
+	slog.InfoContext(ctx, "Admin event stream disconnected", "reason", reason, "events_sent", sent, "duration", time.Since(start))
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// apiVersionHeader selects the response format. Version 1, the default, returns lists as
+// bare JSON arrays; version 2 wraps them in a ListEnvelope. The version can also be asked
+// for with the profile parameter of Accept, e.g. "application/json; profile=2".
+const apiVersionHeader = "X-API-Version"
+
+// API versions
+const (
+	apiVersion1 = "1"
+	apiVersion2 = "2"
+)
+
+// ListEnvelope is the version 2 format of a list response
+type ListEnvelope struct {
+	Data  any       `json:"data"`
+	Meta  ListMeta  `json:"meta"`
+	Links ListLinks `json:"links"`
+}
+
+// ListMeta describes the list in a ListEnvelope
+type ListMeta struct {
+	Count int `json:"count"`
+	// ChangeSeq is the change sequence the list is current as of, for lists of tasks
+	ChangeSeq *int64 `json:"change_seq,omitempty"`
+}
+
+// ListLinks are the related URLs of a ListEnvelope
+type ListLinks struct {
+	Self string `json:"self"`
+	// Next fetches the page after this one, when the list goes on
+	Next string `json:"next,omitempty"`
+	// Changes fetches the changes made after the list, for lists of tasks
+	Changes string `json:"changes,omitempty"`
+}
+
+type apiVersionKey struct{}
+
+// apiVersion returns the API version the request ctx belongs to asked for
+func apiVersion(ctx context.Context) string {
+	if v, ok := ctx.Value(apiVersionKey{}).(string); ok {
+		return v
+	}
+	return apiVersion1
+}
+
+// requestedAPIVersion reads the version from X-API-Version, then from the profile of an
+// application/json media range in Accept. It returns "" when neither asks for one.
+func requestedAPIVersion(r *http.Request) string {
+	if v := strings.TrimSpace(r.Header.Get(apiVersionHeader)); v != "" {
+		return v
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(accept)
+		if err == nil && mediaType == "application/json" && params["profile"] != "" {
+			return params["profile"]
+		}
+	}
+	return ""
+}
+
+// APIVersionMiddleware negotiates the API version and the JSON format that goes with it. An
+// unknown version or format gets 400 rather than the default, so a client written against a
+// newer version notices. The version is echoed in X-API-Version and recorded on the request
+// span as api.version, with the format as api.json_naming and api.json_time.
+func APIVersionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", apiVersionHeader+", Accept")
+		version := requestedAPIVersion(r)
+		if version == "" {
+			version = apiVersion1
+		}
+		if version != apiVersion1 && version != apiVersion2 {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			http.Error(w, "Unsupported API version, expected 1 or 2", http.StatusBadRequest)
+			return
+		}
+
+		format, err := requestedJSONFormat(r, version)
+		if err != nil {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set(apiVersionHeader, version)
+		ctx := r.Context()
+		trace.SpanFromContext(ctx).SetAttributes(
+			attribute.String("api.version", version),
+			attribute.String("api.json_naming", format.naming),
+			attribute.String("api.json_time", format.time),
+		)
+		ctx = context.WithValue(ctx, apiVersionKey{}, version)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, jsonFormatKey{}, format)))
+	})
+}
+
+// writeList writes the page of items, a slice, that the request's limit and offset ask
+// for, in the format of its API version. It returns the response status: 400 for an
+// invalid page.
+func writeList(w http.ResponseWriter, r *http.Request, items any) int {
+	page, err := parseListPage(r, maxListRows)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return http.StatusBadRequest
+	}
+	v := reflect.ValueOf(items)
+	start := min(page.offset, v.Len())
+	end := min(start+page.limit, v.Len())
+	writeListPage(w, r, v.Slice(start, end).Interface(), page, end < v.Len())
+	return http.StatusOK
+}
+
+// writeListPage writes items, the page of a list, in the format of the request's API
+// version, with a link to the next page when more is set
+func writeListPage(w http.ResponseWriter, r *http.Request, items any, page listPage, more bool) {
+	next := setPageHints(w, r, page, more)
+	w.Header().Set("Content-Type", "application/json")
+	if apiVersion(r.Context()) == apiVersion1 {
+		writeJSON(w, r, items)
+		return
+	}
+	v := reflect.ValueOf(items)
+	if v.IsNil() {
+		// An empty list is [] in the envelope, never null
+		items = []any{}
+	}
+	writeEnvelope(w, r, ListEnvelope{Data: items, Meta: ListMeta{Count: v.Len()}, Links: ListLinks{Next: next}})
+}
+
+// writeEnvelope writes env with its self link filled in
+func writeEnvelope(w http.ResponseWriter, r *http.Request, env ListEnvelope) {
+	env.Links.Self = r.URL.RequestURI()
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, env)
+}
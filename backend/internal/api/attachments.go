@@ -0,0 +1,323 @@
+package api
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"todo-app/internal/integrations"
+	"todo-app/internal/requestctx"
+	"todo-app/internal/store"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxAttachmentNameLength bounds the file name of an attachment, in characters
+const maxAttachmentNameLength = 255
+
+// UseAttachments keeps attachment content in attachments. Without it the attachment routes
+// answer 501.
+func (h *Handlers) UseAttachments(attachments integrations.AttachmentStore) {
+	h.attachments = attachments
+}
+
+// TaskAttachments serves GET and POST /tasks/{id}/attachments. POST takes the file as the
+// raw body, its name from ?name= and its type from Content-Type.
+func (h *Handlers) TaskAttachments(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" && r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	method, endpoint := r.Method, "/tasks/:id/attachments"
+	if h.attachments == nil {
+		http.Error(w, "Attachments are not configured", http.StatusNotImplemented)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusNotImplemented)
+		return
+	}
+
+	id, ok := h.taskIDFromPath(w, r, start, method, endpoint, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.Int("task.id", id))
+
+	if method == "GET" {
+		span.SetAttributes(attribute.String("operation", "get_attachments"))
+		attachments, err := h.db.GetAttachments(ctx, id)
+		if err != nil {
+			h.attachmentError(w, r, start, method, endpoint, err)
+			return
+		}
+		h.recordRequestMetrics(ctx, start, method, endpoint, writeList(w, r, attachments))
+		return
+	}
+
+	span.SetAttributes(attribute.String("operation", "upload_attachment"))
+	name, ok := attachmentName(r.URL.Query().Get("name"))
+	if !ok {
+		http.Error(w, fmt.Sprintf("Invalid ?name=, expected a file name of at most %d characters", maxAttachmentNameLength), http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+		return
+	}
+	contentType, ok := attachmentContentType(r.Header.Get("Content-Type"))
+	if !ok {
+		http.Error(w, "Invalid Content-Type", http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+		return
+	}
+	if r.ContentLength > store.MaxAttachmentSize {
+		http.Error(w, fmt.Sprintf("Attachment too large, at most %d bytes allowed", store.MaxAttachmentSize), http.StatusRequestEntityTooLarge)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Spool the upload to disk first: its size and digest are needed before it is stored,
+	// and the storage may read it more than once
+	spool, err := os.CreateTemp("", "todo-attachment-*")
+	if err != nil {
+		h.attachmentError(w, r, start, method, endpoint, err)
+		return
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool, hash), http.MaxBytesReader(w, r.Body, store.MaxAttachmentSize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("Attachment too large, at most %d bytes allowed", store.MaxAttachmentSize), http.StatusRequestEntityTooLarge)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, method, endpoint) {
+			return
+		}
+		http.Error(w, "Failed to read the request body", http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+		return
+	}
+
+	attachment := &store.Attachment{
+		TaskID:      id,
+		Name:        name,
+		ContentType: contentType,
+		Size:        size,
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+		StorageKey:  strconv.Itoa(id) + "/" + uuid.NewString(),
+		CreatedBy:   requestctx.User(ctx),
+	}
+	span.SetAttributes(
+		attribute.Int64("attachment.size", size),
+		attribute.String("attachment.storage", h.attachments.Name()),
+	)
+	// Checked before the upload so a full workspace does not cost a transfer, and again
+	// when the row is inserted
+	if err := h.db.CheckAttachmentQuota(ctx, id, size); err != nil {
+		h.attachmentError(w, r, start, method, endpoint, err)
+		return
+	}
+	blob := integrations.AttachmentBlob{Body: spool, Size: size, SHA256: attachment.SHA256, ContentType: contentType}
+	if err := h.attachments.Put(ctx, attachment.StorageKey, blob); err != nil {
+		h.attachmentError(w, r, start, method, endpoint, err)
+		return
+	}
+	if err := h.db.CreateAttachment(ctx, attachment); err != nil {
+		if err := h.attachments.Delete(ctx, attachment.StorageKey); err != nil {
+			slog.WarnContext(ctx, "Failed to delete the content of an attachment that was not recorded", "error", err, "key", attachment.StorageKey)
+		}
+		h.attachmentError(w, r, start, method, endpoint, err)
+		return
+	}
+	slog.InfoContext(ctx, "Uploaded attachment", "id", attachment.ID, "task_id", id, "size", size, "storage", h.attachments.Name())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, r, attachment)
+	h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusCreated)
+}
+
+// TaskAttachment serves GET and DELETE /tasks/{id}/attachments/{attachment} and
+// GET /tasks/{id}/attachments/{attachment}/download. Downloads redirect to a signed URL
+// when the storage hands them out, and are served by the API otherwise.
+func (h *Handlers) TaskAttachment(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	download := strings.HasSuffix(r.URL.Path, "/download")
+	var operation, endpoint string
+	switch {
+	case r.Method == "GET" && download:
+		operation, endpoint = "download_attachment", "/tasks/:id/attachments/:attachment/download"
+	case r.Method == "GET":
+		operation, endpoint = "get_attachment", "/tasks/:id/attachments/:attachment"
+	case r.Method == "DELETE" && !download:
+		operation, endpoint = "delete_attachment", "/tasks/:id/attachments/:attachment"
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	method := r.Method
+	if h.attachments == nil {
+		http.Error(w, "Attachments are not configured", http.StatusNotImplemented)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusNotImplemented)
+		return
+	}
+
+	id, ok := h.taskIDFromPath(w, r, start, method, endpoint, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	attachmentID, err := strconv.Atoi(r.PathValue("attachment"))
+	if err != nil {
+		http.Error(w, "Invalid attachment ID", http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+		return
+	}
+	span.SetAttributes(
+		attribute.String("operation", operation),
+		attribute.Int("task.id", id),
+		attribute.Int("attachment.id", attachmentID),
+	)
+
+	if method == "DELETE" {
+		attachment, err := h.db.DeleteAttachment(ctx, id, attachmentID)
+		if err != nil {
+			h.attachmentError(w, r, start, method, endpoint, err)
+			return
+		}
+		// The row is gone, so a failure here only leaves content nothing points to
+		if err := h.attachments.Delete(ctx, attachment.StorageKey); err != nil {
+			span.RecordError(err)
+			slog.WarnContext(ctx, "Failed to delete attachment content", "error", err, "key", attachment.StorageKey)
+		}
+		slog.InfoContext(ctx, "Deleted attachment", "id", attachmentID, "task_id", id)
+		w.WriteHeader(http.StatusNoContent)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusNoContent)
+		return
+	}
+
+	attachment, err := h.db.GetAttachment(ctx, id, attachmentID)
+	if err != nil {
+		h.attachmentError(w, r, start, method, endpoint, err)
+		return
+	}
+	if !download {
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, r, attachment)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusOK)
+		return
+	}
+
+	signed, err := h.attachments.SignedURL(ctx, attachment.StorageKey, attachment.Name, attachment.ContentType)
+	if err == nil {
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, signed, http.StatusFound)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusFound)
+		return
+	}
+	if !errors.Is(err, integrations.ErrSignedURLUnsupported) {
+		h.attachmentError(w, r, start, method, endpoint, err)
+		return
+	}
+	content, err := h.attachments.Open(ctx, attachment.StorageKey)
+	if err != nil {
+		h.attachmentError(w, r, start, method, endpoint, err)
+		return
+	}
+	defer content.Close()
+	// The type is the uploader's, so browsers are told not to render or sniff it
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", `"`+attachment.SHA256+`"`)
+	if _, err := io.Copy(w, content); err != nil {
+		slog.WarnContext(ctx, "Failed to send attachment content", "error", err, "id", attachmentID)
+	}
+	h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusOK)
+}
+
+// attachmentName trims a file name and reports whether it is one an attachment may have
+func attachmentName(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxAttachmentNameLength || strings.ContainsAny(name, "/\\\x00") {
+		return "", false
+	}
+	return name, true
+}
+
+// attachmentContentType normalizes a media type, application/octet-stream when empty
+func attachmentContentType(v string) (string, bool) {
+	if v == "" {
+		return "application/octet-stream", true
+	}
+	mediaType, params, err := mime.ParseMediaType(v)
+	if err != nil {
+		return "", false
+	}
+	return mime.FormatMediaType(mediaType, params), true
+}
+
+// attachmentError answers a failed attachment or upload request
+func (h *Handlers) attachmentError(w http.ResponseWriter, r *http.Request, start time.Time, method, endpoint string, err error) {
+	ctx := r.Context()
+	if h.abandonIfCanceled(ctx, start, method, endpoint) {
+		return
+	}
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		status = http.StatusNotFound
+		if r.PathValue("attachment") != "" {
+			http.Error(w, "Attachment not found", status)
+		} else if r.PathValue("upload") != "" {
+			http.Error(w, "Upload not found", status)
+		} else {
+			http.Error(w, "Task not found", status)
+		}
+	case errors.Is(err, store.ErrQuotaExceeded):
+		status = http.StatusForbidden
+		slog.WarnContext(ctx, "Refused attachment over quota", "error", err)
+		http.Error(w, err.Error(), status)
+	case errors.Is(err, integrations.ErrBlobNotFound):
+		status = http.StatusNotFound
+		slog.ErrorContext(ctx, "Attachment content is missing from storage", "error", err)
+		http.Error(w, "Attachment content not found", status)
+	default:
+		trace.SpanFromContext(ctx).RecordError(err)
+		slog.ErrorContext(ctx, "Error handling attachment", "error", err, "endpoint", endpoint)
+		http.Error(w, "Internal server error", status)
+	}
+	h.recordRequestMetrics(ctx, start, method, endpoint, status)
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// UseBackups serves /admin/backups from backups. Without it those routes answer 501.
+func (h *Handlers) UseBackups(backups *store.Backups) {
+	h.backups = backups
+}
+
+// Backups serves GET and POST /admin/backups
+func (h *Handlers) Backups(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" && r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.backups == nil {
+		http.Error(w, "Backups are only available with a SQLite database and TODO_STORE=sql", http.StatusNotImplemented)
+		h.recordRequestMetrics(ctx, start, r.Method, "/admin/backups", http.StatusNotImplemented)
+		return
+	}
+
+	if r.Method == "GET" {
+		span.SetAttributes(attribute.String("operation", "list_backups"))
+		backups, err := h.backups.List()
+		if err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error listing backups", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.recordRequestMetrics(ctx, start, "GET", "/admin/backups", http.StatusInternalServerError)
+			return
+		}
+		h.recordRequestMetrics(ctx, start, "GET", "/admin/backups", writeList(w, r, backups))
+		return
+	}
+
+	span.SetAttributes(attribute.String("operation", "create_backup"))
+	backup, err := h.backups.Create(ctx, store.BackupTriggerAPI)
+	if backup == nil {
+		if h.abandonIfCanceled(ctx, start, "POST", "/admin/backups") {
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error backing up the database", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, "POST", "/admin/backups", http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Backed up the database but failed to remove old backups", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, r, backup)
+	slog.InfoContext(ctx, "Backed up the database", "name", backup.Name, "size_bytes", backup.SizeBytes)
+	h.recordRequestMetrics(ctx, start, "POST", "/admin/backups", http.StatusCreated)
+}
+
+// RestoreBackup serves POST /admin/backups/{name}/restore
+func (h *Handlers) RestoreBackup(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.backups == nil {
+		http.Error(w, "Backups are only available with a SQLite database and TODO_STORE=sql", http.StatusNotImplemented)
+		h.recordRequestMetrics(ctx, start, "POST", "/admin/backups/:name/restore", http.StatusNotImplemented)
+		return
+	}
+
+	name := r.PathValue("name")
+	span.SetAttributes(
+		attribute.String("operation", "restore_backup"),
+		attribute.String("backup.name", name),
+	)
+	slog.WarnContext(ctx, "Restoring the database from a backup", "name", name)
+
+	path, err := h.backups.Path(name)
+	if err == nil {
+		err = h.backups.Restore(ctx, path, store.BackupTriggerAPI)
+	}
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "POST", "/admin/backups/:name/restore") {
+			return
+		}
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, store.ErrBackupNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, store.ErrBackupSchemaMismatch):
+			status = http.StatusConflict
+		case errors.Is(err, store.ErrBackupInvalid):
+			status = http.StatusUnprocessableEntity
+		}
+		if status == http.StatusInternalServerError {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error restoring backup", "error", err, "name", name)
+			http.Error(w, "Internal server error", status)
+		} else {
+			http.Error(w, err.Error(), status)
+		}
+		h.recordRequestMetrics(ctx, start, "POST", "/admin/backups/:name/restore", status)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	slog.WarnContext(ctx, "Restored the database from a backup", "name", name)
+	h.recordRequestMetrics(ctx, start, "POST", "/admin/backups/:name/restore", http.StatusNoContent)
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// BulkTasks applies a batch of create/complete/delete operations atomically
+func (h *Handlers) BulkTasks(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Operations []store.BulkOperation `json:"operations"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Operations) == 0 {
+		http.Error(w, "At least one operation is required", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Operations) > store.MaxBulkOperations {
+		http.Error(w, fmt.Sprintf("At most %d operations are allowed per request", store.MaxBulkOperations), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("operation", "bulk_tasks"),
+		attribute.Int("bulk.operations", len(req.Operations)),
+	)
+	slog.InfoContext(ctx, "Applying bulk operations", "count", len(req.Operations))
+
+	if invalid := store.ValidateBulkOperations(req.Operations); invalid != nil {
+		slog.WarnContext(ctx, "Rejected invalid bulk operations", "invalid", len(invalid))
+		writeBulkResponse(w, r, http.StatusUnprocessableEntity, false, invalid)
+		h.recordRequestMetrics(ctx, start, "POST", "/tasks/bulk", http.StatusUnprocessableEntity)
+		return
+	}
+
+	results, err := h.db.ExecuteBulk(ctx, req.Operations)
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "POST", "/tasks/bulk") {
+			return
+		}
+		if errors.Is(err, store.ErrBulkAborted) {
+			slog.WarnContext(ctx, "Bulk operations rolled back")
+			writeBulkResponse(w, r, http.StatusUnprocessableEntity, false, results)
+			h.recordRequestMetrics(ctx, start, "POST", "/tasks/bulk", http.StatusUnprocessableEntity)
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error applying bulk operations", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, "POST", "/tasks/bulk", http.StatusInternalServerError)
+		return
+	}
+
+	// Rule notifications were written to the outbox with the changes; subscribers and
+	// webhooks hear about them here
+	var created, completed []*store.Task
+	var deleted []int
+	for _, result := range results {
+		if result.Op == store.BulkDelete {
+			deleted = append(deleted, result.ID)
+		}
+		if result.Task == nil {
+			continue
+		}
+		switch result.Op {
+		case store.BulkCreate:
+			created = append(created, result.Task)
+		case store.BulkComplete:
+			completed = append(completed, result.Task)
+		}
+	}
+	h.events.PublishTasks(ctx, store.EventTaskCreated, created...)
+	h.events.PublishTasks(ctx, store.EventTaskCompleted, completed...)
+	for _, id := range deleted {
+		h.events.Publish(ctx, store.BusEvent{Type: store.EventTaskDeleted, TaskID: id})
+	}
+
+	writeBulkResponse(w, r, http.StatusOK, true, results)
+	slog.InfoContext(ctx, "Bulk operations applied", "count", len(results))
+	h.recordRequestMetrics(ctx, start, "POST", "/tasks/bulk", http.StatusOK)
+}
+
+// BulkResponse is the body of a POST /tasks/bulk response
+type BulkResponse struct {
+	Committed bool               `json:"committed"`
+	Results   []store.BulkResult `json:"results"`
+}
+
+func writeBulkResponse(w http.ResponseWriter, r *http.Request, status int, committed bool, results []store.BulkResult) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	writeJSON(w, r, BulkResponse{Committed: committed, Results: results})
+}
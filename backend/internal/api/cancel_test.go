@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// cancelOnRead cancels the request context when the handler starts reading the body, as a
+// client that disconnects right after sending its request
+type cancelOnRead struct {
+	io.Reader
+	cancel context.CancelFunc
+}
+
+func (r cancelOnRead) Read(p []byte) (int, error) {
+	r.cancel()
+	return r.Reader.Read(p)
+}
+
+// cancelTest has handlers on a SQLite database, whose calls fail once the request context
+// is canceled, with the request metrics collected and an external server standing in for
+// the httpbin call, made for every published event as the integrations do
+type cancelTest struct {
+	h             *Handlers
+	router        http.Handler
+	metrics       *sdkmetric.ManualReader
+	externalCalls atomic.Int32
+}
+
+func newCancelTest(t *testing.T) *cancelTest {
+	t.Helper()
+	ct := &cancelTest{metrics: sdkmetric.NewManualReader()}
+	previous := otel.GetMeterProvider()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(ct.metrics)))
+	t.Cleanup(func() { otel.SetMeterProvider(previous) })
+
+	external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ct.externalCalls.Add(1)
+	}))
+	t.Cleanup(external.Close)
+
+	ct.h, ct.router = newTestHandlers(t, nil)
+	ct.h.events.OnPublish(func(ctx context.Context, event store.BusEvent) {
+		req, _ := http.NewRequestWithContext(context.WithoutCancel(ctx), "GET", external.URL, nil)
+		if resp, err := http.DefaultClient.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	})
+	return ct
+}
+
+// statusCount returns how many requests to route were recorded with status
+func (ct *cancelTest) statusCount(t *testing.T, method, endpoint string, status int) int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := ct.metrics.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("collecting metrics: %v", err)
+	}
+	want := attribute.NewSet(
+		attribute.String("method", method),
+		attribute.String("endpoint", endpoint),
+		attribute.Int("status_code", status),
+	)
+	var count int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if m.Name != "todo_app.requests" || !ok {
+				continue
+			}
+			for _, dp := range sum.DataPoints {
+				if dp.Attributes.Equals(&want) {
+					count += dp.Value
+				}
+			}
+		}
+	}
+	return count
+}
+
+func (ct *cancelTest) taskCount(t *testing.T) int {
+	t.Helper()
+	tasks, err := ct.h.db.GetAllTasks(context.Background(), store.TaskQuery{})
+	if err != nil {
+		t.Fatalf("GetAllTasks: %v", err)
+	}
+	return len(tasks)
+}
+
+func TestCreateTaskAbandonedWhenClientGoesAway(t *testing.T) {
+	ct := newCancelTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	body := cancelOnRead{Reader: strings.NewReader(`{"title": "Never stored"}`), cancel: cancel}
+	r := httptest.NewRequest("POST", "/tasks", body).WithContext(ctx)
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	ct.router.ServeHTTP(w, r)
+
+	if got := ct.statusCount(t, "POST", "/tasks", statusClientClosedRequest); got != 1 {
+		t.Errorf("requests recorded with 499 = %d, want 1", got)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("abandoned request got a response body: %s", w.Body)
+	}
+	if n := ct.taskCount(t); n != 0 {
+		t.Errorf("database holds %d tasks, want none written", n)
+	}
+	if n := ct.externalCalls.Load(); n != 0 {
+		t.Errorf("external API called %d times, want none", n)
+	}
+}
+
+func TestDeleteTaskAbandonedWhenClientGoesAway(t *testing.T) {
+	ct := newCancelTest(t)
+	task, err := ct.h.db.CreateTask(context.Background(), store.NewTask{Title: "Still here"})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := httptest.NewRequest("DELETE", "/tasks/"+strconv.Itoa(task.ID), nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	ct.router.ServeHTTP(w, r)
+
+	if got := ct.statusCount(t, "DELETE", "/tasks/:id", statusClientClosedRequest); got != 1 {
+		t.Errorf("requests recorded with 499 = %d, want 1", got)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("abandoned request got a response body: %s", w.Body)
+	}
+	if n := ct.taskCount(t); n != 1 {
+		t.Errorf("database holds %d tasks, want the task kept", n)
+	}
+	if n := ct.externalCalls.Load(); n != 0 {
+		t.Errorf("external API called %d times, want none", n)
+	}
+}
+
+// TestCreateTaskCompletesWithClient is the control for the tests above: the same request
+// without the disconnect writes the task and calls out
+func TestCreateTaskCompletesWithClient(t *testing.T) {
+	ct := newCancelTest(t)
+
+	w := serve(t, ct.router, "POST", "/tasks", `{"title": "Stored"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /tasks = %d %s, want 201", w.Code, w.Body)
+	}
+	if got := ct.statusCount(t, "POST", "/tasks", http.StatusCreated); got != 1 {
+		t.Errorf("requests recorded with 201 = %d, want 1", got)
+	}
+	if n := ct.taskCount(t); n != 1 {
+		t.Errorf("database holds %d tasks, want 1", n)
+	}
+	if n := ct.externalCalls.Load(); n != 1 {
+		t.Errorf("external API called %d times, want 1", n)
+	}
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// changeSeqHeader carries the change sequence: on a mutation, the sequence number of its
+// last change; on GET /tasks, the sequence the returned tasks are current to
+const changeSeqHeader = "X-Change-Seq"
+
+// changeSeqWriter adds the change sequence header to successful responses
+type changeSeqWriter struct {
+	http.ResponseWriter
+	changes     *store.ChangeSeq
+	wroteHeader bool
+}
+
+func (w *changeSeqWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if seq := w.changes.Value(); seq > 0 && statusCode < 300 {
+			w.Header().Set(changeSeqHeader, strconv.FormatInt(seq, 10))
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *changeSeqWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *changeSeqWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// ChangeSeqMiddleware returns the sequence number of the last change a mutation made in
+// the X-Change-Seq header, so a client can tell when GET /tasks?since_seq= has caught up
+// with its own writes
+func ChangeSeqMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" || r.Method == "OPTIONS" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, changes := store.WithChangeSeq(r.Context())
+		next.ServeHTTP(&changeSeqWriter{ResponseWriter: w, changes: changes}, r.WithContext(ctx))
+	})
+}
+
+// getTaskChanges serves GET /tasks?since_seq=N. Filters and sorting do not apply: a sync
+// client needs every change to keep its copy whole.
+func (h *Handlers) getTaskChanges(w http.ResponseWriter, r *http.Request, start time.Time, v string) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	sinceSeq, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || sinceSeq < 0 {
+		http.Error(w, "Invalid since_seq", http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, "GET", "/tasks", http.StatusBadRequest)
+		return
+	}
+	for _, param := range []string{"q", "tag", "list", "sort"} {
+		if r.URL.Query().Has(param) {
+			http.Error(w, fmt.Sprintf("since_seq cannot be combined with %s", param), http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, "GET", "/tasks", http.StatusBadRequest)
+			return
+		}
+	}
+
+	span.SetAttributes(
+		attribute.String("operation", "get_task_changes"),
+		attribute.Int64("query.since_seq", sinceSeq),
+	)
+	slog.InfoContext(ctx, "Getting task changes", "since_seq", sinceSeq)
+
+	changes, err := h.db.GetTaskChanges(ctx, sinceSeq)
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "GET", "/tasks") {
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error getting task changes", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, "GET", "/tasks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(changeSeqHeader, strconv.FormatInt(changes.Seq, 10))
+	writeJSON(w, r, changes)
+	h.recordRequestMetrics(ctx, start, "GET", "/tasks", http.StatusOK)
+}
+
+// GetTaskDelta handles GET /tasks/changes?since=RFC3339
+func (h *Handlers) GetTaskDelta(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	since, err := time.Parse(time.RFC3339, r.URL.Query().Get("since"))
+	if err != nil {
+		http.Error(w, "Invalid since, expected an RFC 3339 timestamp such as 2025-01-31T09:00:00Z", http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, "GET", "/tasks/changes", http.StatusBadRequest)
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("operation", "get_task_delta"),
+		attribute.String("query.since", since.Format(time.RFC3339)),
+	)
+	slog.InfoContext(ctx, "Getting task delta", "since", since)
+
+	delta, err := h.db.GetTaskDelta(ctx, since)
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "GET", "/tasks/changes") {
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error getting task delta", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, "GET", "/tasks/changes", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, delta)
+	h.recordRequestMetrics(ctx, start, "GET", "/tasks/changes", http.StatusOK)
+}
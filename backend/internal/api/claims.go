@@ -0,0 +1,102 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"todo-app/internal/requestctx"
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ClaimTask handles POST /tasks/{id}/claim and DELETE /tasks/{id}/claim for the requesting user
+func (h *Handlers) ClaimTask(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" && r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	method, endpoint := r.Method, "/tasks/:id/claim"
+
+	id, ok := h.taskIDFromPath(w, r, start, method, endpoint, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	userID := requestctx.User(r.Context())
+	span.SetAttributes(
+		attribute.Int("task.id", id),
+		attribute.String("user.id", userID),
+	)
+
+	var task *store.Task
+	var err error
+	if method == "POST" {
+		ttl := store.ClaimTTL
+		var req store.TaskClaim
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+			return
+		}
+		if req.TTLSeconds != 0 {
+			ttl = time.Duration(req.TTLSeconds) * time.Second
+			if ttl < 0 || ttl > store.MaxClaimTTL {
+				http.Error(w, fmt.Sprintf("ttl_seconds must be between 1 and %d", int(store.MaxClaimTTL.Seconds())), http.StatusBadRequest)
+				h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+				return
+			}
+		}
+		span.SetAttributes(
+			attribute.String("operation", "claim_task"),
+			attribute.Float64("claim.ttl_seconds", ttl.Seconds()),
+		)
+		slog.InfoContext(ctx, "Claiming task", "id", id, "ttl", ttl)
+		task, err = h.db.ClaimTask(ctx, id, userID, ttl)
+	} else {
+		span.SetAttributes(attribute.String("operation", "release_task"))
+		slog.InfoContext(ctx, "Releasing task", "id", id)
+		task, err = h.db.ReleaseTask(ctx, id, userID)
+	}
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, method, endpoint) {
+			return
+		}
+		if err == sql.ErrNoRows {
+			http.Error(w, "Task not found", http.StatusNotFound)
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusNotFound)
+		} else if errors.Is(err, store.ErrTaskClaimed) {
+			slog.WarnContext(ctx, "Task claim conflict", "id", id, "error", err)
+			http.Error(w, err.Error(), http.StatusConflict)
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusConflict)
+		} else {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error handling task claim", "error", err, "id", id)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, task)
+	h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusOK)
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GetCluster serves GET /admin/cluster, listing the live replicas and the leader
+func (h *Handlers) GetCluster(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	span.SetAttributes(attribute.String("operation", "get_cluster"))
+
+	status, err := h.cluster.Status(ctx)
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "GET", "/admin/cluster") {
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error listing cluster members", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, "GET", "/admin/cluster", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, status)
+	h.recordRequestMetrics(ctx, start, "GET", "/admin/cluster", http.StatusOK)
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// deadLetterRequest reads the kind and ID of a /admin/dead-letters/{kind}/{id} request
+func deadLetterRequest(r *http.Request) (string, int, bool) {
+	kind := r.PathValue("kind")
+	id, err := strconv.Atoi(r.PathValue("id"))
+	return kind, id, err == nil && slices.Contains(store.DeadLetterKinds, kind)
+}
+
+// GetDeadLetters serves GET /admin/dead-letters, the most recent dead letters of every kind
+// or those named in ?kind=
+func (h *Handlers) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	kinds := store.DeadLetterKinds
+	if v := r.URL.Query().Get("kind"); v != "" {
+		kinds = config.SplitList(v)
+		for _, kind := range kinds {
+			if !slices.Contains(store.DeadLetterKinds, kind) {
+				http.Error(w, "Invalid kind, expected one of "+strings.Join(store.DeadLetterKinds, ", "), http.StatusBadRequest)
+				h.recordRequestMetrics(ctx, start, "GET", "/admin/dead-letters", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > store.MaxDeadLetters {
+			http.Error(w, "Invalid limit, expected 1 to "+strconv.Itoa(store.MaxDeadLetters), http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, "GET", "/admin/dead-letters", http.StatusBadRequest)
+			return
+		}
+	}
+	page, err := parseListPage(r, 100)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, "GET", "/admin/dead-letters", http.StatusBadRequest)
+		return
+	}
+	span.SetAttributes(attribute.String("operation", "get_dead_letters"))
+
+	// One more than the page, to tell whether another follows
+	letters, err := h.db.DeadLetters(ctx, kinds, page.offset+page.limit+1)
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "GET", "/admin/dead-letters") {
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error listing dead letters", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, "GET", "/admin/dead-letters", http.StatusInternalServerError)
+		return
+	}
+
+	more := len(letters) > page.offset+page.limit
+	letters = letters[min(page.offset, len(letters)):min(page.offset+page.limit, len(letters))]
+	writeListPage(w, r, letters, page, more)
+	h.recordRequestMetrics(ctx, start, "GET", "/admin/dead-letters", http.StatusOK)
+}
+
+// DeadLetter serves GET and DELETE /admin/dead-letters/{kind}/{id}: inspect a dead letter
+// with its payload, or discard it
+func (h *Handlers) DeadLetter(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" && r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	kind, id, ok := deadLetterRequest(r)
+	if !ok {
+		http.Error(w, "Invalid dead letter", http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, r.Method, "/admin/dead-letters/:kind/:id", http.StatusBadRequest)
+		return
+	}
+	span.SetAttributes(attribute.String("dead_letter.kind", kind), attribute.Int("dead_letter.id", id))
+
+	var letter *store.DeadLetter
+	var err error
+	if r.Method == "GET" {
+		span.SetAttributes(attribute.String("operation", "get_dead_letter"))
+		letter, err = h.db.DeadLetter(ctx, kind, id)
+	} else {
+		span.SetAttributes(attribute.String("operation", "delete_dead_letter"))
+		slog.InfoContext(ctx, "Discarding dead letter", "kind", kind, "id", id)
+		err = h.db.DeleteDeadLetter(ctx, kind, id)
+	}
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, r.Method, "/admin/dead-letters/:kind/:id") {
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Dead letter not found", http.StatusNotFound)
+			h.recordRequestMetrics(ctx, start, r.Method, "/admin/dead-letters/:kind/:id", http.StatusNotFound)
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error handling dead letter", "error", err, "kind", kind, "id", id)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, r.Method, "/admin/dead-letters/:kind/:id", http.StatusInternalServerError)
+		return
+	}
+
+	if letter == nil {
+		w.WriteHeader(http.StatusNoContent)
+		h.recordRequestMetrics(ctx, start, r.Method, "/admin/dead-letters/:kind/:id", http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, letter)
+	h.recordRequestMetrics(ctx, start, r.Method, "/admin/dead-letters/:kind/:id", http.StatusOK)
+}
+
+// ReplayDeadLetter serves POST /admin/dead-letters/{kind}/{id}/replay. A webhook delivery
+// goes back in the delivery queue with a fresh set of attempts; a notification is sent
+// again through the outbound queue and stays a dead letter if it fails again. Either way
+// the outcome is asynchronous, so the response is 202.
+func (h *Handlers) ReplayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	kind, id, ok := deadLetterRequest(r)
+	if !ok {
+		http.Error(w, "Invalid dead letter", http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, "POST", "/admin/dead-letters/:kind/:id/replay", http.StatusBadRequest)
+		return
+	}
+	span.SetAttributes(
+		attribute.String("operation", "replay_dead_letter"),
+		attribute.String("dead_letter.kind", kind),
+		attribute.Int("dead_letter.id", id),
+	)
+	slog.InfoContext(ctx, "Replaying dead letter", "kind", kind, "id", id)
+
+	var err error
+	if kind == store.DeadLetterWebhook {
+		err = h.db.RequeueWebhookDelivery(ctx, id)
+	} else {
+		var n *store.FailedNotification
+		if n, err = h.db.GetFailedNotification(ctx, id); err == nil {
+			if !h.outbound.Enqueue(ctx, "dead_letter_replay", func(ctx context.Context) {
+				if err := h.notifications.Replay(ctx, n); err != nil {
+					slog.WarnContext(ctx, "Dead letter replay failed", "kind", kind, "id", id, "error", err)
+				}
+			}) {
+				http.Error(w, "Outbound queue full", http.StatusServiceUnavailable)
+				h.recordRequestMetrics(ctx, start, "POST", "/admin/dead-letters/:kind/:id/replay", http.StatusServiceUnavailable)
+				return
+			}
+		}
+	}
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "POST", "/admin/dead-letters/:kind/:id/replay") {
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Dead letter not found", http.StatusNotFound)
+			h.recordRequestMetrics(ctx, start, "POST", "/admin/dead-letters/:kind/:id/replay", http.StatusNotFound)
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error replaying dead letter", "error", err, "kind", kind, "id", id)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, "POST", "/admin/dead-letters/:kind/:id/replay", http.StatusInternalServerError)
+		return
+	}
+
+	h.deadLetterReplays.Add(ctx, 1, metric.WithAttributes(attribute.String("kind", kind)))
+	w.WriteHeader(http.StatusAccepted)
+	h.recordRequestMetrics(ctx, start, "POST", "/admin/dead-letters/:kind/:id/replay", http.StatusAccepted)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-app/internal/config"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// demoWriteLimit is how many writes a client IP may make per demoWriteWindow in demo mode
+	demoWriteLimit  = config.EnvInt("TODO_DEMO_WRITE_LIMIT", 30)
+	demoWriteWindow = config.EnvDuration("TODO_DEMO_WRITE_WINDOW", 10*time.Minute)
+	// trustedProxyHops is how many proxies in front of the server append to
+	// X-Forwarded-For; the client is the address the outermost one saw. With none, the
+	// header is ignored, since clients can set it to anything.
+	trustedProxyHops = config.EnvInt("TODO_TRUSTED_PROXY_HOPS", 0)
+)
+
+// DemoGuard protects a public demo instance (TODO_DEMO) by limiting the writes of each
+// client IP, across the replicas when Redis is configured. The admin endpoints need no
+// guarding here: the router's adminMiddleware keeps them to holders of TODO_ADMIN_TOKEN in
+// every mode.
+type DemoGuard struct {
+	writes *RateLimiter
+}
+
+// NewDemoGuard creates the guard configured by TODO_DEMO_WRITE_LIMIT. rdb may be nil.
+func NewDemoGuard(rdb *redis.Client) *DemoGuard {
+	return &DemoGuard{writes: newRateLimiter(demoWriteLimit, demoWriteWindow, rdb)}
+}
+
+// Middleware refuses writes over the client IP's limit with 429 and Retry-After; reads and
+// requests with the admin token are only subject to TODO_RATE_LIMIT. Outside demo mode it
+// does nothing.
+func (g *DemoGuard) Middleware(next http.Handler) http.Handler {
+	if !config.DemoMode {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		read := r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+		if read || isAdminRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ip := clientIP(r)
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("client.address", ip))
+		if ok, _, reset := g.writes.Allow(r.Context(), "ip:"+ip); !ok {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(reset.Seconds()))))
+			http.Error(w, "Demo write limit exceeded, try again later", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the address of the client behind TODO_TRUSTED_PROXY_HOPS proxies: the
+// entry of X-Forwarded-For the outermost trusted proxy appended, or the peer address
+func clientIP(r *http.Request) string {
+	if trustedProxyHops > 0 {
+		var hops []string
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			for _, hop := range strings.Split(header, ",") {
+				hops = append(hops, strings.TrimSpace(hop))
+			}
+		}
+		if i := len(hops) - trustedProxyHops; i >= 0 && i < len(hops) && net.ParseIP(hops[i]) != nil {
+			return hops[i]
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
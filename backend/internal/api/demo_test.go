@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"todo-app/internal/config"
+)
+
+func TestDemoGuard(t *testing.T) {
+	previousDemo, previousLimit, previousToken := config.DemoMode, demoWriteLimit, adminToken
+	config.DemoMode, demoWriteLimit, adminToken = true, 2, "let-me-in"
+	t.Cleanup(func() { config.DemoMode, demoWriteLimit, adminToken = previousDemo, previousLimit, previousToken })
+
+	_, router := newTestHandlers(t, nil)
+	handler := NewDemoGuard(nil).Middleware(router)
+	send := func(method, target, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, nil)
+		r.RemoteAddr = "203.0.113.7:4711"
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// The admin endpoints are refused by the router's admin check, the same as outside demo mode
+	if w := send("GET", "/admin/jobs", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("admin request without the token = %d, want 401 from the admin check", w.Code)
+	}
+	if w := send("GET", "/admin/jobs", "let-me-in"); w.Code == http.StatusUnauthorized || w.Code == http.StatusForbidden {
+		t.Errorf("admin request with the token = %d, want it let through", w.Code)
+	}
+
+	for i := range 2 {
+		if w := send("DELETE", "/tasks/999", ""); w.Code == http.StatusTooManyRequests {
+			t.Fatalf("write %d = 429, want it within the limit of 2", i+1)
+		}
+	}
+	w := send("DELETE", "/tasks/999", "")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("third write = %d with Retry-After %q, want 429 with Retry-After", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := send("GET", "/tasks", ""); w.Code != http.StatusOK {
+		t.Errorf("read over the write limit = %d, want 200", w.Code)
+	}
+	if w := send("DELETE", "/tasks/999", "let-me-in"); w.Code == http.StatusTooManyRequests {
+		t.Error("write with the admin token was limited")
+	}
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TaskDependencies serves GET and POST /tasks/{id}/dependencies and
+// DELETE /tasks/{id}/dependencies/{blocker}
+func (h *Handlers) TaskDependencies(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	ref, blockerRef := r.PathValue("id"), r.PathValue("blocker")
+
+	var operation, endpoint string
+	switch {
+	case r.Method == "GET" && blockerRef == "":
+		operation, endpoint = "get_task_dependencies", "/tasks/:id/dependencies"
+	case r.Method == "POST" && blockerRef == "":
+		operation, endpoint = "add_task_dependency", "/tasks/:id/dependencies"
+	case r.Method == "DELETE" && blockerRef != "":
+		operation, endpoint = "remove_task_dependency", "/tasks/:id/dependencies/:blocker"
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	method := r.Method
+
+	id, ok := h.taskIDFromPath(w, r, start, method, endpoint, ref)
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("operation", operation),
+		attribute.Int("task.id", id),
+	)
+
+	var body any
+	var err error
+	status := http.StatusOK
+	switch method {
+	case "GET":
+		body, err = h.db.GetTaskDependencies(ctx, id)
+	case "POST":
+		var req struct {
+			BlockedBy json.RawMessage `json:"blocked_by"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.BlockedBy) == 0 {
+			http.Error(w, "Invalid request body, expected {\"blocked_by\": <task id or uuid>}", http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+			return
+		}
+		var blockerID int
+		blockerID, err = h.db.ResolveTaskRef(ctx, strings.Trim(string(req.BlockedBy), `"`))
+		if err == nil {
+			span.SetAttributes(attribute.Int("task.blocked_by", blockerID))
+			slog.InfoContext(ctx, "Adding task dependency", "id", id, "blocked_by", blockerID)
+			body, err = h.db.AddTaskDependency(ctx, id, blockerID)
+			status = http.StatusCreated
+		}
+	case "DELETE":
+		var blockerID int
+		blockerID, err = h.db.ResolveTaskRef(ctx, blockerRef)
+		if errors.Is(err, store.ErrBlockerNotFound) {
+			err = sql.ErrNoRows
+		}
+		if err == nil {
+			span.SetAttributes(attribute.Int("task.blocked_by", blockerID))
+			slog.InfoContext(ctx, "Removing task dependency", "id", id, "blocked_by", blockerID)
+			err = h.db.RemoveTaskDependency(ctx, id, blockerID)
+			status = http.StatusNoContent
+		}
+	}
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, method, endpoint) {
+			return
+		}
+		status = http.StatusInternalServerError
+		switch {
+		case err == sql.ErrNoRows:
+			status = http.StatusNotFound
+			if method == "DELETE" {
+				http.Error(w, "Dependency not found", status)
+			} else {
+				http.Error(w, "Task not found", status)
+			}
+		case errors.Is(err, store.ErrBlockerNotFound):
+			status = http.StatusUnprocessableEntity
+			http.Error(w, "Blocking task not found", status)
+		case errors.Is(err, store.ErrSelfDependency):
+			status = http.StatusUnprocessableEntity
+			http.Error(w, "A task cannot block itself", status)
+		case errors.Is(err, store.ErrDependencyCycle):
+			status = http.StatusConflict
+			http.Error(w, "Dependency would create a cycle", status)
+		default:
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error handling task dependencies", "error", err, "id", id, "operation", operation)
+			http.Error(w, "Internal server error", status)
+		}
+		h.recordRequestMetrics(ctx, start, method, endpoint, status)
+		return
+	}
+
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		h.recordRequestMetrics(ctx, start, method, endpoint, status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	writeJSON(w, r, body)
+	h.recordRequestMetrics(ctx, start, method, endpoint, status)
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/integrations"
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxDiagnosticsCPUProfile caps the CPU profile so the bundle is written within the
+// server's write timeout
+const maxDiagnosticsCPUProfile = 10 * time.Second
+
+// processStart is when the process started, for the uptime in a diagnostics bundle
+var processStart = time.Now()
+
+// configSummary lists the TODO_* and OTEL_* environment variables that are set, with
+// secrets redacted, and the optional features they turn on
+func configSummary() map[string]any {
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, "TODO_") || strings.HasPrefix(name, "OTEL_") {
+			env[name] = telemetry.RedactValue(name, value)
+		}
+	}
+	return map[string]any{
+		"environment": env,
+		"features": map[string]bool{
+			"encryption":        store.EncryptionEnabled(),
+			"siem_export":       integrations.AuditExporter != nil,
+			"request_signing":   os.Getenv("TODO_SIGNING_CLIENTS") != "",
+			"otlp_export":       os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "",
+			"feature_overrides": adminToken != "",
+			"demo":              config.DemoMode,
+		},
+		"experimental_features": config.EnabledFeatures,
+	}
+}
+
+// runtimeSummary describes the process: Go version, memory, GC and the modules it was built with
+func runtimeSummary() map[string]any {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	summary := map[string]any{
+		"go_version":     runtime.Version(),
+		"os":             runtime.GOOS,
+		"arch":           runtime.GOARCH,
+		"num_cpu":        runtime.NumCPU(),
+		"gomaxprocs":     runtime.GOMAXPROCS(0),
+		"goroutines":     runtime.NumGoroutine(),
+		"heap_alloc":     mem.HeapAlloc,
+		"heap_sys":       mem.HeapSys,
+		"heap_objects":   mem.HeapObjects,
+		"total_alloc":    mem.TotalAlloc,
+		"sys":            mem.Sys,
+		"num_gc":         mem.NumGC,
+		"gc_pause_total": time.Duration(mem.PauseTotalNs).String(),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		deps := map[string]string{}
+		for _, dep := range info.Deps {
+			deps[dep.Path] = dep.Version
+		}
+		summary["main_module"] = info.Main.Path
+		summary["dependencies"] = deps
+	}
+	return summary
+}
+
+// diagnosticsBundle writes the files of a bundle into a zip. A section that fails is
+// recorded in the manifest rather than failing the whole bundle, since a bundle is most
+// wanted when something is broken.
+type diagnosticsBundle struct {
+	zip    *zip.Writer
+	now    time.Time
+	files  []string
+	errors map[string]string
+}
+
+func (b *diagnosticsBundle) Add(name string, write func(w io.Writer) error) {
+	w, err := b.zip.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: b.now})
+	if err == nil {
+		err = write(w)
+	}
+	if err != nil {
+		b.errors[name] = err.Error()
+		return
+	}
+	b.files = append(b.files, name)
+}
+
+// addJSON adds the indented JSON of the value v returns as the file name. Bundle files keep
+// the default JSON format, so tooling reads every bundle alike whatever the request negotiated.
+func (b *diagnosticsBundle) addJSON(name string, v func() (any, error)) {
+	b.Add(name, func(w io.Writer) error {
+		value, err := v()
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(value)
+	})
+}
+
+// writeDiagnostics builds a bundle: the config summary, schema and database statistics,
+// recent warnings and errors, runtime statistics, and goroutine, heap and optionally CPU
+// profiles
+func (h *Handlers) writeDiagnostics(ctx context.Context, out io.Writer, cpuProfile time.Duration) error {
+	now := time.Now().UTC()
+	b := &diagnosticsBundle{zip: zip.NewWriter(out), now: now, errors: map[string]string{}}
+
+	b.addJSON("config.json", func() (any, error) { return configSummary(), nil })
+	b.addJSON("database.json", func() (any, error) { return h.db.Diagnostics(ctx) })
+	b.addJSON("logs.json", func() (any, error) { return telemetry.RecentLogs.Entries(), nil })
+	b.addJSON("slo.json", func() (any, error) { return h.slo.Summary(), nil })
+	b.addJSON("runtime.json", func() (any, error) { return runtimeSummary(), nil })
+	b.Add("goroutines.txt", func(w io.Writer) error { return pprof.Lookup("goroutine").WriteTo(w, 1) })
+	b.Add("heap.pprof", func(w io.Writer) error { return pprof.Lookup("heap").WriteTo(w, 0) })
+	if cpuProfile > 0 {
+		b.Add("cpu.pprof", func(w io.Writer) error {
+			if err := pprof.StartCPUProfile(w); err != nil {
+				return err
+			}
+			select {
+			case <-time.After(cpuProfile):
+			case <-ctx.Done():
+			}
+			pprof.StopCPUProfile()
+			return ctx.Err()
+		})
+	}
+
+	hostname, _ := os.Hostname()
+	b.addJSON("manifest.json", func() (any, error) {
+		return map[string]any{
+			"generated_at":    now,
+			"service_version": telemetry.ServiceVersion,
+			"hostname":        hostname,
+			"instance_id":     telemetry.InstanceID,
+			"cluster_leader":  h.cluster.IsLeader(),
+			"pid":             os.Getpid(),
+			"started_at":      processStart.UTC(),
+			"uptime_seconds":  int(now.Sub(processStart).Seconds()),
+			"files":           b.files,
+			"errors":          b.errors,
+		}, nil
+	})
+	return b.zip.Close()
+}
+
+// GetDiagnostics serves GET /admin/diagnostics, a zip of redacted diagnostics to attach to
+// a bug report. ?cpu_seconds= adds a CPU profile of that many seconds (at most 10).
+func (h *Handlers) GetDiagnostics(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var cpuProfile time.Duration
+	if v := r.URL.Query().Get("cpu_seconds"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds < 0 || time.Duration(seconds)*time.Second > maxDiagnosticsCPUProfile {
+			http.Error(w, fmt.Sprintf("cpu_seconds must be between 0 and %d", int(maxDiagnosticsCPUProfile.Seconds())), http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, "GET", "/admin/diagnostics", http.StatusBadRequest)
+			return
+		}
+		cpuProfile = time.Duration(seconds) * time.Second
+	}
+
+	span.SetAttributes(
+		attribute.String("operation", "diagnostics_bundle"),
+		attribute.Int64("diagnostics.cpu_profile_seconds", int64(cpuProfile.Seconds())),
+	)
+	slog.InfoContext(ctx, "Building diagnostics bundle", "cpu_profile", cpuProfile)
+
+	// Built in memory so a failure can still be reported with a status code
+	var buf bytes.Buffer
+	if err := h.writeDiagnostics(ctx, &buf, cpuProfile); err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error building diagnostics bundle", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, "GET", "/admin/diagnostics", http.StatusInternalServerError)
+		return
+	}
+
+	span.SetAttributes(attribute.Int("diagnostics.bytes", buf.Len()))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="todo-app-diagnostics-%s.zip"`,
+		time.Now().UTC().Format("20060102-150405")))
+	w.Write(buf.Bytes())
+	h.recordRequestMetrics(ctx, start, "GET", "/admin/diagnostics", http.StatusOK)
+}
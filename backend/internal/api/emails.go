@@ -0,0 +1,63 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"todo-app/internal/integrations"
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+)
+
+// PreviewEmail handles GET /admin/emails, listing the templates, and GET /admin/emails/{name},
+// rendering one with sample data so overrides can be checked in a browser. ?format=text
+// shows the plaintext part.
+func (h *Handlers) PreviewEmail(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.PathValue("name")
+	if name == "" {
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, r, h.emails.Names())
+		return
+	}
+
+	due := time.Now().Add(time.Hour)
+	sample := store.Task{ID: 1, Title: "Renew passport", Description: "Bring two photos.", DueAt: &due}
+	now := time.Now()
+	email, err := h.emails.Render(name, integrations.EmailData{
+		Event: store.EventTaskDue,
+		Task:  &sample,
+		Tasks: []store.Task{sample, {ID: 2, Title: "Book flights", Completed: true}},
+		Report: &telemetry.HealthReport{
+			Instance: telemetry.InstanceID, From: now.Add(-integrations.HealthReportInterval), To: now,
+			Requests: 1200, Errors: 3, ErrorRate: 0.0025, P95Ms: 42.5,
+			Routes: []telemetry.RouteHealth{
+				{Route: "POST /tasks", Requests: 200, Errors: 3, ErrorRate: 0.015, P95Ms: 61.2},
+				{Route: "GET /tasks", Requests: 1000, P95Ms: 38.4},
+			},
+			SlowestQueries: []telemetry.QueryHealth{{Statement: "SELECT id, title FROM tasks WHERE deleted_at IS NULL", Count: 1000, MaxMs: 180.3, AvgMs: 4.1}},
+			ExportFailures: map[string]int{"spans": 2},
+		},
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "Email preview failed", "template", name, "error", err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("X-Email-Subject", email.Subject)
+	if r.URL.Query().Get("format") == "text" || email.HTML == "" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(email.Text))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(email.HTML))
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-app/internal/store"
+)
+
+// taskETag is the entity tag of a task's JSON, its updated_at in nanoseconds. The same
+// instant always reads back the same from the database, so the tag is stable until the
+// task changes.
+func taskETag(task *store.Task) string {
+	return `"` + strconv.FormatInt(task.UpdatedAt.UnixNano(), 36) + `"`
+}
+
+// etagTime returns the updated_at an entity tag from taskETag stands for
+func etagTime(tag string) (time.Time, bool) {
+	opaque, ok := strings.CutPrefix(tag, `"`)
+	if !ok {
+		return time.Time{}, false
+	}
+	if opaque, ok = strings.CutSuffix(opaque, `"`); !ok {
+		return time.Time{}, false
+	}
+	nanos, err := strconv.ParseInt(opaque, 36, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, nanos).UTC(), true
+}
+
+// ifNoneMatch reports whether r's If-None-Match matches etag, so a GET can answer 304.
+// Comparison is weak, as RFC 9110 asks for If-None-Match.
+func ifNoneMatch(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatch reads r's If-Match for a conditional update. It returns the updated_at the
+// client's copy of the task has, or nil when the header is absent or "*". A tag that is
+// weak, malformed or one of several can never match; the update then fails with 412.
+func ifMatch(r *http.Request) (*time.Time, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, true
+	}
+	at, ok := etagTime(header)
+	if !ok {
+		return nil, false
+	}
+	return &at, true
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GetTaskHistory handles GET /tasks/{id}/history
+func (h *Handlers) GetTaskHistory(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := h.taskIDFromPath(w, r, start, "GET", "/tasks/:id/history", r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("operation", "get_task_history"),
+		attribute.Int("task.id", id),
+	)
+
+	events, err := h.db.GetTaskHistory(ctx, id)
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "GET", "/tasks/:id/history") {
+			return
+		}
+		if err == sql.ErrNoRows {
+			http.Error(w, "Task not found", http.StatusNotFound)
+			h.recordRequestMetrics(ctx, start, "GET", "/tasks/:id/history", http.StatusNotFound)
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error getting task history", "error", err, "id", id)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, "GET", "/tasks/:id/history", http.StatusInternalServerError)
+		return
+	}
+
+	h.recordRequestMetrics(ctx, start, "GET", "/tasks/:id/history", writeList(w, r, events))
+}
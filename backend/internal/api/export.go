@@ -0,0 +1,186 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ExportFormatMarkdown is the only export format supported so far
+const ExportFormatMarkdown = "markdown"
+
+// exportGroup is one section of an export, rendered under its own heading
+type exportGroup struct {
+	Name  string
+	Tasks []store.Task
+}
+
+// groupTasksForExport splits tasks into one section per list, in the order lists are
+// given. Lists without tasks are left out.
+func groupTasksForExport(tasks []store.Task, lists []store.List) []exportGroup {
+	byList := make(map[int][]store.Task)
+	for _, task := range tasks {
+		byList[task.ListID] = append(byList[task.ListID], task)
+	}
+
+	var groups []exportGroup
+	for _, list := range lists {
+		if len(byList[list.ID]) > 0 {
+			groups = append(groups, exportGroup{Name: list.Name, Tasks: byList[list.ID]})
+		}
+	}
+	if len(groups) == 0 {
+		groups = append(groups, exportGroup{Name: store.DefaultListName})
+	}
+	return groups
+}
+
+// renderMarkdownChecklist renders groups as a GitHub-flavored markdown checklist. Open
+// tasks come before completed ones, each in manual order, subtasks are nested under their parent, and
+// descriptions are indented under their task so they stay attached to it when pasted
+// into notes apps or PR descriptions. POST /import/markdown reads the same layout back.
+func renderMarkdownChecklist(groups []exportGroup, exportedAt time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Tasks\n\n_Exported %s_\n", exportedAt.UTC().Format("2006-01-02 15:04 MST"))
+
+	for _, group := range groups {
+		fmt.Fprintf(&b, "\n## %s\n\n", group.Name)
+		if len(group.Tasks) == 0 {
+			b.WriteString("_No tasks_\n")
+			continue
+		}
+
+		inGroup := make(map[int]bool, len(group.Tasks))
+		for _, task := range group.Tasks {
+			inGroup[task.ID] = true
+		}
+		var roots []store.Task
+		children := make(map[int][]store.Task)
+		for _, task := range group.Tasks {
+			if task.ParentID != nil && inGroup[*task.ParentID] {
+				children[*task.ParentID] = append(children[*task.ParentID], task)
+			} else {
+				roots = append(roots, task)
+			}
+		}
+		writeChecklistItems(&b, roots, children, "")
+	}
+
+	return b.String()
+}
+
+// writeChecklistItems writes tasks and, recursively, their subtasks at the given indent
+func writeChecklistItems(b *strings.Builder, tasks []store.Task, children map[int][]store.Task, indent string) {
+	tasks = append([]store.Task(nil), tasks...)
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if tasks[i].Completed != tasks[j].Completed {
+			return !tasks[i].Completed
+		}
+		return tasks[i].Position < tasks[j].Position
+	})
+
+	for _, task := range tasks {
+		box := " "
+		if task.Completed {
+			box = "x"
+		}
+		fmt.Fprintf(b, "%s- [%s] %s", indent, box, task.Title)
+		if task.DueAt != nil {
+			fmt.Fprintf(b, " (due %s)", task.DueAt.UTC().Format("2006-01-02"))
+		}
+		b.WriteString("\n")
+
+		if description := strings.TrimSpace(task.Description); description != "" {
+			b.WriteString("\n")
+			for _, line := range strings.Split(description, "\n") {
+				if line == "" {
+					b.WriteString("\n")
+					continue
+				}
+				fmt.Fprintf(b, "%s  %s\n", indent, line)
+			}
+			b.WriteString("\n")
+		}
+
+		writeChecklistItems(b, children[task.ID], children, indent+"  ")
+	}
+}
+
+// ExportTasks handles GET /tasks/export?format=markdown[&list=ID]
+func (h *Handlers) ExportTasks(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = ExportFormatMarkdown
+	}
+	if format != ExportFormatMarkdown {
+		http.Error(w, "Invalid format, expected markdown", http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, "GET", "/tasks/export", http.StatusBadRequest)
+		return
+	}
+
+	query := store.TaskQuery{Sort: store.SortCreated}
+	if v := r.URL.Query().Get("list"); v != "" {
+		listID, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid list ID", http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, "GET", "/tasks/export", http.StatusBadRequest)
+			return
+		}
+		query.ListID = &listID
+	}
+
+	span.SetAttributes(
+		attribute.String("operation", "export_tasks"),
+		attribute.String("export.format", format),
+	)
+	slog.InfoContext(ctx, "Exporting tasks", "format", format)
+
+	tasks, err := h.store.GetAllTasks(ctx, query)
+	var lists []store.List
+	if err == nil {
+		lists, err = h.db.GetLists(ctx)
+	}
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "GET", "/tasks/export") {
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error exporting tasks", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, "GET", "/tasks/export", http.StatusInternalServerError)
+		return
+	}
+
+	span.SetAttributes(attribute.Int("export.task_count", len(tasks)))
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Write([]byte(renderMarkdownChecklist(groupTasksForExport(tasks, lists), time.Now())))
+
+	slog.InfoContext(ctx, "Successfully exported tasks", "count", len(tasks), "format", format)
+	h.recordRequestMetrics(ctx, start, "GET", "/tasks/export", http.StatusOK)
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/requestctx"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// featureOverrideHeader turns experimental features on for a single request. It is only
+// honored with the admin token, so canaries can be run in production without exposing the
+// experimental code paths to everyone.
+const featureOverrideHeader = "X-Feature-Override"
+
+// adminToken authorizes feature overrides and the /admin endpoints, sent as
+// "Authorization: Bearer <token>"; both are refused when it is empty
+var adminToken = config.EnvString("TODO_ADMIN_TOKEN", "")
+
+// isAdminRequest reports whether r carries the admin token
+func isAdminRequest(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+// adminMiddleware refuses a request to the admin route pattern with 401 unless it carries
+// the admin token, so an unset TODO_ADMIN_TOKEN turns the admin endpoints off
+func (h *Handlers) adminMiddleware(pattern string, next http.Handler) http.Handler {
+	method, endpoint, _ := strings.Cut(pattern, " ")
+	endpoint = routeEndpoint(endpoint)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isAdminRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		ctx := r.Context()
+		slog.WarnContext(ctx, "Refused admin request without the admin token", "route", pattern)
+		h.enableCORS(w)
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "The admin endpoints need the admin token", http.StatusUnauthorized)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusUnauthorized)
+	})
+}
+
+// FeatureOverrideMiddleware applies X-Feature-Override, a comma-separated list of features
+// to turn on for this request. Without the admin token the request is refused rather than
+// served without the features, so a canary never silently tests the old path. The features
+// are recorded on the request span as feature.overrides.
+func FeatureOverrideMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get(featureOverrideHeader)
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := r.Context()
+		if !isAdminRequest(r) {
+			slog.WarnContext(ctx, "Refused feature override without the admin token", "features", header)
+			http.Error(w, "Feature overrides require the admin token", http.StatusForbidden)
+			return
+		}
+		features := config.SplitList(header)
+		if err := config.CheckFeatures(features); err != nil {
+			http.Error(w, "Invalid "+featureOverrideHeader+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		trace.SpanFromContext(ctx).SetAttributes(attribute.StringSlice("feature.overrides", features))
+		slog.InfoContext(ctx, "Feature override", "features", features)
+		next.ServeHTTP(w, r.WithContext(requestctx.WithFeatures(ctx, features)))
+	})
+}
+
+// hasFeatureOverrides reports whether the request ctx belongs to turned any feature on
+func hasFeatureOverrides(ctx context.Context) bool {
+	_, ok := requestctx.Features(ctx)
+	return ok
+}
@@ -0,0 +1,490 @@
+package api
+
+//go:generate protoc --proto_path=../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative taskpb/tasks.proto
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/requestctx"
+	"todo-app/internal/store"
+	"todo-app/taskpb"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/text/language"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GRPCAddr is where the gRPC TaskService listens; empty turns it off
+var GRPCAddr = config.EnvString("TODO_GRPC_ADDR", ":9090")
+
+// Metadata keys of the gRPC API, the counterparts of X-User-ID, X-Request-ID, X-Trace-ID,
+// X-Tenant-ID and X-Change-Seq
+const (
+	grpcUserIDKey    = "x-user-id"
+	grpcRequestIDKey = "x-request-id"
+	grpcTraceIDKey   = "x-trace-id"
+	grpcTenantKey    = "x-tenant-id"
+	grpcChangeSeqKey = "x-change-seq"
+	// grpcTruncatedKey is set on a ListTasks response cut to TODO_MAX_LIST_ROWS, which has
+	// no paging of its own; narrow the query, or page with the HTTP API
+	grpcTruncatedKey = "x-list-truncated"
+)
+
+// grpcMethod is the method recorded in request metrics and SLOs for gRPC calls, whose
+// endpoint is the full method name
+const grpcMethod = "GRPC"
+
+// taskServer implements the gRPC TaskService on the same database, event bus and
+// notifications as the HTTP handlers
+type taskServer struct {
+	taskpb.UnimplementedTaskServiceServer
+	h *Handlers
+}
+
+// NewGRPCServer returns a gRPC server for the TaskService. otelgrpc traces every call,
+// continuing traces propagated in the metadata, and records the rpc.server.* metrics;
+// calls are also counted in the request metrics and SLOs under the GRPC method.
+func NewGRPCServer(h *Handlers, verifier *RequestVerifier, limiter *RateLimiter) *grpc.Server {
+	s := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(h.grpcUnaryInterceptor(verifier, limiter)),
+		grpc.ChainStreamInterceptor(h.grpcStreamInterceptor(verifier, limiter)),
+	)
+	taskpb.RegisterTaskServiceServer(s, &taskServer{h: h})
+	return s
+}
+
+// grpcUser returns the user a call acts for from its metadata. Requests cannot be signed
+// over gRPC, so users that belong to a signing client are refused. Calls count towards the
+// user's rate limit like HTTP requests.
+func grpcUser(ctx context.Context, verifier *RequestVerifier, limiter *RateLimiter, fullMethod string) (string, error) {
+	userID := requestctx.DefaultUser
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(grpcUserIDKey); len(values) > 0 && strings.TrimSpace(values[0]) != "" {
+			userID = strings.TrimSpace(values[0])
+		}
+	}
+	if !verifier.AllowsUnsigned(ctx, userID, grpcMethod, fullMethod) {
+		return "", status.Error(codes.Unauthenticated, "Requests for this user must be signed, which only the HTTP API supports")
+	}
+	if ok, _, reset := limiter.Allow(ctx, userID); !ok {
+		return "", status.Errorf(codes.ResourceExhausted, "Rate limit exceeded, retry in %s", reset.Round(time.Second))
+	}
+	return userID, nil
+}
+
+// grpcRequestContext sets the request context up as RequestContextMiddleware and
+// UserMiddleware do over HTTP. The locale stays unset, since ListTasks asks for one in its
+// request.
+func grpcRequestContext(ctx context.Context, userID string) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := uuid.NewString()
+	if values := md.Get(grpcRequestIDKey); len(values) > 0 && values[0] != "" && len(values[0]) <= maxRequestIDLength {
+		id = values[0]
+	}
+	ctx = requestctx.WithUser(requestctx.WithRequestID(ctx, id), userID)
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("request.id", id))
+	if values := md.Get(grpcTenantKey); len(values) > 0 && strings.TrimSpace(values[0]) != "" {
+		ctx = requestctx.WithTenant(ctx, strings.TrimSpace(values[0]))
+		span.SetAttributes(attribute.String("tenant.id", requestctx.Tenant(ctx)))
+	}
+	if attrs := requestBaggage(ctx); len(attrs) > 0 {
+		span.SetAttributes(attrs...)
+		ctx = requestctx.WithBaggage(ctx, attrs)
+	}
+	return ctx
+}
+
+// grpcRequestHeader is the response metadata every call gets: its request ID and, when
+// traced, its trace ID
+func grpcRequestHeader(ctx context.Context) metadata.MD {
+	md := metadata.Pairs(grpcRequestIDKey, requestctx.RequestID(ctx))
+	if id := traceID(ctx); id != "" {
+		md.Set(grpcTraceIDKey, id)
+	}
+	return md
+}
+
+// grpcUnaryInterceptor sets the request context and change sequence up as the HTTP
+// middleware do, and records the request metrics
+func (h *Handlers) grpcUnaryInterceptor(verifier *RequestVerifier, limiter *RateLimiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		userID, err := grpcUser(ctx, verifier, limiter, info.FullMethod)
+		if err != nil {
+			h.recordRequestMetrics(ctx, start, grpcMethod, info.FullMethod, httpStatusFromCode(status.Code(err)))
+			return nil, err
+		}
+		ctx, changes := store.WithChangeSeq(grpcRequestContext(ctx, userID))
+		grpc.SetHeader(ctx, grpcRequestHeader(ctx))
+
+		resp, err := handler(ctx, req)
+		if seq := changes.Value(); seq > 0 && err == nil {
+			grpc.SetHeader(ctx, metadata.Pairs(grpcChangeSeqKey, strconv.FormatInt(seq, 10)))
+		}
+		h.recordRequestMetrics(context.WithoutCancel(ctx), start, grpcMethod, info.FullMethod, httpStatusFromCode(status.Code(err)))
+		return resp, err
+	}
+}
+
+// grpcStreamInterceptor sets the request context up for streaming calls. Streams are not counted in the
+// request metrics, since their duration is how long the client watched.
+func (h *Handlers) grpcStreamInterceptor(verifier *RequestVerifier, limiter *RateLimiter) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		userID, err := grpcUser(ss.Context(), verifier, limiter, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		ctx := grpcRequestContext(ss.Context(), userID)
+		ss.SetHeader(grpcRequestHeader(ctx))
+		return handler(srv, &userStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// userStream is a ServerStream whose context carries the request context
+type userStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *userStream) Context() context.Context {
+	return s.ctx
+}
+
+// httpStatusFromCode maps a gRPC status code to the HTTP status recorded in request metrics
+// and SLOs, so both APIs are summarized alike
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.FailedPrecondition, codes.Aborted, codes.AlreadyExists:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Canceled:
+		return statusClientClosedRequest
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// grpcError converts an error from the database layer to a gRPC status, recording
+// unexpected ones on the span
+func grpcError(ctx context.Context, err error, action string) error {
+	switch {
+	case ctx.Err() != nil:
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("request.canceled", true))
+		return status.FromContextError(ctx.Err()).Err()
+	case errors.Is(err, sql.ErrNoRows):
+		return status.Error(codes.NotFound, "Task not found")
+	case errors.Is(err, store.ErrTaskDeleted):
+		return status.Error(codes.NotFound, "Task has been deleted")
+	case errors.Is(err, store.ErrListNotFound):
+		return status.Error(codes.NotFound, "List not found")
+	case errors.Is(err, store.ErrTaskBlocked), errors.Is(err, store.ErrTaskClaimed):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, store.ErrQuotaExceeded):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case store.IsUnavailable(err):
+		slog.WarnContext(ctx, "Database unavailable while "+action, "error", err)
+		return status.Error(codes.Unavailable, "Database unavailable, retry shortly")
+	}
+	trace.SpanFromContext(ctx).RecordError(err)
+	slog.ErrorContext(ctx, "Error "+action, "error", err)
+	return status.Error(codes.Internal, "Internal server error")
+}
+
+// taskID resolves a task reference, the numeric ID or the UUID
+func (s *taskServer) taskID(ctx context.Context, ref string) (int, error) {
+	if id, err := strconv.Atoi(ref); err == nil {
+		return id, nil
+	}
+	taskUUID, ok := store.ParseTaskUUID(ref)
+	if !ok {
+		return 0, status.Error(codes.InvalidArgument, "Invalid task ID")
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("task.uuid", taskUUID))
+	id, err := s.h.store.TaskIDByUUID(ctx, taskUUID)
+	if merged := (*store.TaskMergedError)(nil); errors.As(err, &merged) {
+		return merged.Into, nil
+	}
+	if err != nil {
+		return 0, grpcError(ctx, err, "resolving task UUID")
+	}
+	return id, nil
+}
+
+func (s *taskServer) ListTasks(ctx context.Context, req *taskpb.ListTasksRequest) (*taskpb.ListTasksResponse, error) {
+	span := trace.SpanFromContext(ctx)
+
+	query := store.TaskQuery{Search: req.Search, Tag: req.Tag, Sort: req.Sort, Locale: store.DefaultLocale}
+	if query.Sort == "" {
+		query.Sort = store.SortCreated
+	}
+	if query.Sort != store.SortCreated && query.Sort != store.SortTitle && query.Sort != store.SortPosition {
+		return nil, status.Error(codes.InvalidArgument, "Invalid sort, expected created_at, title or position")
+	}
+	if req.Locale != "" {
+		if tag, err := language.Parse(req.Locale); err == nil {
+			query.Locale = tag
+		}
+	}
+	if req.ListId != nil {
+		listID := int(*req.ListId)
+		query.ListID = &listID
+		span.SetAttributes(attribute.Int("query.list_id", listID))
+	}
+	// One more than the maximum, to tell whether the list was cut
+	query.Limit = maxListRows + 1
+
+	span.SetAttributes(
+		attribute.String("operation", "get_all_tasks"),
+		attribute.String("query.sort", query.Sort),
+		attribute.String("query.locale", query.Locale.String()),
+	)
+	slog.InfoContext(ctx, "Getting all tasks", "sort", query.Sort, "search", query.Search)
+
+	// Read before the tasks, so a change racing with the list is returned again by the next since_seq
+	seq, err := s.h.store.ChangeSeq(ctx)
+	var tasks []store.Task
+	if err == nil {
+		tasks, err = s.h.store.GetAllTasks(ctx, query)
+	}
+	if err != nil {
+		return nil, grpcError(ctx, err, "getting tasks")
+	}
+	if len(tasks) > maxListRows {
+		tasks = tasks[:maxListRows]
+		span.SetAttributes(attribute.Bool("list.truncated", true))
+		grpc.SetHeader(ctx, metadata.Pairs(grpcTruncatedKey, "true"))
+	}
+
+	resp := &taskpb.ListTasksResponse{Seq: seq, Tasks: make([]*taskpb.Task, len(tasks))}
+	for i := range tasks {
+		resp.Tasks[i] = taskToProto(&tasks[i])
+	}
+	slog.InfoContext(ctx, "Successfully retrieved tasks", "count", len(tasks))
+	return resp, nil
+}
+
+func (s *taskServer) CreateTask(ctx context.Context, req *taskpb.CreateTaskRequest) (*taskpb.Task, error) {
+	span := trace.SpanFromContext(ctx)
+
+	input := store.NewTask{Tags: store.Tags(req.Tags)}
+	title, err := store.NormalizeTitle(req.Title)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	input.Title = title
+	if input.Description, err = store.NormalizeDescription(req.Description); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if input.Tags, err = store.NormalizeTags(input.Tags); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if req.DueAt != nil {
+		dueAt := req.DueAt.AsTime()
+		input.DueAt = &dueAt
+	}
+	if req.ListId != nil {
+		listID := int(*req.ListId)
+		input.ListID = &listID
+	}
+
+	span.SetAttributes(
+		attribute.String("operation", "create_task"),
+		attribute.String("task.title", input.Title),
+	)
+	store.RecordTitleNormalization(span, req.Title, input.Title)
+	slog.InfoContext(ctx, "Creating new task", "title", input.Title)
+
+	task, err := s.h.store.CreateTask(ctx, input)
+	if err != nil {
+		return nil, grpcError(ctx, err, "creating task")
+	}
+	s.h.events.PublishTasks(ctx, store.EventTaskCreated, task)
+
+	slog.InfoContext(ctx, "Task created successfully", "id", task.ID, "title", task.Title)
+	return taskToProto(task), nil
+}
+
+func (s *taskServer) CompleteTask(ctx context.Context, req *taskpb.CompleteTaskRequest) (*taskpb.Task, error) {
+	id, err := s.taskID(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("operation", "complete_task"),
+		attribute.Int("task.id", id),
+	)
+	slog.InfoContext(ctx, "Completing task", "id", id)
+
+	task, err := s.h.store.CompleteTask(ctx, id)
+	if err != nil {
+		return nil, grpcError(ctx, err, "completing task")
+	}
+
+	s.h.events.PublishTasks(ctx, store.EventTaskCompleted, task)
+
+	slog.InfoContext(ctx, "Task completed successfully", "id", task.ID, "title", task.Title)
+	return taskToProto(task), nil
+}
+
+func (s *taskServer) DeleteTask(ctx context.Context, req *taskpb.DeleteTaskRequest) (*taskpb.DeleteTaskResponse, error) {
+	id, err := s.taskID(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("operation", "delete_task"),
+		attribute.Int("task.id", id),
+	)
+	slog.InfoContext(ctx, "Deleting task", "id", id)
+
+	if err := s.h.store.DeleteTask(ctx, id); err != nil {
+		return nil, grpcError(ctx, err, "deleting task")
+	}
+
+	s.h.events.Publish(ctx, store.BusEvent{Type: store.EventTaskDeleted, TaskID: id})
+
+	slog.InfoContext(ctx, "Task deleted successfully", "id", id)
+	return &taskpb.DeleteTaskResponse{}, nil
+}
+
+// Watch streams bus events, the same ones /ws clients receive, until the client cancels,
+// falls behind or the server shuts down
+func (s *taskServer) Watch(req *taskpb.WatchRequest, stream taskpb.TaskService_WatchServer) error {
+	ctx := stream.Context()
+	span := trace.SpanFromContext(ctx)
+
+	events := store.BusEvents
+	if len(req.Events) > 0 {
+		for _, event := range req.Events {
+			if !slices.Contains(store.BusEvents, event) {
+				return status.Error(codes.InvalidArgument, "Unknown event "+event+", expected one of "+strings.Join(store.BusEvents, ", "))
+			}
+		}
+		events = req.Events
+	}
+
+	sub := s.h.events.Subscribe(wsBuffer)
+	defer sub.Close()
+
+	connected := time.Now()
+	span.SetAttributes(attribute.StringSlice("watch.events", events))
+	slog.InfoContext(ctx, "Watch started", "events", events)
+
+	sent := 0
+	reason := ""
+	var err error
+	for err == nil && reason == "" {
+		select {
+		case <-ctx.Done():
+			reason = "client_closed"
+		case event, ok := <-sub.C:
+			if !ok {
+				reason = "shutdown"
+				err = status.Error(codes.Unavailable, "Server is shutting down")
+				if sub.Lagged() {
+					reason = "lagged"
+					err = status.Error(codes.Aborted, "Watcher fell behind; catch up with GET /tasks/changes and watch again")
+				}
+				break
+			}
+			if !slices.Contains(events, event.Type) {
+				continue
+			}
+			if err = stream.Send(busEventToProto(event)); err != nil {
+				reason = "write_error"
+				span.RecordError(err)
+			} else {
+				sent++
+			}
+		}
+	}
+
+	span.SetAttributes(
+		attribute.String("watch.close_reason", reason),
+		attribute.Int("watch.events_sent", sent),
+	)
+	slog.InfoContext(ctx, "Watch ended", "reason", reason, "events_sent", sent, "duration", time.Since(connected))
+	return err
+}
+
+func taskToProto(t *store.Task) *taskpb.Task {
+	pb := &taskpb.Task{
+		Id:             int64(t.ID),
+		Uuid:           t.UUID,
+		Title:          t.Title,
+		Description:    t.Description,
+		Completed:      t.Completed,
+		CreatedAt:      timestamppb.New(t.CreatedAt),
+		DueAt:          optionalTimestamp(t.DueAt),
+		CompletedAt:    optionalTimestamp(t.CompletedAt),
+		Position:       int64(t.Position),
+		ListId:         int64(t.ListID),
+		Tags:           []string(t.Tags),
+		ClaimedBy:      t.ClaimedBy,
+		ClaimExpiresAt: optionalTimestamp(t.ClaimExpiresAt),
+		Blocked:        t.Blocked,
+	}
+	if t.ParentID != nil {
+		parentID := int64(*t.ParentID)
+		pb.ParentId = &parentID
+	}
+	return pb
+}
+
+func busEventToProto(e store.BusEvent) *taskpb.TaskEvent {
+	pb := &taskpb.TaskEvent{
+		Type:     e.Type,
+		TaskId:   int64(e.TaskID),
+		TaskUuid: e.TaskUUID,
+		Seq:      e.Seq,
+		Time:     timestamppb.New(e.Time),
+		Actor:    e.Actor,
+		TraceId:  e.TraceID,
+	}
+	if e.Task != nil {
+		pb.Task = taskToProto(e.Task)
+	}
+	return pb
+}
+
+func optionalTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
@@ -0,0 +1,809 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/integrations"
+	"todo-app/internal/requestctx"
+	"todo-app/internal/scheduler"
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Handlers struct {
+	db                *store.DB
+	store             store.TaskStore
+	requestCounter    metric.Int64Counter
+	requestDuration   metric.Float64Histogram
+	panics            metric.Int64Counter
+	slo               *telemetry.SLOTracker
+	emails            *integrations.EmailTemplates
+	notifications     *integrations.NotificationDispatcher
+	webhooks          *integrations.WebhookDispatcher
+	outbound          *integrations.OutboundQueue
+	events            *store.EventBus
+	ws                *wsMetrics
+	cluster           *scheduler.Cluster
+	taskCache         taskListCache
+	cacheLookups      metric.Int64Counter
+	idempotency       idempotencyStore
+	deadLetterReplays metric.Int64Counter
+	hooks             *integrations.HookReceiver
+	backups           *store.Backups
+	jobs              *scheduler.Scheduler
+	attachments       integrations.AttachmentStore
+	uploads           *integrations.UploadStaging
+}
+
+func NewHandlers(db *store.DB, emails *integrations.EmailTemplates, notifications *integrations.NotificationDispatcher, webhooks *integrations.WebhookDispatcher, hooks *integrations.HookReceiver, outbound *integrations.OutboundQueue, events *store.EventBus, cluster *scheduler.Cluster) *Handlers {
+	meter := telemetry.GetMeter()
+
+	requestCounter, _ := meter.Int64Counter("todo_app.requests",
+		metric.WithDescription("Number of requests"),
+		metric.WithUnit("1"))
+
+	requestDuration, _ := meter.Float64Histogram("todo_app.request_duration",
+		metric.WithDescription("Request duration in milliseconds"),
+		metric.WithUnit("ms"))
+
+	panics, _ := meter.Int64Counter("todo_app.http.panics",
+		metric.WithDescription("Handler panics recovered, by route"),
+		metric.WithUnit("1"))
+
+	cacheLookups, _ := meter.Int64Counter("todo_app.cache.lookups",
+		metric.WithDescription("Task list cache lookups by result"),
+		metric.WithUnit("1"))
+
+	deadLetterReplays, _ := meter.Int64Counter("todo_app.dead_letters.replays",
+		metric.WithDescription("Number of dead letters replayed, by kind"),
+		metric.WithUnit("1"))
+
+	return &Handlers{
+		db:                db,
+		store:             db,
+		requestCounter:    requestCounter,
+		requestDuration:   requestDuration,
+		panics:            panics,
+		slo:               telemetry.NewSLOTracker(config.EnvInt("TODO_SLO_SAMPLES", 1024), config.EnvDuration("TODO_SLO_WINDOW", time.Hour)),
+		emails:            emails,
+		notifications:     notifications,
+		webhooks:          webhooks,
+		outbound:          outbound,
+		events:            events,
+		ws:                newWSMetrics(),
+		cluster:           cluster,
+		taskCache:         newMemoryCache(),
+		cacheLookups:      cacheLookups,
+		idempotency:       db,
+		deadLetterReplays: deadLetterReplays,
+		hooks:             hooks,
+	}
+}
+
+// UseTaskStore serves the task lifecycle routes from tasks instead of the database
+func (h *Handlers) UseTaskStore(tasks store.TaskStore) {
+	h.store = tasks
+}
+
+func (h *Handlers) enableCORS(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", strings.Join([]string{"Content-Type", userIDHeader, idempotencyKeyHeader,
+		signatureClientHeader, signatureTimestampHeader, signatureNonceHeader, signatureBodyHashHeader, signatureHeader,
+		featureOverrideHeader, apiVersionHeader, requestIDHeader, tenantHeader, uploadOffsetHeader, "Authorization", "If-Match", "If-None-Match"}, ", "))
+	w.Header().Set("Access-Control-Expose-Headers", strings.Join([]string{changeSeqHeader, idempotentReplayedHeader,
+		rateLimitLimitHeader, rateLimitRemainingHeader, apiVersionHeader, requestIDHeader, traceIDHeader, uploadOffsetHeader, uploadLengthHeader,
+		"Retry-After", "Link", "ETag", "Location"}, ", "))
+}
+
+// Preflight answers CORS preflight requests for every route
+func (h *Handlers) Preflight(w http.ResponseWriter, r *http.Request) {
+	h.enableCORS(w)
+	w.WriteHeader(http.StatusOK)
+}
+
+// maxRequestBodyBytes bounds the body of requests without a larger limit of their own, such as
+// creating a task
+const maxRequestBodyBytes = 1 << 20
+
+// viewStale is the ?view= of GET /tasks that lists only stale tasks
+const viewStale = "stale"
+
+func (h *Handlers) GetTasks(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Record metrics
+	h.requestCounter.Add(ctx, 1,
+		metric.WithAttributes(
+			attribute.String("method", "GET"),
+			attribute.String("endpoint", "/tasks"),
+		))
+
+	if v := r.URL.Query().Get("since_seq"); v != "" {
+		h.getTaskChanges(w, r, start, v)
+		return
+	}
+
+	query := store.TaskQuery{
+		Search: r.URL.Query().Get("q"),
+		Tag:    r.URL.Query().Get("tag"),
+		Sort:   r.URL.Query().Get("sort"),
+		Locale: requestctx.Locale(ctx, store.DefaultLocale),
+	}
+	if query.Sort == "" {
+		query.Sort = store.SortCreated
+	}
+	if query.Sort != store.SortCreated && query.Sort != store.SortTitle && query.Sort != store.SortPosition {
+		http.Error(w, "Invalid sort, expected created_at, title or position", http.StatusBadRequest)
+		return
+	}
+	switch view := r.URL.Query().Get("view"); view {
+	case "":
+	case viewStale:
+		query.Stale = true
+		span.SetAttributes(attribute.String("query.view", view))
+	default:
+		http.Error(w, "Invalid view, expected stale", http.StatusBadRequest)
+		return
+	}
+	if v := r.URL.Query().Get("list"); v != "" {
+		listID, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid list ID", http.StatusBadRequest)
+			return
+		}
+		query.ListID = &listID
+		span.SetAttributes(attribute.Int("query.list_id", listID))
+	}
+	page, err := parseListPage(r, maxListRows)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, "GET", "/tasks", http.StatusBadRequest)
+		return
+	}
+	// One more than the page, to tell whether another follows
+	query.Offset, query.Limit = page.offset, page.limit+1
+
+	span.SetAttributes(
+		attribute.String("operation", "get_all_tasks"),
+		attribute.String("query.sort", query.Sort),
+		attribute.String("query.locale", query.Locale.String()),
+	)
+	slog.InfoContext(ctx, "Getting all tasks", "sort", query.Sort, "search", query.Search)
+
+	// Read before the tasks, so a change racing with the list is returned again by the next since_seq
+	seq, err := h.store.ChangeSeq(ctx)
+	var body []byte
+	if err == nil {
+		body, err = h.taskList(ctx, seq, query)
+	}
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "GET", "/tasks") {
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error getting tasks", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, "GET", "/tasks", http.StatusInternalServerError)
+		return
+	}
+
+	// The cached body is the bare array; it only needs counting to be cut to the page or wrapped
+	var tasks []json.RawMessage
+	json.Unmarshal(body, &tasks)
+	more := len(tasks) > page.limit
+	if more {
+		tasks = tasks[:page.limit]
+	}
+	next := setPageHints(w, r, page, more)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(changeSeqHeader, strconv.FormatInt(seq, 10))
+	switch {
+	case apiVersion(ctx) == apiVersion2:
+		writeEnvelope(w, r, ListEnvelope{
+			Data:  tasks,
+			Meta:  ListMeta{Count: len(tasks), ChangeSeq: &seq},
+			Links: ListLinks{Changes: "/tasks?since_seq=" + strconv.FormatInt(seq, 10), Next: next},
+		})
+	case more:
+		writeJSON(w, r, tasks)
+	default:
+		w.Write(body)
+	}
+
+	h.recordRequestMetrics(ctx, start, "GET", "/tasks", http.StatusOK)
+}
+
+func (h *Handlers) GetTask(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := h.taskIDFromPath(w, r, start, "GET", "/tasks/:id", r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("operation", "get_task"),
+		attribute.Int("task.id", id),
+	)
+	slog.InfoContext(ctx, "Getting task", "id", id)
+
+	task, err := h.store.GetTask(ctx, id)
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "GET", "/tasks/:id") {
+			return
+		}
+		if err == sql.ErrNoRows {
+			slog.WarnContext(ctx, "Task not found", "id", id)
+			http.Error(w, "Task not found", http.StatusNotFound)
+			h.recordRequestMetrics(ctx, start, "GET", "/tasks/:id", http.StatusNotFound)
+		} else {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error getting task", "error", err, "id", id)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.recordRequestMetrics(ctx, start, "GET", "/tasks/:id", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	span.SetAttributes(attribute.Bool("task.completed", task.Completed))
+
+	if prefersHTML(r) {
+		span.SetAttributes(attribute.String("response.format", "html"))
+		if err := renderTaskHTML(w, task); err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error rendering task as HTML", "error", err, "id", id)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.recordRequestMetrics(ctx, start, "GET", "/tasks/:id", http.StatusInternalServerError)
+			return
+		}
+		slog.InfoContext(ctx, "Successfully rendered task", "id", task.ID)
+		h.recordRequestMetrics(ctx, start, "GET", "/tasks/:id", http.StatusOK)
+		return
+	}
+
+	etag := taskETag(task)
+	w.Header().Set("ETag", etag)
+	if ifNoneMatch(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		h.recordRequestMetrics(ctx, start, "GET", "/tasks/:id", http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, task)
+	slog.InfoContext(ctx, "Successfully retrieved task", "id", task.ID)
+	h.recordRequestMetrics(ctx, start, "GET", "/tasks/:id", http.StatusOK)
+}
+
+func (h *Handlers) CreateTask(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req store.NewTask
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Request body too large, at most %d bytes allowed", maxRequestBodyBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	originalTitle := req.Title
+	title, err := store.NormalizeTitle(req.Title)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Title = title
+
+	description, err := store.NormalizeDescription(req.Description)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Description = description
+
+	if req.Tags, err = store.NormalizeTags(req.Tags); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("operation", "create_task"),
+		attribute.String("task.title", req.Title),
+	)
+	store.RecordTitleNormalization(span, originalTitle, req.Title)
+	slog.InfoContext(ctx, "Creating new task", "title", req.Title)
+
+	task, err := h.store.CreateTask(ctx, req)
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "POST", "/tasks") {
+			return
+		}
+		if errors.Is(err, store.ErrListNotFound) {
+			http.Error(w, "List not found", http.StatusUnprocessableEntity)
+			h.recordRequestMetrics(ctx, start, "POST", "/tasks", http.StatusUnprocessableEntity)
+			return
+		}
+		if errors.Is(err, store.ErrQuotaExceeded) {
+			slog.WarnContext(ctx, "Refused task over quota", "error", err)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			h.recordRequestMetrics(ctx, start, "POST", "/tasks", http.StatusForbidden)
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error creating task", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, "POST", "/tasks", http.StatusInternalServerError)
+		return
+	}
+
+	h.events.PublishTasks(ctx, store.EventTaskCreated, task)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", taskETag(task))
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, r, task)
+
+	slog.InfoContext(ctx, "Task created successfully", "id", task.ID, "title", task.Title)
+	h.recordRequestMetrics(ctx, start, "POST", "/tasks", http.StatusCreated)
+}
+
+func (h *Handlers) DeleteTask(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := h.taskIDFromPath(w, r, start, "DELETE", "/tasks/:id", r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("operation", "delete_task"),
+		attribute.Int("task.id", id),
+	)
+	slog.InfoContext(ctx, "Deleting task", "id", id)
+
+	err := h.store.DeleteTask(ctx, id)
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "DELETE", "/tasks/:id") {
+			return
+		}
+		if err == sql.ErrNoRows {
+			slog.WarnContext(ctx, "Task not found for deletion", "id", id)
+			http.Error(w, "Task not found", http.StatusNotFound)
+			h.recordRequestMetrics(ctx, start, "DELETE", "/tasks/:id", http.StatusNotFound)
+		} else if errors.Is(err, store.ErrTaskClaimed) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			h.recordRequestMetrics(ctx, start, "DELETE", "/tasks/:id", http.StatusConflict)
+		} else {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error deleting task", "error", err, "id", id)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.recordRequestMetrics(ctx, start, "DELETE", "/tasks/:id", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.events.Publish(ctx, store.BusEvent{Type: store.EventTaskDeleted, TaskID: id})
+
+	w.WriteHeader(http.StatusNoContent)
+	slog.InfoContext(ctx, "Task deleted successfully", "id", id)
+	h.recordRequestMetrics(ctx, start, "DELETE", "/tasks/:id", http.StatusNoContent)
+}
+
+func (h *Handlers) CompleteTask(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := h.taskIDFromPath(w, r, start, "POST", "/tasks/:id/complete", r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("operation", "complete_task"),
+		attribute.Int("task.id", id),
+	)
+	slog.InfoContext(ctx, "Completing task", "id", id)
+
+	task, err := h.store.CompleteTask(ctx, id)
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "POST", "/tasks/:id/complete") {
+			return
+		}
+		if err == sql.ErrNoRows {
+			slog.WarnContext(ctx, "Task not found for completion", "id", id)
+			http.Error(w, "Task not found", http.StatusNotFound)
+			h.recordRequestMetrics(ctx, start, "POST", "/tasks/:id/complete", http.StatusNotFound)
+		} else if errors.Is(err, store.ErrTaskBlocked) || errors.Is(err, store.ErrTaskClaimed) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			h.recordRequestMetrics(ctx, start, "POST", "/tasks/:id/complete", http.StatusConflict)
+		} else {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error completing task", "error", err, "id", id)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.recordRequestMetrics(ctx, start, "POST", "/tasks/:id/complete", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.events.PublishTasks(ctx, store.EventTaskCompleted, task)
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, task)
+	slog.InfoContext(ctx, "Task completed successfully", "id", task.ID, "title", task.Title)
+	h.recordRequestMetrics(ctx, start, "POST", "/tasks/:id/complete", http.StatusOK)
+}
+
+func (h *Handlers) UncompleteTask(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := h.taskIDFromPath(w, r, start, "POST", "/tasks/:id/uncomplete", r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("operation", "uncomplete_task"),
+		attribute.Int("task.id", id),
+	)
+	slog.InfoContext(ctx, "Reopening task", "id", id)
+
+	task, err := h.store.UncompleteTask(ctx, id)
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "POST", "/tasks/:id/uncomplete") {
+			return
+		}
+		if err == sql.ErrNoRows {
+			slog.WarnContext(ctx, "Task not found for reopening", "id", id)
+			http.Error(w, "Task not found", http.StatusNotFound)
+			h.recordRequestMetrics(ctx, start, "POST", "/tasks/:id/uncomplete", http.StatusNotFound)
+		} else if errors.Is(err, store.ErrTaskClaimed) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			h.recordRequestMetrics(ctx, start, "POST", "/tasks/:id/uncomplete", http.StatusConflict)
+		} else {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error reopening task", "error", err, "id", id)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.recordRequestMetrics(ctx, start, "POST", "/tasks/:id/uncomplete", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.events.PublishTasks(ctx, store.EventTaskUncompleted, task)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", taskETag(task))
+	writeJSON(w, r, task)
+	slog.InfoContext(ctx, "Task reopened successfully", "id", task.ID, "title", task.Title)
+	h.recordRequestMetrics(ctx, start, "POST", "/tasks/:id/uncomplete", http.StatusOK)
+}
+
+func (h *Handlers) UpdateTask(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "PATCH" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := h.taskIDFromPath(w, r, start, "PATCH", "/tasks/:id", r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	var req store.TaskUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	ifUpdatedAt, ok := ifMatch(r)
+	if !ok {
+		http.Error(w, "Task has been modified", http.StatusPreconditionFailed)
+		h.recordRequestMetrics(ctx, start, "PATCH", "/tasks/:id", http.StatusPreconditionFailed)
+		return
+	}
+	req.IfUpdatedAt = ifUpdatedAt
+
+	if req.Title == nil && req.Description == nil && req.Completed == nil && !req.DueAt.Set && req.ListID == nil && req.Tags == nil {
+		http.Error(w, "No fields to update", http.StatusBadRequest)
+		return
+	}
+
+	if req.Title != nil {
+		title, err := store.NormalizeTitle(*req.Title)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		store.RecordTitleNormalization(span, *req.Title, title)
+		req.Title = &title
+	}
+
+	if req.Description != nil {
+		description, err := store.NormalizeDescription(*req.Description)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Description = &description
+	}
+	if req.Tags != nil {
+		tags, err := store.NormalizeTags(*req.Tags)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Tags = &tags
+	}
+
+	span.SetAttributes(
+		attribute.String("operation", "update_task"),
+		attribute.Int("task.id", id),
+	)
+	slog.InfoContext(ctx, "Updating task", "id", id)
+
+	task, err := h.store.UpdateTask(ctx, id, req)
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "PATCH", "/tasks/:id") {
+			return
+		}
+		if err == sql.ErrNoRows {
+			slog.WarnContext(ctx, "Task not found for update", "id", id)
+			http.Error(w, "Task not found", http.StatusNotFound)
+			h.recordRequestMetrics(ctx, start, "PATCH", "/tasks/:id", http.StatusNotFound)
+		} else if errors.Is(err, store.ErrListNotFound) {
+			http.Error(w, "List not found", http.StatusUnprocessableEntity)
+			h.recordRequestMetrics(ctx, start, "PATCH", "/tasks/:id", http.StatusUnprocessableEntity)
+		} else if errors.Is(err, store.ErrTaskBlocked) || errors.Is(err, store.ErrTaskClaimed) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			h.recordRequestMetrics(ctx, start, "PATCH", "/tasks/:id", http.StatusConflict)
+		} else if errors.Is(err, store.ErrTaskModified) {
+			slog.InfoContext(ctx, "Task changed since the client read it", "id", id)
+			http.Error(w, "Task has been modified", http.StatusPreconditionFailed)
+			h.recordRequestMetrics(ctx, start, "PATCH", "/tasks/:id", http.StatusPreconditionFailed)
+		} else {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error updating task", "error", err, "id", id)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.recordRequestMetrics(ctx, start, "PATCH", "/tasks/:id", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if req.Completed != nil && *req.Completed {
+		h.events.PublishTasks(ctx, store.EventTaskCompleted, task)
+	} else if req.Completed != nil {
+		h.events.PublishTasks(ctx, store.EventTaskUncompleted, task)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", taskETag(task))
+	writeJSON(w, r, task)
+	slog.InfoContext(ctx, "Task updated successfully", "id", task.ID, "title", task.Title)
+	h.recordRequestMetrics(ctx, start, "PATCH", "/tasks/:id", http.StatusOK)
+}
+
+// taskIDFromPath resolves the {id} path value of a task route, which may be the numeric
+// ID or the task's UUID (or ULID). On failure it writes the response (400 for a malformed
+// reference, 404 for an unknown one, 410 for a deleted UUID, 308 to the survivor for a
+// merged task) and returns false.
+func (h *Handlers) taskIDFromPath(w http.ResponseWriter, r *http.Request, start time.Time, method, endpoint, ref string) (int, bool) {
+	ctx := r.Context()
+
+	if id, err := strconv.Atoi(ref); err == nil {
+		into, err := h.db.MergedInto(ctx, id)
+		if err == nil {
+			redirectMerged(w, r, ref, into)
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusPermanentRedirect)
+			return 0, false
+		}
+		if err != sql.ErrNoRows {
+			if h.abandonIfCanceled(ctx, start, method, endpoint) {
+				return 0, false
+			}
+			slog.ErrorContext(ctx, "Error checking for a merged task", "error", err, "id", id)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusInternalServerError)
+			return 0, false
+		}
+		return id, true
+	}
+
+	taskUUID, ok := store.ParseTaskUUID(ref)
+	if !ok {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return 0, false
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("task.uuid", taskUUID))
+	id, err := h.store.TaskIDByUUID(ctx, taskUUID)
+	var merged *store.TaskMergedError
+	switch {
+	case err == nil:
+		return id, true
+	case errors.As(err, &merged):
+		redirectMerged(w, r, ref, merged.Into)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusPermanentRedirect)
+	case errors.Is(err, store.ErrTaskDeleted):
+		http.Error(w, "Task has been deleted", http.StatusGone)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusGone)
+	case err == sql.ErrNoRows:
+		http.Error(w, "Task not found", http.StatusNotFound)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusNotFound)
+	default:
+		if h.abandonIfCanceled(ctx, start, method, endpoint) {
+			return 0, false
+		}
+		slog.ErrorContext(ctx, "Error resolving task UUID", "error", err, "uuid", taskUUID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusInternalServerError)
+	}
+	return 0, false
+}
+
+// statusClientClosedRequest is the de facto status code (from nginx) for requests abandoned by the client
+const statusClientClosedRequest = 499
+
+// abandonIfCanceled reports whether the request context is done, in which case the client
+// has disconnected (or the server is shutting down) and no further work should be started.
+// The request is recorded with status 499 and nothing is written to the response.
+func (h *Handlers) abandonIfCanceled(ctx context.Context, start time.Time, method, endpoint string) bool {
+	err := ctx.Err()
+	if err == nil {
+		return false
+	}
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Bool("request.canceled", true))
+	span.AddEvent("request.abandoned", trace.WithAttributes(attribute.String("reason", err.Error())))
+	slog.WarnContext(ctx, "Client went away, abandoning request", "method", method, "endpoint", endpoint, "reason", err)
+	h.recordRequestMetrics(context.WithoutCancel(ctx), start, method, endpoint, statusClientClosedRequest)
+	return true
+}
+
+func (h *Handlers) recordRequestMetrics(ctx context.Context, start time.Time, method, endpoint string, statusCode int) {
+	elapsed := time.Since(start)
+	duration := elapsed.Milliseconds()
+
+	attrs := []attribute.KeyValue{
+		attribute.String("method", method),
+		attribute.String("endpoint", endpoint),
+		attribute.Int("status_code", statusCode),
+	}
+
+	h.requestCounter.Add(ctx, 1, metric.WithAttributes(attrs...))
+	h.requestDuration.Record(ctx, float64(duration), metric.WithAttributes(attrs...))
+	h.slo.Record(method+" "+endpoint, statusCode, elapsed)
+	telemetry.Health.RecordRequest(method+" "+endpoint, statusCode, elapsed)
+}
+
+// GetSLO reports rolling per-route success rates and latency percentiles computed in-process
+func (h *Handlers) GetSLO(w http.ResponseWriter, r *http.Request) {
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, map[string]any{
+		"window_seconds": int(h.slo.Window().Seconds()),
+		"routes":         h.slo.Summary(),
+	})
+}
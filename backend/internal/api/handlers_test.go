@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"todo-app/internal/store"
+)
+
+// newTestHandlers returns handlers on a migrated SQLite database in a temporary directory,
+// serving the task routes from tasks unless it is nil, and the router in front of them
+func newTestHandlers(t *testing.T, tasks store.TaskStore) (*Handlers, http.Handler) {
+	t.Helper()
+	ctx := context.Background()
+	db, err := store.NewDB(ctx, filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Setup(ctx); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	h := NewHandlers(db, nil, nil, nil, nil, nil, store.NewEventBus(), nil)
+	if tasks != nil {
+		h.UseTaskStore(tasks)
+	}
+	return h, NewRouter(h)
+}
+
+// serve sends a request with body to handler and returns the response
+func serve(t *testing.T, handler http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func decodeTask(t *testing.T, w *httptest.ResponseRecorder) store.Task {
+	t.Helper()
+	var task store.Task
+	if err := json.NewDecoder(w.Body).Decode(&task); err != nil {
+		t.Fatalf("decoding task: %v", err)
+	}
+	return task
+}
+
+func TestTaskLifecycleOnMemoryStore(t *testing.T) {
+	tasks, err := store.NewMemoryStore()
+	if err != nil {
+		t.Fatalf("NewMemoryStore: %v", err)
+	}
+	h, router := newTestHandlers(t, tasks)
+
+	w := serve(t, router, "POST", "/tasks", `{"title": "  Buy milk "}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /tasks = %d %s, want 201", w.Code, w.Body)
+	}
+	created := decodeTask(t, w)
+	if created.Title != "Buy milk" || created.Completed {
+		t.Fatalf("created task = %+v, want an open task titled Buy milk", created)
+	}
+	if w.Header().Get("ETag") == "" {
+		t.Error("POST /tasks sent no ETag")
+	}
+	path := "/tasks/" + strconv.Itoa(created.ID)
+
+	if _, err := h.db.GetTask(context.Background(), created.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("task found in the database (err %v), want it only in the memory store", err)
+	}
+
+	w = serve(t, router, "GET", path, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d %s, want 200", path, w.Code, w.Body)
+	}
+	if got := decodeTask(t, w); got.ID != created.ID || got.Title != created.Title {
+		t.Errorf("GET %s = %+v, want %+v", path, got, created)
+	}
+
+	w = serve(t, router, "POST", path+"/complete", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST %s/complete = %d %s, want 200", path, w.Code, w.Body)
+	}
+	if got := decodeTask(t, w); !got.Completed || got.CompletedAt == nil {
+		t.Errorf("completed task = %+v, want completed with a completion time", got)
+	}
+
+	w = serve(t, router, "DELETE", path, "")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE %s = %d %s, want 204", path, w.Code, w.Body)
+	}
+	if w = serve(t, router, "GET", path, ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET %s after delete = %d, want 404", path, w.Code)
+	}
+}
+
+func TestCreateTaskValidationOnMemoryStore(t *testing.T) {
+	tasks, err := store.NewMemoryStore()
+	if err != nil {
+		t.Fatalf("NewMemoryStore: %v", err)
+	}
+	_, router := newTestHandlers(t, tasks)
+
+	for _, body := range []string{`{"title": ""}`, `{"title": `, `{"title": "   "}`} {
+		w := serve(t, router, "POST", "/tasks", body)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("POST /tasks %s = %d, want 400", body, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+			t.Errorf("POST /tasks %s Content-Type = %q, want application/problem+json", body, ct)
+		}
+	}
+
+	list, err := tasks.GetAllTasks(context.Background(), store.TaskQuery{})
+	if err != nil {
+		t.Fatalf("GetAllTasks: %v", err)
+	}
+	if len(list) != 0 {
+		t.Errorf("store holds %d tasks after invalid creates, want none", len(list))
+	}
+}
+
+func TestUncompletePublishesAndSetsETag(t *testing.T) {
+	h, router := newTestHandlers(t, nil)
+	var published []string
+	h.events.OnPublish(func(_ context.Context, event store.BusEvent) {
+		published = append(published, event.Type)
+	})
+
+	created := decodeTask(t, serve(t, router, "POST", "/tasks", `{"title": "Reopen me"}`))
+	path := "/tasks/" + strconv.Itoa(created.ID)
+	if w := serve(t, router, "POST", path+"/complete", ""); w.Code != http.StatusOK {
+		t.Fatalf("complete = %d %s", w.Code, w.Body)
+	}
+	completedETag := serve(t, router, "GET", path, "").Header().Get("ETag")
+
+	w := serve(t, router, "POST", path+"/uncomplete", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("uncomplete = %d %s", w.Code, w.Body)
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" || etag == completedETag {
+		t.Errorf("uncomplete ETag = %q, want a new one after %q", etag, completedETag)
+	}
+	if got := serve(t, router, "GET", path, "").Header().Get("ETag"); got != etag {
+		t.Errorf("GET after uncomplete has ETag %q, want the %q uncomplete sent", got, etag)
+	}
+	if len(published) == 0 || published[len(published)-1] != store.EventTaskUncompleted {
+		t.Errorf("published %v, want %s last", published, store.EventTaskUncompleted)
+	}
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"todo-app/internal/integrations"
+	"todo-app/internal/requestctx"
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HookResponse reports what a delivery did
+type HookResponse struct {
+	Provider   string             `json:"provider"`
+	Event      string             `json:"event"`
+	DeliveryID string             `json:"delivery_id"`
+	Duplicate  bool               `json:"duplicate,omitempty"`
+	Results    []store.HookResult `json:"results"`
+}
+
+// Hook serves POST /hooks/{provider}: the delivery's signature is verified, its payload
+// checked against the provider's schema for the event, and the task changes it asks for
+// are applied as the user hook:<provider>. Events the provider does not handle are
+// acknowledged and ignored, and redeliveries are acknowledged without applying again.
+func (h *Handlers) Hook(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.PathValue("provider")
+	span.SetAttributes(
+		attribute.String("operation", "receive_hook"),
+		attribute.String("hook.provider", name),
+	)
+	provider, secret, ok := h.hooks.Provider(name)
+	if !ok {
+		http.Error(w, "Unknown hook provider", http.StatusNotFound)
+		h.recordRequestMetrics(ctx, start, "POST", "/hooks/:provider", http.StatusNotFound)
+		return
+	}
+	reject := func(status int, outcome, msg string) {
+		slog.WarnContext(ctx, "Rejected inbound hook", "provider", name, "outcome", outcome, "reason", msg)
+		h.hooks.CountReceived(ctx, name, outcome)
+		http.Error(w, msg, status)
+		h.recordRequestMetrics(ctx, start, "POST", "/hooks/:provider", status)
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, integrations.MaxHookBody))
+	if err != nil {
+		reject(http.StatusRequestEntityTooLarge, "too_large", "Payload too large")
+		return
+	}
+	if err := provider.Verify(r.Header, body, secret); err != nil {
+		reject(http.StatusUnauthorized, "invalid_signature", "Invalid signature: "+err.Error())
+		return
+	}
+	event, deliveryID, err := provider.Event(r.Header, body)
+	if err != nil {
+		reject(http.StatusBadRequest, "invalid_payload", err.Error())
+		return
+	}
+	span.SetAttributes(attribute.String("hook.event", event), attribute.String("hook.delivery_id", deliveryID))
+
+	response := HookResponse{Provider: name, Event: event, DeliveryID: deliveryID, Results: []store.HookResult{}}
+	schema := provider.Schema(event)
+	if schema == nil {
+		slog.InfoContext(ctx, "Ignored inbound hook event", "provider", name, "event", event)
+		h.hooks.CountReceived(ctx, name, "ignored")
+		writeHookResponse(w, r, response)
+		h.recordRequestMetrics(ctx, start, "POST", "/hooks/:provider", http.StatusOK)
+		return
+	}
+	var payload any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		reject(http.StatusBadRequest, "invalid_payload", "Invalid JSON")
+		return
+	}
+	if err := schema.Validate(payload); err != nil {
+		reject(http.StatusUnprocessableEntity, "invalid_payload", "Invalid payload: "+err.Error())
+		return
+	}
+	actions, err := provider.Parse(event, body)
+	if err != nil {
+		reject(http.StatusUnprocessableEntity, "invalid_payload", err.Error())
+		return
+	}
+
+	results, err := h.db.ApplyHook(requestctx.WithUser(ctx, "hook:"+name), name, event, deliveryID, actions)
+	if errors.Is(err, store.ErrHookDuplicate) {
+		slog.InfoContext(ctx, "Dropped inbound hook redelivery", "provider", name, "delivery_id", deliveryID)
+		h.hooks.CountReceived(ctx, name, "duplicate")
+		response.Duplicate = true
+		writeHookResponse(w, r, response)
+		h.recordRequestMetrics(ctx, start, "POST", "/hooks/:provider", http.StatusOK)
+		return
+	}
+	if errors.Is(err, store.ErrQuotaExceeded) {
+		reject(http.StatusForbidden, "quota_exceeded", err.Error())
+		return
+	}
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "POST", "/hooks/:provider") {
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error applying inbound hook", "error", err, "provider", name, "delivery_id", deliveryID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, "POST", "/hooks/:provider", http.StatusInternalServerError)
+		return
+	}
+
+	for _, result := range results {
+		switch result.Status {
+		case "created":
+			h.events.PublishTasks(ctx, store.EventTaskCreated, result.Task)
+		case "completed":
+			h.events.PublishTasks(ctx, store.EventTaskCompleted, result.Task)
+		case "reopened":
+			h.events.PublishTasks(ctx, store.EventTaskUncompleted, result.Task)
+		}
+	}
+	slog.InfoContext(ctx, "Applied inbound hook", "provider", name, "event", event, "delivery_id", deliveryID, "actions", len(results))
+	h.hooks.CountReceived(ctx, name, "applied")
+	response.Results = results
+	writeHookResponse(w, r, response)
+	h.recordRequestMetrics(ctx, start, "POST", "/hooks/:provider", http.StatusOK)
+}
+
+func writeHookResponse(w http.ResponseWriter, r *http.Request, response HookResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, response)
+}
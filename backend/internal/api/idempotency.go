@@ -0,0 +1,164 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"todo-app/internal/requestctx"
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// idempotencyKeyHeader names a client-chosen key that makes retries of a request safe
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader marks a response replayed from an earlier request with the same key
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// idempotencyStore keeps Idempotency-Keys and the responses stored for them: the database,
+// or Redis when it is configured
+type idempotencyStore interface {
+	BeginIdempotentRequest(ctx context.Context, userID, key, fingerprint string) (*store.IdempotentResponse, error)
+	FinishIdempotentRequest(ctx context.Context, userID, key string, response store.IdempotentResponse) error
+	AbandonIdempotentRequest(ctx context.Context, userID, key string) error
+}
+
+// requestFingerprint identifies a request by its method, URL and body, so a key reused for
+// a different request can be told apart from a retry
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Idempotent makes retries of a request carrying an Idempotency-Key safe: the first request
+// with a key runs and its response is stored, and later requests with the same key and the
+// same body get that response back instead of running again. Keys are scoped to the user.
+//
+// A keyed request runs to completion even if the client goes away, since the client is
+// likely to retry precisely because it never saw the response. Server errors are not
+// stored, so the retry of a failed request runs again.
+func (h *Handlers) Idempotent(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+
+		start := time.Now()
+		ctx := r.Context()
+		span := trace.SpanFromContext(ctx)
+
+		h.enableCORS(w)
+
+		if len(key) > maxIdempotencyKeyLength {
+			http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, r.Method, endpoint, http.StatusBadRequest)
+			return
+		}
+		// Read in full for the fingerprint, so capped before the key is reserved
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Request body too large, at most %d bytes allowed", maxRequestBodyBytes), http.StatusRequestEntityTooLarge)
+			h.recordRequestMetrics(ctx, start, r.Method, endpoint, http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, r.Method, endpoint, http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		userID := requestctx.User(ctx)
+		stored, err := h.idempotency.BeginIdempotentRequest(ctx, userID, key, requestFingerprint(r, body))
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, store.ErrIdempotencyKeyReused):
+				status = http.StatusUnprocessableEntity
+				http.Error(w, err.Error(), status)
+			case errors.Is(err, store.ErrIdempotencyKeyInUse):
+				status = http.StatusConflict
+				http.Error(w, err.Error(), status)
+			default:
+				span.RecordError(err)
+				slog.ErrorContext(ctx, "Error checking idempotency key", "error", err)
+				http.Error(w, "Internal server error", status)
+			}
+			h.recordRequestMetrics(ctx, start, r.Method, endpoint, status)
+			return
+		}
+
+		if stored != nil {
+			span.SetAttributes(attribute.Bool("idempotency.replayed", true))
+			slog.InfoContext(ctx, "Replaying response for idempotency key", "status", stored.Status)
+			if stored.ContentType != "" {
+				w.Header().Set("Content-Type", stored.ContentType)
+			}
+			if stored.ChangeSeq > 0 {
+				w.Header().Set(changeSeqHeader, strconv.FormatInt(stored.ChangeSeq, 10))
+			}
+			w.Header().Set(idempotentReplayedHeader, "true")
+			w.WriteHeader(stored.Status)
+			io.WriteString(w, stored.Body)
+			h.recordRequestMetrics(ctx, start, r.Method, endpoint, stored.Status)
+			return
+		}
+		span.SetAttributes(attribute.Bool("idempotency.replayed", false))
+
+		// A panic is answered with a 500 by recoverMiddleware, so the key is released as for
+		// any 500 rather than left in progress until it expires
+		defer func() {
+			if p := recover(); p != nil {
+				if err := h.idempotency.AbandonIdempotentRequest(context.WithoutCancel(ctx), userID, key); err != nil {
+					slog.ErrorContext(ctx, "Error releasing idempotency key", "error", err)
+				}
+				panic(p)
+			}
+		}()
+
+		rw := &responseWriter{ResponseWriter: w, body: &bytes.Buffer{}, statusCode: http.StatusOK}
+		next(rw, r.WithContext(context.WithoutCancel(ctx)))
+
+		// The client may be gone, so the outcome is recorded regardless of ctx
+		ctx = context.WithoutCancel(ctx)
+		if rw.statusCode >= 500 {
+			if err := h.idempotency.AbandonIdempotentRequest(ctx, userID, key); err != nil {
+				slog.ErrorContext(ctx, "Error releasing idempotency key", "error", err)
+			}
+			return
+		}
+		changeSeq, _ := strconv.ParseInt(w.Header().Get(changeSeqHeader), 10, 64)
+		err = h.idempotency.FinishIdempotentRequest(ctx, userID, key, store.IdempotentResponse{
+			Status:      rw.statusCode,
+			ContentType: w.Header().Get("Content-Type"),
+			ChangeSeq:   changeSeq,
+			Body:        rw.body.String(),
+		})
+		if err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error storing idempotent response", "error", err)
+			// Better a retry that runs again than one stuck on a key that never finishes
+			if err := h.idempotency.AbandonIdempotentRequest(ctx, userID, key); err != nil {
+				slog.ErrorContext(ctx, "Error releasing idempotency key", "error", err)
+			}
+		}
+	}
+}
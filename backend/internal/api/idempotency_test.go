@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIdempotencyKeyReleasedAfterPanic(t *testing.T) {
+	h, _ := newTestHandlers(t, nil)
+	panicking := true
+	handler := h.recoverMiddleware("POST /things", h.Idempotent("/things", func(w http.ResponseWriter, r *http.Request) {
+		if panicking {
+			panic("handler bug")
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	send := func() int {
+		r := httptest.NewRequest("POST", "/things", strings.NewReader(`{"name": "thing"}`))
+		r.Header.Set(idempotencyKeyHeader, "retry-me")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if status := send(); status != http.StatusInternalServerError {
+		t.Fatalf("panicking request = %d, want 500", status)
+	}
+	panicking = false
+	if status := send(); status != http.StatusCreated {
+		t.Errorf("retry after the panic = %d, want 201 from running again", status)
+	}
+	if status := send(); status != http.StatusCreated {
+		t.Errorf("replay of the retry = %d, want its stored 201", status)
+	}
+}
+
+func TestIdempotentBodyTooLarge(t *testing.T) {
+	h, _ := newTestHandlers(t, nil)
+	handler := h.Idempotent("/things", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	send := func(body string) int {
+		r := httptest.NewRequest("POST", "/things", strings.NewReader(body))
+		r.Header.Set(idempotencyKeyHeader, "big")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if status := send(strings.Repeat("x", maxRequestBodyBytes+1)); status != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized request = %d, want 413", status)
+	}
+	if status := send(`{"name": "thing"}`); status != http.StatusCreated {
+		t.Errorf("request after the oversized one = %d, want 201 since the key was never reserved", status)
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ImportMarkdown handles POST /import/markdown[?list=ID]
+func (h *Handlers) ImportMarkdown(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	span.SetAttributes(attribute.String("operation", "import_markdown"))
+
+	var listID *int
+	if v := r.URL.Query().Get("list"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid list ID", http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, "POST", "/import/markdown", http.StatusBadRequest)
+			return
+		}
+		listID = &id
+	}
+
+	items, skipped, err := store.ParseMarkdownChecklist(http.MaxBytesReader(w, r.Body, store.MaxImportBytes))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, "POST", "/import/markdown", http.StatusBadRequest)
+		return
+	}
+	if len(items) == 0 {
+		http.Error(w, "No checklist items found, expected lines like \"- [ ] title\"", http.StatusUnprocessableEntity)
+		h.recordRequestMetrics(ctx, start, "POST", "/import/markdown", http.StatusUnprocessableEntity)
+		return
+	}
+	if len(items) > store.MaxImportTasks {
+		http.Error(w, fmt.Sprintf("Too many checklist items, at most %d allowed", store.MaxImportTasks), http.StatusRequestEntityTooLarge)
+		h.recordRequestMetrics(ctx, start, "POST", "/import/markdown", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	for i := range items {
+		title, err := store.NormalizeTitle(items[i].Title)
+		if err == nil {
+			items[i].Description, err = store.NormalizeDescription(items[i].Description)
+		}
+		if err != nil {
+			http.Error(w, (&store.ImportError{Line: items[i].Line, Err: err}).Error(), http.StatusUnprocessableEntity)
+			h.recordRequestMetrics(ctx, start, "POST", "/import/markdown", http.StatusUnprocessableEntity)
+			return
+		}
+		items[i].Title = title
+		items[i].ListID = listID
+	}
+
+	span.SetAttributes(
+		attribute.Int("import.items", len(items)),
+		attribute.Int("import.skipped_lines", len(skipped)),
+	)
+	slog.InfoContext(ctx, "Importing markdown checklist", "items", len(items), "skipped_lines", len(skipped))
+
+	tasks, err := h.db.ImportTasks(ctx, items)
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "POST", "/import/markdown") {
+			return
+		}
+		if errors.Is(err, store.ErrListNotFound) {
+			http.Error(w, "List not found", http.StatusUnprocessableEntity)
+			h.recordRequestMetrics(ctx, start, "POST", "/import/markdown", http.StatusUnprocessableEntity)
+			return
+		}
+		if errors.Is(err, store.ErrQuotaExceeded) {
+			slog.WarnContext(ctx, "Refused import over quota", "error", err)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			h.recordRequestMetrics(ctx, start, "POST", "/import/markdown", http.StatusForbidden)
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error importing tasks", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, "POST", "/import/markdown", http.StatusInternalServerError)
+		return
+	}
+
+	summary := store.ImportSummary{Created: len(tasks), SkippedLines: skipped, Tasks: tasks}
+	if summary.SkippedLines == nil {
+		summary.SkippedLines = []int{}
+	}
+	for _, task := range tasks {
+		if task.Completed {
+			summary.Completed++
+		}
+		if task.ParentID != nil {
+			summary.Subtasks++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, r, summary)
+
+	slog.InfoContext(ctx, "Markdown checklist imported", "created", summary.Created, "subtasks", summary.Subtasks)
+	h.recordRequestMetrics(ctx, start, "POST", "/import/markdown", http.StatusCreated)
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"todo-app/internal/scheduler"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// UseScheduler serves /admin/jobs from jobs. Without it those routes answer 501.
+func (h *Handlers) UseScheduler(jobs *scheduler.Scheduler) {
+	h.jobs = jobs
+}
+
+// GetJobs serves GET /admin/jobs, listing the background jobs with their last and next run
+func (h *Handlers) GetJobs(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.jobs == nil {
+		http.Error(w, "No scheduler", http.StatusNotImplemented)
+		h.recordRequestMetrics(ctx, start, "GET", "/admin/jobs", http.StatusNotImplemented)
+		return
+	}
+
+	span.SetAttributes(attribute.String("operation", "list_jobs"))
+	h.recordRequestMetrics(ctx, start, "GET", "/admin/jobs", writeList(w, r, h.jobs.Jobs()))
+}
+
+// RunJob serves POST /admin/jobs/{name}/run, running a job now and answering with its status
+// once the run is over. A run that fails is still a 200; its outcome says so.
+func (h *Handlers) RunJob(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.jobs == nil {
+		http.Error(w, "No scheduler", http.StatusNotImplemented)
+		h.recordRequestMetrics(ctx, start, "POST", "/admin/jobs/:name/run", http.StatusNotImplemented)
+		return
+	}
+
+	name := r.PathValue("name")
+	span.SetAttributes(
+		attribute.String("operation", "run_job"),
+		attribute.String("job.name", name),
+	)
+	slog.InfoContext(ctx, "Running job on request", "job", name)
+
+	status, err := h.jobs.RunNow(ctx, name)
+	if err != nil {
+		code := http.StatusConflict
+		if errors.Is(err, scheduler.ErrJobNotFound) {
+			code = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), code)
+		h.recordRequestMetrics(ctx, start, "POST", "/admin/jobs/:name/run", code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, status)
+	h.recordRequestMetrics(ctx, start, "POST", "/admin/jobs/:name/run", http.StatusOK)
+}
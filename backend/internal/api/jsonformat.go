@@ -0,0 +1,299 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"todo-app/internal/config"
+)
+
+// Field naming and time formats of JSON responses. Clients migrating from APIs that spell
+// fields in camelCase or send times as epoch milliseconds can ask for those instead.
+const (
+	namingSnakeCase = "snake_case"
+	namingCamelCase = "camelCase"
+	timeRFC3339     = "rfc3339"
+	timeEpochMillis = "epoch_ms"
+)
+
+// jsonFormat is how a response spells field names and times
+type jsonFormat struct {
+	naming string
+	time   string
+}
+
+// defaultJSONFormat is the format of every API version unless configured otherwise
+var defaultJSONFormat = jsonFormat{naming: namingSnakeCase, time: timeRFC3339}
+
+// JSONFormats sets the response format of API versions, as comma-separated
+// version=naming+time entries, e.g. "1=camelCase+epoch_ms"; versions not listed use
+// snake_case+rfc3339
+var JSONFormats = config.SplitList(config.EnvString("TODO_JSON_FORMATS", ""))
+
+// versionJSONFormats is the format of each API version configured by SetJSONFormats
+var versionJSONFormats = map[string]jsonFormat{}
+
+// SetJSONFormats configures the response format of API versions from entries of
+// JSONFormats, returning an error for the first malformed one
+func SetJSONFormats(entries []string) error {
+	formats := map[string]jsonFormat{}
+	for _, entry := range entries {
+		version, format, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid entry %q, expected version=naming+time", entry)
+		}
+		if version != apiVersion1 && version != apiVersion2 {
+			return fmt.Errorf("unknown API version %q, expected 1 or 2", version)
+		}
+		f := defaultJSONFormat
+		for _, part := range strings.Split(format, "+") {
+			if err := f.set(strings.TrimSpace(part)); err != nil {
+				return err
+			}
+		}
+		formats[version] = f
+	}
+	versionJSONFormats = formats
+	return nil
+}
+
+// set sets the naming or time format of f to value, in any case
+func (f *jsonFormat) set(value string) error {
+	if naming, ok := matchFold(value, namingSnakeCase, namingCamelCase); ok {
+		f.naming = naming
+	} else if format, ok := matchFold(value, timeRFC3339, timeEpochMillis); ok {
+		f.time = format
+	} else {
+		return fmt.Errorf("unknown JSON format %q, expected %s, %s, %s or %s", value, namingSnakeCase, namingCamelCase, timeRFC3339, timeEpochMillis)
+	}
+	return nil
+}
+
+// matchFold returns the option equal to value under case folding
+func matchFold(value string, options ...string) (string, bool) {
+	for _, option := range options {
+		if strings.EqualFold(value, option) {
+			return option, true
+		}
+	}
+	return "", false
+}
+
+// requestedJSONFormat returns the format of version, overridden by the naming and time
+// parameters of an application/json media range in Accept, e.g.
+// "application/json; naming=camelCase; time=epoch_ms"
+func requestedJSONFormat(r *http.Request, version string) (jsonFormat, error) {
+	f, ok := versionJSONFormats[version]
+	if !ok {
+		f = defaultJSONFormat
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(accept)
+		if err != nil || mediaType != "application/json" {
+			continue
+		}
+		if v, ok := params["naming"]; ok {
+			if f.naming, ok = matchFold(v, namingSnakeCase, namingCamelCase); !ok {
+				return f, fmt.Errorf("Unsupported naming %q, expected %s or %s", v, namingSnakeCase, namingCamelCase)
+			}
+		}
+		if v, ok := params["time"]; ok {
+			if f.time, ok = matchFold(v, timeRFC3339, timeEpochMillis); !ok {
+				return f, fmt.Errorf("Unsupported time format %q, expected %s or %s", v, timeRFC3339, timeEpochMillis)
+			}
+		}
+		break
+	}
+	return f, nil
+}
+
+type jsonFormatKey struct{}
+
+// responseJSONFormat returns the format negotiated for the request ctx belongs to
+func responseJSONFormat(ctx context.Context) jsonFormat {
+	if f, ok := ctx.Value(jsonFormatKey{}).(jsonFormat); ok {
+		return f
+	}
+	return defaultJSONFormat
+}
+
+// writeJSON writes v as JSON in the format negotiated for r
+func writeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	return json.NewEncoder(w).Encode(formatJSON(r.Context(), v))
+}
+
+// formatJSON returns v in the format negotiated for the request ctx belongs to, ready to be
+// encoded. In the default format that is v itself. Otherwise struct fields are renamed and
+// times converted; map keys are data and keep their spelling, and values that encode
+// themselves, such as json.RawMessage, are left as they are.
+func formatJSON(ctx context.Context, v any) any {
+	f := responseJSONFormat(ctx)
+	if f == defaultJSONFormat {
+		return v
+	}
+	return f.value(reflect.ValueOf(v))
+}
+
+var (
+	timeType      = reflect.TypeFor[time.Time]()
+	marshalerType = reflect.TypeFor[json.Marshaler]()
+)
+
+// value returns v converted to f
+func (f jsonFormat) value(v reflect.Value) any {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	if v.Type() == timeType {
+		if f.time == timeEpochMillis {
+			return v.Interface().(time.Time).UnixMilli()
+		}
+		return v.Interface()
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return f.value(v.Elem())
+	}
+	if v.Type().Implements(marshalerType) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		var obj jsonObject
+		f.appendFields(&obj, v)
+		return obj
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]any, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			m[fmt.Sprint(iter.Key().Interface())] = f.value(iter.Value())
+		}
+		return m
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		fallthrough
+	case reflect.Array:
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = f.value(v.Index(i))
+		}
+		return items
+	}
+	return v.Interface()
+}
+
+// appendFields appends the fields of struct v to obj as encoding/json would encode them,
+// with embedded structs inlined
+func (f jsonFormat) appendFields(obj *jsonObject, v reflect.Value) {
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		fv := v.Field(i)
+		if field.Anonymous && name == "" {
+			if fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				f.appendFields(obj, fv)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if slices.Contains(strings.Split(opts, ","), "omitempty") && emptyJSONValue(fv) {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if f.naming == namingCamelCase {
+			name = camelCase(name)
+		}
+		*obj = append(*obj, jsonMember{name: name, value: f.value(fv)})
+	}
+}
+
+// emptyJSONValue reports whether omitempty leaves v out
+func emptyJSONValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+// camelCase turns a snake_case name into camelCase, due_at into dueAt
+func camelCase(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			r := []rune(parts[i])
+			r[0] = unicode.ToUpper(r[0])
+			parts[i] = string(r)
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// jsonObject is a JSON object that keeps its members in struct field order
+type jsonObject []jsonMember
+
+type jsonMember struct {
+	name  string
+	value any
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(m.name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
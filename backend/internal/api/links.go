@@ -0,0 +1,142 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TaskLinks serves GET and POST /tasks/{id}/links and DELETE /tasks/{id}/links/{linked}
+func (h *Handlers) TaskLinks(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	ref, linkedRef := r.PathValue("id"), r.PathValue("linked")
+
+	var operation, endpoint string
+	switch {
+	case r.Method == "GET" && linkedRef == "":
+		operation, endpoint = "get_task_links", "/tasks/:id/links"
+	case r.Method == "POST" && linkedRef == "":
+		operation, endpoint = "add_task_link", "/tasks/:id/links"
+	case r.Method == "DELETE" && linkedRef != "":
+		operation, endpoint = "remove_task_link", "/tasks/:id/links/:linked"
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	method := r.Method
+
+	id, ok := h.taskIDFromPath(w, r, start, method, endpoint, ref)
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("operation", operation),
+		attribute.Int("task.id", id),
+	)
+
+	var body any
+	var err error
+	status := http.StatusOK
+	switch method {
+	case "GET":
+		body, err = h.db.GetTaskLinks(ctx, id)
+	case "POST":
+		var req struct {
+			Type string          `json:"type"`
+			Task json.RawMessage `json:"task"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Task) == 0 {
+			http.Error(w, "Invalid request body, expected {\"type\": \"relates_to\", \"task\": <task id or uuid>}", http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+			return
+		}
+		if req.Type == "" {
+			req.Type = store.LinkRelatesTo
+		}
+		var linkedID int
+		linkedID, err = h.db.ResolveTaskRef(ctx, strings.Trim(string(req.Task), `"`))
+		if errors.Is(err, store.ErrBlockerNotFound) {
+			err = store.ErrLinkedTaskNotFound
+		}
+		if err == nil {
+			span.SetAttributes(attribute.Int("task.linked_id", linkedID), attribute.String("task.link_type", req.Type))
+			slog.InfoContext(ctx, "Adding task link", "id", id, "linked_id", linkedID, "type", req.Type)
+			body, err = h.db.AddTaskLink(ctx, id, linkedID, req.Type)
+			status = http.StatusCreated
+		}
+	case "DELETE":
+		linkType := r.URL.Query().Get("type")
+		var linkedID int
+		linkedID, err = h.db.ResolveTaskRef(ctx, linkedRef)
+		if errors.Is(err, store.ErrBlockerNotFound) {
+			err = sql.ErrNoRows
+		}
+		if err == nil {
+			span.SetAttributes(attribute.Int("task.linked_id", linkedID), attribute.String("task.link_type", linkType))
+			slog.InfoContext(ctx, "Removing task link", "id", id, "linked_id", linkedID, "type", linkType)
+			err = h.db.RemoveTaskLink(ctx, id, linkedID, linkType)
+			status = http.StatusNoContent
+		}
+	}
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, method, endpoint) {
+			return
+		}
+		status = http.StatusInternalServerError
+		switch {
+		case err == sql.ErrNoRows:
+			status = http.StatusNotFound
+			if method == "DELETE" {
+				http.Error(w, "Link not found", status)
+			} else {
+				http.Error(w, "Task not found", status)
+			}
+		case errors.Is(err, store.ErrInvalidLinkType):
+			status = http.StatusBadRequest
+			http.Error(w, "Invalid link type, expected relates_to, duplicates or duplicated_by", status)
+		case errors.Is(err, store.ErrLinkedTaskNotFound):
+			status = http.StatusUnprocessableEntity
+			http.Error(w, "Linked task not found", status)
+		case errors.Is(err, store.ErrSelfLink):
+			status = http.StatusUnprocessableEntity
+			http.Error(w, "A task cannot be linked to itself", status)
+		default:
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error handling task links", "error", err, "id", id, "operation", operation)
+			http.Error(w, "Internal server error", status)
+		}
+		h.recordRequestMetrics(ctx, start, method, endpoint, status)
+		return
+	}
+
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		h.recordRequestMetrics(ctx, start, method, endpoint, status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	writeJSON(w, r, body)
+	h.recordRequestMetrics(ctx, start, method, endpoint, status)
+}
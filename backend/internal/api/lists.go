@@ -0,0 +1,243 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Lists serves GET /lists and POST /lists
+func (h *Handlers) Lists(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+		return
+	case "GET":
+		span.SetAttributes(attribute.String("operation", "get_lists"))
+		lists, err := h.db.GetLists(ctx)
+		if err != nil {
+			if h.abandonIfCanceled(ctx, start, "GET", "/lists") {
+				return
+			}
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error getting lists", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.recordRequestMetrics(ctx, start, "GET", "/lists", http.StatusInternalServerError)
+			return
+		}
+		h.recordRequestMetrics(ctx, start, "GET", "/lists", writeList(w, r, lists))
+	case "POST":
+		name, ok := store.DecodeListName(w, r)
+		if !ok {
+			h.recordRequestMetrics(ctx, start, "POST", "/lists", http.StatusBadRequest)
+			return
+		}
+
+		span.SetAttributes(
+			attribute.String("operation", "create_list"),
+			attribute.String("list.name", name),
+		)
+		slog.InfoContext(ctx, "Creating list", "name", name)
+
+		list, err := h.db.CreateList(ctx, name, nil)
+		if err != nil {
+			if h.abandonIfCanceled(ctx, start, "POST", "/lists") {
+				return
+			}
+			if errors.Is(err, store.ErrListNameTaken) {
+				http.Error(w, "A list with this name already exists", http.StatusConflict)
+				h.recordRequestMetrics(ctx, start, "POST", "/lists", http.StatusConflict)
+				return
+			}
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error creating list", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.recordRequestMetrics(ctx, start, "POST", "/lists", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, r, list)
+		slog.InfoContext(ctx, "List created", "id", list.ID, "name", list.Name)
+		h.recordRequestMetrics(ctx, start, "POST", "/lists", http.StatusCreated)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// List serves GET, PATCH and DELETE /lists/{id}
+func (h *Handlers) List(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid list ID", http.StatusBadRequest)
+		return
+	}
+
+	var operation string
+	switch r.Method {
+	case "GET":
+		operation = "get_list"
+	case "PATCH":
+		operation = "rename_list"
+	case "DELETE":
+		operation = "delete_list"
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	method, endpoint := r.Method, "/lists/:id"
+
+	span.SetAttributes(
+		attribute.String("operation", operation),
+		attribute.Int("list.id", id),
+	)
+
+	var list *store.List
+	switch r.Method {
+	case "GET":
+		list, err = h.db.GetList(ctx, id)
+	case "PATCH":
+		name, ok := store.DecodeListName(w, r)
+		if !ok {
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+			return
+		}
+		slog.InfoContext(ctx, "Renaming list", "id", id, "name", name)
+		list, err = h.db.RenameList(ctx, id, name)
+	case "DELETE":
+		slog.InfoContext(ctx, "Deleting list", "id", id)
+		err = h.db.DeleteList(ctx, id)
+	}
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, method, endpoint) {
+			return
+		}
+		status := http.StatusInternalServerError
+		switch {
+		case err == sql.ErrNoRows:
+			status = http.StatusNotFound
+			http.Error(w, "List not found", status)
+		case errors.Is(err, store.ErrListNameTaken):
+			status = http.StatusConflict
+			http.Error(w, "A list with this name already exists", status)
+		case errors.Is(err, store.ErrDefaultList):
+			status = http.StatusConflict
+			http.Error(w, "The default list cannot be deleted", status)
+		default:
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error handling list request", "error", err, "id", id, "operation", operation)
+			http.Error(w, "Internal server error", status)
+		}
+		h.recordRequestMetrics(ctx, start, method, endpoint, status)
+		return
+	}
+
+	if list == nil {
+		w.WriteHeader(http.StatusNoContent)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, list)
+	h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusOK)
+}
+
+// ListSettings serves GET and PUT /lists/{id}/settings, the defaults applied to new tasks in a list
+func (h *Handlers) ListSettings(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" && r.Method != "PUT" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	method, endpoint := r.Method, "/lists/:id/settings"
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid list ID", http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+		return
+	}
+	span.SetAttributes(attribute.Int("list.id", id))
+
+	var settings *store.ListSettings
+	if r.Method == "GET" {
+		span.SetAttributes(attribute.String("operation", "get_list_settings"))
+		settings, err = h.db.GetListSettings(ctx, id)
+	} else {
+		span.SetAttributes(attribute.String("operation", "save_list_settings"))
+		settings = &store.ListSettings{}
+		if err := json.NewDecoder(r.Body).Decode(settings); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+			return
+		}
+		settings.ListID = id
+		if err := settings.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+			return
+		}
+		slog.InfoContext(ctx, "Saving list settings", "id", id,
+			"default_tags", settings.DefaultTags,
+			"reminder_offset_seconds", settings.ReminderOffsetSeconds)
+		err = h.db.SaveListSettings(ctx, settings)
+	}
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, method, endpoint) {
+			return
+		}
+		status := http.StatusInternalServerError
+		if err == sql.ErrNoRows {
+			status = http.StatusNotFound
+			http.Error(w, "List not found", status)
+		} else {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error handling list settings", "error", err, "id", id)
+			http.Error(w, "Internal server error", status)
+		}
+		h.recordRequestMetrics(ctx, start, method, endpoint, status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, settings)
+	h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusOK)
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"bytes"
+	"html/template"
+	"mime"
+	"net/http"
+	"strings"
+
+	"todo-app/internal/store"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// markdown renders task descriptions. Raw HTML in the source is not passed
+// through (goldmark's default), so descriptions cannot inject markup or scripts.
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+var taskPageTemplate = template.Must(template.New("task").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Task.Title}}</title>
+</head>
+<body>
+<article class="task{{if .Task.Completed}} completed{{end}}">
+<h1>{{.Task.Title}}</h1>
+<p class="task-meta">Created {{.Task.CreatedAt.Format "2006-01-02 15:04"}}{{if .Task.DueAt}} &middot; due {{.Task.DueAt.Format "2006-01-02 15:04"}}{{end}}{{if .Task.Completed}} &middot; completed{{end}}</p>
+<div class="task-description">
+{{.Description}}
+</div>
+</article>
+</body>
+</html>
+`))
+
+// prefersHTML reports whether the client asked for text/html over JSON
+func prefersHTML(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/html":
+			return true
+		case "application/json", "*/*":
+			return false
+		}
+	}
+	return false
+}
+
+// renderMarkdown converts a markdown description to sanitized HTML
+func renderMarkdown(source string) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
+
+// renderTaskHTML writes a task as a standalone HTML page with its description rendered from markdown
+func renderTaskHTML(w http.ResponseWriter, task *store.Task) error {
+	description, err := renderMarkdown(task.Description)
+	if err != nil {
+		return err
+	}
+
+	var page bytes.Buffer
+	err = taskPageTemplate.Execute(&page, struct {
+		Task        *store.Task
+		Description template.HTML
+	}{task, description})
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err = page.WriteTo(w)
+	return err
+}
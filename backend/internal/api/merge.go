@@ -0,0 +1,130 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// redirectMerged sends the client to the task a merged task ref was merged into, keeping the
+// rest of the path and the query. 308 keeps the method and body.
+func redirectMerged(w http.ResponseWriter, r *http.Request, ref string, into int) {
+	target := *r.URL
+	target.Path = strings.Replace(r.URL.Path, "/tasks/"+ref, "/tasks/"+strconv.Itoa(into), 1)
+	target.RawPath = ""
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	http.Redirect(w, r, target.RequestURI(), http.StatusPermanentRedirect)
+}
+
+// MergeTasks serves POST /tasks/merge, merging {"tasks": [...]} into {"into": ...}, each a
+// task ID or UUID. It responds with the surviving task.
+func (h *Handlers) MergeTasks(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	const endpoint = "/tasks/merge"
+	badRequest := func(message string) {
+		http.Error(w, message, http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, "POST", endpoint, http.StatusBadRequest)
+	}
+
+	var req struct {
+		Into  json.RawMessage   `json:"into"`
+		Tasks []json.RawMessage `json:"tasks"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Into) == 0 {
+		badRequest("Invalid request body, expected {\"into\": <task id or uuid>, \"tasks\": [<task id or uuid>, ...]}")
+		return
+	}
+	if len(req.Tasks) == 0 || len(req.Tasks) > store.MaxMergeTasks {
+		badRequest(fmt.Sprintf("Between 1 and %d tasks can be merged at once", store.MaxMergeTasks))
+		return
+	}
+
+	into, err := h.db.ResolveTaskRef(ctx, strings.Trim(string(req.Into), `"`))
+	ids := make([]int, 0, len(req.Tasks))
+	seen := map[int]bool{into: true}
+	for _, ref := range req.Tasks {
+		if err != nil {
+			break
+		}
+		var id int
+		id, err = h.db.ResolveTaskRef(ctx, strings.Trim(string(ref), `"`))
+		if err == nil && seen[id] {
+			badRequest("A task can only be merged once, and not into itself")
+			return
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+
+	var task *store.Task
+	if err == nil {
+		span.SetAttributes(
+			attribute.String("operation", "merge_tasks"),
+			attribute.Int("task.id", into),
+			attribute.IntSlice("merge.task_ids", ids),
+		)
+		slog.InfoContext(ctx, "Merging tasks", "into", into, "ids", ids)
+		task, err = h.db.MergeTasks(ctx, into, ids)
+	}
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "POST", endpoint) {
+			return
+		}
+		status := http.StatusInternalServerError
+		switch {
+		case err == sql.ErrNoRows || errors.Is(err, store.ErrBlockerNotFound):
+			status = http.StatusNotFound
+			http.Error(w, "Task not found", status)
+		case errors.Is(err, store.ErrTaskClaimed):
+			status = http.StatusConflict
+			http.Error(w, err.Error(), status)
+		case errors.Is(err, store.ErrDependencyCycle):
+			status = http.StatusConflict
+			http.Error(w, "Merging would create a dependency cycle", status)
+		case errors.Is(err, store.ErrMergeIntoSubtask), errors.Is(err, store.ErrMergeTags):
+			status = http.StatusUnprocessableEntity
+			http.Error(w, err.Error(), status)
+		default:
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error merging tasks", "error", err, "into", into)
+			http.Error(w, "Internal server error", status)
+		}
+		h.recordRequestMetrics(ctx, start, "POST", endpoint, status)
+		return
+	}
+
+	for _, id := range ids {
+		h.events.Publish(ctx, store.BusEvent{Type: store.EventTaskDeleted, TaskID: id})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, task)
+	slog.InfoContext(ctx, "Tasks merged successfully", "into", task.ID, "merged", len(ids))
+	h.recordRequestMetrics(ctx, start, "POST", endpoint, http.StatusOK)
+}
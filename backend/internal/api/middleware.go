@@ -0,0 +1,141 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// responseWriter wraps http.ResponseWriter to capture response body
+type responseWriter struct {
+	http.ResponseWriter
+	body       *bytes.Buffer
+	statusCode int
+	// limit, when set, caps how much of the body is captured; size counts all of it
+	limit int
+	size  int64
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	captured := b
+	if rw.limit > 0 {
+		captured = b[:max(0, min(len(b), rw.limit-rw.body.Len()))]
+	}
+	rw.body.Write(captured)
+	n, err := rw.ResponseWriter.Write(b)
+	rw.size += int64(n)
+	return n, err
+}
+
+func (rw *responseWriter) WriteHeader(statusCode int) {
+	rw.statusCode = statusCode
+	rw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// BodyTracingMiddleware adds request and response bodies to the span as events, within the
+// limits of TODO_TRACE_BODY_*: only allowed content types, credentials masked, and cut to
+// TODO_TRACE_BODY_MAX_BYTES. At most telemetry.TraceBodyReadLimit bytes of a body are held
+// for its event; the rest streams through.
+func BodyTracingMiddleware(next http.Handler) http.Handler {
+	if !telemetry.TraceBodies {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := trace.SpanFromContext(r.Context())
+
+		// Capture request body
+		if r.Body != nil && r.Method != "GET" && r.Method != "DELETE" {
+			head, whole, complete, err := telemetry.ReadBodyHead(r.Body)
+			if err == nil {
+				size := r.ContentLength
+				if complete {
+					size = int64(len(head))
+				}
+				span.AddEvent("http.request.body",
+					trace.WithAttributes(telemetry.BodyEventAttributes(r.Header.Get("Content-Type"), head, size)...))
+				// Restore the body for the handler
+				r.Body = struct {
+					io.Reader
+					io.Closer
+				}{whole, r.Body}
+			}
+		}
+
+		// Wrap response writer to capture response body
+		rw := &responseWriter{
+			ResponseWriter: w,
+			body:           &bytes.Buffer{},
+			statusCode:     http.StatusOK,
+			limit:          telemetry.TraceBodyReadLimit,
+		}
+
+		// Call the next handler
+		next.ServeHTTP(rw, r)
+
+		// Add response body as an event
+		if rw.size > 0 {
+			attrs := telemetry.BodyEventAttributes(rw.Header().Get("Content-Type"), rw.body.Bytes(), rw.size)
+			span.AddEvent("http.response.body",
+				trace.WithAttributes(append(attrs, attribute.Int("status_code", rw.statusCode))...))
+		}
+	})
+}
+
+// unavailableWriter turns a 500 into a 503 with Retry-After while the database is unavailable
+type unavailableWriter struct {
+	http.ResponseWriter
+	unavailable func() bool
+	// swallowed is set once the handler's 500 body is being dropped
+	swallowed bool
+}
+
+func (w *unavailableWriter) WriteHeader(statusCode int) {
+	if statusCode == http.StatusInternalServerError && w.unavailable() {
+		w.swallowed = true
+		w.Header().Set("Retry-After", strconv.Itoa(int(store.DatabaseRetryAfter.Seconds())))
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Del("Content-Length")
+		w.ResponseWriter.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w.ResponseWriter, "Database unavailable, retry shortly\n")
+		return
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *unavailableWriter) Write(b []byte) (int, error) {
+	if w.swallowed {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *unavailableWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush and Hijack keep streaming responses and the WebSocket upgrade working through the wrapper
+
+func (w *unavailableWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *unavailableWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// unavailableMiddleware answers 503 with Retry-After instead of 500 while the database is
+// failing over, so clients back off and retry rather than report an error
+func (h *Handlers) unavailableMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&unavailableWriter{ResponseWriter: w, unavailable: h.db.Unavailable}, r)
+	})
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+	_ "time/tzdata"
+	"todo-app/internal/requestctx"
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+) // quiet hours accept IANA time zones even where the host has no zoneinfo
+
+// NotificationSettings serves GET and PUT /notification-settings for the requesting user
+func (h *Handlers) NotificationSettings(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" && r.Method != "PUT" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	method, endpoint := r.Method, "/notification-settings"
+
+	userID := requestctx.User(r.Context())
+	span.SetAttributes(attribute.String("user.id", userID))
+
+	var settings *store.NotificationSettings
+	var err error
+	if r.Method == "GET" {
+		span.SetAttributes(attribute.String("operation", "get_notification_settings"))
+		settings, err = h.db.GetNotificationSettings(ctx, userID)
+	} else {
+		span.SetAttributes(attribute.String("operation", "save_notification_settings"))
+		settings = &store.NotificationSettings{}
+		if err := json.NewDecoder(r.Body).Decode(settings); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+			return
+		}
+		settings.UserID = userID
+		if err := settings.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+			return
+		}
+		slog.InfoContext(ctx, "Saving notification settings",
+			"quiet_hours_start", settings.QuietHoursStart,
+			"quiet_hours_end", settings.QuietHoursEnd,
+			"batch_window_seconds", settings.BatchWindowSeconds)
+		err = h.db.SaveNotificationSettings(ctx, settings)
+	}
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, method, endpoint) {
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error handling notification settings", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, settings)
+	h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusOK)
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"todo-app/internal/integrations"
+	"todo-app/internal/requestctx"
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GetNotifiers handles GET /notifiers, listing the notifiers rules can use
+func (h *Handlers) GetNotifiers(w http.ResponseWriter, r *http.Request) {
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, map[string]any{
+		"notifiers": h.notifications.Notifiers(),
+		"events":    integrations.NotificationEvents,
+	})
+}
+
+// NotificationRules serves GET and POST /notification-rules for the requesting user
+func (h *Handlers) NotificationRules(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	userID := requestctx.User(r.Context())
+	span.SetAttributes(attribute.String("user.id", userID))
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+		return
+	case "GET":
+		span.SetAttributes(attribute.String("operation", "get_notification_rules"))
+		rules, err := h.db.GetNotificationRules(ctx, userID)
+		if err != nil {
+			if h.abandonIfCanceled(ctx, start, "GET", "/notification-rules") {
+				return
+			}
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error getting notification rules", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.recordRequestMetrics(ctx, start, "GET", "/notification-rules", http.StatusInternalServerError)
+			return
+		}
+		h.recordRequestMetrics(ctx, start, "GET", "/notification-rules", writeList(w, r, rules))
+	case "POST":
+		var rule store.NotificationRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, "POST", "/notification-rules", http.StatusBadRequest)
+			return
+		}
+		rule.UserID = userID
+		if err := h.notifications.Validate(&rule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, "POST", "/notification-rules", http.StatusBadRequest)
+			return
+		}
+
+		span.SetAttributes(
+			attribute.String("operation", "create_notification_rule"),
+			attribute.String("notification.event", rule.Event),
+			attribute.String("notification.notifier", rule.Notifier),
+		)
+		slog.InfoContext(ctx, "Creating notification rule", "event", rule.Event, "notifier", rule.Notifier)
+
+		created, err := h.db.CreateNotificationRule(ctx, rule)
+		if err != nil {
+			if h.abandonIfCanceled(ctx, start, "POST", "/notification-rules") {
+				return
+			}
+			if errors.Is(err, store.ErrListNotFound) {
+				http.Error(w, "List not found", http.StatusUnprocessableEntity)
+				h.recordRequestMetrics(ctx, start, "POST", "/notification-rules", http.StatusUnprocessableEntity)
+				return
+			}
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error creating notification rule", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.recordRequestMetrics(ctx, start, "POST", "/notification-rules", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, r, created)
+		slog.InfoContext(ctx, "Notification rule created", "id", created.ID)
+		h.recordRequestMetrics(ctx, start, "POST", "/notification-rules", http.StatusCreated)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// NotificationRule serves DELETE /notification-rules/{id}
+func (h *Handlers) NotificationRule(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid rule ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := requestctx.User(r.Context())
+	span.SetAttributes(
+		attribute.String("operation", "delete_notification_rule"),
+		attribute.Int("notification.rule_id", id),
+		attribute.String("user.id", userID),
+	)
+	slog.InfoContext(ctx, "Deleting notification rule", "id", id)
+
+	if err := h.db.DeleteNotificationRule(ctx, userID, id); err != nil {
+		if h.abandonIfCanceled(ctx, start, "DELETE", "/notification-rules/:id") {
+			return
+		}
+		if err == sql.ErrNoRows {
+			http.Error(w, "Notification rule not found", http.StatusNotFound)
+			h.recordRequestMetrics(ctx, start, "DELETE", "/notification-rules/:id", http.StatusNotFound)
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error deleting notification rule", "error", err, "id", id)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, "DELETE", "/notification-rules/:id", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	h.recordRequestMetrics(ctx, start, "DELETE", "/notification-rules/:id", http.StatusNoContent)
+}
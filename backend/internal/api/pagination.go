@@ -0,0 +1,71 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"todo-app/internal/config"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxListRows is the most rows a list response holds, however many match, so no query can
+// grow a response without bound. Longer lists are read a page at a time with the limit
+// and offset parameters, and a page with rows after it links to the next one.
+var maxListRows = config.EnvInt("TODO_MAX_LIST_ROWS", 1000)
+
+// listPage is the part of a list a request asked for
+type listPage struct {
+	limit  int
+	offset int
+}
+
+// parseListPage reads the limit and offset parameters of r. The limit defaults to
+// defaultLimit and is cut to maxListRows rather than refused, as clients cannot know the
+// deployment's maximum; the next page link carries on from where the page stops.
+func parseListPage(r *http.Request, defaultLimit int) (listPage, error) {
+	page := listPage{limit: min(defaultLimit, maxListRows)}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return page, errors.New("Invalid limit, expected a positive number")
+		}
+		page.limit = min(n, maxListRows)
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return page, errors.New("Invalid offset, expected 0 or more")
+		}
+		page.offset = n
+	}
+	return page, nil
+}
+
+// next returns the URL of the page after p, for the list r asked for
+func (p listPage) next(r *http.Request) string {
+	u := *r.URL
+	q := u.Query()
+	q.Set("offset", strconv.Itoa(p.offset+p.limit))
+	q.Set("limit", strconv.Itoa(p.limit))
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
+}
+
+// setPageHints records the page on the request span and, when more rows follow it, links
+// the next page in a Link header. It returns the next page's URL, or "" for the last page.
+func setPageHints(w http.ResponseWriter, r *http.Request, page listPage, more bool) string {
+	trace.SpanFromContext(r.Context()).SetAttributes(
+		attribute.Int("list.limit", page.limit),
+		attribute.Int("list.offset", page.offset),
+		attribute.Bool("list.truncated", more),
+	)
+	if !more {
+		return ""
+	}
+	next := page.next(r)
+	w.Header().Set("Link", "<"+next+`>; rel="next"`)
+	return next
+}
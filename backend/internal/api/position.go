@@ -0,0 +1,79 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MoveTask handles POST /tasks/{id}/move
+func (h *Handlers) MoveTask(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := h.taskIDFromPath(w, r, start, "POST", "/tasks/:id/move", r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	var move store.TaskMove
+	if err := json.NewDecoder(r.Body).Decode(&move); err != nil || move.Position == nil {
+		http.Error(w, "Invalid request body, expected {\"position\": <index>}", http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, "POST", "/tasks/:id/move", http.StatusBadRequest)
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("operation", "move_task"),
+		attribute.Int("task.id", id),
+		attribute.Int("task.position", *move.Position),
+	)
+	slog.InfoContext(ctx, "Moving task", "id", id, "position", *move.Position)
+
+	task, err := h.db.MoveTask(ctx, id, *move.Position)
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "POST", "/tasks/:id/move") {
+			return
+		}
+		if err == sql.ErrNoRows {
+			slog.WarnContext(ctx, "Task not found for move", "id", id)
+			http.Error(w, "Task not found", http.StatusNotFound)
+			h.recordRequestMetrics(ctx, start, "POST", "/tasks/:id/move", http.StatusNotFound)
+		} else if errors.Is(err, store.ErrInvalidPosition) {
+			http.Error(w, "Position out of range", http.StatusUnprocessableEntity)
+			h.recordRequestMetrics(ctx, start, "POST", "/tasks/:id/move", http.StatusUnprocessableEntity)
+		} else {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error moving task", "error", err, "id", id)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.recordRequestMetrics(ctx, start, "POST", "/tasks/:id/move", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, task)
+	slog.InfoContext(ctx, "Task moved successfully", "id", task.ID, "position", task.Position)
+	h.recordRequestMetrics(ctx, start, "POST", "/tasks/:id/move", http.StatusOK)
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	runtimepprof "runtime/pprof"
+	"slices"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/scheduler"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PprofAddr is where the admin listener serves net/http/pprof; empty turns it off. It must
+// be a loopback address, so profiles are reached through the host (kubectl port-forward,
+// ssh) and never from the network.
+var PprofAddr = config.EnvString("TODO_PPROF_ADDR", "")
+
+// Continuous profiling: every TODO_PROFILE_INTERVAL each replica writes a CPU profile of
+// TODO_PROFILE_CPU_DURATION and a heap profile to TODO_PROFILE_DIR, keeping the newest
+// TODO_PROFILE_KEEP of each kind
+var (
+	profileDir         = config.EnvString("TODO_PROFILE_DIR", "")
+	profileInterval    = config.EnvDuration("TODO_PROFILE_INTERVAL", 10*time.Minute)
+	profileCPUDuration = config.EnvDuration("TODO_PROFILE_CPU_DURATION", 10*time.Second)
+	profileKeep        = config.EnvInt("TODO_PROFILE_KEEP", 144)
+)
+
+// CheckPprofAddr reports whether addr is a loopback address the pprof admin server may
+// listen on
+func CheckPprofAddr(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid TODO_PPROF_ADDR %q: %w", addr, err)
+	}
+	if !isLoopbackHost(host) {
+		return fmt.Errorf("TODO_PPROF_ADDR %q is not a loopback address, e.g. 127.0.0.1:6060", addr)
+	}
+	return nil
+}
+
+// NewPprofListener listens on addr for the pprof admin server, refusing addresses that are
+// not loopback
+func NewPprofListener(addr string) (net.Listener, error) {
+	if err := CheckPprofAddr(addr); err != nil {
+		return nil, err
+	}
+	return net.Listen("tcp", addr)
+}
+
+// isLoopbackHost reports whether every address host stands for is loopback; an empty host
+// listens on every interface
+func isLoopbackHost(host string) bool {
+	if host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.IsLoopback()
+	}
+	addrs, err := net.LookupHost(host)
+	if err != nil || len(addrs) == 0 {
+		return false
+	}
+	return !slices.ContainsFunc(addrs, func(a string) bool {
+		ip := net.ParseIP(a)
+		return ip == nil || !ip.IsLoopback()
+	})
+}
+
+// NewPprofServer serves the net/http/pprof handlers under /debug/pprof/ on a mux of its
+// own, so they are never part of the public router. It has no write timeout, since
+// /debug/pprof/profile and /debug/pprof/trace stream for as long as ?seconds= asks.
+func NewPprofServer() *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+}
+
+// NewProfileExportJob returns the continuous profiling job, or false when TODO_PROFILE_DIR
+// is not set. Each replica profiles itself, so file names carry the instance ID.
+func NewProfileExportJob() (scheduler.Job, bool, error) {
+	if profileDir == "" {
+		return scheduler.Job{}, false, nil
+	}
+	if profileCPUDuration <= 0 || profileCPUDuration >= profileInterval {
+		return scheduler.Job{}, false, fmt.Errorf("TODO_PROFILE_CPU_DURATION must be positive and shorter than TODO_PROFILE_INTERVAL (%s), got %s", profileInterval, profileCPUDuration)
+	}
+	if profileKeep < 1 {
+		return scheduler.Job{}, false, fmt.Errorf("TODO_PROFILE_KEEP must be at least 1, got %d", profileKeep)
+	}
+	if err := os.MkdirAll(profileDir, 0o750); err != nil {
+		return scheduler.Job{}, false, fmt.Errorf("failed to create TODO_PROFILE_DIR: %w", err)
+	}
+	return scheduler.Job{
+		Name:         "profile_export",
+		Interval:     profileInterval,
+		Run:          exportProfiles,
+		EveryReplica: true,
+	}, true, nil
+}
+
+// exportProfiles writes one CPU and one heap profile, then removes the oldest beyond
+// TODO_PROFILE_KEEP. The CPU profile fails while another is running, such as one asked of
+// /debug/pprof/profile or a diagnostics bundle; the heap profile is written anyway.
+func exportProfiles(ctx context.Context) error {
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	prefix := filepath.Join(profileDir, telemetry.InstanceID+"-"+stamp)
+
+	cpuErr := writeProfileFile(prefix+"-cpu.pprof", func(f *os.File) error {
+		if err := runtimepprof.StartCPUProfile(f); err != nil {
+			return err
+		}
+		select {
+		case <-time.After(profileCPUDuration):
+		case <-ctx.Done():
+		}
+		runtimepprof.StopCPUProfile()
+		return ctx.Err()
+	})
+	heapErr := writeProfileFile(prefix+"-heap.pprof", func(f *os.File) error {
+		return runtimepprof.Lookup("heap").WriteTo(f, 0)
+	})
+
+	removed := 0
+	for _, kind := range []string{"cpu", "heap"} {
+		removed += pruneProfiles(ctx, kind)
+	}
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("profile_export.prefix", prefix),
+		attribute.Int("profile_export.removed", removed),
+	)
+	if cpuErr != nil {
+		return fmt.Errorf("cpu profile: %w", cpuErr)
+	}
+	if heapErr != nil {
+		return fmt.Errorf("heap profile: %w", heapErr)
+	}
+	return nil
+}
+
+// writeProfileFile writes a profile to path, removing the file if writing fails
+func writeProfileFile(path string, write func(f *os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// pruneProfiles removes the oldest profiles of a kind in TODO_PROFILE_DIR beyond
+// TODO_PROFILE_KEEP, by modification time, since instance IDs change on every restart. In a
+// directory shared by replicas the limit is for all of them.
+func pruneProfiles(ctx context.Context, kind string) int {
+	matches, err := filepath.Glob(filepath.Join(profileDir, "*-"+kind+".pprof"))
+	if err != nil || len(matches) <= profileKeep {
+		return 0
+	}
+	modTimes := make(map[string]time.Time, len(matches))
+	for _, path := range matches {
+		if info, err := os.Stat(path); err == nil {
+			modTimes[path] = info.ModTime()
+		}
+	}
+	slices.SortFunc(matches, func(a, b string) int { return modTimes[a].Compare(modTimes[b]) })
+	removed := 0
+	for _, path := range matches[:len(matches)-profileKeep] {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			slog.WarnContext(ctx, "Failed to remove old profile", "path", path, "error", err)
+			continue
+		}
+		removed++
+	}
+	return removed
+}
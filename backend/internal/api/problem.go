@@ -0,0 +1,124 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceIDHeader carries the ID of the trace a request was served in, for users to paste
+// into the tracing UI when reporting a problem
+const traceIDHeader = "X-Trace-ID"
+
+// problemContentType is the media type of RFC 7807 problem details
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body. TraceID is an extension member with the
+// trace the failed request was served in.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	TraceID  string `json:"trace_id,omitempty"`
+}
+
+// traceID returns the ID of the trace ctx belongs to, or "" when it is not traced
+func traceID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
+
+// problemWriter turns the plain text error responses of http.Error into problem details.
+// Handlers keep writing their messages as text; the body and status are held back until
+// the handler returns, then written as JSON with the message as detail.
+type problemWriter struct {
+	http.ResponseWriter
+	r      *http.Request
+	status int
+	// detail collects the message of an error response being converted
+	detail *bytes.Buffer
+}
+
+func (w *problemWriter) WriteHeader(statusCode int) {
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	w.status = statusCode
+	if statusCode >= 400 && strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		w.detail = &bytes.Buffer{}
+		return
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *problemWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.detail != nil {
+		return w.detail.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// finish writes the problem details of a converted error response
+func (w *problemWriter) finish() {
+	if w.detail == nil {
+		return
+	}
+	problem := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(w.status),
+		Status:   w.status,
+		Detail:   strings.TrimSpace(w.detail.String()),
+		Instance: w.r.URL.Path,
+		TraceID:  traceID(w.r.Context()),
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.status)
+	json.NewEncoder(w.ResponseWriter).Encode(problem)
+}
+
+func (w *problemWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush and Hijack keep streaming responses and the WebSocket upgrade working through the
+// wrapper; an error response being converted is only written once the handler returns
+
+func (w *problemWriter) Flush() {
+	if w.detail != nil {
+		return
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *problemWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// problemMiddleware returns the request's trace ID in X-Trace-ID and turns the handler's
+// text error responses into RFC 7807 problem details carrying it too. It runs inside the
+// route's otelhttp handler, whose span is the one to look up.
+func problemMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := traceID(r.Context()); id != "" {
+			w.Header().Set(traceIDHeader, id)
+		}
+		pw := &problemWriter{ResponseWriter: w, r: r}
+		defer pw.finish()
+		next.ServeHTTP(pw, r)
+	})
+}
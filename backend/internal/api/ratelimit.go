@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/requestctx"
+	"todo-app/internal/telemetry"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// rateLimit is how many requests a user may make per rateLimitWindow; 0 turns limiting off
+	rateLimit = config.EnvInt("TODO_RATE_LIMIT", 0)
+	// rateLimitWindow is the fixed window requests are counted in
+	rateLimitWindow = config.EnvDuration("TODO_RATE_LIMIT_WINDOW", time.Minute)
+)
+
+// Response headers describing the caller's rate limit
+const (
+	rateLimitLimitHeader     = "X-RateLimit-Limit"
+	rateLimitRemainingHeader = "X-RateLimit-Remaining"
+)
+
+// rateCounter counts requests per key in fixed windows
+type rateCounter interface {
+	// incr counts a request for key in the window starting at windowStart and returns the
+	// count so far
+	incr(ctx context.Context, key string, windowStart time.Time, window time.Duration) (int64, error)
+}
+
+// memoryRateCounter is a rateCounter for a single replica
+type memoryRateCounter struct {
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int64
+}
+
+func (c *memoryRateCounter) incr(_ context.Context, key string, windowStart time.Time, _ time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !windowStart.Equal(c.start) {
+		c.start, c.counts = windowStart, map[string]int64{}
+	}
+	c.counts[key]++
+	return c.counts[key], nil
+}
+
+// RateLimiter caps how many requests each user makes per window, counting in Redis when it
+// is configured so the limit applies across replicas rather than per replica
+type RateLimiter struct {
+	limit   int
+	window  time.Duration
+	counter rateCounter
+
+	limited metric.Int64Counter
+}
+
+// NewRateLimiter creates the limiter configured by TODO_RATE_LIMIT. rdb may be nil.
+func NewRateLimiter(rdb *redis.Client) *RateLimiter {
+	return newRateLimiter(rateLimit, rateLimitWindow, rdb)
+}
+
+func newRateLimiter(limit int, window time.Duration, rdb *redis.Client) *RateLimiter {
+	l := &RateLimiter{limit: limit, window: window, counter: &memoryRateCounter{}}
+	if rdb != nil {
+		l.counter = &redisRateCounter{rdb: rdb}
+	}
+	l.limited, _ = telemetry.GetMeter().Int64Counter("todo_app.rate_limited",
+		metric.WithDescription("Requests rejected by the rate limit"),
+		metric.WithUnit("1"))
+	return l
+}
+
+// Allow counts a request for userID. It returns whether it is within the limit, the
+// requests left in the window and how long until the window resets. If the counter fails
+// the request is let through: an outage of the store should not take the API down with it.
+func (l *RateLimiter) Allow(ctx context.Context, userID string) (bool, int, time.Duration) {
+	if l.limit <= 0 {
+		return true, math.MaxInt, 0
+	}
+	now := time.Now()
+	windowStart := now.Truncate(l.window)
+	reset := windowStart.Add(l.window).Sub(now)
+
+	count, err := l.counter.incr(ctx, userID, windowStart, l.window)
+	if err != nil {
+		slog.WarnContext(ctx, "Error counting request for rate limit", "error", err)
+		return true, l.limit, reset
+	}
+	if count > int64(l.limit) {
+		l.limited.Add(ctx, 1)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("rate_limited", true))
+		slog.WarnContext(ctx, "Rate limit exceeded", "user_id", userID, "limit", l.limit, "window", l.window)
+		return false, 0, reset
+	}
+	return true, l.limit - int(count), reset
+}
+
+// Middleware rejects requests over the user's limit with 429 and Retry-After. It must run
+// after UserMiddleware, which identifies the user.
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	if l.limit <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ok, remaining, reset := l.Allow(r.Context(), requestctx.User(r.Context()))
+		w.Header().Set(rateLimitLimitHeader, strconv.Itoa(l.limit))
+		w.Header().Set(rateLimitRemainingHeader, strconv.Itoa(remaining))
+		if !ok {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(reset.Seconds()))))
+			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Startup stages that must finish before the server is ready
+const (
+	StageDatabase   = "database"
+	StageMigrations = "migrations"
+	StageWarmup     = "warmup"
+)
+
+// startupRequestWait is how long a request that arrives during startup is held for the
+// server to become ready before it gets 503
+var startupRequestWait = config.EnvDuration("TODO_STARTUP_REQUEST_WAIT", 10*time.Second)
+
+// Readiness tracks startup for the health probes. The server listens before the database
+// is migrated so /healthz and /readyz can answer; /readyz returns 503 until every stage is
+// done, so a load balancer only routes to an instance that can serve. Other requests that
+// arrive early wait for readiness.
+type Readiness struct {
+	mu      sync.Mutex
+	pending []string
+	done    map[string]time.Duration
+	started time.Time
+	handler http.Handler
+	ready   chan struct{}
+	checks  map[string]func() error
+}
+
+// NewReadiness starts tracking the given startup stages
+func NewReadiness(stages ...string) *Readiness {
+	r := &Readiness{
+		pending: stages,
+		done:    map[string]time.Duration{},
+		checks:  map[string]func() error{},
+		started: time.Now(),
+		ready:   make(chan struct{}),
+	}
+	telemetry.GetMeter().Int64ObservableGauge("todo_app.ready",
+		metric.WithDescription("1 when the instance is ready to serve traffic, 0 while starting"),
+		metric.WithUnit("1"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			if r.Ready() {
+				o.Observe(1)
+			} else {
+				o.Observe(0)
+			}
+			return nil
+		}))
+	return r
+}
+
+// Done marks a startup stage finished
+func (r *Readiness) Done(ctx context.Context, stage string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, s := range r.pending {
+		if s == stage {
+			r.pending = append(r.pending[:i:i], r.pending[i+1:]...)
+			break
+		}
+	}
+	r.done[stage] = time.Since(r.started)
+	slog.InfoContext(ctx, "Startup stage finished", "stage", stage, "elapsed", r.done[stage])
+	r.checkReady(ctx)
+}
+
+// SetHandler installs the handler for everything but the probes. Readiness also waits for it.
+func (r *Readiness) SetHandler(ctx context.Context, h http.Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handler = h
+	r.checkReady(ctx)
+}
+
+func (r *Readiness) checkReady(ctx context.Context) {
+	if len(r.pending) > 0 || r.handler == nil {
+		return
+	}
+	select {
+	case <-r.ready:
+	default:
+		close(r.ready)
+		slog.InfoContext(ctx, "Server ready", "startup", time.Since(r.started))
+	}
+}
+
+// AddCheck adds a health check to /readyz once startup has finished. An instance whose check
+// returns an error answers 503 with the error, so it is taken out of rotation until it
+// recovers; requests are still served.
+func (r *Readiness) AddCheck(name string, check func() error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = check
+}
+
+// Ready reports whether startup has finished
+func (r *Readiness) Ready() bool {
+	select {
+	case <-r.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// readinessStatus is the body of /readyz
+type readinessStatus struct {
+	Status   string           `json:"status"`
+	Pending  []string         `json:"pending,omitempty"`
+	Finished map[string]int64 `json:"finished_ms,omitempty"`
+	// Checks holds "ok" or the error of each check added with AddCheck
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Handler serves /healthz and /readyz, and passes everything else to the handler set with
+// SetHandler once startup has finished
+func (r *Readiness) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/healthz":
+			// Liveness: the process is up and serving, whatever its readiness
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte("ok\n"))
+			return
+		case "/readyz":
+			r.serveReadyz(w)
+			return
+		}
+
+		select {
+		case <-r.ready:
+		default:
+			if !r.waitReady(req) {
+				w.Header().Set("Retry-After", strconv.Itoa(max(int(startupRequestWait.Seconds()), 1)))
+				http.Error(w, "Server is starting", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		// handler is set before ready is closed and never changes after
+		r.handler.ServeHTTP(w, req)
+	})
+}
+
+// waitReady holds a request that arrived during startup until the server is ready, for at
+// most startupRequestWait
+func (r *Readiness) waitReady(req *http.Request) bool {
+	ctx := req.Context()
+	_, span := telemetry.GetTracer().Start(ctx, "startup.wait", trace.WithAttributes(
+		attribute.String("http.method", req.Method),
+		attribute.String("http.path", req.URL.Path),
+	))
+	defer span.End()
+
+	timer := time.NewTimer(startupRequestWait)
+	defer timer.Stop()
+	select {
+	case <-r.ready:
+		return true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	span.SetAttributes(attribute.Bool("startup.timed_out", true))
+	slog.WarnContext(ctx, "Rejected request during startup", "method", req.Method, "path", req.URL.Path)
+	return false
+}
+
+// serveReadyz answers /readyz. It is a probe outside the API versions, so it keeps the
+// default JSON format whatever the Accept header asks for.
+func (r *Readiness) serveReadyz(w http.ResponseWriter) {
+	r.mu.Lock()
+	status := readinessStatus{Status: "ready", Finished: map[string]int64{}}
+	for stage, elapsed := range r.done {
+		status.Finished[stage] = elapsed.Milliseconds()
+	}
+	code := http.StatusOK
+	if !r.Ready() {
+		status.Status, code = "starting", http.StatusServiceUnavailable
+		status.Pending = append([]string{}, r.pending...)
+		if r.handler == nil {
+			status.Pending = append(status.Pending, "handlers")
+		}
+	} else if len(r.checks) > 0 {
+		status.Checks = map[string]string{}
+		for name, check := range r.checks {
+			status.Checks[name] = "ok"
+			if err := check(); err != nil {
+				status.Checks[name] = err.Error()
+				status.Status, code = "unhealthy", http.StatusServiceUnavailable
+			}
+		}
+	}
+	r.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recoverWriter notes whether the response has started, after which a panic can no longer
+// be answered with a 500
+type recoverWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (w *recoverWriter) WriteHeader(statusCode int) {
+	w.started = true
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *recoverWriter) Write(b []byte) (int, error) {
+	w.started = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recoverWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush and Hijack keep streaming responses and the WebSocket upgrade working through the wrapper
+
+func (w *recoverWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *recoverWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.started = true
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// recoverMiddleware turns a panic in the handler of pattern into a 500 instead of a dropped
+// connection. The panic and its stack are recorded on the request span, logged at ERROR with
+// the trace and counted in todo_app.http.panics. It runs inside problemMiddleware, so the 500
+// is a problem response with the trace ID to look the stack up by. A panic after the
+// response started can only abort it.
+func (h *Handlers) recoverMiddleware(pattern string, next http.Handler) http.Handler {
+	method, endpoint, _ := strings.Cut(pattern, " ")
+	endpoint = routeEndpoint(endpoint)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &recoverWriter{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			ctx := r.Context()
+			stack := string(debug.Stack())
+			err, ok := p.(error)
+			if !ok {
+				err = fmt.Errorf("%v", p)
+			}
+
+			span := trace.SpanFromContext(ctx)
+			span.RecordError(err, trace.WithAttributes(
+				attribute.String("exception.type", fmt.Sprintf("%T", p)),
+				attribute.String("exception.stacktrace", stack),
+			))
+			span.SetStatus(codes.Error, "panic: "+err.Error())
+			slog.ErrorContext(ctx, "Recovered from panic in handler", "error", err, "route", pattern, "stack", stack)
+			h.panics.Add(ctx, 1, metric.WithAttributes(attribute.String("route", pattern)))
+
+			if rw.started {
+				panic(http.ErrAbortHandler)
+			}
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// routeEndpoint returns the endpoint label the handlers record metrics under for a route
+// path, /tasks/:id for /tasks/{id}
+func routeEndpoint(path string) string {
+	var b strings.Builder
+	for {
+		open := strings.IndexByte(path, '{')
+		closing := strings.IndexByte(path, '}')
+		if open < 0 || closing < open {
+			b.WriteString(path)
+			return b.String()
+		}
+		b.WriteString(path[:open])
+		b.WriteString(":" + strings.TrimSuffix(path[open+1:closing], "..."))
+		path = path[closing+1:]
+	}
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// UseRedis moves the task list cache and Idempotency-Keys to Redis, shared by every replica
+func (h *Handlers) UseRedis(rdb *redis.Client) {
+	h.taskCache = &redisCache{rdb: rdb}
+	h.idempotency = &redisIdempotencyStore{rdb: rdb}
+}
+
+// redisIdempotencyStore keeps Idempotency-Keys in Redis, where they expire on their own
+type redisIdempotencyStore struct {
+	rdb *redis.Client
+}
+
+// redisIdempotencyRecord is a key's value; Status is 0 while the request is in progress
+type redisIdempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	ChangeSeq   int64  `json:"change_seq,omitempty"`
+	Body        string `json:"body,omitempty"`
+}
+
+func (s *redisIdempotencyStore) BeginIdempotentRequest(ctx context.Context, userID, key, fingerprint string) (*store.IdempotentResponse, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "redis.beginIdempotentRequest",
+		trace.WithAttributes(attribute.String("db.operation", "insert_idempotency_key")))
+	defer span.End()
+
+	k := store.RedisKey("idempotency", userID, key)
+	pending, err := json.Marshal(redisIdempotencyRecord{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, err
+	}
+	// A key that expires between SETNX and GET is free again, so try once more
+	for range 2 {
+		reserved, err := s.rdb.SetNX(ctx, k, pending, store.IdempotencyTTL).Result()
+		if err != nil {
+			return nil, err
+		}
+		if reserved {
+			return nil, nil
+		}
+		raw, err := s.rdb.Get(ctx, k).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var record redisIdempotencyRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return nil, err
+		}
+		if record.Fingerprint != fingerprint {
+			return nil, store.ErrIdempotencyKeyReused
+		}
+		if record.Status == 0 {
+			return nil, store.ErrIdempotencyKeyInUse
+		}
+		body, err := store.OpenField(store.FieldIdempotentResponse, record.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &store.IdempotentResponse{
+			Status:      record.Status,
+			ContentType: record.ContentType,
+			ChangeSeq:   record.ChangeSeq,
+			Body:        body,
+		}, nil
+	}
+	return nil, store.ErrIdempotencyKeyInUse
+}
+
+func (s *redisIdempotencyStore) FinishIdempotentRequest(ctx context.Context, userID, key string, response store.IdempotentResponse) error {
+	k := store.RedisKey("idempotency", userID, key)
+	raw, err := s.rdb.Get(ctx, k).Bytes()
+	if err != nil {
+		return err
+	}
+	var record redisIdempotencyRecord
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return err
+	}
+	if record.Body, err = store.SealField(store.FieldIdempotentResponse, response.Body); err != nil {
+		return err
+	}
+	record.Status, record.ContentType, record.ChangeSeq = response.Status, response.ContentType, response.ChangeSeq
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.rdb.Set(ctx, k, value, redis.KeepTTL).Err()
+}
+
+func (s *redisIdempotencyStore) AbandonIdempotentRequest(ctx context.Context, userID, key string) error {
+	k := store.RedisKey("idempotency", userID, key)
+	raw, err := s.rdb.Get(ctx, k).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var record redisIdempotencyRecord
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return err
+	}
+	if record.Status != 0 {
+		return nil
+	}
+	return s.rdb.Del(ctx, k).Err()
+}
+
+// redisNonceStore keeps signature nonces in Redis until they can no longer be replayed
+type redisNonceStore struct {
+	rdb    *redis.Client
+	maxAge time.Duration
+}
+
+func NewRedisNonceStore(rdb *redis.Client, maxAge time.Duration) store.NonceStore {
+	return &redisNonceStore{rdb: rdb, maxAge: maxAge}
+}
+
+func (s *redisNonceStore) Add(ctx context.Context, key string, _ time.Time) (bool, error) {
+	// Timestamps up to maxAge in the future are accepted, so remember the nonce for both sides
+	return s.rdb.SetNX(ctx, store.RedisKey("nonce", key), 1, 2*s.maxAge).Result()
+}
+
+// redisCache is a taskListCache in Redis, shared by the replicas. Values are sealed when
+// field encryption is on, since they hold task titles and descriptions.
+type redisCache struct {
+	rdb *redis.Client
+}
+
+func (c *redisCache) get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.rdb.Get(ctx, store.RedisKey("cache", key)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	value, err = store.OpenField(store.FieldCachedTasks, value)
+	return []byte(value), err == nil, err
+}
+
+func (c *redisCache) set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	sealed, err := store.SealField(store.FieldCachedTasks, string(value))
+	if err != nil {
+		return err
+	}
+	return c.rdb.Set(ctx, store.RedisKey("cache", key), sealed, ttl).Err()
+}
+
+// redisRateCounter counts requests in Redis, so a limit holds across the replicas
+type redisRateCounter struct {
+	rdb *redis.Client
+}
+
+func (c *redisRateCounter) incr(ctx context.Context, key string, windowStart time.Time, window time.Duration) (int64, error) {
+	k := store.RedisKey("rate", key, strconv.FormatInt(windowStart.Unix(), 10))
+	pipe := c.rdb.TxPipeline()
+	count := pipe.Incr(ctx, k)
+	pipe.Expire(ctx, k, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
+}
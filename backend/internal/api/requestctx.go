@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"todo-app/internal/config"
+	"todo-app/internal/requestctx"
+	"todo-app/internal/store"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestIDHeader carries the ID of a request. A caller's own ID is kept, so one ID can
+// follow a request through a fronting proxy; otherwise one is made up. Either way it is
+// returned in the response.
+const requestIDHeader = "X-Request-ID"
+
+// tenantHeader names the tenant a request acts for. Like X-User-ID it is not
+// authenticated; a fronting proxy is expected to set it.
+const tenantHeader = "X-Tenant-ID"
+
+// maxRequestIDLength bounds a caller's request ID, which ends up in every log line
+const maxRequestIDLength = 128
+
+// requestID returns the caller's request ID, or a new one when it sent none or one that
+// is too long or not printable ASCII
+func requestID(r *http.Request) string {
+	id := strings.TrimSpace(r.Header.Get(requestIDHeader))
+	if id == "" || len(id) > maxRequestIDLength || strings.ContainsFunc(id, func(c rune) bool { return c < 0x21 || c > 0x7e }) {
+		return uuid.NewString()
+	}
+	return id
+}
+
+// RequestContextMiddleware stores the request ID, tenant and locale of a request in its
+// context, for requestctx to hand to the code below. It runs outside every other
+// middleware, so their log lines carry the request ID too.
+func RequestContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestID(r)
+		w.Header().Set(requestIDHeader, id)
+
+		ctx := requestctx.WithRequestID(r.Context(), id)
+		if tenant := strings.TrimSpace(r.Header.Get(tenantHeader)); tenant != "" {
+			ctx = requestctx.WithTenant(ctx, tenant)
+		}
+		ctx = requestctx.WithLocale(ctx, store.RequestLocale(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// baggageKeys are the W3C baggage entries recorded from a caller, as baggage.<key>, on the
+// request span, the spans below it and its log lines. Baggage is not authenticated, so a
+// baggage user.id is only what the caller claims; it never replaces X-User-ID.
+var baggageKeys = config.SplitList(config.EnvString("TODO_BAGGAGE_KEYS", "user.id,client.version"))
+
+// maxBaggageValueLength bounds a recorded baggage value, which ends up on every span
+const maxBaggageValueLength = 256
+
+// requestBaggage returns the entries of baggageKeys in the baggage otelhttp extracted from
+// the request, leaving out values that are too long
+func requestBaggage(ctx context.Context) []attribute.KeyValue {
+	bag := baggage.FromContext(ctx)
+	if bag.Len() == 0 {
+		return nil
+	}
+	var attrs []attribute.KeyValue
+	for _, key := range baggageKeys {
+		if value := bag.Member(key).Value(); value != "" && len(value) <= maxBaggageValueLength {
+			attrs = append(attrs, attribute.String("baggage."+key, value))
+		}
+	}
+	return attrs
+}
+
+// requestSpanMiddleware records the request ID, tenant and selected baggage on the request
+// span, which is only started once the request is routed. The baggage also goes into the
+// context, from where it reaches the spans started below and log records. The route and span
+// are noted for the access log line too.
+func requestSpanMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		noteAccessLogRoute(r)
+		ctx := r.Context()
+		span := trace.SpanFromContext(ctx)
+		if id := requestctx.RequestID(ctx); id != "" {
+			span.SetAttributes(attribute.String("request.id", id))
+		}
+		if tenant := requestctx.Tenant(ctx); tenant != "" {
+			span.SetAttributes(attribute.String("tenant.id", tenant))
+		}
+		if attrs := requestBaggage(ctx); len(attrs) > 0 {
+			span.SetAttributes(attrs...)
+			r = r.WithContext(requestctx.WithBaggage(ctx, attrs))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"todo-app/internal/requestctx"
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GetReview serves GET /review, the requesting user's weekly review queue: open tasks that
+// are overdue, stale, without a due date or unprioritized, most pressing first, leaving out
+// those they decided on recently
+func (h *Handlers) GetReview(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	method, endpoint := "GET", "/review"
+
+	page, err := parseListPage(r, 50)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+		return
+	}
+	userID := requestctx.User(ctx)
+	span.SetAttributes(
+		attribute.String("operation", "get_review"),
+		attribute.String("user.id", userID),
+	)
+
+	// One more than the page, to tell whether another follows
+	items, err := h.db.GetReviewQueue(ctx, userID, page.offset+page.limit+1)
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, method, endpoint) {
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error fetching review queue", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusInternalServerError)
+		return
+	}
+
+	more := len(items) > page.offset+page.limit
+	items = items[min(page.offset, len(items)):min(page.offset+page.limit, len(items))]
+	writeListPage(w, r, items, page, more)
+	h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusOK)
+}
+
+// ReviewDecision serves POST /review/{id}/decision, which records the requesting user's
+// keep, defer or delete decision on a task in their review queue
+func (h *Handlers) ReviewDecision(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	method, endpoint := "POST", "/review/:id/decision"
+
+	id, ok := h.taskIDFromPath(w, r, start, method, endpoint, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Decision string     `json:"decision"`
+		Until    *time.Time `json:"until"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body, expected {\"decision\": \"keep\", \"defer\" or \"delete\"}", http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+		return
+	}
+
+	userID := requestctx.User(ctx)
+	span.SetAttributes(
+		attribute.String("operation", "review_decision"),
+		attribute.Int("task.id", id),
+		attribute.String("review.decision", req.Decision),
+	)
+	slog.InfoContext(ctx, "Recording review decision", "id", id, "decision", req.Decision)
+
+	decision, err := h.db.DecideReview(ctx, userID, id, req.Decision, req.Until)
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, method, endpoint) {
+			return
+		}
+		status := http.StatusInternalServerError
+		switch {
+		case err == sql.ErrNoRows:
+			status = http.StatusNotFound
+			http.Error(w, "Task not found or already completed", status)
+		case errors.Is(err, store.ErrInvalidReviewDecision):
+			status = http.StatusBadRequest
+			http.Error(w, "Invalid decision, expected keep, defer or delete", status)
+		case errors.Is(err, store.ErrDeferInPast):
+			status = http.StatusBadRequest
+			http.Error(w, "until must be in the future", status)
+		case errors.Is(err, store.ErrTaskClaimed):
+			status = http.StatusConflict
+			http.Error(w, err.Error(), status)
+		default:
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error recording review decision", "error", err, "id", id)
+			http.Error(w, "Internal server error", status)
+		}
+		h.recordRequestMetrics(ctx, start, method, endpoint, status)
+		return
+	}
+
+	if decision.Decision == store.ReviewDelete {
+		h.events.Publish(ctx, store.BusEvent{Type: store.EventTaskDeleted, TaskID: id})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, decision)
+	h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusOK)
+}
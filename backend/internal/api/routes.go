@@ -0,0 +1,974 @@
+// Package api serves the REST and gRPC APIs, the WebSocket feed and the admin endpoints, with
+// the middleware they share.
+package api
+
+import (
+	_ "embed"
+	"net/http"
+	"strings"
+
+	"todo-app/internal/scheduler"
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+	"todo-app/openapi"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+//go:embed docs.html
+var docsPage []byte
+
+// NewRouter maps every route to its handler. Each route gets its own otelhttp handler named
+// after its pattern, so spans read e.g. "POST /tasks/{id}/complete" and carry http.route.
+// A path that matches a route with another method gets 405 from the mux.
+//
+// Every API route is documented as it is registered, and the resulting OpenAPI document is
+// served at /openapi.json with Swagger UI at /docs.
+func NewRouter(handlers *Handlers) *http.ServeMux {
+	mux := http.NewServeMux()
+	api := newAPIBuilder()
+
+	route := func(pattern string, handler http.HandlerFunc, op openapi.Operation) {
+		api.Add(pattern, op)
+		mux.Handle(pattern, otelhttp.NewHandler(requestSpanMiddleware(problemMiddleware(handlers.recoverMiddleware(pattern, handlers.unavailableMiddleware(APIVersionMiddleware(FeatureOverrideMiddleware(handler)))))), pattern))
+	}
+	// admin routes need the admin token
+	admin := func(pattern string, handler http.HandlerFunc, op openapi.Operation) {
+		op.Description = strings.TrimSpace("Needs the admin token as \"Authorization: Bearer <token>\". " + op.Description)
+		if op.Responses == nil {
+			op.Responses = map[string]openapi.Response{}
+		}
+		op.Responses["401"] = problemResponse("Missing or wrong admin token")
+		api.Add(pattern, op)
+		mux.Handle(pattern, otelhttp.NewHandler(requestSpanMiddleware(problemMiddleware(handlers.recoverMiddleware(pattern, handlers.adminMiddleware(pattern, handlers.unavailableMiddleware(APIVersionMiddleware(FeatureOverrideMiddleware(handler))))))), pattern))
+	}
+	// traced routes also record request and response bodies as span events
+	traced := func(pattern string, handler http.HandlerFunc, op openapi.Operation) {
+		api.Add(pattern, op)
+		mux.Handle(pattern, otelhttp.NewHandler(requestSpanMiddleware(BodyTracingMiddleware(problemMiddleware(handlers.recoverMiddleware(pattern, handlers.unavailableMiddleware(APIVersionMiddleware(FeatureOverrideMiddleware(handler))))))), pattern))
+	}
+
+	// Serve frontend files
+	mux.Handle("GET /", http.FileServer(http.Dir("../frontend")))
+	mux.HandleFunc("OPTIONS /", handlers.Preflight)
+
+	mux.Handle("GET /openapi.json", api.Handler())
+	mux.HandleFunc("GET /docs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(docsPage)
+	})
+
+	taskRef := openapi.Parameter{Name: "id", In: "path", Required: true, Schema: openapi.String(),
+		Description: "Numeric task ID or task UUID"}
+	notFound := problemResponse("Task not found")
+	conflict := problemResponse("Another user holds a claim on the task")
+
+	traced("GET /tasks", handlers.GetTasks, openapi.Operation{
+		Summary: "List tasks", Tags: []string{"tasks"}, OperationID: "listTasks",
+		Parameters: []openapi.Parameter{
+			apiVersionParam(), limitParam(), offsetParam(),
+			query("q", "Case- and diacritic-insensitive title search"),
+			query("sort", "created_at (default), title or position"),
+			query("list", "Only tasks in the list with this ID"),
+			query("tag", "Only tasks carrying this tag"),
+			query("locale", "BCP 47 tag for title collation"),
+			query("view", "stale: only open tasks unchanged for TODO_STALE_DAYS"),
+			query("since_seq", "Return the changes after this change sequence instead of the list; excludes the other parameters"),
+		},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "Tasks, enveloped for X-API-Version 2, or the changes after since_seq", Content: map[string]openapi.MediaType{
+				"application/json": {Schema: &openapi.Schema{OneOf: []*openapi.Schema{
+					api.SchemaOf([]store.Task{}), api.SchemaOf(store.TaskChanges{}), api.SchemaOf(ListEnvelope{}),
+				}}},
+			}},
+			"400": problemResponse("Invalid parameter"),
+		},
+	})
+	traced("POST /tasks", handlers.Idempotent("/tasks", handlers.CreateTask), openapi.Operation{
+		Summary: "Create a task", Tags: []string{"tasks"}, OperationID: "createTask",
+		Description: "With an Idempotency-Key, a retry with the same key and body returns the original response " +
+			"with Idempotent-Replayed: true instead of creating another task.",
+		Parameters: []openapi.Parameter{{Name: idempotencyKeyHeader, In: "header", Schema: openapi.String(),
+			Description: "Client-chosen key, unique per request, that makes retries safe"}},
+		RequestBody: api.Body(store.NewTask{}),
+		Responses: map[string]openapi.Response{
+			"201": api.Returns("Created task", store.Task{}),
+			"400": problemResponse("Invalid title, tags, body or Idempotency-Key"),
+			"403": problemResponse("The workspace is at its open task quota"),
+			"409": problemResponse("A request with the same Idempotency-Key is still in progress"),
+			"413": problemResponse("The body is larger than 1 MiB"),
+			"422": problemResponse("Unknown list, or the Idempotency-Key was used for a different request"),
+		},
+	})
+	traced("POST /tasks/bulk", handlers.BulkTasks, openapi.Operation{
+		Summary: "Apply create, complete and delete operations in one transaction", Tags: []string{"tasks"}, OperationID: "bulkTasks",
+		RequestBody: api.Body(struct {
+			Operations []store.BulkOperation `json:"operations"`
+		}{}),
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("All operations applied", BulkResponse{}),
+			"422": api.Returns("An operation failed and the batch was rolled back", BulkResponse{}),
+		},
+	})
+	traced("POST /tasks/merge", handlers.MergeTasks, openapi.Operation{
+		Summary: "Merge duplicate tasks into one", Tags: []string{"tasks"}, OperationID: "mergeTasks",
+		Description: "The surviving task gets the union of the tags, the earliest created_at and, if it has none, the earliest " +
+			"due date; subtasks, dependencies and attachments move to it. The merged tasks are removed at once, and their IDs and UUIDs " +
+			"redirect to the survivor with 308.",
+		RequestBody: api.Body(&openapi.Schema{Type: "object", Required: []string{"into", "tasks"}, Properties: map[string]*openapi.Schema{
+			"into":  {Description: "Numeric ID or UUID of the surviving task"},
+			"tasks": {Type: "array", Items: &openapi.Schema{}, Description: "Numeric IDs or UUIDs of the tasks to merge, at most 100"},
+		}}),
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Surviving task", store.Task{}),
+			"400": problemResponse("Invalid body, no or too many tasks, or a task listed twice"),
+			"404": notFound,
+			"409": problemResponse("Another user holds a claim, or merging would create a dependency cycle"),
+			"422": problemResponse("A merged task is an ancestor of the survivor, or there would be too many tags"),
+		},
+	})
+	traced("GET /tasks/changes", handlers.GetTaskDelta, openapi.Operation{
+		Summary: "Tasks created, updated and deleted since a time, for offline sync", Tags: []string{"tasks"}, OperationID: "getTaskDelta",
+		Parameters: []openapi.Parameter{{Name: "since", In: "query", Required: true, Description: "RFC 3339 timestamp; pass the previous server_time",
+			Schema: &openapi.Schema{Type: "string", Format: "date-time"}}},
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Changes after since", store.TaskDelta{}),
+			"400": problemResponse("Missing or invalid since"),
+		},
+	})
+	// Not traced: the body tracing writer cannot hand the connection over to the WebSocket
+	route("GET /ws", handlers.TaskEvents, openapi.Operation{
+		Summary: "WebSocket pushing task.created, task.completed, task.uncompleted and task.deleted events", Tags: []string{"tasks"}, OperationID: "taskEvents",
+		Description: "Upgrade to a WebSocket that receives one JSON message per event. The first message is {\"type\":\"ready\"}, " +
+			"idle connections get {\"type\":\"heartbeat\"}, and a client that falls behind gets {\"type\":\"resync\"} and is " +
+			"disconnected; it should catch up with GET /tasks/changes and reconnect.",
+		Parameters: []openapi.Parameter{query("events", "Comma-separated event types to receive; all when absent")},
+		Responses: map[string]openapi.Response{
+			"101": {Description: "Switching to the WebSocket protocol; messages are BusEvent", Content: map[string]openapi.MediaType{
+				"application/json": {Schema: api.SchemaOf(store.BusEvent{})}}},
+			"400": problemResponse("Unknown event type"),
+			"426": problemResponse("Not a WebSocket upgrade request"),
+		},
+	})
+	traced("GET /tasks/trash", handlers.GetTrash, openapi.Operation{
+		Summary: "List deleted tasks that can still be restored", Tags: []string{"trash"}, OperationID: "listTrash",
+		Parameters: []openapi.Parameter{apiVersionParam(), limitParam(), offsetParam()},
+		Responses:  map[string]openapi.Response{"200": api.Returns("Deleted tasks", []store.Task{}), "400": invalidPage()},
+	})
+	traced("GET /tasks/export", handlers.ExportTasks, openapi.Operation{
+		Summary: "Export tasks as a markdown checklist", Tags: []string{"import/export"}, OperationID: "exportTasks",
+		Parameters: []openapi.Parameter{
+			query("format", "markdown"),
+			query("list", "Only export the list with this ID"),
+		},
+		Responses: map[string]openapi.Response{"200": {
+			Description: "Markdown checklist",
+			Content:     map[string]openapi.MediaType{"text/markdown": {Schema: openapi.String()}},
+		}},
+	})
+	traced("GET /tasks/{id}", handlers.GetTask, openapi.Operation{
+		Summary: "Get a task", Description: "Send Accept: text/html for an HTML page with the description rendered.",
+		Tags: []string{"tasks"}, OperationID: "getTask",
+		Parameters: []openapi.Parameter{taskRef, {Name: "If-None-Match", In: "header", Schema: openapi.String(),
+			Description: "ETag of the copy the client has; 304 while the task is unchanged"}},
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Task, with its version in ETag", store.Task{}),
+			"304": {Description: "Not modified since the ETag in If-None-Match"},
+			"404": notFound,
+			"410": problemResponse("The task was deleted and purged"),
+		},
+	})
+	traced("PATCH /tasks/{id}", handlers.UpdateTask, openapi.Operation{
+		Summary: "Update a task", Description: "Only the fields present in the body change. With If-Match, " +
+			"the update only applies if the task is still at that ETag, so two clients cannot overwrite each other.",
+		Tags: []string{"tasks"}, OperationID: "updateTask",
+		Parameters: []openapi.Parameter{taskRef, {Name: "If-Match", In: "header", Schema: openapi.String(),
+			Description: "ETag of the version the update was made against"}},
+		RequestBody: api.Body(store.TaskUpdate{}),
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Updated task, with its new version in ETag", store.Task{}),
+			"404": notFound,
+			"409": conflict,
+			"412": problemResponse("The task changed since the version in If-Match"),
+			"422": problemResponse("Unknown list"),
+		},
+	})
+	traced("DELETE /tasks/{id}", handlers.DeleteTask, openapi.Operation{
+		Summary: "Move a task to the trash", Tags: []string{"tasks"}, OperationID: "deleteTask", Parameters: []openapi.Parameter{taskRef},
+		Responses: map[string]openapi.Response{"204": {Description: "Deleted"}, "404": notFound, "409": conflict},
+	})
+	traced("POST /tasks/{id}/complete", handlers.CompleteTask, openapi.Operation{
+		Summary: "Mark a task complete", Tags: []string{"tasks"}, OperationID: "completeTask", Parameters: []openapi.Parameter{taskRef},
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Completed task", store.Task{}),
+			"404": notFound,
+			"409": problemResponse("The task is blocked by incomplete tasks, or another user holds a claim on it"),
+		},
+	})
+	traced("POST /tasks/{id}/uncomplete", handlers.UncompleteTask, openapi.Operation{
+		Summary: "Mark a task not complete", Tags: []string{"tasks"}, OperationID: "uncompleteTask", Parameters: []openapi.Parameter{taskRef},
+		Responses: map[string]openapi.Response{"200": api.Returns("Reopened task", store.Task{}), "404": notFound, "409": conflict},
+	})
+	traced("POST /tasks/{id}/restore", handlers.RestoreTask, openapi.Operation{
+		Summary: "Take a task out of the trash", Tags: []string{"trash"}, OperationID: "restoreTask", Parameters: []openapi.Parameter{taskRef},
+		Responses: map[string]openapi.Response{"200": api.Returns("Restored task", store.Task{}), "404": notFound},
+	})
+	traced("POST /tasks/{id}/move", handlers.MoveTask, openapi.Operation{
+		Summary: "Move a task in the manual order", Tags: []string{"tasks"}, OperationID: "moveTask", Parameters: []openapi.Parameter{taskRef},
+		RequestBody: api.Body(store.TaskMove{}),
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Moved task", store.Task{}),
+			"404": notFound,
+			"422": problemResponse("Position out of range"),
+		},
+	})
+	traced("GET /tasks/{id}/history", handlers.GetTaskHistory, openapi.Operation{
+		Summary: "Audit trail of a task", Tags: []string{"tasks"}, OperationID: "getTaskHistory", Parameters: []openapi.Parameter{taskRef, apiVersionParam(), limitParam(), offsetParam()},
+		Responses: map[string]openapi.Response{"200": api.Returns("Events, oldest first", []store.TaskEvent{}), "404": notFound, "400": invalidPage()},
+	})
+	traced("POST /tasks/{id}/claim", handlers.ClaimTask, openapi.Operation{
+		Summary: "Claim a task for the requesting user", Tags: []string{"claims"}, OperationID: "claimTask",
+		Parameters:  []openapi.Parameter{taskRef, userHeader()},
+		RequestBody: &openapi.RequestBody{Content: api.JSON(store.TaskClaim{})},
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Claimed task", store.Task{}),
+			"400": problemResponse("Invalid ttl_seconds"),
+			"404": notFound,
+			"409": problemResponse("Another user holds the claim"),
+		},
+	})
+	traced("DELETE /tasks/{id}/claim", handlers.ClaimTask, openapi.Operation{
+		Summary: "Release your claim on a task", Tags: []string{"claims"}, OperationID: "releaseTask",
+		Parameters: []openapi.Parameter{taskRef, userHeader()},
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Released task", store.Task{}),
+			"404": notFound,
+			"409": problemResponse("Another user holds the claim"),
+		},
+	})
+	traced("GET /tasks/{id}/dependencies", handlers.TaskDependencies, openapi.Operation{
+		Summary: "Tasks blocking this task and tasks it blocks", Tags: []string{"dependencies"}, OperationID: "getTaskDependencies",
+		Parameters: []openapi.Parameter{taskRef},
+		Responses:  map[string]openapi.Response{"200": api.Returns("Dependencies", store.TaskDependencies{}), "404": notFound},
+	})
+	traced("POST /tasks/{id}/dependencies", handlers.TaskDependencies, openapi.Operation{
+		Summary: "Declare the task blocked by another", Tags: []string{"dependencies"}, OperationID: "addTaskDependency",
+		Parameters: []openapi.Parameter{taskRef},
+		RequestBody: api.Body(&openapi.Schema{Type: "object", Properties: map[string]*openapi.Schema{
+			"blocked_by": {Description: "Numeric ID or UUID of the blocking task"},
+		}}),
+		Responses: map[string]openapi.Response{
+			"201": api.Returns("Task with its updated blockers", store.Task{}),
+			"404": notFound,
+			"409": problemResponse("The dependency would create a cycle"),
+			"422": problemResponse("Unknown blocker, or the task itself"),
+		},
+	})
+	traced("DELETE /tasks/{id}/dependencies/{blocker}", handlers.TaskDependencies, openapi.Operation{
+		Summary: "Remove a blocked-by relationship", Tags: []string{"dependencies"}, OperationID: "removeTaskDependency",
+		Parameters: []openapi.Parameter{taskRef},
+		Responses:  map[string]openapi.Response{"204": {Description: "Removed"}, "404": problemResponse("Task or dependency not found")},
+	})
+
+	traced("GET /tasks/{id}/links", handlers.TaskLinks, openapi.Operation{
+		Summary: "Tasks linked to this task, by link type", Tags: []string{"links"}, OperationID: "getTaskLinks",
+		Parameters: []openapi.Parameter{taskRef},
+		Responses:  map[string]openapi.Response{"200": api.Returns("Links", store.TaskLinks{}), "404": notFound},
+	})
+	traced("POST /tasks/{id}/links", handlers.TaskLinks, openapi.Operation{
+		Summary: "Link the task to another", Tags: []string{"links"}, OperationID: "addTaskLink",
+		Parameters: []openapi.Parameter{taskRef},
+		RequestBody: api.Body(&openapi.Schema{Type: "object", Properties: map[string]*openapi.Schema{
+			"type": {Type: "string", Description: "relates_to (default), duplicates or duplicated_by"},
+			"task": {Description: "Numeric ID or UUID of the linked task"},
+		}}),
+		Responses: map[string]openapi.Response{
+			"201": api.Returns("Task with its updated links", store.Task{}),
+			"400": problemResponse("Invalid body or link type"),
+			"404": notFound,
+			"422": problemResponse("Unknown linked task, or the task itself"),
+		},
+	})
+	traced("DELETE /tasks/{id}/links/{linked}", handlers.TaskLinks, openapi.Operation{
+		Summary: "Remove the links between two tasks", Tags: []string{"links"}, OperationID: "removeTaskLink",
+		Parameters: []openapi.Parameter{taskRef,
+			{Name: "type", In: "query", Schema: openapi.String(), Description: "Only remove links of this type"}},
+		Responses: map[string]openapi.Response{
+			"204": {Description: "Removed"},
+			"400": problemResponse("Invalid link type"),
+			"404": problemResponse("Task or link not found"),
+		},
+	})
+
+	// Attachments are routed without body tracing, which would copy every file into a span
+	attachmentID := openapi.Parameter{Name: "attachment", In: "path", Required: true, Schema: openapi.Integer()}
+	attachmentsUnavailable := problemResponse("Attachment storage is not configured")
+	route("GET /tasks/{id}/attachments", handlers.TaskAttachments, openapi.Operation{
+		Summary: "Files attached to the task", Tags: []string{"attachments"}, OperationID: "getTaskAttachments",
+		Parameters: []openapi.Parameter{taskRef, apiVersionParam(), limitParam(), offsetParam()},
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Attachments, oldest first", []store.Attachment{}),
+			"400": invalidPage(),
+			"404": notFound,
+			"501": attachmentsUnavailable,
+		},
+	})
+	route("POST /tasks/{id}/attachments", handlers.TaskAttachments, openapi.Operation{
+		Summary: "Attach a file to the task", Tags: []string{"attachments"}, OperationID: "uploadTaskAttachment",
+		Description: "The body is the file itself, of at most TODO_MAX_ATTACHMENT_SIZE bytes, stored with its Content-Type " +
+			"(application/octet-stream when absent) in the storage selected by TODO_ATTACHMENT_STORAGE.",
+		Parameters: []openapi.Parameter{taskRef, userHeader(), {Name: "name", In: "query", Required: true, Schema: openapi.String(),
+			Description: "File name the attachment is downloaded as"}},
+		RequestBody: &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+			"application/octet-stream": {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+		}},
+		Responses: map[string]openapi.Response{
+			"201": api.Returns("Attachment", store.Attachment{}),
+			"400": problemResponse("Missing or invalid name or Content-Type"),
+			"403": problemResponse("The file would go over the workspace's attachment quota"),
+			"404": notFound,
+			"413": problemResponse("The file is larger than TODO_MAX_ATTACHMENT_SIZE"),
+			"501": attachmentsUnavailable,
+		},
+	})
+	route("GET /tasks/{id}/attachments/{attachment}", handlers.TaskAttachment, openapi.Operation{
+		Summary: "Describe an attachment", Tags: []string{"attachments"}, OperationID: "getTaskAttachment",
+		Parameters: []openapi.Parameter{taskRef, attachmentID},
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Attachment", store.Attachment{}),
+			"404": problemResponse("Task or attachment not found"),
+			"501": attachmentsUnavailable,
+		},
+	})
+	route("GET /tasks/{id}/attachments/{attachment}/download", handlers.TaskAttachment, openapi.Operation{
+		Summary: "Download an attachment", Tags: []string{"attachments"}, OperationID: "downloadTaskAttachment",
+		Description: "With s3 or gcs storage, redirects to a URL signed for TODO_ATTACHMENT_URL_TTL that downloads the file " +
+			"from the bucket; with local storage, answers with the file.",
+		Parameters: []openapi.Parameter{taskRef, attachmentID},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "The file, as an attachment with its name and Content-Type",
+				Content: map[string]openapi.MediaType{"application/octet-stream": {Schema: &openapi.Schema{Type: "string", Format: "binary"}}}},
+			"302": {Description: "Signed URL of the file in the bucket, in Location"},
+			"404": problemResponse("Task or attachment not found"),
+			"501": attachmentsUnavailable,
+		},
+	})
+	route("DELETE /tasks/{id}/attachments/{attachment}", handlers.TaskAttachment, openapi.Operation{
+		Summary: "Delete an attachment and its file", Tags: []string{"attachments"}, OperationID: "deleteTaskAttachment",
+		Parameters: []openapi.Parameter{taskRef, attachmentID},
+		Responses: map[string]openapi.Response{
+			"204": {Description: "Deleted"},
+			"404": problemResponse("Task or attachment not found"),
+			"501": attachmentsUnavailable,
+		},
+	})
+
+	uploadID := openapi.Parameter{Name: "upload", In: "path", Required: true, Schema: openapi.String()}
+	uploadsUnavailable := problemResponse("Attachment storage or upload staging is not configured")
+	route("POST /tasks/{id}/uploads", handlers.TaskUploads, openapi.Operation{
+		Summary: "Start a chunked upload of a file to attach", Tags: []string{"attachments"}, OperationID: "createTaskUpload",
+		Description: "For files too large or connections too flaky for one request. Send the file in order with PATCH; " +
+			"an upload without a chunk for TODO_UPLOAD_TTL is discarded.",
+		Parameters: []openapi.Parameter{taskRef, userHeader()},
+		RequestBody: api.Body(&openapi.Schema{Type: "object", Required: []string{"name", "size"}, Properties: map[string]*openapi.Schema{
+			"name":         {Type: "string", Description: "File name the attachment is downloaded as"},
+			"content_type": {Type: "string", Description: "Media type of the file; application/octet-stream by default"},
+			"size":         {Type: "integer", Description: "Size of the whole file in bytes, at most TODO_MAX_UPLOAD_SIZE"},
+		}}),
+		Responses: map[string]openapi.Response{
+			"201": api.Returns("Upload, at offset 0; Location is where to send its chunks", store.Upload{}),
+			"400": problemResponse("Missing or invalid name, content_type or size"),
+			"403": problemResponse("The file would go over the workspace's attachment quota"),
+			"404": notFound,
+			"413": problemResponse("The file is larger than TODO_MAX_UPLOAD_SIZE"),
+			"501": uploadsUnavailable,
+		},
+	})
+	route("GET /tasks/{id}/uploads/{upload}", handlers.TaskUpload, openapi.Operation{
+		Summary: "Where an upload is, to resume it", Tags: []string{"attachments"}, OperationID: "getTaskUpload",
+		Description: "HEAD answers with only the Upload-Offset and Upload-Length headers.",
+		Parameters:  []openapi.Parameter{taskRef, uploadID},
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Upload, with its offset and size also in Upload-Offset and Upload-Length", store.Upload{}),
+			"404": problemResponse("Task or upload not found, or the upload expired"),
+			"501": uploadsUnavailable,
+		},
+	})
+	route("PATCH /tasks/{id}/uploads/{upload}", handlers.TaskUpload, openapi.Operation{
+		Summary: "Send the next chunk of an upload", Tags: []string{"attachments"}, OperationID: "uploadTaskChunk",
+		Description: "The body is written at Upload-Offset, which must be the upload's offset. If the connection breaks, " +
+			"what arrived is kept: GET the upload for its offset and continue from there. The chunk that completes the file " +
+			"answers 201 with the attachment; if storing it failed, a PATCH with an empty body at the end retries.",
+		Parameters: []openapi.Parameter{taskRef, uploadID, {Name: uploadOffsetHeader, In: "header", Required: true,
+			Schema: openapi.Integer(), Description: "Offset of the chunk in the file"}},
+		RequestBody: &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+			"application/offset+octet-stream": {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+		}},
+		Responses: map[string]openapi.Response{
+			"201": api.Returns("Attachment made from the completed upload", store.Attachment{}),
+			"204": {Description: "Chunk stored; the Upload-Offset response header is the new offset"},
+			"400": problemResponse("Missing or invalid Upload-Offset"),
+			"403": problemResponse("The file would go over the workspace's attachment quota"),
+			"404": problemResponse("Task or upload not found, or the upload expired"),
+			"409": problemResponse("Upload-Offset is not the upload's offset, which is in the Upload-Offset response header"),
+			"413": problemResponse("The chunk goes past the size given when the upload started"),
+			"501": uploadsUnavailable,
+		},
+	})
+	route("DELETE /tasks/{id}/uploads/{upload}", handlers.TaskUpload, openapi.Operation{
+		Summary: "Cancel an upload", Tags: []string{"attachments"}, OperationID: "cancelTaskUpload",
+		Parameters: []openapi.Parameter{taskRef, uploadID},
+		Responses: map[string]openapi.Response{
+			"204": {Description: "Canceled; what was received is discarded"},
+			"404": problemResponse("Task or upload not found"),
+			"501": uploadsUnavailable,
+		},
+	})
+
+	traced("POST /import/markdown", handlers.ImportMarkdown, openapi.Operation{
+		Summary: "Create tasks from a markdown checklist", Tags: []string{"import/export"}, OperationID: "importMarkdown",
+		Parameters: []openapi.Parameter{query("list", "List to import into; the default list otherwise")},
+		RequestBody: &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+			"text/markdown": {Schema: openapi.String()},
+		}},
+		Responses: map[string]openapi.Response{
+			"201": api.Returns("What was created", store.ImportSummary{}),
+			"400": problemResponse("Invalid checklist"),
+			"403": problemResponse("The import would go over the workspace's open task quota"),
+		},
+	})
+
+	listID := openapi.Parameter{Name: "id", In: "path", Required: true, Schema: openapi.Integer()}
+	listName := struct {
+		Name string `json:"name"`
+	}{}
+	traced("GET /lists", handlers.Lists, openapi.Operation{
+		Summary: "List all lists with task counts", Tags: []string{"lists"}, OperationID: "listLists",
+		Parameters: []openapi.Parameter{apiVersionParam(), limitParam(), offsetParam()},
+		Responses:  map[string]openapi.Response{"200": api.Returns("Lists", []store.List{}), "400": invalidPage()},
+	})
+	traced("POST /lists", handlers.Lists, openapi.Operation{
+		Summary: "Create a list", Tags: []string{"lists"}, OperationID: "createList", RequestBody: api.Body(listName),
+		Responses: map[string]openapi.Response{"201": api.Returns("Created list", store.List{}), "409": problemResponse("Name taken")},
+	})
+	traced("GET /lists/{id}", handlers.List, openapi.Operation{
+		Summary: "Get a list", Tags: []string{"lists"}, OperationID: "getList", Parameters: []openapi.Parameter{listID},
+		Responses: map[string]openapi.Response{"200": api.Returns("List", store.List{}), "404": problemResponse("List not found")},
+	})
+	traced("PATCH /lists/{id}", handlers.List, openapi.Operation{
+		Summary: "Rename a list", Tags: []string{"lists"}, OperationID: "renameList", Parameters: []openapi.Parameter{listID},
+		RequestBody: api.Body(listName),
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Renamed list", store.List{}),
+			"404": problemResponse("List not found"),
+			"409": problemResponse("Name taken"),
+		},
+	})
+	traced("DELETE /lists/{id}", handlers.List, openapi.Operation{
+		Summary: "Delete a list, moving its tasks to the default list", Tags: []string{"lists"}, OperationID: "deleteList",
+		Parameters: []openapi.Parameter{listID},
+		Responses: map[string]openapi.Response{
+			"204": {Description: "Deleted"},
+			"404": problemResponse("List not found"),
+			"409": problemResponse("The default list cannot be deleted"),
+		},
+	})
+	traced("GET /lists/{id}/settings", handlers.ListSettings, openapi.Operation{
+		Summary: "Get the defaults applied to new tasks in a list", Tags: []string{"lists"}, OperationID: "getListSettings",
+		Parameters: []openapi.Parameter{listID},
+		Responses:  map[string]openapi.Response{"200": api.Returns("Settings", store.ListSettings{}), "404": problemResponse("List not found")},
+	})
+	traced("PUT /lists/{id}/settings", handlers.ListSettings, openapi.Operation{
+		Summary: "Replace the defaults applied to new tasks in a list", Tags: []string{"lists"}, OperationID: "putListSettings",
+		Parameters:  []openapi.Parameter{listID},
+		RequestBody: api.Body(store.ListSettings{}),
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Saved settings", store.ListSettings{}),
+			"400": problemResponse("Invalid settings"),
+			"404": problemResponse("List not found"),
+		},
+	})
+
+	workspaceID := openapi.Parameter{Name: "id", In: "path", Required: true, Schema: openapi.Integer()}
+	memberUser := openapi.Parameter{Name: "user", In: "path", Required: true, Schema: openapi.String()}
+	traced("GET /workspaces", handlers.Workspaces, openapi.Operation{
+		Summary: "List all workspaces with list counts", Tags: []string{"workspaces"}, OperationID: "listWorkspaces",
+		Parameters: []openapi.Parameter{apiVersionParam(), limitParam(), offsetParam()},
+		Responses:  map[string]openapi.Response{"200": api.Returns("Workspaces", []store.Workspace{}), "400": invalidPage()},
+	})
+	traced("POST /workspaces", handlers.Workspaces, openapi.Operation{
+		Summary: "Create a workspace owned by the requesting user", Tags: []string{"workspaces"}, OperationID: "createWorkspace",
+		RequestBody: api.Body(listName),
+		Responses:   map[string]openapi.Response{"201": api.Returns("Created workspace", store.Workspace{}), "409": problemResponse("Name taken")},
+	})
+	traced("GET /workspaces/{id}", handlers.Workspace, openapi.Operation{
+		Summary: "Get a workspace", Tags: []string{"workspaces"}, OperationID: "getWorkspace", Parameters: []openapi.Parameter{workspaceID},
+		Responses: map[string]openapi.Response{"200": api.Returns("Workspace", store.Workspace{}), "404": problemResponse("Workspace not found")},
+	})
+	traced("PATCH /workspaces/{id}", handlers.Workspace, openapi.Operation{
+		Summary: "Rename a workspace", Tags: []string{"workspaces"}, OperationID: "renameWorkspace", Parameters: []openapi.Parameter{workspaceID},
+		RequestBody: api.Body(listName),
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Renamed workspace", store.Workspace{}),
+			"404": problemResponse("Workspace not found"),
+			"409": problemResponse("Name taken"),
+		},
+	})
+	traced("DELETE /workspaces/{id}", handlers.Workspace, openapi.Operation{
+		Summary: "Delete an empty workspace", Tags: []string{"workspaces"}, OperationID: "deleteWorkspace",
+		Parameters: []openapi.Parameter{workspaceID},
+		Responses: map[string]openapi.Response{
+			"204": {Description: "Deleted"},
+			"404": problemResponse("Workspace not found"),
+			"409": problemResponse("The default workspace, or one that still has lists, cannot be deleted"),
+		},
+	})
+	traced("GET /workspaces/{id}/lists", handlers.WorkspaceLists, openapi.Operation{
+		Summary: "List the lists in a workspace", Tags: []string{"workspaces"}, OperationID: "listWorkspaceLists",
+		Parameters: []openapi.Parameter{workspaceID, apiVersionParam(), limitParam(), offsetParam()},
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Lists", []store.List{}),
+			"400": invalidPage(),
+			"404": problemResponse("Workspace not found"),
+		},
+	})
+	traced("POST /workspaces/{id}/lists", handlers.WorkspaceLists, openapi.Operation{
+		Summary: "Create a list in a workspace", Tags: []string{"workspaces"}, OperationID: "createWorkspaceList",
+		Parameters: []openapi.Parameter{workspaceID}, RequestBody: api.Body(listName),
+		Responses: map[string]openapi.Response{
+			"201": api.Returns("Created list", store.List{}),
+			"404": problemResponse("Workspace not found"),
+			"409": problemResponse("Name taken"),
+		},
+	})
+	traced("GET /workspaces/{id}/members", handlers.WorkspaceMembers, openapi.Operation{
+		Summary: "List the members of a workspace", Tags: []string{"workspaces"}, OperationID: "listWorkspaceMembers",
+		Parameters: []openapi.Parameter{workspaceID, apiVersionParam(), limitParam(), offsetParam()},
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Members", []store.WorkspaceMember{}),
+			"400": invalidPage(),
+			"404": problemResponse("Workspace not found"),
+		},
+	})
+	traced("PUT /workspaces/{id}/members/{user}", handlers.WorkspaceMember, openapi.Operation{
+		Summary: "Add a member to a workspace or change their role", Tags: []string{"workspaces"}, OperationID: "putWorkspaceMember",
+		Parameters: []openapi.Parameter{workspaceID, memberUser},
+		RequestBody: api.Body(struct {
+			Role string `json:"role"`
+		}{}),
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Member", store.WorkspaceMember{}),
+			"400": problemResponse("Invalid role"),
+			"404": problemResponse("Workspace not found"),
+		},
+	})
+	traced("DELETE /workspaces/{id}/members/{user}", handlers.WorkspaceMember, openapi.Operation{
+		Summary: "Remove a member from a workspace", Tags: []string{"workspaces"}, OperationID: "deleteWorkspaceMember",
+		Parameters: []openapi.Parameter{workspaceID, memberUser},
+		Responses:  map[string]openapi.Response{"204": {Description: "Removed"}, "404": problemResponse("Workspace or member not found")},
+	})
+	traced("GET /workspaces/{id}/settings", handlers.WorkspaceSettings, openapi.Operation{
+		Summary: "Get the defaults applied to new tasks in a workspace's lists", Tags: []string{"workspaces"}, OperationID: "getWorkspaceSettings",
+		Parameters: []openapi.Parameter{workspaceID},
+		Responses:  map[string]openapi.Response{"200": api.Returns("Settings", store.WorkspaceSettings{}), "404": problemResponse("Workspace not found")},
+	})
+	traced("PUT /workspaces/{id}/settings", handlers.WorkspaceSettings, openapi.Operation{
+		Summary: "Replace the defaults applied to new tasks in a workspace's lists", Tags: []string{"workspaces"}, OperationID: "putWorkspaceSettings",
+		Parameters:  []openapi.Parameter{workspaceID},
+		RequestBody: api.Body(store.WorkspaceSettings{}),
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Saved settings", store.WorkspaceSettings{}),
+			"400": problemResponse("Invalid settings"),
+			"404": problemResponse("Workspace not found"),
+		},
+	})
+
+	route("GET /notifiers", handlers.GetNotifiers, openapi.Operation{
+		Summary: "Notifiers and events available to notification rules", Tags: []string{"notifications"}, OperationID: "listNotifiers",
+		Responses: map[string]openapi.Response{"200": api.Returns("Notifiers and events", struct {
+			Notifiers []string `json:"notifiers"`
+			Events    []string `json:"events"`
+		}{})},
+	})
+	traced("GET /notification-rules", handlers.NotificationRules, openapi.Operation{
+		Summary: "List the requesting user's notification rules", Tags: []string{"notifications"}, OperationID: "listNotificationRules",
+		Parameters: []openapi.Parameter{userHeader(), apiVersionParam(), limitParam(), offsetParam()},
+		Responses:  map[string]openapi.Response{"200": api.Returns("Rules", []store.NotificationRule{}), "400": invalidPage()},
+	})
+	traced("POST /notification-rules", handlers.NotificationRules, openapi.Operation{
+		Summary: "Create a notification rule", Tags: []string{"notifications"}, OperationID: "createNotificationRule",
+		Parameters:  []openapi.Parameter{userHeader()},
+		RequestBody: api.Body(store.NotificationRule{}),
+		Responses: map[string]openapi.Response{
+			"201": api.Returns("Created rule", store.NotificationRule{}),
+			"400": problemResponse("Invalid event, notifier or target"),
+			"422": problemResponse("Unknown list"),
+		},
+	})
+	route("DELETE /notification-rules/{id}", handlers.NotificationRule, openapi.Operation{
+		Summary: "Delete a notification rule", Tags: []string{"notifications"}, OperationID: "deleteNotificationRule",
+		Parameters: []openapi.Parameter{{Name: "id", In: "path", Required: true, Schema: openapi.Integer()}, userHeader()},
+		Responses:  map[string]openapi.Response{"204": {Description: "Deleted"}, "404": problemResponse("Rule not found")},
+	})
+	traced("GET /notification-settings", handlers.NotificationSettings, openapi.Operation{
+		Summary: "Get the requesting user's quiet hours and batch window", Tags: []string{"notifications"}, OperationID: "getNotificationSettings",
+		Parameters: []openapi.Parameter{userHeader()},
+		Responses:  map[string]openapi.Response{"200": api.Returns("Settings", store.NotificationSettings{})},
+	})
+	traced("PUT /notification-settings", handlers.NotificationSettings, openapi.Operation{
+		Summary: "Replace the requesting user's quiet hours and batch window", Tags: []string{"notifications"}, OperationID: "putNotificationSettings",
+		Parameters:  []openapi.Parameter{userHeader()},
+		RequestBody: api.Body(store.NotificationSettings{}),
+		Responses:   map[string]openapi.Response{"200": api.Returns("Saved settings", store.NotificationSettings{}), "400": problemResponse("Invalid settings")},
+	})
+	route("GET /me/stats", handlers.GetUserStats, openapi.Operation{
+		Summary: "Get the requesting user's completion totals and streak", Tags: []string{"users"}, OperationID: "getUserStats",
+		Parameters: []openapi.Parameter{userHeader()},
+		Responses:  map[string]openapi.Response{"200": api.Returns("Stats", store.UserStats{})},
+	})
+	route("GET /me/usage", handlers.GetUsage, openapi.Operation{
+		Summary: "Get the requesting user's usage of each quota", Tags: []string{"users"}, OperationID: "getUsage",
+		Parameters: []openapi.Parameter{userHeader()},
+		Responses:  map[string]openapi.Response{"200": api.Returns("Usage", store.Usage{})},
+	})
+	route("GET /stats/heatmap", handlers.GetHeatmap, openapi.Operation{
+		Summary: "Count the task completions of every day of a year", Tags: []string{"users"}, OperationID: "getHeatmap",
+		Parameters: []openapi.Parameter{
+			{Name: "year", In: "query", Schema: openapi.Integer(), Description: "Year to count, by default the current one in tz"},
+			query("tz", "IANA time zone the days are in, by default that of the requesting user's notification settings"),
+			userHeader(),
+		},
+		Responses: map[string]openapi.Response{"200": api.Returns("Completions per day", store.Heatmap{}), "400": problemResponse("Invalid year or unknown time zone")},
+	})
+
+	route("GET /review", handlers.GetReview, openapi.Operation{
+		Summary: "Get the requesting user's weekly review queue", Tags: []string{"review"}, OperationID: "getReview",
+		Parameters: []openapi.Parameter{
+			userHeader(), apiVersionParam(), offsetParam(),
+			{Name: "limit", In: "query", Description: "At most this many, 50 by default", Schema: openapi.Integer()},
+		},
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Open tasks needing attention with the reasons, overdue first, then stale, without a due date and unprioritized", []store.ReviewItem{}),
+			"400": invalidPage(),
+		},
+	})
+	traced("POST /review/{id}/decision", handlers.ReviewDecision, openapi.Operation{
+		Summary: "Keep, defer or delete a task in the review", Tags: []string{"review"}, OperationID: "decideReview",
+		Parameters: []openapi.Parameter{taskRef, userHeader()},
+		RequestBody: api.Body(&openapi.Schema{Type: "object", Properties: map[string]*openapi.Schema{
+			"decision": {Type: "string", Description: "keep, defer or delete"},
+			"until":    {Type: "string", Format: "date-time", Description: "For defer, when the task comes back; TODO_REVIEW_INTERVAL (a week) from now by default"},
+		}}),
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("The decision, with the task unless deleted", store.ReviewDecision{}),
+			"400": problemResponse("Invalid decision or until"),
+			"404": problemResponse("Task not found or already completed"),
+			"409": problemResponse("Another user holds a claim on the task"),
+		},
+	})
+
+	webhookID := openapi.Parameter{Name: "id", In: "path", Required: true, Schema: openapi.Integer()}
+	route("GET /webhooks", handlers.Webhooks, openapi.Operation{
+		Summary: "List the requesting user's webhooks", Tags: []string{"webhooks"}, OperationID: "listWebhooks",
+		Parameters: []openapi.Parameter{userHeader(), apiVersionParam(), limitParam(), offsetParam()},
+		Responses:  map[string]openapi.Response{"200": api.Returns("Webhooks, without their secrets", []store.Webhook{}), "400": invalidPage()},
+	})
+	// Not traced: the body carries the secret
+	route("POST /webhooks", handlers.Webhooks, openapi.Operation{
+		Summary: "Register a webhook", Tags: []string{"webhooks"}, OperationID: "createWebhook",
+		Description: "Task events in events (all of them when empty) are POSTed to url, signed with the secret in " +
+			"X-Webhook-Signature. A secret is generated when none is given; it is returned only in this response.",
+		Parameters:  []openapi.Parameter{userHeader()},
+		RequestBody: api.Body(store.Webhook{}),
+		Responses: map[string]openapi.Response{
+			"201": api.Returns("Created webhook with its secret", store.Webhook{}),
+			"400": problemResponse("Invalid URL, event or secret"),
+			"403": problemResponse("The user is at their webhook quota"),
+		},
+	})
+	route("DELETE /webhooks/{id}", handlers.Webhook, openapi.Operation{
+		Summary: "Delete a webhook and its deliveries", Tags: []string{"webhooks"}, OperationID: "deleteWebhook",
+		Parameters: []openapi.Parameter{webhookID, userHeader()},
+		Responses:  map[string]openapi.Response{"204": {Description: "Deleted"}, "404": problemResponse("Webhook not found")},
+	})
+	route("GET /webhooks/{id}/deliveries", handlers.WebhookDeliveries, openapi.Operation{
+		Summary: "A webhook's most recent deliveries", Tags: []string{"webhooks"}, OperationID: "listWebhookDeliveries",
+		Parameters: []openapi.Parameter{webhookID, userHeader(), apiVersionParam(), limitParam(), offsetParam()},
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Deliveries, newest first", []store.WebhookDelivery{}),
+			"400": invalidPage(),
+			"404": problemResponse("Webhook not found"),
+		},
+	})
+	// Not traced: payloads from other systems may carry anything
+	route("POST /hooks/{provider}", handlers.Hook, openapi.Operation{
+		Summary: "Receive an event from an external system", Tags: []string{"hooks"}, OperationID: "receiveHook",
+		Description: "github creates a task for each opened issue (X-GitHub-Event: issues) and completes or reopens it with " +
+			"the issue. test takes task.create, task.complete and task.reopen events signed in Test-Signature. The payload " +
+			"is checked against the provider's schema for the event; other events are acknowledged and ignored, and a " +
+			"delivery ID already received is acknowledged without applying it again.",
+		Parameters: []openapi.Parameter{{Name: "provider", In: "path", Required: true, Schema: openapi.String(),
+			Description: "github or test"}},
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("What the delivery did", HookResponse{}),
+			"400": problemResponse("Invalid JSON or missing event headers"),
+			"401": problemResponse("Invalid signature"),
+			"404": problemResponse("Provider unknown or not configured"),
+			"413": problemResponse("Payload too large"),
+			"422": problemResponse("Payload does not match the event's schema"),
+		},
+	})
+
+	snapshotID := openapi.Parameter{Name: "id", In: "path", Required: true, Schema: openapi.Integer()}
+	snapshotNotFound := problemResponse("Snapshot not found")
+	route("GET /snapshots", handlers.Snapshots, openapi.Operation{
+		Summary: "List snapshots", Tags: []string{"snapshots"}, OperationID: "listSnapshots",
+		Parameters: []openapi.Parameter{apiVersionParam(), limitParam(), offsetParam()},
+		Responses:  map[string]openapi.Response{"200": api.Returns("Snapshots", []store.Snapshot{}), "400": invalidPage()},
+	})
+	route("POST /snapshots", handlers.Snapshots, openapi.Operation{
+		Summary: "Save a named snapshot of all tasks", Tags: []string{"snapshots"}, OperationID: "createSnapshot",
+		RequestBody: api.Body(struct {
+			Name string `json:"name"`
+		}{}),
+		Responses: map[string]openapi.Response{"201": api.Returns("Created snapshot", store.Snapshot{}), "409": problemResponse("Name taken")},
+	})
+	route("DELETE /snapshots/{id}", handlers.Snapshot, openapi.Operation{
+		Summary: "Delete a snapshot", Tags: []string{"snapshots"}, OperationID: "deleteSnapshot", Parameters: []openapi.Parameter{snapshotID},
+		Responses: map[string]openapi.Response{"204": {Description: "Deleted"}, "404": snapshotNotFound},
+	})
+	route("GET /snapshots/{id}/diff", handlers.Snapshot, openapi.Operation{
+		Summary: "Tasks added, removed and changed since the snapshot", Tags: []string{"snapshots"}, OperationID: "diffSnapshot",
+		Parameters: []openapi.Parameter{snapshotID},
+		Responses:  map[string]openapi.Response{"200": api.Returns("Differences", store.SnapshotDiff{}), "404": snapshotNotFound},
+	})
+	route("POST /snapshots/{id}/restore", handlers.Snapshot, openapi.Operation{
+		Summary: "Make the task list match the snapshot again", Tags: []string{"snapshots"}, OperationID: "restoreSnapshot",
+		Parameters: []openapi.Parameter{snapshotID},
+		Responses:  map[string]openapi.Response{"200": api.Returns("What the restore changed", store.SnapshotDiff{}), "404": snapshotNotFound},
+	})
+
+	admin("GET /admin/slo", handlers.GetSLO, openapi.Operation{
+		Summary: "Rolling per-route success rate and latency percentiles", Tags: []string{"admin"}, OperationID: "getSLO",
+		Responses: map[string]openapi.Response{"200": api.Returns("SLO summary", struct {
+			WindowSeconds int                  `json:"window_seconds"`
+			Routes        []telemetry.RouteSLO `json:"routes"`
+		}{})},
+	})
+	admin("GET /admin/diagnostics", handlers.GetDiagnostics, openapi.Operation{
+		Summary: "Download a redacted diagnostics bundle to attach to a bug report", Tags: []string{"admin"}, OperationID: "getDiagnostics",
+		Description: "A zip with the configuration (secrets redacted), schema version and database statistics, recent warnings and " +
+			"errors, SLOs, runtime statistics, and goroutine and heap profiles.",
+		Parameters: []openapi.Parameter{{Name: "cpu_seconds", In: "query", Description: "Also capture a CPU profile of this many seconds (at most 10)",
+			Schema: openapi.Integer()}},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "Diagnostics bundle", Content: map[string]openapi.MediaType{
+				"application/zip": {Schema: &openapi.Schema{Type: "string", Format: "binary"}}}},
+			"400": problemResponse("Invalid cpu_seconds"),
+		},
+	})
+	admin("GET /admin/cluster", handlers.GetCluster, openapi.Operation{
+		Summary: "List the live replicas and which one is the leader", Tags: []string{"admin"}, OperationID: "getCluster",
+		Responses: map[string]openapi.Response{"200": api.Returns("Cluster status", scheduler.ClusterStatus{})},
+	})
+	deadLetterParams := []openapi.Parameter{
+		{Name: "kind", In: "path", Required: true, Schema: openapi.String(), Description: "webhook or notification"},
+		{Name: "id", In: "path", Required: true, Schema: openapi.Integer()},
+	}
+	deadLetterNotFound := problemResponse("Dead letter not found")
+	admin("GET /admin/dead-letters", handlers.GetDeadLetters, openapi.Operation{
+		Summary: "List failed webhook deliveries and notifications", Tags: []string{"admin"}, OperationID: "listDeadLetters",
+		Parameters: []openapi.Parameter{
+			apiVersionParam(), offsetParam(),
+			query("kind", "Comma-separated kinds to list: webhook, notification"),
+			{Name: "limit", In: "query", Description: "At most this many, 100 by default and 500 at most", Schema: openapi.Integer()},
+		},
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Dead letters, most recently failed first, without payloads", []store.DeadLetter{}),
+			"400": problemResponse("Invalid kind, limit or offset"),
+		},
+	})
+	admin("GET /admin/dead-letters/{kind}/{id}", handlers.DeadLetter, openapi.Operation{
+		Summary: "Inspect a dead letter with its payload", Tags: []string{"admin"}, OperationID: "getDeadLetter",
+		Parameters: deadLetterParams,
+		Responses:  map[string]openapi.Response{"200": api.Returns("Dead letter", store.DeadLetter{}), "404": deadLetterNotFound},
+	})
+	admin("DELETE /admin/dead-letters/{kind}/{id}", handlers.DeadLetter, openapi.Operation{
+		Summary: "Discard a dead letter", Tags: []string{"admin"}, OperationID: "deleteDeadLetter",
+		Parameters: deadLetterParams,
+		Responses:  map[string]openapi.Response{"204": {Description: "Discarded"}, "404": deadLetterNotFound},
+	})
+	admin("POST /admin/dead-letters/{kind}/{id}/replay", handlers.ReplayDeadLetter, openapi.Operation{
+		Summary: "Send a dead letter again", Tags: []string{"admin"}, OperationID: "replayDeadLetter",
+		Description: "A webhook delivery is queued again with a fresh set of attempts. A notification is sent again in the " +
+			"background; it is removed once delivered and stays a dead letter, with the new error, if it fails again.",
+		Parameters: deadLetterParams,
+		Responses: map[string]openapi.Response{
+			"202": {Description: "Replay started"},
+			"404": deadLetterNotFound,
+			"503": problemResponse("Outbound queue full"),
+		},
+	})
+	admin("GET /admin/events", handlers.AdminEvents, openapi.Operation{
+		Summary: "Server-sent event stream of task, audit and security events", Tags: []string{"admin"}, OperationID: "adminEvents",
+		Description: "Task events (task.created, task.completed, task.uncompleted, task.deleted) " +
+			"arrive as they are published, audit events as they are recorded in the task history, and security events as this replica " +
+			"records them. The first event is ready, idle streams get heartbeat comments, and a client that falls behind gets resync " +
+			"and is disconnected. Audit events have their history position as the event ID: reconnecting with Last-Event-ID resumes " +
+			"after it, otherwise the stream starts with new events.",
+		Parameters: []openapi.Parameter{{Name: "Last-Event-ID", In: "header", Description: "ID of the last audit event received",
+			Schema: openapi.Integer()}},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "Event stream; data is a BusEvent for task events and an AuditEvent for audit and security events",
+				Content: map[string]openapi.MediaType{"text/event-stream": {Schema: &openapi.Schema{Type: "string"}}}},
+			"400": problemResponse("Invalid Last-Event-ID"),
+		},
+	})
+	admin("GET /admin/jobs", handlers.GetJobs, openapi.Operation{
+		Summary: "Background jobs with their last and next run on this replica", Tags: []string{"admin"}, OperationID: "listJobs",
+		Parameters: []openapi.Parameter{apiVersionParam(), limitParam(), offsetParam()},
+		Responses:  map[string]openapi.Response{"200": api.Returns("Jobs, in registration order", []scheduler.JobStatus{}), "400": invalidPage()},
+	})
+	admin("POST /admin/jobs/{name}/run", handlers.RunJob, openapi.Operation{
+		Summary: "Run a background job now", Tags: []string{"admin"}, OperationID: "runJob",
+		Description: "Runs the job on this replica and answers once the run is over, with last_outcome saying how it went.",
+		Parameters:  []openapi.Parameter{{Name: "name", In: "path", Required: true, Schema: openapi.String()}},
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Status after the run", scheduler.JobStatus{}),
+			"404": problemResponse("No job by that name"),
+			"409": problemResponse("The job is running already, or only the leader runs it and this replica is not the leader"),
+		},
+	})
+	admin("POST /admin/tags/rename", handlers.RenameTag, openapi.Operation{
+		Summary: "Rename a tag on every task", Tags: []string{"admin"}, OperationID: "renameTag",
+		Description: "Renames the tag on every task, trashed ones included, and in notification rules, in one transaction. " +
+			"Each task changed gets an updated event in its history.",
+		RequestBody: api.Body(struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		}{}),
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("What was renamed", store.TagOperation{}),
+			"400": problemResponse("Invalid tag, or the same tag twice"),
+			"404": problemResponse("No task carries the tag"),
+			"409": problemResponse("A task already carries the new tag; merge them instead"),
+		},
+	})
+	admin("POST /admin/tags/merge", handlers.MergeTags, openapi.Operation{
+		Summary: "Merge a tag into another on every task", Tags: []string{"admin"}, OperationID: "mergeTags",
+		Description: "Replaces the tag with into on every task, trashed ones included, and in notification rules, in one " +
+			"transaction. A task carrying both keeps into once. Each task changed gets an updated event in its history.",
+		RequestBody: api.Body(struct {
+			From string `json:"from"`
+			Into string `json:"into"`
+		}{}),
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("What was merged", store.TagOperation{}),
+			"400": problemResponse("Invalid tag, or the same tag twice"),
+			"404": problemResponse("No task carries the tag"),
+		},
+	})
+	backupsUnavailable := problemResponse("The database is not SQLite, or TODO_STORE is not sql")
+	admin("GET /admin/backups", handlers.Backups, openapi.Operation{
+		Summary: "List database backups", Tags: []string{"admin"}, OperationID: "listBackups",
+		Parameters: []openapi.Parameter{apiVersionParam(), limitParam(), offsetParam()},
+		Responses: map[string]openapi.Response{
+			"200": api.Returns("Backups, newest first", []store.Backup{}),
+			"400": invalidPage(),
+			"501": backupsUnavailable,
+		},
+	})
+	admin("POST /admin/backups", handlers.Backups, openapi.Operation{
+		Summary: "Back up the database", Tags: []string{"admin"}, OperationID: "createBackup",
+		Description: "Writes a consistent copy of the SQLite database to TODO_BACKUP_DIR while the server keeps serving, " +
+			"then removes the oldest backups beyond TODO_BACKUP_KEEP.",
+		Responses: map[string]openapi.Response{
+			"201": api.Returns("Backup written", store.Backup{}),
+			"501": backupsUnavailable,
+		},
+	})
+	admin("POST /admin/backups/{name}/restore", handlers.RestoreBackup, openapi.Operation{
+		Summary: "Restore the database from a backup", Tags: []string{"admin"}, OperationID: "restoreBackup",
+		Description: "Replaces all data with the backup's in one transaction. Every task that existed before or after " +
+			"is recorded as changed, so sync clients catch up with since_seq. Task IDs are not reused.",
+		Parameters: []openapi.Parameter{{Name: "name", In: "path", Required: true, Schema: openapi.String()}},
+		Responses: map[string]openapi.Response{
+			"204": {Description: "Restored"},
+			"404": problemResponse("Backup not found"),
+			"409": problemResponse("The backup is at another schema version"),
+			"422": problemResponse("The file is not a valid backup"),
+			"501": backupsUnavailable,
+		},
+	})
+	admin("GET /admin/emails", handlers.PreviewEmail, openapi.Operation{
+		Summary: "List email templates", Tags: []string{"admin"}, OperationID: "listEmailTemplates",
+		Responses: map[string]openapi.Response{"200": api.Returns("Template names", []string{})},
+	})
+	admin("GET /admin/emails/{name}", handlers.PreviewEmail, openapi.Operation{
+		Summary: "Preview an email template rendered with sample data", Tags: []string{"admin"}, OperationID: "previewEmail",
+		Parameters: []openapi.Parameter{query("format", "text for the plaintext part")},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "Rendered email", Content: map[string]openapi.MediaType{"text/html": {Schema: openapi.String()}}},
+			"404": problemResponse("Unknown template"),
+		},
+	})
+
+	return mux
+}
+
+// newAPIBuilder starts the OpenAPI document, with schemas for the types whose JSON form
+// differs from their Go structure
+func newAPIBuilder() *openapi.Builder {
+	api := openapi.NewBuilder("TODO App API", "1.0.0",
+		"Task management API. Errors are returned as RFC 7807 problem details. Responses carry their trace ID in the X-Trace-ID header, and mutations return the change sequence in the X-Change-Seq header.")
+	api.Define(store.OptionalTime{}, &openapi.Schema{Type: "string", Format: "date-time", Nullable: true,
+		Description: "Omit to leave unchanged, null to clear"})
+	return api
+}
+
+func query(name, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: openapi.String()}
+}
+
+func userHeader() openapi.Parameter {
+	return openapi.Parameter{Name: userIDHeader, In: "header", Schema: openapi.String(),
+		Description: "User the request acts for; \"default\" when absent"}
+}
+
+// apiVersionParam documents X-API-Version on the routes returning lists
+func apiVersionParam() openapi.Parameter {
+	return openapi.Parameter{Name: apiVersionHeader, In: "header", Schema: openapi.String(),
+		Description: "1 (default) for a bare array, 2 for {data, meta, links}; also accepted as Accept: application/json; profile=2"}
+}
+
+// limitParam and offsetParam document the paging of the routes returning lists
+func limitParam() openapi.Parameter {
+	return openapi.Parameter{Name: "limit", In: "query", Schema: openapi.Integer(),
+		Description: "At most this many rows; TODO_MAX_LIST_ROWS, the default, is also the most a response holds. " +
+			"A page with rows after it links the next in a Link header and, for X-API-Version 2, links.next"}
+}
+
+func offsetParam() openapi.Parameter {
+	return openapi.Parameter{Name: "offset", In: "query", Schema: openapi.Integer(),
+		Description: "Rows to skip from the start of the list"}
+}
+
+func invalidPage() openapi.Response {
+	return problemResponse("Invalid limit or offset")
+}
+
+// problemSchema documents Problem, the body of every error response
+var problemSchema = &openapi.Schema{Type: "object", Properties: map[string]*openapi.Schema{
+	"type":     {Type: "string", Description: "Always about:blank; status and title say what went wrong"},
+	"title":    {Type: "string", Description: "The HTTP status text"},
+	"status":   openapi.Integer(),
+	"detail":   {Type: "string", Description: "What went wrong with this request"},
+	"instance": {Type: "string", Description: "The request path"},
+	"trace_id": {Type: "string", Description: "Trace of the request, also in X-Trace-ID"},
+}}
+
+func problemResponse(description string) openapi.Response {
+	return openapi.Response{Description: description, Content: map[string]openapi.MediaType{
+		problemContentType: {Schema: problemSchema},
+	}}
+}
@@ -0,0 +1,325 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/integrations"
+	"todo-app/internal/requestctx"
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Request signing headers
+const (
+	signatureClientHeader    = "X-Client-ID"
+	signatureTimestampHeader = "X-Signature-Timestamp"
+	signatureNonceHeader     = "X-Signature-Nonce"
+	signatureBodyHashHeader  = "X-Content-SHA256"
+	signatureHeader          = "X-Signature"
+	maxSignatureNonceLength  = 128
+	// maxSignedBodyInMemory is how much of a signed body is held in memory while it is hashed
+	maxSignedBodyInMemory = 1 << 20
+)
+
+// SigningClient is a machine-to-machine client that authenticates by signing requests with
+// a shared secret. Its signed requests act as UserID.
+type SigningClient struct {
+	ID     string
+	Secret []byte
+	UserID string
+}
+
+// parseSigningClients parses TODO_SIGNING_CLIENTS: comma-separated id:secret or
+// id:secret:user entries. The user defaults to the client ID.
+func parseSigningClients(v string) (map[string]SigningClient, error) {
+	clients := map[string]SigningClient{}
+	for _, entry := range strings.Split(v, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid signing client %q, expected id:secret or id:secret:user", entry)
+		}
+		client := SigningClient{ID: parts[0], Secret: []byte(parts[1]), UserID: parts[0]}
+		if len(parts) == 3 && parts[2] != "" {
+			client.UserID = parts[2]
+		}
+		if _, ok := clients[client.ID]; ok {
+			return nil, fmt.Errorf("duplicate signing client %q", client.ID)
+		}
+		clients[client.ID] = client
+	}
+	return clients, nil
+}
+
+// signingString is what a client signs: the method, the path with query string, the
+// timestamp, the nonce and the hex SHA-256 of the body, one per line
+func signingString(method, requestURI, timestamp, nonce, bodyHash string) string {
+	return strings.Join([]string{method, requestURI, timestamp, nonce, bodyHash}, "\n")
+}
+
+// SignRequest returns the hex HMAC-SHA256 signature of a request, as a client computes it
+func SignRequest(secret []byte, method, requestURI, timestamp, nonce, bodyHash string) string {
+	mac := hmac.New(sha256.New, secret)
+	io.WriteString(mac, signingString(method, requestURI, timestamp, nonce, bodyHash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RequestVerifier authenticates signed requests from SigningClients
+type RequestVerifier struct {
+	clients map[string]SigningClient
+	users   map[string]bool
+	nonces  store.NonceStore
+
+	rejected metric.Int64Counter
+}
+
+// NewRequestVerifier reads the signing clients from TODO_SIGNING_CLIENTS. Nonces of accepted
+// requests are remembered in nonces.
+func NewRequestVerifier(nonces store.NonceStore) (*RequestVerifier, error) {
+	clients, err := parseSigningClients(config.EnvString("TODO_SIGNING_CLIENTS", ""))
+	if err != nil {
+		return nil, err
+	}
+	v := &RequestVerifier{clients: clients, users: map[string]bool{}, nonces: nonces}
+	for _, client := range clients {
+		v.users[client.UserID] = true
+	}
+	v.rejected, _ = telemetry.GetMeter().Int64Counter("todo_app.signature.rejected",
+		metric.WithDescription("Requests rejected by request signature verification"),
+		metric.WithUnit("1"))
+	return v, nil
+}
+
+// signatureError is a rejected signature; reason is the metric attribute, message goes to the
+// client along with status, 401 unless set
+type signatureError struct {
+	reason  string
+	message string
+	status  int
+}
+
+func (e *signatureError) Error() string {
+	return e.message
+}
+
+// verify checks a signed request and returns its client. It consumes the body and replaces it
+// with the spooled copy, which the caller must close. The nonce is checked separately, since
+// only a correctly signed request may use one up.
+func (v *RequestVerifier) verify(w http.ResponseWriter, r *http.Request, now time.Time) (*SigningClient, *signatureError) {
+	client, ok := v.clients[r.Header.Get(signatureClientHeader)]
+	if !ok {
+		return nil, &signatureError{reason: "unknown_client", message: "Unknown client"}
+	}
+
+	timestamp := r.Header.Get(signatureTimestampHeader)
+	seconds, parseErr := strconv.ParseInt(timestamp, 10, 64)
+	if parseErr != nil {
+		return nil, &signatureError{reason: "invalid_timestamp", message: "Invalid " + signatureTimestampHeader}
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > integrations.SignatureMaxSkew || skew < -integrations.SignatureMaxSkew {
+		return nil, &signatureError{reason: "expired", message: "Signature timestamp outside the allowed window"}
+	}
+	nonce := r.Header.Get(signatureNonceHeader)
+	if nonce == "" || len(nonce) > maxSignatureNonceLength {
+		return nil, &signatureError{reason: "invalid_nonce", message: "Missing or invalid " + signatureNonceHeader}
+	}
+
+	bodyHash, err := spoolSignedBody(w, r)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(strings.ToLower(r.Header.Get(signatureBodyHashHeader))), []byte(bodyHash)) {
+		r.Body.Close()
+		return nil, &signatureError{reason: "body_mismatch", message: signatureBodyHashHeader + " does not match the body"}
+	}
+
+	expected := SignRequest(client.Secret, r.Method, r.URL.RequestURI(), timestamp, nonce, bodyHash)
+	if !hmac.Equal([]byte(strings.ToLower(r.Header.Get(signatureHeader))), []byte(expected)) {
+		r.Body.Close()
+		return nil, &signatureError{reason: "bad_signature", message: "Invalid signature"}
+	}
+	return &client, nil
+}
+
+// Middleware authenticates signed requests, which then act as their client's user whatever
+// X-User-ID says. Unsigned requests keep identifying their user with X-User-ID, except that
+// they cannot claim a user that belongs to a signing client.
+func (v *RequestVerifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if r.Header.Get(signatureHeader) == "" {
+			if v.users[requestUserID(r)] {
+				v.reject(w, r, &signatureError{reason: "unsigned", message: "Requests for this user must be signed"})
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := time.Now()
+		client, err := v.verify(w, r, now)
+		if err != nil {
+			v.reject(w, r, err)
+			return
+		}
+		defer r.Body.Close()
+		fresh, nonceErr := v.nonces.Add(ctx, client.ID+"\n"+r.Header.Get(signatureNonceHeader), now)
+		if nonceErr != nil {
+			slog.ErrorContext(ctx, "Error recording signature nonce", "error", nonceErr)
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !fresh {
+			v.reject(w, r, &signatureError{reason: "replayed", message: "Signature nonce already used"})
+			return
+		}
+		slog.DebugContext(ctx, "Verified request signature", "client_id", client.ID)
+		next.ServeHTTP(w, r.WithContext(requestctx.WithUser(ctx, client.UserID)))
+	})
+}
+
+// AllowsUnsigned reports whether an unsigned request may act for userID, for APIs that
+// cannot carry a signature. A refusal is recorded like a rejected signature.
+func (v *RequestVerifier) AllowsUnsigned(ctx context.Context, userID, method, path string) bool {
+	if !v.users[userID] {
+		return true
+	}
+	v.rejected.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "unsigned")))
+	integrations.RecordSecurityEvent(ctx, "signature.rejected", 7, map[string]string{
+		"reason": "unsigned",
+		"method": method,
+		"path":   path,
+	})
+	slog.WarnContext(ctx, "Rejected unsigned request", "method", method, "path", path)
+	return false
+}
+
+func (v *RequestVerifier) reject(w http.ResponseWriter, r *http.Request, err *signatureError) {
+	ctx := r.Context()
+	v.rejected.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", err.reason)))
+	integrations.RecordSecurityEvent(ctx, "signature.rejected", 7, map[string]string{
+		"reason":    err.reason,
+		"client_id": r.Header.Get(signatureClientHeader),
+		"method":    r.Method,
+		"path":      r.URL.Path,
+		"remote":    r.RemoteAddr,
+	})
+	slog.WarnContext(ctx, "Rejected request signature", "reason", err.reason,
+		"client_id", r.Header.Get(signatureClientHeader), "method", r.Method, "path", r.URL.Path)
+	status := http.StatusUnauthorized
+	if err.status != 0 {
+		status = err.status
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	http.Error(w, err.Error(), status)
+}
+
+// signedBodyLimit is the largest body a signed request may carry: the limit of the route it
+// is for, so the body is never read past what the handler would accept. The middleware runs
+// before routing, so the routes with larger limits are recognized by their path.
+func signedBodyLimit(r *http.Request) int64 {
+	switch {
+	case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/attachments"):
+		return store.MaxAttachmentSize
+	case r.Method == "PATCH" && strings.Contains(r.URL.Path, "/uploads/"):
+		return store.MaxUploadSize
+	}
+	return maxRequestBodyBytes
+}
+
+// spooledBody is a verified request body handed on to the handler. Bodies up to
+// maxSignedBodyInMemory stay in memory; larger ones, such as attachment uploads, are
+// spooled to a temporary file that Close removes.
+type spooledBody struct {
+	io.Reader
+	file *os.File
+}
+
+func (b *spooledBody) Close() error {
+	if b.file == nil {
+		return nil
+	}
+	b.file.Close()
+	return os.Remove(b.file.Name())
+}
+
+// spoolSignedBody hashes the body as it is read, up to signedBodyLimit, and replaces r.Body
+// with the spooled copy. It returns the hex SHA-256 of the body.
+func spoolSignedBody(w http.ResponseWriter, r *http.Request) (string, *signatureError) {
+	hash := sha256.New()
+	if r.Body == nil || r.Body == http.NoBody {
+		r.Body = &spooledBody{Reader: bytes.NewReader(nil)}
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+	limit := signedBodyLimit(r)
+	if r.ContentLength > limit {
+		return "", tooLargeSignedBody(limit)
+	}
+	body := io.TeeReader(http.MaxBytesReader(w, r.Body, limit), hash)
+
+	head, err := io.ReadAll(io.LimitReader(body, maxSignedBodyInMemory+1))
+	if err == nil && len(head) <= maxSignedBodyInMemory {
+		r.Body = &spooledBody{Reader: bytes.NewReader(head)}
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+	var spooled *spooledBody
+	if err == nil {
+		spooled, err = spoolToFile(io.MultiReader(bytes.NewReader(head), body))
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return "", tooLargeSignedBody(limit)
+	}
+	if err != nil {
+		return "", &signatureError{reason: "invalid_body", message: "Invalid request body"}
+	}
+	r.Body = spooled
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// spoolToFile copies body to a temporary file and returns it rewound
+func spoolToFile(body io.Reader) (*spooledBody, error) {
+	file, err := os.CreateTemp("", "todo-signed-body-*")
+	if err != nil {
+		return nil, err
+	}
+	spooled := &spooledBody{Reader: file, file: file}
+	if _, err := io.Copy(file, body); err != nil {
+		spooled.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		spooled.Close()
+		return nil, err
+	}
+	return spooled, nil
+}
+
+func tooLargeSignedBody(limit int64) *signatureError {
+	return &signatureError{
+		reason:  "invalid_body",
+		message: fmt.Sprintf("Request body too large, at most %d bytes allowed", limit),
+		status:  http.StatusRequestEntityTooLarge,
+	}
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// memoryNonces is a NonceStore that remembers every nonce
+type memoryNonces map[string]bool
+
+func (n memoryNonces) Add(_ context.Context, key string, _ time.Time) (bool, error) {
+	if n[key] {
+		return false, nil
+	}
+	n[key] = true
+	return true, nil
+}
+
+// countingReader is an endless body that counts how much of it was read
+type countingReader struct {
+	read int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'x'
+	}
+	c.read += int64(len(p))
+	return len(p), nil
+}
+
+func newTestVerifier(t *testing.T) *RequestVerifier {
+	t.Helper()
+	t.Setenv("TODO_SIGNING_CLIENTS", "robot:s3cret")
+	v, err := NewRequestVerifier(memoryNonces{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func signedRequest(method, target string, body io.Reader, bodyHash string) *http.Request {
+	r := httptest.NewRequest(method, target, body)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	r.Header.Set(signatureClientHeader, "robot")
+	r.Header.Set(signatureTimestampHeader, timestamp)
+	r.Header.Set(signatureNonceHeader, "nonce-"+timestamp+"-"+bodyHash[:8])
+	r.Header.Set(signatureBodyHashHeader, bodyHash)
+	r.Header.Set(signatureHeader, SignRequest([]byte("s3cret"), method, r.URL.RequestURI(), timestamp, r.Header.Get(signatureNonceHeader), bodyHash))
+	return r
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestSignedRequestBodyReachesHandler(t *testing.T) {
+	v := newTestVerifier(t)
+	for name, body := range map[string][]byte{
+		"small":                      []byte(`{"title": "signed"}`),
+		"larger than the memory cap": bytes.Repeat([]byte("a"), maxSignedBodyInMemory+10),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var got []byte
+			handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = io.ReadAll(r.Body)
+			}))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, signedRequest("POST", "/tasks/1/attachments", bytes.NewReader(body), sha256Hex(body)))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+			}
+			if !bytes.Equal(got, body) {
+				t.Errorf("handler read %d bytes, want the %d signed", len(got), len(body))
+			}
+		})
+	}
+}
+
+func TestSignedRequestBodyIsCapped(t *testing.T) {
+	v := newTestVerifier(t)
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler ran for an oversized body")
+	}))
+	body := &countingReader{}
+	r := signedRequest("POST", "/tasks", body, sha256Hex(nil))
+	r.ContentLength = -1
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want 413", w.Code)
+	}
+	if body.read > 2*maxRequestBodyBytes {
+		t.Errorf("read %d bytes of the body, want the reading to stop near the %d byte limit", body.read, maxRequestBodyBytes)
+	}
+}
+
+func TestSignedRequestDeclaredTooLarge(t *testing.T) {
+	v := newTestVerifier(t)
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler ran for an oversized body")
+	}))
+	body := &countingReader{}
+	r := signedRequest("POST", "/tasks", body, sha256Hex(nil))
+	r.ContentLength = maxRequestBodyBytes + 1
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want 413", w.Code)
+	}
+	if body.read != 0 {
+		t.Errorf("read %d bytes of a body declared too large, want none", body.read)
+	}
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Snapshots serves GET and POST /snapshots
+func (h *Handlers) Snapshots(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+		return
+	case "GET":
+		span.SetAttributes(attribute.String("operation", "list_snapshots"))
+		snapshots, err := h.db.GetSnapshots(ctx)
+		if err != nil {
+			if h.abandonIfCanceled(ctx, start, "GET", "/snapshots") {
+				return
+			}
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error listing snapshots", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.recordRequestMetrics(ctx, start, "GET", "/snapshots", http.StatusInternalServerError)
+			return
+		}
+		h.recordRequestMetrics(ctx, start, "GET", "/snapshots", writeList(w, r, snapshots))
+	case "POST":
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		name, err := store.NormalizeTitle(req.Name)
+		if err != nil {
+			http.Error(w, "Snapshot name is required", http.StatusBadRequest)
+			return
+		}
+
+		span.SetAttributes(
+			attribute.String("operation", "create_snapshot"),
+			attribute.String("snapshot.name", name),
+		)
+		slog.InfoContext(ctx, "Creating snapshot", "name", name)
+
+		snapshot, err := h.db.CreateSnapshot(ctx, name)
+		if err != nil {
+			if h.abandonIfCanceled(ctx, start, "POST", "/snapshots") {
+				return
+			}
+			if errors.Is(err, store.ErrSnapshotNameTaken) {
+				http.Error(w, "A snapshot with this name already exists", http.StatusConflict)
+				h.recordRequestMetrics(ctx, start, "POST", "/snapshots", http.StatusConflict)
+				return
+			}
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error creating snapshot", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.recordRequestMetrics(ctx, start, "POST", "/snapshots", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, r, snapshot)
+		slog.InfoContext(ctx, "Snapshot created", "id", snapshot.ID, "name", snapshot.Name, "tasks", snapshot.TaskCount)
+		h.recordRequestMetrics(ctx, start, "POST", "/snapshots", http.StatusCreated)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Snapshot serves DELETE /snapshots/{id}, GET /snapshots/{id}/diff and POST /snapshots/{id}/restore
+func (h *Handlers) Snapshot(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/snapshots/")
+	idPart, action, _ := strings.Cut(path, "/")
+	id, err := strconv.Atoi(idPart)
+	if err != nil {
+		http.Error(w, "Invalid snapshot ID", http.StatusBadRequest)
+		return
+	}
+
+	var method, endpoint, operation string
+	switch {
+	case action == "" && r.Method == "DELETE":
+		method, endpoint, operation = "DELETE", "/snapshots/:id", "delete_snapshot"
+	case action == "diff" && r.Method == "GET":
+		method, endpoint, operation = "GET", "/snapshots/:id/diff", "diff_snapshot"
+	case action == "restore" && r.Method == "POST":
+		method, endpoint, operation = "POST", "/snapshots/:id/restore", "restore_snapshot"
+	case action == "" || action == "diff" || action == "restore":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("operation", operation),
+		attribute.Int("snapshot.id", id),
+	)
+	slog.InfoContext(ctx, "Handling snapshot request", "id", id, "action", action)
+
+	var diff *store.SnapshotDiff
+	switch action {
+	case "":
+		err = h.db.DeleteSnapshot(ctx, id)
+	case "diff":
+		diff, err = h.db.DiffSnapshot(ctx, id)
+	case "restore":
+		diff, err = h.db.RestoreSnapshot(ctx, id)
+	}
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, method, endpoint) {
+			return
+		}
+		if err == sql.ErrNoRows {
+			http.Error(w, "Snapshot not found", http.StatusNotFound)
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusNotFound)
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error handling snapshot request", "error", err, "id", id, "action", action)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusInternalServerError)
+		return
+	}
+
+	if diff == nil {
+		w.WriteHeader(http.StatusNoContent)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, diff)
+	slog.InfoContext(ctx, "Snapshot request handled", "id", id, "action", action,
+		"added", len(diff.Added), "removed", len(diff.Removed), "changed", len(diff.Changed))
+	h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusOK)
+}
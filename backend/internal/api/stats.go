@@ -0,0 +1,213 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/requestctx"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GetUserStats serves GET /me/stats, the requesting user's completion totals and streak
+func (h *Handlers) GetUserStats(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	method, endpoint := "GET", "/me/stats"
+
+	userID := requestctx.User(ctx)
+	span.SetAttributes(
+		attribute.String("operation", "get_user_stats"),
+		attribute.String("user.id", userID),
+	)
+
+	stats, err := h.db.GetUserStats(ctx, userID)
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, method, endpoint) {
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error fetching user stats", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, stats)
+	h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusOK)
+}
+
+// heatmapCacheTTL is how long a GET /stats/heatmap response is cached; 0 turns the cache
+// off. Like the task list, entries are keyed by the change sequence, so a completion misses
+// the cache straight away.
+var heatmapCacheTTL = config.EnvDuration("TODO_HEATMAP_CACHE_TTL", time.Hour)
+
+// GetHeatmap serves GET /stats/heatmap?year=YYYY&tz=Zone, the completions of every day of a
+// year for a contribution-style calendar. Days are in tz, by default the time zone of the
+// requesting user's notification settings, and the year defaults to the current one there.
+func (h *Handlers) GetHeatmap(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	method, endpoint := "GET", "/stats/heatmap"
+	span.SetAttributes(attribute.String("operation", "get_heatmap"))
+
+	var loc *time.Location
+	var err error
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		if loc, err = time.LoadLocation(tz); err != nil {
+			http.Error(w, fmt.Sprintf("Unknown time zone %q", tz), http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+			return
+		}
+	} else if loc, err = h.db.UserLocation(ctx, requestctx.User(ctx)); err != nil {
+		h.heatmapError(w, ctx, start, err)
+		return
+	}
+	year := time.Now().In(loc).Year()
+	if v := r.URL.Query().Get("year"); v != "" {
+		y, err := strconv.Atoi(v)
+		if err != nil || y < 1970 || y > year+1 {
+			http.Error(w, fmt.Sprintf("Invalid year, expected 1970 to %d", year+1), http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+			return
+		}
+		year = y
+	}
+	span.SetAttributes(attribute.Int("heatmap.year", year), attribute.String("heatmap.timezone", loc.String()))
+
+	body, err := h.heatmap(ctx, year, loc)
+	if err != nil {
+		h.heatmapError(w, ctx, start, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+	h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusOK)
+}
+
+func (h *Handlers) heatmapError(w http.ResponseWriter, ctx context.Context, start time.Time, err error) {
+	if h.abandonIfCanceled(ctx, start, "GET", "/stats/heatmap") {
+		return
+	}
+	trace.SpanFromContext(ctx).RecordError(err)
+	slog.ErrorContext(ctx, "Error fetching completion heatmap", "error", err)
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+	h.recordRequestMetrics(ctx, start, "GET", "/stats/heatmap", http.StatusInternalServerError)
+}
+
+// heatmap returns the encoded heatmap of year in loc, from the task list cache when it has
+// it. A cache that fails is skipped rather than failing the request.
+func (h *Handlers) heatmap(ctx context.Context, year int, loc *time.Location) ([]byte, error) {
+	span := trace.SpanFromContext(ctx)
+	seq, err := h.db.ChangeSeq(ctx)
+	if err != nil {
+		return nil, err
+	}
+	format := responseJSONFormat(ctx)
+	key := fmt.Sprintf("heatmap:%d:%d:%s:%s+%s", seq, year, loc, format.naming, format.time)
+	if heatmapCacheTTL > 0 {
+		body, ok, err := h.taskCache.get(ctx, key)
+		if err != nil {
+			slog.WarnContext(ctx, "Error reading heatmap cache", "error", err)
+		}
+		span.SetAttributes(attribute.Bool("cache.hit", ok))
+		if ok {
+			h.cacheLookups.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "hit")))
+			return body, nil
+		}
+		h.cacheLookups.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "miss")))
+	}
+
+	heatmap, err := h.db.GetCompletionHeatmap(ctx, year, loc)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(formatJSON(ctx, heatmap))
+	if err != nil {
+		return nil, err
+	}
+	body = append(body, '\n')
+	if heatmapCacheTTL > 0 {
+		if err := h.taskCache.set(ctx, key, body, heatmapCacheTTL); err != nil {
+			slog.WarnContext(ctx, "Error writing heatmap cache", "error", err)
+		}
+	}
+	return body, nil
+}
+
+// GetUsage serves GET /me/usage, the requesting user's usage of each quota
+func (h *Handlers) GetUsage(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	method, endpoint := "GET", "/me/usage"
+
+	userID := requestctx.User(ctx)
+	span.SetAttributes(
+		attribute.String("operation", "get_usage"),
+		attribute.String("user.id", userID),
+	)
+
+	usage, err := h.db.GetUsage(ctx, userID)
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, method, endpoint) {
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error fetching quota usage", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, usage)
+	h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusOK)
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RenameTag serves POST /admin/tags/rename, renaming {"from": ...} to {"to": ...} on every task
+func (h *Handlers) RenameTag(w http.ResponseWriter, r *http.Request) {
+	h.replaceTag(w, r, store.TagRename)
+}
+
+// MergeTags serves POST /admin/tags/merge, replacing {"from": ...} with {"into": ...} on every task
+func (h *Handlers) MergeTags(w http.ResponseWriter, r *http.Request) {
+	h.replaceTag(w, r, store.TagMerge)
+}
+
+func (h *Handlers) replaceTag(w http.ResponseWriter, r *http.Request, operation string) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	endpoint := "/admin/tags/" + operation
+	badRequest := func(message string) {
+		http.Error(w, message, http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, "POST", endpoint, http.StatusBadRequest)
+	}
+
+	var req struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+		Into string `json:"into"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		badRequest("Invalid request body")
+		return
+	}
+	to, toField := req.To, "to"
+	if operation == store.TagMerge {
+		to, toField = req.Into, "into"
+	}
+	from, err := store.NormalizeTag(req.From)
+	if err != nil {
+		badRequest("Invalid from: " + err.Error())
+		return
+	}
+	if to, err = store.NormalizeTag(to); err != nil {
+		badRequest("Invalid " + toField + ": " + err.Error())
+		return
+	}
+	if from == to {
+		badRequest("from and " + toField + " are the same tag")
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("operation", operation+"_tag"),
+		attribute.String("tag.from", from),
+		attribute.String("tag.to", to),
+	)
+	slog.InfoContext(ctx, "Replacing tag", "operation", operation, "from", from, "to", to)
+
+	var op *store.TagOperation
+	if operation == store.TagMerge {
+		op, err = h.db.MergeTags(ctx, from, to)
+	} else {
+		op, err = h.db.RenameTag(ctx, from, to)
+	}
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "POST", endpoint) {
+			return
+		}
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, store.ErrTagNotFound):
+			status = http.StatusNotFound
+			http.Error(w, "No task carries the tag "+from, status)
+		case errors.Is(err, store.ErrTagExists):
+			status = http.StatusConflict
+			http.Error(w, err.Error(), status)
+		default:
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error replacing tag", "error", err, "operation", operation, "from", from, "to", to)
+			http.Error(w, "Internal server error", status)
+		}
+		h.recordRequestMetrics(ctx, start, "POST", endpoint, status)
+		return
+	}
+
+	span.SetAttributes(attribute.Int("tag.tasks", len(op.TaskIDs)))
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, op)
+	slog.InfoContext(ctx, "Tag replaced successfully", "operation", operation, "from", from, "to", to, "tasks", len(op.TaskIDs))
+	h.recordRequestMetrics(ctx, start, "POST", endpoint, http.StatusOK)
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// taskCacheTTL is how long a GET /tasks result is cached; 0 turns the cache off. Entries
+// are keyed by the change sequence, so any change to a task misses the cache straight
+// away; the TTL only bounds how long an expired claim, or a task not yet shown as stale,
+// can still show.
+var taskCacheTTL = config.EnvDuration("TODO_TASK_CACHE_TTL", 10*time.Second)
+
+// maxMemoryCacheEntries bounds the in-memory cache; each distinct query is one entry
+const maxMemoryCacheEntries = 256
+
+// taskListCache stores encoded GET /tasks responses
+type taskListCache interface {
+	// get returns the value stored under key, if it is there and fresh
+	get(ctx context.Context, key string) ([]byte, bool, error)
+	set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// taskListCacheKey identifies a task list by the change sequence it is current to, the
+// query that produced it and the JSON format it is encoded in
+func taskListCacheKey(seq int64, q store.TaskQuery, format jsonFormat) string {
+	listID := ""
+	if q.ListID != nil {
+		listID = fmt.Sprint(*q.ListID)
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%q %q %q %q %q %t %d %d", q.Search, q.Tag, q.Sort, q.Locale, listID, q.Stale, q.Offset, q.Limit)))
+	return fmt.Sprintf("tasks:%d:%s:%s+%s", seq, hex.EncodeToString(sum[:16]), format.naming, format.time)
+}
+
+// memoryCache is a taskListCache for a single replica
+type memoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+}
+
+type memoryCacheEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{entries: map[string]memoryCacheEntry{}}
+}
+
+func (c *memoryCache) get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (c *memoryCache) set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= maxMemoryCacheEntries {
+		// Entries for older change sequences are never read again; clearing everything
+		// expired usually makes room, and failing that the cache starts over
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxMemoryCacheEntries {
+			clear(c.entries)
+		}
+	}
+	c.entries[key] = memoryCacheEntry{value: value, expires: now.Add(ttl)}
+	return nil
+}
+
+// taskList returns the encoded tasks matching query as of seq, from the cache when it has
+// them. A cache that fails is skipped rather than failing the request, and so is a request
+// with feature overrides, which is there to exercise the code behind the cache.
+func (h *Handlers) taskList(ctx context.Context, seq int64, query store.TaskQuery) ([]byte, error) {
+	span := trace.SpanFromContext(ctx)
+	key := taskListCacheKey(seq, query, responseJSONFormat(ctx))
+	useCache := taskCacheTTL > 0 && !hasFeatureOverrides(ctx)
+	if useCache {
+		body, ok, err := h.taskCache.get(ctx, key)
+		if err != nil {
+			slog.WarnContext(ctx, "Error reading task list cache", "error", err)
+		}
+		span.SetAttributes(attribute.Bool("cache.hit", ok))
+		if ok {
+			h.cacheLookups.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "hit")))
+			slog.InfoContext(ctx, "Served tasks from cache", "seq", seq)
+			return body, nil
+		}
+		h.cacheLookups.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "miss")))
+	}
+
+	tasks, err := h.store.GetAllTasks(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if tasks == nil {
+		tasks = []store.Task{}
+	}
+	body, err := json.Marshal(formatJSON(ctx, tasks))
+	if err != nil {
+		return nil, err
+	}
+	body = append(body, '\n')
+	slog.InfoContext(ctx, "Successfully retrieved tasks", "count", len(tasks))
+
+	if useCache {
+		if err := h.taskCache.set(ctx, key, body, taskCacheTTL); err != nil {
+			slog.WarnContext(ctx, "Error writing task list cache", "error", err)
+		}
+	}
+	return body, nil
+}
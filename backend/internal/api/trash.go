@@ -0,0 +1,109 @@
+package api
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GetTrash handles GET /tasks/trash
+func (h *Handlers) GetTrash(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	span.SetAttributes(attribute.String("operation", "get_trash"))
+	slog.InfoContext(ctx, "Getting trash")
+
+	tasks, err := h.db.GetTrash(ctx)
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "GET", "/tasks/trash") {
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error getting trash", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, "GET", "/tasks/trash", http.StatusInternalServerError)
+		return
+	}
+
+	if tasks == nil {
+		tasks = []store.Task{}
+	}
+
+	status := writeList(w, r, tasks)
+
+	slog.InfoContext(ctx, "Successfully retrieved trash", "count", len(tasks))
+	h.recordRequestMetrics(ctx, start, "GET", "/tasks/trash", status)
+}
+
+// RestoreTask handles POST /tasks/{id}/restore
+func (h *Handlers) RestoreTask(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := h.taskIDFromPath(w, r, start, "POST", "/tasks/:id/restore", r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("operation", "restore_task"),
+		attribute.Int("task.id", id),
+	)
+	slog.InfoContext(ctx, "Restoring task", "id", id)
+
+	task, err := h.db.RestoreTask(ctx, id)
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "POST", "/tasks/:id/restore") {
+			return
+		}
+		if err == sql.ErrNoRows {
+			slog.WarnContext(ctx, "Task not found in trash", "id", id)
+			http.Error(w, "Task not found in trash", http.StatusNotFound)
+			h.recordRequestMetrics(ctx, start, "POST", "/tasks/:id/restore", http.StatusNotFound)
+		} else {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error restoring task", "error", err, "id", id)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.recordRequestMetrics(ctx, start, "POST", "/tasks/:id/restore", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, task)
+	slog.InfoContext(ctx, "Task restored successfully", "id", task.ID, "title", task.Title)
+	h.recordRequestMetrics(ctx, start, "POST", "/tasks/:id/restore", http.StatusOK)
+}
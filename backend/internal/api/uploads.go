@@ -0,0 +1,325 @@
+package api
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"todo-app/internal/integrations"
+	"todo-app/internal/requestctx"
+	"todo-app/internal/store"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Headers of chunked uploads, named as in the tus protocol
+const (
+	uploadOffsetHeader = "Upload-Offset"
+	uploadLengthHeader = "Upload-Length"
+)
+
+// UseUploads stages chunked uploads in staging. Without it, or without attachments, the
+// upload routes answer 501.
+func (h *Handlers) UseUploads(staging *integrations.UploadStaging) {
+	h.uploads = staging
+}
+
+// TaskUploads serves POST /tasks/{id}/uploads, which starts a chunked upload of a file
+// that becomes an attachment once all of it has arrived
+func (h *Handlers) TaskUploads(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	method, endpoint := r.Method, "/tasks/:id/uploads"
+	if h.uploads == nil || h.attachments == nil {
+		http.Error(w, "Uploads are not configured", http.StatusNotImplemented)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusNotImplemented)
+		return
+	}
+
+	id, ok := h.taskIDFromPath(w, r, start, method, endpoint, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name        string `json:"name"`
+		ContentType string `json:"content_type"`
+		Size        int64  `json:"size"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+		return
+	}
+	name, ok := attachmentName(req.Name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Invalid name, expected a file name of at most %d characters", maxAttachmentNameLength), http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+		return
+	}
+	contentType, ok := attachmentContentType(req.ContentType)
+	if !ok {
+		http.Error(w, "Invalid content_type", http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+		return
+	}
+	if req.Size <= 0 {
+		http.Error(w, "Invalid size, expected the file's size in bytes", http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+		return
+	}
+	if req.Size > store.MaxUploadSize {
+		http.Error(w, fmt.Sprintf("Upload too large, at most %d bytes allowed", store.MaxUploadSize), http.StatusRequestEntityTooLarge)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	upload := &store.Upload{
+		ID:          uuid.NewString(),
+		TaskID:      id,
+		Name:        name,
+		ContentType: contentType,
+		Size:        req.Size,
+		CreatedBy:   requestctx.User(ctx),
+	}
+	span.SetAttributes(
+		attribute.String("operation", "create_upload"),
+		attribute.Int("task.id", id),
+		attribute.String("upload.id", upload.ID),
+		attribute.Int64("upload.size", upload.Size),
+	)
+	if err := h.db.CreateUpload(ctx, upload); err != nil {
+		h.attachmentError(w, r, start, method, endpoint, err)
+		return
+	}
+	slog.InfoContext(ctx, "Started upload", "id", upload.ID, "task_id", id, "size", upload.Size)
+
+	w.Header().Set("Location", "/tasks/"+strconv.Itoa(id)+"/uploads/"+upload.ID)
+	w.Header().Set(uploadOffsetHeader, "0")
+	w.Header().Set(uploadLengthHeader, strconv.FormatInt(upload.Size, 10))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, r, upload)
+	h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusCreated)
+}
+
+// TaskUpload serves GET (and HEAD), PATCH and DELETE /tasks/{id}/uploads/{upload}. PATCH
+// appends the body at Upload-Offset, which must be where the upload is; the chunk that
+// completes the file answers 201 with the new attachment.
+func (h *Handlers) TaskUpload(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var operation string
+	switch r.Method {
+	case "GET", "HEAD":
+		operation = "get_upload"
+	case "PATCH":
+		operation = "upload_chunk"
+	case "DELETE":
+		operation = "cancel_upload"
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	method, endpoint := r.Method, "/tasks/:id/uploads/:upload"
+	if h.uploads == nil || h.attachments == nil {
+		http.Error(w, "Uploads are not configured", http.StatusNotImplemented)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusNotImplemented)
+		return
+	}
+
+	id, ok := h.taskIDFromPath(w, r, start, method, endpoint, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	uploadID := r.PathValue("upload")
+	span.SetAttributes(
+		attribute.String("operation", operation),
+		attribute.Int("task.id", id),
+		attribute.String("upload.id", uploadID),
+	)
+	if _, err := uuid.Parse(uploadID); err != nil {
+		h.attachmentError(w, r, start, method, endpoint, sql.ErrNoRows)
+		return
+	}
+	// Offsets change with every chunk, so nothing on the way may cache them
+	w.Header().Set("Cache-Control", "no-store")
+
+	if method == "DELETE" {
+		if err := h.db.DeleteUpload(ctx, id, uploadID); err != nil {
+			h.attachmentError(w, r, start, method, endpoint, err)
+			return
+		}
+		if err := h.uploads.Remove(uploadID); err != nil {
+			slog.WarnContext(ctx, "Failed to remove the staging file of a canceled upload", "error", err, "id", uploadID)
+		}
+		slog.InfoContext(ctx, "Canceled upload", "id", uploadID, "task_id", id)
+		w.WriteHeader(http.StatusNoContent)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusNoContent)
+		return
+	}
+
+	upload, err := h.db.GetUpload(ctx, id, uploadID)
+	if err != nil {
+		h.attachmentError(w, r, start, method, endpoint, err)
+		return
+	}
+	if method != "PATCH" {
+		writeUploadOffset(w, upload)
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, r, upload)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusOK)
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get(uploadOffsetHeader), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "Missing or invalid Upload-Offset header", http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+		return
+	}
+	if offset != upload.Received {
+		writeUploadOffset(w, upload)
+		http.Error(w, fmt.Sprintf("Upload-Offset %d does not match the upload, which is at %d", offset, upload.Received), http.StatusConflict)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusConflict)
+		return
+	}
+	if r.ContentLength > upload.Size-offset {
+		http.Error(w, fmt.Sprintf("Chunk goes past the end of the upload, at most %d more bytes expected", upload.Size-offset), http.StatusRequestEntityTooLarge)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// What arrived of a chunk is kept even if the connection breaks, so the client resumes
+	// from there rather than from the start of the chunk
+	if offset < upload.Size {
+		written, writeErr := h.uploads.Write(uploadID, offset, http.MaxBytesReader(w, r.Body, upload.Size-offset))
+		span.SetAttributes(attribute.Int64("upload.chunk_size", written))
+		if written > 0 {
+			upload, err = h.db.AdvanceUpload(ctx, uploadID, offset, offset+written)
+			if errors.Is(err, store.ErrUploadOffsetMismatch) {
+				http.Error(w, "Another request wrote to the upload at the same offset", http.StatusConflict)
+				h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusConflict)
+				return
+			}
+			if err != nil {
+				h.attachmentError(w, r, start, method, endpoint, err)
+				return
+			}
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(writeErr, &tooLarge) {
+			writeUploadOffset(w, upload)
+			http.Error(w, fmt.Sprintf("Chunk goes past the end of the upload, at most %d more bytes expected", upload.Size-offset), http.StatusRequestEntityTooLarge)
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusRequestEntityTooLarge)
+			return
+		}
+		if writeErr != nil {
+			if h.abandonIfCanceled(ctx, start, method, endpoint) {
+				return
+			}
+			h.attachmentError(w, r, start, method, endpoint, writeErr)
+			return
+		}
+	}
+	if upload.Received < upload.Size {
+		writeUploadOffset(w, upload)
+		w.WriteHeader(http.StatusNoContent)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusNoContent)
+		return
+	}
+
+	// The last chunk is in: store the file and turn the upload into an attachment. A PATCH
+	// with an empty body at the end retries this if it failed.
+	attachment, err := h.completeUpload(r, upload)
+	if err != nil {
+		h.attachmentError(w, r, start, method, endpoint, err)
+		return
+	}
+	slog.InfoContext(ctx, "Completed upload", "id", uploadID, "attachment_id", attachment.ID, "task_id", id, "size", upload.Size)
+
+	writeUploadOffset(w, upload)
+	w.Header().Set("Location", "/tasks/"+strconv.Itoa(id)+"/attachments/"+strconv.Itoa(attachment.ID))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, r, attachment)
+	h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusCreated)
+}
+
+// completeUpload stores the staged file of a complete upload and records it as an
+// attachment. The staging file is removed once the attachment exists; if storing fails the
+// upload stays complete, for the client to retry.
+func (h *Handlers) completeUpload(r *http.Request, upload *store.Upload) (*store.Attachment, error) {
+	ctx := r.Context()
+	f, err := h.uploads.Open(upload.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	content := io.NewSectionReader(f, 0, upload.Size)
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return nil, err
+	}
+
+	attachment := &store.Attachment{
+		TaskID:      upload.TaskID,
+		Name:        upload.Name,
+		ContentType: upload.ContentType,
+		Size:        upload.Size,
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+		StorageKey:  strconv.Itoa(upload.TaskID) + "/" + uuid.NewString(),
+		CreatedBy:   upload.CreatedBy,
+	}
+	blob := integrations.AttachmentBlob{Body: content, Size: upload.Size, SHA256: attachment.SHA256, ContentType: upload.ContentType}
+	if err := h.attachments.Put(ctx, attachment.StorageKey, blob); err != nil {
+		return nil, err
+	}
+	if err := h.db.CompleteUpload(ctx, upload.ID, attachment); err != nil {
+		if err := h.attachments.Delete(ctx, attachment.StorageKey); err != nil {
+			slog.WarnContext(ctx, "Failed to delete the content of an upload that was not recorded", "error", err, "key", attachment.StorageKey)
+		}
+		return nil, err
+	}
+	f.Close()
+	if err := h.uploads.Remove(upload.ID); err != nil {
+		slog.WarnContext(ctx, "Failed to remove the staging file of a completed upload", "error", err, "id", upload.ID)
+	}
+	return attachment, nil
+}
+
+func writeUploadOffset(w http.ResponseWriter, upload *store.Upload) {
+	w.Header().Set(uploadOffsetHeader, strconv.FormatInt(upload.Received, 10))
+	w.Header().Set(uploadLengthHeader, strconv.FormatInt(upload.Size, 10))
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"todo-app/internal/requestctx"
+)
+
+// userIDHeader identifies the user a request acts for. The app has no authentication,
+// so this only scopes per-user settings such as notification rules; a fronting proxy
+// that authenticates users is expected to set it.
+const userIDHeader = "X-User-ID"
+
+// requestUserID returns the user a request acts for
+func requestUserID(r *http.Request) string {
+	if id := strings.TrimSpace(r.Header.Get(userIDHeader)); id != "" {
+		return id
+	}
+	return requestctx.DefaultUser
+}
+
+// UserMiddleware stores the requesting user in the request context, so code below the
+// handlers (e.g. the task history) can attribute changes without taking a request. A user
+// already authenticated by an outer middleware (a signed request) is kept.
+func UserMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requestctx.User(r.Context()) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(requestctx.WithUser(r.Context(), requestUserID(r))))
+	})
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"todo-app/internal/requestctx"
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Webhooks serves GET and POST /webhooks for the requesting user
+func (h *Handlers) Webhooks(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	userID := requestctx.User(r.Context())
+	span.SetAttributes(attribute.String("user.id", userID))
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+		return
+	case "GET":
+		span.SetAttributes(attribute.String("operation", "get_webhooks"))
+		webhooks, err := h.db.GetWebhooks(ctx, userID)
+		if err != nil {
+			if h.abandonIfCanceled(ctx, start, "GET", "/webhooks") {
+				return
+			}
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error getting webhooks", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.recordRequestMetrics(ctx, start, "GET", "/webhooks", http.StatusInternalServerError)
+			return
+		}
+		h.recordRequestMetrics(ctx, start, "GET", "/webhooks", writeList(w, r, webhooks))
+	case "POST":
+		var webhook store.Webhook
+		if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, "POST", "/webhooks", http.StatusBadRequest)
+			return
+		}
+		webhook.UserID = userID
+		if err := h.webhooks.Validate(&webhook); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, "POST", "/webhooks", http.StatusBadRequest)
+			return
+		}
+
+		span.SetAttributes(
+			attribute.String("operation", "create_webhook"),
+			attribute.StringSlice("webhook.events", webhook.Events),
+		)
+		slog.InfoContext(ctx, "Creating webhook", "events", webhook.Events)
+
+		created, err := h.db.CreateWebhook(ctx, webhook)
+		if err != nil {
+			if h.abandonIfCanceled(ctx, start, "POST", "/webhooks") {
+				return
+			}
+			if errors.Is(err, store.ErrQuotaExceeded) {
+				slog.WarnContext(ctx, "Refused webhook over quota", "error", err)
+				http.Error(w, err.Error(), http.StatusForbidden)
+				h.recordRequestMetrics(ctx, start, "POST", "/webhooks", http.StatusForbidden)
+				return
+			}
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "Error creating webhook", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			h.recordRequestMetrics(ctx, start, "POST", "/webhooks", http.StatusInternalServerError)
+			return
+		}
+		// The secret is shown this once
+		created.Secret = webhook.Secret
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, r, created)
+		slog.InfoContext(ctx, "Webhook created", "id", created.ID)
+		h.recordRequestMetrics(ctx, start, "POST", "/webhooks", http.StatusCreated)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Webhook serves DELETE /webhooks/{id}
+func (h *Handlers) Webhook(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := requestctx.User(r.Context())
+	span.SetAttributes(
+		attribute.String("operation", "delete_webhook"),
+		attribute.Int("webhook.id", id),
+		attribute.String("user.id", userID),
+	)
+	slog.InfoContext(ctx, "Deleting webhook", "id", id)
+
+	if err := h.db.DeleteWebhook(ctx, userID, id); err != nil {
+		if h.abandonIfCanceled(ctx, start, "DELETE", "/webhooks/:id") {
+			return
+		}
+		if err == sql.ErrNoRows {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			h.recordRequestMetrics(ctx, start, "DELETE", "/webhooks/:id", http.StatusNotFound)
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error deleting webhook", "error", err, "id", id)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, "DELETE", "/webhooks/:id", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	h.recordRequestMetrics(ctx, start, "DELETE", "/webhooks/:id", http.StatusNoContent)
+}
+
+// WebhookDeliveries serves GET /webhooks/{id}/deliveries, the webhook's 50 most recent
+// deliveries with their attempts and last errors
+func (h *Handlers) WebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := requestctx.User(r.Context())
+	span.SetAttributes(
+		attribute.String("operation", "get_webhook_deliveries"),
+		attribute.Int("webhook.id", id),
+		attribute.String("user.id", userID),
+	)
+
+	deliveries, err := h.db.GetWebhookDeliveries(ctx, userID, id, 50)
+	if err != nil {
+		if h.abandonIfCanceled(ctx, start, "GET", "/webhooks/:id/deliveries") {
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			h.recordRequestMetrics(ctx, start, "GET", "/webhooks/:id/deliveries", http.StatusNotFound)
+			return
+		}
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Error getting webhook deliveries", "error", err, "id", id)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		h.recordRequestMetrics(ctx, start, "GET", "/webhooks/:id/deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	h.recordRequestMetrics(ctx, start, "GET", "/webhooks/:id/deliveries", writeList(w, r, deliveries))
+}
@@ -0,0 +1,412 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-app/internal/requestctx"
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Workspaces serves GET /workspaces and POST /workspaces
+func (h *Handlers) Workspaces(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+		return
+	case "GET":
+		span.SetAttributes(attribute.String("operation", "get_workspaces"))
+		workspaces, err := h.db.GetWorkspaces(ctx)
+		if err != nil {
+			h.workspaceError(ctx, w, start, "GET", "/workspaces", err)
+			return
+		}
+		h.recordRequestMetrics(ctx, start, "GET", "/workspaces", writeList(w, r, workspaces))
+	case "POST":
+		name, ok := decodeWorkspaceName(w, r)
+		if !ok {
+			h.recordRequestMetrics(ctx, start, "POST", "/workspaces", http.StatusBadRequest)
+			return
+		}
+		owner := requestctx.User(ctx)
+		span.SetAttributes(
+			attribute.String("operation", "create_workspace"),
+			attribute.String("workspace.name", name),
+		)
+		slog.InfoContext(ctx, "Creating workspace", "name", name, "owner", owner)
+
+		workspace, err := h.db.CreateWorkspace(ctx, name, owner)
+		if err != nil {
+			h.workspaceError(ctx, w, start, "POST", "/workspaces", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, r, workspace)
+		slog.InfoContext(ctx, "Workspace created", "id", workspace.ID, "name", workspace.Name)
+		h.recordRequestMetrics(ctx, start, "POST", "/workspaces", http.StatusCreated)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Workspace serves GET, PATCH and DELETE /workspaces/{id}
+func (h *Handlers) Workspace(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var operation string
+	switch r.Method {
+	case "GET":
+		operation = "get_workspace"
+	case "PATCH":
+		operation = "rename_workspace"
+	case "DELETE":
+		operation = "delete_workspace"
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	method, endpoint := r.Method, "/workspaces/:id"
+
+	id, ok := h.workspaceID(w, r, start, endpoint)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.String("operation", operation))
+
+	var workspace *store.Workspace
+	var err error
+	switch r.Method {
+	case "GET":
+		workspace, err = h.db.GetWorkspace(ctx, id)
+	case "PATCH":
+		name, ok := decodeWorkspaceName(w, r)
+		if !ok {
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+			return
+		}
+		slog.InfoContext(ctx, "Renaming workspace", "id", id, "name", name)
+		workspace, err = h.db.RenameWorkspace(ctx, id, name)
+	case "DELETE":
+		slog.InfoContext(ctx, "Deleting workspace", "id", id)
+		err = h.db.DeleteWorkspace(ctx, id)
+	}
+	if err != nil {
+		h.workspaceError(ctx, w, start, method, endpoint, err)
+		return
+	}
+
+	if workspace == nil {
+		w.WriteHeader(http.StatusNoContent)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, workspace)
+	h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusOK)
+}
+
+// WorkspaceLists serves GET /workspaces/{id}/lists and POST /workspaces/{id}/lists, which
+// creates a list in the workspace
+func (h *Handlers) WorkspaceLists(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" && r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	method, endpoint := r.Method, "/workspaces/:id/lists"
+
+	id, ok := h.workspaceID(w, r, start, endpoint)
+	if !ok {
+		return
+	}
+
+	if r.Method == "GET" {
+		span.SetAttributes(attribute.String("operation", "get_workspace_lists"))
+		lists, err := h.db.GetWorkspaceLists(ctx, id)
+		if err != nil {
+			h.workspaceError(ctx, w, start, method, endpoint, err)
+			return
+		}
+		h.recordRequestMetrics(ctx, start, method, endpoint, writeList(w, r, lists))
+		return
+	}
+
+	name, ok := store.DecodeListName(w, r)
+	if !ok {
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+		return
+	}
+	span.SetAttributes(
+		attribute.String("operation", "create_list"),
+		attribute.String("list.name", name),
+	)
+	slog.InfoContext(ctx, "Creating list", "name", name, "workspace_id", id)
+
+	list, err := h.db.CreateList(ctx, name, &id)
+	if err != nil {
+		h.workspaceError(ctx, w, start, method, endpoint, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, r, list)
+	slog.InfoContext(ctx, "List created", "id", list.ID, "name", list.Name, "workspace_id", id)
+	h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusCreated)
+}
+
+// WorkspaceMembers serves GET /workspaces/{id}/members
+func (h *Handlers) WorkspaceMembers(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	endpoint := "/workspaces/:id/members"
+
+	id, ok := h.workspaceID(w, r, start, endpoint)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.String("operation", "get_workspace_members"))
+
+	members, err := h.db.GetWorkspaceMembers(ctx, id)
+	if err != nil {
+		h.workspaceError(ctx, w, start, "GET", endpoint, err)
+		return
+	}
+	h.recordRequestMetrics(ctx, start, "GET", endpoint, writeList(w, r, members))
+}
+
+// WorkspaceMember serves PUT and DELETE /workspaces/{id}/members/{user}. PUT adds the user
+// with {"role": "owner" | "member"}, or changes their role.
+func (h *Handlers) WorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "PUT" && r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	method, endpoint := r.Method, "/workspaces/:id/members/:user"
+
+	id, ok := h.workspaceID(w, r, start, endpoint)
+	if !ok {
+		return
+	}
+	userID := strings.TrimSpace(r.PathValue("user"))
+	if userID == "" {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+		return
+	}
+	span.SetAttributes(attribute.String("member.user_id", userID))
+
+	if r.Method == "DELETE" {
+		span.SetAttributes(attribute.String("operation", "remove_workspace_member"))
+		slog.InfoContext(ctx, "Removing workspace member", "id", id, "user_id", userID)
+		if err := h.db.RemoveWorkspaceMember(ctx, id, userID); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Member not found", http.StatusNotFound)
+				h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusNotFound)
+				return
+			}
+			h.workspaceError(ctx, w, start, method, endpoint, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusNoContent)
+		return
+	}
+
+	span.SetAttributes(attribute.String("operation", "save_workspace_member"))
+	member := &store.WorkspaceMember{}
+	if err := json.NewDecoder(r.Body).Decode(member); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+		return
+	}
+	if !store.ValidWorkspaceRole(member.Role) {
+		http.Error(w, "Invalid role, expected "+store.WorkspaceRoleOwner+" or "+store.WorkspaceRoleMember, http.StatusBadRequest)
+		h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+		return
+	}
+	member.WorkspaceID, member.UserID = id, userID
+	slog.InfoContext(ctx, "Saving workspace member", "id", id, "user_id", userID, "role", member.Role)
+	if err := h.db.SaveWorkspaceMember(ctx, member); err != nil {
+		h.workspaceError(ctx, w, start, method, endpoint, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, member)
+	h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusOK)
+}
+
+// WorkspaceSettings serves GET and PUT /workspaces/{id}/settings, the defaults applied to new
+// tasks in every list of a workspace
+func (h *Handlers) WorkspaceSettings(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	h.enableCORS(w)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" && r.Method != "PUT" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	method, endpoint := r.Method, "/workspaces/:id/settings"
+
+	id, ok := h.workspaceID(w, r, start, endpoint)
+	if !ok {
+		return
+	}
+
+	var settings *store.WorkspaceSettings
+	var err error
+	if r.Method == "GET" {
+		span.SetAttributes(attribute.String("operation", "get_workspace_settings"))
+		settings, err = h.db.GetWorkspaceSettings(ctx, id)
+	} else {
+		span.SetAttributes(attribute.String("operation", "save_workspace_settings"))
+		settings = &store.WorkspaceSettings{}
+		if err := json.NewDecoder(r.Body).Decode(settings); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+			return
+		}
+		settings.WorkspaceID = id
+		if err := settings.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusBadRequest)
+			return
+		}
+		slog.InfoContext(ctx, "Saving workspace settings", "id", id,
+			"default_tags", settings.DefaultTags,
+			"reminder_offset_seconds", settings.ReminderOffsetSeconds)
+		err = h.db.SaveWorkspaceSettings(ctx, settings)
+	}
+	if err != nil {
+		h.workspaceError(ctx, w, start, method, endpoint, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, settings)
+	h.recordRequestMetrics(ctx, start, method, endpoint, http.StatusOK)
+}
+
+// workspaceID reads the {id} path value, writing a 400 response if it is not a number
+func (h *Handlers) workspaceID(w http.ResponseWriter, r *http.Request, start time.Time, endpoint string) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		h.recordRequestMetrics(r.Context(), start, r.Method, endpoint, http.StatusBadRequest)
+		return 0, false
+	}
+	trace.SpanFromContext(r.Context()).SetAttributes(attribute.Int("workspace.id", id))
+	return id, true
+}
+
+// workspaceError writes the response for an error from the workspace routes
+func (h *Handlers) workspaceError(ctx context.Context, w http.ResponseWriter, start time.Time, method, endpoint string, err error) {
+	if h.abandonIfCanceled(ctx, start, method, endpoint) {
+		return
+	}
+	status := http.StatusInternalServerError
+	switch {
+	case err == sql.ErrNoRows, errors.Is(err, store.ErrWorkspaceNotFound):
+		status = http.StatusNotFound
+		http.Error(w, "Workspace not found", status)
+	case errors.Is(err, store.ErrWorkspaceNameTaken):
+		status = http.StatusConflict
+		http.Error(w, "A workspace with this name already exists", status)
+	case errors.Is(err, store.ErrListNameTaken):
+		status = http.StatusConflict
+		http.Error(w, "A list with this name already exists", status)
+	case errors.Is(err, store.ErrDefaultWorkspace), errors.Is(err, store.ErrWorkspaceNotEmpty):
+		status = http.StatusConflict
+		http.Error(w, err.Error(), status)
+	default:
+		trace.SpanFromContext(ctx).RecordError(err)
+		slog.ErrorContext(ctx, "Error handling workspace request", "error", err, "endpoint", endpoint)
+		http.Error(w, "Internal server error", status)
+	}
+	h.recordRequestMetrics(ctx, start, method, endpoint, status)
+}
+
+// decodeWorkspaceName reads {"name": ...} from the request body like a list name, writing a
+// 400 response if the name is unusable
+func decodeWorkspaceName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return "", false
+	}
+	name, err := store.NormalizeTitle(req.Name)
+	if err != nil {
+		http.Error(w, "Workspace name is required", http.StatusBadRequest)
+		return "", false
+	}
+	return name, true
+}
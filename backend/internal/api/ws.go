@@ -0,0 +1,193 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/requestctx"
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/websocket"
+)
+
+var (
+	// wsBuffer is how many events a connection may fall behind before it is told to resync
+	wsBuffer = config.EnvInt("TODO_WS_BUFFER", 64)
+	// wsHeartbeatInterval is how often an idle connection gets a heartbeat message
+	wsHeartbeatInterval = config.EnvDuration("TODO_WS_HEARTBEAT_INTERVAL", 30*time.Second)
+)
+
+// wsWriteTimeout bounds each message write, so a stalled client cannot hold a connection open
+const wsWriteTimeout = 10 * time.Second
+
+// Control messages sent on /ws alongside task events
+const (
+	wsMessageReady     = "ready"
+	wsMessageHeartbeat = "heartbeat"
+	// wsMessageResync tells a client that fell behind to catch up with GET /tasks/changes
+	// and reconnect
+	wsMessageResync = "resync"
+)
+
+// wsControlMessage is a message on /ws that is not a task event
+type wsControlMessage struct {
+	Type   string    `json:"type"`
+	Time   time.Time `json:"time"`
+	Events []string  `json:"events,omitempty"`
+}
+
+// wsMetrics are the per-connection instruments for /ws
+type wsMetrics struct {
+	connections metric.Int64UpDownCounter
+	sent        metric.Int64Counter
+	duration    metric.Float64Histogram
+}
+
+func newWSMetrics() *wsMetrics {
+	meter := telemetry.GetMeter()
+	m := &wsMetrics{}
+	m.connections, _ = meter.Int64UpDownCounter("todo_app.ws.connections",
+		metric.WithDescription("Open /ws connections"),
+		metric.WithUnit("1"))
+	m.sent, _ = meter.Int64Counter("todo_app.ws.messages_sent",
+		metric.WithDescription("Messages sent to /ws connections"),
+		metric.WithUnit("1"))
+	m.duration, _ = meter.Float64Histogram("todo_app.ws.connection_duration",
+		metric.WithDescription("How long /ws connections stay open, in seconds"),
+		metric.WithUnit("s"))
+	return m
+}
+
+// TaskEvents serves GET /ws, a WebSocket that pushes task.created, task.completed,
+// task.uncompleted and task.deleted events as they are committed. ?events= limits the connection to a
+// comma-separated subset.
+func (h *Handlers) TaskEvents(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx := r.Context()
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	events := store.BusEvents
+	if v := r.URL.Query().Get("events"); v != "" {
+		events = nil
+		for _, event := range strings.Split(v, ",") {
+			event = strings.TrimSpace(event)
+			if !slices.Contains(store.BusEvents, event) {
+				http.Error(w, "Unknown event "+event+", expected one of "+strings.Join(store.BusEvents, ", "), http.StatusBadRequest)
+				h.recordRequestMetrics(ctx, start, "GET", "/ws", http.StatusBadRequest)
+				return
+			}
+			events = append(events, event)
+		}
+	}
+
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		w.Header().Set("Upgrade", "websocket")
+		http.Error(w, "Expected a WebSocket upgrade", http.StatusUpgradeRequired)
+		h.recordRequestMetrics(ctx, start, "GET", "/ws", http.StatusUpgradeRequired)
+		return
+	}
+
+	server := websocket.Server{
+		// Events are readable by any client of the API, so any origin may connect,
+		// including non-browser clients that send none
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(conn *websocket.Conn) {
+			h.streamTaskEvents(ctx, conn, events)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// streamTaskEvents sends bus events to conn until the client disconnects, falls behind or
+// the bus shuts down
+func (h *Handlers) streamTaskEvents(ctx context.Context, conn *websocket.Conn, events []string) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "ws.connection", trace.WithAttributes(
+		attribute.StringSlice("ws.events", events),
+		attribute.String("user.id", requestctx.User(ctx)),
+	))
+	defer span.End()
+
+	sub := h.events.Subscribe(wsBuffer)
+	defer sub.Close()
+
+	connected := time.Now()
+	h.ws.connections.Add(ctx, 1)
+	slog.InfoContext(ctx, "WebSocket connected", "events", events)
+
+	// The server's read and write timeouts still apply to the hijacked connection
+	conn.SetReadDeadline(time.Time{})
+	send := func(messageType string, v any) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		if err := websocket.JSON.Send(conn, v); err != nil {
+			return err
+		}
+		h.ws.sent.Add(ctx, 1, metric.WithAttributes(attribute.String("type", messageType)))
+		return nil
+	}
+
+	// Clients only listen, but reading is how a close from their side is noticed
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		var msg []byte
+		for websocket.Message.Receive(conn, &msg) == nil {
+		}
+	}()
+
+	heartbeat := time.NewTicker(wsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	sent := 0
+	reason := ""
+	err := send(wsMessageReady, wsControlMessage{Type: wsMessageReady, Time: time.Now().UTC(), Events: events})
+	for err == nil && reason == "" {
+		select {
+		case <-closed:
+			reason = "client_closed"
+		case event, ok := <-sub.C:
+			if !ok {
+				reason = "shutdown"
+				if sub.Lagged() {
+					reason = "lagged"
+					err = send(wsMessageResync, wsControlMessage{Type: wsMessageResync, Time: time.Now().UTC()})
+				}
+				break
+			}
+			if !slices.Contains(events, event.Type) {
+				continue
+			}
+			if err = send(event.Type, event); err == nil {
+				sent++
+			}
+		case <-heartbeat.C:
+			err = send(wsMessageHeartbeat, wsControlMessage{Type: wsMessageHeartbeat, Time: time.Now().UTC()})
+		}
+	}
+	if err != nil {
+		reason = "write_error"
+		span.RecordError(err)
+	}
+	conn.Close()
+
+	duration := time.Since(connected)
+	h.ws.connections.Add(ctx, -1)
+	h.ws.duration.Record(ctx, duration.Seconds(), metric.WithAttributes(attribute.String("reason", reason)))
+	span.SetAttributes(
+		attribute.String("ws.close_reason", reason),
+		attribute.Int("ws.events_sent", sent),
+	)
+	slog.InfoContext(ctx, "WebSocket disconnected", "reason", reason, "events_sent", sent, "duration", duration)
+}
@@ -0,0 +1,96 @@
+// Package config reads the server's settings from TODO_* environment variables and holds the
+// feature flags that gate experimental behavior.
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvString returns the value of key, or def when it is unset or empty
+func EnvString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// EnvInt returns key parsed as a positive integer, or def when it is unset or invalid
+func EnvInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		StderrLogger.Printf("ignoring invalid %s=%q, using %d", key, v, def)
+		return def
+	}
+	return n
+}
+
+// EnvMillis returns key parsed as an integer number of milliseconds, the unit
+// used by the standard OTEL_* environment variables, or def when it is unset or invalid
+func EnvMillis(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	ms, err := strconv.Atoi(v)
+	if err != nil || ms <= 0 {
+		StderrLogger.Printf("ignoring invalid %s=%q, using %s", key, v, def)
+		return def
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// EnvDuration returns key parsed as a Go duration (e.g. "90s", "1h"), or def when it is unset or invalid
+func EnvDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		StderrLogger.Printf("ignoring invalid %s=%q, using %s", key, v, def)
+		return def
+	}
+	return d
+}
+
+// EnvBool returns key parsed as a boolean ("true", "1", "false", "0", ...), or def when it is unset or invalid
+func EnvBool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		StderrLogger.Printf("ignoring invalid %s=%q, using %t", key, v, def)
+		return def
+	}
+	return b
+}
+
+// SplitList splits a comma-separated list, dropping blanks and lowercasing entries
+func SplitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// StderrLogger is used for telemetry pipeline problems. It deliberately bypasses slog,
+// because slog records are themselves exported through the (possibly broken) pipeline.
+var StderrLogger = log.New(os.Stderr, "telemetry: ", log.LstdFlags)
+
+// DemoMode runs a public demo instance: the data is reset on a schedule, writes are limited
+// per client IP, outbound integrations are stubbed and the admin endpoints need the admin
+// token
+var DemoMode = EnvBool("TODO_DEMO", false)
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"todo-app/internal/requestctx"
+)
+
+// Experimental features
+const (
+	// FeatureSQLTagFilter filters GET /tasks?tag= in SQL instead of after loading every task
+	FeatureSQLTagFilter = "sql_tag_filter"
+)
+
+// experimentalFeatures describes the features that can be turned on
+var experimentalFeatures = map[string]string{
+	FeatureSQLTagFilter: "Filter tasks by tag in SQL instead of after loading them",
+}
+
+// EnabledFeatures turns experimental features on for every request
+var EnabledFeatures = SplitList(EnvString("TODO_FEATURES", ""))
+
+// CheckFeatures returns an error naming the first unknown feature
+func CheckFeatures(names []string) error {
+	for _, name := range names {
+		if _, ok := experimentalFeatures[name]; !ok {
+			known := make([]string, 0, len(experimentalFeatures))
+			for k := range experimentalFeatures {
+				known = append(known, k)
+			}
+			slices.Sort(known)
+			return fmt.Errorf("unknown feature %q, expected one of %s", name, strings.Join(known, ", "))
+		}
+	}
+	return nil
+}
+
+// FeatureEnabled reports whether an experimental feature is on, for everyone or for the
+// request ctx belongs to
+func FeatureEnabled(ctx context.Context, name string) bool {
+	if slices.Contains(EnabledFeatures, name) {
+		return true
+	}
+	overrides, _ := requestctx.Features(ctx)
+	return slices.Contains(overrides, name)
+}
@@ -0,0 +1,302 @@
+package integrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/scheduler"
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// attachmentCleanupInterval is how often the content of attachments whose task was purged
+// is deleted
+var attachmentCleanupInterval = config.EnvDuration("TODO_ATTACHMENT_CLEANUP_INTERVAL", time.Hour)
+
+// attachmentCleanupBatch bounds the attachments deleted per run
+const attachmentCleanupBatch = 100
+
+// Attachment storage backends, for TODO_ATTACHMENT_STORAGE
+const (
+	AttachmentStorageLocal = "local"
+	AttachmentStorageS3    = "s3"
+	AttachmentStorageGCS   = "gcs"
+)
+
+var (
+	// ErrBlobNotFound is returned when opening content that is not in the attachment storage
+	ErrBlobNotFound = errors.New("attachment content not found")
+	// ErrSignedURLUnsupported is returned by storage that cannot hand out download URLs, so
+	// the content is served by the API instead
+	ErrSignedURLUnsupported = errors.New("attachment storage does not support signed URLs")
+)
+
+// AttachmentBlob is the content of an attachment to store. SHA256 is the hex digest of
+// Body, which is read from the start and may be read again on retries.
+type AttachmentBlob struct {
+	Body        io.ReadSeeker
+	Size        int64
+	SHA256      string
+	ContentType string
+}
+
+// AttachmentStore keeps the content of attachments by key. Keys are slash-separated and
+// chosen by the caller; the database keeps which key belongs to which attachment.
+type AttachmentStore interface {
+	// Name is the backend, for logs and spans
+	Name() string
+	// Put stores blob under key, replacing anything there
+	Put(ctx context.Context, key string, blob AttachmentBlob) error
+	// Open returns the content under key, ErrBlobNotFound if there is none
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the content under key; deleting content that is not there succeeds
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL that downloads key as filename without credentials until it
+	// expires, ErrSignedURLUnsupported for storage the API serves itself
+	SignedURL(ctx context.Context, key, filename, contentType string) (string, error)
+}
+
+// NewAttachmentStore returns the attachment storage selected by TODO_ATTACHMENT_STORAGE.
+// local keeps files under TODO_ATTACHMENT_DIR. s3 and gcs keep objects in
+// TODO_ATTACHMENT_BUCKET under TODO_ATTACHMENT_PREFIX, with TODO_ATTACHMENT_ACCESS_KEY_ID
+// and TODO_ATTACHMENT_SECRET_ACCESS_KEY (HMAC keys for gcs), and sign download URLs valid
+// for TODO_ATTACHMENT_URL_TTL.
+func NewAttachmentStore() (AttachmentStore, error) {
+	kind := strings.ToLower(config.EnvString("TODO_ATTACHMENT_STORAGE", AttachmentStorageLocal))
+	switch kind {
+	case AttachmentStorageLocal:
+		return NewLocalAttachmentStore()
+	case AttachmentStorageS3, AttachmentStorageGCS:
+		return newCloudAttachmentStore(kind)
+	}
+	return nil, fmt.Errorf("unknown TODO_ATTACHMENT_STORAGE %q, expected local, s3 or gcs", kind)
+}
+
+// NewLocalAttachmentStore returns the store that keeps attachments under TODO_ATTACHMENT_DIR
+func NewLocalAttachmentStore() (AttachmentStore, error) {
+	dir, err := filepath.Abs(config.EnvString("TODO_ATTACHMENT_DIR", "attachments"))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create TODO_ATTACHMENT_DIR: %w", err)
+	}
+	return &localAttachmentStore{dir: dir}, nil
+}
+
+// localAttachmentStore keeps attachments as files under dir. Every replica must see the
+// same directory, so more than one replica needs it on a shared volume.
+type localAttachmentStore struct {
+	dir string
+}
+
+func (s *localAttachmentStore) Name() string { return AttachmentStorageLocal }
+
+// path returns the file of key, refusing keys that would leave dir
+func (s *localAttachmentStore) path(key string) (string, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, s.dir+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid attachment key %q", key)
+	}
+	return path, nil
+}
+
+// Put writes to a temporary file renamed into place, so a failed upload never leaves half
+// a file under key
+func (s *localAttachmentStore) Put(ctx context.Context, key string, blob AttachmentBlob) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	if _, err := blob.Body.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, blob.Body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *localAttachmentStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return f, err
+}
+
+func (s *localAttachmentStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *localAttachmentStore) SignedURL(ctx context.Context, key, filename, contentType string) (string, error) {
+	return "", ErrSignedURLUnsupported
+}
+
+// cloudAttachmentStore keeps attachments in an S3-compatible bucket. Google Cloud Storage
+// is reached through its XML API, which accepts S3 requests signed with HMAC keys.
+type cloudAttachmentStore struct {
+	name   string
+	s3     *s3Client
+	prefix string
+	urlTTL time.Duration
+}
+
+// newCloudAttachmentStore configures the s3 or gcs backend. S3 falls back to the AWS_*
+// variables for its region and keys; GCS defaults to storage.googleapis.com.
+func newCloudAttachmentStore(kind string) (*cloudAttachmentStore, error) {
+	bucket := config.EnvString("TODO_ATTACHMENT_BUCKET", "")
+	if bucket == "" {
+		return nil, fmt.Errorf("TODO_ATTACHMENT_STORAGE=%s needs TODO_ATTACHMENT_BUCKET", kind)
+	}
+
+	region := config.EnvString("TODO_ATTACHMENT_REGION", config.EnvString("AWS_REGION", "us-east-1"))
+	defaultEndpoint := "https://s3." + region + ".amazonaws.com"
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if kind == AttachmentStorageGCS {
+		region = config.EnvString("TODO_ATTACHMENT_REGION", "auto")
+		defaultEndpoint = "https://storage.googleapis.com"
+		accessKey, secretKey = "", ""
+	}
+	endpoint, err := url.Parse(config.EnvString("TODO_ATTACHMENT_ENDPOINT", defaultEndpoint))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid TODO_ATTACHMENT_ENDPOINT: %v", err)
+	}
+	s3 := &s3Client{
+		client:    NewHTTPClient(),
+		endpoint:  endpoint,
+		bucket:    bucket,
+		region:    region,
+		accessKey: config.EnvString("TODO_ATTACHMENT_ACCESS_KEY_ID", accessKey),
+		secretKey: config.EnvString("TODO_ATTACHMENT_SECRET_ACCESS_KEY", secretKey),
+	}
+	if s3.accessKey == "" || s3.secretKey == "" {
+		return nil, fmt.Errorf("TODO_ATTACHMENT_STORAGE=%s needs an access key and secret", kind)
+	}
+
+	urlTTL := config.EnvDuration("TODO_ATTACHMENT_URL_TTL", 15*time.Minute)
+	// SigV4 presigned URLs are valid for at most a week
+	if urlTTL <= 0 || urlTTL > 7*24*time.Hour {
+		return nil, fmt.Errorf("TODO_ATTACHMENT_URL_TTL must be between 1s and 168h, got %s", urlTTL)
+	}
+	return &cloudAttachmentStore{
+		name:   kind,
+		s3:     s3,
+		prefix: strings.Trim(config.EnvString("TODO_ATTACHMENT_PREFIX", ""), "/"),
+		urlTTL: urlTTL,
+	}, nil
+}
+
+func (s *cloudAttachmentStore) Name() string { return s.name }
+
+func (s *cloudAttachmentStore) objectKey(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return s.prefix + "/" + key
+}
+
+func (s *cloudAttachmentStore) Put(ctx context.Context, key string, blob AttachmentBlob) error {
+	if _, err := blob.Body.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	err := s.s3.put(ctx, s.objectKey(key), blob.Body, blob.Size, blob.SHA256, blob.ContentType)
+	recentIntegrations.record(s.name, err)
+	return err
+}
+
+func (s *cloudAttachmentStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	body, err := s.s3.get(ctx, s.objectKey(key))
+	if errors.Is(err, errObjectNotFound) {
+		recentIntegrations.record(s.name, nil)
+		return nil, ErrBlobNotFound
+	}
+	recentIntegrations.record(s.name, err)
+	return body, err
+}
+
+func (s *cloudAttachmentStore) Delete(ctx context.Context, key string) error {
+	err := s.s3.delete(ctx, s.objectKey(key))
+	recentIntegrations.record(s.name, err)
+	return err
+}
+
+// SignedURL signs locally, without a request to the store, so it cannot tell whether the
+// object exists. The URL makes the store answer with the attachment's name and type.
+func (s *cloudAttachmentStore) SignedURL(ctx context.Context, key, filename, contentType string) (string, error) {
+	query := url.Values{}
+	query.Set("response-content-disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	if contentType != "" {
+		query.Set("response-content-type", contentType)
+	}
+	return s.s3.presign(s.objectKey(key), query, s.urlTTL, time.Now().UTC()), nil
+}
+
+// NewAttachmentCleanupJob returns a job that deletes the attachments of purged tasks,
+// content first, so a failed delete leaves the row to be retried on the next run rather
+// than content nothing points to
+func NewAttachmentCleanupJob(db *store.DB, attachments AttachmentStore) scheduler.Job {
+	return scheduler.Job{
+		Name:     "attachment_cleanup",
+		Interval: attachmentCleanupInterval,
+		Run: func(ctx context.Context) error {
+			orphans, err := db.OrphanedAttachments(ctx, attachmentCleanupBatch)
+			if err != nil {
+				return err
+			}
+			trace.SpanFromContext(ctx).SetAttributes(attribute.Int("attachment_cleanup.orphans", len(orphans)))
+			deleted := 0
+			var errs []error
+			for _, a := range orphans {
+				if err := attachments.Delete(ctx, a.StorageKey); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				if err := db.ForgetAttachment(ctx, a.ID); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				deleted++
+			}
+			if deleted > 0 {
+				slog.InfoContext(ctx, "Deleted attachments of purged tasks", "count", deleted, "storage", attachments.Name())
+			}
+			return errors.Join(errs...)
+		},
+	}
+}
@@ -0,0 +1,39 @@
+package integrations
+
+import (
+	"context"
+	"errors"
+
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Replay sends a failed notification again. It is removed once delivered, or kept with
+// the new error and one more attempt.
+func (d *NotificationDispatcher) Replay(ctx context.Context, n *store.FailedNotification) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "notification.replay",
+		trace.WithAttributes(
+			attribute.Int("dead_letter.id", n.ID),
+			attribute.Int("notification.rule_id", n.RuleID),
+			attribute.String("notification.notifier", n.Notifier),
+			attribute.String("notification.event", n.Event),
+			attribute.Int("task.id", n.Task.ID),
+		))
+	defer span.End()
+
+	notifier, err := d.registry.New(n.Notifier, n.Target)
+	if err == nil {
+		err = notifier.Notify(ctx, n.Event, &n.Task)
+	}
+	if err == nil {
+		return d.db.ForgetFailedNotification(ctx, n.ID)
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	return errors.Join(err, d.db.RecordReplayFailure(ctx, n.ID, err))
+}
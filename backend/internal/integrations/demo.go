@@ -0,0 +1,77 @@
+package integrations
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/scheduler"
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// demoResetInterval is how often a demo instance goes back to its sample tasks
+var demoResetInterval = config.EnvDuration("TODO_DEMO_RESET_INTERVAL", time.Hour)
+
+// stubResponse answers an outbound request in demo mode without sending it, as a receiver
+// that accepted it would, so notifications and webhooks look delivered
+func stubResponse(ctx context.Context, req *http.Request) *http.Response {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("demo.stubbed", true))
+	slog.InfoContext(ctx, "Demo mode, outbound request not sent", "method", req.Method, "host", req.URL.Host)
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(strings.NewReader("{}")),
+		ContentLength: 2,
+		Request:       req,
+	}
+}
+
+// NewDemoResetJob returns a job that deletes what demo visitors made and creates the sample
+// tasks again. It runs at startup too, so a demo instance always starts fresh. /ws clients
+// get the deletions and the new tasks as events.
+func NewDemoResetJob(db *store.DB, tasks store.TaskStore, events *store.EventBus) scheduler.Job {
+	return scheduler.Job{
+		Name:     "demo_reset",
+		Interval: demoResetInterval,
+		Run: func(ctx context.Context) error {
+			removed, err := db.ResetDemo(ctx)
+			if err != nil {
+				return err
+			}
+			if memory, ok := tasks.(*store.MemoryStore); ok {
+				removed = memory.Reset()
+			}
+			for _, id := range removed {
+				events.Publish(ctx, store.BusEvent{Type: store.EventTaskDeleted, TaskID: id})
+			}
+
+			for _, demo := range store.DemoTasks(time.Now().UTC()) {
+				task, err := tasks.CreateTask(ctx, demo.NewTask)
+				if err != nil {
+					return err
+				}
+				if demo.Completed {
+					if task, err = tasks.CompleteTask(ctx, task.ID); err != nil {
+						return err
+					}
+				}
+				events.PublishTasks(ctx, store.EventTaskCreated, task)
+			}
+
+			trace.SpanFromContext(ctx).SetAttributes(attribute.Int("demo_reset.removed", len(removed)))
+			slog.InfoContext(ctx, "Reset demo data", "removed", len(removed))
+			return nil
+		},
+	}
+}
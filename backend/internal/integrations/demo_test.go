@@ -0,0 +1,167 @@
+package integrations
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/store"
+)
+
+// dialCountingServer is a test server that counts the connections made to it
+func dialCountingServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	t.Setenv("TODO_EGRESS_ALLOW_PRIVATE", "true")
+	var dials atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			dials.Add(1)
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+	return server, &dials
+}
+
+func setDemoMode(t *testing.T, on bool) {
+	t.Helper()
+	previous := config.DemoMode
+	config.DemoMode = on
+	t.Cleanup(func() { config.DemoMode = previous })
+}
+
+func TestHTTPClientStubsEveryCallInDemoMode(t *testing.T) {
+	server, dials := dialCountingServer(t)
+	setDemoMode(t, true)
+	c := NewHTTPClient()
+
+	for name, do := range map[string]func(context.Context, *http.Request) (*http.Response, error){
+		"Do":                c.Do,
+		"DoWithBodyCapture": c.DoWithBodyCapture,
+	} {
+		req, err := http.NewRequest(http.MethodPut, server.URL+"/bucket/key", strings.NewReader("object"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := do(context.Background(), req)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: status = %d, want the stubbed 200", name, resp.StatusCode)
+		}
+	}
+	if n := dials.Load(); n != 0 {
+		t.Errorf("demo mode dialed the server %d times, want none", n)
+	}
+}
+
+func TestHTTPClientCallsOutsideDemoMode(t *testing.T) {
+	server, dials := dialCountingServer(t)
+	setDemoMode(t, false)
+	c := NewHTTPClient()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Do(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent || dials.Load() == 0 {
+		t.Errorf("status = %d after %d dials, want the server's 204", resp.StatusCode, dials.Load())
+	}
+}
+
+func TestDemoResetJob(t *testing.T) {
+	ctx := context.Background()
+	db, err := store.NewDB(ctx, filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Setup(ctx); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	memory, err := store.NewMemoryStore()
+	if err != nil {
+		t.Fatalf("NewMemoryStore: %v", err)
+	}
+
+	for name, tasks := range map[string]store.TaskStore{"sql": db, "memory": memory} {
+		t.Run(name, func(t *testing.T) {
+			events := store.NewEventBus()
+			var published []store.BusEvent
+			events.OnPublish(func(_ context.Context, event store.BusEvent) {
+				published = append(published, event)
+			})
+			job := NewDemoResetJob(db, tasks, events)
+
+			// At startup, on a database that was just set up, and again once visitors used it
+			for run := range 2 {
+				visitor, err := tasks.CreateTask(ctx, store.NewTask{Title: "Visitor task"})
+				if err != nil {
+					t.Fatalf("CreateTask: %v", err)
+				}
+				if _, err := db.CreateList(ctx, fmt.Sprintf("Visitor list %d", run), nil); err != nil {
+					t.Fatalf("CreateList: %v", err)
+				}
+				published = nil
+				if err := job.Run(ctx); err != nil {
+					t.Fatalf("run %d: %v", run, err)
+				}
+
+				got, err := tasks.GetAllTasks(ctx, store.TaskQuery{})
+				if err != nil {
+					t.Fatalf("GetAllTasks: %v", err)
+				}
+				samples := store.DemoTasks(time.Now())
+				if len(got) != len(samples) {
+					t.Errorf("run %d left %d tasks, want the %d samples", run, len(got), len(samples))
+				}
+				completed := 0
+				for _, task := range got {
+					if task.Title == "Visitor task" {
+						t.Errorf("run %d kept the visitor's task", run)
+					}
+					if task.Completed {
+						completed++
+					}
+				}
+				if completed != 2 {
+					t.Errorf("run %d left %d completed tasks, want the 2 completed samples", run, completed)
+				}
+				lists, err := db.GetLists(ctx)
+				if err != nil {
+					t.Fatalf("GetLists: %v", err)
+				}
+				if len(lists) != 1 {
+					t.Errorf("run %d left %d lists, want only the default", run, len(lists))
+				}
+
+				deleted := slices.IndexFunc(published, func(e store.BusEvent) bool {
+					return e.Type == store.EventTaskDeleted && e.TaskID == visitor.ID
+				})
+				created := slices.IndexFunc(published, func(e store.BusEvent) bool { return e.Type == store.EventTaskCreated })
+				if deleted < 0 || created < deleted {
+					t.Errorf("run %d published %+v, want the visitor task deleted before the samples are created", run, published)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package integrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+// errEgressDenied is returned for outbound requests the egress policy does not allow
+var errEgressDenied = errors.New("egress denied")
+
+// EgressError reports why the egress policy refused a destination
+type EgressError struct {
+	Host   string
+	Reason string
+}
+
+func (e *EgressError) Error() string {
+	return fmt.Sprintf("egress to %s denied: %s", e.Host, e.Reason)
+}
+
+func (e *EgressError) Unwrap() error {
+	return errEgressDenied
+}
+
+// blockedPrefixes are special-purpose ranges netip has no predicate for
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, embeds an IPv4 address
+}
+
+// EgressPolicy decides which destinations the HTTPClient may reach. Notification targets
+// come from users, so without it a webhook could point the server at itself, the cloud
+// metadata endpoint or anything else on the internal network (SSRF).
+//
+// Addresses are checked after DNS resolution and the connection is made to the checked
+// address, so a name cannot resolve to a public address for the check and a private one
+// for the request (DNS rebinding).
+type EgressPolicy struct {
+	// Schemes are the allowed URL schemes
+	Schemes []string
+	// Hosts, when not empty, are the only hosts allowed. "*.example.com" allows subdomains.
+	Hosts []string
+	// AllowPrivate allows loopback, private, link-local and other internal addresses
+	AllowPrivate bool
+
+	denied metric.Int64Counter
+}
+
+// NewEgressPolicy returns the policy configured by TODO_EGRESS_* environment variables
+func NewEgressPolicy() *EgressPolicy {
+	p := &EgressPolicy{
+		Schemes:      config.SplitList(config.EnvString("TODO_EGRESS_ALLOWED_SCHEMES", "https,http")),
+		Hosts:        config.SplitList(config.EnvString("TODO_EGRESS_ALLOWED_HOSTS", "")),
+		AllowPrivate: config.EnvBool("TODO_EGRESS_ALLOW_PRIVATE", false),
+	}
+	p.denied, _ = telemetry.GetMeter().Int64Counter("todo_app.egress.denied",
+		metric.WithDescription("Outbound requests refused by the egress policy"),
+		metric.WithUnit("1"))
+	return p
+}
+
+// CheckURL checks what can be known about a URL without resolving it: its scheme, its host
+// against the allow-list and, for IP literals, the address
+func (p *EgressPolicy) CheckURL(u *url.URL) error {
+	host := strings.ToLower(u.Hostname())
+	scheme := strings.ToLower(u.Scheme)
+	allowed := false
+	for _, s := range p.Schemes {
+		allowed = allowed || s == scheme
+	}
+	if !allowed {
+		return &EgressError{Host: host, Reason: fmt.Sprintf("scheme %q is not allowed", u.Scheme)}
+	}
+	if !p.hostAllowed(host) {
+		return &EgressError{Host: host, Reason: "host is not on the allow-list"}
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return p.checkAddr(host, ip)
+	}
+	if !p.AllowPrivate && (host == "localhost" || strings.HasSuffix(host, ".localhost")) {
+		return &EgressError{Host: host, Reason: "loopback address"}
+	}
+	return nil
+}
+
+func (p *EgressPolicy) hostAllowed(host string) bool {
+	if len(p.Hosts) == 0 {
+		return true
+	}
+	for _, allowed := range p.Hosts {
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAddr refuses internal addresses unless AllowPrivate is set
+func (p *EgressPolicy) checkAddr(host string, ip netip.Addr) error {
+	if p.AllowPrivate {
+		return nil
+	}
+	ip = ip.Unmap()
+	var reason string
+	switch {
+	case ip.IsLoopback():
+		reason = "loopback address"
+	case ip.IsPrivate():
+		reason = "private address"
+	case ip.IsLinkLocalUnicast(), ip.IsLinkLocalMulticast():
+		reason = "link-local address"
+	case ip.IsUnspecified(), ip.IsMulticast(), ip.IsInterfaceLocalMulticast():
+		reason = "non-unicast address"
+	default:
+		for _, prefix := range blockedPrefixes {
+			if prefix.Contains(ip) {
+				reason = "reserved address"
+				break
+			}
+		}
+	}
+	if reason != "" {
+		return &EgressError{Host: host, Reason: fmt.Sprintf("%s %s", reason, ip)}
+	}
+	return nil
+}
+
+// DialContext resolves addr, refuses it if any of its addresses is internal, and connects
+// to the checked addresses directly so the connection goes where the check looked
+func (p *EgressPolicy) DialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, portStr, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		port, err := strconv.ParseUint(portStr, 10, 16)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			if err := p.checkAddr(host, ip); err != nil {
+				p.recordDenied(ctx, err)
+				return nil, err
+			}
+		}
+
+		var lastErr error
+		for _, ip := range ips {
+			conn, err := dialer.DialContext(ctx, network, netip.AddrPortFrom(ip.Unmap(), uint16(port)).String())
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+}
+
+// CheckRedirect applies CheckURL to every redirect, so an allowed host cannot bounce the
+// request somewhere that is not
+func (p *EgressPolicy) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if err := p.CheckURL(req.URL); err != nil {
+		p.recordDenied(req.Context(), err)
+		return err
+	}
+	return nil
+}
+
+func (p *EgressPolicy) recordDenied(ctx context.Context, err error) {
+	var egressErr *EgressError
+	if !errors.As(err, &egressErr) {
+		return
+	}
+	if p.denied != nil {
+		p.denied.Add(ctx, 1)
+	}
+	RecordSecurityEvent(ctx, "egress.denied", 6, map[string]string{"host": egressErr.Host, "reason": egressErr.Reason})
+}
@@ -0,0 +1,412 @@
+package integrations
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Built-in email templates. Each email NAME has a NAME.txt.tmpl plaintext template, which
+// also defines the "subject" block, and optionally a NAME.html.tmpl that defines the
+// "content" block rendered inside layout.html.tmpl.
+//
+//go:embed templates/email/*.tmpl
+var defaultEmailTemplates embed.FS
+
+// Email template names
+const (
+	EmailReminder  = "reminder"
+	EmailTaskEvent = "task_event"
+	EmailDigest    = "digest"
+	// EmailHealthReport is the telemetry health report sent to operators
+	EmailHealthReport = "health_report"
+)
+
+// EmailTheme holds the values templates use for branding
+type EmailTheme struct {
+	ProductName     string
+	AccentColor     string
+	BackgroundColor string
+	FontFamily      string
+}
+
+func LoadEmailTheme() EmailTheme {
+	return EmailTheme{
+		ProductName:     config.EnvString("TODO_EMAIL_PRODUCT_NAME", "TODO App"),
+		AccentColor:     config.EnvString("TODO_EMAIL_ACCENT_COLOR", "#3498db"),
+		BackgroundColor: config.EnvString("TODO_EMAIL_BACKGROUND_COLOR", "#f4f6f8"),
+		FontFamily:      config.EnvString("TODO_EMAIL_FONT_FAMILY", "Helvetica, Arial, sans-serif"),
+	}
+}
+
+// EmailData is what every email template is executed with. Reminders use Task,
+// digests use Tasks, the health report uses Report. Subject is set once the subject block has been rendered, so the
+// HTML layout can use it.
+type EmailData struct {
+	Theme       EmailTheme
+	Subject     string
+	Event       string
+	Task        *store.Task
+	Tasks       []store.Task
+	Report      *telemetry.HealthReport
+	GeneratedAt time.Time
+}
+
+// Email is a rendered message. HTML is empty for text-only templates.
+type Email struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// EmailTemplates renders the built-in templates, with any file of the same name in an
+// override directory taking precedence, so deployments can restyle emails without a rebuild
+type EmailTemplates struct {
+	theme EmailTheme
+	text  map[string]*texttemplate.Template
+	html  map[string]*htmltemplate.Template
+}
+
+var emailTemplateFuncs = map[string]any{
+	"formatTime": func(t any) string {
+		switch v := t.(type) {
+		case time.Time:
+			return v.UTC().Format("Mon, 02 Jan 2006 15:04 MST")
+		case *time.Time:
+			if v == nil {
+				return ""
+			}
+			return v.UTC().Format("Mon, 02 Jan 2006 15:04 MST")
+		}
+		return fmt.Sprint(t)
+	},
+	"percent": func(f float64) string {
+		return fmt.Sprintf("%.2f%%", f*100)
+	},
+}
+
+// LoadEmailTemplates parses the built-in templates and the overrides found in dir,
+// which may be empty to use the built-in templates only
+func LoadEmailTemplates(dir string, theme EmailTheme) (*EmailTemplates, error) {
+	sources := []fs.FS{mustSub(defaultEmailTemplates, "templates/email")}
+	if dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("email template directory: %w", err)
+		}
+		// Later sources win
+		sources = append(sources, os.DirFS(dir))
+	}
+
+	read := func(name string) ([]byte, bool, error) {
+		for i := len(sources) - 1; i >= 0; i-- {
+			b, err := fs.ReadFile(sources[i], name)
+			if err == nil {
+				return b, true, nil
+			}
+			if !os.IsNotExist(err) {
+				return nil, false, err
+			}
+		}
+		return nil, false, nil
+	}
+
+	names := map[string]bool{}
+	for _, source := range sources {
+		matches, err := fs.Glob(source, "*.txt.tmpl")
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			names[strings.TrimSuffix(match, ".txt.tmpl")] = true
+		}
+	}
+
+	layout, _, err := read("layout.html.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	t := &EmailTemplates{
+		theme: theme,
+		text:  map[string]*texttemplate.Template{},
+		html:  map[string]*htmltemplate.Template{},
+	}
+	for name := range names {
+		src, _, err := read(name + ".txt.tmpl")
+		if err != nil {
+			return nil, err
+		}
+		textTmpl, err := texttemplate.New(name).Funcs(emailTemplateFuncs).Parse(string(src))
+		if err != nil {
+			return nil, fmt.Errorf("parse %s.txt.tmpl: %w", name, err)
+		}
+		if textTmpl.Lookup("subject") == nil {
+			return nil, fmt.Errorf("%s.txt.tmpl does not define a subject", name)
+		}
+		t.text[name] = textTmpl
+
+		src, ok, err := read(name + ".html.tmpl")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		htmlTmpl, err := htmltemplate.New(name).Funcs(emailTemplateFuncs).Parse(string(layout))
+		if err == nil {
+			htmlTmpl, err = htmlTmpl.Parse(string(src))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse %s.html.tmpl: %w", name, err)
+		}
+		t.html[name] = htmlTmpl
+	}
+
+	return t, nil
+}
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// Names lists the available templates
+func (t *EmailTemplates) Names() []string {
+	names := make([]string, 0, len(t.text))
+	for name := range t.text {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render executes the named template. data.Theme and data.GeneratedAt are filled in.
+func (t *EmailTemplates) Render(name string, data EmailData) (*Email, error) {
+	textTmpl, ok := t.text[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown email template %q", name)
+	}
+	data.Theme = t.theme
+	if data.GeneratedAt.IsZero() {
+		data.GeneratedAt = time.Now()
+	}
+
+	var subject, text bytes.Buffer
+	if err := textTmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := textTmpl.Execute(&text, data); err != nil {
+		return nil, err
+	}
+	email := &Email{Subject: strings.TrimSpace(subject.String()), Text: text.String()}
+	data.Subject = email.Subject
+
+	if htmlTmpl, ok := t.html[name]; ok {
+		var html bytes.Buffer
+		if err := htmlTmpl.ExecuteTemplate(&html, "layout", data); err != nil {
+			return nil, err
+		}
+		email.HTML = html.String()
+	}
+
+	return email, nil
+}
+
+// Mailer renders templates and delivers them over SMTP
+type Mailer struct {
+	templates *EmailTemplates
+	addr      string
+	auth      smtp.Auth
+	from      string
+	to        []string
+}
+
+// NewMailer configures delivery from TODO_SMTP_* and TODO_EMAIL_* variables. Without
+// TODO_SMTP_ADDR and TODO_EMAIL_TO, emails are rendered but not sent.
+func NewMailer(templates *EmailTemplates) *Mailer {
+	m := &Mailer{
+		templates: templates,
+		addr:      config.EnvString("TODO_SMTP_ADDR", ""),
+		from:      config.EnvString("TODO_EMAIL_FROM", "todo-app@localhost"),
+	}
+	m.to = emailList(config.EnvString("TODO_EMAIL_TO", ""))
+	if user := config.EnvString("TODO_SMTP_USERNAME", ""); user != "" && m.addr != "" {
+		host, _, _ := strings.Cut(m.addr, ":")
+		m.auth = smtp.PlainAuth("", user, config.EnvString("TODO_SMTP_PASSWORD", ""), host)
+	}
+	return m
+}
+
+// emailList splits a comma-separated list of recipients
+func emailList(s string) []string {
+	var to []string
+	for _, addr := range strings.Split(s, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	return to
+}
+
+// Enabled reports whether the mailer has an SMTP server and default recipients
+func (m *Mailer) Enabled() bool {
+	return m.addr != "" && len(m.to) > 0
+}
+
+// Send renders the named template and delivers it to the configured recipients
+func (m *Mailer) Send(ctx context.Context, name string, data EmailData) error {
+	return m.SendTo(ctx, m.to, name, data)
+}
+
+// SendTo renders the named template and delivers it to the given recipients as a
+// multipart/alternative message
+func (m *Mailer) SendTo(ctx context.Context, to []string, name string, data EmailData) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "email.send",
+		trace.WithAttributes(
+			attribute.String("email.template", name),
+			attribute.Int("email.recipients", len(to)),
+		))
+	defer span.End()
+
+	if config.DemoMode {
+		slog.InfoContext(ctx, "Demo mode, email not sent", "template", name)
+		span.SetAttributes(attribute.Bool("demo.stubbed", true))
+		return nil
+	}
+	if m.addr == "" || len(to) == 0 {
+		slog.DebugContext(ctx, "Email delivery not configured, skipping", "template", name)
+		span.SetAttributes(attribute.Bool("email.skipped", true))
+		return nil
+	}
+
+	email, err := m.templates.Render(name, data)
+	if err == nil {
+		var msg []byte
+		if msg, err = buildEmailMessage(m.from, to, email); err == nil {
+			err = smtp.SendMail(m.addr, m.auth, m.from, to, msg)
+			recentIntegrations.record(integrationSMTP, err)
+		}
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		slog.ErrorContext(ctx, "Failed to send email", "template", name, "error", err)
+		return err
+	}
+
+	slog.InfoContext(ctx, "Email sent", "template", name, "subject", email.Subject)
+	return nil
+}
+
+// buildEmailMessage encodes email as RFC 5322 message with a plaintext part and,
+// when the template has one, an HTML alternative
+func buildEmailMessage(from string, to []string, email *Email) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\n",
+		from, strings.Join(to, ", "), mime.QEncoding.Encode("utf-8", email.Subject), time.Now().Format(time.RFC1123Z))
+
+	if email.HTML == "" {
+		b.WriteString("Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQuotedPrintable(&b, email.Text); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+
+	w := multipart.NewWriter(&b)
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", w.Boundary())
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", email.Text},
+		{"text/html; charset=utf-8", email.HTML},
+	} {
+		pw, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(pw, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, s string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(s)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// emailNotifier sends task events as templated emails, to the given recipient or the
+// mailer's configured recipients
+type emailNotifier struct {
+	mailer *Mailer
+	to     []string
+}
+
+func newEmailNotifier(mailer *Mailer, target string) (Notifier, error) {
+	n := emailNotifier{mailer: mailer, to: mailer.to}
+	if target != "" {
+		addr, err := mail.ParseAddress(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid email address %q: %w", target, err)
+		}
+		n.to = []string{addr.Address}
+	}
+	return n, nil
+}
+
+func (emailNotifier) Name() string {
+	return "email"
+}
+
+func (n emailNotifier) Notify(ctx context.Context, event string, task *store.Task) error {
+	name := EmailReminder
+	if event != store.EventTaskDue {
+		name = EmailTaskEvent
+	}
+	return n.mailer.SendTo(ctx, n.to, name, EmailData{Event: event, Task: task})
+}
+
+// NotifyDigest sends a batch of notifications as one digest email
+func (n emailNotifier) NotifyDigest(ctx context.Context, items []NotificationItem) error {
+	tasks := make([]store.Task, len(items))
+	for i := range items {
+		tasks[i] = items[i].Task
+	}
+	return n.mailer.SendTo(ctx, n.to, EmailDigest, EmailData{Event: digestEvent(items), Tasks: tasks, GeneratedAt: time.Now()})
+}
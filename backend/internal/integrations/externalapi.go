@@ -0,0 +1,204 @@
+package integrations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ExternalAPIConfig is where the external notifier reports task events. It is read from the
+// JSON file named by TODO_EXTERNAL_API_CONFIG, if any, and then from TODO_EXTERNAL_API_*
+// variables, which take precedence.
+type ExternalAPIConfig struct {
+	URL    string `json:"url"`
+	Method string `json:"method"`
+	// Headers are added to every call, e.g. an API key
+	Headers map[string]string `json:"headers"`
+	// Timeout bounds a call, as a Go duration in the file
+	Timeout time.Duration `json:"-"`
+	// Mock logs what would be sent instead of calling out, for development
+	Mock bool `json:"mock"`
+}
+
+// LoadExternalAPIConfig reads the external API configuration and checks it
+func LoadExternalAPIConfig() (ExternalAPIConfig, error) {
+	cfg := ExternalAPIConfig{
+		URL:     "https://httpbin.org/get",
+		Method:  http.MethodGet,
+		Headers: map[string]string{},
+		Timeout: 10 * time.Second,
+	}
+
+	if path := os.Getenv("TODO_EXTERNAL_API_CONFIG"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		var file struct {
+			ExternalAPIConfig
+			Timeout string `json:"timeout"`
+		}
+		file.ExternalAPIConfig = cfg
+		if err := json.Unmarshal(data, &file); err != nil {
+			return cfg, fmt.Errorf("%s: %w", path, err)
+		}
+		cfg = file.ExternalAPIConfig
+		if file.Timeout != "" {
+			if cfg.Timeout, err = time.ParseDuration(file.Timeout); err != nil {
+				return cfg, fmt.Errorf("%s: invalid timeout %q", path, file.Timeout)
+			}
+		}
+	}
+
+	cfg.URL = config.EnvString("TODO_EXTERNAL_API_URL", cfg.URL)
+	cfg.Method = strings.ToUpper(config.EnvString("TODO_EXTERNAL_API_METHOD", cfg.Method))
+	cfg.Timeout = config.EnvDuration("TODO_EXTERNAL_API_TIMEOUT", cfg.Timeout)
+	cfg.Mock = config.EnvBool("TODO_EXTERNAL_API_MOCK", cfg.Mock)
+	if cfg.Headers == nil {
+		cfg.Headers = map[string]string{}
+	}
+	for _, header := range config.SplitList(os.Getenv("TODO_EXTERNAL_API_HEADERS")) {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return cfg, fmt.Errorf("invalid header %q, expected Name:value", header)
+		}
+		cfg.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	if u, err := url.Parse(cfg.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return cfg, fmt.Errorf("invalid URL %q", cfg.URL)
+	}
+	switch cfg.Method {
+	case http.MethodGet, http.MethodPost, http.MethodPut:
+	default:
+		return cfg, fmt.Errorf("unsupported method %q, expected GET, POST or PUT", cfg.Method)
+	}
+	if cfg.Timeout <= 0 {
+		return cfg, fmt.Errorf("timeout must be positive")
+	}
+	return cfg, nil
+}
+
+// externalAPINotifier reports task events to the configured external API. A GET sends the
+// event in the query string; POST and PUT send it as a JSON body.
+type externalAPINotifier struct {
+	client *HTTPClient
+	config ExternalAPIConfig
+}
+
+func (externalAPINotifier) Name() string {
+	return "external"
+}
+
+func (n externalAPINotifier) Notify(ctx context.Context, event string, task *store.Task) error {
+	target, _ := url.Parse(n.config.URL)
+
+	// Create a new span for the external API call
+	ctx, span := telemetry.GetTracer().Start(ctx, "external.api.notification",
+		trace.WithAttributes(
+			attribute.String("api.service", target.Host),
+			attribute.Bool("api.mock", n.config.Mock),
+			attribute.String("notification.event", event),
+			attribute.Int("task.id", task.ID),
+			attribute.String("task.title", task.Title),
+		))
+	defer span.End()
+
+	// Prepare the request with properly encoded parameters
+	params := url.Values{}
+	params.Add("task_id", fmt.Sprintf("%d", task.ID))
+	params.Add("task_title", task.Title)
+	params.Add("event", event)
+	var body []byte
+	if n.config.Method == http.MethodGet {
+		query := target.Query()
+		for k, v := range params {
+			query[k] = v
+		}
+		target.RawQuery = query.Encode()
+	} else {
+		body, _ = json.Marshal(map[string]any{"task_id": task.ID, "task_title": task.Title, "event": event})
+	}
+
+	if n.config.Mock {
+		slog.InfoContext(ctx, "Mock external API call",
+			"method", n.config.Method,
+			"url", target.String(),
+			"body", string(body),
+			"task_id", task.ID,
+			"event", event)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, n.config.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, n.config.Method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "Failed to create external API request", "error", err)
+		return err
+	}
+
+	// Add custom headers
+	req.Header.Set("X-Task-ID", fmt.Sprintf("%d", task.ID))
+	req.Header.Set("X-Task-Title", task.Title)
+	req.Header.Set("X-Task-Event", event)
+	req.Header.Set("User-Agent", "todo-app/1.0")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range n.config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	// Make the request with body capture
+	resp, err := n.client.DoWithBodyCapture(ctx, req)
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			slog.WarnContext(ctx, "External API call timed out", "timeout", n.config.Timeout, "error", err)
+			recentIntegrations.record(integrationExternal, err)
+			return err
+		}
+		if ctx.Err() != nil {
+			// The caller gave up, which says nothing about the API
+			slog.WarnContext(ctx, "External API call abandoned, request context is done", "error", err)
+			return err
+		}
+		slog.ErrorContext(ctx, "External API call failed", "error", err)
+		recentIntegrations.record(integrationExternal, err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		slog.WarnContext(ctx, "External API returned non-success status",
+			"task_id", task.ID,
+			"status_code", resp.StatusCode)
+		err := fmt.Errorf("external API returned status %d", resp.StatusCode)
+		recentIntegrations.record(integrationExternal, err)
+		return err
+	}
+	recentIntegrations.record(integrationExternal, nil)
+
+	slog.InfoContext(ctx, "Successfully notified external API",
+		"task_id", task.ID,
+		"event", event,
+		"status_code", resp.StatusCode)
+	return nil
+}
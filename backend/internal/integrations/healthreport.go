@@ -0,0 +1,37 @@
+package integrations
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/scheduler"
+	"todo-app/internal/telemetry"
+)
+
+var (
+	HealthReportInterval = config.EnvDuration("TODO_HEALTH_REPORT_INTERVAL", 7*24*time.Hour)
+	HealthReportTo       = emailList(config.EnvString("TODO_HEALTH_REPORT_TO", ""))
+)
+
+// NewHealthReportJob returns a job that emails the telemetry health report to the
+// TODO_HEALTH_REPORT_TO recipients every TODO_HEALTH_REPORT_INTERVAL. It runs on every
+// replica, since each reports on itself. The run at startup, and any run with nothing to
+// report, sends nothing.
+func NewHealthReportJob(stats *telemetry.HealthStats, mailer *Mailer, to []string) scheduler.Job {
+	return scheduler.Job{
+		Name:         "health_report",
+		Interval:     HealthReportInterval,
+		EveryReplica: true,
+		Run: func(ctx context.Context) error {
+			report := stats.Report()
+			if report.Empty() {
+				return nil
+			}
+			slog.InfoContext(ctx, "Sending telemetry health report",
+				"requests", report.Requests, "errors", report.Errors, "p95_ms", report.P95Ms)
+			return mailer.SendTo(ctx, to, EmailHealthReport, EmailData{Report: report, GeneratedAt: report.To})
+		},
+	}
+}
@@ -0,0 +1,254 @@
+package integrations
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/store"
+	"todo-app/openapi"
+)
+
+// githubHook creates a task for each opened GitHub issue and completes or reopens it with
+// the issue. Other events, such as ping, are ignored.
+type githubHook struct{}
+
+var githubIssuesSchema = &openapi.Schema{
+	Type:     "object",
+	Required: []string{"action", "issue", "repository"},
+	Properties: map[string]*openapi.Schema{
+		"action": {Type: "string"},
+		"issue": {
+			Type:     "object",
+			Required: []string{"number", "title", "html_url"},
+			Properties: map[string]*openapi.Schema{
+				"number":   {Type: "integer"},
+				"title":    {Type: "string"},
+				"body":     {Type: "string", Nullable: true},
+				"html_url": {Type: "string"},
+				"labels": {
+					Type: "array",
+					Items: &openapi.Schema{
+						Type:       "object",
+						Required:   []string{"name"},
+						Properties: map[string]*openapi.Schema{"name": {Type: "string"}},
+					},
+				},
+			},
+		},
+		"repository": {
+			Type:       "object",
+			Required:   []string{"full_name"},
+			Properties: map[string]*openapi.Schema{"full_name": {Type: "string"}},
+		},
+	},
+}
+
+func (githubHook) Verify(header http.Header, body, secret []byte) error {
+	sig, ok := strings.CutPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+	if !ok {
+		return errors.New("missing X-Hub-Signature-256")
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return errors.New("malformed X-Hub-Signature-256")
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+func (githubHook) Event(header http.Header, body []byte) (string, string, error) {
+	event, delivery := header.Get("X-GitHub-Event"), header.Get("X-GitHub-Delivery")
+	if event == "" || delivery == "" {
+		return "", "", errors.New("Missing X-GitHub-Event or X-GitHub-Delivery")
+	}
+	return event, delivery, nil
+}
+
+func (githubHook) Schema(event string) *openapi.Schema {
+	if event == "issues" {
+		return githubIssuesSchema
+	}
+	return nil
+}
+
+func (githubHook) Parse(event string, body []byte) ([]store.HookAction, error) {
+	var payload struct {
+		Action string `json:"action"`
+		Issue  struct {
+			Number  int    `json:"number"`
+			Title   string `json:"title"`
+			Body    string `json:"body"`
+			HTMLURL string `json:"html_url"`
+			Labels  []struct {
+				Name string `json:"name"`
+			} `json:"labels"`
+		} `json:"issue"`
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	externalID := payload.Repository.FullName + "#" + strconv.Itoa(payload.Issue.Number)
+
+	switch payload.Action {
+	case "opened":
+		title, err := store.NormalizeTitle(payload.Issue.Title)
+		if err != nil {
+			return nil, err
+		}
+		description, err := store.NormalizeDescription(strings.TrimSpace(payload.Issue.Body + "\n\n" + payload.Issue.HTMLURL))
+		if err != nil {
+			return nil, err
+		}
+		// Labels that are not valid tags are left off rather than refusing the issue
+		var tags store.Tags
+		for _, label := range payload.Issue.Labels {
+			if tag, err := store.NormalizeTag(label.Name); err == nil && !tags.Has(tag) && len(tags) < store.MaxTagsPerTask {
+				tags = append(tags, tag)
+			}
+		}
+		if tags, err = store.NormalizeTags(tags); err != nil {
+			return nil, err
+		}
+		return []store.HookAction{{Op: store.HookCreate, ExternalID: externalID, Task: store.NewTask{Title: title, Description: description, Tags: tags}}}, nil
+	case "closed":
+		return []store.HookAction{{Op: store.HookComplete, ExternalID: externalID}}, nil
+	case "reopened":
+		return []store.HookAction{{Op: store.HookReopen, ExternalID: externalID}}, nil
+	}
+	return nil, nil
+}
+
+// testHook is a Stripe-style provider for trying out integrations: the body names its own
+// event and ID, and the Test-Signature header is "t=<unix seconds>,v1=<hex>", signed the
+// same way as outgoing webhooks (see SignWebhook).
+type testHook struct{}
+
+var testHookSchema = &openapi.Schema{
+	Type:     "object",
+	Required: []string{"id", "type", "data"},
+	Properties: map[string]*openapi.Schema{
+		"id":   {Type: "string"},
+		"type": {Type: "string", Enum: []any{"task.create", "task.complete", "task.reopen"}},
+		"data": {
+			Type:     "object",
+			Required: []string{"object"},
+			Properties: map[string]*openapi.Schema{
+				"object": {
+					Type:     "object",
+					Required: []string{"id"},
+					Properties: map[string]*openapi.Schema{
+						"id":          {Type: "string"},
+						"title":       {Type: "string"},
+						"description": {Type: "string"},
+						"due_at":      {Type: "string", Format: "date-time", Nullable: true},
+						"tags":        {Type: "array", Items: &openapi.Schema{Type: "string"}},
+					},
+				},
+			},
+		},
+	},
+}
+
+func (testHook) Verify(header http.Header, body, secret []byte) error {
+	var timestamp, sig string
+	for _, part := range strings.Split(header.Get("Test-Signature"), ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			timestamp = v
+		case "v1":
+			sig = v
+		}
+	}
+	if timestamp == "" || sig == "" {
+		return errors.New("missing or malformed Test-Signature")
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("malformed Test-Signature timestamp")
+	}
+	if skew := time.Since(time.Unix(seconds, 0)); skew > SignatureMaxSkew || skew < -SignatureMaxSkew {
+		return errors.New("timestamp outside the allowed skew")
+	}
+	if !hmac.Equal([]byte(sig), []byte(SignWebhook(secret, timestamp, body))) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+func (testHook) Event(header http.Header, body []byte) (string, string, error) {
+	var envelope struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return "", "", errors.New("Invalid JSON")
+	}
+	if envelope.ID == "" || envelope.Type == "" {
+		return "", "", errors.New("Missing id or type")
+	}
+	return envelope.Type, envelope.ID, nil
+}
+
+func (testHook) Schema(event string) *openapi.Schema {
+	return testHookSchema
+}
+
+func (testHook) Parse(event string, body []byte) ([]store.HookAction, error) {
+	var payload struct {
+		Data struct {
+			Object struct {
+				ID          string     `json:"id"`
+				Title       string     `json:"title"`
+				Description string     `json:"description"`
+				DueAt       *time.Time `json:"due_at"`
+				Tags        []string   `json:"tags"`
+			} `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	obj := payload.Data.Object
+
+	switch event {
+	case "task.create":
+		title, err := store.NormalizeTitle(obj.Title)
+		if err != nil {
+			return nil, err
+		}
+		description, err := store.NormalizeDescription(obj.Description)
+		if err != nil {
+			return nil, err
+		}
+		tags, err := store.NormalizeTags(obj.Tags)
+		if err != nil {
+			return nil, err
+		}
+		return []store.HookAction{{Op: store.HookCreate, ExternalID: obj.ID, Task: store.NewTask{Title: title, Description: description, DueAt: obj.DueAt, Tags: tags}}}, nil
+	case "task.complete":
+		return []store.HookAction{{Op: store.HookComplete, ExternalID: obj.ID}}, nil
+	case "task.reopen":
+		return []store.HookAction{{Op: store.HookReopen, ExternalID: obj.ID}}, nil
+	}
+	return nil, fmt.Errorf("unsupported event %q", event)
+}
+
+// SignatureMaxSkew is how far a signed request's timestamp may be from the server clock
+var SignatureMaxSkew = config.EnvDuration("TODO_SIGNATURE_MAX_SKEW", 5*time.Minute)
@@ -0,0 +1,94 @@
+package integrations
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"todo-app/internal/config"
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+	"todo-app/openapi"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// HookSecrets are the shared secrets of the inbound hook providers as comma-separated
+// provider:secret pairs. Only providers with a secret are served.
+var HookSecrets = config.EnvString("TODO_HOOK_SECRETS", "")
+
+// MaxHookBody bounds an inbound hook payload
+const MaxHookBody = 1 << 20
+
+// HookProvider receives events from one external system on /hooks/{provider}. Each step of
+// a delivery is a method, so the receiver can tell a forged request from a malformed one.
+type HookProvider interface {
+	// Verify checks the request's signature over body with the shared secret
+	Verify(header http.Header, body, secret []byte) error
+	// Event returns the event type and the provider's ID for this delivery, which stays the
+	// same when the provider redelivers it
+	Event(header http.Header, body []byte) (event, deliveryID string, err error)
+	// Schema is what the payload of event must look like, nil for events that are ignored
+	Schema(event string) *openapi.Schema
+	// Parse turns a payload that matched the schema into the task changes it asks for
+	Parse(event string, body []byte) ([]store.HookAction, error)
+}
+
+// HookReceiver holds the configured hook providers and their secrets
+type HookReceiver struct {
+	providers map[string]HookProvider
+	secrets   map[string][]byte
+	received  metric.Int64Counter
+}
+
+// NewHookReceiver serves the built-in providers that have a secret in secrets, given as
+// comma-separated provider:secret pairs
+func NewHookReceiver(secrets string) (*HookReceiver, error) {
+	r := &HookReceiver{
+		providers: map[string]HookProvider{
+			"github": githubHook{},
+			"test":   testHook{},
+		},
+		secrets: map[string][]byte{},
+	}
+	for _, entry := range config.SplitList(secrets) {
+		name, secret, ok := strings.Cut(entry, ":")
+		if !ok || secret == "" {
+			return nil, fmt.Errorf("invalid hook secret %q, expected provider:secret", name)
+		}
+		if _, known := r.providers[name]; !known {
+			return nil, fmt.Errorf("unknown hook provider %q, expected one of %s", name, strings.Join(r.Names(), ", "))
+		}
+		r.secrets[name] = []byte(secret)
+	}
+	r.received, _ = telemetry.GetMeter().Int64Counter("todo_app.hooks.received",
+		metric.WithDescription("Inbound hook deliveries, by provider and outcome"),
+		metric.WithUnit("1"))
+	return r, nil
+}
+
+// Names returns the built-in provider names, sorted
+func (r *HookReceiver) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Provider returns a provider that is configured with a secret
+func (r *HookReceiver) Provider(name string) (HookProvider, []byte, bool) {
+	p, ok := r.providers[name]
+	secret, configured := r.secrets[name]
+	return p, secret, ok && configured
+}
+
+// CountReceived counts a delivery to provider by outcome: applied, ignored, duplicate, or
+// why it was rejected
+func (r *HookReceiver) CountReceived(ctx context.Context, provider, outcome string) {
+	r.received.Add(ctx, 1, metric.WithAttributes(attribute.String("provider", provider), attribute.String("outcome", outcome)))
+}
@@ -0,0 +1,168 @@
+package integrations
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/requestctx"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HTTPClient wraps http.Client with OpenTelemetry instrumentation and an egress policy
+type HTTPClient struct {
+	client *http.Client
+	policy *EgressPolicy
+	// stub answers every call without sending it, in demo mode
+	stub bool
+}
+
+// NewHTTPClient creates a new instrumented HTTP client restricted by the configured egress policy
+func NewHTTPClient() *HTTPClient {
+	policy := NewEgressPolicy()
+
+	// Every connection goes through the policy's dialer. Proxies are not used: a proxy
+	// would make the connection on our behalf, past the address check.
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.Proxy = nil
+	base.DialContext = policy.DialContext(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+
+	// Create transport with OTel instrumentation
+	transport := otelhttp.NewTransport(base)
+
+	return &HTTPClient{
+		client: &http.Client{
+			Transport:     transport,
+			Timeout:       10 * time.Second,
+			CheckRedirect: policy.CheckRedirect,
+		},
+		policy: policy,
+		stub:   config.DemoMode,
+	}
+}
+
+// CheckURL reports whether the egress policy allows u, as far as can be told before the
+// request; notifiers use it to reject user-supplied targets up front
+func (c *HTTPClient) CheckURL(u *url.URL) error {
+	return c.policy.CheckURL(u)
+}
+
+// Do performs an HTTP request under the egress policy without capturing bodies, for
+// requests whose bodies are too large or too sensitive for span events
+func (c *HTTPClient) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	span := trace.SpanFromContext(ctx)
+	if err := c.policy.CheckURL(req.URL); err != nil {
+		c.policy.recordDenied(ctx, err)
+		span.RecordError(err)
+		span.SetAttributes(attribute.Bool("egress.denied", true))
+		return nil, err
+	}
+	if c.stub {
+		return stubResponse(ctx, req), nil
+	}
+	if id := requestctx.RequestID(ctx); id != "" && req.Header.Get("X-Request-ID") == "" {
+		req.Header.Set("X-Request-ID", id)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+	}
+	return resp, err
+}
+
+// DoWithBodyCapture performs an HTTP request and captures request/response bodies as span
+// events, within the limits of TODO_TRACE_BODY_* as for the API's traced routes
+func (c *HTTPClient) DoWithBodyCapture(ctx context.Context, req *http.Request) (*http.Response, error) {
+	span := trace.SpanFromContext(ctx)
+
+	// Capture request body if present
+	if req.Body != nil && telemetry.TraceBodies {
+		head, whole, complete, err := telemetry.ReadBodyHead(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		size := req.ContentLength
+		if complete {
+			size = int64(len(head))
+		}
+		// Restore the body
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{whole, req.Body}
+
+		// Add request body as event
+		span.AddEvent("http.request.body",
+			trace.WithAttributes(telemetry.BodyEventAttributes(req.Header.Get("Content-Type"), head, size)...))
+	}
+
+	// The receiver can tie its side of a call to the request that caused it
+	if id := requestctx.RequestID(ctx); id != "" && req.Header.Get("X-Request-ID") == "" {
+		req.Header.Set("X-Request-ID", id)
+	}
+
+	// Add request details
+	span.SetAttributes(
+		attribute.String("http.method", req.Method),
+		attribute.String("http.url", req.URL.String()),
+		attribute.String("http.host", req.Host),
+	)
+
+	if err := c.policy.CheckURL(req.URL); err != nil {
+		c.policy.recordDenied(ctx, err)
+		span.RecordError(err)
+		span.SetAttributes(attribute.Bool("egress.denied", true))
+		return nil, err
+	}
+
+	if c.stub {
+		return stubResponse(ctx, req), nil
+	}
+
+	// Perform the request
+	resp, err := c.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	// Capture response body
+	if telemetry.TraceBodies {
+		head, whole, complete, err := telemetry.ReadBodyHead(resp.Body)
+		if err != nil {
+			resp.Body.Close()
+			span.RecordError(err)
+			return nil, fmt.Errorf("failed to read response body: %w", err)
+		}
+		size := resp.ContentLength
+		if complete {
+			size = int64(len(head))
+		}
+		attrs := telemetry.BodyEventAttributes(resp.Header.Get("Content-Type"), head, size)
+		span.AddEvent("http.response.body",
+			trace.WithAttributes(append(attrs, attribute.Int("status_code", resp.StatusCode))...))
+
+		// Restore response body for caller
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{whole, resp.Body}
+	}
+
+	// Add response attributes
+	span.SetAttributes(
+		attribute.Int("http.status_code", resp.StatusCode),
+		attribute.String("http.status_text", http.StatusText(resp.StatusCode)),
+	)
+
+	return resp, nil
+}
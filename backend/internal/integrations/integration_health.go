@@ -0,0 +1,142 @@
+package integrations
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Integration names outcomes are recorded under besides the HTTP notifiers, which use
+// their notifier name (slack, webhook, telegram, push), and cloud attachment storage, which
+// uses its backend (s3, gcs)
+const (
+	integrationSMTP     = "smtp"
+	integrationExternal = "external"
+	integrationWebhooks = "webhooks"
+)
+
+// IntegrationHealthWindow is how far back delivery outcomes count towards an
+// integration's health
+var IntegrationHealthWindow = config.EnvDuration("TODO_INTEGRATION_HEALTH_WINDOW", 15*time.Minute)
+
+// integrationDownAfter is how many attempts in a row must fail for an integration to be
+// reported down, so one timeout does not page anyone
+const integrationDownAfter = 3
+
+// maxIntegrationOutcomes bounds the outcomes kept per integration within the window
+const maxIntegrationOutcomes = 1000
+
+type integrationOutcome struct {
+	at     time.Time
+	failed bool
+}
+
+// integrationHealth keeps the recent delivery outcomes of each integration this process
+// talked to
+type integrationHealth struct {
+	mu       sync.Mutex
+	window   time.Duration
+	outcomes map[string][]integrationOutcome
+}
+
+// recentIntegrations collects the outcomes of every delivery to an outside system
+var recentIntegrations = &integrationHealth{window: IntegrationHealthWindow, outcomes: map[string][]integrationOutcome{}}
+
+// record notes the outcome of one delivery attempt
+func (h *integrationHealth) record(name string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	outcomes := append(h.prune(h.outcomes[name], now), integrationOutcome{at: now, failed: err != nil})
+	if len(outcomes) > maxIntegrationOutcomes {
+		outcomes = outcomes[len(outcomes)-maxIntegrationOutcomes:]
+	}
+	h.outcomes[name] = outcomes
+}
+
+// prune drops outcomes older than the window
+func (h *integrationHealth) prune(outcomes []integrationOutcome, now time.Time) []integrationOutcome {
+	cutoff := now.Add(-h.window)
+	i := sort.Search(len(outcomes), func(i int) bool { return outcomes[i].at.After(cutoff) })
+	return outcomes[i:]
+}
+
+// integrationStatus is an integration's health over the window. An integration is down
+// once its last attempts all failed; one with no attempts in the window is up with a
+// failure rate of 0.
+type integrationStatus struct {
+	name        string
+	up          bool
+	failureRate float64
+}
+
+// statuses returns the health of every integration attempted since the process started
+func (h *integrationHealth) statuses() []integrationStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	statuses := make([]integrationStatus, 0, len(h.outcomes))
+	for name, outcomes := range h.outcomes {
+		outcomes = h.prune(outcomes, now)
+		h.outcomes[name] = outcomes
+
+		status := integrationStatus{name: name}
+		failures, streak := 0, 0
+		for _, o := range outcomes {
+			if o.failed {
+				failures++
+				streak++
+			} else {
+				streak = 0
+			}
+		}
+		if len(outcomes) > 0 {
+			status.failureRate = float64(failures) / float64(len(outcomes))
+		}
+		status.up = streak < min(integrationDownAfter, max(len(outcomes), 1))
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].name < statuses[j].name })
+	return statuses
+}
+
+// RegisterIntegrationHealthGauges reports the health of each integration as
+// todo_app.integration.up and todo_app.integration.failure_rate, labeled by integration.
+// Every replica reports the deliveries it made itself.
+func RegisterIntegrationHealthGauges() {
+	meter := telemetry.GetMeter()
+	up, err := meter.Int64ObservableGauge("todo_app.integration.up",
+		metric.WithDescription("1 while an integration accepts deliveries, 0 once its recent attempts all failed"),
+		metric.WithUnit("1"))
+	var failureRate metric.Float64ObservableGauge
+	if err == nil {
+		failureRate, err = meter.Float64ObservableGauge("todo_app.integration.failure_rate",
+			metric.WithDescription("Share of an integration's delivery attempts that failed within TODO_INTEGRATION_HEALTH_WINDOW"),
+			metric.WithUnit("1"))
+	}
+	if err == nil {
+		_, err = meter.RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+			for _, status := range recentIntegrations.statuses() {
+				attrs := metric.WithAttributes(attribute.String("integration", status.name))
+				value := int64(0)
+				if status.up {
+					value = 1
+				}
+				obs.ObserveInt64(up, value, attrs)
+				obs.ObserveFloat64(failureRate, status.failureRate, attrs)
+			}
+			return nil
+		}, up, failureRate)
+	}
+	if err != nil {
+		slog.Error("Failed to register integration health gauges", "error", err)
+	}
+}
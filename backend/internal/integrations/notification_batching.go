@@ -0,0 +1,147 @@
+package integrations
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+	_ "time/tzdata"
+	"todo-app/internal/config"
+	"todo-app/internal/scheduler"
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+) // quiet hours accept IANA time zones even where the host has no zoneinfo
+
+// notificationFlushInterval is how often held notifications are checked for delivery
+var notificationFlushInterval = config.EnvDuration("TODO_NOTIFICATION_FLUSH_INTERVAL", time.Minute)
+
+// holdUntil decides whether a notification for rule must wait: until the end of the
+// user's quiet hours, until the group's open batch is due, or for a new batch window.
+// A zero time means deliver now.
+func (d *NotificationDispatcher) holdUntil(ctx context.Context, rule *store.NotificationRule, now time.Time) (time.Time, error) {
+	settings, err := d.db.GetNotificationSettings(ctx, rule.UserID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var until time.Time
+	if pending, ok, err := d.db.PendingDeliverAfter(ctx, store.NotificationGroup{UserID: rule.UserID, Notifier: rule.Notifier, Target: rule.Target}); err != nil {
+		return time.Time{}, err
+	} else if ok {
+		until = pending
+	} else if settings.BatchWindowSeconds > 0 {
+		until = now.Add(time.Duration(settings.BatchWindowSeconds) * time.Second)
+	}
+	if quietEnd, quiet := settings.QuietUntil(now); quiet && quietEnd.After(until) {
+		until = quietEnd
+	}
+	return until, nil
+}
+
+// NotificationItem is one event in a digest
+type NotificationItem struct {
+	Event string     `json:"event"`
+	Task  store.Task `json:"task"`
+}
+
+// digestEvent returns the event every item of a digest is about, or eventDigest for a
+// digest of different events
+func digestEvent(items []NotificationItem) string {
+	if len(items) == 0 {
+		return eventDigest
+	}
+	for i := range items {
+		if items[i].Event != items[0].Event {
+			return eventDigest
+		}
+	}
+	return items[0].Event
+}
+
+// DigestNotifier is implemented by notifiers that can deliver several notifications as
+// one message. Notifiers without it get a batch one notification at a time.
+type DigestNotifier interface {
+	NotifyDigest(ctx context.Context, items []NotificationItem) error
+}
+
+// NewNotificationDigestJob returns a job that delivers held notifications once due,
+// coalescing each user's notifications per notifier and target into one digest
+func NewNotificationDigestJob(d *NotificationDispatcher) scheduler.Job {
+	return scheduler.Job{
+		Name:     "notification_digests",
+		Interval: notificationFlushInterval,
+		Run: func(ctx context.Context) error {
+			groups, err := d.db.DueNotificationGroups(ctx, time.Now())
+			if err != nil {
+				return err
+			}
+			trace.SpanFromContext(ctx).SetAttributes(attribute.Int("notification.due_groups", len(groups)))
+
+			var errs []error
+			for _, g := range groups {
+				if err := d.flush(ctx, g); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			return errors.Join(errs...)
+		},
+	}
+}
+
+// flush delivers a group's held notifications and removes them once delivered
+func (d *NotificationDispatcher) flush(ctx context.Context, g store.NotificationGroup) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "notification.digest",
+		trace.WithAttributes(
+			attribute.String("notification.notifier", g.Notifier),
+			attribute.String("user.id", g.UserID),
+		))
+	defer span.End()
+
+	queued, err := d.db.QueuedNotifications(ctx, g)
+	if err != nil || len(queued) == 0 {
+		return err
+	}
+	span.SetAttributes(attribute.Int("notification.count", len(queued)))
+
+	items := make([]NotificationItem, len(queued))
+	for i, n := range queued {
+		items[i] = NotificationItem{Event: n.Event, Task: n.Task}
+	}
+
+	notifier, err := d.registry.New(g.Notifier, g.Target)
+	if err == nil {
+		if digest, ok := notifier.(DigestNotifier); ok && len(items) > 1 {
+			err = digest.NotifyDigest(ctx, items)
+		} else {
+			for i := range items {
+				if err = notifier.Notify(ctx, items[i].Event, &items[i].Task); err != nil {
+					break
+				}
+			}
+		}
+	}
+
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		slog.WarnContext(ctx, "Notification digest failed, retrying next run", "notifier", g.Notifier, "user_id", g.UserID, "error", err)
+	}
+	d.sent.Add(ctx, int64(len(items)), metric.WithAttributes(
+		attribute.String("notifier", g.Notifier),
+		attribute.String("event", "digest"),
+		attribute.String("outcome", outcome),
+	))
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Sent notification digest", "notifier", g.Notifier, "user_id", g.UserID, "count", len(items))
+	return d.db.DeleteQueuedNotifications(ctx, g, queued[len(queued)-1].ID)
+}
@@ -0,0 +1,135 @@
+package integrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NotificationDispatcher evaluates notification rules for task events and delivers
+// through the notifiers the matching rules name
+type NotificationDispatcher struct {
+	db       *store.DB
+	registry *NotifierRegistry
+	sent     metric.Int64Counter
+}
+
+func NewNotificationDispatcher(db *store.DB, registry *NotifierRegistry) *NotificationDispatcher {
+	sent, _ := telemetry.GetMeter().Int64Counter("todo_app.notifications.sent",
+		metric.WithDescription("Number of rule-based notifications sent, by notifier, event and outcome"),
+		metric.WithUnit("1"))
+
+	return &NotificationDispatcher{db: db, registry: registry, sent: sent}
+}
+
+// Notifiers returns the names of the notifiers rules can use
+func (d *NotificationDispatcher) Notifiers() []string {
+	return d.registry.Names()
+}
+
+// Validate checks a rule before it is stored: known event and notifier, a usable target
+// and a normalized tag
+func (d *NotificationDispatcher) Validate(rule *store.NotificationRule) error {
+	if !slices.Contains(NotificationEvents, rule.Event) {
+		return fmt.Errorf("Invalid event, expected one of %s", strings.Join(NotificationEvents, ", "))
+	}
+	if rule.Tag != nil {
+		tag, err := store.NormalizeTag(*rule.Tag)
+		if err != nil {
+			return err
+		}
+		rule.Tag = &tag
+	}
+	rule.Target = strings.TrimSpace(rule.Target)
+	if _, err := d.registry.New(rule.Notifier, rule.Target); err != nil {
+		if errors.Is(err, errUnknownNotifier) {
+			return fmt.Errorf("Unknown notifier, expected one of %s", strings.Join(d.registry.Names(), ", "))
+		}
+		return fmt.Errorf("Invalid target: %v", err)
+	}
+	return nil
+}
+
+// Dispatch notifies through every rule matching event on task, or queues the
+// notification when the rule's user has quiet hours or batching in effect. Each delivery
+// gets its own span; failures are joined into the returned error.
+func (d *NotificationDispatcher) Dispatch(ctx context.Context, event string, task *store.Task) error {
+	rules, err := d.db.GetNotificationRulesFor(ctx, event, task.ListID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.Matches(event, task) {
+			continue
+		}
+		if err := d.deliver(ctx, rule, event, task); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliver notifies through one rule, or queues the notification for later. links are
+// added to its span, e.g. the change an outbox entry was written by.
+func (d *NotificationDispatcher) deliver(ctx context.Context, rule *store.NotificationRule, event string, task *store.Task, links ...trace.Link) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "notification.dispatch",
+		trace.WithAttributes(
+			attribute.Int("notification.rule_id", rule.ID),
+			attribute.String("notification.notifier", rule.Notifier),
+			attribute.String("notification.event", event),
+			attribute.String("user.id", rule.UserID),
+			attribute.Int("task.id", task.ID),
+		),
+		trace.WithLinks(links...))
+	defer span.End()
+
+	outcome := "success"
+	notifier, err := d.registry.New(rule.Notifier, rule.Target)
+	var until time.Time
+	if err == nil {
+		until, err = d.holdUntil(ctx, rule, time.Now())
+	}
+	if err == nil && !until.IsZero() {
+		outcome = "queued"
+		span.SetAttributes(attribute.String("notification.deliver_after", until.UTC().Format(time.RFC3339)))
+		err = d.db.QueueNotification(ctx, store.QueuedNotification{
+			UserID:       rule.UserID,
+			RuleID:       rule.ID,
+			Notifier:     rule.Notifier,
+			Target:       rule.Target,
+			Event:        event,
+			Task:         *task,
+			DeliverAfter: until,
+		})
+	} else if err == nil {
+		err = notifier.Notify(ctx, event, task)
+	}
+
+	if err != nil {
+		outcome = "error"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		slog.WarnContext(ctx, "Notification rule failed", "rule_id", rule.ID, "notifier", rule.Notifier, "error", err)
+	}
+	d.sent.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("notifier", rule.Notifier),
+		attribute.String("event", event),
+		attribute.String("outcome", outcome),
+	))
+	return err
+}
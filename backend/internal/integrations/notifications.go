@@ -0,0 +1,119 @@
+// Package integrations talks to the systems outside the app: notifiers and email for
+// reminders, outgoing webhooks, inbound hooks, the SIEM and the external task API.
+package integrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"todo-app/internal/config"
+	"todo-app/internal/store"
+)
+
+// NotificationEvents lists the events notification rules can subscribe to
+var NotificationEvents = []string{store.EventTaskCreated, store.EventTaskCompleted, store.EventTaskDue}
+
+// eventDigest names a batch of held notifications delivered as one message
+const eventDigest = "digest"
+
+// errUnknownNotifier is returned when no notifier is registered under a name
+var errUnknownNotifier = errors.New("unknown notifier")
+
+// Notifier delivers a notification about a task event
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, event string, task *store.Task) error
+}
+
+// NotifierFactory builds a notifier that delivers to target. What a target is depends on
+// the notifier: an email address, a webhook URL, a chat ID. An empty target asks for
+// the notifier's configured default, if it has one.
+type NotifierFactory func(target string) (Notifier, error)
+
+// NotifierRegistry maps notifier names to factories, so notification rules stored in the
+// database can name a channel without the code that evaluates them knowing every channel
+type NotifierRegistry struct {
+	mu        sync.RWMutex
+	factories map[string]NotifierFactory
+}
+
+func NewNotifierRegistry() *NotifierRegistry {
+	return &NotifierRegistry{factories: map[string]NotifierFactory{}}
+}
+
+// Register adds a notifier under name, replacing any previous registration
+func (r *NotifierRegistry) Register(name string, factory NotifierFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.factories[name] = factory
+}
+
+// New builds the named notifier for target
+func (r *NotifierRegistry) New(name, target string) (Notifier, error) {
+	r.mu.RLock()
+	factory, ok := r.factories[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q", errUnknownNotifier, name)
+	}
+	return factory(target)
+}
+
+// Names lists the registered notifiers
+func (r *NotifierRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewDefaultNotifierRegistry registers the built-in notifiers
+func NewDefaultNotifierRegistry(client *HTTPClient, mailer *Mailer, external ExternalAPIConfig) *NotifierRegistry {
+	r := NewNotifierRegistry()
+	r.Register("external", func(string) (Notifier, error) {
+		return externalAPINotifier{client: client, config: external}, nil
+	})
+	r.Register("email", func(target string) (Notifier, error) {
+		return newEmailNotifier(mailer, target)
+	})
+	r.Register("slack", func(target string) (Notifier, error) {
+		return newSlackNotifier(client, target)
+	})
+	r.Register("webhook", func(target string) (Notifier, error) {
+		return newWebhookNotifier(client, target)
+	})
+	r.Register("telegram", func(target string) (Notifier, error) {
+		return newTelegramNotifier(client, config.EnvString("TODO_TELEGRAM_BOT_TOKEN", ""), target)
+	})
+	r.Register("push", func(target string) (Notifier, error) {
+		return newPushNotifier(client, target)
+	})
+	return r
+}
+
+// NewNotifiers builds the notifiers named in a comma-separated list with their default
+// targets, skipping names that are unknown or need a target
+func NewNotifiers(names string, registry *NotifierRegistry) []Notifier {
+	var notifiers []Notifier
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		notifier, err := registry.New(name, "")
+		if err != nil {
+			slog.Warn("Ignoring notifier", "notifier", name, "error", err)
+			continue
+		}
+		notifiers = append(notifiers, notifier)
+	}
+	return notifiers
+}
@@ -0,0 +1,281 @@
+package integrations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// notificationText is the one-line summary chat and push notifiers deliver
+func notificationText(event string, task *store.Task) string {
+	var verb string
+	switch event {
+	case store.EventTaskCreated:
+		verb = "New task"
+	case store.EventTaskCompleted:
+		verb = "Completed"
+	case store.EventTaskDue:
+		verb = "Due soon"
+	case store.EventTaskStale:
+		verb = fmt.Sprintf("Stale for %d days", task.AgeDays)
+	default:
+		verb = event
+	}
+	text := fmt.Sprintf("%s: %s", verb, task.Title)
+	if len(task.Tags) > 0 {
+		text += " #" + strings.Join(task.Tags, " #")
+	}
+	return text
+}
+
+// digestText lists the notifications of a digest, one per line
+func digestText(items []NotificationItem) string {
+	lines := make([]string, 0, len(items)+1)
+	if digestEvent(items) == store.EventTaskStale {
+		lines = append(lines, fmt.Sprintf("%d stale tasks:", len(items)))
+	} else {
+		lines = append(lines, fmt.Sprintf("%d task updates:", len(items)))
+	}
+	for i := range items {
+		lines = append(lines, "- "+notificationText(items[i].Event, &items[i].Task))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// digestTask stands in for the task of a digest in spans and logs
+var digestTask = &store.Task{}
+
+// parseNotifierURL validates a notifier target that must be an absolute http(s) URL the
+// client's egress policy allows
+func parseNotifierURL(client *HTTPClient, name, target string) (string, error) {
+	if target == "" {
+		return "", fmt.Errorf("%s notifier needs a target URL", name)
+	}
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("%s notifier target must be an http(s) URL", name)
+	}
+	if err := client.CheckURL(u); err != nil {
+		return "", fmt.Errorf("%s notifier target not allowed: %w", name, err)
+	}
+	return u.String(), nil
+}
+
+// postNotification sends one notification request, traced as "notification.<name>", and
+// treats any non-2xx response as a failed delivery
+func postNotification(ctx context.Context, client *HTTPClient, name, event string, task *store.Task, req *http.Request) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "notification."+name,
+		trace.WithAttributes(
+			attribute.String("notification.notifier", name),
+			attribute.String("notification.event", event),
+			attribute.Int("task.id", task.ID),
+		))
+	defer span.End()
+
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", "todo-app/1.0")
+
+	resp, err := client.DoWithBodyCapture(ctx, req)
+	if err != nil {
+		span.RecordError(err)
+		slog.WarnContext(ctx, "Notification failed", "notifier", name, "task_id", task.ID, "error", err)
+		recentIntegrations.record(name, err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err := fmt.Errorf("%s notifier returned status %d", name, resp.StatusCode)
+		span.RecordError(err)
+		slog.WarnContext(ctx, "Notification rejected", "notifier", name, "task_id", task.ID, "status_code", resp.StatusCode)
+		recentIntegrations.record(name, err)
+		return err
+	}
+	recentIntegrations.record(name, nil)
+
+	slog.InfoContext(ctx, "Notification sent", "notifier", name, "task_id", task.ID, "event", event)
+	return nil
+}
+
+// postJSON builds a JSON POST request for postNotification
+func postJSON(target string, body any) (*http.Request, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", target, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// slackNotifier posts to a Slack incoming webhook
+type slackNotifier struct {
+	client *HTTPClient
+	url    string
+}
+
+func newSlackNotifier(client *HTTPClient, target string) (Notifier, error) {
+	u, err := parseNotifierURL(client, "slack", target)
+	if err != nil {
+		return nil, err
+	}
+	return slackNotifier{client: client, url: u}, nil
+}
+
+func (slackNotifier) Name() string {
+	return "slack"
+}
+
+func (n slackNotifier) Notify(ctx context.Context, event string, task *store.Task) error {
+	req, err := postJSON(n.url, map[string]string{"text": notificationText(event, task)})
+	if err != nil {
+		return err
+	}
+	return postNotification(ctx, n.client, n.Name(), event, task, req)
+}
+
+func (n slackNotifier) NotifyDigest(ctx context.Context, items []NotificationItem) error {
+	req, err := postJSON(n.url, map[string]string{"text": digestText(items)})
+	if err != nil {
+		return err
+	}
+	return postNotification(ctx, n.client, n.Name(), eventDigest, digestTask, req)
+}
+
+// WebhookPayload is the body the webhook notifier posts. A digest has Items instead of Task.
+type WebhookPayload struct {
+	Event  string             `json:"event"`
+	Task   *store.Task        `json:"task,omitempty"`
+	Items  []NotificationItem `json:"items,omitempty"`
+	SentAt time.Time          `json:"sent_at"`
+}
+
+// webhookNotifier posts the event and the full task as JSON to an arbitrary URL
+type webhookNotifier struct {
+	client *HTTPClient
+	url    string
+}
+
+func newWebhookNotifier(client *HTTPClient, target string) (Notifier, error) {
+	u, err := parseNotifierURL(client, "webhook", target)
+	if err != nil {
+		return nil, err
+	}
+	return webhookNotifier{client: client, url: u}, nil
+}
+
+func (webhookNotifier) Name() string {
+	return "webhook"
+}
+
+func (n webhookNotifier) Notify(ctx context.Context, event string, task *store.Task) error {
+	req, err := postJSON(n.url, WebhookPayload{Event: event, Task: task, SentAt: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Task-Event", event)
+	return postNotification(ctx, n.client, n.Name(), event, task, req)
+}
+
+func (n webhookNotifier) NotifyDigest(ctx context.Context, items []NotificationItem) error {
+	req, err := postJSON(n.url, WebhookPayload{Event: eventDigest, Items: items, SentAt: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Task-Event", eventDigest)
+	return postNotification(ctx, n.client, n.Name(), eventDigest, digestTask, req)
+}
+
+// telegramNotifier sends a message to a chat through the Telegram Bot API
+type telegramNotifier struct {
+	client *HTTPClient
+	token  string
+	chatID string
+}
+
+func newTelegramNotifier(client *HTTPClient, token, target string) (Notifier, error) {
+	if token == "" {
+		return nil, errors.New("telegram notifier needs TODO_TELEGRAM_BOT_TOKEN")
+	}
+	if target == "" {
+		return nil, errors.New("telegram notifier needs a chat ID as target")
+	}
+	return telegramNotifier{client: client, token: token, chatID: target}, nil
+}
+
+func (telegramNotifier) Name() string {
+	return "telegram"
+}
+
+func (n telegramNotifier) Notify(ctx context.Context, event string, task *store.Task) error {
+	return n.send(ctx, event, task, notificationText(event, task))
+}
+
+func (n telegramNotifier) NotifyDigest(ctx context.Context, items []NotificationItem) error {
+	return n.send(ctx, eventDigest, digestTask, digestText(items))
+}
+
+func (n telegramNotifier) send(ctx context.Context, event string, task *store.Task, text string) error {
+	req, err := postJSON("https://api.telegram.org/bot"+n.token+"/sendMessage", map[string]string{
+		"chat_id": n.chatID,
+		"text":    text,
+	})
+	if err != nil {
+		return err
+	}
+	return postNotification(ctx, n.client, n.Name(), event, task, req)
+}
+
+// pushNotifier publishes to an ntfy-style topic URL: the body is the message and the
+// Title header its heading
+type pushNotifier struct {
+	client *HTTPClient
+	url    string
+}
+
+func newPushNotifier(client *HTTPClient, target string) (Notifier, error) {
+	u, err := parseNotifierURL(client, "push", target)
+	if err != nil {
+		return nil, err
+	}
+	return pushNotifier{client: client, url: u}, nil
+}
+
+func (pushNotifier) Name() string {
+	return "push"
+}
+
+func (n pushNotifier) Notify(ctx context.Context, event string, task *store.Task) error {
+	req, err := http.NewRequest("POST", n.url, strings.NewReader(notificationText(event, task)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", "Todo: "+event)
+	req.Header.Set("Tags", strings.Join(task.Tags, ","))
+	return postNotification(ctx, n.client, n.Name(), event, task, req)
+}
+
+func (n pushNotifier) NotifyDigest(ctx context.Context, items []NotificationItem) error {
+	req, err := http.NewRequest("POST", n.url, strings.NewReader(digestText(items)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", fmt.Sprintf("Todo: %d task updates", len(items)))
+	return postNotification(ctx, n.client, n.Name(), eventDigest, digestTask, req)
+}
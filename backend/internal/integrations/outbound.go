@@ -0,0 +1,216 @@
+package integrations
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// OutboundRate is the sustained number of queued notifications delivered per second
+	OutboundRate = config.EnvInt("TODO_OUTBOUND_RATE", 10)
+	// OutboundBurst is how many queued notifications may go out at once after a quiet period
+	OutboundBurst = config.EnvInt("TODO_OUTBOUND_BURST", 20)
+	// OutboundQueueSize bounds the notifications waiting for delivery; more are dropped
+	OutboundQueueSize = config.EnvInt("TODO_OUTBOUND_QUEUE_SIZE", 10000)
+	// OutboundWorkers is how many queued notifications are delivered concurrently
+	OutboundWorkers = config.EnvInt("TODO_OUTBOUND_WORKERS", 4)
+)
+
+// tokenBucket lets through rate calls per second on average and up to burst at once
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst int) *tokenBucket {
+	return &tokenBucket{rate: float64(rate), burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// SetRate changes the average rate and the burst, keeping the tokens already saved up to
+// the new burst
+func (b *tokenBucket) SetRate(rate, burst float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rate, b.burst = rate, burst
+	b.tokens = min(b.tokens, burst)
+}
+
+// Wait blocks until a token is available or ctx is done
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// outboundCall is a queued notification
+type outboundCall struct {
+	ctx context.Context
+	// link is to the span that queued the call, whose trace the delivery does not join
+	link     trace.Link
+	name     string
+	queuedAt time.Time
+	run      func(ctx context.Context)
+}
+
+// OutboundQueue delivers notifications to third parties (webhooks, Slack, the external API)
+// off the request path at a bounded rate, so a bulk request creating thousands of tasks
+// trickles its notifications out instead of firing them all at once. The queue is bounded
+// too: when it is full, new notifications are dropped and counted rather than piling up
+// in memory.
+type OutboundQueue struct {
+	calls   chan outboundCall
+	limiter *tokenBucket
+	rate    int
+	burst   int
+	workers int
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	dropped metric.Int64Counter
+	waited  metric.Float64Histogram
+}
+
+// NewOutboundQueue creates a queue holding up to size calls, delivered by workers
+// goroutines at rate per second with bursts of up to burst
+func NewOutboundQueue(size, rate, burst, workers int) *OutboundQueue {
+	meter := telemetry.GetMeter()
+
+	q := &OutboundQueue{
+		calls:   make(chan outboundCall, size),
+		limiter: newTokenBucket(rate, burst),
+		rate:    rate,
+		burst:   burst,
+		workers: workers,
+	}
+
+	q.dropped, _ = meter.Int64Counter("todo_app.outbound.dropped",
+		metric.WithDescription("Notifications dropped because the outbound queue was full"),
+		metric.WithUnit("1"))
+
+	q.waited, _ = meter.Float64Histogram("todo_app.outbound.queue_time",
+		metric.WithDescription("Time notifications spent in the outbound queue"),
+		metric.WithUnit("ms"))
+
+	depth, err := meter.Int64ObservableGauge("todo_app.outbound.queue_depth",
+		metric.WithDescription("Notifications waiting in the outbound queue"),
+		metric.WithUnit("1"))
+	if err == nil {
+		_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+			o.ObserveInt64(depth, int64(len(q.calls)))
+			return nil
+		}, depth)
+	}
+	if err != nil {
+		slog.Error("Failed to register outbound queue gauge", "error", err)
+	}
+
+	return q
+}
+
+// Enqueue schedules run for delivery and reports whether it was queued. run gets a context
+// that carries ctx's values but not its cancellation, so it outlives the request, nor its
+// span: the delivery is a trace of its own, outbound.<name>, linked to the span that queued it.
+func (q *OutboundQueue) Enqueue(ctx context.Context, name string, run func(ctx context.Context)) bool {
+	call := outboundCall{
+		ctx:      trace.ContextWithSpanContext(context.WithoutCancel(ctx), trace.SpanContext{}),
+		link:     trace.LinkFromContext(ctx),
+		name:     name,
+		queuedAt: time.Now(),
+		run:      run,
+	}
+	select {
+	case q.calls <- call:
+		return true
+	default:
+		q.dropped.Add(ctx, 1, metric.WithAttributes(attribute.String("outbound.name", name)))
+		slog.WarnContext(ctx, "Outbound queue full, dropping notification", "name", name, "queue_size", cap(q.calls))
+		return false
+	}
+}
+
+// SetReplicas shares the rate limit out between replicas, since the external API sees
+// their calls together. Each gets an equal part of the rate and burst.
+func (q *OutboundQueue) SetReplicas(n int) {
+	n = max(n, 1)
+	q.limiter.SetRate(float64(q.rate)/float64(n), max(float64(q.burst)/float64(n), 1))
+	slog.Info("Outbound rate limit shared between replicas", "replicas", n, "rate", float64(q.rate)/float64(n))
+}
+
+// Start launches the delivery workers
+func (q *OutboundQueue) Start(ctx context.Context) {
+	ctx, q.cancel = context.WithCancel(ctx)
+	for range q.workers {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			q.work(ctx)
+		}()
+	}
+}
+
+// Stop stops the workers after their current delivery. Notifications still queued are dropped.
+func (q *OutboundQueue) Stop() {
+	if q.cancel != nil {
+		q.cancel()
+	}
+	q.wg.Wait()
+	if pending := len(q.calls); pending > 0 {
+		slog.Warn("Outbound queue stopped with undelivered notifications", "pending", pending)
+	}
+}
+
+func (q *OutboundQueue) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case call := <-q.calls:
+			if err := q.limiter.Wait(ctx); err != nil {
+				return
+			}
+			waited := time.Since(call.queuedAt)
+			q.waited.Record(call.ctx, float64(waited.Milliseconds()),
+				metric.WithAttributes(attribute.String("outbound.name", call.name)))
+			runCtx, span := telemetry.GetTracer().Start(call.ctx, "outbound."+call.name,
+				trace.WithNewRoot(),
+				trace.WithLinks(call.link),
+				trace.WithAttributes(
+					attribute.String("outbound.name", call.name),
+					attribute.Int64("outbound.queue_time_ms", waited.Milliseconds()),
+				))
+			call.run(runCtx)
+			span.End()
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package integrations
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestOutboundCallIsLinkedTrace(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	q := NewOutboundQueue(1, 100, 1, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	q.Start(context.Background())
+
+	ctx, request := otel.Tracer("test").Start(ctx, "request")
+	ran := make(chan trace.SpanContext, 1)
+	if !q.Enqueue(ctx, "test_call", func(ctx context.Context) {
+		ran <- trace.SpanContextFromContext(ctx)
+	}) {
+		t.Fatal("Enqueue refused the call")
+	}
+	request.End()
+	// The request is over before the call runs
+	cancel()
+
+	delivery := <-ran
+	if !delivery.IsValid() {
+		t.Fatal("the call ran without a span")
+	}
+	if delivery.TraceID() == request.SpanContext().TraceID() {
+		t.Error("the call joined the trace of the request that queued it")
+	}
+	// Stopping waits for the call's span to end
+	q.Stop()
+
+	for _, span := range spans.Ended() {
+		if span.Name() != "outbound.test_call" {
+			continue
+		}
+		if span.Parent().IsValid() {
+			t.Errorf("outbound.test_call has parent %v, want a root span", span.Parent())
+		}
+		links := span.Links()
+		if len(links) != 1 || links[0].SpanContext.SpanID() != request.SpanContext().SpanID() {
+			t.Errorf("outbound.test_call links = %v, want one to the request span", links)
+		}
+		return
+	}
+	t.Fatal("no outbound.test_call span ended")
+}
@@ -0,0 +1,118 @@
+package integrations
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"todo-app/internal/scheduler"
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NotificationOutbox delivers the notifications written to the outbox with task changes.
+// Entries go out through the outbound queue, so they share its rate limit, and are retried
+// with backoff until OutboxMaxAttempts. After that they become dead letters.
+type NotificationOutbox struct {
+	db          *store.DB
+	dispatcher  *NotificationDispatcher
+	outbound    *OutboundQueue
+	maxAttempts int
+}
+
+// NewNotificationOutbox creates the outbox and registers its queue depth gauges, reported by
+// the leader only since every replica sees the same table
+func NewNotificationOutbox(db *store.DB, dispatcher *NotificationDispatcher, outbound *OutboundQueue, isLeader func() bool) *NotificationOutbox {
+	o := &NotificationOutbox{db: db, dispatcher: dispatcher, outbound: outbound, maxAttempts: store.OutboxMaxAttempts}
+
+	meter := telemetry.GetMeter()
+	depth, err := meter.Int64ObservableGauge("todo_app.outbox.depth",
+		metric.WithDescription("Notifications waiting in the outbox, including those being retried"),
+		metric.WithUnit("1"))
+	var oldestAge metric.Float64ObservableGauge
+	if err == nil {
+		oldestAge, err = meter.Float64ObservableGauge("todo_app.outbox.oldest_age",
+			metric.WithDescription("Age of the oldest notification waiting in the outbox"),
+			metric.WithUnit("s"))
+	}
+	if err == nil {
+		_, err = meter.RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+			if !isLeader() {
+				return nil
+			}
+			n, oldest, err := db.OutboxDepth(ctx)
+			if err != nil {
+				slog.WarnContext(ctx, "Failed to collect outbox gauges", "error", err)
+				return err
+			}
+			age := 0.0
+			if !oldest.IsZero() {
+				age = time.Since(oldest).Seconds()
+			}
+			obs.ObserveInt64(depth, n)
+			obs.ObserveFloat64(oldestAge, age)
+			return nil
+		}, depth, oldestAge)
+	}
+	if err != nil {
+		slog.Error("Failed to register outbox gauges", "error", err)
+	}
+	return o
+}
+
+// NewNotificationOutboxJob hands due outbox entries to the outbound queue
+func NewNotificationOutboxJob(o *NotificationOutbox) scheduler.Job {
+	return scheduler.Job{
+		Name:     "notification_outbox",
+		Interval: store.OutboxInterval,
+		Run: func(ctx context.Context) error {
+			entries, err := o.db.ClaimOutbox(ctx, time.Now(), store.OutboxBatchSize)
+			if err != nil {
+				return err
+			}
+			trace.SpanFromContext(ctx).SetAttributes(attribute.Int("outbox.due", len(entries)))
+			for i := range entries {
+				entry := &entries[i]
+				if !o.outbound.Enqueue(ctx, "notification_outbox", func(ctx context.Context) {
+					if err := o.deliver(ctx, entry); err != nil {
+						slog.ErrorContext(ctx, "Error recording outbox delivery", "outbox_id", entry.ID, "error", err)
+					}
+				}) {
+					// The rest stay claimed and are picked up again once the claim runs out
+					break
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// deliver makes one attempt at an entry and records the outcome: removed when delivered,
+// rescheduled after a backoff when not, and moved to the dead letters on the last attempt.
+// Only an error recording the outcome is returned.
+func (o *NotificationOutbox) deliver(ctx context.Context, entry *store.OutboxEntry) error {
+	entry.Attempts++
+	rule := &store.NotificationRule{ID: entry.RuleID, UserID: entry.UserID, Event: entry.Event, Notifier: entry.Notifier, Target: entry.Target}
+	var links []trace.Link
+	if link, ok := originLink(entry.TraceID, entry.SpanID); ok {
+		links = append(links, link)
+	}
+	err := o.dispatcher.deliver(ctx, rule, entry.Event, &entry.Task, links...)
+	if err == nil {
+		return o.db.DeleteOutboxEntry(ctx, entry.ID)
+	}
+
+	if entry.Attempts >= o.maxAttempts {
+		slog.ErrorContext(ctx, "Notification failed, giving up", "outbox_id", entry.ID, "rule_id", entry.RuleID,
+			"attempts", entry.Attempts, "error", err)
+		return o.db.DeadLetterOutboxEntry(ctx, entry, err)
+	}
+	next := time.Now().Add(store.BackoffAfter(store.OutboxBackoff, entry.Attempts)).UTC()
+	slog.WarnContext(ctx, "Notification failed, will retry", "outbox_id", entry.ID, "rule_id", entry.RuleID,
+		"attempt", entry.Attempts, "next_attempt_at", next, "error", err)
+	return o.db.RetryOutboxEntry(ctx, entry, next, err)
+}
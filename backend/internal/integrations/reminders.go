@@ -0,0 +1,103 @@
+package integrations
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"todo-app/internal/scheduler"
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// reminderSender sends due-date reminders through a set of notifiers and the
+// task.due notification rules
+type reminderSender struct {
+	db        *store.DB
+	notifiers []Notifier
+	rules     *NotificationDispatcher
+	sent      metric.Int64Counter
+}
+
+// NewReminderJob returns a job that notifies about tasks due within TODO_REMINDER_LEAD, or
+// their list's reminder offset.
+// A reminder is retried on the next run unless every notifier and rule succeeded.
+func NewReminderJob(db *store.DB, notifiers []Notifier, rules *NotificationDispatcher) scheduler.Job {
+	sent, _ := telemetry.GetMeter().Int64Counter("todo_app.reminders.sent",
+		metric.WithDescription("Number of due-date reminders sent, by notifier and outcome"),
+		metric.WithUnit("1"))
+
+	s := &reminderSender{db: db, notifiers: notifiers, rules: rules, sent: sent}
+	return scheduler.Job{Name: "reminders", Interval: store.ReminderInterval, Run: s.run}
+}
+
+func (s *reminderSender) run(ctx context.Context) error {
+	if len(s.notifiers) == 0 && s.rules == nil {
+		return nil
+	}
+
+	now := time.Now()
+	tasks, err := s.db.GetTasksDueForReminder(ctx, now, store.ReminderBatchSize)
+	if err != nil {
+		return err
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("reminder.due_tasks", len(tasks)))
+
+	var errs []error
+	for i := range tasks {
+		if err := s.remind(ctx, &tasks[i], now); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// remind sends one reminder in its own span and marks the task reminded if every notifier succeeded
+func (s *reminderSender) remind(ctx context.Context, task *store.Task, now time.Time) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "reminder.send",
+		trace.WithAttributes(
+			attribute.Int("task.id", task.ID),
+			attribute.String("task.uuid", task.UUID),
+			attribute.String("task.due_at", task.DueAt.UTC().Format(time.RFC3339)),
+		))
+	defer span.End()
+
+	var errs []error
+	for _, notifier := range s.notifiers {
+		outcome := "success"
+		if err := notifier.Notify(ctx, store.EventTaskDue, task); err != nil {
+			outcome = "error"
+			errs = append(errs, err)
+		}
+		s.sent.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("notifier", notifier.Name()),
+			attribute.String("outcome", outcome),
+		))
+	}
+
+	if s.rules != nil {
+		if err := s.rules.Dispatch(ctx, store.EventTaskDue, task); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	if err := s.db.MarkReminded(ctx, task.ID, now); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	slog.InfoContext(ctx, "Sent due-date reminder", "id", task.ID, "due_at", task.DueAt)
+	return nil
+}
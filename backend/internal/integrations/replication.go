@@ -0,0 +1,228 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/scheduler"
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// replicaInterval is how often the database is checked for changes to replicate
+	replicaInterval = config.EnvDuration("TODO_REPLICA_INTERVAL", 10*time.Second)
+	// replicaRefresh is how often the database is replicated even without task changes, for
+	// the tables that do not go through the change log (lists, rules, settings, ...)
+	replicaRefresh = config.EnvDuration("TODO_REPLICA_REFRESH", time.Hour)
+	// replicaMaxLag is the replication lag past which /readyz fails; 0 only reports the lag
+	replicaMaxLag = config.EnvDuration("TODO_REPLICA_MAX_LAG", 5*time.Minute)
+)
+
+// Objects written under the replica prefix
+const (
+	replicaDatabaseKey = "tasks.db"
+	replicaPositionKey = "position.json"
+)
+
+// ReplicaPosition is the position.json written next to the replica, so whoever restores it
+// knows how current it is
+type ReplicaPosition struct {
+	Seq          int64     `json:"seq"`
+	ReplicatedAt time.Time `json:"replicated_at"`
+	InstanceID   string    `json:"instance_id"`
+	SizeBytes    int64     `json:"size_bytes"`
+}
+
+// Replicator streams the SQLite database to S3-compatible storage. Every TODO_REPLICA_INTERVAL
+// it compares the change sequence with the one last replicated and, when tasks changed or
+// TODO_REPLICA_REFRESH has passed, uploads a consistent snapshot as tasks.db followed by
+// position.json. The lag is how long the oldest change not yet uploaded has waited.
+type Replicator struct {
+	db     *store.DB
+	s3     *s3Client
+	prefix string
+
+	mu         sync.Mutex
+	seq        int64
+	uploadedAt time.Time
+	// pendingSince is when the oldest change not yet replicated was made, zero when in sync
+	pendingSince time.Time
+	lastErr      error
+
+	uploads metric.Int64Counter
+}
+
+// NewReplicator reads the replica from TODO_REPLICA_URL, s3://bucket/prefix, and returns nil
+// when it is unset. The store is reached at TODO_REPLICA_ENDPOINT (AWS S3 in
+// TODO_REPLICA_REGION by default) with TODO_REPLICA_ACCESS_KEY_ID and
+// TODO_REPLICA_SECRET_ACCESS_KEY, falling back to the AWS_* variables.
+func NewReplicator(db *store.DB) (*Replicator, error) {
+	target := config.EnvString("TODO_REPLICA_URL", "")
+	if target == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return nil, fmt.Errorf("invalid TODO_REPLICA_URL %q, expected s3://bucket/prefix", target)
+	}
+	if !db.SupportsSnapshots() {
+		return nil, fmt.Errorf("replication needs a SQLite database: %w", store.ErrBackupUnsupported)
+	}
+
+	region := config.EnvString("TODO_REPLICA_REGION", config.EnvString("AWS_REGION", "us-east-1"))
+	endpoint, err := url.Parse(config.EnvString("TODO_REPLICA_ENDPOINT", "https://s3."+region+".amazonaws.com"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid TODO_REPLICA_ENDPOINT: %v", err)
+	}
+	s3 := &s3Client{
+		client:    NewHTTPClient(),
+		endpoint:  endpoint,
+		bucket:    u.Host,
+		region:    region,
+		accessKey: config.EnvString("TODO_REPLICA_ACCESS_KEY_ID", os.Getenv("AWS_ACCESS_KEY_ID")),
+		secretKey: config.EnvString("TODO_REPLICA_SECRET_ACCESS_KEY", os.Getenv("AWS_SECRET_ACCESS_KEY")),
+	}
+	if s3.accessKey == "" || s3.secretKey == "" {
+		return nil, fmt.Errorf("replication to %s needs an access key and secret", target)
+	}
+
+	r := &Replicator{db: db, s3: s3, prefix: strings.Trim(u.Path, "/")}
+	meter := telemetry.GetMeter()
+	r.uploads, _ = meter.Int64Counter("todo_app.replication.uploads",
+		metric.WithDescription("Database snapshots uploaded to the replica, by result"),
+		metric.WithUnit("1"))
+	meter.Float64ObservableGauge("todo_app.replication.lag",
+		metric.WithDescription("How long the oldest change not yet replicated has waited, 0 when in sync"),
+		metric.WithUnit("s"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			o.Observe(r.Lag().Seconds())
+			return nil
+		}))
+	return r, nil
+}
+
+// Job returns the replication job. It runs on every replica, since each replicates its own
+// database file.
+func (r *Replicator) Job() scheduler.Job {
+	return scheduler.Job{Name: "replication", Interval: replicaInterval, Run: r.replicate, EveryReplica: true}
+}
+
+// Lag returns how long the oldest change not yet replicated has waited
+func (r *Replicator) Lag() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pendingSince.IsZero() {
+		return 0
+	}
+	return time.Since(r.pendingSince)
+}
+
+// Check reports the replica unhealthy, for /readyz, when the lag has passed TODO_REPLICA_MAX_LAG
+func (r *Replicator) Check() error {
+	lag := r.Lag()
+	if replicaMaxLag <= 0 || lag <= replicaMaxLag {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lastErr != nil {
+		return fmt.Errorf("replication lag %s exceeds %s: %v", lag.Round(time.Second), replicaMaxLag, r.lastErr)
+	}
+	return fmt.Errorf("replication lag %s exceeds %s", lag.Round(time.Second), replicaMaxLag)
+}
+
+func (r *Replicator) replicate(ctx context.Context) error {
+	span := trace.SpanFromContext(ctx)
+	seq, err := r.db.ChangeSeq(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	replicated, uploadedAt := r.seq, r.uploadedAt
+	r.mu.Unlock()
+	if seq == replicated && !uploadedAt.IsZero() && time.Since(uploadedAt) < replicaRefresh {
+		span.SetAttributes(attribute.Bool("replication.in_sync", true))
+		return nil
+	}
+
+	pendingSince, err := r.db.ChangeTimeAfter(ctx, replicated)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	if r.pendingSince.IsZero() {
+		r.pendingSince = pendingSince
+	}
+	r.mu.Unlock()
+
+	now := time.Now().UTC()
+	size, err := r.upload(ctx, seq, now)
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	r.uploads.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastErr = err
+	if err != nil {
+		return err
+	}
+	r.seq, r.uploadedAt, r.pendingSince = seq, now, time.Time{}
+	span.SetAttributes(
+		attribute.Int64("replication.seq", seq),
+		attribute.Int64("replication.size_bytes", size),
+	)
+	slog.DebugContext(ctx, "Replicated the database", "seq", seq, "size_bytes", size)
+	return nil
+}
+
+// upload writes a snapshot to a temporary file and uploads it, then the position. A reader
+// that sees the new position therefore finds the snapshot it describes, or a newer one.
+func (r *Replicator) upload(ctx context.Context, seq int64, now time.Time) (int64, error) {
+	dir, err := os.MkdirTemp("", "todo-replica-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, replicaDatabaseKey)
+	if err := r.db.SnapshotTo(ctx, path); err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if err := r.s3.PutFile(ctx, r.key(replicaDatabaseKey), path); err != nil {
+		return 0, err
+	}
+
+	position, err := json.Marshal(ReplicaPosition{Seq: seq, ReplicatedAt: now, InstanceID: telemetry.InstanceID, SizeBytes: info.Size()})
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), r.s3.PutBytes(ctx, r.key(replicaPositionKey), position)
+}
+
+func (r *Replicator) key(name string) string {
+	if r.prefix == "" {
+		return name
+	}
+	return r.prefix + "/" + name
+}
@@ -0,0 +1,237 @@
+package integrations
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// s3Client reads and writes objects in an S3-compatible store (AWS S3, MinIO, R2, Google
+// Cloud Storage with HMAC keys, ...) with path-style URLs and Signature Version 4, which
+// every such store accepts
+type s3Client struct {
+	client    *HTTPClient
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+}
+
+// s3TimeFormat is the format of X-Amz-Date
+const s3TimeFormat = "20060102T150405Z"
+
+// s3EmptyPayload is the SHA-256 of an empty body, for requests without one
+const s3EmptyPayload = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// errObjectNotFound is returned when reading an object that does not exist
+var errObjectNotFound = errors.New("object not found")
+
+// objectURL returns the path-style URL of key
+func (c *s3Client) objectURL(key string) *url.URL {
+	u := *c.endpoint
+	u.RawPath = strings.TrimSuffix(c.endpoint.EscapedPath(), "/") + "/" + s3Escape(c.bucket) + "/" + s3Escape(key)
+	u.Path = strings.TrimSuffix(c.endpoint.Path, "/") + "/" + c.bucket + "/" + key
+	return &u
+}
+
+// s3Escape percent-encodes a key as SigV4 canonical URIs want: everything but unreserved
+// characters and the slashes between segments
+func s3Escape(key string) string {
+	var b strings.Builder
+	for _, c := range []byte(key) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', strings.IndexByte("-._~/", c) >= 0:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// PutFile uploads the file at path as key. The file is read twice, once for the payload
+// hash the signature covers and once for the upload, rather than held in memory.
+func (c *s3Client) PutFile(ctx context.Context, key, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return c.put(ctx, key, f, size, hex.EncodeToString(hash.Sum(nil)), "")
+}
+
+// PutBytes uploads body as key
+func (c *s3Client) PutBytes(ctx context.Context, key string, body []byte) error {
+	sum := sha256.Sum256(body)
+	return c.put(ctx, key, bytes.NewReader(body), int64(len(body)), hex.EncodeToString(sum[:]), "")
+}
+
+// put uploads body, whose hex SHA-256 is payloadHash, as key. The content type, when
+// given, is stored with the object and returned on download.
+func (c *s3Client) put(ctx context.Context, key string, body io.Reader, size int64, payloadHash, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.objectURL(key).String(), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	c.sign(req, payloadHash, time.Now().UTC())
+
+	resp, err := c.client.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("s3 PUT %s: %s: %s", key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// get downloads key, errObjectNotFound if there is no such object. The caller closes the
+// body.
+func (c *s3Client) get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.sign(req, s3EmptyPayload, time.Now().UTC())
+
+	resp, err := c.client.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, errObjectNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("s3 GET %s: %s: %s", key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp.Body, nil
+}
+
+// delete removes key; removing an object that does not exist succeeds
+func (c *s3Client) delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+	c.sign(req, s3EmptyPayload, time.Now().UTC())
+
+	resp, err := c.client.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("s3 DELETE %s: %s: %s", key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// presign returns a GET URL for key that is valid for ttl without credentials, with query
+// signed as well, such as response-content-disposition
+func (c *s3Client) presign(key string, query url.Values, ttl time.Duration, now time.Time) string {
+	amzDate := now.Format(s3TimeFormat)
+	day := amzDate[:8]
+	scope := day + "/" + c.region + "/s3/aws4_request"
+
+	u := c.objectURL(key)
+	q := url.Values{}
+	for name, values := range query {
+		q[name] = values
+	}
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", c.accessKey+"/"+scope)
+	q.Set("X-Amz-Date", amzDate)
+	q.Set("X-Amz-Expires", strconv.Itoa(int(ttl.Seconds())))
+	q.Set("X-Amz-SignedHeaders", "host")
+	// SigV4 wants %20 rather than + for spaces, which url.Values.Encode writes
+	canonicalQuery := strings.ReplaceAll(q.Encode(), "+", "%20")
+
+	canonical := strings.Join([]string{
+		http.MethodGet,
+		u.EscapedPath(),
+		canonicalQuery,
+		"host:" + u.Host,
+		"",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+	signature := hex.EncodeToString(hmacSHA256(c.signingKey(day), stringToSign))
+
+	u.RawQuery = canonicalQuery + "&X-Amz-Signature=" + signature
+	return u.String()
+}
+
+// sign adds the headers and Authorization of AWS Signature Version 4 to req
+func (c *s3Client) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format(s3TimeFormat)
+	day := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"", // no query
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + c.region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	signature := hex.EncodeToString(hmacSHA256(c.signingKey(day), stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+c.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// signingKey derives the SigV4 key for a day's requests
+func (c *s3Client) signingKey(day string) []byte {
+	key := []byte("AWS4" + c.secretKey)
+	for _, part := range []string{day, c.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return key
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
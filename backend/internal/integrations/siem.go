@@ -0,0 +1,402 @@
+package integrations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/requestctx"
+	"todo-app/internal/scheduler"
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// siemInterval is how often audit and security events are forwarded
+	siemInterval = config.EnvDuration("TODO_SIEM_INTERVAL", 10*time.Second)
+	// siemBatchSize is the most task history events forwarded per run
+	siemBatchSize = config.EnvInt("TODO_SIEM_BATCH_SIZE", 500)
+	// siemQueueSize bounds the security events waiting to be forwarded
+	siemQueueSize = config.EnvInt("TODO_SIEM_QUEUE_SIZE", 1000)
+)
+
+// siemCursor names the export_cursors row holding the last forwarded task event
+const siemCursor = "siem"
+
+// formatAuditEvent renders an event as a JSON object or a CEF line
+func formatAuditEvent(format string, e store.AuditEvent) (string, error) {
+	if format == "json" {
+		b, err := json.Marshal(e)
+		return string(b), err
+	}
+
+	ext := []string{
+		"rt=" + strconv.FormatInt(e.Time.UnixMilli(), 10),
+		"cat=" + cefExtension(e.Category),
+		"outcome=" + cefExtension(e.Outcome),
+	}
+	if e.Actor != "" {
+		ext = append(ext, "suser="+cefExtension(e.Actor))
+	}
+	if e.TaskID != 0 {
+		ext = append(ext, "cs1Label=taskId", "cs1="+strconv.Itoa(e.TaskID))
+	}
+	if len(e.Fields) > 0 {
+		ext = append(ext, "cs2Label=fields", "cs2="+cefExtension(strings.Join(e.Fields, ",")))
+	}
+	if e.TraceID != "" {
+		ext = append(ext, "cs3Label=traceId", "cs3="+cefExtension(e.TraceID))
+	}
+	if len(e.Details) > 0 {
+		keys := make([]string, 0, len(e.Details))
+		for k := range e.Details {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		details := make([]string, 0, len(keys))
+		for _, k := range keys {
+			details = append(details, k+"="+e.Details[k])
+		}
+		ext = append(ext, "msg="+cefExtension(strings.Join(details, " ")))
+	}
+	return fmt.Sprintf("CEF:0|todo-app|todo-app|%s|%s|%s|%d|%s",
+		telemetry.ServiceVersion, cefHeader(e.Type), cefHeader(e.Category+" "+e.Type), e.Severity, strings.Join(ext, " ")), nil
+}
+
+// cefHeader escapes a CEF header field
+func cefHeader(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ").Replace(s)
+}
+
+// cefExtension escapes a CEF extension value
+func cefExtension(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`).Replace(s)
+}
+
+// siemSink delivers formatted events to the SIEM
+type siemSink interface {
+	Send(ctx context.Context, lines []string) error
+	Close() error
+}
+
+// syslogSink writes RFC 5424 syslog messages over UDP or TCP. It dials lazily and redials
+// after a failed write.
+type syslogSink struct {
+	network, addr string
+	hostname      string
+	conn          net.Conn
+}
+
+func (s *syslogSink) Send(ctx context.Context, lines []string) error {
+	if s.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, s.network, s.addr)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	for _, line := range lines {
+		// Facility local0 (16), severity informational (6)
+		msg := fmt.Sprintf("<134>1 %s %s todo-app %d - - %s", time.Now().UTC().Format(time.RFC3339Nano), s.hostname, os.Getpid(), line)
+		if s.network == "tcp" {
+			// Non-transparent framing: one message per line
+			msg += "\n"
+		}
+		s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if _, err := s.conn.Write([]byte(msg)); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *syslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// httpSink POSTs each batch to an HTTPS collector: a JSON array of events, or CEF lines.
+// Batches go through Do rather than DoWithBodyCapture, since audit records do not belong
+// in span events.
+type httpSink struct {
+	url    string
+	token  string
+	format string
+	client *HTTPClient
+}
+
+func (s *httpSink) Send(ctx context.Context, lines []string) error {
+	var body []byte
+	contentType := "text/plain"
+	if s.format == "json" {
+		body = []byte("[" + strings.Join(lines, ",") + "]")
+		contentType = "application/json"
+	} else {
+		body = []byte(strings.Join(lines, "\n") + "\n")
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.client.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("SIEM endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *httpSink) Close() error {
+	return nil
+}
+
+// newSIEMSink parses the endpoint: udp://host:port or tcp://host:port for syslog, or an
+// http(s) URL for a collector accepting POSTs
+func newSIEMSink(endpoint, format, token string) (siemSink, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "udp", "tcp":
+		if u.Host == "" {
+			return nil, fmt.Errorf("syslog endpoint %q has no host", endpoint)
+		}
+		hostname, _ := os.Hostname()
+		if hostname == "" {
+			hostname = "-"
+		}
+		return &syslogSink{network: u.Scheme, addr: u.Host, hostname: hostname}, nil
+	case "https", "http":
+		// Checked up front so a collector the egress policy refuses fails startup rather than
+		// every delivery; one on a private network needs TODO_EGRESS_ALLOW_PRIVATE
+		client := NewHTTPClient()
+		if err := client.CheckURL(u); err != nil {
+			return nil, fmt.Errorf("SIEM endpoint %q: %w", endpoint, err)
+		}
+		return &httpSink{url: endpoint, token: token, format: format, client: client}, nil
+	}
+	return nil, fmt.Errorf("unsupported SIEM endpoint scheme %q, expected udp, tcp, http or https", u.Scheme)
+}
+
+// SIEMExporter forwards the task audit history and security events (rejected request
+// signatures, refused outbound requests) to a SIEM. It is configured apart from the
+// OpenTelemetry exporters, since audit records usually go to a different team and system.
+//
+// Task events are read from task_events after a persisted cursor, so only committed
+// changes are sent and nothing is lost across restarts; a failed delivery is retried on the
+// next run. Security events are queued in memory.
+type SIEMExporter struct {
+	db       *store.DB
+	sink     siemSink
+	format   string
+	isLeader func() bool
+
+	mu      sync.Mutex
+	pending []store.AuditEvent
+
+	exported metric.Int64Counter
+	dropped  metric.Int64Counter
+}
+
+// AuditExporter receives security events; nil when no SIEM is configured
+var AuditExporter *SIEMExporter
+
+// NewSIEMExporter returns the exporter configured by TODO_SIEM_*, or nil when
+// TODO_SIEM_ENDPOINT is unset
+func NewSIEMExporter(db *store.DB) (*SIEMExporter, error) {
+	endpoint := config.EnvString("TODO_SIEM_ENDPOINT", "")
+	if endpoint == "" {
+		return nil, nil
+	}
+	format := config.EnvString("TODO_SIEM_FORMAT", "json")
+	if format != "json" && format != "cef" {
+		return nil, fmt.Errorf("unsupported TODO_SIEM_FORMAT %q, expected json or cef", format)
+	}
+	sink, err := newSIEMSink(endpoint, format, config.EnvString("TODO_SIEM_TOKEN", ""))
+	if err != nil {
+		return nil, err
+	}
+
+	meter := telemetry.GetMeter()
+	e := &SIEMExporter{db: db, sink: sink, format: format}
+	e.exported, _ = meter.Int64Counter("todo_app.siem.exported",
+		metric.WithDescription("Audit and security events forwarded to the SIEM"),
+		metric.WithUnit("1"))
+	e.dropped, _ = meter.Int64Counter("todo_app.siem.dropped",
+		metric.WithDescription("Security events dropped because the SIEM queue was full"),
+		metric.WithUnit("1"))
+	return e, nil
+}
+
+// securityListeners receive the security events recorded on this replica, for the admin
+// event stream
+var (
+	securityListenersMu sync.Mutex
+	securityListeners   = map[chan store.AuditEvent]struct{}{}
+)
+
+// ListenSecurityEvents returns a channel receiving the security events recorded on this
+// replica from now on, holding up to buffer of them; events it has no room for are
+// dropped. The returned func stops listening.
+func ListenSecurityEvents(buffer int) (<-chan store.AuditEvent, func()) {
+	ch := make(chan store.AuditEvent, buffer)
+	securityListenersMu.Lock()
+	defer securityListenersMu.Unlock()
+	securityListeners[ch] = struct{}{}
+	return ch, func() {
+		securityListenersMu.Lock()
+		defer securityListenersMu.Unlock()
+		delete(securityListeners, ch)
+	}
+}
+
+// RecordSecurityEvent queues a security event for the SIEM, if one is configured, and
+// hands it to the security event listeners
+func RecordSecurityEvent(ctx context.Context, eventType string, severity int, details map[string]string) {
+	e := store.AuditEvent{
+		Time:     time.Now().UTC(),
+		Category: store.AuditCategorySecurity,
+		Type:     eventType,
+		Outcome:  "failure",
+		Severity: severity,
+		Actor:    requestctx.User(ctx),
+		Details:  details,
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		e.TraceID = sc.TraceID().String()
+	}
+
+	securityListenersMu.Lock()
+	for ch := range securityListeners {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+	securityListenersMu.Unlock()
+
+	if AuditExporter != nil {
+		AuditExporter.enqueue(ctx, e)
+	}
+}
+
+func (e *SIEMExporter) enqueue(ctx context.Context, event store.AuditEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.pending) >= siemQueueSize {
+		e.dropped.Add(ctx, 1)
+		return
+	}
+	e.pending = append(e.pending, event)
+}
+
+// Job returns the job that forwards events every siemInterval
+// The job runs on every replica, since each queues its own security events; only the
+// leader exports the task history.
+func (e *SIEMExporter) Job() scheduler.Job {
+	return scheduler.Job{Name: "siem_export", Interval: siemInterval, Run: e.export, EveryReplica: true}
+}
+
+// SetLeader makes only the replica for which isLeader reports true export the task history,
+// so it is sent once per cluster
+func (e *SIEMExporter) SetLeader(isLeader func() bool) {
+	e.isLeader = isLeader
+}
+
+// Close closes the connection to the SIEM
+func (e *SIEMExporter) Close() error {
+	return e.sink.Close()
+}
+
+func (e *SIEMExporter) export(ctx context.Context) error {
+	span := trace.SpanFromContext(ctx)
+
+	e.mu.Lock()
+	security := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+
+	var cursor int64
+	var tasks []store.AuditEvent
+	if e.isLeader == nil || e.isLeader() {
+		var err error
+		cursor, tasks, err = e.db.AuditEventsAfterCursor(ctx, siemCursor, siemBatchSize)
+		if err != nil {
+			e.requeue(security)
+			return err
+		}
+	}
+
+	events := append(security, tasks...)
+	span.SetAttributes(
+		attribute.Int("siem.security_events", len(security)),
+		attribute.Int("siem.task_events", len(tasks)),
+	)
+	if len(events) == 0 {
+		return nil
+	}
+
+	lines := make([]string, 0, len(events))
+	for _, event := range events {
+		line, err := formatAuditEvent(e.format, event)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, line)
+	}
+	if err := e.sink.Send(ctx, lines); err != nil {
+		e.requeue(security)
+		return fmt.Errorf("sending to SIEM: %w", err)
+	}
+	if len(tasks) > 0 {
+		if err := e.db.SetExportCursor(ctx, siemCursor, cursor); err != nil {
+			return err
+		}
+	}
+	e.exported.Add(ctx, int64(len(events)))
+	slog.DebugContext(ctx, "Forwarded events to SIEM", "count", len(events))
+	return nil
+}
+
+// requeue puts security events back after a failed delivery, ahead of newer ones
+func (e *SIEMExporter) requeue(events []store.AuditEvent) {
+	if len(events) == 0 {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pending = append(events, e.pending...)
+	if over := len(e.pending) - siemQueueSize; over > 0 {
+		e.dropped.Add(context.Background(), int64(over))
+		e.pending = e.pending[over:]
+	}
+}
@@ -0,0 +1,42 @@
+package integrations
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"todo-app/internal/requestctx"
+)
+
+func TestSIEMHTTPSinkFollowsEgressPolicy(t *testing.T) {
+	var got string
+	var requestID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = string(body)
+		requestID = r.Header.Get("X-Request-ID")
+	}))
+	t.Cleanup(server.Close)
+
+	if _, err := newSIEMSink(server.URL, "json", ""); err == nil {
+		t.Fatal("a loopback collector was accepted without TODO_EGRESS_ALLOW_PRIVATE")
+	}
+
+	t.Setenv("TODO_EGRESS_ALLOW_PRIVATE", "true")
+	sink, err := newSIEMSink(server.URL, "json", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := requestctx.WithRequestID(context.Background(), "req-1")
+	if err := sink.Send(ctx, []string{`{"event":"a"}`, `{"event":"b"}`}); err != nil {
+		t.Fatal(err)
+	}
+	if got != `[{"event":"a"},{"event":"b"}]` {
+		t.Errorf("collector got %q, want the batch as a JSON array", got)
+	}
+	if requestID != "req-1" {
+		t.Errorf("X-Request-ID = %q, want the request ID from the context", requestID)
+	}
+}
@@ -0,0 +1,102 @@
+package integrations
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/scheduler"
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// staleReportInterval is how often the stale tasks report is sent
+	staleReportInterval = config.EnvDuration("TODO_STALE_REPORT_INTERVAL", 7*24*time.Hour)
+	// staleReportLimit is the most tasks one report lists, those unchanged the longest
+	staleReportLimit = config.EnvInt("TODO_STALE_REPORT_LIMIT", 50)
+)
+
+// staleReportCursor is the export cursor recording when the last report was sent, so a
+// restart does not send it again; its position is the number of tasks the report listed
+const staleReportCursor = "stale_report"
+
+// staleReportCheck is how often the job checks whether a report is due
+const staleReportCheck = time.Hour
+
+// NewStaleReportJob returns a job that sends the stale tasks to the notifiers every
+// TODO_STALE_REPORT_INTERVAL, as one digest to notifiers that take digests. A report that
+// fails for any notifier is sent again at the next check.
+func NewStaleReportJob(db *store.DB, notifiers []Notifier) scheduler.Job {
+	sent, _ := telemetry.GetMeter().Int64Counter("todo_app.stale_reports.sent",
+		metric.WithDescription("Number of stale tasks reports sent, by notifier and outcome"),
+		metric.WithUnit("1"))
+
+	return scheduler.Job{
+		Name:     "stale_report",
+		Interval: staleReportCheck,
+		Run: func(ctx context.Context) error {
+			span := trace.SpanFromContext(ctx)
+			if len(notifiers) == 0 {
+				return nil
+			}
+			last, err := db.ExportCursorTime(ctx, staleReportCursor)
+			if err != nil {
+				return err
+			}
+			if !last.IsZero() && time.Since(last) < staleReportInterval {
+				span.SetAttributes(attribute.Bool("stale_report.due", false))
+				return nil
+			}
+
+			tasks, err := db.GetStaleTasks(ctx, staleReportLimit)
+			if err != nil {
+				return err
+			}
+			span.SetAttributes(attribute.Bool("stale_report.due", true), attribute.Int("stale_report.tasks", len(tasks)))
+			if len(tasks) > 0 {
+				items := make([]NotificationItem, len(tasks))
+				for i := range tasks {
+					items[i] = NotificationItem{Event: store.EventTaskStale, Task: tasks[i]}
+				}
+				var errs []error
+				for _, notifier := range notifiers {
+					outcome := "success"
+					if err := sendStaleReport(ctx, notifier, items); err != nil {
+						outcome = "error"
+						errs = append(errs, err)
+					}
+					sent.Add(ctx, 1, metric.WithAttributes(
+						attribute.String("notifier", notifier.Name()),
+						attribute.String("outcome", outcome),
+					))
+				}
+				if err := errors.Join(errs...); err != nil {
+					return err
+				}
+				slog.InfoContext(ctx, "Sent stale tasks report", "tasks", len(tasks), "stale_days", store.StaleDays)
+			}
+			return db.SetExportCursor(ctx, staleReportCursor, int64(len(tasks)))
+		},
+	}
+}
+
+// sendStaleReport delivers the report as one digest, or task by task to a notifier
+// without digests
+func sendStaleReport(ctx context.Context, notifier Notifier, items []NotificationItem) error {
+	if digest, ok := notifier.(DigestNotifier); ok && len(items) > 1 {
+		return digest.NotifyDigest(ctx, items)
+	}
+	for i := range items {
+		if err := notifier.Notify(ctx, items[i].Event, &items[i].Task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
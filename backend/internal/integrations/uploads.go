@@ -0,0 +1,141 @@
+package integrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/scheduler"
+	"todo-app/internal/store"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// uploadCleanupInterval is how often abandoned uploads are removed
+var uploadCleanupInterval = config.EnvDuration("TODO_UPLOAD_CLEANUP_INTERVAL", time.Hour)
+
+// UploadStaging keeps the bytes of chunked uploads received so far, one file per upload in
+// dir, until they are complete and go to the attachment storage. Every replica must see the
+// same directory, since the chunks of one upload may reach different replicas.
+type UploadStaging struct {
+	dir string
+}
+
+// NewUploadStaging stages uploads in TODO_UPLOAD_DIR
+func NewUploadStaging() (*UploadStaging, error) {
+	dir, err := filepath.Abs(config.EnvString("TODO_UPLOAD_DIR", "uploads"))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create TODO_UPLOAD_DIR: %w", err)
+	}
+	return &UploadStaging{dir: dir}, nil
+}
+
+// path returns the staging file of an upload; IDs are UUIDs, so one cannot leave dir
+func (s *UploadStaging) path(id string) (string, error) {
+	if id == "" || filepath.Base(id) != id {
+		return "", fmt.Errorf("invalid upload ID %q", id)
+	}
+	return filepath.Join(s.dir, id), nil
+}
+
+// Write stages the bytes of r at offset and returns how many were written and synced. On a
+// broken connection that is the part of the chunk that arrived, which the upload keeps.
+func (s *UploadStaging) Write(id string, offset int64, r io.Reader) (int64, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o640)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, copyErr := io.Copy(f, r)
+	if err := f.Sync(); err != nil {
+		return 0, err
+	}
+	return n, copyErr
+}
+
+// Open returns the staging file of an upload, for its first size bytes to be stored
+func (s *UploadStaging) Open(id string) (*os.File, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Remove deletes the staging file of an upload; removing one that is not there succeeds
+func (s *UploadStaging) Remove(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// NewUploadCleanupJob returns a job that forgets uploads without a chunk for
+// TODO_UPLOAD_TTL and removes their staging files, along with any staging file left that
+// old, such as one whose upload was completed or canceled while a chunk was being written
+func NewUploadCleanupJob(db *store.DB, staging *UploadStaging) scheduler.Job {
+	return scheduler.Job{
+		Name:     "upload_cleanup",
+		Interval: uploadCleanupInterval,
+		Run: func(ctx context.Context) error {
+			cutoff := time.Now().Add(-store.UploadTTL)
+			abandoned, err := db.PurgeAbandonedUploads(ctx, cutoff)
+			if err != nil {
+				return err
+			}
+			var errs []error
+			for _, id := range abandoned {
+				if err := staging.Remove(id); err != nil {
+					errs = append(errs, err)
+				}
+			}
+
+			entries, err := os.ReadDir(staging.dir)
+			if err != nil {
+				return errors.Join(append(errs, err)...)
+			}
+			stale := 0
+			for _, entry := range entries {
+				info, err := entry.Info()
+				if err != nil || entry.IsDir() || info.ModTime().After(cutoff) {
+					continue
+				}
+				if err := staging.Remove(entry.Name()); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				stale++
+			}
+
+			trace.SpanFromContext(ctx).SetAttributes(
+				attribute.Int("upload_cleanup.abandoned", len(abandoned)),
+				attribute.Int("upload_cleanup.stale_files", stale),
+			)
+			if len(abandoned) > 0 {
+				slog.InfoContext(ctx, "Removed abandoned uploads", "count", len(abandoned), "ttl", store.UploadTTL)
+			}
+			return errors.Join(errs...)
+		},
+	}
+}
@@ -0,0 +1,225 @@
+package integrations
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-app/internal/scheduler"
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SignWebhook returns the hex HMAC-SHA256 of "<timestamp>.<body>", as a receiver computes
+// it to check the v1 value of the X-Webhook-Signature header
+func SignWebhook(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookDispatcher queues task events for the webhooks subscribed to them and delivers
+// them, signed, with retries. Deliveries are stored before they are attempted, so an event
+// is not lost to a receiver that is down or to a restart.
+type WebhookDispatcher struct {
+	db     *store.DB
+	client *HTTPClient
+
+	deliveries metric.Int64Counter
+}
+
+func NewWebhookDispatcher(db *store.DB, client *HTTPClient) *WebhookDispatcher {
+	deliveries, _ := telemetry.GetMeter().Int64Counter("todo_app.webhooks.deliveries",
+		metric.WithDescription("Webhook delivery attempts by event and outcome"),
+		metric.WithUnit("1"))
+	return &WebhookDispatcher{db: db, client: client, deliveries: deliveries}
+}
+
+// Validate checks a webhook before it is stored: an allowed URL, known events (all of
+// them when none are given) and a secret, generated when none is given
+func (d *WebhookDispatcher) Validate(webhook *store.Webhook) error {
+	u, err := parseNotifierURL(d.client, "webhook", strings.TrimSpace(webhook.URL))
+	if err != nil {
+		return err
+	}
+	webhook.URL = u
+
+	if len(webhook.Events) == 0 {
+		webhook.Events = slices.Clone(store.BusEvents)
+	}
+	for _, event := range webhook.Events {
+		if !slices.Contains(store.BusEvents, event) {
+			return fmt.Errorf("Invalid event %q, expected one of %s", event, strings.Join(store.BusEvents, ", "))
+		}
+	}
+	slices.Sort(webhook.Events)
+	webhook.Events = slices.Compact(webhook.Events)
+
+	if webhook.Secret == "" {
+		webhook.Secret = "whsec_" + crand.Text()
+	} else if len(webhook.Secret) < store.MinWebhookSecretLength {
+		return fmt.Errorf("Secret must be at least %d characters", store.MinWebhookSecretLength)
+	}
+	return nil
+}
+
+// Enqueue queues event for the webhooks subscribed to it. It is an EventBus hook, so it
+// runs for every change this replica makes; the request's cancellation is ignored because
+// the change has already been committed.
+func (d *WebhookDispatcher) Enqueue(ctx context.Context, event store.BusEvent) {
+	ctx = context.WithoutCancel(ctx)
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error encoding webhook payload", "event", event.Type, "error", err)
+		return
+	}
+	n, err := d.db.QueueWebhookDeliveries(ctx, event.Type, payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error queueing webhook deliveries", "event", event.Type, "task_id", event.TaskID, "error", err)
+		return
+	}
+	if n > 0 {
+		slog.InfoContext(ctx, "Queued webhook deliveries", "event", event.Type, "task_id", event.TaskID, "count", n)
+	}
+}
+
+// NewWebhookDeliveryJob returns a job that sends due webhook deliveries and clears out old
+// delivered ones
+func NewWebhookDeliveryJob(d *WebhookDispatcher) scheduler.Job {
+	return scheduler.Job{
+		Name:     "webhook_deliveries",
+		Interval: store.WebhookInterval,
+		Run: func(ctx context.Context) error {
+			deliveries, err := d.db.DueWebhookDeliveries(ctx, time.Now(), store.WebhookBatchSize)
+			if err != nil {
+				return err
+			}
+			trace.SpanFromContext(ctx).SetAttributes(attribute.Int("webhook.due_deliveries", len(deliveries)))
+			for i := range deliveries {
+				if err := d.deliver(ctx, &deliveries[i]); err != nil {
+					return err
+				}
+			}
+
+			purged, err := d.db.PurgeWebhookDeliveries(ctx, time.Now().Add(-store.WebhookRetention))
+			if err != nil {
+				return err
+			}
+			if purged > 0 {
+				slog.InfoContext(ctx, "Purged delivered webhook deliveries", "count", purged)
+			}
+			return nil
+		},
+	}
+}
+
+// deliver makes one attempt at a delivery and records the outcome. A failed attempt is
+// retried after a backoff until WebhookMaxAttempts; only an error recording the outcome
+// is returned. The attempt's span links to the change that queued the delivery.
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery *store.WebhookDelivery) error {
+	delivery.Attempts++
+	opts := []trace.SpanStartOption{trace.WithAttributes(
+		attribute.Int("webhook.id", delivery.WebhookID),
+		attribute.Int("webhook.delivery_id", delivery.ID),
+		attribute.String("webhook.event", delivery.Event),
+		attribute.Int("webhook.attempt", delivery.Attempts),
+	)}
+	if link, ok := originLink(delivery.TraceID, delivery.SpanID); ok {
+		opts = append(opts, trace.WithLinks(link))
+	}
+	ctx, span := telemetry.GetTracer().Start(ctx, "webhook.deliver", opts...)
+	defer span.End()
+
+	statusCode, err := d.send(ctx, delivery)
+	recentIntegrations.record(integrationWebhooks, err)
+	now := time.Now().UTC()
+	if statusCode != 0 {
+		delivery.LastStatusCode = &statusCode
+	}
+
+	outcome := store.WebhookDelivered
+	if err == nil {
+		delivery.Status, delivery.DeliveredAt, delivery.LastError = store.WebhookDelivered, &now, nil
+		slog.InfoContext(ctx, "Webhook delivered", "webhook_id", delivery.WebhookID, "delivery_id", delivery.ID,
+			"event", delivery.Event, "attempt", delivery.Attempts)
+	} else {
+		msg := err.Error()
+		delivery.LastError = &msg
+		span.RecordError(err)
+		span.SetStatus(codes.Error, msg)
+		if delivery.Attempts >= store.WebhookMaxAttempts {
+			outcome, delivery.Status, delivery.FailedAt = store.WebhookFailed, store.WebhookFailed, &now
+			slog.ErrorContext(ctx, "Webhook delivery failed, giving up", "webhook_id", delivery.WebhookID,
+				"delivery_id", delivery.ID, "attempts", delivery.Attempts, "error", err)
+		} else {
+			outcome = "retry"
+			next := now.Add(store.BackoffAfter(store.WebhookBackoff, delivery.Attempts))
+			delivery.NextAttemptAt = &next
+			span.SetAttributes(attribute.String("webhook.next_attempt_at", next.Format(time.RFC3339)))
+			slog.WarnContext(ctx, "Webhook delivery failed, will retry", "webhook_id", delivery.WebhookID,
+				"delivery_id", delivery.ID, "attempt", delivery.Attempts, "next_attempt_at", next, "error", err)
+		}
+	}
+	span.SetAttributes(attribute.String("webhook.outcome", outcome))
+	d.deliveries.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("event", delivery.Event),
+		attribute.String("outcome", outcome),
+	))
+	return d.db.UpdateWebhookDelivery(ctx, delivery)
+}
+
+// send posts the payload, signed with the webhook's secret, and returns the response status
+func (d *WebhookDispatcher) send(ctx context.Context, delivery *store.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "todo-app/1.0")
+	req.Header.Set(store.WebhookEventHeader, delivery.Event)
+	req.Header.Set(store.WebhookDeliveryHeader, strconv.Itoa(delivery.ID))
+	req.Header.Set(store.WebhookSignatureHeader, "t="+timestamp+",v1="+SignWebhook([]byte(delivery.Secret), timestamp, delivery.Payload))
+
+	resp, err := d.client.DoWithBodyCapture(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// originLink is a link to the span of the change that queued a delivery or notification,
+// from the IDs stored with it
+func originLink(hexTraceID, hexSpanID string) (trace.Link, bool) {
+	traceID, err := trace.TraceIDFromHex(hexTraceID)
+	if err != nil {
+		return trace.Link{}, false
+	}
+	spanID, err := trace.SpanIDFromHex(hexSpanID)
+	if err != nil {
+		return trace.Link{}, false
+	}
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled, Remote: true})
+	return trace.Link{SpanContext: sc}, true
+}
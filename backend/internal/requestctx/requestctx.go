@@ -0,0 +1,115 @@
+// Package requestctx carries what is known about the request being served through its
+// context: its ID, the user and tenant it acts for, the experimental features it turned on
+// and its locale. The API middleware sets these once, so handlers, the store, notifiers and
+// loggers read them from any context derived from the request instead of taking a request.
+package requestctx
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/text/language"
+)
+
+// DefaultUser is used when a request does not name a user
+const DefaultUser = "default"
+
+type (
+	requestIDKey struct{}
+	userKey      struct{}
+	tenantKey    struct{}
+	featuresKey  struct{}
+	localeKey    struct{}
+	baggageKey   struct{}
+)
+
+// WithRequestID returns a context carrying the ID of the request it belongs to
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the ID of the request ctx belongs to, or "" outside a request
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithUser returns a context carrying the user a request acts for
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// User returns the user the request ctx belongs to acts for, or "" outside a request
+func User(ctx context.Context) string {
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
+
+// WithTenant returns a context carrying the tenant a request acts for
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// Tenant returns the tenant the request ctx belongs to acts for, or "" when it names none
+func Tenant(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// WithFeatures returns a context turning experimental features on for one request
+func WithFeatures(ctx context.Context, features []string) context.Context {
+	return context.WithValue(ctx, featuresKey{}, features)
+}
+
+// Features returns the features the request ctx belongs to turned on, and whether it
+// turned any on
+func Features(ctx context.Context) ([]string, bool) {
+	features, ok := ctx.Value(featuresKey{}).([]string)
+	return features, ok
+}
+
+// WithLocale returns a context carrying the locale a request asked for
+func WithLocale(ctx context.Context, locale language.Tag) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// Locale returns the locale the request ctx belongs to asked for, or def outside a request
+func Locale(ctx context.Context, def language.Tag) language.Tag {
+	if locale, ok := ctx.Value(localeKey{}).(language.Tag); ok {
+		return locale
+	}
+	return def
+}
+
+// WithBaggage returns a context carrying the baggage entries of a request worth recording,
+// as baggage.<key> attributes
+func WithBaggage(ctx context.Context, attrs []attribute.KeyValue) context.Context {
+	return context.WithValue(ctx, baggageKey{}, attrs)
+}
+
+// Baggage returns the baggage attributes of the request ctx belongs to, or nil when it
+// carries none
+func Baggage(ctx context.Context) []attribute.KeyValue {
+	attrs, _ := ctx.Value(baggageKey{}).([]attribute.KeyValue)
+	return attrs
+}
+
+// LogAttrs returns the request ID, user, tenant and baggage of ctx as log attributes,
+// leaving out the ones it does not carry
+func LogAttrs(ctx context.Context) []slog.Attr {
+	var attrs []slog.Attr
+	if id := RequestID(ctx); id != "" {
+		attrs = append(attrs, slog.String("request.id", id))
+	}
+	if user := User(ctx); user != "" {
+		attrs = append(attrs, slog.String("user.id", user))
+	}
+	if tenant := Tenant(ctx); tenant != "" {
+		attrs = append(attrs, slog.String("tenant.id", tenant))
+	}
+	for _, kv := range Baggage(ctx) {
+		attrs = append(attrs, slog.String(string(kv.Key), kv.Value.AsString()))
+	}
+	return attrs
+}
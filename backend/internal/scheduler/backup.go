@@ -0,0 +1,23 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+
+	"todo-app/internal/store"
+)
+
+// NewBackupJob returns a job that backs up the database every BackupInterval
+func NewBackupJob(backups *store.Backups) Job {
+	return Job{
+		Name:     "backup",
+		Interval: store.BackupInterval,
+		Run: func(ctx context.Context) error {
+			backup, err := backups.Create(ctx, store.BackupTriggerSchedule)
+			if backup != nil {
+				slog.InfoContext(ctx, "Backed up the database", "name", backup.Name, "size_bytes", backup.SizeBytes)
+			}
+			return err
+		},
+	}
+}
@@ -0,0 +1,314 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// clusterEnabled turns on coordination between replicas sharing the database
+	clusterEnabled = config.EnvBool("TODO_CLUSTER", false)
+	// clusterLeaseTTL is how long leadership survives without renewal, and so the longest
+	// failover after a leader dies without releasing it
+	clusterLeaseTTL = config.EnvDuration("TODO_CLUSTER_LEASE_TTL", 15*time.Second)
+	// clusterHeartbeat is how often a replica reports itself alive and renews its lease
+	clusterHeartbeat = config.EnvDuration("TODO_CLUSTER_HEARTBEAT", 5*time.Second)
+	// clusterRelayInterval is how often task events written by other replicas are picked up
+	clusterRelayInterval = config.EnvDuration("TODO_CLUSTER_RELAY_INTERVAL", time.Second)
+	// clusterLock is where the scheduler lease is held: "database" or "redis"
+	clusterLock = config.EnvString("TODO_CLUSTER_LOCK", "database")
+)
+
+// schedulerLease is the lease whose holder runs the background jobs
+const schedulerLease = "scheduler"
+
+// Cluster coordinates replicas that share the database, so several can run behind a load
+// balancer. Replicas heartbeat into cluster_members; the holder of the scheduler lease, in
+// cluster_leases or Redis, is the leader and the only replica running background jobs, so reminders
+// and digests are sent once; task events written by other replicas are read back from
+// task_events and published on the local event bus, so every /ws client sees every change;
+// signature nonces are kept in the database, so a signed request cannot be replayed against
+// another replica; and the outbound rate limit is shared out between the live replicas.
+//
+// State that only affects one replica's view stays in process: the SLO window, the task
+// gauge cache (only the leader reports the gauges) and open WebSocket connections.
+//
+// When clustering is off this replica is always the leader and nothing is written.
+type Cluster struct {
+	db      *store.DB
+	events  *store.EventBus
+	leases  store.LeaseStore
+	enabled bool
+
+	leader       atomic.Bool
+	leaseExpires atomic.Int64
+	members      atomic.Int64
+	startedAt    time.Time
+
+	mu        sync.Mutex
+	listeners []func(members int)
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	leaderChanges metric.Int64Counter
+	relayed       metric.Int64Counter
+}
+
+// NewCluster creates the coordinator. It does nothing until Start.
+func NewCluster(db *store.DB, events *store.EventBus) *Cluster {
+	c := &Cluster{db: db, events: events, leases: store.NewDBLeaseStore(db), enabled: clusterEnabled, startedAt: time.Now().UTC()}
+	c.members.Store(1)
+	if !c.enabled {
+		c.leader.Store(true)
+	}
+
+	meter := telemetry.GetMeter()
+	c.leaderChanges, _ = meter.Int64Counter("todo_app.cluster.leader_changes",
+		metric.WithDescription("Times this replica gained or lost the scheduler lease"),
+		metric.WithUnit("1"))
+	c.relayed, _ = meter.Int64Counter("todo_app.cluster.relayed_events",
+		metric.WithDescription("Task events from other replicas published on the local event bus"),
+		metric.WithUnit("1"))
+	meter.Int64ObservableGauge("todo_app.cluster.leader",
+		metric.WithDescription("1 while this replica holds the scheduler lease"),
+		metric.WithUnit("1"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			if c.IsLeader() {
+				o.Observe(1)
+			} else {
+				o.Observe(0)
+			}
+			return nil
+		}))
+	meter.Int64ObservableGauge("todo_app.cluster.members",
+		metric.WithDescription("Live replicas seen at the last heartbeat"),
+		metric.WithUnit("1"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(c.members.Load())
+			return nil
+		}))
+	return c
+}
+
+// ConfigureLock holds the scheduler lease where TODO_CLUSTER_LOCK says. rdb may be nil
+// unless it says redis. Every replica must use the same lock, or each could lead.
+func (c *Cluster) ConfigureLock(rdb *redis.Client) error {
+	switch clusterLock {
+	case "database":
+	case "redis":
+		if rdb == nil {
+			return errors.New("TODO_CLUSTER_LOCK=redis needs TODO_REDIS_URL")
+		}
+		c.leases = &redisLeaseStore{rdb: rdb}
+	default:
+		return fmt.Errorf("invalid TODO_CLUSTER_LOCK %q, expected database or redis", clusterLock)
+	}
+	return nil
+}
+
+// Enabled reports whether replicas coordinate through the database
+func (c *Cluster) Enabled() bool {
+	return c.enabled
+}
+
+// IsLeader reports whether this replica should run the background jobs. Leadership lapses
+// on its own once the lease expires without renewal, e.g. while the database is unreachable.
+func (c *Cluster) IsLeader() bool {
+	if !c.enabled {
+		return true
+	}
+	return c.leader.Load() && time.Now().UnixNano() < c.leaseExpires.Load()
+}
+
+// Members returns the number of live replicas, at least 1
+func (c *Cluster) Members() int {
+	return int(c.members.Load())
+}
+
+// OnMembersChange registers f to be called with the number of live replicas whenever it
+// changes. Listeners must be registered before Start.
+func (c *Cluster) OnMembersChange(f func(members int)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listeners = append(c.listeners, f)
+}
+
+// Start joins the cluster and runs the heartbeat and event relay until Stop. The first
+// heartbeat runs before it returns, so leadership is settled when the scheduler starts.
+func (c *Cluster) Start(ctx context.Context) error {
+	if !c.enabled {
+		return nil
+	}
+	ctx, c.cancel = context.WithCancel(ctx)
+
+	cursor, err := c.db.LastTaskEventID(ctx)
+	if err != nil {
+		return err
+	}
+	c.heartbeat(ctx)
+	slog.InfoContext(ctx, "Joined cluster", "instance_id", telemetry.InstanceID, "leader", c.IsLeader(), "members", c.Members())
+
+	c.wg.Add(2)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(clusterHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.heartbeat(ctx)
+			}
+		}
+	}()
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(clusterRelayInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				cursor = c.relay(ctx, cursor)
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop leaves the cluster, releasing the lease so another replica takes over without
+// waiting for it to expire
+func (c *Cluster) Stop() {
+	if !c.enabled || c.cancel == nil {
+		return
+	}
+	c.cancel()
+	c.wg.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.leases.Release(ctx, schedulerLease, telemetry.InstanceID); err != nil {
+		slog.WarnContext(ctx, "Error releasing scheduler lease", "error", err)
+	}
+	if err := c.db.LeaveCluster(ctx, telemetry.InstanceID); err != nil {
+		slog.WarnContext(ctx, "Error leaving cluster", "error", err)
+	}
+	c.leader.Store(false)
+}
+
+// heartbeat records this replica as alive, takes or renews the scheduler lease and counts
+// the live replicas
+func (c *Cluster) heartbeat(ctx context.Context) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "cluster.heartbeat", trace.WithNewRoot(),
+		trace.WithAttributes(attribute.String("service.instance.id", telemetry.InstanceID)))
+	defer span.End()
+
+	now := time.Now().UTC()
+	members, err := c.db.HeartbeatMember(ctx, telemetry.InstanceID, c.startedAt, now, clusterLeaseTTL)
+	if err != nil {
+		span.RecordError(err)
+		slog.WarnContext(ctx, "Cluster heartbeat failed", "error", err)
+	} else if members = max(members, 1); int64(members) != c.members.Swap(int64(members)) {
+		slog.InfoContext(ctx, "Cluster membership changed", "members", members)
+		c.mu.Lock()
+		listeners := c.listeners
+		c.mu.Unlock()
+		for _, f := range listeners {
+			f(members)
+		}
+	}
+
+	acquired, err := c.leases.Acquire(ctx, schedulerLease, telemetry.InstanceID, now, clusterLeaseTTL)
+	if err != nil {
+		// Keep the current state; IsLeader stops reporting leadership once the lease runs out
+		span.RecordError(err)
+		slog.WarnContext(ctx, "Error renewing scheduler lease", "error", err)
+		return
+	}
+	if acquired {
+		c.leaseExpires.Store(now.Add(clusterLeaseTTL).UnixNano())
+	}
+	if was := c.leader.Swap(acquired); was != acquired {
+		c.leaderChanges.Add(ctx, 1, metric.WithAttributes(attribute.Bool("leader", acquired)))
+		if acquired {
+			slog.InfoContext(ctx, "Became cluster leader", "instance_id", telemetry.InstanceID)
+		} else {
+			slog.WarnContext(ctx, "Lost cluster leadership", "instance_id", telemetry.InstanceID)
+		}
+	}
+	span.SetAttributes(attribute.Bool("cluster.leader", acquired), attribute.Int("cluster.members", c.Members()))
+
+	if acquired {
+		if err := c.db.PurgeClusterState(ctx, now, clusterLeaseTTL); err != nil {
+			slog.WarnContext(ctx, "Error purging stale cluster state", "error", err)
+		}
+	}
+}
+
+// relay publishes task events written by other replicas since cursor and returns the new cursor
+func (c *Cluster) relay(ctx context.Context, cursor int64) int64 {
+	events, last, err := c.db.RemoteTaskEvents(ctx, cursor, telemetry.InstanceID, 500)
+	if err != nil {
+		slog.WarnContext(ctx, "Error reading task events from other replicas", "error", err)
+		return cursor
+	}
+	for _, event := range events {
+		c.events.Relay(ctx, event)
+		c.relayed.Add(ctx, 1, metric.WithAttributes(attribute.String("event", event.Type)))
+	}
+	return last
+}
+
+// NonceStore returns where signature nonces are remembered: the database when clustering
+// is on, so every replica sees them, and memory otherwise
+func (c *Cluster) NonceStore(maxAge time.Duration) store.NonceStore {
+	if !c.enabled {
+		return store.NewNonceCache(maxAge)
+	}
+	return store.NewDBNonceStore(c.db, maxAge)
+}
+
+// ClusterStatus is the body of GET /admin/cluster
+type ClusterStatus struct {
+	Enabled    bool                  `json:"enabled"`
+	InstanceID string                `json:"instance_id"`
+	Leader     bool                  `json:"leader"`
+	Members    []store.ClusterMember `json:"members"`
+}
+
+// Status lists the live replicas and the leader. With clustering off, this replica is the
+// only member.
+func (c *Cluster) Status(ctx context.Context) (ClusterStatus, error) {
+	status := ClusterStatus{Enabled: c.enabled, InstanceID: telemetry.InstanceID, Leader: c.IsLeader()}
+	if !c.enabled {
+		host, _ := os.Hostname()
+		status.Members = []store.ClusterMember{{InstanceID: telemetry.InstanceID, Hostname: host, StartedAt: c.startedAt,
+			LastSeen: time.Now().UTC(), Leader: true}}
+		return status, nil
+	}
+	now := time.Now().UTC()
+	leader, err := c.leases.Holder(ctx, schedulerLease, now)
+	if err != nil {
+		return status, err
+	}
+	status.Members, err = c.db.ClusterMembers(ctx, now, clusterLeaseTTL, leader)
+	return status, err
+}
@@ -0,0 +1,27 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"todo-app/internal/store"
+)
+
+// NewHookDeliveryPurgeJob forgets inbound deliveries after HookDeliveryRetention
+func NewHookDeliveryPurgeJob(db *store.DB) Job {
+	return Job{
+		Name:     "hook_delivery_purge",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			purged, err := db.PurgeHookDeliveries(ctx, time.Now().Add(-store.HookDeliveryRetention))
+			if err != nil {
+				return err
+			}
+			if purged > 0 {
+				slog.InfoContext(ctx, "Purged inbound hook deliveries", "count", purged)
+			}
+			return nil
+		},
+	}
+}
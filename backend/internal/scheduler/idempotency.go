@@ -0,0 +1,27 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"todo-app/internal/store"
+)
+
+// NewIdempotencyPurgeJob returns a job that removes keys older than IdempotencyTTL
+func NewIdempotencyPurgeJob(db *store.DB) Job {
+	return Job{
+		Name:     "idempotency_purge",
+		Interval: store.IdempotencyPurgeInterval,
+		Run: func(ctx context.Context) error {
+			purged, err := db.PurgeIdempotencyKeys(ctx, time.Now().Add(-store.IdempotencyTTL))
+			if err != nil {
+				return err
+			}
+			if purged > 0 {
+				slog.InfoContext(ctx, "Purged expired idempotency keys", "count", purged, "ttl", store.IdempotencyTTL)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,261 @@
+// Package scheduler runs the background jobs and coordinates the replicas, so that jobs which
+// must run once per cluster run on the leader only.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Job is a unit of background work run periodically by a Scheduler
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+	// EveryReplica runs the job on every replica of a cluster rather than only the leader,
+	// for jobs that work on the replica's own state
+	EveryReplica bool
+}
+
+// Errors of RunNow
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobRunning  = errors.New("job is already running")
+	// ErrNotLeader is returned for a job that only the cluster leader runs, on another replica
+	ErrNotLeader = errors.New("job runs on the cluster leader only")
+)
+
+// Job run triggers, for the job.trigger span attribute and JobStatus.LastTrigger
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// JobStatus is a registered job as this replica sees it. A job that only the leader runs
+// has no next run on other replicas.
+type JobStatus struct {
+	Name            string     `json:"name"`
+	IntervalSeconds float64    `json:"interval_seconds"`
+	EveryReplica    bool       `json:"every_replica"`
+	Running         bool       `json:"running"`
+	Runs            int        `json:"runs"`
+	LastRunAt       *time.Time `json:"last_run_at"`
+	LastDurationMs  *float64   `json:"last_duration_ms"`
+	LastOutcome     string     `json:"last_outcome,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+	LastTrigger     string     `json:"last_trigger,omitempty"`
+	NextRunAt       *time.Time `json:"next_run_at"`
+}
+
+// scheduledJob is a registered job and what is known of its runs
+type scheduledJob struct {
+	Job
+	// running is held for the length of a run, so runs of a job never overlap
+	running sync.Mutex
+
+	mu     sync.Mutex
+	status JobStatus
+	next   time.Time
+}
+
+// Scheduler runs registered jobs on their intervals until stopped. Each run gets
+// its own root span so background work shows up in traces alongside requests.
+type Scheduler struct {
+	jobs     []*scheduledJob
+	isLeader func() bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	runCounter  metric.Int64Counter
+	runDuration metric.Float64Histogram
+}
+
+func NewScheduler() *Scheduler {
+	meter := telemetry.GetMeter()
+
+	runCounter, _ := meter.Int64Counter("todo_app.jobs.runs",
+		metric.WithDescription("Number of background job runs"),
+		metric.WithUnit("1"))
+
+	runDuration, _ := meter.Float64Histogram("todo_app.jobs.duration",
+		metric.WithDescription("Duration of background job runs"),
+		metric.WithUnit("ms"))
+
+	return &Scheduler{
+		runCounter:  runCounter,
+		runDuration: runDuration,
+	}
+}
+
+// SetLeader makes jobs run only while isLeader reports true, unless they run on every
+// replica, so a cluster sends reminders and purges the trash once
+func (s *Scheduler) SetLeader(isLeader func() bool) {
+	s.isLeader = isLeader
+}
+
+// Add registers a job. Jobs must be added before Start.
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, &scheduledJob{
+		Job: job,
+		status: JobStatus{
+			Name:            job.Name,
+			IntervalSeconds: job.Interval.Seconds(),
+			EveryReplica:    job.EveryReplica,
+		},
+	})
+}
+
+// Start launches every registered job in its own goroutine. Each job runs once
+// immediately and then on every tick of its interval.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.loop(s.ctx, job)
+		}()
+	}
+}
+
+// Stop cancels running jobs and waits for them to return
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+// runsHere reports whether this replica runs job
+func (s *Scheduler) runsHere(job *scheduledJob) bool {
+	return job.EveryReplica || s.isLeader == nil || s.isLeader()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job *scheduledJob) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		job.mu.Lock()
+		job.next = time.Now().UTC().Add(job.Interval)
+		job.mu.Unlock()
+		if s.runsHere(job) {
+			job.running.Lock()
+			s.runOnce(ctx, job, TriggerSchedule)
+			job.running.Unlock()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Jobs returns the status of the registered jobs, in the order they were added
+func (s *Scheduler) Jobs() []JobStatus {
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		statuses = append(statuses, s.jobStatus(job))
+	}
+	return statuses
+}
+
+func (s *Scheduler) jobStatus(job *scheduledJob) JobStatus {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	status := job.status
+	if !job.next.IsZero() && s.runsHere(job) {
+		next := job.next
+		status.NextRunAt = &next
+	}
+	return status
+}
+
+// RunNow runs the job called name at once, outside its schedule, and returns its status
+// after the run. It fails with ErrJobRunning rather than wait for a run in progress, and with
+// ErrNotLeader for a job this replica does not run. The run is cancelled when the scheduler
+// stops, not when ctx is done.
+func (s *Scheduler) RunNow(ctx context.Context, name string) (JobStatus, error) {
+	i := slices.IndexFunc(s.jobs, func(job *scheduledJob) bool { return job.Name == name })
+	if i < 0 {
+		return JobStatus{}, ErrJobNotFound
+	}
+	job := s.jobs[i]
+	if !s.runsHere(job) {
+		return JobStatus{}, ErrNotLeader
+	}
+	if !job.running.TryLock() {
+		return JobStatus{}, ErrJobRunning
+	}
+	defer job.running.Unlock()
+
+	runCtx := s.ctx
+	if runCtx == nil {
+		runCtx = context.WithoutCancel(ctx)
+	}
+	// The run is its own trace; link it to the request that asked for it
+	s.runOnce(runCtx, job, TriggerManual, trace.WithLinks(trace.LinkFromContext(ctx)))
+	return s.jobStatus(job), nil
+}
+
+func (s *Scheduler) runOnce(ctx context.Context, job *scheduledJob, trigger string, opts ...trace.SpanStartOption) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "job."+job.Name,
+		append([]trace.SpanStartOption{
+			trace.WithNewRoot(),
+			trace.WithAttributes(attribute.String("job.name", job.Name), attribute.String("job.trigger", trigger)),
+		}, opts...)...)
+	defer span.End()
+
+	start := time.Now()
+	job.mu.Lock()
+	job.status.Running = true
+	job.mu.Unlock()
+
+	err := job.Run(ctx)
+	elapsed := time.Since(start)
+	duration := float64(elapsed.Milliseconds())
+
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		slog.ErrorContext(ctx, "Background job failed", "job", job.Name, "error", err)
+	}
+
+	job.mu.Lock()
+	job.status.Running = false
+	job.status.Runs++
+	started := start.UTC()
+	job.status.LastRunAt = &started
+	lastDuration := float64(elapsed.Microseconds()) / 1000
+	job.status.LastDurationMs = &lastDuration
+	job.status.LastOutcome = outcome
+	job.status.LastError = ""
+	if err != nil {
+		job.status.LastError = err.Error()
+	}
+	job.status.LastTrigger = trigger
+	job.mu.Unlock()
+
+	attrs := metric.WithAttributes(
+		attribute.String("job", job.Name),
+		attribute.String("outcome", outcome),
+	)
+	s.runCounter.Add(ctx, 1, attrs)
+	s.runDuration.Record(ctx, duration, attrs)
+}
@@ -0,0 +1,55 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"todo-app/internal/store"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisLeaseStore holds leases as Redis keys that expire on their own. Expiry is measured by
+// the Redis server, so the replicas' clocks do not need to agree.
+type redisLeaseStore struct {
+	rdb *redis.Client
+}
+
+// acquireLeaseScript sets the key to the holder if it is free, and extends it if the holder
+// already has it
+var acquireLeaseScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if current == false then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+if current == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+return 0`)
+
+// releaseLeaseScript deletes the key only if the holder still has it
+var releaseLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`)
+
+func (s *redisLeaseStore) Acquire(ctx context.Context, name, holder string, _ time.Time, ttl time.Duration) (bool, error) {
+	n, err := acquireLeaseScript.Run(ctx, s.rdb, []string{store.RedisKey("lease", name)}, holder, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+func (s *redisLeaseStore) Release(ctx context.Context, name, holder string) error {
+	return releaseLeaseScript.Run(ctx, s.rdb, []string{store.RedisKey("lease", name)}, holder).Err()
+}
+
+func (s *redisLeaseStore) Holder(ctx context.Context, name string, _ time.Time) (string, error) {
+	holder, err := s.rdb.Get(ctx, store.RedisKey("lease", name)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return holder, err
+}
@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"todo-app/internal/store"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewRetentionJob returns a job that archives or deletes tasks completed more than
+// RetentionDays ago. Each run is summed up in a retention.run span, and the tasks it removed
+// are counted in todo_app.retention.purged by action; a dry run only logs what it would remove.
+func NewRetentionJob(db *store.DB) Job {
+	purgedCounter, _ := telemetry.GetMeter().Int64Counter("todo_app.retention.purged",
+		metric.WithDescription("Completed tasks removed by the retention job"),
+		metric.WithUnit("1"))
+	retention := time.Duration(store.RetentionDays) * 24 * time.Hour
+
+	return Job{
+		Name:     "retention",
+		Interval: store.RetentionInterval,
+		Run: func(ctx context.Context) error {
+			cutoff := time.Now().Add(-retention)
+			ctx, span := telemetry.GetTracer().Start(ctx, "retention.run",
+				trace.WithAttributes(
+					attribute.Int("retention.days", store.RetentionDays),
+					attribute.String("retention.action", store.RetentionAction),
+					attribute.Bool("retention.dry_run", store.RetentionDryRun),
+					attribute.String("retention.cutoff", cutoff.UTC().Format(time.RFC3339)),
+				))
+			defer span.End()
+
+			purged, err := db.PurgeCompletedTasks(ctx, cutoff, store.RetentionAction, store.RetentionDryRun)
+			if err != nil {
+				return err
+			}
+			span.SetAttributes(attribute.Int64("retention.count", purged))
+
+			if store.RetentionDryRun {
+				slog.InfoContext(ctx, "Retention dry run", "would_remove", purged, "action", store.RetentionAction, "days", store.RetentionDays)
+				return nil
+			}
+			purgedCounter.Add(ctx, purged, metric.WithAttributes(attribute.String("action", store.RetentionAction)))
+			if purged > 0 {
+				slog.InfoContext(ctx, "Removed completed tasks past retention", "count", purged, "action", store.RetentionAction, "days", store.RetentionDays)
+			}
+			return nil
+		},
+	}
+}
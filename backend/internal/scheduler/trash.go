@@ -0,0 +1,27 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"todo-app/internal/store"
+)
+
+// NewTrashPurgeJob returns a job that permanently removes tasks older than TrashRetention
+func NewTrashPurgeJob(db *store.DB) Job {
+	return Job{
+		Name:     "trash_purge",
+		Interval: store.TrashPurgeInterval,
+		Run: func(ctx context.Context) error {
+			purged, err := db.PurgeDeletedTasks(ctx, time.Now().Add(-store.TrashRetention))
+			if err != nil {
+				return err
+			}
+			if purged > 0 {
+				slog.InfoContext(ctx, "Purged deleted tasks", "count", purged, "retention", store.TrashRetention)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,224 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MaxAttachmentSize caps the size of one uploaded file
+var MaxAttachmentSize = int64(config.EnvInt("TODO_MAX_ATTACHMENT_SIZE", 25<<20))
+
+// Attachment is a file uploaded to a task. The content lives in the attachment storage
+// under StorageKey; the row only describes it.
+type Attachment struct {
+	ID          int       `json:"id"`
+	TaskID      int       `json:"task_id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	StorageKey  string    `json:"-"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+const attachmentColumns = `id, task_id, name, content_type, size, sha256, storage_key, created_by, created_at`
+
+func scanAttachment(row rowScanner) (*Attachment, error) {
+	a := &Attachment{}
+	err := row.Scan(&a.ID, &a.TaskID, &a.Name, &a.ContentType, &a.Size, &a.SHA256, &a.StorageKey, &a.CreatedBy, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// CheckAttachmentQuota returns sql.ErrNoRows if the task does not exist or is in the trash,
+// and a QuotaError if its workspace has no room for size more bytes. Uploads check it before
+// storing the file, and CreateAttachment checks it again.
+func (db *DB) CheckAttachmentQuota(ctx context.Context, taskID int, size int64) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.CheckAttachmentQuota",
+		trace.WithAttributes(
+			attribute.String("db.operation", "check_attachment_quota"),
+			attribute.Int("task.id", taskID),
+		))
+	defer span.End()
+
+	return checkAttachmentQuota(ctx, db.conn, taskID, size)
+}
+
+// CreateAttachment records an attachment whose content is already stored, setting its ID
+// and CreatedAt
+func (db *DB) CreateAttachment(ctx context.Context, a *Attachment) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.CreateAttachment",
+		trace.WithAttributes(
+			attribute.String("db.operation", "insert_attachment"),
+			attribute.Int("task.id", a.TaskID),
+			attribute.Int64("attachment.size", a.Size),
+		))
+	defer span.End()
+
+	err := db.WithTx(ctx, "create_attachment", func(ctx context.Context, tx *sql.Tx) error {
+		if err := checkAttachmentQuota(ctx, tx, a.TaskID, a.Size); err != nil {
+			return err
+		}
+		query := `INSERT INTO attachments (task_id, name, content_type, size, sha256, storage_key, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING ` + attachmentColumns
+		start := time.Now()
+		created, err := scanAttachment(db.queryReturning(ctx, tx, "attachments", 0, query,
+			a.TaskID, a.Name, a.ContentType, a.Size, a.SHA256, a.StorageKey, a.CreatedBy, time.Now().UTC()))
+		db.checkSlowQuery(ctx, start, query)
+		if err != nil {
+			return err
+		}
+		*a = *created
+		return nil
+	})
+	if errors.Is(err, ErrQuotaExceeded) || errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// GetAttachments returns the attachments of a task, oldest first, sql.ErrNoRows if the
+// task does not exist or is in the trash
+func (db *DB) GetAttachments(ctx context.Context, taskID int) ([]Attachment, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetAttachments",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_attachments"),
+			attribute.Int("task.id", taskID),
+		))
+	defer span.End()
+
+	if err := taskExists(ctx, db.conn, taskID); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + attachmentColumns + ` FROM attachments WHERE task_id = ? ORDER BY id`
+	start := time.Now()
+	rows, err := db.conn.QueryContext(ctx, query, taskID)
+	db.checkSlowQuery(ctx, start, query, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, *a)
+	}
+	return attachments, rows.Err()
+}
+
+// GetAttachment returns one attachment of a task, sql.ErrNoRows if there is none or the
+// task is in the trash
+func (db *DB) GetAttachment(ctx context.Context, taskID, id int) (*Attachment, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetAttachment",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_attachment"),
+			attribute.Int("task.id", taskID),
+			attribute.Int("attachment.id", id),
+		))
+	defer span.End()
+
+	query := `SELECT ` + attachmentColumns + ` FROM attachments
+	WHERE id = ? AND task_id = ? AND task_id IN (SELECT id FROM tasks WHERE deleted_at IS NULL)`
+	start := time.Now()
+	a, err := scanAttachment(db.conn.QueryRowContext(ctx, query, id, taskID))
+	db.checkSlowQuery(ctx, start, query, id, taskID)
+	return a, err
+}
+
+// DeleteAttachment removes an attachment's row and returns it so the caller can delete its
+// content, sql.ErrNoRows if there is none
+func (db *DB) DeleteAttachment(ctx context.Context, taskID, id int) (*Attachment, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.DeleteAttachment",
+		trace.WithAttributes(
+			attribute.String("db.operation", "delete_attachment"),
+			attribute.Int("task.id", taskID),
+			attribute.Int("attachment.id", id),
+		))
+	defer span.End()
+
+	var deleted *Attachment
+	err := db.WithTx(ctx, "delete_attachment", func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		deleted, err = scanAttachment(tx.QueryRowContext(ctx, `SELECT `+attachmentColumns+` FROM attachments
+		WHERE id = ? AND task_id = ? AND task_id IN (SELECT id FROM tasks WHERE deleted_at IS NULL)`, id, taskID))
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM attachments WHERE id = ?`, id)
+		return err
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return deleted, err
+}
+
+// OrphanedAttachments returns up to limit attachments whose task has been purged, for the
+// content to be deleted before the row with ForgetAttachment
+func (db *DB) OrphanedAttachments(ctx context.Context, limit int) ([]Attachment, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.OrphanedAttachments",
+		trace.WithAttributes(attribute.String("db.operation", "select_orphaned_attachments")))
+	defer span.End()
+
+	query := `SELECT ` + attachmentColumns + ` FROM attachments
+	WHERE NOT EXISTS (SELECT 1 FROM tasks WHERE tasks.id = attachments.task_id)
+	ORDER BY id LIMIT ?`
+	start := time.Now()
+	rows, err := db.conn.QueryContext(ctx, query, limit)
+	db.checkSlowQuery(ctx, start, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []Attachment
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, *a)
+	}
+	return attachments, rows.Err()
+}
+
+// ForgetAttachment removes an attachment's row once its content is gone
+func (db *DB) ForgetAttachment(ctx context.Context, id int) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.ForgetAttachment",
+		trace.WithAttributes(
+			attribute.String("db.operation", "delete_attachment_row"),
+			attribute.Int("attachment.id", id),
+		))
+	defer span.End()
+
+	_, err := db.conn.ExecContext(ctx, `DELETE FROM attachments WHERE id = ?`, id)
+	return err
+}
+
+// taskExists returns sql.ErrNoRows unless the task exists and is not in the trash
+func taskExists(ctx context.Context, q queryer, taskID int) error {
+	var id int
+	return q.QueryRowContext(ctx, `SELECT id FROM tasks WHERE id = ? AND deleted_at IS NULL`, taskID).Scan(&id)
+}
@@ -0,0 +1,405 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ErrBackupUnsupported is returned by NewBackups for a database other than a SQLite file
+	ErrBackupUnsupported = errors.New("backups are only supported for SQLite databases")
+	// ErrBackupNotFound is returned for a backup name that is not in the backup directory
+	ErrBackupNotFound = errors.New("backup not found")
+	// ErrBackupInvalid is returned when restoring a file that is not a backup of this app
+	ErrBackupInvalid = errors.New("not a valid backup")
+	// ErrBackupSchemaMismatch is returned when restoring a backup taken at another schema version
+	ErrBackupSchemaMismatch = errors.New("backup schema version does not match the database")
+)
+
+// BackupInterval is how often the backup job runs; 0, the default, turns it off
+var BackupInterval = config.EnvDuration("TODO_BACKUP_INTERVAL", 0)
+
+// Backup triggers, for the todo_app.backup.runs metric
+const (
+	BackupTriggerAPI      = "api"
+	BackupTriggerSchedule = "schedule"
+	BackupTriggerCLI      = "cli"
+)
+
+// backupDir is where backups are written, TODO_BACKUP_DIR
+func backupDir() string {
+	return config.EnvString("TODO_BACKUP_DIR", "./backups")
+}
+
+// Backup is a backup file in the backup directory
+type Backup struct {
+	Name      string    `json:"name"`
+	SizeBytes int64     `json:"size_bytes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const (
+	backupPrefix = "tasks-"
+	// preMigrationPrefix, followed by the schema version and a dash, names the backups taken
+	// before startup migrates the database. Pruning leaves them alone, as they are the way
+	// back to the release before an upgrade.
+	preMigrationPrefix = "pre-migrate-v"
+	backupSuffix       = ".db"
+	// backupTimeFormat sorts in time order, so names do too
+	backupTimeFormat = "20060102T150405.000Z"
+)
+
+// backupSkipTables are not restored: they hold the state of the running servers rather than
+// data, and the change log must keep counting up so sync clients see the restore
+var backupSkipTables = []string{
+	"schema_migrations", "changelog", "cluster_members", "cluster_leases", "signature_nonces", "idempotency_keys",
+}
+
+// Backups takes online backups of a SQLite database into TODO_BACKUP_DIR and restores them.
+// A backup is a consistent copy written with VACUUM INTO while the server keeps serving;
+// after each one only the newest TODO_BACKUP_KEEP are kept.
+type Backups struct {
+	db   *DB
+	dir  string
+	keep int
+
+	runs        metric.Int64Counter
+	size        metric.Int64Histogram
+	lastSuccess atomic.Int64
+}
+
+// NewBackups returns the backups of db, or ErrBackupUnsupported when it is not a SQLite file
+func NewBackups(db *DB) (*Backups, error) {
+	if !db.SupportsSnapshots() {
+		return nil, ErrBackupUnsupported
+	}
+	b := &Backups{
+		db:   db,
+		dir:  backupDir(),
+		keep: config.EnvInt("TODO_BACKUP_KEEP", 7),
+	}
+	meter := telemetry.GetMeter()
+	b.runs, _ = meter.Int64Counter("todo_app.backup.runs",
+		metric.WithDescription("Database backups and restores by operation, trigger and result"),
+		metric.WithUnit("1"))
+	b.size, _ = meter.Int64Histogram("todo_app.backup.size",
+		metric.WithDescription("Size of the database backups written"),
+		metric.WithUnit("By"))
+	meter.Int64ObservableGauge("todo_app.backup.last_success",
+		metric.WithDescription("Unix time of the last successful backup, 0 before the first"),
+		metric.WithUnit("s"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(b.lastSuccess.Load())
+			return nil
+		}))
+	return b, nil
+}
+
+// Create writes a new backup, then removes the oldest beyond TODO_BACKUP_KEEP
+func (b *Backups) Create(ctx context.Context, trigger string) (*Backup, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.Backup",
+		trace.WithAttributes(
+			attribute.String("db.operation", "backup"),
+			attribute.String("backup.trigger", trigger),
+		))
+	defer span.End()
+
+	backup, err := b.create(ctx)
+	b.record(ctx, "backup", trigger, err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	b.lastSuccess.Store(backup.CreatedAt.Unix())
+	b.size.Record(ctx, backup.SizeBytes)
+	span.SetAttributes(attribute.String("backup.name", backup.Name), attribute.Int64("backup.size_bytes", backup.SizeBytes))
+
+	if err := b.prune(); err != nil {
+		// The backup itself succeeded
+		span.RecordError(err)
+		return backup, fmt.Errorf("removing old backups: %w", err)
+	}
+	return backup, nil
+}
+
+func (b *Backups) create(ctx context.Context) (*Backup, error) {
+	return b.db.backupInto(ctx, b.dir, backupPrefix)
+}
+
+// backupInto writes a backup of the database into dir, named prefix and the time, so it
+// can be listed and restored
+func (db *DB) backupInto(ctx context.Context, dir, prefix string) (*Backup, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	name := prefix + now.Format(backupTimeFormat) + backupSuffix
+	path := filepath.Join(dir, name)
+	if err := db.SnapshotTo(ctx, path); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Backup{Name: name, SizeBytes: info.Size(), CreatedAt: now}, nil
+}
+
+// SupportsSnapshots reports whether SnapshotTo can copy the database, which only a SQLite
+// file can be
+func (db *DB) SupportsSnapshots() bool {
+	return db.dialect == dialectSQLite
+}
+
+// SnapshotTo writes a consistent copy of a SQLite database to path with VACUUM INTO, while
+// the server keeps serving. Other databases return ErrBackupUnsupported.
+func (db *DB) SnapshotTo(ctx context.Context, path string) error {
+	if !db.SupportsSnapshots() {
+		return ErrBackupUnsupported
+	}
+	if _, err := db.conn.ExecContext(ctx, `VACUUM INTO ?`, path); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// prune removes the oldest backups beyond the number to keep. Pre-migration backups are
+// neither counted nor removed.
+func (b *Backups) prune() error {
+	backups, err := b.List()
+	if err != nil {
+		return err
+	}
+	backups = slices.DeleteFunc(backups, func(backup Backup) bool {
+		_, preMigration, _ := parseBackupName(backup.Name)
+		return preMigration
+	})
+	var errs []error
+	for _, backup := range backups[min(b.keep, len(backups)):] {
+		errs = append(errs, os.Remove(filepath.Join(b.dir, backup.Name)))
+	}
+	return errors.Join(errs...)
+}
+
+// List returns the backups in the backup directory, newest first
+func (b *Backups) List() ([]Backup, error) {
+	entries, err := os.ReadDir(b.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Backup{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	backups := []Backup{}
+	for _, entry := range entries {
+		created, _, ok := parseBackupName(entry.Name())
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{Name: entry.Name(), SizeBytes: info.Size(), CreatedAt: created})
+	}
+	slices.SortFunc(backups, func(a, b Backup) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(b.Name, a.Name)
+	})
+	return backups, nil
+}
+
+// parseBackupName returns the time in a backup file name, whether it is a pre-migration
+// backup, and whether name is a backup at all
+func parseBackupName(name string) (created time.Time, preMigration bool, ok bool) {
+	stamp, ok := strings.CutPrefix(name, backupPrefix)
+	if !ok {
+		var version string
+		if stamp, preMigration = strings.CutPrefix(name, preMigrationPrefix); !preMigration {
+			return time.Time{}, false, false
+		}
+		if version, stamp, ok = strings.Cut(stamp, "-"); !ok {
+			return time.Time{}, false, false
+		}
+		if _, err := strconv.Atoi(version); err != nil {
+			return time.Time{}, false, false
+		}
+	}
+	if stamp, ok = strings.CutSuffix(stamp, backupSuffix); !ok {
+		return time.Time{}, false, false
+	}
+	created, err := time.Parse(backupTimeFormat, stamp)
+	return created, preMigration, err == nil
+}
+
+// Path returns the path of the backup called name, or ErrBackupNotFound
+func (b *Backups) Path(name string) (string, error) {
+	if _, _, ok := parseBackupName(name); !ok || filepath.Base(name) != name {
+		return "", ErrBackupNotFound
+	}
+	path := filepath.Join(b.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrBackupNotFound
+	}
+	return path, nil
+}
+
+// Restore replaces the data in the database with the backup file at path, in one
+// transaction, while the server keeps serving. The backup must be at the database's schema
+// version. Every task that existed before or after is recorded in the change log, so sync
+// clients pick up the restore; IDs are not reused, as new tasks keep numbering from where
+// the database was.
+func (b *Backups) Restore(ctx context.Context, path, trigger string) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.Restore",
+		trace.WithAttributes(
+			attribute.String("db.operation", "restore"),
+			attribute.String("backup.trigger", trigger),
+			attribute.String("backup.name", filepath.Base(path)),
+		))
+	defer span.End()
+
+	err := b.restore(ctx, path)
+	b.record(ctx, "restore", trigger, err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+func (b *Backups) restore(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return ErrBackupNotFound
+	}
+
+	// ATTACH applies to one connection and cannot run inside a transaction
+	conn, err := b.db.conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `ATTACH DATABASE ? AS backup`, path); err != nil {
+		return fmt.Errorf("%w: %v", ErrBackupInvalid, err)
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), `DETACH DATABASE backup`)
+
+	var backupVersion, version int
+	if err := conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM backup.schema_migrations`).Scan(&backupVersion); err != nil {
+		return fmt.Errorf("%w: %v", ErrBackupInvalid, err)
+	}
+	if err := conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM main.schema_migrations`).Scan(&version); err != nil {
+		return err
+	}
+	if backupVersion != version {
+		return fmt.Errorf("%w: backup is at version %d, the database at %d", ErrBackupSchemaMismatch, backupVersion, version)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// Tables are emptied and refilled one at a time, so references only hold at the end
+	if _, err := tx.ExecContext(ctx, `PRAGMA defer_foreign_keys = ON`); err != nil {
+		return err
+	}
+
+	before, err := selectIDs(ctx, tx, `SELECT id FROM main.tasks`)
+	if err != nil {
+		return err
+	}
+	tables, err := selectStrings(ctx, tx, `SELECT name FROM main.sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if slices.Contains(backupSkipTables, table) {
+			continue
+		}
+		columns, err := selectStrings(ctx, tx, `SELECT name FROM pragma_table_info(?, 'main')`, table)
+		if err != nil {
+			return err
+		}
+		quoted := make([]string, len(columns))
+		for i, column := range columns {
+			quoted[i] = quoteIdentifier(column)
+		}
+		list := strings.Join(quoted, ", ")
+		if _, err := tx.ExecContext(ctx, `DELETE FROM main.`+quoteIdentifier(table)); err != nil {
+			return fmt.Errorf("restoring %s: %w", table, err)
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO main.`+quoteIdentifier(table)+` (`+list+`) SELECT `+list+` FROM backup.`+quoteIdentifier(table))
+		if err != nil {
+			return fmt.Errorf("restoring %s: %w", table, err)
+		}
+	}
+
+	// The restored tasks changed now, as far as delta sync and ETags are concerned
+	if _, err := tx.ExecContext(ctx, `UPDATE main.tasks SET updated_at = ?`, time.Now().UTC()); err != nil {
+		return err
+	}
+	after, err := selectIDs(ctx, tx, `SELECT id FROM main.tasks`)
+	if err != nil {
+		return err
+	}
+	changed := append(after, before...)
+	slices.Sort(changed)
+	if err := b.db.logTaskChange(ctx, tx, slices.Compact(changed)...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (b *Backups) record(ctx context.Context, operation, trigger string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	b.runs.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("operation", operation),
+		attribute.String("trigger", trigger),
+		attribute.String("result", result),
+	))
+}
+
+// selectStrings returns the single string column of a query's rows
+func selectStrings(ctx context.Context, q queryer, query string, args ...any) ([]string, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
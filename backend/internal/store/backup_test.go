@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPruneKeepsPreMigrationBackups(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	t.Setenv("TODO_BACKUP_DIR", dir)
+	t.Setenv("TODO_BACKUP_KEEP", "1")
+	db, err := NewDB(ctx, filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	if err := db.Setup(ctx); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+
+	preMigration, err := db.backupInto(ctx, dir, preMigrationPrefix+"31-")
+	if err != nil {
+		t.Fatalf("backupInto: %v", err)
+	}
+	backups, err := NewBackups(db)
+	if err != nil {
+		t.Fatalf("NewBackups: %v", err)
+	}
+	var latest *Backup
+	for range 3 {
+		// Backup names are to the millisecond
+		time.Sleep(2 * time.Millisecond)
+		if latest, err = backups.Create(ctx, BackupTriggerCLI); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	list, err := backups.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var names []string
+	for _, b := range list {
+		names = append(names, b.Name)
+	}
+	if len(list) != 2 || list[0].Name != latest.Name || list[1].Name != preMigration.Name {
+		t.Errorf("backups after pruning = %v, want %s and %s", names, latest.Name, preMigration.Name)
+	}
+	if _, err := backups.Path(preMigration.Name); err != nil {
+		t.Errorf("Path(%s): %v", preMigration.Name, err)
+	}
+}
+
+func TestParseBackupName(t *testing.T) {
+	tests := []struct {
+		name         string
+		preMigration bool
+		ok           bool
+	}{
+		{"tasks-20260101T020000.000Z.db", false, true},
+		{"pre-migrate-v31-20260101T020000.000Z.db", true, true},
+		{"pre-migrate-vX-20260101T020000.000Z.db", false, false},
+		{"pre-migrate-v31.db", false, false},
+		{"tasks-yesterday.db", false, false},
+		{"notes.txt", false, false},
+	}
+	for _, tt := range tests {
+		created, preMigration, ok := parseBackupName(tt.name)
+		if ok != tt.ok || preMigration != tt.preMigration {
+			t.Errorf("parseBackupName(%q) = %v, %v, want %v, %v", tt.name, preMigration, ok, tt.preMigration, tt.ok)
+		}
+		if ok && !strings.Contains(tt.name, created.Format(backupTimeFormat)) {
+			t.Errorf("parseBackupName(%q) time = %v", tt.name, created)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Operations accepted by POST /tasks/bulk
+const (
+	BulkCreate   = "create"
+	BulkComplete = "complete"
+	BulkDelete   = "delete"
+)
+
+// MaxBulkOperations caps how many operations a single bulk request may contain
+var MaxBulkOperations = config.EnvInt("TODO_BULK_MAX_OPERATIONS", 500)
+
+// ErrBulkAborted is returned when an operation failed and the whole batch was rolled back
+var ErrBulkAborted = errors.New("bulk operation aborted")
+
+// BulkOperation is one entry of a bulk request. ID is used by complete and delete,
+// the embedded NewTask fields by create.
+type BulkOperation struct {
+	Op string `json:"op"`
+	ID int    `json:"id,omitempty"`
+	NewTask
+}
+
+// BulkResult reports the outcome of one bulk operation using HTTP status semantics.
+// Operations after a failure are not attempted and report 424 Failed Dependency.
+type BulkResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	ID     int    `json:"id,omitempty"`
+	Status int    `json:"status"`
+	Task   *Task  `json:"task,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ExecuteBulk runs all operations in a single transaction. If any operation fails the
+// transaction is rolled back and ErrBulkAborted is returned alongside the per-item results.
+func (db *DB) ExecuteBulk(ctx context.Context, ops []BulkOperation) ([]BulkResult, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.ExecuteBulk",
+		trace.WithAttributes(
+			attribute.String("db.operation", "bulk"),
+			attribute.Int("bulk.operations", len(ops)),
+		))
+	defer span.End()
+
+	results := make([]BulkResult, len(ops))
+	err := db.WithTx(ctx, "bulk", func(ctx context.Context, tx *sql.Tx) error {
+		failed := false
+		for i, op := range ops {
+			results[i] = BulkResult{Index: i, Op: op.Op, ID: op.ID}
+			if failed {
+				results[i].Status = http.StatusFailedDependency
+				results[i].Error = "not attempted"
+				continue
+			}
+
+			var task *Task
+			var err error
+			switch op.Op {
+			case BulkCreate:
+				task, err = db.insertTask(ctx, tx, op.NewTask)
+				results[i].Status = http.StatusCreated
+			case BulkComplete:
+				task, err = db.completeTask(ctx, tx, op.ID)
+				results[i].Status = http.StatusOK
+			case BulkDelete:
+				err = db.deleteTask(ctx, tx, op.ID)
+				results[i].Status = http.StatusNoContent
+			default:
+				err = fmt.Errorf("unknown operation %q", op.Op)
+			}
+
+			if err == sql.ErrNoRows {
+				results[i].Status = http.StatusNotFound
+				results[i].Error = "Task not found"
+				failed = true
+			} else if errors.Is(err, ErrListNotFound) {
+				results[i].Status = http.StatusUnprocessableEntity
+				results[i].Error = "List not found"
+				failed = true
+			} else if errors.Is(err, ErrQuotaExceeded) {
+				results[i].Status = http.StatusForbidden
+				results[i].Error = err.Error()
+				failed = true
+			} else if errors.Is(err, ErrTaskBlocked) || errors.Is(err, ErrTaskClaimed) {
+				results[i].Status = http.StatusConflict
+				results[i].Error = err.Error()
+				failed = true
+			} else if err != nil {
+				// Anything else is a database failure, so give up on the whole batch
+				return fmt.Errorf("bulk operation %d (%s): %w", i, op.Op, err)
+			}
+
+			if task != nil {
+				results[i].Task = task
+				results[i].ID = task.ID
+				event := EventTaskCreated
+				if op.Op == BulkComplete {
+					event = EventTaskCompleted
+				}
+				if err := db.queueOutbox(ctx, tx, event, task); err != nil {
+					return err
+				}
+			}
+			span.AddEvent("bulk.operation",
+				trace.WithAttributes(
+					attribute.Int("index", i),
+					attribute.String("op", op.Op),
+					attribute.Int("task.id", results[i].ID),
+					attribute.Int("status", results[i].Status),
+				))
+		}
+		if failed {
+			return ErrBulkAborted
+		}
+		return nil
+	})
+	if errors.Is(err, ErrBulkAborted) {
+		span.SetAttributes(attribute.Bool("bulk.committed", false))
+		return results, ErrBulkAborted
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Bool("bulk.committed", true))
+
+	return results, nil
+}
+
+// ValidateBulkOperations normalizes create payloads in place and returns a result
+// for every invalid operation, or nil when all operations are valid
+func ValidateBulkOperations(ops []BulkOperation) []BulkResult {
+	var invalid []BulkResult
+	for i := range ops {
+		op := &ops[i]
+		var err error
+		switch op.Op {
+		case BulkCreate:
+			op.Title, err = NormalizeTitle(op.Title)
+			if err == nil {
+				op.Description, err = NormalizeDescription(op.Description)
+			}
+			if err == nil {
+				op.Tags, err = NormalizeTags(op.Tags)
+			}
+		case BulkComplete, BulkDelete:
+			if op.ID <= 0 {
+				err = errors.New("Invalid task ID")
+			}
+		default:
+			err = fmt.Errorf("Unknown operation %q, expected create, complete or delete", op.Op)
+		}
+		if err != nil {
+			invalid = append(invalid, BulkResult{Index: i, Op: op.Op, ID: op.ID, Status: http.StatusBadRequest, Error: err.Error()})
+		}
+	}
+	return invalid
+}
@@ -0,0 +1,313 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TaskChanges is the response of GET /tasks?since_seq=N: the live tasks changed after N and
+// the IDs of tasks deleted after N. Passing Seq as the next since_seq continues from here.
+type TaskChanges struct {
+	Seq     int64  `json:"seq"`
+	Tasks   []Task `json:"tasks"`
+	Deleted []int  `json:"deleted"`
+}
+
+// TaskDelta is the response of GET /tasks/changes?since=T: the tasks created and updated
+// after T and tombstones for the tasks deleted after T. Passing ServerTime as the next
+// since continues from here.
+type TaskDelta struct {
+	Since      time.Time       `json:"since"`
+	ServerTime time.Time       `json:"server_time"`
+	Created    []Task          `json:"created"`
+	Updated    []Task          `json:"updated"`
+	Deleted    []TaskTombstone `json:"deleted"`
+}
+
+// TaskTombstone marks a deleted task, whether it is still in the trash or already purged
+type TaskTombstone struct {
+	ID        int       `json:"id"`
+	UUID      string    `json:"uuid"`
+	DeletedAt time.Time `json:"deleted_at"`
+	// MergedInto is the task this one was merged into, if it was
+	MergedInto *int `json:"merged_into,omitempty"`
+}
+
+// ChangeSeq collects the highest sequence number written while handling a request
+type ChangeSeq struct {
+	seq atomic.Int64
+}
+
+// WithChangeSeq returns a context collecting the sequence numbers written under it
+func WithChangeSeq(ctx context.Context) (context.Context, *ChangeSeq) {
+	changes := &ChangeSeq{}
+	return context.WithValue(ctx, changeSeqKey{}, changes), changes
+}
+
+// Value returns the highest sequence number written so far, 0 for none
+func (c *ChangeSeq) Value() int64 {
+	return c.seq.Load()
+}
+
+func (c *ChangeSeq) observe(seq int64) {
+	for {
+		current := c.seq.Load()
+		if seq <= current || c.seq.CompareAndSwap(current, seq) {
+			return
+		}
+	}
+}
+
+type changeSeqKey struct{}
+
+// changeSeqFromContext returns the collector installed by ChangeSeqMiddleware, or nil outside a request
+func changeSeqFromContext(ctx context.Context) *ChangeSeq {
+	c, _ := ctx.Value(changeSeqKey{}).(*ChangeSeq)
+	return c
+}
+
+// logTaskChange appends task IDs to the changelog. q should be the transaction that made
+// the change, so a sequence number is visible exactly when the change is.
+func (db *DB) logTaskChange(ctx context.Context, q queryer, taskIDs ...int) error {
+	now := time.Now().UTC()
+	for _, id := range taskIDs {
+		var seq int64
+		var err error
+		if db.dialect == dialectMySQL {
+			var result sql.Result
+			if result, err = q.ExecContext(ctx, `INSERT INTO changelog (task_id, created_at) VALUES (?, ?)`, id, now); err == nil {
+				seq, err = result.LastInsertId()
+			}
+		} else {
+			err = q.QueryRowContext(ctx, `INSERT INTO changelog (task_id, created_at) VALUES (?, ?) RETURNING seq`, id, now).Scan(&seq)
+		}
+		if err != nil {
+			return err
+		}
+		if c := changeSeqFromContext(ctx); c != nil {
+			c.observe(seq)
+		}
+	}
+	return nil
+}
+
+// touchTask sets the updated_at of a task changed without its row being written, such as
+// by a new dependency changing its blocked_by
+func touchTask(ctx context.Context, q queryer, id int) error {
+	_, err := q.ExecContext(ctx, `UPDATE tasks SET updated_at = ? WHERE id = ?`, time.Now().UTC(), id)
+	return err
+}
+
+// logTaskChangesWhere appends every task matching where to the changelog, for statements
+// that change many tasks at once. MySQL cannot return the sequence numbers of an INSERT
+// ... SELECT, so there the tasks are appended one at a time.
+func (db *DB) logTaskChangesWhere(ctx context.Context, q queryer, where string, args ...any) error {
+	// These tasks are changed by the caller's statement or, for the blocked flag, by a change
+	// to another task, so their updated_at is set here
+	if _, err := q.ExecContext(ctx, `UPDATE tasks SET updated_at = ? WHERE `+where, append([]any{time.Now().UTC()}, args...)...); err != nil {
+		return err
+	}
+	if db.dialect == dialectMySQL {
+		ids, err := selectIDs(ctx, q, `SELECT id FROM tasks WHERE `+where, args...)
+		if err != nil {
+			return err
+		}
+		return db.logTaskChange(ctx, q, ids...)
+	}
+
+	args = append([]any{time.Now().UTC()}, args...)
+	rows, err := q.QueryContext(ctx, `INSERT INTO changelog (task_id, created_at) SELECT id, ? FROM tasks WHERE `+where+` RETURNING seq`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	c := changeSeqFromContext(ctx)
+	for rows.Next() {
+		var seq int64
+		if err := rows.Scan(&seq); err != nil {
+			return err
+		}
+		if c != nil {
+			c.observe(seq)
+		}
+	}
+	return rows.Err()
+}
+
+// selectIDs runs query, which selects one integer column, and returns the values
+func selectIDs(ctx context.Context, q queryer, query string, args ...any) ([]int, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ChangeSeq returns the sequence number of the latest committed change
+func (db *DB) ChangeSeq(ctx context.Context) (int64, error) {
+	var seq int64
+	err := db.conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(seq), 0) FROM changelog`).Scan(&seq)
+	return seq, err
+}
+
+// ChangeTimeAfter returns when the first change after seq was logged, or the zero time when
+// there is none
+func (db *DB) ChangeTimeAfter(ctx context.Context, seq int64) (time.Time, error) {
+	var at time.Time
+	err := db.conn.QueryRowContext(ctx, `SELECT created_at FROM changelog WHERE seq > ? ORDER BY seq LIMIT 1`, seq).Scan(&at)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return at, err
+}
+
+// GetTaskChanges returns the tasks changed after sinceSeq. The current sequence is read
+// before the changes, so a change racing with this call is at worst returned twice, never
+// skipped.
+func (db *DB) GetTaskChanges(ctx context.Context, sinceSeq int64) (*TaskChanges, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetTaskChanges",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_task_changes"),
+			attribute.Int64("changes.since_seq", sinceSeq),
+		))
+	defer span.End()
+
+	seq, err := db.ChangeSeq(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes := &TaskChanges{Seq: seq, Tasks: []Task{}, Deleted: []int{}}
+
+	query := `SELECT ` + taskColumns + ` FROM tasks
+	WHERE deleted_at IS NULL AND id IN (SELECT task_id FROM changelog WHERE seq > ?)
+	ORDER BY id`
+	start := time.Now()
+	rows, err := db.conn.QueryContext(ctx, query, sinceSeq)
+	db.checkSlowQuery(ctx, start, query, sinceSeq)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		changes.Tasks = append(changes.Tasks, *task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := db.attachRelations(ctx, db.conn, changes.Tasks); err != nil {
+		return nil, err
+	}
+
+	// Trashed and purged tasks both count as deleted
+	query = `SELECT DISTINCT c.task_id FROM changelog c
+	LEFT JOIN tasks t ON t.id = c.task_id AND t.deleted_at IS NULL
+	WHERE c.seq > ? AND t.id IS NULL
+	ORDER BY c.task_id`
+	start = time.Now()
+	deleted, err := db.conn.QueryContext(ctx, query, sinceSeq)
+	db.checkSlowQuery(ctx, start, query, sinceSeq)
+	if err != nil {
+		return nil, err
+	}
+	defer deleted.Close()
+	for deleted.Next() {
+		var id int
+		if err := deleted.Scan(&id); err != nil {
+			return nil, err
+		}
+		changes.Deleted = append(changes.Deleted, id)
+	}
+	if err := deleted.Err(); err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.Int64("changes.seq", seq),
+		attribute.Int("changes.tasks", len(changes.Tasks)),
+		attribute.Int("changes.deleted", len(changes.Deleted)),
+	)
+	return changes, nil
+}
+
+// GetTaskDelta returns the tasks changed after since, by their updated_at. ServerTime is taken before the
+// queries, so a change racing with this call is at worst returned twice, never skipped.
+func (db *DB) GetTaskDelta(ctx context.Context, since time.Time) (*TaskDelta, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetTaskDelta",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_task_delta"),
+			attribute.String("delta.since", since.Format(time.RFC3339)),
+		))
+	defer span.End()
+
+	since = since.UTC()
+	delta := &TaskDelta{Since: since, ServerTime: time.Now().UTC(), Created: []Task{}, Updated: []Task{}, Deleted: []TaskTombstone{}}
+
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE deleted_at IS NULL AND updated_at > ? ORDER BY id`
+	start := time.Now()
+	tasks, err := db.selectTasks(ctx, db.conn, query, since)
+	db.checkSlowQuery(ctx, start, query, since)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.attachRelations(ctx, db.conn, tasks); err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		if task.CreatedAt.After(since) {
+			delta.Created = append(delta.Created, task)
+		} else {
+			delta.Updated = append(delta.Updated, task)
+		}
+	}
+
+	// Tasks in the trash still have their row; purged tasks only their tombstone
+	query = `SELECT id, uuid, deleted_at, NULL FROM tasks WHERE deleted_at > ?
+	UNION ALL
+	SELECT task_id, uuid, deleted_at, merged_into FROM task_tombstones WHERE deleted_at > ?
+	ORDER BY 1`
+	start = time.Now()
+	rows, err := db.conn.QueryContext(ctx, query, since, since)
+	db.checkSlowQuery(ctx, start, query, since, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t TaskTombstone
+		if err := rows.Scan(&t.ID, &t.UUID, &t.DeletedAt, &t.MergedInto); err != nil {
+			return nil, err
+		}
+		delta.Deleted = append(delta.Deleted, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.Int("delta.created", len(delta.Created)),
+		attribute.Int("delta.updated", len(delta.Updated)),
+		attribute.Int("delta.deleted", len(delta.Deleted)),
+	)
+	return delta, nil
+}
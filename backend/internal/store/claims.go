@@ -0,0 +1,180 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/requestctx"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ClaimTTL is how long a claim lasts unless the request asks for another duration
+var ClaimTTL = config.EnvDuration("TODO_CLAIM_TTL", 15*time.Minute)
+
+// MaxClaimTTL caps the duration a claim may be requested for
+const MaxClaimTTL = 24 * time.Hour
+
+// ErrTaskClaimed is returned when another user holds an active claim on a task
+var ErrTaskClaimed = errors.New("task is claimed by another user")
+
+// ClaimError reports who holds the claim that blocked an operation
+type ClaimError struct {
+	TaskID    int
+	ClaimedBy string
+	ExpiresAt time.Time
+}
+
+func (e *ClaimError) Error() string {
+	return fmt.Sprintf("Task %d is claimed by %s until %s", e.TaskID, e.ClaimedBy, e.ExpiresAt.UTC().Format(time.RFC3339))
+}
+
+func (e *ClaimError) Unwrap() error {
+	return ErrTaskClaimed
+}
+
+// TaskClaim is the optional body of POST /tasks/{id}/claim
+type TaskClaim struct {
+	TTLSeconds int `json:"ttl_seconds"`
+}
+
+// activeClaim returns the holder and expiry of an unexpired claim on id, if any
+func (db *DB) activeClaim(ctx context.Context, q queryer, id int, now time.Time) (string, time.Time, bool, error) {
+	var claimedBy sql.NullString
+	var expiresAt sql.NullTime
+	err := q.QueryRowContext(ctx, `SELECT claimed_by, claim_expires_at FROM tasks WHERE id = ? AND deleted_at IS NULL`, id).
+		Scan(&claimedBy, &expiresAt)
+	if err != nil {
+		return "", time.Time{}, false, err
+	}
+	if !claimedBy.Valid || !expiresAt.Valid || !expiresAt.Time.After(now) {
+		return "", time.Time{}, false, nil
+	}
+	return claimedBy.String, expiresAt.Time, true, nil
+}
+
+// checkClaim returns a *ClaimError if a user other than the one in ctx holds an active
+// claim on id. Changes made outside a request, e.g. by background jobs, are not checked.
+func (db *DB) checkClaim(ctx context.Context, q queryer, id int) error {
+	userID := requestctx.User(ctx)
+	if userID == "" {
+		return nil
+	}
+	holder, expiresAt, ok, err := db.activeClaim(ctx, q, id, time.Now())
+	if err == sql.ErrNoRows {
+		// Let the statement that follows report the missing task
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if ok && holder != userID {
+		return &ClaimError{TaskID: id, ClaimedBy: holder, ExpiresAt: expiresAt}
+	}
+	return nil
+}
+
+// ClaimTask gives userID the task for ttl. Claiming is a single conditional UPDATE, so of
+// two concurrent claims exactly one wins; the holder claiming again extends the claim.
+func (db *DB) ClaimTask(ctx context.Context, id int, userID string, ttl time.Duration) (*Task, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.ClaimTask",
+		trace.WithAttributes(
+			attribute.String("db.operation", "claim_task"),
+			attribute.Int("task.id", id),
+			attribute.String("user.id", userID),
+		))
+	defer span.End()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(ttl)
+	query := `UPDATE tasks SET claimed_by = ?, claim_expires_at = ?, updated_at = ?
+	WHERE id = ? AND deleted_at IS NULL
+		AND (claimed_by IS NULL OR claimed_by = ? OR claim_expires_at IS NULL OR claim_expires_at <= ?)
+	RETURNING ` + taskColumns
+	start := time.Now()
+	task, err := scanTask(db.queryReturning(ctx, tx, "tasks", int64(id), query, userID, expiresAt, now, id, userID, now))
+	db.checkSlowQuery(ctx, start, query, userID, expiresAt, now, id, userID, now)
+	if err == sql.ErrNoRows {
+		// Either the task does not exist or someone else holds it
+		holder, heldUntil, ok, err := db.activeClaim(ctx, tx, id, now)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			span.SetAttributes(attribute.String("task.claimed_by", holder))
+			return nil, &ClaimError{TaskID: id, ClaimedBy: holder, ExpiresAt: heldUntil}
+		}
+		return nil, sql.ErrNoRows
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	changes := map[string]FieldChange{"claim_expires_at": {To: expiresAt}}
+	if err := db.recordTaskEvent(ctx, tx, id, TaskEventClaimed, changes); err != nil {
+		return nil, err
+	}
+	return task, tx.Commit()
+}
+
+// ReleaseTask drops userID's claim on a task. Releasing an unclaimed task is a no-op;
+// releasing someone else's active claim is a *ClaimError.
+func (db *DB) ReleaseTask(ctx context.Context, id int, userID string) (*Task, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.ReleaseTask",
+		trace.WithAttributes(
+			attribute.String("db.operation", "release_task"),
+			attribute.Int("task.id", id),
+			attribute.String("user.id", userID),
+		))
+	defer span.End()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	holder, expiresAt, ok, err := db.activeClaim(ctx, tx, id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if ok && holder != userID {
+		return nil, &ClaimError{TaskID: id, ClaimedBy: holder, ExpiresAt: expiresAt}
+	}
+
+	task, err := db.releaseClaim(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		if err := db.recordTaskEvent(ctx, tx, id, TaskEventReleased, nil); err != nil {
+			return nil, err
+		}
+	}
+	return task, tx.Commit()
+}
+
+// releaseClaim clears any claim on id
+func (db *DB) releaseClaim(ctx context.Context, q queryer, id int) (*Task, error) {
+	query := `UPDATE tasks SET claimed_by = NULL, claim_expires_at = NULL, updated_at = ? WHERE id = ? AND deleted_at IS NULL RETURNING ` + taskColumns
+	now := time.Now().UTC()
+	start := time.Now()
+	task, err := scanTask(db.queryReturning(ctx, q, "tasks", int64(id), query, now, id))
+	db.checkSlowQuery(ctx, start, query, now, id)
+	return task, err
+}
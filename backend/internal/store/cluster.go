@@ -0,0 +1,239 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"time"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// relayedEvents maps the task history events that are pushed to clients to their bus events
+var relayedEvents = map[string]string{
+	TaskEventCreated:     EventTaskCreated,
+	TaskEventCompleted:   EventTaskCompleted,
+	TaskEventUncompleted: EventTaskUncompleted,
+	TaskEventDeleted:     EventTaskDeleted,
+}
+
+// dbNonceStore keeps signature nonces in the signature_nonces table
+type dbNonceStore struct {
+	db     *DB
+	maxAge time.Duration
+}
+
+func NewDBNonceStore(db *DB, maxAge time.Duration) NonceStore {
+	return &dbNonceStore{db: db, maxAge: maxAge}
+}
+
+func (s *dbNonceStore) Add(ctx context.Context, key string, now time.Time) (bool, error) {
+	now = now.UTC()
+	if _, err := s.db.conn.ExecContext(ctx, `DELETE FROM signature_nonces WHERE nonce_key = ? AND expires_at < ?`, key, now); err != nil {
+		return false, err
+	}
+	// Timestamps up to maxAge in the future are accepted, so remember the nonce for both sides
+	result, err := s.db.conn.ExecContext(ctx, `
+	INSERT INTO signature_nonces (nonce_key, expires_at) VALUES (?, ?) ON CONFLICT (nonce_key) DO NOTHING`,
+		key, now.Add(2*s.maxAge))
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// ClusterMember is a replica that has heartbeated recently
+type ClusterMember struct {
+	InstanceID string    `json:"instance_id"`
+	Hostname   string    `json:"hostname"`
+	StartedAt  time.Time `json:"started_at"`
+	LastSeen   time.Time `json:"last_seen"`
+	Leader     bool      `json:"leader"`
+}
+
+// HeartbeatMember records that instance is alive and returns how many replicas were seen
+// within ttl
+func (db *DB) HeartbeatMember(ctx context.Context, instance string, startedAt, now time.Time, ttl time.Duration) (int, error) {
+	host, _ := os.Hostname()
+	_, err := db.conn.ExecContext(ctx, `
+	INSERT INTO cluster_members (instance_id, hostname, started_at, last_seen) VALUES (?, ?, ?, ?)
+	ON CONFLICT (instance_id) DO UPDATE SET last_seen = excluded.last_seen`, instance, host, startedAt, now)
+	if err != nil {
+		return 0, err
+	}
+	var members int
+	err = db.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM cluster_members WHERE last_seen >= ?`, now.Add(-ttl)).Scan(&members)
+	return members, err
+}
+
+// LeaseStore holds named leases, each held by one replica at a time until it expires
+type LeaseStore interface {
+	// Acquire takes the named lease for holder, or extends it if holder already has it.
+	// It reports whether holder has the lease until now+ttl.
+	Acquire(ctx context.Context, name, holder string, now time.Time, ttl time.Duration) (bool, error)
+	// Release gives up holder's lease, if it still has it
+	Release(ctx context.Context, name, holder string) error
+	// Holder returns who has the named lease, or "" if it is free
+	Holder(ctx context.Context, name string, now time.Time) (string, error)
+}
+
+// dbLeaseStore keeps leases in the cluster_leases table
+type dbLeaseStore struct {
+	db *DB
+}
+
+func NewDBLeaseStore(db *DB) LeaseStore {
+	return &dbLeaseStore{db: db}
+}
+
+func (s *dbLeaseStore) Acquire(ctx context.Context, name, holder string, now time.Time, ttl time.Duration) (bool, error) {
+	if s.db.dialect == dialectMySQL {
+		return s.acquireInTwo(ctx, name, holder, now, ttl)
+	}
+	result, err := s.db.conn.ExecContext(ctx, `
+	INSERT INTO cluster_leases (name, holder, expires_at) VALUES (?, ?, ?)
+	ON CONFLICT (name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+	WHERE cluster_leases.holder = excluded.holder OR cluster_leases.expires_at < ?`,
+		name, holder, now.Add(ttl), now)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// acquireInTwo takes a free lease with an insert, and an expired or held one with an update.
+// It is for MySQL, where an upsert cannot be conditional: keeping the row unchanged still
+// counts as a match.
+func (s *dbLeaseStore) acquireInTwo(ctx context.Context, name, holder string, now time.Time, ttl time.Duration) (bool, error) {
+	result, err := s.db.conn.ExecContext(ctx, `
+	INSERT INTO cluster_leases (name, holder, expires_at) VALUES (?, ?, ?) ON CONFLICT (name) DO NOTHING`,
+		name, holder, now.Add(ttl))
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 1 {
+		return err == nil, err
+	}
+	result, err = s.db.conn.ExecContext(ctx, `
+	UPDATE cluster_leases SET holder = ?, expires_at = ? WHERE name = ? AND (holder = ? OR expires_at < ?)`,
+		holder, now.Add(ttl), name, holder, now)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+func (s *dbLeaseStore) Release(ctx context.Context, name, holder string) error {
+	_, err := s.db.conn.ExecContext(ctx, `DELETE FROM cluster_leases WHERE name = ? AND holder = ?`, name, holder)
+	return err
+}
+
+func (s *dbLeaseStore) Holder(ctx context.Context, name string, now time.Time) (string, error) {
+	var holder string
+	err := s.db.conn.QueryRowContext(ctx, `SELECT holder FROM cluster_leases WHERE name = ? AND expires_at >= ?`,
+		name, now).Scan(&holder)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return holder, err
+}
+
+// LeaveCluster removes instance from the members
+func (db *DB) LeaveCluster(ctx context.Context, instance string) error {
+	_, err := db.conn.ExecContext(ctx, `DELETE FROM cluster_members WHERE instance_id = ?`, instance)
+	return err
+}
+
+// PurgeClusterState removes members gone for a while and expired signature nonces
+func (db *DB) PurgeClusterState(ctx context.Context, now time.Time, ttl time.Duration) error {
+	if _, err := db.conn.ExecContext(ctx, `DELETE FROM cluster_members WHERE last_seen < ?`, now.Add(-10*ttl)); err != nil {
+		return err
+	}
+	_, err := db.conn.ExecContext(ctx, `DELETE FROM signature_nonces WHERE expires_at < ?`, now)
+	return err
+}
+
+// ClusterMembers returns the replicas seen within ttl, marking leader as the leader
+func (db *DB) ClusterMembers(ctx context.Context, now time.Time, ttl time.Duration, leader string) ([]ClusterMember, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.ClusterMembers",
+		trace.WithAttributes(attribute.String("db.operation", "select_cluster_members")))
+	defer span.End()
+
+	rows, err := db.conn.QueryContext(ctx, `
+	SELECT instance_id, hostname, started_at, last_seen, instance_id = ?
+	FROM cluster_members WHERE last_seen >= ? ORDER BY started_at`, leader, now.Add(-ttl))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []ClusterMember{}
+	for rows.Next() {
+		var m ClusterMember
+		if err := rows.Scan(&m.InstanceID, &m.Hostname, &m.StartedAt, &m.LastSeen, &m.Leader); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// LastTaskEventID returns the newest task event, where the relay starts
+func (db *DB) LastTaskEventID(ctx context.Context) (int64, error) {
+	var id int64
+	err := db.conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM task_events`).Scan(&id)
+	return id, err
+}
+
+// RemoteTaskEvents returns the relayed task events after cursor that another replica wrote,
+// as bus events, and the cursor to continue from
+func (db *DB) RemoteTaskEvents(ctx context.Context, cursor int64, instance string, limit int) ([]BusEvent, int64, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+	SELECT id, task_id, event, actor, COALESCE(trace_id, ''), COALESCE(instance_id, ''), created_at
+	FROM task_events WHERE id > ? ORDER BY id LIMIT ?`, cursor, limit)
+	if err != nil {
+		return nil, cursor, err
+	}
+	type remoteEvent struct {
+		event    BusEvent
+		instance string
+	}
+	var found []remoteEvent
+	for rows.Next() {
+		var r remoteEvent
+		var event string
+		if err := rows.Scan(&cursor, &r.event.TaskID, &event, &r.event.Actor, &r.event.TraceID, &r.instance, &r.event.Time); err != nil {
+			rows.Close()
+			return nil, cursor, err
+		}
+		r.event.Type = relayedEvents[event]
+		if r.instance != instance && r.event.Type != "" {
+			found = append(found, r)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, cursor, err
+	}
+
+	events := make([]BusEvent, 0, len(found))
+	for _, r := range found {
+		if r.event.Type != EventTaskDeleted {
+			// The task as it is now; it may have changed again since the event
+			task, err := db.GetTask(ctx, r.event.TaskID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return nil, cursor, err
+			}
+			r.event.Task = task
+		}
+		events = append(events, r.event)
+	}
+	return events, cursor, nil
+}
@@ -0,0 +1,130 @@
+package store
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+
+	"todo-app/internal/config"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Sort orders accepted by GET /tasks?sort=
+const (
+	SortCreated  = "created_at"
+	SortTitle    = "title"
+	SortPosition = "position"
+)
+
+// DefaultLocale is used for collation when the request does not ask for one
+var DefaultLocale = language.Make(config.EnvString("TODO_LOCALE", "en"))
+
+// RequestLocale picks the collation locale from ?locale=, then Accept-Language, then TODO_LOCALE
+func RequestLocale(r *http.Request) language.Tag {
+	if v := r.URL.Query().Get("locale"); v != "" {
+		if tag, err := language.Parse(v); err == nil {
+			return tag
+		}
+	}
+	if tags, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language")); err == nil && len(tags) > 0 {
+		return tags[0]
+	}
+	return DefaultLocale
+}
+
+// sortTasksByTitle orders tasks by title using the collation rules of locale,
+// so accented and non-Latin titles sort where speakers of that language expect.
+// Ties keep their existing (creation) order.
+func sortTasksByTitle(tasks []Task, locale language.Tag) {
+	c := collate.New(locale, collate.Loose)
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return c.CompareString(tasks[i].Title, tasks[j].Title) < 0
+	})
+}
+
+// foldForSearch strips diacritics and case so "unicode" matches "Ünïcode"
+func foldForSearch(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), cases.Fold(), norm.NFC)
+	folded, _, err := transform.String(t, s)
+	if err != nil {
+		return strings.ToLower(s)
+	}
+	return folded
+}
+
+// filterTasksBySearch keeps tasks whose title contains search, ignoring case and diacritics
+func filterTasksBySearch(tasks []Task, search string) []Task {
+	needle := foldForSearch(search)
+	filtered := tasks[:0]
+	for _, task := range tasks {
+		if strings.Contains(foldForSearch(task.Title), needle) {
+			filtered = append(filtered, task)
+		}
+	}
+	return filtered
+}
+
+// pageCollector gathers the page of a task query whose search, tag filter or title order is
+// applied here rather than in SQL. It holds at most offset+limit tasks however many rows the
+// query reads: without title order the rows arrive in page order and reading stops once
+// enough have matched, and with it only the offset+limit first titles seen so far are kept.
+type pageCollector struct {
+	q        TaskQuery
+	tag      string // tag still to filter on, "" when SQL did it
+	needle   string
+	collator *collate.Collator
+	keep     int // 0 keeps every match
+	tasks    []Task
+}
+
+func newPageCollector(q TaskQuery, tag string) *pageCollector {
+	c := &pageCollector{q: q, tag: tag, needle: foldForSearch(q.Search)}
+	if q.Limit > 0 {
+		c.keep = q.Offset + q.Limit
+	}
+	if q.Sort == SortTitle {
+		c.collator = collate.New(q.Locale, collate.Loose)
+	}
+	return c
+}
+
+// add offers the next row and reports whether the page is complete, so later rows cannot
+// change it
+func (c *pageCollector) add(task Task) bool {
+	if c.q.Search != "" && !strings.Contains(foldForSearch(task.Title), c.needle) {
+		return false
+	}
+	if c.tag != "" && !task.Tags.Has(c.tag) {
+		return false
+	}
+	if c.collator == nil {
+		c.tasks = append(c.tasks, task)
+		return c.keep > 0 && len(c.tasks) >= c.keep
+	}
+	// After every title that sorts the same, so ties keep their existing (creation) order
+	i := sort.Search(len(c.tasks), func(i int) bool {
+		return c.collator.CompareString(task.Title, c.tasks[i].Title) < 0
+	})
+	if c.keep > 0 && i >= c.keep {
+		return false
+	}
+	c.tasks = append(c.tasks, Task{})
+	copy(c.tasks[i+1:], c.tasks[i:])
+	c.tasks[i] = task
+	if c.keep > 0 && len(c.tasks) > c.keep {
+		c.tasks = c.tasks[:c.keep]
+	}
+	return false
+}
+
+// page returns the collected tasks the query's offset and limit select
+func (c *pageCollector) page() []Task {
+	return c.q.page(c.tasks)
+}
@@ -0,0 +1,40 @@
+package store_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"todo-app/internal/store"
+
+	"golang.org/x/text/language"
+)
+
+func TestCollectPageHoldsOnlyThePage(t *testing.T) {
+	var tasks []store.Task
+	for i := range 1000 {
+		tasks = append(tasks, store.Task{ID: i + 1, Title: fmt.Sprintf("task %03d", (i*7919)%1000)})
+	}
+
+	q := store.TaskQuery{Sort: store.SortTitle, Locale: language.English, Offset: 10, Limit: 5}
+	page, held, _ := store.CollectPage(q, tasks)
+	if held > q.Offset+q.Limit {
+		t.Errorf("held %d tasks for title order, want at most offset+limit = %d", held, q.Offset+q.Limit)
+	}
+	var titles []string
+	for _, task := range page {
+		titles = append(titles, task.Title)
+	}
+	if want := []string{"task 010", "task 011", "task 012", "task 013", "task 014"}; !slices.Equal(titles, want) {
+		t.Errorf("title page = %v, want %v", titles, want)
+	}
+
+	q = store.TaskQuery{Search: "task", Offset: 10, Limit: 5}
+	page, held, read := store.CollectPage(q, tasks)
+	if held > q.Offset+q.Limit || read != q.Offset+q.Limit {
+		t.Errorf("held %d tasks after reading %d for a search, want to stop at offset+limit = %d", held, read, q.Offset+q.Limit)
+	}
+	if len(page) != 5 || page[0].ID != 11 {
+		t.Errorf("search page = %+v, want tasks 11 to 15", page)
+	}
+}
@@ -0,0 +1,822 @@
+// Package store keeps the tasks and everything attached to them in SQLite, PostgreSQL or
+// MySQL, with Redis for state the replicas share when it is configured.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"github.com/XSAM/otelsql"
+	_ "github.com/mattn/go-sqlite3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.27.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrTaskDeleted is returned when a task UUID refers to a task that has been deleted
+var ErrTaskDeleted = errors.New("task has been deleted")
+
+// ErrTaskModified is returned when a conditional update finds the task changed since the
+// version it was made against
+var ErrTaskModified = errors.New("task has been modified since it was read")
+
+type DB struct {
+	conn               *sql.DB
+	dialect            sqlDialect
+	slowQueryThreshold time.Duration
+	ids                IDGenerator
+	// health follows failovers; nil except on PostgreSQL
+	health *dbHealth
+}
+
+// NewDB opens the database and checks that it answers. Setup must run before it is used.
+// dataSourceName is a SQLite file, a postgres:// URL for PostgreSQL, or a mysql:// URL for
+// MySQL or MariaDB.
+func NewDB(ctx context.Context, dataSourceName string) (*DB, error) {
+	dialect := dialectFor(dataSourceName)
+	dbAttributes := []attribute.KeyValue{semconv.DBSystemSqlite, attribute.String("db.name", "tasks.db")}
+	switch dialect {
+	case dialectSQLite:
+		var settings []attribute.KeyValue
+		dataSourceName, settings = loadSQLiteSettings().apply(dataSourceName)
+		dbAttributes = append(dbAttributes, settings...)
+	case dialectPostgres:
+		dbAttributes = []attribute.KeyValue{semconv.DBSystemPostgreSQL, attribute.String("db.name", postgresDatabase(dataSourceName))}
+	case dialectMySQL:
+		dbAttributes = []attribute.KeyValue{semconv.DBSystemMySQL, attribute.String("db.name", mysqlDatabase(dataSourceName))}
+	}
+
+	options := []otelsql.Option{
+		otelsql.WithAttributes(dbAttributes...),
+		otelsql.WithTracerProvider(otel.GetTracerProvider()),
+		otelsql.WithMeterProvider(otel.GetMeterProvider()),
+		otelsql.WithSQLCommenter(true),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			// Only create spans when there's an existing parent span
+			SpanFilter: func(ctx context.Context, method otelsql.Method, query string, args []driver.NamedValue) bool {
+				// Check if there's a valid parent span in the context
+				return trace.SpanFromContext(ctx).SpanContext().IsValid()
+			},
+		}),
+		otelsql.WithAttributesGetter(func(ctx context.Context, method otelsql.Method, query string, args []driver.NamedValue) []attribute.KeyValue {
+			// Format the query with actual values instead of placeholders
+			formattedQuery := formatQueryWithArgs(query, args)
+			return []attribute.KeyValue{
+				attribute.String("db.statement.formatted", formattedQuery),
+			}
+		}),
+	}
+
+	// Open the database with the instrumented driver
+	var conn *sql.DB
+	var health *dbHealth
+	var err error
+	maxIdle := config.EnvInt("TODO_DB_MAX_IDLE_CONNS", 5)
+	switch dialect {
+	case dialectPostgres:
+		health = newDBHealth(maxIdle)
+		conn, err = openPostgres(dataSourceName, health, options...)
+	case dialectMySQL:
+		conn, err = openMySQL(dataSourceName, options...)
+	default:
+		// Register the otelsql wrapper for sqlite3
+		var driverName string
+		if driverName, err = otelsql.Register("sqlite3", options...); err == nil {
+			conn, err = sql.Open(driverName, dataSourceName)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Configure connection pool for better observability. MySQL closes connections idle for
+	// longer than its wait_timeout, so there they are replaced after a few minutes by default.
+	var maxLifetime time.Duration
+	if dialect == dialectMySQL {
+		maxLifetime = 3 * time.Minute
+	}
+	conn.SetMaxOpenConns(config.EnvInt("TODO_DB_MAX_OPEN_CONNS", 10))
+	conn.SetMaxIdleConns(maxIdle)
+	conn.SetConnMaxLifetime(config.EnvDuration("TODO_DB_CONN_MAX_LIFETIME", maxLifetime))
+
+	// Register database statistics metrics
+	err = otelsql.RegisterDBStatsMetrics(conn, otelsql.WithAttributes(dbAttributes...))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.PingContext(ctx); err != nil {
+		return nil, err
+	}
+
+	fieldCipher, err = NewFieldCipher(os.Getenv("TODO_ENCRYPTION_KEY"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TODO_ENCRYPTION_KEY: %w", err)
+	}
+	ids, err := idGeneratorFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID configuration: %w", err)
+	}
+
+	if health != nil {
+		health.start(conn)
+	}
+	return &DB{
+		conn:               conn,
+		dialect:            dialect,
+		slowQueryThreshold: config.EnvDuration("TODO_SLOW_QUERY_THRESHOLD", 100*time.Millisecond),
+		ids:                ids,
+		health:             health,
+	}, nil
+}
+
+// Setup checks the recorded migrations, creates the schema, applies pending migrations and
+// encrypts leftover plaintext.
+// It is separate from NewDB so the server can report itself as starting while it runs.
+func (db *DB) Setup(ctx context.Context) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.Setup",
+		trace.WithAttributes(attribute.String("db.operation", "setup")))
+	defer span.End()
+
+	// A database a newer release migrated is refused before this one creates or alters
+	// anything in it. One without schema_migrations is new or predates migrations; migrate
+	// checks it once the table exists.
+	tables, err := selectStrings(ctx, db.conn, db.dialect.tablesQuery())
+	if err != nil {
+		return err
+	}
+	if slices.Contains(tables, "schema_migrations") {
+		if err := db.CheckSchema(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := db.createTables(ctx); err != nil {
+		return err
+	}
+	if err := db.migrate(ctx); err != nil {
+		return err
+	}
+	return db.sealExistingFields(ctx)
+}
+
+func (db *DB) createTables(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS tasks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		completed BOOLEAN DEFAULT FALSE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := db.conn.ExecContext(ctx, db.dialect.ddl(query)); err != nil {
+		return err
+	}
+
+	query = `
+	CREATE TABLE IF NOT EXISTS lists (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		is_default BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := db.conn.ExecContext(ctx, db.dialect.ddl(query)); err != nil {
+		return err
+	}
+	return db.createDefaultList(ctx)
+}
+
+// taskColumns is the column list every task query selects or returns, in scanTask order
+const taskColumns = `id, uuid, title, description, completed, created_at, due_at, completed_at, deleted_at, position, parent_id, list_id, tags, claimed_by, claim_expires_at, updated_at`
+
+// queryer is implemented by *sql.DB and *sql.Tx, so statements can run inside or outside a transaction
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTask(row rowScanner) (*Task, error) {
+	task := &Task{}
+	err := row.Scan(&task.ID, &task.UUID, sealedString{fieldTaskTitle, &task.Title}, sealedString{fieldTaskDescription, &task.Description}, &task.Completed, &task.CreatedAt, &task.DueAt, &task.CompletedAt, &task.DeletedAt, &task.Position, &task.ParentID, &task.ListID, &task.Tags, &task.ClaimedBy, &task.ClaimExpiresAt, &task.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	// An expired claim is the same as none
+	if task.ClaimExpiresAt != nil && !task.ClaimExpiresAt.After(time.Now()) {
+		task.ClaimedBy, task.ClaimExpiresAt = nil, nil
+	}
+	task.setAge(time.Now())
+	return task, nil
+}
+
+func (db *DB) GetAllTasks(ctx context.Context, q TaskQuery) ([]Task, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetAllTasks",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_all_tasks"),
+			attribute.String("query.sort", q.Sort),
+			attribute.Bool("query.search", q.Search != ""),
+		))
+	defer span.End()
+	orderBy := `created_at DESC, id DESC`
+	if q.Sort == SortPosition {
+		orderBy = `position, id`
+	}
+	where := `deleted_at IS NULL`
+	var args []any
+	if q.ListID != nil {
+		where += ` AND list_id = ?`
+		args = append(args, *q.ListID)
+	}
+	if q.Stale {
+		cutoff, ok := staleCutoff(time.Now())
+		if !ok {
+			return nil, nil
+		}
+		where += ` AND completed = FALSE AND updated_at < ?`
+		args = append(args, cutoff.UTC())
+	}
+	sqlTagFilter := q.Tag != "" && config.FeatureEnabled(ctx, config.FeatureSQLTagFilter)
+	if sqlTagFilter {
+		span.SetAttributes(attribute.Bool("feature."+config.FeatureSQLTagFilter, true))
+		where += ` AND ? IN (` + db.dialect.jsonValues(`tasks.tags`) + `)`
+		args = append(args, q.Tag)
+	}
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE ` + where + ` ORDER BY ` + orderBy
+	// The page can only be cut in SQL when nothing is filtered or reordered afterwards
+	sqlPage := q.Limit > 0 && q.Search == "" && (q.Tag == "" || sqlTagFilter) && q.Sort != SortTitle
+	if sqlPage {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, q.Limit, q.Offset)
+		span.SetAttributes(attribute.Int("query.limit", q.Limit), attribute.Int("query.offset", q.Offset))
+	}
+	start := time.Now()
+	defer func() { db.checkSlowQuery(ctx, start, query, args...) }()
+	rows, err := db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// SQLite has no locale-aware collation, and titles may be encrypted, so search and title
+	// ordering happen here; the collector keeps the page without holding every row
+	var tag string
+	if !sqlTagFilter {
+		tag = q.Tag
+	}
+	collector := newPageCollector(q, tag)
+	var tasks []Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		if sqlPage {
+			tasks = append(tasks, *task)
+		} else if collector.add(*task) {
+			break
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !sqlPage {
+		tasks = collector.page()
+	}
+
+	if err := db.attachRelations(ctx, db.conn, tasks); err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+func (db *DB) GetTask(ctx context.Context, id int) (*Task, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetTask",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_task"),
+			attribute.Int("task.id", id),
+		))
+	defer span.End()
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE id = ? AND deleted_at IS NULL`
+
+	start := time.Now()
+	task, err := scanTask(db.conn.QueryRowContext(ctx, query, id))
+	db.checkSlowQuery(ctx, start, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := []Task{*task}
+	if err := db.attachRelations(ctx, db.conn, tasks); err != nil {
+		return nil, err
+	}
+	return &tasks[0], nil
+}
+
+func (db *DB) CreateTask(ctx context.Context, input NewTask) (*Task, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.CreateTask",
+		trace.WithAttributes(
+			attribute.String("db.operation", "insert_task"),
+			attribute.String("task.title", input.Title),
+		))
+	defer span.End()
+
+	// Dummy error for demonstration purposes
+	if input.Title == "errorTest" {
+		err := fmt.Errorf("simulated database error: cannot create task with title 'errorTest'")
+
+		// Capture stack trace
+		stackTrace := string(debug.Stack())
+
+		// Record error with stack trace
+		span.RecordError(err, trace.WithStackTrace(true))
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(
+			attribute.String("error.type", "SimulatedError"),
+			attribute.Bool("error.simulated", true),
+			attribute.String("exception.stacktrace", stackTrace),
+		)
+
+		// Add an event with the stack trace for better visibility
+		span.AddEvent("error.with.stacktrace",
+			trace.WithAttributes(
+				attribute.String("error.message", err.Error()),
+				attribute.String("stack.trace", stackTrace),
+			),
+		)
+
+		return nil, err
+	}
+
+	var task *Task
+	err := db.WithTx(ctx, "create_task", func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		if task, err = db.insertTask(ctx, tx, input); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+		return db.queueOutbox(ctx, tx, EventTaskCreated, task)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func (db *DB) insertTask(ctx context.Context, q queryer, input NewTask) (*Task, error) {
+	listID, err := db.resolveListID(ctx, q, input.ListID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOpenTaskQuota(ctx, q, listID); err != nil {
+		return nil, err
+	}
+
+	title, err := SealField(fieldTaskTitle, input.Title)
+	if err != nil {
+		return nil, err
+	}
+	description, err := SealField(fieldTaskDescription, input.Description)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := db.taskDefaults(ctx, q, listID)
+	if err != nil {
+		return nil, err
+	}
+	tags := withDefaultTags(input.Tags, settings.DefaultTags)
+
+	// New tasks go to the top of the manual order, matching the newest-first default. The top
+	// is read through a derived table because MySQL cannot read the table an INSERT writes in
+	// a subquery. Without an id from the generator, the database numbers the task.
+	columns, values := `uuid, title, description, due_at, list_id, tags, reminder_offset, remind_at, updated_at, position`, `?, ?, ?, ?, ?, ?, ?, ?, ?`
+	args := []any{db.ids.TaskUUID(), title, description, utcTime(input.DueAt), listID, tags,
+		settings.ReminderOffsetSeconds, remindAt(input.DueAt, settings.ReminderOffsetSeconds), time.Now().UTC()}
+	id := db.ids.TaskID()
+	if id != 0 {
+		columns, values = `id, `+columns, `?, `+values
+		args = append([]any{id}, args...)
+	}
+	query := `INSERT INTO tasks (` + columns + `)
+	VALUES (` + values + `, (SELECT top FROM (SELECT COALESCE(MIN(position), 0) - 1 AS top FROM tasks WHERE deleted_at IS NULL) AS newest))
+	RETURNING ` + taskColumns
+
+	start := time.Now()
+	task, err := scanTask(db.queryReturning(ctx, q, "tasks", int64(id), query, args...))
+	db.checkSlowQuery(ctx, start, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return task, db.recordTaskEvent(ctx, q, task.ID, TaskEventCreated, nil)
+}
+
+func (db *DB) DeleteTask(ctx context.Context, id int) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.DeleteTask",
+		trace.WithAttributes(
+			attribute.String("db.operation", "delete_task"),
+			attribute.Int("task.id", id),
+		))
+	defer span.End()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := db.deleteTask(ctx, tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteTask moves a task to the trash. It stays there, restorable, until the purge
+// job removes it for good.
+func (db *DB) deleteTask(ctx context.Context, q queryer, id int) error {
+	if err := db.checkClaim(ctx, q, id); err != nil {
+		return err
+	}
+
+	query := `UPDATE tasks SET deleted_at = ?, updated_at = ?, claimed_by = NULL, claim_expires_at = NULL WHERE id = ? AND deleted_at IS NULL`
+	now := time.Now().UTC()
+	start := time.Now()
+	result, err := q.ExecContext(ctx, query, now, now, id)
+	db.checkSlowQuery(ctx, start, query, now, now, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return db.recordTaskEvent(ctx, q, id, TaskEventDeleted, nil)
+}
+
+// TaskIDByUUID returns the numeric ID of the task with the given UUID, including tasks in
+// the trash. It returns a *TaskMergedError if the task was merged into another, ErrTaskDeleted
+// if it was purged, sql.ErrNoRows if it never existed.
+func (db *DB) TaskIDByUUID(ctx context.Context, taskUUID string) (int, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.TaskIDByUUID",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_task_id"),
+			attribute.String("task.uuid", taskUUID),
+		))
+	defer span.End()
+
+	var id int
+	err := db.conn.QueryRowContext(ctx, `SELECT id FROM tasks WHERE uuid = ?`, taskUUID).Scan(&id)
+	if err != sql.ErrNoRows {
+		return id, err
+	}
+
+	var mergedInto sql.NullInt64
+	err = db.conn.QueryRowContext(ctx, `SELECT merged_into FROM task_tombstones WHERE uuid = ?`, taskUUID).Scan(&mergedInto)
+	if err != nil {
+		return 0, err
+	}
+	span.SetAttributes(attribute.Bool("task.deleted", true))
+	if mergedInto.Valid {
+		return 0, &TaskMergedError{Into: int(mergedInto.Int64)}
+	}
+	return 0, ErrTaskDeleted
+}
+
+func (db *DB) CompleteTask(ctx context.Context, id int) (*Task, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.CompleteTask",
+		trace.WithAttributes(
+			attribute.String("db.operation", "update_task"),
+			attribute.Int("task.id", id),
+		))
+	defer span.End()
+
+	var task *Task
+	err := db.WithTx(ctx, "complete_task", func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		if task, err = db.completeTask(ctx, tx, id); err != nil {
+			return err
+		}
+		return db.queueOutbox(ctx, tx, EventTaskCompleted, task)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// completeTask marks a task complete and releases its claim, unless it is blocked (see
+// checkNotBlocked) or claimed by someone else (see checkClaim)
+func (db *DB) completeTask(ctx context.Context, q queryer, id int) (*Task, error) {
+	if err := db.checkClaim(ctx, q, id); err != nil {
+		return nil, err
+	}
+	if err := db.checkNotBlocked(ctx, q, id); err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE tasks SET completed = TRUE, completed_at = COALESCE(completed_at, ?), updated_at = ?, claimed_by = NULL, claim_expires_at = NULL WHERE id = ? AND deleted_at IS NULL RETURNING ` + taskColumns
+
+	now := time.Now().UTC()
+	start := time.Now()
+	task, err := scanTask(db.queryReturning(ctx, q, "tasks", int64(id), query, now, now, id))
+	db.checkSlowQuery(ctx, start, query, now, now, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return task, db.recordTaskEvent(ctx, q, id, TaskEventCompleted, nil)
+}
+
+// UpdateTask applies the non-nil fields of update and returns the updated row
+func (db *DB) UpdateTask(ctx context.Context, id int, update TaskUpdate) (*Task, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.UpdateTask",
+		trace.WithAttributes(
+			attribute.String("db.operation", "update_task_fields"),
+			attribute.Int("task.id", id),
+		))
+	defer span.End()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := scanTask(tx.QueryRowContext(ctx, `SELECT `+taskColumns+` FROM tasks WHERE id = ? AND deleted_at IS NULL`, id))
+	if err != nil {
+		return nil, err
+	}
+	if update.IfUpdatedAt != nil && !before.UpdatedAt.Equal(*update.IfUpdatedAt) {
+		return nil, ErrTaskModified
+	}
+	if err := db.checkClaim(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
+	var sets []string
+	var args []any
+	if update.Title != nil {
+		title, err := SealField(fieldTaskTitle, *update.Title)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, "title = ?")
+		args = append(args, title)
+	}
+	if update.Description != nil {
+		description, err := SealField(fieldTaskDescription, *update.Description)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, "description = ?")
+		args = append(args, description)
+	}
+	if update.Completed != nil {
+		if *update.Completed {
+			if err := db.checkNotBlocked(ctx, tx, id); err != nil {
+				return nil, err
+			}
+			sets = append(sets, "completed = TRUE", "completed_at = COALESCE(completed_at, ?)", "claimed_by = NULL", "claim_expires_at = NULL")
+			args = append(args, time.Now().UTC())
+		} else {
+			sets = append(sets, "completed = FALSE", "completed_at = NULL")
+		}
+	}
+	if update.DueAt.Set {
+		offset, err := taskReminderOffset(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, "due_at = ?", "remind_at = ?")
+		args = append(args, utcTime(update.DueAt.Value), remindAt(update.DueAt.Value, offset))
+	}
+	if update.Tags != nil {
+		sets = append(sets, "tags = ?")
+		args = append(args, *update.Tags)
+	}
+	if update.ListID != nil {
+		if _, err := db.resolveListID(ctx, tx, update.ListID); err != nil {
+			return nil, err
+		}
+		sets = append(sets, "list_id = ?")
+		args = append(args, *update.ListID)
+	}
+	if len(sets) == 0 {
+		return nil, fmt.Errorf("no fields to update")
+	}
+	span.SetAttributes(attribute.Int("task.updated_fields", len(sets)))
+	if update.DueAt.Set {
+		// A new due date deserves a new reminder
+		sets = append(sets, "reminded_at = NULL")
+	}
+	sets = append(sets, "updated_at = ?")
+	args = append(args, time.Now().UTC())
+
+	query := `UPDATE tasks SET ` + strings.Join(sets, ", ") + ` WHERE id = ? AND deleted_at IS NULL RETURNING ` + taskColumns
+	args = append(args, id)
+
+	start := time.Now()
+	task, err := scanTask(db.queryReturning(ctx, tx, "tasks", int64(id), query, args...))
+	db.checkSlowQuery(ctx, start, query, args...)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return nil, err
+	}
+
+	// Completion gets its own event type; any other field change is an update
+	changes := taskFieldChanges(*before, *task)
+	if _, ok := changes["completed"]; ok {
+		event := TaskEventCompleted
+		if !task.Completed {
+			event = TaskEventUncompleted
+		}
+		if err := db.recordTaskEvent(ctx, tx, id, event, nil); err != nil {
+			return nil, err
+		}
+		delete(changes, "completed")
+		delete(changes, "completed_at")
+	}
+	if len(changes) > 0 {
+		if err := db.recordTaskEvent(ctx, tx, id, TaskEventUpdated, changes); err != nil {
+			return nil, err
+		}
+	}
+	if update.Completed != nil && *update.Completed {
+		if err := db.queueOutbox(ctx, tx, EventTaskCompleted, task); err != nil {
+			return nil, err
+		}
+	}
+
+	return task, tx.Commit()
+}
+
+func (db *DB) UncompleteTask(ctx context.Context, id int) (*Task, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.UncompleteTask",
+		trace.WithAttributes(
+			attribute.String("db.operation", "update_task"),
+			attribute.Int("task.id", id),
+		))
+	defer span.End()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	task, err := db.uncompleteTask(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	return task, tx.Commit()
+}
+
+// uncompleteTask reopens a task, unless someone else holds a claim on it
+func (db *DB) uncompleteTask(ctx context.Context, q queryer, id int) (*Task, error) {
+	if err := db.checkClaim(ctx, q, id); err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE tasks SET completed = FALSE, completed_at = NULL, updated_at = ? WHERE id = ? AND deleted_at IS NULL RETURNING ` + taskColumns
+	now := time.Now().UTC()
+	start := time.Now()
+	task, err := scanTask(db.queryReturning(ctx, q, "tasks", int64(id), query, now, id))
+	db.checkSlowQuery(ctx, start, query, now, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return task, db.recordTaskEvent(ctx, q, id, TaskEventUncompleted, nil)
+}
+
+// TaskStats holds aggregate counts describing the current state of the task list
+type TaskStats struct {
+	Open           int64
+	Overdue        int64
+	CompletedToday int64
+}
+
+// GetTaskStats computes task counts in a single aggregate query. "Today" starts at local midnight.
+func (db *DB) GetTaskStats(ctx context.Context, now time.Time) (TaskStats, error) {
+	query := `
+	SELECT
+		COALESCE(SUM(CASE WHEN completed = FALSE THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN completed = FALSE AND due_at IS NOT NULL AND due_at < ? THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN completed_at IS NOT NULL AND completed_at >= ? THEN 1 ELSE 0 END), 0)
+	FROM tasks
+	WHERE deleted_at IS NULL`
+
+	year, month, day := now.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, now.Location()).UTC()
+
+	var stats TaskStats
+	start := time.Now()
+	err := db.conn.QueryRowContext(ctx, query, now.UTC(), midnight).Scan(&stats.Open, &stats.Overdue, &stats.CompletedToday)
+	db.checkSlowQuery(ctx, start, query, now.UTC(), midnight)
+	return stats, err
+}
+
+func (db *DB) Close() error {
+	if db.health != nil {
+		db.health.stop()
+	}
+	return db.conn.Close()
+}
+
+// utcTime normalizes an optional timestamp to UTC so stored values compare consistently
+func utcTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+// formatQueryWithArgs replaces SQL placeholders with actual values for better observability
+func formatQueryWithArgs(query string, args []driver.NamedValue) string {
+	if len(args) == 0 {
+		return query
+	}
+
+	formattedQuery := query
+	for i, arg := range args {
+		placeholder := "?"
+		if strings.Contains(query, "$") {
+			// PostgreSQL style placeholders
+			placeholder = fmt.Sprintf("$%d", i+1)
+		}
+
+		var value string
+		if arg.Value == nil {
+			value = "NULL"
+		} else {
+			switch v := arg.Value.(type) {
+			case string:
+				value = fmt.Sprintf("'%s'", v)
+			case []byte:
+				value = fmt.Sprintf("'%s'", string(v))
+			case int64, int32, int16, int8, int:
+				value = fmt.Sprintf("%d", v)
+			case float64, float32:
+				value = fmt.Sprintf("%f", v)
+			case bool:
+				if v {
+					value = "TRUE"
+				} else {
+					value = "FALSE"
+				}
+			default:
+				value = fmt.Sprintf("%v", v)
+			}
+		}
+
+		// Replace the first occurrence of the placeholder
+		formattedQuery = strings.Replace(formattedQuery, placeholder, value, 1)
+	}
+
+	return formattedQuery
+}
+
+// Warm runs the queries behind the task list and the task gauges once, so the first
+// requests do not pay for loading the database pages
+func (db *DB) Warm(ctx context.Context) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.Warm",
+		trace.WithAttributes(attribute.String("db.operation", "warmup")))
+	defer span.End()
+
+	if _, err := db.GetAllTasks(ctx, TaskQuery{}); err != nil {
+		return err
+	}
+	_, err := db.GetTaskStats(ctx, time.Now())
+	return err
+}
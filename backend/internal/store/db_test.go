@@ -0,0 +1,58 @@
+package store_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"todo-app/internal/store"
+	"todo-app/internal/store/storetest"
+)
+
+// newTestDB opens and migrates the database at dataSourceName, emptied first when it is
+// a shared PostgreSQL or MySQL database, and closes it when the test ends
+func newTestDB(t *testing.T, dataSourceName string) *store.DB {
+	t.Helper()
+	ctx := context.Background()
+	db, err := store.NewDB(ctx, dataSourceName)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := store.DropTables(ctx, db); err != nil {
+		t.Fatalf("DropTables: %v", err)
+	}
+	if err := db.Setup(ctx); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	return db
+}
+
+func TestSQLiteStore(t *testing.T) {
+	storetest.TestStore(t, func(t *testing.T) store.TaskStore {
+		return newTestDB(t, filepath.Join(t.TempDir(), "tasks.db"))
+	})
+}
+
+// TestPostgresStore runs against the database at TODO_TEST_POSTGRES_URL, whose tables it
+// drops; it is skipped when that is unset
+func TestPostgresStore(t *testing.T) {
+	testSharedDB(t, "TODO_TEST_POSTGRES_URL")
+}
+
+// TestMySQLStore runs against the database at TODO_TEST_MYSQL_URL, whose tables it drops;
+// it is skipped when that is unset
+func TestMySQLStore(t *testing.T) {
+	testSharedDB(t, "TODO_TEST_MYSQL_URL")
+}
+
+func testSharedDB(t *testing.T, env string) {
+	dataSourceName := os.Getenv(env)
+	if dataSourceName == "" {
+		t.Skip(env + " not set")
+	}
+	storetest.TestStore(t, func(t *testing.T) store.TaskStore {
+		return newTestDB(t, dataSourceName)
+	})
+}
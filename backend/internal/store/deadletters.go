@@ -0,0 +1,228 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Kinds of dead letter
+const (
+	DeadLetterWebhook      = "webhook"
+	deadLetterNotification = "notification"
+)
+
+// DeadLetterKinds lists the outbound deliveries that are kept once they have failed
+var DeadLetterKinds = []string{DeadLetterWebhook, deadLetterNotification}
+
+// MaxDeadLetters bounds a GET /admin/dead-letters page
+const MaxDeadLetters = 500
+
+// DeadLetter is an outbound delivery that failed for good: a webhook delivery out of
+// attempts, or a rule notification its notifier rejected. Payload is only filled in when
+// a single dead letter is inspected.
+type DeadLetter struct {
+	Kind     string          `json:"kind"`
+	ID       int             `json:"id"`
+	UserID   string          `json:"user_id"`
+	Channel  string          `json:"channel"`
+	Target   string          `json:"target"`
+	Event    string          `json:"event"`
+	Error    string          `json:"error"`
+	Attempts int             `json:"attempts"`
+	FailedAt time.Time       `json:"failed_at"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+}
+
+// FailedNotification is a rule notification whose delivery failed, with the task as it
+// was when the event happened
+type FailedNotification struct {
+	ID       int
+	UserID   string
+	RuleID   int
+	Notifier string
+	Target   string
+	Event    string
+	Task     Task
+	Error    string
+	Attempts int
+}
+
+// recordFailedNotification keeps a failed notification as a dead letter
+func (db *DB) recordFailedNotification(ctx context.Context, q queryer, n FailedNotification) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.recordFailedNotification",
+		trace.WithAttributes(
+			attribute.String("db.operation", "insert_failed_notification"),
+			attribute.Int("notification.rule_id", n.RuleID),
+		))
+	defer span.End()
+
+	data, err := json.Marshal(n.Task)
+	if err != nil {
+		return err
+	}
+	task, err := SealField(fieldFailedNotification, string(data))
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx, `
+	INSERT INTO failed_notifications (user_id, rule_id, notifier, target, event, task, error, attempts, failed_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		n.UserID, n.RuleID, n.Notifier, n.Target, n.Event, task, n.Error, n.Attempts, time.Now().UTC())
+	return err
+}
+
+// GetFailedNotification returns a failed notification, sql.ErrNoRows if there is none
+func (db *DB) GetFailedNotification(ctx context.Context, id int) (*FailedNotification, error) {
+	n := &FailedNotification{}
+	var task string
+	err := db.conn.QueryRowContext(ctx, `
+	SELECT id, user_id, rule_id, notifier, target, event, task, error, attempts FROM failed_notifications WHERE id = ?`, id).
+		Scan(&n.ID, &n.UserID, &n.RuleID, &n.Notifier, &n.Target, &n.Event, sealedString{fieldFailedNotification, &task}, &n.Error, &n.Attempts)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(task), &n.Task); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// deadLetterQueries select each kind's dead letters as DeadLetter columns, with the payload
+// last. Both take the ID to match, or NULL for all.
+var deadLetterQueries = map[string]string{
+	DeadLetterWebhook: `SELECT 'webhook', d.id, w.user_id, 'webhook', w.url, d.event, COALESCE(d.last_error, ''), d.attempts, d.failed_at, d.payload
+	FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+	WHERE d.status = 'failed' AND (?1 IS NULL OR d.id = ?1)`,
+	deadLetterNotification: `SELECT 'notification', id, user_id, notifier, target, event, error, attempts, failed_at, task
+	FROM failed_notifications WHERE (?1 IS NULL OR id = ?1)`,
+}
+
+// DeadLetters returns the most recent dead letters of the given kinds, without payloads
+func (db *DB) DeadLetters(ctx context.Context, kinds []string, limit int) ([]DeadLetter, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.DeadLetters",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_dead_letters"),
+			attribute.StringSlice("dead_letter.kinds", kinds),
+		))
+	defer span.End()
+
+	parts := make([]string, len(kinds))
+	for i, kind := range kinds {
+		parts[i] = deadLetterQueries[kind]
+	}
+	query := `SELECT * FROM (` + strings.Join(parts, "\nUNION ALL\n") + `) AS letters ORDER BY 9 DESC LIMIT ?2`
+	start := time.Now()
+	rows, err := db.conn.QueryContext(ctx, query, nil, limit)
+	db.checkSlowQuery(ctx, start, query, nil, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	letters := []DeadLetter{}
+	for rows.Next() {
+		letter, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		letter.Payload = nil
+		letters = append(letters, *letter)
+	}
+	return letters, rows.Err()
+}
+
+// DeadLetter returns one dead letter with its payload, sql.ErrNoRows if there is none
+func (db *DB) DeadLetter(ctx context.Context, kind string, id int) (*DeadLetter, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.DeadLetter",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_dead_letters"),
+			attribute.String("dead_letter.kind", kind),
+			attribute.Int("dead_letter.id", id),
+		))
+	defer span.End()
+
+	return scanDeadLetter(db.conn.QueryRowContext(ctx, deadLetterQueries[kind], id))
+}
+
+func scanDeadLetter(row rowScanner) (*DeadLetter, error) {
+	letter := &DeadLetter{}
+	var payload string
+	err := row.Scan(&letter.Kind, &letter.ID, &letter.UserID, &letter.Channel, &letter.Target, &letter.Event,
+		&letter.Error, &letter.Attempts, &letter.FailedAt, &payload)
+	if err != nil {
+		return nil, err
+	}
+	field := fieldWebhookPayload
+	if letter.Kind == deadLetterNotification {
+		field = fieldFailedNotification
+	}
+	if payload, err = OpenField(field, payload); err != nil {
+		return nil, err
+	}
+	letter.Payload = json.RawMessage(payload)
+	return letter, nil
+}
+
+// DeleteDeadLetter discards a dead letter, sql.ErrNoRows if there is none
+func (db *DB) DeleteDeadLetter(ctx context.Context, kind string, id int) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.DeleteDeadLetter",
+		trace.WithAttributes(
+			attribute.String("db.operation", "delete_dead_letter"),
+			attribute.String("dead_letter.kind", kind),
+			attribute.Int("dead_letter.id", id),
+		))
+	defer span.End()
+
+	query := `DELETE FROM failed_notifications WHERE id = ?`
+	if kind == DeadLetterWebhook {
+		query = `DELETE FROM webhook_deliveries WHERE id = ? AND status = 'failed'`
+	}
+	result, err := db.conn.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RequeueWebhookDelivery makes a failed webhook delivery pending again with a fresh set of
+// attempts, sql.ErrNoRows if there is no such failed delivery
+func (db *DB) RequeueWebhookDelivery(ctx context.Context, id int) error {
+	result, err := db.conn.ExecContext(ctx, `
+	UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = ?, failed_at = NULL
+	WHERE id = ? AND status = 'failed'`, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ForgetFailedNotification removes a failed notification that has been delivered
+func (db *DB) ForgetFailedNotification(ctx context.Context, id int) error {
+	_, err := db.conn.ExecContext(ctx, `DELETE FROM failed_notifications WHERE id = ?`, id)
+	return err
+}
+
+// RecordReplayFailure counts another failed attempt at a failed notification
+func (db *DB) RecordReplayFailure(ctx context.Context, id int, cause error) error {
+	_, err := db.conn.ExecContext(ctx, `UPDATE failed_notifications SET error = ?, attempts = attempts + 1, failed_at = ? WHERE id = ?`,
+		cause.Error(), time.Now().UTC(), id)
+	return err
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// demoResetTables are emptied by ResetDemo, before the tasks, lists and workspaces, whose
+// default list and workspace are kept. Attachments are left for the attachment cleanup job,
+// which deletes their content once their task is gone; the cluster tables, signature nonces
+// and the schema version are not demo data.
+var demoResetTables = []string{
+	"snapshot_tasks",
+	"snapshots",
+	"task_dependencies",
+	"task_links",
+	"review_decisions",
+	"hook_tasks",
+	"hook_deliveries",
+	"task_events",
+	"task_tombstones",
+	"notification_queue",
+	"notification_outbox",
+	"notification_rules",
+	"notification_settings",
+	"failed_notifications",
+	"webhook_deliveries",
+	"webhooks",
+	"idempotency_keys",
+	"export_cursors",
+	"user_stats",
+	"uploads",
+	"list_settings",
+	"changelog",
+	"workspace_settings",
+	"workspace_members",
+}
+
+// DemoTask is a task a demo instance starts with after each reset
+type DemoTask struct {
+	NewTask
+	Completed bool
+}
+
+// DemoTasks returns the tasks a demo starts with, due relative to now
+func DemoTasks(now time.Time) []DemoTask {
+	day := func(days int) *time.Time {
+		t := now.Add(time.Duration(days) * 24 * time.Hour).Truncate(time.Hour)
+		return &t
+	}
+	return []DemoTask{
+		{NewTask: NewTask{Title: "Try the demo", Description: "Everything here is reset regularly, so feel free to change anything", Tags: Tags{"welcome"}}},
+		{NewTask: NewTask{Title: "Buy groceries", Description: "Milk, eggs, bread", DueAt: day(1), Tags: Tags{"errands"}}},
+		{NewTask: NewTask{Title: "Book dentist appointment", DueAt: day(3), Tags: Tags{"health"}}},
+		{NewTask: NewTask{Title: "Review quarterly report", DueAt: day(-1), Tags: Tags{"work"}}},
+		{NewTask: NewTask{Title: "Plan weekend trip", Tags: Tags{"personal"}}},
+		{NewTask: NewTask{Title: "Renew library books", Tags: Tags{"errands"}}, Completed: true},
+		{NewTask: NewTask{Title: "Set up the project board", Tags: Tags{"work"}}, Completed: true},
+	}
+}
+
+// ResetDemo deletes every task and everything users made around them, leaving the default
+// list and workspace under their original names, and returns the IDs of the tasks that were
+// not in the trash. Each deleted task is appended to the changelog, so clients following
+// GET /tasks/changes drop it, and the change sequence keeps growing.
+func (db *DB) ResetDemo(ctx context.Context) ([]int, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.ResetDemo",
+		trace.WithAttributes(attribute.String("db.operation", "reset_demo")))
+	defer span.End()
+
+	var removed []int
+	err := db.WithTx(ctx, "reset_demo", func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		removed, err = selectIDs(ctx, tx, `SELECT id FROM tasks WHERE deleted_at IS NULL ORDER BY id`)
+		if err != nil {
+			return err
+		}
+		for _, table := range demoResetTables {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table); err != nil {
+				return err
+			}
+		}
+		ids, err := selectIDs(ctx, tx, `SELECT id FROM tasks`)
+		if err != nil {
+			return err
+		}
+		if err := db.logTaskChange(ctx, tx, ids...); err != nil {
+			return err
+		}
+		statements := []struct {
+			query string
+			args  []any
+		}{
+			{`DELETE FROM tasks`, nil},
+			{`DELETE FROM lists WHERE is_default = FALSE`, nil},
+			{`UPDATE lists SET name = ? WHERE is_default = TRUE`, []any{DefaultListName}},
+			{`DELETE FROM workspaces WHERE is_default = FALSE`, nil},
+			{`UPDATE workspaces SET name = ? WHERE is_default = TRUE`, []any{DefaultWorkspaceName}},
+		}
+		for _, s := range statements {
+			start := time.Now()
+			_, err := tx.ExecContext(ctx, s.query, s.args...)
+			db.checkSlowQuery(ctx, start, s.query, s.args...)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("demo.removed_tasks", len(removed)))
+	return removed, nil
+}
@@ -0,0 +1,337 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ErrTaskBlocked is returned when completing a task whose blockers are not all complete
+	ErrTaskBlocked = errors.New("task is blocked by incomplete tasks")
+	// ErrDependencyCycle is returned when a dependency would make a task block itself
+	ErrDependencyCycle = errors.New("dependency would create a cycle")
+	// ErrSelfDependency is returned when a task is declared blocked by itself
+	ErrSelfDependency = errors.New("a task cannot block itself")
+	// ErrBlockerNotFound is returned when the blocking task does not exist or is in the trash
+	ErrBlockerNotFound = errors.New("blocking task not found")
+)
+
+// TaskDependencies is the response of GET /tasks/{id}/dependencies
+type TaskDependencies struct {
+	BlockedBy []Task `json:"blocked_by"`
+	Blocking  []Task `json:"blocking"`
+}
+
+// attachDependencies fills in BlockedBy and Blocked. Blockers in the trash are ignored;
+// a task is blocked while any remaining blocker is incomplete.
+func (db *DB) attachDependencies(ctx context.Context, q queryer, tasks []Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	query := `SELECT d.task_id, d.blocked_by_id, b.completed FROM task_dependencies d
+	JOIN tasks b ON b.id = d.blocked_by_id
+	WHERE b.deleted_at IS NULL`
+	var args []any
+	if len(tasks) == 1 {
+		query += ` AND d.task_id = ?`
+		args = append(args, tasks[0].ID)
+	}
+	query += ` ORDER BY d.task_id, d.blocked_by_id`
+
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args...)
+	db.checkSlowQuery(ctx, start, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	index := make(map[int]int, len(tasks))
+	for i := range tasks {
+		index[tasks[i].ID] = i
+	}
+	for rows.Next() {
+		var taskID, blockerID int
+		var completed bool
+		if err := rows.Scan(&taskID, &blockerID, &completed); err != nil {
+			return err
+		}
+		i, ok := index[taskID]
+		if !ok {
+			continue
+		}
+		tasks[i].BlockedBy = append(tasks[i].BlockedBy, blockerID)
+		if !completed {
+			tasks[i].Blocked = true
+		}
+	}
+	return rows.Err()
+}
+
+// openBlockers returns the IDs of the incomplete, non-trashed tasks blocking id
+func (db *DB) openBlockers(ctx context.Context, q queryer, id int) ([]int, error) {
+	rows, err := q.QueryContext(ctx, `SELECT b.id FROM task_dependencies d
+	JOIN tasks b ON b.id = d.blocked_by_id
+	WHERE d.task_id = ? AND b.completed = FALSE AND b.deleted_at IS NULL
+	ORDER BY b.id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var blocker int
+		if err := rows.Scan(&blocker); err != nil {
+			return nil, err
+		}
+		ids = append(ids, blocker)
+	}
+	return ids, rows.Err()
+}
+
+// BlockedError reports the tasks that keep a task from being completed
+type BlockedError struct {
+	TaskID   int
+	Blockers []int
+}
+
+func (e *BlockedError) Error() string {
+	ids := make([]string, len(e.Blockers))
+	for i, id := range e.Blockers {
+		ids[i] = strconv.Itoa(id)
+	}
+	return fmt.Sprintf("Task %d is blocked by incomplete tasks: %s", e.TaskID, strings.Join(ids, ", "))
+}
+
+func (e *BlockedError) Unwrap() error {
+	return ErrTaskBlocked
+}
+
+// checkNotBlocked returns a *BlockedError if id has incomplete blockers
+func (db *DB) checkNotBlocked(ctx context.Context, q queryer, id int) error {
+	blockers, err := db.openBlockers(ctx, q, id)
+	if err != nil {
+		return err
+	}
+	if len(blockers) > 0 {
+		return &BlockedError{TaskID: id, Blockers: blockers}
+	}
+	return nil
+}
+
+// GetTaskDependencies returns the active tasks blocking id and the ones it blocks
+func (db *DB) GetTaskDependencies(ctx context.Context, id int) (*TaskDependencies, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetTaskDependencies",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_task_dependencies"),
+			attribute.Int("task.id", id),
+		))
+	defer span.End()
+
+	if _, err := db.GetTask(ctx, id); err != nil {
+		return nil, err
+	}
+
+	deps := &TaskDependencies{}
+	var err error
+	deps.BlockedBy, err = db.queryDependentTasks(ctx, `SELECT `+prefixedTaskColumns("t")+` FROM task_dependencies d
+	JOIN tasks t ON t.id = d.blocked_by_id
+	WHERE d.task_id = ? AND t.deleted_at IS NULL ORDER BY t.id`, id)
+	if err != nil {
+		return nil, err
+	}
+	deps.Blocking, err = db.queryDependentTasks(ctx, `SELECT `+prefixedTaskColumns("t")+` FROM task_dependencies d
+	JOIN tasks t ON t.id = d.task_id
+	WHERE d.blocked_by_id = ? AND t.deleted_at IS NULL ORDER BY t.id`, id)
+	if err != nil {
+		return nil, err
+	}
+	return deps, nil
+}
+
+func (db *DB) queryDependentTasks(ctx context.Context, query string, args ...any) ([]Task, error) {
+	start := time.Now()
+	rows, err := db.conn.QueryContext(ctx, query, args...)
+	db.checkSlowQuery(ctx, start, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []Task{}
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *task)
+	}
+	return tasks, rows.Err()
+}
+
+// prefixedTaskColumns qualifies taskColumns with a table alias for joins
+func prefixedTaskColumns(alias string) string {
+	columns := strings.Split(taskColumns, ",")
+	for i, c := range columns {
+		columns[i] = alias + "." + strings.TrimSpace(c)
+	}
+	return strings.Join(columns, ", ")
+}
+
+// AddTaskDependency declares that id is blocked by blockerID and returns the task with its
+// updated blocked status. Adding an existing dependency is a no-op.
+func (db *DB) AddTaskDependency(ctx context.Context, id, blockerID int) (*Task, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.AddTaskDependency",
+		trace.WithAttributes(
+			attribute.String("db.operation", "insert_task_dependency"),
+			attribute.Int("task.id", id),
+			attribute.Int("task.blocked_by", blockerID),
+		))
+	defer span.End()
+
+	if id == blockerID {
+		return nil, ErrSelfDependency
+	}
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var active int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM tasks WHERE id = ? AND deleted_at IS NULL`, id).Scan(&active)
+	if err == nil && active == 0 {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM tasks WHERE id = ? AND deleted_at IS NULL`, blockerID).Scan(&active)
+	if err == nil && active == 0 {
+		err = ErrBlockerNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The new edge closes a cycle if id already (transitively) blocks blockerID
+	var cycle int
+	query := `WITH RECURSIVE blockers (id) AS (
+		SELECT blocked_by_id FROM task_dependencies WHERE task_id = ?
+		UNION
+		SELECT d.blocked_by_id FROM task_dependencies d JOIN blockers b ON d.task_id = b.id
+	)
+	SELECT COUNT(*) FROM blockers WHERE id = ?`
+	start := time.Now()
+	err = tx.QueryRowContext(ctx, query, blockerID, id).Scan(&cycle)
+	db.checkSlowQuery(ctx, start, query, blockerID, id)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	if cycle > 0 {
+		return nil, ErrDependencyCycle
+	}
+
+	result, err := tx.ExecContext(ctx, `
+	INSERT INTO task_dependencies (task_id, blocked_by_id, created_at) VALUES (?, ?, ?)
+	ON CONFLICT (task_id, blocked_by_id) DO NOTHING`, id, blockerID, time.Now().UTC())
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	if added, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if added > 0 {
+		err = touchTask(ctx, tx, id)
+		if err == nil {
+			err = db.recordTaskEvent(ctx, tx, id, TaskEventDependencyAdded, map[string]FieldChange{"blocked_by": {To: blockerID}})
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	task, err := scanTask(tx.QueryRowContext(ctx, `SELECT `+taskColumns+` FROM tasks WHERE id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	tasks := []Task{*task}
+	if err := db.attachRelations(ctx, tx, tasks); err != nil {
+		return nil, err
+	}
+
+	return &tasks[0], tx.Commit()
+}
+
+// RemoveTaskDependency deletes a dependency, sql.ErrNoRows if there is none
+func (db *DB) RemoveTaskDependency(ctx context.Context, id, blockerID int) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.RemoveTaskDependency",
+		trace.WithAttributes(
+			attribute.String("db.operation", "delete_task_dependency"),
+			attribute.Int("task.id", id),
+			attribute.Int("task.blocked_by", blockerID),
+		))
+	defer span.End()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM task_dependencies WHERE task_id = ? AND blocked_by_id = ?`, id, blockerID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	err = touchTask(ctx, tx, id)
+	if err == nil {
+		err = db.recordTaskEvent(ctx, tx, id, TaskEventDependencyRemoved, map[string]FieldChange{"blocked_by": {From: blockerID}})
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ResolveTaskRef turns a numeric ID or UUID into a task ID, following a merged task's UUID
+// to the task it was merged into
+func (db *DB) ResolveTaskRef(ctx context.Context, ref string) (int, error) {
+	if id, err := strconv.Atoi(ref); err == nil {
+		return id, nil
+	}
+	taskUUID, ok := ParseTaskUUID(ref)
+	if !ok {
+		return 0, ErrBlockerNotFound
+	}
+	id, err := db.TaskIDByUUID(ctx, taskUUID)
+	if merged := (*TaskMergedError)(nil); errors.As(err, &merged) {
+		return merged.Into, nil
+	}
+	if err == sql.ErrNoRows || errors.Is(err, ErrTaskDeleted) {
+		return 0, ErrBlockerNotFound
+	}
+	return id, err
+}
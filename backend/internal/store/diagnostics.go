@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DBDiagnostics summarizes the database for a diagnostics bundle
+type DBDiagnostics struct {
+	SchemaVersion int                `json:"schema_version"`
+	LatestVersion int                `json:"latest_version"`
+	Migrations    []AppliedMigration `json:"migrations"`
+	Tables        map[string]int64   `json:"tables"`
+	Dialect       string             `json:"dialect"`
+	// The storage statistics are SQLite's, zero on PostgreSQL
+	PageSize      int64          `json:"page_size"`
+	PageCount     int64          `json:"page_count"`
+	FreelistCount int64          `json:"freelist_count"`
+	JournalMode   string         `json:"journal_mode"`
+	Encrypted     bool           `json:"encrypted"`
+	Pool          map[string]any `json:"pool"`
+}
+
+// AppliedMigration is a row of schema_migrations
+type AppliedMigration struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// Diagnostics gathers the schema version, row counts and storage and pool statistics
+func (db *DB) Diagnostics(ctx context.Context) (*DBDiagnostics, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.Diagnostics",
+		trace.WithAttributes(attribute.String("db.operation", "diagnostics")))
+	defer span.End()
+
+	d := &DBDiagnostics{
+		LatestVersion: migrations[len(migrations)-1].version,
+		Tables:        map[string]int64{},
+		Dialect:       string(db.dialect),
+		Encrypted:     EncryptionEnabled(),
+	}
+	rows, err := db.conn.QueryContext(ctx, `SELECT version, name, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var m AppliedMigration
+		if err := rows.Scan(&m.Version, &m.Name, &m.AppliedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.Migrations = append(d.Migrations, m)
+		d.SchemaVersion = max(d.SchemaVersion, m.Version)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.conn.QueryContext(ctx, db.dialect.tablesQuery())
+	if err != nil {
+		return nil, err
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, table := range tables {
+		var count int64
+		query := fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, strings.ReplaceAll(table, `"`, `""`))
+		if err := db.conn.QueryRowContext(ctx, query).Scan(&count); err != nil {
+			return nil, err
+		}
+		d.Tables[table] = count
+	}
+
+	pragmas := map[string]any{
+		"page_size":      &d.PageSize,
+		"page_count":     &d.PageCount,
+		"freelist_count": &d.FreelistCount,
+		"journal_mode":   &d.JournalMode,
+	}
+	if db.dialect != dialectSQLite {
+		pragmas = nil
+	}
+	for pragma, dest := range pragmas {
+		if err := db.conn.QueryRowContext(ctx, "PRAGMA "+pragma).Scan(dest); err != nil {
+			return nil, err
+		}
+	}
+
+	stats := db.conn.Stats()
+	d.Pool = map[string]any{
+		"max_open_connections": stats.MaxOpenConnections,
+		"open_connections":     stats.OpenConnections,
+		"in_use":               stats.InUse,
+		"idle":                 stats.Idle,
+		"wait_count":           stats.WaitCount,
+		"wait_duration_ms":     stats.WaitDuration.Milliseconds(),
+		"max_idle_closed":      stats.MaxIdleClosed,
+		"max_lifetime_closed":  stats.MaxLifetimeClosed,
+	}
+	return d, nil
+}
@@ -0,0 +1,224 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sqlDialect is the kind of SQL database the tasks are stored in. Statements are written
+// for SQLite and most run unchanged on PostgreSQL and MySQL; the few that differ ask the
+// dialect.
+type sqlDialect string
+
+const (
+	dialectSQLite   sqlDialect = "sqlite"
+	dialectPostgres sqlDialect = "postgres"
+	dialectMySQL    sqlDialect = "mysql"
+)
+
+// dialectFor picks the dialect from the data source name: a postgres:// or postgresql://
+// URL is PostgreSQL, a mysql:// URL MySQL or MariaDB, anything else a SQLite file
+func dialectFor(dsn string) sqlDialect {
+	switch {
+	case strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://"):
+		return dialectPostgres
+	case strings.HasPrefix(dsn, "mysql://"):
+		return dialectMySQL
+	}
+	return dialectSQLite
+}
+
+var (
+	ddlStatement     = regexp.MustCompile(`(?i)^\s*(CREATE|ALTER)\b`)
+	ddlAutoincrement = regexp.MustCompile(`(?i)\bINTEGER PRIMARY KEY AUTOINCREMENT\b`)
+	ddlInteger       = regexp.MustCompile(`(?i)\bINTEGER\b`)
+	ddlTimestamp     = regexp.MustCompile(`(?i)\bTIMESTAMP\b`)
+	ddlReferences    = regexp.MustCompile(`(?i)\s+REFERENCES\s+\w+\s*\(\s*\w+\s*\)`)
+
+	ddlCreateIndex      = regexp.MustCompile(`(?i)^\s*CREATE\s+(UNIQUE\s+)?INDEX\b`)
+	ddlIfNotExists      = regexp.MustCompile(`(?i)\bINDEX IF NOT EXISTS\b`)
+	ddlPartial          = regexp.MustCompile(`(?is)\)\s*WHERE\b.*$`)
+	ddlCurrentTimestamp = regexp.MustCompile(`(?i)\bDEFAULT CURRENT_TIMESTAMP\b`)
+	ddlTextKey          = regexp.MustCompile(`(?i)\bTEXT(\s+NOT NULL)?(\s+(PRIMARY KEY|UNIQUE))`)
+	ddlTextDefault      = regexp.MustCompile(`(?i)\bTEXT(\s+NOT NULL)?\s+DEFAULT\s+('[^']*')`)
+	ddlCompositeKey     = regexp.MustCompile(`(?i)\bPRIMARY KEY\s*\(([^)]*)\)`)
+)
+
+// ddl adapts a CREATE or ALTER statement written for SQLite; others are returned as is.
+// Integers are 64-bit as in SQLite, and REFERENCES clauses are dropped: SQLite only
+// enforces them with TODO_SQLITE_FOREIGN_KEYS, and the app does not expect it to. On PostgreSQL, timestamps carry a time
+// zone so they always mean UTC; see mysqlDDL for MySQL.
+func (d sqlDialect) ddl(stmt string) string {
+	if d == dialectSQLite || !ddlStatement.MatchString(stmt) {
+		return stmt
+	}
+	if d == dialectMySQL {
+		return mysqlDDL(stmt)
+	}
+	stmt = ddlAutoincrement.ReplaceAllString(stmt, "BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY")
+	stmt = ddlInteger.ReplaceAllString(stmt, "BIGINT")
+	stmt = ddlTimestamp.ReplaceAllString(stmt, "TIMESTAMPTZ")
+	return ddlReferences.ReplaceAllString(stmt, "")
+}
+
+// mysqlDDL adapts a CREATE or ALTER statement for MySQL. Timestamps are DATETIME with
+// microseconds, always in UTC. A TEXT column cannot be a key, so one declared PRIMARY KEY or
+// UNIQUE, or named in the table's PRIMARY KEY, becomes VARCHAR(255); an index on other TEXT
+// columns needs a replacement statement with prefix lengths. TEXT defaults are written as
+// expressions, the only form MySQL accepts. MySQL has neither CREATE INDEX IF NOT EXISTS nor
+// partial indexes, so the condition is dropped and the index covers every row.
+func mysqlDDL(stmt string) string {
+	stmt = ddlAutoincrement.ReplaceAllString(stmt, "BIGINT AUTO_INCREMENT PRIMARY KEY")
+	stmt = ddlInteger.ReplaceAllString(stmt, "BIGINT")
+	stmt = ddlTimestamp.ReplaceAllString(stmt, "DATETIME(6)")
+	stmt = ddlCurrentTimestamp.ReplaceAllString(stmt, "DEFAULT CURRENT_TIMESTAMP(6)")
+	stmt = ddlReferences.ReplaceAllString(stmt, "")
+	stmt = ddlTextKey.ReplaceAllString(stmt, "VARCHAR(255)$1$2")
+	for _, key := range ddlCompositeKey.FindAllStringSubmatch(stmt, -1) {
+		for _, column := range strings.Split(key[1], ",") {
+			column = regexp.QuoteMeta(strings.Trim(strings.TrimSpace(column), `"`))
+			stmt = regexp.MustCompile(`(?i)(\b`+column+`"?\s+)TEXT\b`).ReplaceAllString(stmt, "${1}VARCHAR(255)")
+		}
+	}
+	stmt = ddlTextDefault.ReplaceAllString(stmt, "TEXT$1 DEFAULT ($2)")
+	if ddlCreateIndex.MatchString(stmt) {
+		stmt = ddlIfNotExists.ReplaceAllString(stmt, "INDEX")
+		stmt = ddlPartial.ReplaceAllString(stmt, ")")
+	}
+	return stmt
+}
+
+// jsonValues is a subquery selecting the elements of the JSON array of strings expr, as
+// column value
+func (d sqlDialect) jsonValues(expr string) string {
+	switch d {
+	case dialectPostgres:
+		return `SELECT value FROM json_array_elements_text(CAST(` + expr + ` AS json))`
+	case dialectMySQL:
+		return `SELECT value FROM JSON_TABLE(` + expr + `, '$[*]' COLUMNS (value TEXT PATH '$')) AS elements`
+	}
+	return `SELECT value FROM json_each(` + expr + `)`
+}
+
+// jsonIDs is a subquery selecting the elements of the JSON array of integers expr, as
+// column value
+func (d sqlDialect) jsonIDs(expr string) string {
+	switch d {
+	case dialectPostgres:
+		return `SELECT CAST(value AS BIGINT) FROM json_array_elements_text(CAST(` + expr + ` AS json))`
+	case dialectMySQL:
+		return `SELECT value FROM JSON_TABLE(` + expr + `, '$[*]' COLUMNS (value BIGINT PATH '$')) AS elements`
+	}
+	return `SELECT value FROM json_each(` + expr + `)`
+}
+
+// tablesQuery lists the application's tables by name
+func (d sqlDialect) tablesQuery() string {
+	switch d {
+	case dialectPostgres:
+		return `SELECT table_name FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_type = 'BASE TABLE' ORDER BY table_name`
+	case dialectMySQL:
+		return `SELECT table_name FROM information_schema.tables
+		WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE' ORDER BY table_name`
+	}
+	return `SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`
+}
+
+// rewritePlaceholders calls write for each ? and ?NNN placeholder of query with its number,
+// counted the way SQLite counts: a bare ? takes the number after the highest used so far.
+// Placeholders inside quotes and comments are left alone.
+func rewritePlaceholders(query string, write func(b *strings.Builder, n int)) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 1
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end])
+			i += end - 1
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+4])
+			i += end + 3
+		case c == '?':
+			j := i + 1
+			for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+				j++
+			}
+			if j > i+1 {
+				k, _ := strconv.Atoi(query[i+1 : j])
+				n = max(n, k)
+				write(&b, k)
+				i = j - 1
+			} else {
+				n++
+				write(&b, n)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+var returningClause = regexp.MustCompile(`\s+RETURNING\s+`)
+
+// queryReturning runs query, an INSERT or an UPDATE of the row id that ends in RETURNING,
+// and returns the row. MySQL has no RETURNING, so there the statement runs without it and
+// the columns are read back from table by id, or by the id the INSERT generated when id is 0.
+// An UPDATE that matches no row reads as sql.ErrNoRows, as it does elsewhere.
+func (db *DB) queryReturning(ctx context.Context, q queryer, table string, id int64, query string, args ...any) rowScanner {
+	loc := returningClause.FindStringIndex(query)
+	if db.dialect != dialectMySQL || loc == nil {
+		return q.QueryRowContext(ctx, query, args...)
+	}
+	result, err := q.ExecContext(ctx, query[:loc[0]], args...)
+	if err != nil {
+		return errRow{err}
+	}
+	if id == 0 {
+		if id, err = result.LastInsertId(); err != nil {
+			return errRow{err}
+		}
+	} else if n, err := result.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = sql.ErrNoRows
+		}
+		return errRow{err}
+	}
+	return q.QueryRowContext(ctx, `SELECT `+query[loc[1]:]+` FROM `+table+` WHERE id = ?`, id)
+}
+
+// errRow is a row whose statement failed
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(dest ...any) error {
+	return r.err
+}
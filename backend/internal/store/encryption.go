@@ -0,0 +1,276 @@
+package store
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// encryptedPrefix marks a sealed value. Values without it are plaintext, written before
+// encryption was enabled, and are read as they are.
+const encryptedPrefix = "enc:v1:"
+
+// Encrypted fields. The name is bound to the ciphertext as additional data, so a value
+// copied into another field fails to decrypt instead of showing up there.
+const (
+	fieldTaskTitle          = "tasks.title"
+	fieldTaskDescription    = "tasks.description"
+	fieldSnapshotTask       = "snapshot_tasks.data"
+	fieldTaskEventChanges   = "task_events.changes"
+	fieldNotificationQueue  = "notification_queue.task"
+	FieldIdempotentResponse = "idempotency_keys.response"
+	FieldCachedTasks        = "cache.tasks"
+	fieldWebhookSecret      = "webhooks.secret"
+	fieldWebhookPayload     = "webhook_deliveries.payload"
+	fieldFailedNotification = "failed_notifications.task"
+	fieldNotificationOutbox = "notification_outbox.task"
+)
+
+var errNoEncryptionKey = errors.New("found an encrypted value but TODO_ENCRYPTION_KEY is not set")
+
+// FieldCipher encrypts task content at rest with AES-GCM. Titles and descriptions are
+// sealed as they are written and opened as they are scanned, along with the copies kept in
+// snapshots, task history, the notification queue and outbox, stored idempotent responses,
+// task lists cached in Redis, webhook payloads and failed notifications. Webhook secrets are
+// sealed too. Search and sorting happen after the tasks are loaded, so they keep working on
+// the plaintext.
+type FieldCipher struct {
+	aead cipher.AEAD
+}
+
+// fieldCipher is the cipher NewDB configured from TODO_ENCRYPTION_KEY, or nil when
+// encryption is off. It is package state because scanTask and the other readers are free
+// functions shared by every query.
+var fieldCipher *FieldCipher
+
+// EncryptionEnabled reports whether TODO_ENCRYPTION_KEY is set
+func EncryptionEnabled() bool {
+	return fieldCipher != nil
+}
+
+// NewFieldCipher creates a cipher from a base64-encoded 16, 24 or 32 byte key
+// (AES-128, -192 or -256). An empty key returns nil, which leaves fields in plaintext.
+func NewFieldCipher(key string) (*FieldCipher, error) {
+	if key == "" {
+		return nil, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("key is not valid base64: %w", err)
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, fmt.Errorf("key must be 16, 24 or 32 bytes, got %d", len(raw))
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &FieldCipher{aead: aead}, nil
+}
+
+// Seal encrypts plaintext for field. A nil cipher returns plaintext unchanged.
+func (c *FieldCipher) Seal(field, plaintext string) (string, error) {
+	if c == nil {
+		return plaintext, nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), []byte(field))
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value of field written by Seal. Plaintext values are returned unchanged.
+func (c *FieldCipher) Open(field, value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return value, nil
+	}
+	if c == nil {
+		return "", errNoEncryptionKey
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("decrypting %s: %w", field, err)
+	}
+	nonceSize := c.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", fmt.Errorf("decrypting %s: value too short", field)
+	}
+	plaintext, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("decrypting %s: %w", field, err)
+	}
+	return string(plaintext), nil
+}
+
+// SealField encrypts a value with the configured cipher
+func SealField(field, plaintext string) (string, error) {
+	return fieldCipher.Seal(field, plaintext)
+}
+
+// OpenField decrypts a value with the configured cipher
+func OpenField(field, value string) (string, error) {
+	return fieldCipher.Open(field, value)
+}
+
+// sealedString scans an encrypted column into dest, decrypting it
+type sealedString struct {
+	field string
+	dest  *string
+}
+
+func (s sealedString) Scan(src any) error {
+	var value string
+	switch v := src.(type) {
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	case nil:
+		value = ""
+	default:
+		return fmt.Errorf("cannot scan %T into %s", src, s.field)
+	}
+	opened, err := OpenField(s.field, value)
+	if err != nil {
+		return err
+	}
+	*s.dest = opened
+	return nil
+}
+
+// sealedColumn is a column holding an encrypted field, in a table with the primary key key
+type sealedColumn struct {
+	table, column, field string
+	key                  []string
+}
+
+// sealedColumns are the columns holding encrypted fields
+var sealedColumns = []sealedColumn{
+	{"tasks", "title", fieldTaskTitle, []string{"id"}},
+	{"tasks", "description", fieldTaskDescription, []string{"id"}},
+	{"snapshot_tasks", "data", fieldSnapshotTask, []string{"snapshot_id", "task_id"}},
+	{"task_events", "changes", fieldTaskEventChanges, []string{"id"}},
+	{"notification_queue", "task", fieldNotificationQueue, []string{"id"}},
+	{"idempotency_keys", "response", FieldIdempotentResponse, []string{"user_id", `"key"`}},
+	{"webhooks", "secret", fieldWebhookSecret, []string{"id"}},
+	{"webhook_deliveries", "payload", fieldWebhookPayload, []string{"id"}},
+	{"failed_notifications", "task", fieldFailedNotification, []string{"id"}},
+	{"notification_outbox", "task", fieldNotificationOutbox, []string{"id"}},
+}
+
+// sealExistingFields encrypts the plaintext left in encrypted columns from before
+// encryption was enabled. Values already sealed are skipped, so it is cheap to run on
+// every start. Without a key it only checks that nothing is encrypted, so a missing key
+// stops startup instead of failing every read.
+func (db *DB) sealExistingFields(ctx context.Context) error {
+	if fieldCipher == nil {
+		var sealed int
+		err := db.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM tasks WHERE substr(title, 1, ?) = ?`,
+			len(encryptedPrefix), encryptedPrefix).Scan(&sealed)
+		if err != nil {
+			return err
+		}
+		if sealed > 0 {
+			return errNoEncryptionKey
+		}
+		return nil
+	}
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.sealExistingFields",
+		trace.WithAttributes(attribute.String("db.operation", "encrypt_fields")))
+	defer span.End()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	total := 0
+	for _, c := range sealedColumns {
+		n, err := sealColumn(ctx, tx, c)
+		if err != nil {
+			return fmt.Errorf("encrypting %s: %w", c.field, err)
+		}
+		total += n
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	span.SetAttributes(attribute.Int("encryption.sealed_values", total))
+	if total > 0 {
+		slog.InfoContext(ctx, "Encrypted existing plaintext fields", "count", total)
+	}
+	return nil
+}
+
+// sealColumn seals the plaintext values of c row by row, so each gets its own nonce and
+// rows holding the same value cannot be told apart by their ciphertext
+func sealColumn(ctx context.Context, tx *sql.Tx, c sealedColumn) (int, error) {
+	query := fmt.Sprintf(`SELECT %s, %s FROM %s WHERE substr(%s, 1, ?) != ?`,
+		strings.Join(c.key, ", "), c.column, c.table, c.column)
+	rows, err := tx.QueryContext(ctx, query, len(encryptedPrefix), encryptedPrefix)
+	if err != nil {
+		return 0, err
+	}
+	type plaintextRow struct {
+		key   []any
+		value string
+	}
+	var pending []plaintextRow
+	for rows.Next() {
+		row := plaintextRow{key: make([]any, len(c.key))}
+		dest := make([]any, 0, len(c.key)+1)
+		for i := range row.key {
+			dest = append(dest, &row.key[i])
+		}
+		if err := rows.Scan(append(dest, &row.value)...); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending = append(pending, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	where := make([]string, len(c.key))
+	for i, column := range c.key {
+		where[i] = column + " = ?"
+	}
+	update := fmt.Sprintf(`UPDATE %s SET %s = ? WHERE %s`, c.table, c.column, strings.Join(where, " AND "))
+	total := 0
+	for _, row := range pending {
+		sealed, err := SealField(c.field, row.value)
+		if err != nil {
+			return 0, err
+		}
+		result, err := tx.ExecContext(ctx, update, append([]any{sealed}, row.key...)...)
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		total += int(n)
+	}
+	return total, nil
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSealExistingFieldsSealsEachRow(t *testing.T) {
+	ctx := context.Background()
+	db, err := NewDB(ctx, filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	if err := db.Setup(ctx); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	var ids []int
+	for range 2 {
+		task, err := db.CreateTask(ctx, NewTask{Title: "Same title", Description: "Same description"})
+		if err != nil {
+			t.Fatalf("CreateTask: %v", err)
+		}
+		ids = append(ids, task.ID)
+	}
+
+	key := make([]byte, 32)
+	rand.Read(key)
+	cipher, err := NewFieldCipher(base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		t.Fatalf("NewFieldCipher: %v", err)
+	}
+	fieldCipher = cipher
+	t.Cleanup(func() { fieldCipher = nil })
+	if err := db.sealExistingFields(ctx); err != nil {
+		t.Fatalf("sealExistingFields: %v", err)
+	}
+
+	titles := map[string]bool{}
+	for _, id := range ids {
+		var title string
+		if err := db.conn.QueryRowContext(ctx, `SELECT title FROM tasks WHERE id = ?`, id).Scan(&title); err != nil {
+			t.Fatalf("reading task %d: %v", id, err)
+		}
+		if !strings.HasPrefix(title, encryptedPrefix) {
+			t.Errorf("title of task %d = %q, want it sealed", id, title)
+		}
+		titles[title] = true
+
+		task, err := db.GetTask(ctx, id)
+		if err != nil {
+			t.Fatalf("GetTask(%d): %v", id, err)
+		}
+		if task.Title != "Same title" || task.Description != "Same description" {
+			t.Errorf("task %d opened as %q / %q", id, task.Title, task.Description)
+		}
+	}
+	if len(titles) != len(ids) {
+		t.Error("equal titles were sealed to the same ciphertext")
+	}
+}
+
+func TestNormalizeRejectsEncryptedPrefix(t *testing.T) {
+	if _, err := NormalizeTitle(encryptedPrefix + "abc"); err == nil {
+		t.Error("NormalizeTitle accepted a title starting with the encrypted prefix")
+	}
+	if _, err := NormalizeDescription("  " + encryptedPrefix + "abc"); err == nil {
+		t.Error("NormalizeDescription accepted a description starting with the encrypted prefix")
+	}
+	if _, err := NormalizeTitle("Read about " + encryptedPrefix); err != nil {
+		t.Errorf("NormalizeTitle refused the prefix inside a title: %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"todo-app/internal/requestctx"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// EventTaskDeleted is published when a task is moved to the trash. It is delivered to live
+// subscribers and webhooks; notification rules do not fire on it.
+const EventTaskDeleted = "task.deleted"
+
+// BusEvents are the event types published on the EventBus
+var BusEvents = []string{EventTaskCreated, EventTaskCompleted, EventTaskUncompleted, EventTaskDeleted}
+
+// BusEvent is a task change published after it has been committed
+type BusEvent struct {
+	Type     string    `json:"type"`
+	TaskID   int       `json:"task_id"`
+	TaskUUID string    `json:"task_uuid,omitempty"`
+	Task     *Task     `json:"task,omitempty"`
+	Seq      int64     `json:"seq,omitempty"`
+	Time     time.Time `json:"time"`
+	Actor    string    `json:"actor,omitempty"`
+	TraceID  string    `json:"trace_id,omitempty"`
+}
+
+// Subscription receives the events published on an EventBus. C is closed when the
+// subscriber falls behind or the bus shuts down.
+type Subscription struct {
+	C      <-chan BusEvent
+	ch     chan BusEvent
+	bus    *EventBus
+	lagged bool
+}
+
+// Lagged reports whether C was closed because the subscriber fell behind
+func (s *Subscription) Lagged() bool {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	return s.lagged
+}
+
+// Close unsubscribes. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subs[s]; ok {
+		delete(s.bus.subs, s)
+		close(s.ch)
+	}
+}
+
+// EventBus fans task changes out to in-process subscribers. Publish never blocks the
+// request that made the change: a subscriber whose buffer is full is dropped, and is
+// expected to reconnect and catch up with GET /tasks/changes.
+type EventBus struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+	hooks  []func(ctx context.Context, event BusEvent)
+
+	published metric.Int64Counter
+	dropped   metric.Int64Counter
+}
+
+// NewEventBus creates an empty bus
+func NewEventBus() *EventBus {
+	b := &EventBus{subs: map[*Subscription]struct{}{}}
+	meter := telemetry.GetMeter()
+	b.published, _ = meter.Int64Counter("todo_app.events.published",
+		metric.WithDescription("Task events published on the event bus"),
+		metric.WithUnit("1"))
+	b.dropped, _ = meter.Int64Counter("todo_app.events.dropped_subscribers",
+		metric.WithDescription("Event bus subscribers dropped for falling behind"),
+		metric.WithUnit("1"))
+	meter.Int64ObservableGauge("todo_app.events.subscribers",
+		metric.WithDescription("Current event bus subscribers"),
+		metric.WithUnit("1"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			o.Observe(int64(len(b.subs)))
+			return nil
+		}))
+	return b
+}
+
+// Subscribe registers a subscriber that can hold up to buffer undelivered events
+func (b *EventBus) Subscribe(buffer int) *Subscription {
+	ch := make(chan BusEvent, buffer)
+	s := &Subscription{C: ch, ch: ch, bus: b}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return s
+	}
+	b.subs[s] = struct{}{}
+	return s
+}
+
+// OnPublish registers a hook that Publish calls, before notifying subscribers, for every
+// change made by this replica. Relayed changes skip it, as the replica that made them ran
+// its own hooks. Hooks must be registered before anything is published.
+func (b *EventBus) OnPublish(hook func(ctx context.Context, event BusEvent)) {
+	b.hooks = append(b.hooks, hook)
+}
+
+// Publish delivers event to every subscriber, filling in the change sequence, and the actor
+// and trace unless set, from ctx
+func (b *EventBus) Publish(ctx context.Context, event BusEvent) {
+	if b == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	if event.Task != nil {
+		event.TaskID = event.Task.ID
+		event.TaskUUID = event.Task.UUID
+	}
+	if c := changeSeqFromContext(ctx); c != nil {
+		event.Seq = c.seq.Load()
+	}
+	if event.Actor == "" {
+		event.Actor = requestctx.User(ctx)
+	}
+	if sc := trace.SpanContextFromContext(ctx); event.TraceID == "" && sc.HasTraceID() {
+		event.TraceID = sc.TraceID().String()
+	}
+	for _, hook := range b.hooks {
+		hook(ctx, event)
+	}
+	b.Relay(ctx, event)
+}
+
+// Relay delivers an event published by another replica to every subscriber
+func (b *EventBus) Relay(ctx context.Context, event BusEvent) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		select {
+		case s.ch <- event:
+		default:
+			s.lagged = true
+			delete(b.subs, s)
+			close(s.ch)
+			b.dropped.Add(ctx, 1)
+		}
+	}
+	b.published.Add(ctx, 1, metric.WithAttributes(attribute.String("event", event.Type)))
+}
+
+// PublishTasks publishes one event of type for each task
+func (b *EventBus) PublishTasks(ctx context.Context, eventType string, tasks ...*Task) {
+	for _, task := range tasks {
+		b.Publish(ctx, BusEvent{Type: eventType, Task: task})
+	}
+}
+
+// Close ends every subscription, and any made later, so connections holding them finish
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subs {
+		delete(b.subs, s)
+		close(s.ch)
+	}
+}
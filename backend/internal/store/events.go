@@ -0,0 +1,192 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"todo-app/internal/requestctx"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Task history event types
+const (
+	TaskEventCreated           = "created"
+	TaskEventUpdated           = "updated"
+	TaskEventCompleted         = "completed"
+	TaskEventUncompleted       = "uncompleted"
+	TaskEventDeleted           = "deleted"
+	TaskEventRestored          = "restored"
+	TaskEventMoved             = "moved"
+	TaskEventDependencyAdded   = "dependency_added"
+	TaskEventDependencyRemoved = "dependency_removed"
+	TaskEventPurged            = "purged"
+	TaskEventClaimed           = "claimed"
+	TaskEventReleased          = "released"
+	TaskEventMerged            = "merged"
+	TaskEventLinkAdded         = "link_added"
+	TaskEventLinkRemoved       = "link_removed"
+)
+
+// systemActor is recorded for changes made outside a request, e.g. by background jobs
+const systemActor = "system"
+
+// FieldChange is the old and new value of one task field
+type FieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// TaskEvent is one entry of a task's audit history. TraceID and SpanID point at the
+// request (or job run) that made the change.
+type TaskEvent struct {
+	ID        int                    `json:"id"`
+	TaskID    int                    `json:"task_id"`
+	Event     string                 `json:"event"`
+	Changes   map[string]FieldChange `json:"changes,omitempty"`
+	Actor     string                 `json:"actor"`
+	TraceID   string                 `json:"trace_id,omitempty"`
+	SpanID    string                 `json:"span_id,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// eventContext returns the actor and trace identifiers recorded with events made under ctx
+func eventContext(ctx context.Context) (actor, traceID, spanID string) {
+	actor = requestctx.User(ctx)
+	if actor == "" {
+		actor = systemActor
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		traceID, spanID = sc.TraceID().String(), sc.SpanID().String()
+	}
+	return actor, traceID, spanID
+}
+
+// recordTaskEvent appends an event to a task's history and the change to the changelog.
+// q should be the transaction that made the change, so the change and its record commit or
+// roll back together.
+func (db *DB) recordTaskEvent(ctx context.Context, q queryer, taskID int, event string, changes map[string]FieldChange) error {
+	data := "{}"
+	if len(changes) > 0 {
+		b, err := json.Marshal(changes)
+		if err != nil {
+			return err
+		}
+		data = string(b)
+	}
+	// Changes can hold old and new titles and descriptions
+	data, err := SealField(fieldTaskEventChanges, data)
+	if err != nil {
+		return err
+	}
+	actor, traceID, spanID := eventContext(ctx)
+	_, err = q.ExecContext(ctx, `
+	INSERT INTO task_events (task_id, event, changes, actor, trace_id, span_id, instance_id, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, taskID, event, data, actor, traceID, spanID, telemetry.InstanceID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if err := db.logTaskChange(ctx, q, taskID); err != nil {
+		return err
+	}
+	// Background jobs complete tasks for no one in particular
+	if user := requestctx.User(ctx); event == TaskEventCompleted && user != "" {
+		if err := db.recordCompletion(ctx, q, user, time.Now()); err != nil {
+			return err
+		}
+	}
+	switch event {
+	case TaskEventCompleted, TaskEventUncompleted, TaskEventDeleted, TaskEventRestored:
+		// The blocked flag of the tasks it blocks may have changed with it
+		if err := db.logTaskChangesWhere(ctx, q, `id IN (SELECT task_id FROM task_dependencies WHERE blocked_by_id = ?)`, taskID); err != nil {
+			return err
+		}
+	}
+	switch event {
+	case TaskEventDeleted, TaskEventRestored:
+		// Linked tasks only list it while it is out of the trash
+		return db.logTaskChangesWhere(ctx, q, `id IN (SELECT linked_id FROM task_links WHERE task_id = ?)
+		OR id IN (SELECT task_id FROM task_links WHERE linked_id = ?)`, taskID, taskID)
+	}
+	return nil
+}
+
+// taskFieldChanges describes the user-visible fields that differ between before and after
+func taskFieldChanges(before, after Task) map[string]FieldChange {
+	changes := map[string]FieldChange{}
+	for _, field := range changedTaskFields(before, after) {
+		switch field {
+		case "title":
+			changes[field] = FieldChange{before.Title, after.Title}
+		case "description":
+			changes[field] = FieldChange{before.Description, after.Description}
+		case "completed":
+			changes[field] = FieldChange{before.Completed, after.Completed}
+		case "due_at":
+			changes[field] = FieldChange{before.DueAt, after.DueAt}
+		case "completed_at":
+			changes[field] = FieldChange{before.CompletedAt, after.CompletedAt}
+		case "tags":
+			changes[field] = FieldChange{before.Tags, after.Tags}
+		}
+	}
+	if before.ListID != after.ListID {
+		changes["list_id"] = FieldChange{before.ListID, after.ListID}
+	}
+	return changes
+}
+
+// GetTaskHistory returns a task's events, oldest first. History outlives the task, so
+// this works for trashed and purged tasks too; sql.ErrNoRows means the ID was never used.
+func (db *DB) GetTaskHistory(ctx context.Context, taskID int) ([]TaskEvent, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetTaskHistory",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_task_events"),
+			attribute.Int("task.id", taskID),
+		))
+	defer span.End()
+
+	query := `SELECT id, task_id, event, changes, actor, trace_id, span_id, created_at
+	FROM task_events WHERE task_id = ? ORDER BY id`
+	start := time.Now()
+	rows, err := db.conn.QueryContext(ctx, query, taskID)
+	db.checkSlowQuery(ctx, start, query, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []TaskEvent{}
+	for rows.Next() {
+		var e TaskEvent
+		var changes string
+		var traceID, spanID sql.NullString
+		if err := rows.Scan(&e.ID, &e.TaskID, &e.Event, sealedString{fieldTaskEventChanges, &changes}, &e.Actor, &traceID, &spanID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(changes), &e.Changes); err != nil {
+			return nil, err
+		}
+		e.TraceID, e.SpanID = traceID.String, spanID.String
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("task.events", len(events)))
+
+	if len(events) == 0 {
+		var exists int
+		if err := db.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM tasks WHERE id = ?`, taskID).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if exists == 0 {
+			return nil, sql.ErrNoRows
+		}
+	}
+	return events, nil
+}
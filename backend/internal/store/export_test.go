@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// DropTables drops every table of db, so a test against a shared PostgreSQL or MySQL
+// database migrates an empty schema
+func DropTables(ctx context.Context, db *DB) error {
+	switch db.dialect {
+	case dialectPostgres:
+		_, err := db.conn.ExecContext(ctx, `DROP SCHEMA public CASCADE; CREATE SCHEMA public`)
+		return err
+	case dialectMySQL:
+		rows, err := db.conn.QueryContext(ctx, `SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE()`)
+		if err != nil {
+			return err
+		}
+		var tables []string
+		for rows.Next() {
+			var table string
+			if err := rows.Scan(&table); err != nil {
+				rows.Close()
+				return err
+			}
+			tables = append(tables, table)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		// One connection, as the foreign key checks are a session setting
+		conn, err := db.conn.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if _, err := conn.ExecContext(ctx, `SET FOREIGN_KEY_CHECKS = 0`); err != nil {
+			return err
+		}
+		for _, table := range tables {
+			if _, err := conn.ExecContext(ctx, fmt.Sprintf("DROP TABLE `%s`", table)); err != nil {
+				return err
+			}
+		}
+		_, err = conn.ExecContext(ctx, `SET FOREIGN_KEY_CHECKS = 1`)
+		return err
+	}
+	return nil
+}
+
+// CollectPage offers tasks to the page collector of q in order, as GetAllTasks does with its
+// rows. It returns the page, the most tasks the collector held at once and how many tasks
+// were offered before it had the page.
+func CollectPage(q TaskQuery, tasks []Task) (page []Task, held, read int) {
+	c := newPageCollector(q, q.Tag)
+	for _, task := range tasks {
+		read++
+		done := c.add(task)
+		held = max(held, len(c.tasks))
+		if done {
+			break
+		}
+	}
+	return c.page(), held, read
+}
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// DatabaseRetryAfter is how long clients are told to wait while the database is unavailable
+const DatabaseRetryAfter = 2 * time.Second
+
+// dbHealth follows whether a PostgreSQL server answers, from the errors statements get and
+// from a ping every TODO_DB_HEALTH_INTERVAL, or every second while it is down. During a
+// failover, e.g. managed database maintenance, the pooled connections die and new ones are
+// refused for a while; the server answers 503 meanwhile, see DB.Unavailable.
+type dbHealth struct {
+	interval time.Duration
+	maxIdle  int
+	// down is set from the first failure until a ping succeeds
+	down      atomic.Bool
+	downSince atomic.Int64
+	// recheck wakes the watcher to ping at once when a statement fails
+	recheck chan struct{}
+	stop    context.CancelFunc
+}
+
+func newDBHealth(maxIdle int) *dbHealth {
+	return &dbHealth{
+		interval: config.EnvDuration("TODO_DB_HEALTH_INTERVAL", 5*time.Second),
+		maxIdle:  maxIdle,
+		recheck:  make(chan struct{}, 1),
+	}
+}
+
+// IsUnavailable reports whether err says the database cannot be reached right now, rather
+// than that the statement was wrong: a connection that broke or was refused, or a server
+// shutting down, starting up or read-only after a failover
+func IsUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	if rejectedByFailover(err) {
+		return true
+	}
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// rejectedByFailover reports whether the server refused a statement, so it did not run, because
+// it is going away, not ready yet or no longer the primary
+func rejectedByFailover(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch pgErr.Code {
+	case "57P01", "57P02", "57P03", // admin_shutdown, crash_shutdown, cannot_connect_now
+		"25006": // read_only_sql_transaction: connected to a former primary
+		return true
+	}
+	// Class 08, connection exception
+	return strings.HasPrefix(pgErr.Code, "08")
+}
+
+// check notes an error of a statement, and turns it into driver.ErrBadConn when the statement
+// can safely run again: database/sql then drops the connection and retries on another one.
+// That is the case when the server refused it, or when it only reads.
+func (h *dbHealth) check(err error, query string) error {
+	if !IsUnavailable(err) {
+		return err
+	}
+	h.markDown(err)
+	if rejectedByFailover(err) || readOnlyQuery(query) {
+		return driver.ErrBadConn
+	}
+	return err
+}
+
+// readOnlyQuery reports whether query only reads, so running it twice is harmless
+func readOnlyQuery(query string) bool {
+	upper := strings.ToUpper(strings.TrimSpace(query))
+	if strings.HasPrefix(upper, "SELECT") {
+		return !strings.Contains(upper, "FOR UPDATE")
+	}
+	return strings.HasPrefix(upper, "WITH") &&
+		!strings.Contains(upper, "INSERT") && !strings.Contains(upper, "UPDATE") && !strings.Contains(upper, "DELETE")
+}
+
+func (h *dbHealth) markDown(err error) {
+	if h.down.CompareAndSwap(false, true) {
+		h.downSince.Store(time.Now().UnixMilli())
+		slog.Warn("Database unavailable, answering 503 until it is back", "error", err)
+		select {
+		case h.recheck <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// watch pings the database until ctx is done, at once when a statement finds it down. Then
+// the idle connections are closed, so requests do not pick up connections to a server that
+// is gone.
+func (h *dbHealth) watch(ctx context.Context, conn *sql.DB) {
+	flushed := false
+	for {
+		wait := h.interval
+		if h.down.Load() {
+			wait = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		case <-h.recheck:
+		}
+
+		wasDown := h.down.Load()
+		if wasDown && !flushed {
+			// The pooled connections lead to a server that is gone
+			conn.SetMaxIdleConns(0)
+			conn.SetMaxIdleConns(h.maxIdle)
+			flushed = true
+		}
+		pingCtx, cancel := context.WithTimeout(ctx, wait)
+		err := conn.PingContext(pingCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		switch {
+		case err != nil:
+			h.markDown(err)
+		case wasDown:
+			h.down.Store(false)
+			flushed = false
+			slog.Info("Database reachable again", "unavailable_for", time.Since(time.UnixMilli(h.downSince.Load())).Round(time.Millisecond).String())
+		}
+	}
+}
+
+// start pings the database in the background until Close, and reports its availability
+// as the todo_app.db.available gauge
+func (h *dbHealth) start(conn *sql.DB) {
+	ctx, cancel := context.WithCancel(context.Background())
+	h.stop = cancel
+	go h.watch(ctx, conn)
+
+	telemetry.GetMeter().Int64ObservableGauge("todo_app.db.available",
+		metric.WithDescription("1 while the database answers, 0 during a failover"),
+		metric.WithUnit("1"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			available := int64(1)
+			if h.down.Load() {
+				available = 0
+			}
+			o.Observe(available)
+			return nil
+		}))
+}
+
+// Unavailable reports whether the database is known to be unreachable, during a failover.
+// Only PostgreSQL databases are watched; the others always report false.
+func (db *DB) Unavailable() bool {
+	return db.health != nil && db.health.down.Load()
+}
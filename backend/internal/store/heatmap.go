@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Heatmap is a year of completions per day, for a contribution-style calendar
+type Heatmap struct {
+	Year     int          `json:"year"`
+	Timezone string       `json:"timezone"`
+	Total    int          `json:"total"`
+	Max      int          `json:"max"`
+	Days     []HeatmapDay `json:"days"`
+}
+
+// HeatmapDay is the number of completions on one day, YYYY-MM-DD in the heatmap's time zone
+type HeatmapDay struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+// GetCompletionHeatmap counts the completed events of every day of year in loc, including
+// days without any. Events are grouped here rather than in SQL, since the databases do not
+// agree on time zone conversion and a day in loc is not always 24 hours long.
+func (db *DB) GetCompletionHeatmap(ctx context.Context, year int, loc *time.Location) (*Heatmap, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetCompletionHeatmap",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_completion_heatmap"),
+			attribute.Int("heatmap.year", year),
+			attribute.String("heatmap.timezone", loc.String()),
+		))
+	defer span.End()
+
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+	to := from.AddDate(1, 0, 0)
+	query := `SELECT created_at FROM task_events WHERE event = ? AND created_at >= ? AND created_at < ?`
+	start := time.Now()
+	rows, err := db.conn.QueryContext(ctx, query, TaskEventCompleted, from.UTC(), to.UTC())
+	db.checkSlowQuery(ctx, start, query, TaskEventCompleted, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var at time.Time
+		if err := rows.Scan(&at); err != nil {
+			return nil, err
+		}
+		counts[at.In(loc).Format(statsDayLayout)]++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	heatmap := &Heatmap{Year: year, Timezone: loc.String(), Days: []HeatmapDay{}}
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(statsDayLayout)
+		count := counts[date]
+		heatmap.Days = append(heatmap.Days, HeatmapDay{Date: date, Count: count})
+		heatmap.Total += count
+		heatmap.Max = max(heatmap.Max, count)
+	}
+	span.SetAttributes(attribute.Int("heatmap.total", heatmap.Total))
+	return heatmap, nil
+}
+
+// UserLocation returns the time zone of a user's notification settings, UTC by default
+func (db *DB) UserLocation(ctx context.Context, userID string) (*time.Location, error) {
+	return statsLocation(ctx, db.conn, userID)
+}
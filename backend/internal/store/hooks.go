@@ -0,0 +1,159 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HookDeliveryRetention is how long delivery IDs are remembered to drop redeliveries
+const HookDeliveryRetention = 7 * 24 * time.Hour
+
+// Task changes an inbound hook can ask for
+const (
+	HookCreate   = "create"
+	HookComplete = "complete"
+	HookReopen   = "reopen"
+)
+
+// ErrHookDuplicate is returned for a delivery that was already applied
+var ErrHookDuplicate = errors.New("hook delivery already received")
+
+// HookAction is one task change asked for by an inbound event. ExternalID names the object
+// in the provider, e.g. a GitHub issue; the task created for it is found by it later.
+type HookAction struct {
+	Op         string
+	ExternalID string
+	Task       NewTask
+}
+
+// HookResult is the outcome of a HookAction: created, exists (a task was already created
+// for the object), completed, reopened, not_found (no task for the object) or conflict
+type HookResult struct {
+	Op         string `json:"op"`
+	ExternalID string `json:"external_id"`
+	TaskID     int    `json:"task_id,omitempty"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	// Task is the task created or changed, for publishing the event
+	Task *Task `json:"-"`
+}
+
+// ApplyHook records a delivery and applies its actions in one transaction, so a delivery
+// is applied once or not at all. It returns ErrHookDuplicate if the delivery was already
+// received. Actions that cannot apply, such as completing an object with no task, are
+// reported in their result without failing the others.
+func (db *DB) ApplyHook(ctx context.Context, provider, event, deliveryID string, actions []HookAction) ([]HookResult, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.ApplyHook",
+		trace.WithAttributes(
+			attribute.String("db.operation", "apply_hook"),
+			attribute.String("hook.provider", provider),
+			attribute.Int("hook.actions", len(actions)),
+		))
+	defer span.End()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `INSERT INTO hook_deliveries (provider, delivery_id, event, received_at) VALUES (?, ?, ?, ?)
+	ON CONFLICT DO NOTHING`, provider, deliveryID, event, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrHookDuplicate
+	}
+
+	results := make([]HookResult, len(actions))
+	for i, action := range actions {
+		results[i] = HookResult{Op: action.Op, ExternalID: action.ExternalID}
+		if err := db.applyHookAction(ctx, tx, provider, action, &results[i]); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, fmt.Errorf("hook action %d (%s): %w", i, action.Op, err)
+		}
+	}
+	return results, tx.Commit()
+}
+
+func (db *DB) applyHookAction(ctx context.Context, tx *sql.Tx, provider string, action HookAction, res *HookResult) error {
+	err := tx.QueryRowContext(ctx, `SELECT task_id FROM hook_tasks WHERE provider = ? AND external_id = ?`,
+		provider, action.ExternalID).Scan(&res.TaskID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	linked := err == nil
+
+	var task *Task
+	var event string
+	switch action.Op {
+	case HookCreate:
+		if linked {
+			res.Status = "exists"
+			return nil
+		}
+		if task, err = db.insertTask(ctx, tx, action.Task); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO hook_tasks (provider, external_id, task_id, created_at) VALUES (?, ?, ?, ?)`,
+			provider, action.ExternalID, task.ID, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		res.Status, event = "created", EventTaskCreated
+	case HookComplete, HookReopen:
+		if !linked {
+			res.Status = "not_found"
+			return nil
+		}
+		if action.Op == HookComplete {
+			task, err = db.completeTask(ctx, tx, res.TaskID)
+			res.Status, event = "completed", EventTaskCompleted
+		} else {
+			task, err = db.uncompleteTask(ctx, tx, res.TaskID)
+			res.Status = "reopened"
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			// The task was deleted since
+			res.Status = "not_found"
+			return nil
+		}
+		if errors.Is(err, ErrTaskBlocked) || errors.Is(err, ErrTaskClaimed) {
+			res.Status, res.Error = "conflict", err.Error()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown hook action %q", action.Op)
+	}
+
+	res.TaskID, res.Task = task.ID, task
+	if event != "" {
+		return db.queueOutbox(ctx, tx, event, task)
+	}
+	return nil
+}
+
+// PurgeHookDeliveries forgets deliveries received before cutoff
+func (db *DB) PurgeHookDeliveries(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := db.conn.ExecContext(ctx, `DELETE FROM hook_deliveries WHERE received_at < ?`, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
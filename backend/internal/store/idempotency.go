@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// IdempotencyTTL is how long a key and its stored response are kept
+	IdempotencyTTL = config.EnvDuration("TODO_IDEMPOTENCY_TTL", 24*time.Hour)
+	// IdempotencyPurgeInterval is how often expired keys are removed
+	IdempotencyPurgeInterval = config.EnvDuration("TODO_IDEMPOTENCY_PURGE_INTERVAL", time.Hour)
+)
+
+var (
+	ErrIdempotencyKeyInUse  = errors.New("a request with this Idempotency-Key is still in progress")
+	ErrIdempotencyKeyReused = errors.New("this Idempotency-Key was already used for a different request")
+)
+
+// IdempotentResponse is the stored outcome of a request made with an Idempotency-Key
+type IdempotentResponse struct {
+	Status      int
+	ContentType string
+	ChangeSeq   int64
+	Body        string
+}
+
+// BeginIdempotentRequest reserves key for the request with fingerprint. It returns the stored
+// response when the request already completed, ErrIdempotencyKeyInUse while it is still
+// running, ErrIdempotencyKeyReused when the key belongs to a different request, and nil
+// when the caller should handle the request and then finish or abandon the key.
+func (db *DB) BeginIdempotentRequest(ctx context.Context, userID, key, fingerprint string) (*IdempotentResponse, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.beginIdempotentRequest",
+		trace.WithAttributes(attribute.String("db.operation", "insert_idempotency_key")))
+	defer span.End()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	// An expired key is free again even if the purge job has not removed it yet
+	_, err = tx.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE user_id = ? AND "key" = ? AND created_at < ?`,
+		userID, key, now.Add(-IdempotencyTTL))
+	if err != nil {
+		return nil, err
+	}
+	result, err := tx.ExecContext(ctx, `
+	INSERT INTO idempotency_keys (user_id, "key", fingerprint, created_at) VALUES (?, ?, ?, ?)
+	ON CONFLICT (user_id, "key") DO NOTHING`, userID, key, fingerprint, now)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 1 {
+		return nil, tx.Commit()
+	}
+
+	var storedFingerprint string
+	var status sql.NullInt64
+	var contentType sql.NullString
+	var changeSeq sql.NullInt64
+	var body string
+	err = tx.QueryRowContext(ctx, `
+	SELECT fingerprint, status, content_type, change_seq, response FROM idempotency_keys WHERE user_id = ? AND "key" = ?`,
+		userID, key).Scan(&storedFingerprint, &status, &contentType, &changeSeq, sealedString{FieldIdempotentResponse, &body})
+	if err != nil {
+		return nil, err
+	}
+	if storedFingerprint != fingerprint {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if !status.Valid {
+		return nil, ErrIdempotencyKeyInUse
+	}
+	return &IdempotentResponse{
+		Status:      int(status.Int64),
+		ContentType: contentType.String,
+		ChangeSeq:   changeSeq.Int64,
+		Body:        body,
+	}, nil
+}
+
+// FinishIdempotentRequest stores the response of the request holding key, for replay to retries
+func (db *DB) FinishIdempotentRequest(ctx context.Context, userID, key string, response IdempotentResponse) error {
+	body, err := SealField(FieldIdempotentResponse, response.Body)
+	if err != nil {
+		return err
+	}
+	_, err = db.conn.ExecContext(ctx, `
+	UPDATE idempotency_keys SET status = ?, content_type = ?, change_seq = ?, response = ? WHERE user_id = ? AND "key" = ?`,
+		response.Status, response.ContentType, response.ChangeSeq, body, userID, key)
+	return err
+}
+
+// AbandonIdempotentRequest releases key without storing a response, so a retry runs the request again
+func (db *DB) AbandonIdempotentRequest(ctx context.Context, userID, key string) error {
+	_, err := db.conn.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE user_id = ? AND "key" = ? AND status IS NULL`, userID, key)
+	return err
+}
+
+// PurgeIdempotencyKeys removes keys created before cutoff
+func (db *DB) PurgeIdempotencyKeys(ctx context.Context, cutoff time.Time) (int64, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.PurgeIdempotencyKeys",
+		trace.WithAttributes(attribute.String("db.operation", "delete_idempotency_keys")))
+	defer span.End()
+
+	result, err := db.conn.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at < ?`, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	span.SetAttributes(attribute.Int64("purge.count", purged))
+	return purged, nil
+}
@@ -0,0 +1,197 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"github.com/google/uuid"
+)
+
+// ID strategies for new tasks, chosen with TODO_ID_STRATEGY
+const (
+	// IDAutoincrement lets the database number tasks and gives them random (v4) UUIDs
+	IDAutoincrement = "autoincrement"
+	// IDUUIDv7 gives tasks UUIDs that start with their creation time
+	IDUUIDv7 = "uuidv7"
+	// IDULID gives tasks ULIDs instead of UUIDs
+	IDULID = "ulid"
+	// IDSnowflake numbers tasks by creation time and node, with UUIDv7s
+	IDSnowflake = "snowflake"
+)
+
+// IDGenerator assigns the identifiers of new tasks. The numeric ID is the one the API and
+// the other tables use; the UUID is the stable external identifier (see Task.UUID).
+type IDGenerator interface {
+	// TaskID returns the ID of a new task, or 0 to let the database assign the next one
+	TaskID() int
+	// TaskUUID returns the external identifier of a new task
+	TaskUUID() string
+}
+
+// NewIDGenerator returns the generator for strategy. node tells replicas apart in
+// snowflake IDs and must be unique among the replicas sharing a database.
+func NewIDGenerator(strategy string, node int) (IDGenerator, error) {
+	switch strategy {
+	case IDAutoincrement:
+		return autoincrementIDs{}, nil
+	case IDUUIDv7:
+		return uuidv7IDs{}, nil
+	case IDULID:
+		return &ulidIDs{}, nil
+	case IDSnowflake:
+		if node < 0 || node > snowflakeMaxNode {
+			return nil, fmt.Errorf("snowflake node must be between 0 and %d, got %d", snowflakeMaxNode, node)
+		}
+		return &snowflakeIDs{node: node}, nil
+	}
+	return nil, fmt.Errorf("unknown ID strategy %q, expected one of %s, %s, %s, %s",
+		strategy, IDAutoincrement, IDUUIDv7, IDULID, IDSnowflake)
+}
+
+// idGeneratorFromEnv reads TODO_ID_STRATEGY and TODO_NODE_ID. Without TODO_NODE_ID the node
+// is derived from the instance ID, which may collide; set it when running snowflake IDs
+// on several replicas.
+func idGeneratorFromEnv() (IDGenerator, error) {
+	node := -1
+	if v := os.Getenv("TODO_NODE_ID"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TODO_NODE_ID %q", v)
+		}
+		node = n
+	} else {
+		h := fnv.New32a()
+		h.Write([]byte(telemetry.InstanceID))
+		node = int(h.Sum32() % (snowflakeMaxNode + 1))
+	}
+	return NewIDGenerator(config.EnvString("TODO_ID_STRATEGY", IDAutoincrement), node)
+}
+
+// ParseTaskUUID returns the canonical form of an external task identifier, a UUID or a
+// ULID, and false for anything else
+func ParseTaskUUID(ref string) (string, bool) {
+	if u, err := uuid.Parse(ref); err == nil {
+		return u.String(), true
+	}
+	if len(ref) == ulidLength && ref[0] <= '7' {
+		ref = strings.ToUpper(ref)
+		for _, c := range ref {
+			if !strings.ContainsRune(crockford, c) {
+				return "", false
+			}
+		}
+		return ref, true
+	}
+	return "", false
+}
+
+type autoincrementIDs struct{}
+
+func (autoincrementIDs) TaskID() int { return 0 }
+
+func (autoincrementIDs) TaskUUID() string { return uuid.NewString() }
+
+type uuidv7IDs struct{}
+
+func (uuidv7IDs) TaskID() int { return 0 }
+
+// TaskUUID returns a UUIDv7; the uuid package keeps them increasing within a millisecond
+func (uuidv7IDs) TaskUUID() string { return uuid.Must(uuid.NewV7()).String() }
+
+const (
+	// crockford is the base32 alphabet of ULIDs
+	crockford  = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	ulidLength = 26
+)
+
+// ulidIDs generates ULIDs: a 48-bit millisecond timestamp and 80 random bits, written in
+// 26 characters of Crockford base32. Within a millisecond the random part is incremented,
+// so IDs from one replica sort in creation order.
+type ulidIDs struct {
+	mu       sync.Mutex
+	lastMs   uint64
+	lastRand [10]byte
+}
+
+func (g *ulidIDs) TaskID() int { return 0 }
+
+func (g *ulidIDs) TaskUUID() string {
+	g.mu.Lock()
+	ms := uint64(time.Now().UnixMilli())
+	if ms <= g.lastMs {
+		// Same millisecond, or the clock went back: keep the timestamp and count up
+		ms = g.lastMs
+		for i := len(g.lastRand) - 1; i >= 0; i-- {
+			g.lastRand[i]++
+			if g.lastRand[i] != 0 {
+				break
+			}
+		}
+	} else {
+		g.lastMs = ms
+		rand.Read(g.lastRand[:])
+	}
+	var id [16]byte
+	binary.BigEndian.PutUint16(id[0:], uint16(ms>>32))
+	binary.BigEndian.PutUint32(id[2:], uint32(ms))
+	copy(id[6:], g.lastRand[:])
+	g.mu.Unlock()
+	return encodeULID(id)
+}
+
+// encodeULID writes 128 bits as 26 base32 characters, the first carrying the top 3 bits
+func encodeULID(id [16]byte) string {
+	hi, lo := binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:])
+	var out [ulidLength]byte
+	for i := ulidLength - 1; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+const (
+	snowflakeNodeBits = 10
+	snowflakeSeqBits  = 11
+	snowflakeMaxNode  = 1<<snowflakeNodeBits - 1
+	snowflakeMaxSeq   = 1<<snowflakeSeqBits - 1
+)
+
+// snowflakeEpoch is the start of snowflake time
+var snowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// snowflakeIDs numbers tasks with the seconds since snowflakeEpoch, the node and a sequence
+// within the second. The layout is 32+10+11 bits so IDs stay below 2^53 and survive
+// JavaScript clients. A node that creates more than 2048 tasks in a second borrows the next
+// second, so its IDs stay unique and increasing while the clock catches up.
+type snowflakeIDs struct {
+	node    int
+	mu      sync.Mutex
+	lastSec int64
+	seq     int
+}
+
+func (g *snowflakeIDs) TaskID() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	sec := int64(time.Since(snowflakeEpoch) / time.Second)
+	if sec > g.lastSec {
+		g.lastSec, g.seq = sec, 0
+	} else if g.seq++; g.seq > snowflakeMaxSeq {
+		g.lastSec, g.seq = g.lastSec+1, 0
+	}
+	return int(g.lastSec<<(snowflakeNodeBits+snowflakeSeqBits) | int64(g.node)<<snowflakeSeqBits | int64(g.seq))
+}
+
+func (g *snowflakeIDs) TaskUUID() string { return uuid.Must(uuid.NewV7()).String() }
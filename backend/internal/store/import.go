@@ -0,0 +1,210 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MaxImportTasks caps how many checklist items a single import may create
+var MaxImportTasks = config.EnvInt("TODO_IMPORT_MAX_TASKS", 500)
+
+// MaxImportBytes caps the size of an import request body
+const MaxImportBytes = 1 << 20
+
+var (
+	// checklistItemPattern matches "- [ ] title" / "* [x] title" with its indentation
+	checklistItemPattern = regexp.MustCompile(`^([ \t]*)[-*+][ \t]+\[([ xX])\][ \t]+(.*)$`)
+	// dueSuffixPattern matches the " (due 2006-01-02)" suffix written by the markdown export
+	dueSuffixPattern = regexp.MustCompile(`[ \t]+\(due (\d{4}-\d{2}-\d{2})\)$`)
+)
+
+// ImportItem is one checklist item parsed from markdown. Parent is the index of the
+// enclosing item in the parsed slice, or -1 for a top-level item.
+type ImportItem struct {
+	Line      int
+	Parent    int
+	Completed bool
+	NewTask
+}
+
+// ImportSummary is the response of POST /import/markdown
+type ImportSummary struct {
+	Created      int    `json:"created"`
+	Completed    int    `json:"completed"`
+	Subtasks     int    `json:"subtasks"`
+	SkippedLines []int  `json:"skipped_lines"`
+	Tasks        []Task `json:"tasks"`
+}
+
+// ImportError reports a checklist item that could not be imported
+type ImportError struct {
+	Line int
+	Err  error
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ImportError) Unwrap() error {
+	return e.Err
+}
+
+// ParseMarkdownChecklist turns checklist items into import items. An item indented
+// under another becomes its subtask. Indented lines that are not checklist items become
+// the description of the item above them, which is how the markdown export writes
+// descriptions. Any other non-blank line is skipped and its number reported.
+func ParseMarkdownChecklist(r io.Reader) ([]ImportItem, []int, error) {
+	type open struct {
+		indent int
+		index  int
+	}
+
+	var items []ImportItem
+	var skipped []int
+	var stack []open
+	var description []string
+
+	flushDescription := func() {
+		if len(items) > 0 && len(description) > 0 {
+			items[len(items)-1].Description = strings.TrimSpace(strings.Join(description, "\n"))
+		}
+		description = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxImportBytes)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+
+		if m := checklistItemPattern.FindStringSubmatch(text); m != nil {
+			flushDescription()
+			indent := indentWidth(m[1])
+			for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+				stack = stack[:len(stack)-1]
+			}
+
+			item := ImportItem{Line: line, Parent: -1, Completed: m[2] != " "}
+			if len(stack) > 0 {
+				item.Parent = stack[len(stack)-1].index
+			}
+			title := m[3]
+			if due := dueSuffixPattern.FindStringSubmatch(title); due != nil {
+				if t, err := time.Parse("2006-01-02", due[1]); err == nil {
+					item.DueAt = &t
+					title = strings.TrimSuffix(title, due[0])
+				}
+			}
+			item.Title = title
+
+			items = append(items, item)
+			stack = append(stack, open{indent: indent, index: len(items) - 1})
+			continue
+		}
+
+		if text == "" {
+			if description != nil {
+				description = append(description, "")
+			}
+			continue
+		}
+
+		// Lines indented past the current item's marker belong to its description
+		if len(stack) > 0 && indentWidth(text[:len(text)-len(strings.TrimLeft(text, " \t"))]) > stack[len(stack)-1].indent {
+			description = append(description, dedent(text, stack[len(stack)-1].indent+2))
+			continue
+		}
+
+		flushDescription()
+		stack = nil
+		skipped = append(skipped, line)
+	}
+	flushDescription()
+
+	return items, skipped, scanner.Err()
+}
+
+// indentWidth measures leading whitespace, counting a tab as four spaces
+func indentWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		if r == '\t' {
+			width += 4
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+// dedent removes up to width columns of leading whitespace
+func dedent(s string, width int) string {
+	removed := 0
+	for i, r := range s {
+		if removed >= width || (r != ' ' && r != '\t') {
+			return s[i:]
+		}
+		removed += indentWidth(string(r))
+	}
+	return ""
+}
+
+// ImportTasks creates every item in one transaction, so a failed import leaves nothing behind
+func (db *DB) ImportTasks(ctx context.Context, items []ImportItem) ([]Task, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.ImportTasks",
+		trace.WithAttributes(
+			attribute.String("db.operation", "import_tasks"),
+			attribute.Int("import.items", len(items)),
+		))
+	defer span.End()
+
+	tasks := make([]Task, len(items))
+	err := db.WithTx(ctx, "import_tasks", func(ctx context.Context, tx *sql.Tx) error {
+		// Insert in reverse so the first item ends up on top of the manual order
+		for i := len(items) - 1; i >= 0; i-- {
+			item := items[i]
+			task, err := db.insertTask(ctx, tx, item.NewTask)
+			if err == nil && item.Completed {
+				task, err = db.completeTask(ctx, tx, task.ID)
+			}
+			if err != nil {
+				return &ImportError{Line: item.Line, Err: err}
+			}
+			tasks[i] = *task
+		}
+
+		// Parents are known only once every task has an ID
+		for i, item := range items {
+			if item.Parent < 0 {
+				continue
+			}
+			parentID := tasks[item.Parent].ID
+			if _, err := tx.ExecContext(ctx, `UPDATE tasks SET parent_id = ? WHERE id = ?`, parentID, tasks[i].ID); err != nil {
+				return &ImportError{Line: item.Line, Err: err}
+			}
+			tasks[i].ParentID = &parentID
+		}
+		return nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return tasks, nil
+}
@@ -0,0 +1,308 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Task link types. relates_to goes both ways; a task that duplicates another is
+// duplicated_by it from the other side.
+const (
+	LinkRelatesTo    = "relates_to"
+	LinkDuplicates   = "duplicates"
+	LinkDuplicatedBy = "duplicated_by"
+)
+
+var (
+	// ErrInvalidLinkType is returned for a link type other than the Link constants
+	ErrInvalidLinkType = errors.New("invalid link type")
+	// ErrSelfLink is returned when a task is linked to itself
+	ErrSelfLink = errors.New("a task cannot be linked to itself")
+	// ErrLinkedTaskNotFound is returned when the linked task does not exist or is in the trash
+	ErrLinkedTaskNotFound = errors.New("linked task not found")
+)
+
+// TaskLink is a link from a task to another, as seen from the task
+type TaskLink struct {
+	Type   string `json:"type"`
+	TaskID int    `json:"task_id"`
+}
+
+// TaskLinks is the response of GET /tasks/{id}/links
+type TaskLinks struct {
+	RelatesTo    []Task `json:"relates_to"`
+	Duplicates   []Task `json:"duplicates"`
+	DuplicatedBy []Task `json:"duplicated_by"`
+}
+
+// storedLink returns the row a link from id to linkedID of type linkType is stored as.
+// duplicated_by is stored as the other task's duplicates, and relates_to with the lower ID
+// first, so either side finds the same row.
+func storedLink(id, linkedID int, linkType string) (taskID, linked int, stored string, err error) {
+	switch linkType {
+	case LinkRelatesTo:
+		if id > linkedID {
+			id, linkedID = linkedID, id
+		}
+		return id, linkedID, LinkRelatesTo, nil
+	case LinkDuplicates:
+		return id, linkedID, LinkDuplicates, nil
+	case LinkDuplicatedBy:
+		return linkedID, id, LinkDuplicates, nil
+	}
+	return 0, 0, "", ErrInvalidLinkType
+}
+
+// attachRelations fills in the dependencies and links of tasks
+func (db *DB) attachRelations(ctx context.Context, q queryer, tasks []Task) error {
+	if err := db.attachDependencies(ctx, q, tasks); err != nil {
+		return err
+	}
+	return db.attachLinks(ctx, q, tasks)
+}
+
+// attachLinks fills in Links, from both sides of each link. Linked tasks in the trash are
+// ignored.
+func (db *DB) attachLinks(ctx context.Context, q queryer, tasks []Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	// Each row is a link seen from its first column: as stored, and reversed for the
+	// linked task
+	query := `SELECT l.task_id, l.linked_id, l.type FROM task_links l
+	JOIN tasks t ON t.id = l.linked_id
+	WHERE t.deleted_at IS NULL`
+	reversed := `SELECT l.linked_id, l.task_id, CASE WHEN l.type = '` + LinkDuplicates + `' THEN '` + LinkDuplicatedBy + `' ELSE l.type END
+	FROM task_links l
+	JOIN tasks t ON t.id = l.task_id
+	WHERE t.deleted_at IS NULL`
+	var args []any
+	if len(tasks) == 1 {
+		query += ` AND l.task_id = ?`
+		reversed += ` AND l.linked_id = ?`
+		args = append(args, tasks[0].ID, tasks[0].ID)
+	}
+	query = `SELECT * FROM (` + query + ` UNION ALL ` + reversed + `) links ORDER BY 1, 2, 3`
+
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args...)
+	db.checkSlowQuery(ctx, start, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	index := make(map[int]int, len(tasks))
+	for i := range tasks {
+		index[tasks[i].ID] = i
+	}
+	for rows.Next() {
+		var taskID int
+		var link TaskLink
+		if err := rows.Scan(&taskID, &link.TaskID, &link.Type); err != nil {
+			return err
+		}
+		if i, ok := index[taskID]; ok {
+			tasks[i].Links = append(tasks[i].Links, link)
+		}
+	}
+	return rows.Err()
+}
+
+// GetTaskLinks returns the active tasks linked to id, by link type
+func (db *DB) GetTaskLinks(ctx context.Context, id int) (*TaskLinks, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetTaskLinks",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_task_links"),
+			attribute.Int("task.id", id),
+		))
+	defer span.End()
+
+	if _, err := db.GetTask(ctx, id); err != nil {
+		return nil, err
+	}
+
+	links := &TaskLinks{}
+	var err error
+	links.RelatesTo, err = db.queryDependentTasks(ctx, `SELECT `+prefixedTaskColumns("t")+` FROM task_links l
+	JOIN tasks t ON t.id = CASE WHEN l.task_id = ? THEN l.linked_id ELSE l.task_id END
+	WHERE l.type = ? AND (l.task_id = ? OR l.linked_id = ?) AND t.deleted_at IS NULL ORDER BY t.id`,
+		id, LinkRelatesTo, id, id)
+	if err != nil {
+		return nil, err
+	}
+	links.Duplicates, err = db.queryDependentTasks(ctx, `SELECT `+prefixedTaskColumns("t")+` FROM task_links l
+	JOIN tasks t ON t.id = l.linked_id
+	WHERE l.type = ? AND l.task_id = ? AND t.deleted_at IS NULL ORDER BY t.id`, LinkDuplicates, id)
+	if err != nil {
+		return nil, err
+	}
+	links.DuplicatedBy, err = db.queryDependentTasks(ctx, `SELECT `+prefixedTaskColumns("t")+` FROM task_links l
+	JOIN tasks t ON t.id = l.task_id
+	WHERE l.type = ? AND l.linked_id = ? AND t.deleted_at IS NULL ORDER BY t.id`, LinkDuplicates, id)
+	if err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// AddTaskLink links id to linkedID and returns the task with its updated links. Adding an
+// existing link is a no-op.
+func (db *DB) AddTaskLink(ctx context.Context, id, linkedID int, linkType string) (*Task, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.AddTaskLink",
+		trace.WithAttributes(
+			attribute.String("db.operation", "insert_task_link"),
+			attribute.Int("task.id", id),
+			attribute.Int("task.linked_id", linkedID),
+			attribute.String("task.link_type", linkType),
+		))
+	defer span.End()
+
+	taskID, linked, stored, err := storedLink(id, linkedID, linkType)
+	if err != nil {
+		return nil, err
+	}
+	if id == linkedID {
+		return nil, ErrSelfLink
+	}
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var active int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM tasks WHERE id = ? AND deleted_at IS NULL`, id).Scan(&active)
+	if err == nil && active == 0 {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM tasks WHERE id = ? AND deleted_at IS NULL`, linkedID).Scan(&active)
+	if err == nil && active == 0 {
+		err = ErrLinkedTaskNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+	INSERT INTO task_links (task_id, linked_id, type, created_at) VALUES (?, ?, ?, ?)
+	ON CONFLICT (task_id, linked_id, type) DO NOTHING`, taskID, linked, stored, time.Now().UTC())
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	if added, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if added > 0 {
+		// Both tasks list the link, so both change
+		if err := db.recordLinkEvents(ctx, tx, id, linkedID, linkType, TaskEventLinkAdded); err != nil {
+			return nil, err
+		}
+	}
+
+	task, err := scanTask(tx.QueryRowContext(ctx, `SELECT `+taskColumns+` FROM tasks WHERE id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	tasks := []Task{*task}
+	if err := db.attachRelations(ctx, tx, tasks); err != nil {
+		return nil, err
+	}
+
+	return &tasks[0], tx.Commit()
+}
+
+// RemoveTaskLink deletes the links between id and linkedID, only those of linkType unless
+// it is empty; sql.ErrNoRows if there are none
+func (db *DB) RemoveTaskLink(ctx context.Context, id, linkedID int, linkType string) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.RemoveTaskLink",
+		trace.WithAttributes(
+			attribute.String("db.operation", "delete_task_link"),
+			attribute.Int("task.id", id),
+			attribute.Int("task.linked_id", linkedID),
+			attribute.String("task.link_type", linkType),
+		))
+	defer span.End()
+
+	types := []string{LinkRelatesTo, LinkDuplicates, LinkDuplicatedBy}
+	if linkType != "" {
+		if _, _, _, err := storedLink(id, linkedID, linkType); err != nil {
+			return err
+		}
+		types = []string{linkType}
+	}
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	removed := 0
+	for _, t := range types {
+		taskID, linked, stored, _ := storedLink(id, linkedID, t)
+		result, err := tx.ExecContext(ctx, `DELETE FROM task_links WHERE task_id = ? AND linked_id = ? AND type = ?`, taskID, linked, stored)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			removed++
+			if err := db.recordLinkEvents(ctx, tx, id, linkedID, t, TaskEventLinkRemoved); err != nil {
+				return err
+			}
+		}
+	}
+	if removed == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// recordLinkEvents records a link change in the history of both tasks, each with the link
+// as seen from it
+func (db *DB) recordLinkEvents(ctx context.Context, q queryer, id, linkedID int, linkType, event string) error {
+	inverse := linkType
+	switch linkType {
+	case LinkDuplicates:
+		inverse = LinkDuplicatedBy
+	case LinkDuplicatedBy:
+		inverse = LinkDuplicates
+	}
+	for _, side := range []struct {
+		id   int
+		link TaskLink
+	}{
+		{id, TaskLink{Type: linkType, TaskID: linkedID}},
+		{linkedID, TaskLink{Type: inverse, TaskID: id}},
+	} {
+		change := FieldChange{To: side.link}
+		if event == TaskEventLinkRemoved {
+			change = FieldChange{From: side.link}
+		}
+		if err := touchTask(ctx, q, side.id); err != nil {
+			return err
+		}
+		if err := db.recordTaskEvent(ctx, q, side.id, event, map[string]FieldChange{"links": change}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
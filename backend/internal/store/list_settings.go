@@ -0,0 +1,156 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxReminderOffset caps how long before its due date a list's tasks are reminded about
+const maxReminderOffset = 30 * 24 * time.Hour
+
+// ListSettings are the defaults a list applies to the tasks created in it, on top of its
+// workspace's. DefaultTags are added to the tags a task is created with. With
+// ReminderOffsetSeconds, a task created in the list is reminded about that long before it is
+// due instead of TODO_REMINDER_LEAD, and keeps that offset when its due date changes.
+type ListSettings struct {
+	ListID                int       `json:"list_id"`
+	DefaultTags           Tags      `json:"default_tags"`
+	ReminderOffsetSeconds *int      `json:"reminder_offset_seconds"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+// Validate checks the settings and normalizes the default tags
+func (s *ListSettings) Validate() error {
+	tags, err := validateTaskDefaults(s.DefaultTags, s.ReminderOffsetSeconds)
+	s.DefaultTags = tags
+	return err
+}
+
+// validateTaskDefaults checks the defaults a list or workspace applies to new tasks and
+// returns the default tags normalized
+func validateTaskDefaults(tags Tags, reminderOffset *int) (Tags, error) {
+	tags, err := NormalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if reminderOffset != nil {
+		if offset := *reminderOffset; offset < 0 || time.Duration(offset)*time.Second > maxReminderOffset {
+			return nil, fmt.Errorf("reminder_offset_seconds must be between 0 and %d", int(maxReminderOffset.Seconds()))
+		}
+	}
+	return tags, nil
+}
+
+// GetListSettings returns a list's settings, or empty ones if none were saved. It returns
+// sql.ErrNoRows if the list does not exist.
+func (db *DB) GetListSettings(ctx context.Context, listID int) (*ListSettings, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetListSettings",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_list_settings"),
+			attribute.Int("list.id", listID),
+		))
+	defer span.End()
+
+	if _, err := db.resolveListID(ctx, db.conn, &listID); err != nil {
+		if err == ErrListNotFound {
+			err = sql.ErrNoRows
+		}
+		return nil, err
+	}
+	s := &ListSettings{ListID: listID, DefaultTags: Tags{}}
+	err := db.conn.QueryRowContext(ctx, `SELECT default_tags, reminder_offset, updated_at FROM list_settings WHERE list_id = ?`, listID).
+		Scan(&s.DefaultTags, &s.ReminderOffsetSeconds, &s.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SaveListSettings replaces a list's settings. It returns sql.ErrNoRows if the list does not
+// exist.
+func (db *DB) SaveListSettings(ctx context.Context, s *ListSettings) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.SaveListSettings",
+		trace.WithAttributes(
+			attribute.String("db.operation", "upsert_list_settings"),
+			attribute.Int("list.id", s.ListID),
+		))
+	defer span.End()
+
+	if _, err := db.resolveListID(ctx, db.conn, &s.ListID); err != nil {
+		if err == ErrListNotFound {
+			err = sql.ErrNoRows
+		}
+		return err
+	}
+	s.UpdatedAt = time.Now().UTC()
+	_, err := db.conn.ExecContext(ctx, `
+	INSERT INTO list_settings (list_id, default_tags, reminder_offset, updated_at) VALUES (?, ?, ?, ?)
+	ON CONFLICT (list_id) DO UPDATE SET
+		default_tags = excluded.default_tags,
+		reminder_offset = excluded.reminder_offset,
+		updated_at = excluded.updated_at`,
+		s.ListID, s.DefaultTags, s.ReminderOffsetSeconds, s.UpdatedAt)
+	return err
+}
+
+// taskDefaults returns what a task created in a list starts with: the default tags of the
+// list and its workspace, and the list's reminder offset, or else the workspace's
+func (db *DB) taskDefaults(ctx context.Context, q queryer, listID int) (*ListSettings, error) {
+	var workspaceTags Tags
+	var workspaceOffset *int
+	s := &ListSettings{ListID: listID}
+	err := q.QueryRowContext(ctx, `SELECT list_settings.default_tags, list_settings.reminder_offset,
+		workspace_settings.default_tags, workspace_settings.reminder_offset
+	FROM lists
+	LEFT JOIN list_settings ON list_settings.list_id = lists.id
+	LEFT JOIN workspace_settings ON workspace_settings.workspace_id = lists.workspace_id
+	WHERE lists.id = ?`, listID).Scan(&s.DefaultTags, &s.ReminderOffsetSeconds, &workspaceTags, &workspaceOffset)
+	if err != nil {
+		return nil, err
+	}
+	s.DefaultTags = append(s.DefaultTags, workspaceTags...)
+	if s.ReminderOffsetSeconds == nil {
+		s.ReminderOffsetSeconds = workspaceOffset
+	}
+	return s, nil
+}
+
+// withDefaultTags adds the default tags to those a task is created with, as many as
+// fit under MaxTagsPerTask; the task's own tags always stay
+func withDefaultTags(tags, defaults Tags) Tags {
+	merged := append(Tags{}, tags...)
+	for _, tag := range defaults {
+		if len(merged) >= MaxTagsPerTask {
+			break
+		}
+		if !merged.Has(tag) {
+			merged = append(merged, tag)
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// remindAt is when a task due at due with a reminder offset is reminded about, nil when it
+// is reminded TODO_REMINDER_LEAD before it is due
+func remindAt(due *time.Time, offset *int) any {
+	if due == nil || offset == nil {
+		return nil
+	}
+	return due.Add(-time.Duration(*offset) * time.Second).UTC()
+}
+
+// taskReminderOffset returns the reminder offset a task was created with, if any
+func taskReminderOffset(ctx context.Context, q queryer, id int) (*int, error) {
+	var offset *int
+	err := q.QueryRowContext(ctx, `SELECT reminder_offset FROM tasks WHERE id = ?`, id).Scan(&offset)
+	return offset, err
+}
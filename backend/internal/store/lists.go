@@ -0,0 +1,282 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultListName is the name of the list createTables sets up on first run
+const DefaultListName = "Inbox"
+
+var (
+	// ErrListNameTaken is returned when a list with the same name already exists
+	ErrListNameTaken = errors.New("list name already exists")
+	// ErrListNotFound is returned when a task refers to a list that does not exist
+	ErrListNotFound = errors.New("list not found")
+	// ErrDefaultList is returned when trying to delete the default list
+	ErrDefaultList = errors.New("the default list cannot be deleted")
+)
+
+// List groups tasks, e.g. per project. Every task belongs to exactly one list, and every
+// list to one workspace.
+type List struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	IsDefault   bool      `json:"is_default"`
+	WorkspaceID int       `json:"workspace_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	TaskCount   int       `json:"task_count"`
+}
+
+// listColumns selects a list with the number of active tasks in it
+const listColumns = `lists.id, lists.name, lists.is_default, lists.workspace_id, lists.created_at,
+	(SELECT COUNT(*) FROM tasks WHERE tasks.list_id = lists.id AND tasks.deleted_at IS NULL)`
+
+func scanList(row rowScanner) (*List, error) {
+	list := &List{}
+	if err := row.Scan(&list.ID, &list.Name, &list.IsDefault, &list.WorkspaceID, &list.CreatedAt, &list.TaskCount); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// createDefaultList makes sure there is a default list, so tasks created without a
+// list_id always have somewhere to go
+func (db *DB) createDefaultList(ctx context.Context) error {
+	_, err := db.conn.ExecContext(ctx, `
+	INSERT INTO lists (name, is_default)
+	SELECT ?, TRUE WHERE NOT EXISTS (SELECT 1 FROM lists WHERE is_default = TRUE)`, DefaultListName)
+	return err
+}
+
+// resolveListID returns listID if that list exists, or the default list when listID is nil
+func (db *DB) resolveListID(ctx context.Context, q queryer, listID *int) (int, error) {
+	var id int
+	var err error
+	if listID == nil {
+		err = q.QueryRowContext(ctx, `SELECT id FROM lists WHERE is_default = TRUE`).Scan(&id)
+	} else {
+		err = q.QueryRowContext(ctx, `SELECT id FROM lists WHERE id = ?`, *listID).Scan(&id)
+		if err == sql.ErrNoRows {
+			err = ErrListNotFound
+		}
+	}
+	return id, err
+}
+
+func (db *DB) GetLists(ctx context.Context) ([]List, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetLists",
+		trace.WithAttributes(attribute.String("db.operation", "select_lists")))
+	defer span.End()
+
+	query := `SELECT ` + listColumns + ` FROM lists ORDER BY is_default DESC, name`
+	start := time.Now()
+	rows, err := db.conn.QueryContext(ctx, query)
+	db.checkSlowQuery(ctx, start, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []List{}
+	for rows.Next() {
+		list, err := scanList(rows)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, *list)
+	}
+
+	return lists, rows.Err()
+}
+
+func (db *DB) GetList(ctx context.Context, id int) (*List, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetList",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_list"),
+			attribute.Int("list.id", id),
+		))
+	defer span.End()
+
+	return scanList(db.conn.QueryRowContext(ctx, `SELECT `+listColumns+` FROM lists WHERE id = ?`, id))
+}
+
+// CreateList creates a list in a workspace, the default one when workspaceID is nil. It
+// returns ErrWorkspaceNotFound if that workspace does not exist.
+func (db *DB) CreateList(ctx context.Context, name string, workspaceID *int) (*List, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.CreateList",
+		trace.WithAttributes(
+			attribute.String("db.operation", "insert_list"),
+			attribute.String("list.name", name),
+		))
+	defer span.End()
+
+	workspace, err := db.resolveWorkspaceID(ctx, db.conn, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("workspace.id", workspace))
+
+	if taken, err := db.listNameTaken(ctx, name, 0); err != nil || taken {
+		if taken {
+			err = ErrListNameTaken
+		}
+		return nil, err
+	}
+
+	var id int
+	err = db.queryReturning(ctx, db.conn, "lists", 0, `INSERT INTO lists (name, workspace_id, created_at) VALUES (?, ?, ?) RETURNING id`,
+		name, workspace, time.Now().UTC()).Scan(&id)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	return db.GetList(ctx, id)
+}
+
+func (db *DB) RenameList(ctx context.Context, id int, name string) (*List, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.RenameList",
+		trace.WithAttributes(
+			attribute.String("db.operation", "update_list"),
+			attribute.Int("list.id", id),
+			attribute.String("list.name", name),
+		))
+	defer span.End()
+
+	if taken, err := db.listNameTaken(ctx, name, id); err != nil || taken {
+		if taken {
+			err = ErrListNameTaken
+		}
+		return nil, err
+	}
+
+	result, err := db.conn.ExecContext(ctx, `UPDATE lists SET name = ? WHERE id = ?`, name, id)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	return db.GetList(ctx, id)
+}
+
+// listNameTaken reports whether another list than exceptID is called name
+func (db *DB) listNameTaken(ctx context.Context, name string, exceptID int) (bool, error) {
+	var count int
+	err := db.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM lists WHERE name = ? AND id <> ?`, name, exceptID).Scan(&count)
+	return count > 0, err
+}
+
+// DeleteList removes a list with its notification rules and settings, and moves its tasks,
+// including trashed ones, to the default list
+func (db *DB) DeleteList(ctx context.Context, id int) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.DeleteList",
+		trace.WithAttributes(
+			attribute.String("db.operation", "delete_list"),
+			attribute.Int("list.id", id),
+		))
+	defer span.End()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var isDefault bool
+	if err := tx.QueryRowContext(ctx, `SELECT is_default FROM lists WHERE id = ?`, id).Scan(&isDefault); err != nil {
+		return err
+	}
+	if isDefault {
+		return ErrDefaultList
+	}
+
+	defaultID, err := db.resolveListID(ctx, tx, nil)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(map[string]FieldChange{"list_id": {From: id, To: defaultID}})
+	if err != nil {
+		return err
+	}
+	changes, err := SealField(fieldTaskEventChanges, string(data))
+	if err != nil {
+		return err
+	}
+	actor, traceID, spanID := eventContext(ctx)
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO task_events (task_id, event, changes, actor, trace_id, span_id, created_at)
+	SELECT id, ?, ?, ?, ?, ?, ? FROM tasks WHERE list_id = ?`,
+		TaskEventUpdated, changes, actor, traceID, spanID, time.Now().UTC(), id)
+	if err == nil {
+		err = db.logTaskChangesWhere(ctx, tx, `list_id = ?`, id)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	result, err := tx.ExecContext(ctx, `UPDATE tasks SET list_id = ? WHERE list_id = ?`, defaultID, id)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	if moved, err := result.RowsAffected(); err == nil {
+		span.SetAttributes(attribute.Int64("list.moved_tasks", moved))
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM notification_rules WHERE list_id = ?`, id); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM list_settings WHERE list_id = ?`, id); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM lists WHERE id = ?`, id); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DecodeListName reads {"name": ...} from the request body and normalizes it like a
+// task title. It writes a 400 response and returns false if the name is unusable.
+func DecodeListName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return "", false
+	}
+	name, err := NormalizeTitle(req.Name)
+	if err != nil {
+		http.Error(w, "List name is required and must be at most "+strconv.Itoa(maxTitleLength)+" characters", http.StatusBadRequest)
+		return "", false
+	}
+	return name, true
+}
@@ -0,0 +1,320 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Store kinds, chosen with TODO_STORE
+const (
+	// StoreSQL keeps tasks in the database at TODO_DATABASE_URL
+	StoreSQL = "sql"
+	// StoreMemory keeps tasks in process memory; they are lost on exit
+	StoreMemory = "memory"
+)
+
+// MemoryStore is a TaskStore that keeps tasks in memory, for tests and demos that should not
+// need a database. It is safe for concurrent use. Only the task lifecycle lives here: the
+// features that use *DB directly (lists, trash, claims, dependencies, history, ...) do not
+// see these tasks, so a task is never claimed or blocked and every list ID is accepted.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	ids    IDGenerator
+	tasks  map[int]*Task
+	byUUID map[string]int
+	nextID int
+	seq    int64
+}
+
+var _ TaskStore = (*MemoryStore)(nil)
+
+// MemoryDatabaseURL is an SQLite database in process memory, for the features that need *DB
+// when the tasks are in a MemoryStore, so that nothing is written to disk
+const MemoryDatabaseURL = "file:todo?mode=memory&cache=shared"
+
+// memoryDefaultListID is the list of tasks created without one, the ID the default list
+// gets in a new database
+const memoryDefaultListID = 1
+
+// NewMemoryStore returns an empty store whose tasks get identifiers by TODO_ID_STRATEGY
+func NewMemoryStore() (*MemoryStore, error) {
+	ids, err := idGeneratorFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return &MemoryStore{
+		ids:    ids,
+		tasks:  make(map[int]*Task),
+		byUUID: make(map[string]int),
+		nextID: 1,
+	}, nil
+}
+
+// copyTask returns a copy of task that shares nothing mutable with it
+func copyTask(task *Task) Task {
+	c := *task
+	c.Tags = slices.Clone(task.Tags)
+	c.setAge(time.Now())
+	return c
+}
+
+func (m *MemoryStore) GetAllTasks(ctx context.Context, q TaskQuery) ([]Task, error) {
+	m.mu.RLock()
+	var tasks []Task
+	for _, task := range m.tasks {
+		if task.DeletedAt != nil || (q.ListID != nil && task.ListID != *q.ListID) {
+			continue
+		}
+		if c := copyTask(task); !q.Stale || c.Stale {
+			tasks = append(tasks, c)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(tasks, func(i, j int) bool {
+		a, b := tasks[i], tasks[j]
+		if q.Sort == SortPosition {
+			if a.Position != b.Position {
+				return a.Position < b.Position
+			}
+			return a.ID < b.ID
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID > b.ID
+	})
+	if q.Search != "" {
+		tasks = filterTasksBySearch(tasks, q.Search)
+	}
+	if q.Tag != "" {
+		tasks = filterTasksByTag(tasks, q.Tag)
+	}
+	if q.Sort == SortTitle {
+		sortTasksByTitle(tasks, q.Locale)
+	}
+	return q.page(tasks), nil
+}
+
+func (m *MemoryStore) GetTask(ctx context.Context, id int) (*Task, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	task, ok := m.tasks[id]
+	if !ok || task.DeletedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+	c := copyTask(task)
+	return &c, nil
+}
+
+// TaskIDByUUID returns the numeric ID of the task with the given UUID, including deleted
+// tasks, which are kept for that. Tasks are never merged or purged here.
+func (m *MemoryStore) TaskIDByUUID(ctx context.Context, taskUUID string) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.byUUID[taskUUID]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return id, nil
+}
+
+func (m *MemoryStore) CreateTask(ctx context.Context, input NewTask) (*Task, error) {
+	// The same demonstration error as the database, so the demo behaves alike on both
+	if input.Title == "errorTest" {
+		err := fmt.Errorf("simulated database error: cannot create task with title 'errorTest'")
+		span := trace.SpanFromContext(ctx)
+		span.RecordError(err, trace.WithStackTrace(true))
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.ids.TaskID()
+	if id == 0 {
+		id = m.nextID
+	}
+	if _, taken := m.tasks[id]; taken {
+		return nil, fmt.Errorf("task ID %d is already in use", id)
+	}
+	m.nextID = max(m.nextID, id+1)
+
+	// New tasks go to the top of the manual order, as in the database. Without workspaces,
+	// every open task counts towards the one workspace's quota.
+	top, open := 0, 0
+	for _, task := range m.tasks {
+		if task.DeletedAt == nil {
+			top = min(top, task.Position)
+			if !task.Completed {
+				open++
+			}
+		}
+	}
+	if MaxOpenTasksPerWorkspace > 0 && open >= MaxOpenTasksPerWorkspace {
+		return nil, quotaExceeded(ctx, QuotaOpenTasks, MaxOpenTasksPerWorkspace)
+	}
+	listID := memoryDefaultListID
+	if input.ListID != nil {
+		listID = *input.ListID
+	}
+	task := &Task{
+		ID:          id,
+		UUID:        m.ids.TaskUUID(),
+		Title:       input.Title,
+		Description: input.Description,
+		CreatedAt:   time.Now().UTC().Truncate(time.Second),
+		UpdatedAt:   time.Now().UTC(),
+		DueAt:       utcPointer(input.DueAt),
+		Position:    top - 1,
+		ListID:      listID,
+		Tags:        slices.Clone(input.Tags),
+	}
+	if task.Tags == nil {
+		task.Tags = Tags{}
+	}
+	m.tasks[id] = task
+	m.byUUID[task.UUID] = id
+	m.seq++
+
+	c := copyTask(task)
+	return &c, nil
+}
+
+// UpdateTask applies the non-nil fields of update and returns the updated task
+func (m *MemoryStore) UpdateTask(ctx context.Context, id int, update TaskUpdate) (*Task, error) {
+	if update.Title == nil && update.Description == nil && update.Completed == nil &&
+		!update.DueAt.Set && update.Tags == nil && update.ListID == nil {
+		return nil, fmt.Errorf("no fields to update")
+	}
+
+	return m.update(id, func(task *Task) error {
+		if update.IfUpdatedAt != nil && !task.UpdatedAt.Equal(*update.IfUpdatedAt) {
+			return ErrTaskModified
+		}
+		if update.Title != nil {
+			task.Title = *update.Title
+		}
+		if update.Description != nil {
+			task.Description = *update.Description
+		}
+		if update.Completed != nil {
+			setCompleted(task, *update.Completed)
+		}
+		if update.DueAt.Set {
+			task.DueAt = utcPointer(update.DueAt.Value)
+		}
+		if update.Tags != nil {
+			task.Tags = slices.Clone(*update.Tags)
+		}
+		if update.ListID != nil {
+			task.ListID = *update.ListID
+		}
+		return nil
+	})
+}
+
+func (m *MemoryStore) CompleteTask(ctx context.Context, id int) (*Task, error) {
+	return m.update(id, func(task *Task) error {
+		setCompleted(task, true)
+		return nil
+	})
+}
+
+func (m *MemoryStore) UncompleteTask(ctx context.Context, id int) (*Task, error) {
+	return m.update(id, func(task *Task) error {
+		setCompleted(task, false)
+		return nil
+	})
+}
+
+// DeleteTask marks a task deleted. It is kept so its UUID still resolves, as a task in the
+// database's trash does.
+func (m *MemoryStore) DeleteTask(ctx context.Context, id int) error {
+	_, err := m.update(id, func(task *Task) error {
+		now := time.Now().UTC()
+		task.DeletedAt = &now
+		return nil
+	})
+	return err
+}
+
+// update applies change to a task not deleted, unless it fails, and returns a copy of the
+// result
+func (m *MemoryStore) update(id int, change func(task *Task) error) (*Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	task, ok := m.tasks[id]
+	if !ok || task.DeletedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+	if err := change(task); err != nil {
+		return nil, err
+	}
+	task.UpdatedAt = time.Now().UTC()
+	m.seq++
+
+	c := copyTask(task)
+	return &c, nil
+}
+
+// utcPointer returns t in UTC, as the database returns timestamps
+func utcPointer(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+// setCompleted marks a task complete, keeping the first completion time, or reopens it
+func setCompleted(task *Task, completed bool) {
+	task.Completed = completed
+	if !completed {
+		task.CompletedAt = nil
+	} else if task.CompletedAt == nil {
+		now := time.Now().UTC()
+		task.CompletedAt = &now
+	}
+}
+
+// ChangeSeq returns the number of changes made to the tasks so far
+func (m *MemoryStore) ChangeSeq(ctx context.Context) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.seq, nil
+}
+
+// Reset forgets every task, as a demo reset does, and returns the IDs of those not already in
+// the trash. The change sequence keeps growing, so cached lists are invalidated.
+func (m *MemoryStore) Reset() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []int
+	for id, task := range m.tasks {
+		if task.DeletedAt == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	m.tasks = make(map[int]*Task)
+	m.byUUID = make(map[string]int)
+	m.seq++
+	return ids
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,18 @@
+package store_test
+
+import (
+	"testing"
+
+	"todo-app/internal/store"
+	"todo-app/internal/store/storetest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storetest.TestStore(t, func(t *testing.T) store.TaskStore {
+		s, err := store.NewMemoryStore()
+		if err != nil {
+			t.Fatalf("NewMemoryStore: %v", err)
+		}
+		return s
+	})
+}
@@ -0,0 +1,263 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MaxMergeTasks bounds the tasks merged into another in one request
+const MaxMergeTasks = 100
+
+// ErrMergeIntoSubtask is returned when a merged task is an ancestor of the surviving task,
+// which would make the survivor its own ancestor once the subtasks move over
+var ErrMergeIntoSubtask = errors.New("cannot merge a task into one of its subtasks")
+
+// ErrMergeTags is returned when the union of the merged tasks' tags is not a valid tag set
+var ErrMergeTags = errors.New("cannot merge tags")
+
+// TaskMergedError is returned for a task that was merged into another
+type TaskMergedError struct {
+	Into int
+}
+
+func (e *TaskMergedError) Error() string {
+	return fmt.Sprintf("task was merged into task %d", e.Into)
+}
+
+// MergedInto returns the task id was merged into, sql.ErrNoRows if it was not merged
+func (db *DB) MergedInto(ctx context.Context, id int) (int, error) {
+	var into int
+	err := db.conn.QueryRowContext(ctx, `SELECT merged_into FROM task_tombstones WHERE task_id = ? AND merged_into IS NOT NULL`, id).Scan(&into)
+	return into, err
+}
+
+// MergeTasks merges duplicate tasks into the task into, which survives with the union of
+// their tags, the earliest created_at and, if it has none, the earliest due date. Their
+// subtasks, dependencies and hook links move to the survivor. The merged tasks are removed
+// at once, without going through the trash, and leave tombstones pointing at the survivor,
+// as do tasks merged into them before.
+func (db *DB) MergeTasks(ctx context.Context, into int, ids []int) (*Task, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.MergeTasks",
+		trace.WithAttributes(
+			attribute.String("db.operation", "merge_tasks"),
+			attribute.Int("task.id", into),
+			attribute.IntSlice("merge.task_ids", ids),
+		))
+	defer span.End()
+
+	var survivor *Task
+	err := db.WithTx(ctx, "merge_tasks", func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		survivor, err = db.mergeTasks(ctx, tx, into, ids)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return survivor, nil
+}
+
+func (db *DB) mergeTasks(ctx context.Context, tx *sql.Tx, into int, ids []int) (*Task, error) {
+	before, err := scanTask(tx.QueryRowContext(ctx, `SELECT `+taskColumns+` FROM tasks WHERE id = ? AND deleted_at IS NULL`, into))
+	if err != nil {
+		return nil, err
+	}
+	if err := db.checkClaim(ctx, tx, into); err != nil {
+		return nil, err
+	}
+
+	tags := append(Tags{}, before.Tags...)
+	createdAt, dueAt := before.CreatedAt, before.DueAt
+	merged := make([]*Task, len(ids))
+	for i, id := range ids {
+		task, err := scanTask(tx.QueryRowContext(ctx, `SELECT `+taskColumns+` FROM tasks WHERE id = ? AND deleted_at IS NULL`, id))
+		if err != nil {
+			return nil, err
+		}
+		if err := db.checkClaim(ctx, tx, id); err != nil {
+			return nil, err
+		}
+		merged[i] = task
+		tags = append(tags, task.Tags...)
+		if task.CreatedAt.Before(createdAt) {
+			createdAt = task.CreatedAt
+		}
+		if before.DueAt == nil && task.DueAt != nil && (dueAt == nil || task.DueAt.Before(*dueAt)) {
+			dueAt = task.DueAt
+		}
+	}
+	if tags, err = NormalizeTags(tags); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMergeTags, err)
+	}
+
+	// The merged tasks, and with the survivor, as JSON arrays bound to inMerged and inAll
+	mergedJSON, _ := json.Marshal(ids)
+	allJSON, _ := json.Marshal(append([]int{into}, ids...))
+	mergedIDs, allIDs := string(mergedJSON), string(allJSON)
+	inMerged := `IN (` + db.dialect.jsonIDs(`?`) + `)`
+	inAll := `IN (` + db.dialect.jsonIDs(`?1`) + `)`
+
+	var ancestors int
+	err = tx.QueryRowContext(ctx, `WITH RECURSIVE ancestors (id) AS (
+		SELECT parent_id FROM tasks WHERE id = ?
+		UNION
+		SELECT t.parent_id FROM tasks t JOIN ancestors a ON t.id = a.id
+	)
+	SELECT COUNT(*) FROM ancestors WHERE id `+inMerged, into, mergedIDs).Scan(&ancestors)
+	if err != nil {
+		return nil, err
+	}
+	if ancestors > 0 {
+		return nil, ErrMergeIntoSubtask
+	}
+
+	offset, err := taskReminderOffset(ctx, tx, into)
+	if err != nil {
+		return nil, err
+	}
+	task, err := scanTask(db.queryReturning(ctx, tx, "tasks", int64(into), `UPDATE tasks SET tags = ?, created_at = ?, due_at = ?, remind_at = ?, updated_at = ? WHERE id = ?
+	RETURNING `+taskColumns, tags, createdAt.UTC(), utcTime(dueAt), remindAt(dueAt, offset), time.Now().UTC(), into))
+	if err != nil {
+		return nil, err
+	}
+
+	// Subtasks of the merged tasks move to the survivor
+	if err := db.logTaskChangesWhere(ctx, tx, `parent_id `+inMerged, mergedIDs); err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE tasks SET parent_id = ? WHERE parent_id `+inMerged, into, mergedIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// So do their attachments, whose files stay where they are
+	_, err = tx.ExecContext(ctx, `UPDATE attachments SET task_id = ? WHERE task_id `+inMerged, into, mergedIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// So do their dependencies, except those between tasks being merged, which would make
+	// the survivor block itself. A new dependency can only close a cycle through the survivor.
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO task_dependencies (task_id, blocked_by_id, created_at)
+	SELECT CASE WHEN task_id `+inAll+` THEN ?2 ELSE task_id END,
+		CASE WHEN blocked_by_id `+inAll+` THEN ?2 ELSE blocked_by_id END,
+		MIN(created_at)
+	FROM task_dependencies
+	WHERE (task_id `+inAll+`) <> (blocked_by_id `+inAll+`)
+	GROUP BY 1, 2
+	ON CONFLICT (task_id, blocked_by_id) DO NOTHING`, allIDs, into)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM task_dependencies
+	WHERE task_id `+inMerged+` OR blocked_by_id `+inMerged, mergedIDs, mergedIDs)
+	if err != nil {
+		return nil, err
+	}
+	var cycle int
+	err = tx.QueryRowContext(ctx, `WITH RECURSIVE blockers (id) AS (
+		SELECT blocked_by_id FROM task_dependencies WHERE task_id = ?
+		UNION
+		SELECT d.blocked_by_id FROM task_dependencies d JOIN blockers b ON d.task_id = b.id
+	)
+	SELECT COUNT(*) FROM blockers WHERE id = ?`, into, into).Scan(&cycle)
+	if err != nil {
+		return nil, err
+	}
+	if cycle > 0 {
+		return nil, ErrDependencyCycle
+	}
+	// The blocked flag of the tasks the survivor now blocks may have changed
+	if err := db.logTaskChangesWhere(ctx, tx, `id IN (SELECT task_id FROM task_dependencies WHERE blocked_by_id = ?)`, into); err != nil {
+		return nil, err
+	}
+
+	// And their links, except those between tasks being merged. The other ends now list the
+	// survivor instead; a relates_to link keeps the lower ID first.
+	if err := db.logTaskChangesWhere(ctx, tx, `id IN (SELECT linked_id FROM task_links WHERE task_id `+inMerged+`)
+		OR id IN (SELECT task_id FROM task_links WHERE linked_id `+inMerged+`)`, mergedIDs, mergedIDs); err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO task_links (task_id, linked_id, type, created_at)
+	SELECT CASE WHEN type = ?3 AND task_id > linked_id THEN linked_id ELSE task_id END,
+		CASE WHEN type = ?3 AND task_id > linked_id THEN task_id ELSE linked_id END,
+		type, MIN(created_at)
+	FROM (
+		SELECT CASE WHEN task_id `+inAll+` THEN ?2 ELSE task_id END AS task_id,
+			CASE WHEN linked_id `+inAll+` THEN ?2 ELSE linked_id END AS linked_id,
+			type, created_at
+		FROM task_links
+		WHERE (task_id `+inAll+`) <> (linked_id `+inAll+`)
+	) moved
+	GROUP BY 1, 2, 3
+	ON CONFLICT (task_id, linked_id, type) DO NOTHING`, allIDs, into, LinkRelatesTo)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM task_links
+	WHERE task_id `+inMerged+` OR linked_id `+inMerged, mergedIDs, mergedIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Review decisions are about the merged tasks as they were, so the survivor keeps its own
+	_, err = tx.ExecContext(ctx, `DELETE FROM review_decisions WHERE task_id `+inMerged, mergedIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE hook_tasks SET task_id = ? WHERE task_id `+inMerged, into, mergedIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tasks merged into the merged tasks earlier now point at the survivor too
+	_, err = tx.ExecContext(ctx, `UPDATE task_tombstones SET merged_into = ? WHERE merged_into `+inMerged, into, mergedIDs)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	for _, m := range merged {
+		if err := db.recordTaskEvent(ctx, tx, m.ID, TaskEventMerged, map[string]FieldChange{"merged_into": {To: into}}); err != nil {
+			return nil, err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO task_tombstones (uuid, task_id, deleted_at, merged_into) VALUES (?, ?, ?, ?)`,
+			m.UUID, m.ID, now, into)
+		if err != nil {
+			return nil, err
+		}
+	}
+	query := `DELETE FROM tasks WHERE id ` + inMerged
+	start := time.Now()
+	_, err = tx.ExecContext(ctx, query, mergedIDs)
+	db.checkSlowQuery(ctx, start, query, mergedIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := taskFieldChanges(*before, *task)
+	if !before.CreatedAt.Equal(task.CreatedAt) {
+		changes["created_at"] = FieldChange{before.CreatedAt, task.CreatedAt}
+	}
+	changes["merged"] = FieldChange{To: ids}
+	if err := db.recordTaskEvent(ctx, tx, into, TaskEventMerged, changes); err != nil {
+		return nil, err
+	}
+
+	tasks := []Task{*task}
+	if err := db.attachRelations(ctx, tx, tasks); err != nil {
+		return nil, err
+	}
+	return &tasks[0], nil
+}
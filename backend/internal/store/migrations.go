@@ -0,0 +1,841 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"todo-app/internal/config"
+)
+
+var (
+	// ErrSchemaTooNew is returned at startup when a newer release has migrated the database
+	// past the migrations this one knows, as after rolling back a release
+	ErrSchemaTooNew = errors.New("database schema is newer than this release")
+	// ErrSchemaMismatch is returned at startup when the migrations recorded in the database
+	// are not this release's migrations of the same versions
+	ErrSchemaMismatch = errors.New("database schema does not match this release")
+)
+
+// MigrationBackup backs up a SQLite database into TODO_BACKUP_DIR before migrations are
+// applied to it, so an upgrade can be undone
+var MigrationBackup = config.EnvBool("TODO_MIGRATION_BACKUP", true)
+
+// migration is a forward-only schema change applied once, in version order, on startup
+type migration struct {
+	version    int
+	name       string
+	statements []string
+	// postgres replaces statements on PostgreSQL when they use SQLite-only functions.
+	// CREATE and ALTER statements are adapted by sqlDialect.ddl and need no replacement.
+	postgres []string
+	// mysql replaces statements on MySQL likewise, and when an index covers TEXT columns,
+	// which MySQL only indexes by a prefix
+	mysql []string
+}
+
+// migrations lists every schema change made after the initial tasks table.
+// Append new entries with the next version number; never edit an applied one.
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "add_due_and_completed_timestamps",
+		statements: []string{
+			`ALTER TABLE tasks ADD COLUMN due_at TIMESTAMP`,
+			`ALTER TABLE tasks ADD COLUMN completed_at TIMESTAMP`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_due_at ON tasks (due_at)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_completed_at ON tasks (completed_at)`,
+		},
+	},
+	{
+		version: 2,
+		name:    "add_task_description",
+		statements: []string{
+			`ALTER TABLE tasks ADD COLUMN description TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version: 3,
+		name:    "create_snapshots",
+		statements: []string{
+			`CREATE TABLE snapshots (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL UNIQUE,
+				created_at TIMESTAMP NOT NULL,
+				task_count INTEGER NOT NULL
+			)`,
+			`CREATE TABLE snapshot_tasks (
+				snapshot_id INTEGER NOT NULL REFERENCES snapshots (id),
+				task_id INTEGER NOT NULL,
+				data TEXT NOT NULL,
+				PRIMARY KEY (snapshot_id, task_id)
+			)`,
+		},
+	},
+	{
+		version: 4,
+		name:    "add_task_uuid_and_tombstones",
+		statements: []string{
+			`ALTER TABLE tasks ADD COLUMN uuid TEXT`,
+			// Backfill existing rows with random (version 4) UUIDs
+			`UPDATE tasks SET uuid =
+				lower(hex(randomblob(4))) || '-' ||
+				lower(hex(randomblob(2))) || '-4' ||
+				substr(lower(hex(randomblob(2))), 2) || '-' ||
+				substr('89ab', 1 + (abs(random()) % 4), 1) || substr(lower(hex(randomblob(2))), 2) || '-' ||
+				lower(hex(randomblob(6)))
+			WHERE uuid IS NULL`,
+			`CREATE UNIQUE INDEX idx_tasks_uuid ON tasks (uuid)`,
+			`CREATE TABLE task_tombstones (
+				uuid TEXT PRIMARY KEY,
+				task_id INTEGER NOT NULL,
+				deleted_at TIMESTAMP NOT NULL
+			)`,
+		},
+		postgres: []string{
+			`ALTER TABLE tasks ADD COLUMN uuid TEXT`,
+			`UPDATE tasks SET uuid = gen_random_uuid()::text WHERE uuid IS NULL`,
+			`CREATE UNIQUE INDEX idx_tasks_uuid ON tasks (uuid)`,
+			`CREATE TABLE task_tombstones (
+				uuid TEXT PRIMARY KEY,
+				task_id INTEGER NOT NULL,
+				deleted_at TIMESTAMP NOT NULL
+			)`,
+		},
+		mysql: []string{
+			`ALTER TABLE tasks ADD COLUMN uuid VARCHAR(36)`,
+			`UPDATE tasks SET uuid = UUID() WHERE uuid IS NULL`,
+			`CREATE UNIQUE INDEX idx_tasks_uuid ON tasks (uuid)`,
+			`CREATE TABLE task_tombstones (
+				uuid TEXT PRIMARY KEY,
+				task_id INTEGER NOT NULL,
+				deleted_at TIMESTAMP NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 5,
+		name:    "add_task_deleted_at",
+		statements: []string{
+			`ALTER TABLE tasks ADD COLUMN deleted_at TIMESTAMP`,
+			`CREATE INDEX idx_tasks_deleted_at ON tasks (deleted_at)`,
+		},
+	},
+	{
+		version: 6,
+		name:    "add_task_position",
+		statements: []string{
+			`ALTER TABLE tasks ADD COLUMN position INTEGER NOT NULL DEFAULT 0`,
+			// Seed the manual order from the existing newest-first order
+			`UPDATE tasks SET position = (
+				SELECT COUNT(*) FROM tasks AS newer
+				WHERE newer.created_at > tasks.created_at
+					OR (newer.created_at = tasks.created_at AND newer.id > tasks.id)
+			)`,
+			`CREATE INDEX idx_tasks_position ON tasks (position)`,
+		},
+		mysql: []string{
+			`ALTER TABLE tasks ADD COLUMN position INTEGER NOT NULL DEFAULT 0`,
+			// MySQL cannot read the table an UPDATE changes in a subquery, but can join it
+			// to a ranking of its rows
+			`UPDATE tasks JOIN (
+				SELECT id, ROW_NUMBER() OVER (ORDER BY created_at DESC, id DESC) - 1 AS newer
+				FROM tasks
+			) AS ranked ON ranked.id = tasks.id
+			SET tasks.position = ranked.newer`,
+			`CREATE INDEX idx_tasks_position ON tasks (position)`,
+		},
+	},
+	{
+		version: 7,
+		name:    "add_task_parent_id",
+		statements: []string{
+			`ALTER TABLE tasks ADD COLUMN parent_id INTEGER REFERENCES tasks (id)`,
+			`CREATE INDEX idx_tasks_parent_id ON tasks (parent_id)`,
+		},
+	},
+	{
+		version: 8,
+		name:    "add_task_reminded_at",
+		statements: []string{
+			`ALTER TABLE tasks ADD COLUMN reminded_at TIMESTAMP`,
+		},
+	},
+	{
+		version: 9,
+		name:    "add_task_list_id",
+		statements: []string{
+			// The lists table and its default list are created by createTables
+			`ALTER TABLE tasks ADD COLUMN list_id INTEGER REFERENCES lists (id)`,
+			`UPDATE tasks SET list_id = (SELECT id FROM lists WHERE is_default = TRUE)`,
+			`CREATE INDEX idx_tasks_list_id ON tasks (list_id)`,
+		},
+	},
+	{
+		version: 10,
+		name:    "add_task_tags",
+		statements: []string{
+			// JSON array of normalized tag names
+			`ALTER TABLE tasks ADD COLUMN tags TEXT NOT NULL DEFAULT '[]'`,
+		},
+	},
+	{
+		version: 11,
+		name:    "create_notification_rules",
+		statements: []string{
+			// list_id and tag are optional conditions; target is interpreted by the notifier
+			`CREATE TABLE notification_rules (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id TEXT NOT NULL,
+				event TEXT NOT NULL,
+				list_id INTEGER REFERENCES lists (id),
+				tag TEXT,
+				notifier TEXT NOT NULL,
+				target TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX idx_notification_rules_event ON notification_rules (event)`,
+			`CREATE INDEX idx_notification_rules_user_id ON notification_rules (user_id)`,
+		},
+		mysql: []string{
+			`CREATE TABLE notification_rules (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id TEXT NOT NULL,
+				event TEXT NOT NULL,
+				list_id INTEGER REFERENCES lists (id),
+				tag TEXT,
+				notifier TEXT NOT NULL,
+				target TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX idx_notification_rules_event ON notification_rules (event(191))`,
+			`CREATE INDEX idx_notification_rules_user_id ON notification_rules (user_id(191))`,
+		},
+	},
+	{
+		version: 12,
+		name:    "create_notification_settings_and_queue",
+		statements: []string{
+			// Quiet hours are local HH:MM times in timezone; empty means none
+			`CREATE TABLE notification_settings (
+				user_id TEXT PRIMARY KEY,
+				quiet_hours_start TEXT NOT NULL DEFAULT '',
+				quiet_hours_end TEXT NOT NULL DEFAULT '',
+				timezone TEXT NOT NULL DEFAULT 'UTC',
+				batch_window_seconds INTEGER NOT NULL DEFAULT 0,
+				updated_at TIMESTAMP NOT NULL
+			)`,
+			// Notifications held by quiet hours or batching; task is the task's JSON at event time
+			`CREATE TABLE notification_queue (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id TEXT NOT NULL,
+				rule_id INTEGER NOT NULL,
+				notifier TEXT NOT NULL,
+				target TEXT NOT NULL,
+				event TEXT NOT NULL,
+				task TEXT NOT NULL,
+				queued_at TIMESTAMP NOT NULL,
+				deliver_after TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX idx_notification_queue_group ON notification_queue (user_id, notifier, target)`,
+			`CREATE INDEX idx_notification_queue_deliver_after ON notification_queue (deliver_after)`,
+		},
+		mysql: []string{
+			`CREATE TABLE notification_settings (
+				user_id TEXT PRIMARY KEY,
+				quiet_hours_start TEXT NOT NULL DEFAULT '',
+				quiet_hours_end TEXT NOT NULL DEFAULT '',
+				timezone TEXT NOT NULL DEFAULT 'UTC',
+				batch_window_seconds INTEGER NOT NULL DEFAULT 0,
+				updated_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE TABLE notification_queue (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id TEXT NOT NULL,
+				rule_id INTEGER NOT NULL,
+				notifier TEXT NOT NULL,
+				target TEXT NOT NULL,
+				event TEXT NOT NULL,
+				task TEXT NOT NULL,
+				queued_at TIMESTAMP NOT NULL,
+				deliver_after TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX idx_notification_queue_group ON notification_queue (user_id(191), notifier(191), target(191))`,
+			`CREATE INDEX idx_notification_queue_deliver_after ON notification_queue (deliver_after)`,
+		},
+	},
+	{
+		version: 13,
+		name:    "create_task_dependencies",
+		statements: []string{
+			// task_id is blocked by blocked_by_id
+			`CREATE TABLE task_dependencies (
+				task_id INTEGER NOT NULL REFERENCES tasks (id),
+				blocked_by_id INTEGER NOT NULL REFERENCES tasks (id),
+				created_at TIMESTAMP NOT NULL,
+				PRIMARY KEY (task_id, blocked_by_id)
+			)`,
+			`CREATE INDEX idx_task_dependencies_blocked_by_id ON task_dependencies (blocked_by_id)`,
+		},
+	},
+	{
+		version: 14,
+		name:    "create_task_events",
+		statements: []string{
+			// No foreign key: the history outlives tasks purged from the trash
+			`CREATE TABLE task_events (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				task_id INTEGER NOT NULL,
+				event TEXT NOT NULL,
+				changes TEXT NOT NULL DEFAULT '{}',
+				actor TEXT NOT NULL,
+				trace_id TEXT,
+				span_id TEXT,
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX idx_task_events_task_id ON task_events (task_id)`,
+			// Existing tasks start their history with their creation
+			`INSERT INTO task_events (task_id, event, actor, created_at)
+			SELECT id, 'created', 'system', created_at FROM tasks`,
+		},
+	},
+	{
+		version: 15,
+		name:    "add_task_claims",
+		statements: []string{
+			`ALTER TABLE tasks ADD COLUMN claimed_by TEXT`,
+			`ALTER TABLE tasks ADD COLUMN claim_expires_at TIMESTAMP`,
+		},
+	},
+	{
+		version: 16,
+		name:    "create_changelog",
+		statements: []string{
+			// seq only grows: AUTOINCREMENT never reuses the numbers of purged rows
+			`CREATE TABLE changelog (
+				seq INTEGER PRIMARY KEY AUTOINCREMENT,
+				task_id INTEGER NOT NULL,
+				created_at TIMESTAMP NOT NULL
+			)`,
+			// since_seq=0 then returns every existing task
+			`INSERT INTO changelog (task_id, created_at) SELECT id, created_at FROM tasks ORDER BY id`,
+		},
+	},
+	{
+		version: 17,
+		name:    "create_idempotency_keys",
+		statements: []string{
+			// status is NULL while the first request with the key is still running
+			`CREATE TABLE idempotency_keys (
+				user_id TEXT NOT NULL,
+				key TEXT NOT NULL,
+				fingerprint TEXT NOT NULL,
+				status INTEGER,
+				content_type TEXT,
+				change_seq INTEGER,
+				response TEXT,
+				created_at TIMESTAMP NOT NULL,
+				PRIMARY KEY (user_id, key)
+			)`,
+			`CREATE INDEX idx_idempotency_keys_created_at ON idempotency_keys (created_at)`,
+		},
+		mysql: []string{
+			// key is a reserved word in MySQL
+			`CREATE TABLE idempotency_keys (
+				user_id TEXT NOT NULL,
+				"key" TEXT NOT NULL,
+				fingerprint TEXT NOT NULL,
+				status INTEGER,
+				content_type TEXT,
+				change_seq INTEGER,
+				response TEXT,
+				created_at TIMESTAMP NOT NULL,
+				PRIMARY KEY (user_id, "key")
+			)`,
+			`CREATE INDEX idx_idempotency_keys_created_at ON idempotency_keys (created_at)`,
+		},
+	},
+	{
+		version: 18,
+		name:    "create_export_cursors",
+		statements: []string{
+			// How far each export of the task history (e.g. to a SIEM) has got
+			`CREATE TABLE export_cursors (
+				name TEXT PRIMARY KEY,
+				position INTEGER NOT NULL,
+				updated_at TIMESTAMP NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 19,
+		name:    "create_cluster_tables",
+		statements: []string{
+			// Which replica wrote an event, so the others can relay it to their clients
+			`ALTER TABLE task_events ADD COLUMN instance_id TEXT`,
+			`CREATE TABLE cluster_members (
+				instance_id TEXT PRIMARY KEY,
+				hostname TEXT NOT NULL,
+				started_at TIMESTAMP NOT NULL,
+				last_seen TIMESTAMP NOT NULL
+			)`,
+			`CREATE TABLE cluster_leases (
+				name TEXT PRIMARY KEY,
+				holder TEXT NOT NULL,
+				expires_at TIMESTAMP NOT NULL
+			)`,
+			// nonce_key is the client ID and the nonce
+			`CREATE TABLE signature_nonces (
+				nonce_key TEXT PRIMARY KEY,
+				expires_at TIMESTAMP NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 20,
+		name:    "create_webhooks",
+		statements: []string{
+			// events is a comma-separated list of the event types the webhook receives
+			`CREATE TABLE webhooks (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id TEXT NOT NULL,
+				url TEXT NOT NULL,
+				events TEXT NOT NULL,
+				secret TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON webhooks (user_id)`,
+			// status is pending until delivered, or failed once out of attempts. trace_id and
+			// span_id are the change's span, which each delivery attempt links to.
+			`CREATE TABLE webhook_deliveries (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				webhook_id INTEGER NOT NULL REFERENCES webhooks (id),
+				event TEXT NOT NULL,
+				payload TEXT NOT NULL,
+				status TEXT NOT NULL DEFAULT 'pending',
+				attempts INTEGER NOT NULL DEFAULT 0,
+				next_attempt_at TIMESTAMP NOT NULL,
+				last_status_code INTEGER,
+				last_error TEXT,
+				trace_id TEXT,
+				span_id TEXT,
+				created_at TIMESTAMP NOT NULL,
+				delivered_at TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at)`,
+			`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id)`,
+		},
+		mysql: []string{
+			`CREATE TABLE webhooks (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id TEXT NOT NULL,
+				url TEXT NOT NULL,
+				events TEXT NOT NULL,
+				secret TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX idx_webhooks_user_id ON webhooks (user_id(191))`,
+			`CREATE TABLE webhook_deliveries (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				webhook_id INTEGER NOT NULL REFERENCES webhooks (id),
+				event TEXT NOT NULL,
+				payload TEXT NOT NULL,
+				status TEXT NOT NULL DEFAULT 'pending',
+				attempts INTEGER NOT NULL DEFAULT 0,
+				next_attempt_at TIMESTAMP NOT NULL,
+				last_status_code INTEGER,
+				last_error TEXT,
+				trace_id TEXT,
+				span_id TEXT,
+				created_at TIMESTAMP NOT NULL,
+				delivered_at TIMESTAMP
+			)`,
+			`CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (status(191), next_attempt_at)`,
+			`CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id)`,
+		},
+	},
+	{
+		version: 21,
+		name:    "create_dead_letters",
+		statements: []string{
+			`ALTER TABLE webhook_deliveries ADD COLUMN failed_at TIMESTAMP`,
+			// The last attempt's time was not kept, so deliveries that already failed date from their creation
+			`UPDATE webhook_deliveries SET failed_at = created_at WHERE status = 'failed'`,
+			// Rule notifications whose delivery failed, kept until replayed or discarded
+			`CREATE TABLE failed_notifications (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id TEXT NOT NULL,
+				rule_id INTEGER NOT NULL,
+				notifier TEXT NOT NULL,
+				target TEXT NOT NULL,
+				event TEXT NOT NULL,
+				task TEXT NOT NULL,
+				error TEXT NOT NULL,
+				attempts INTEGER NOT NULL,
+				failed_at TIMESTAMP NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 22,
+		name:    "create_notification_outbox",
+		statements: []string{
+			// Rule notifications written with the change that triggered them and removed once
+			// delivered or dead-lettered
+			`CREATE TABLE notification_outbox (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id TEXT NOT NULL,
+				rule_id INTEGER NOT NULL,
+				notifier TEXT NOT NULL,
+				target TEXT NOT NULL,
+				event TEXT NOT NULL,
+				task TEXT NOT NULL,
+				attempts INTEGER NOT NULL DEFAULT 0,
+				next_attempt_at TIMESTAMP NOT NULL,
+				last_error TEXT,
+				trace_id TEXT,
+				span_id TEXT,
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX idx_notification_outbox_next_attempt_at ON notification_outbox (next_attempt_at)`,
+		},
+	},
+	{
+		version: 23,
+		name:    "create_hook_tables",
+		statements: []string{
+			// Deliveries received on /hooks/{provider}, kept for a while so redeliveries are dropped
+			`CREATE TABLE hook_deliveries (
+				provider TEXT NOT NULL,
+				delivery_id TEXT NOT NULL,
+				event TEXT NOT NULL,
+				received_at TIMESTAMP NOT NULL,
+				PRIMARY KEY (provider, delivery_id)
+			)`,
+			// The task created for each external object, e.g. a GitHub issue, so later events find it
+			`CREATE TABLE hook_tasks (
+				provider TEXT NOT NULL,
+				external_id TEXT NOT NULL,
+				task_id INTEGER NOT NULL REFERENCES tasks (id),
+				created_at TIMESTAMP NOT NULL,
+				PRIMARY KEY (provider, external_id)
+			)`,
+		},
+	},
+	{
+		version: 24,
+		name:    "add_tombstone_merged_into",
+		statements: []string{
+			// The task a merged task lives on as, so requests for it can be redirected
+			`ALTER TABLE task_tombstones ADD COLUMN merged_into INTEGER`,
+			`CREATE INDEX idx_task_tombstones_merged ON task_tombstones (task_id) WHERE merged_into IS NOT NULL`,
+		},
+	},
+	{
+		version: 25,
+		name:    "add_task_updated_at",
+		statements: []string{
+			// Every statement that changes a task sets it; existing tasks start from their
+			// last logged change
+			`ALTER TABLE tasks ADD COLUMN updated_at TIMESTAMP`,
+			`UPDATE tasks SET updated_at = COALESCE((SELECT MAX(created_at) FROM changelog WHERE task_id = tasks.id), created_at)`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_updated_at ON tasks (updated_at)`,
+		},
+	},
+	{
+		version: 26,
+		name:    "create_user_stats",
+		statements: []string{
+			// Kept up to date on each completion; last_completed_on is a YYYY-MM-DD day in the
+			// user's time zone, and completed_today counts the completions on that day
+			`CREATE TABLE user_stats (
+				user_id TEXT PRIMARY KEY,
+				completed_total INTEGER NOT NULL DEFAULT 0,
+				completed_today INTEGER NOT NULL DEFAULT 0,
+				current_streak INTEGER NOT NULL DEFAULT 0,
+				longest_streak INTEGER NOT NULL DEFAULT 0,
+				last_completed_on TEXT NOT NULL DEFAULT '',
+				updated_at TIMESTAMP NOT NULL
+			)`,
+			// Totals start from the history; streaks start with the next completion
+			`INSERT INTO user_stats (user_id, completed_total, updated_at)
+			SELECT actor, COUNT(*), MAX(created_at) FROM task_events WHERE event = 'completed' AND actor <> 'system' GROUP BY actor`,
+		},
+	},
+	{
+		version: 27,
+		name:    "create_list_settings",
+		statements: []string{
+			// Defaults for the tasks created in a list; reminder_offset is in seconds
+			`CREATE TABLE list_settings (
+				list_id INTEGER PRIMARY KEY REFERENCES lists (id),
+				default_tags TEXT NOT NULL DEFAULT '[]',
+				reminder_offset INTEGER,
+				updated_at TIMESTAMP NOT NULL
+			)`,
+			// A task created with its list's reminder offset keeps it, and is reminded about at
+			// remind_at rather than TODO_REMINDER_LEAD before it is due
+			`ALTER TABLE tasks ADD COLUMN reminder_offset INTEGER`,
+			`ALTER TABLE tasks ADD COLUMN remind_at TIMESTAMP`,
+		},
+	},
+	{
+		version: 28,
+		name:    "create_workspaces",
+		statements: []string{
+			`CREATE TABLE workspaces (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL UNIQUE,
+				is_default BOOLEAN NOT NULL DEFAULT FALSE,
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE TABLE workspace_members (
+				workspace_id INTEGER NOT NULL REFERENCES workspaces (id),
+				user_id TEXT NOT NULL,
+				role TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL,
+				PRIMARY KEY (workspace_id, user_id)
+			)`,
+			// Defaults for the tasks created in any list of a workspace, under the list's own
+			`CREATE TABLE workspace_settings (
+				workspace_id INTEGER PRIMARY KEY REFERENCES workspaces (id),
+				default_tags TEXT NOT NULL DEFAULT '[]',
+				reminder_offset INTEGER,
+				updated_at TIMESTAMP NOT NULL
+			)`,
+			// Every existing list, the default one included, starts in the default workspace
+			`INSERT INTO workspaces (name, is_default, created_at) VALUES ('Personal', TRUE, CURRENT_TIMESTAMP)`,
+			`ALTER TABLE lists ADD COLUMN workspace_id INTEGER REFERENCES workspaces (id)`,
+			`UPDATE lists SET workspace_id = (SELECT id FROM workspaces WHERE is_default = TRUE)`,
+			`CREATE INDEX idx_lists_workspace ON lists (workspace_id)`,
+		},
+	},
+	{
+		version: 29,
+		name:    "create_attachments",
+		statements: []string{
+			// storage_key names the blob in the attachment store; it has no foreign key on
+			// tasks, so a purged task's attachments are found and removed with their blobs
+			`CREATE TABLE attachments (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				task_id INTEGER NOT NULL,
+				name TEXT NOT NULL,
+				content_type TEXT NOT NULL,
+				size INTEGER NOT NULL,
+				sha256 TEXT NOT NULL,
+				storage_key TEXT NOT NULL UNIQUE,
+				created_by TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX idx_attachments_task ON attachments (task_id)`,
+		},
+	},
+	{
+		version: 30,
+		name:    "create_uploads",
+		statements: []string{
+			// received is how many bytes of size have been written to the upload's staging
+			// file; it only moves forward, by a compare-and-set on its old value
+			`CREATE TABLE uploads (
+				id TEXT PRIMARY KEY,
+				task_id INTEGER NOT NULL,
+				name TEXT NOT NULL,
+				content_type TEXT NOT NULL,
+				size INTEGER NOT NULL,
+				received INTEGER NOT NULL DEFAULT 0,
+				created_by TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL,
+				updated_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX idx_uploads_updated ON uploads (updated_at)`,
+		},
+	},
+	{
+		version: 31,
+		name:    "create_task_links",
+		statements: []string{
+			// task_id duplicates or relates_to linked_id; relates_to goes both ways and is
+			// stored with the lower ID in task_id
+			`CREATE TABLE task_links (
+				task_id INTEGER NOT NULL REFERENCES tasks (id),
+				linked_id INTEGER NOT NULL REFERENCES tasks (id),
+				type TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL,
+				PRIMARY KEY (task_id, linked_id, type)
+			)`,
+			`CREATE INDEX idx_task_links_linked_id ON task_links (linked_id)`,
+		},
+	},
+	{
+		version: 32,
+		name:    "create_review_decisions",
+		statements: []string{
+			// A user's latest weekly review decision on a task, which keeps it out of their
+			// review queue until review_after
+			`CREATE TABLE review_decisions (
+				user_id TEXT NOT NULL,
+				task_id INTEGER NOT NULL REFERENCES tasks (id),
+				decision TEXT NOT NULL,
+				review_after TIMESTAMP NOT NULL,
+				decided_at TIMESTAMP NOT NULL,
+				PRIMARY KEY (user_id, task_id)
+			)`,
+			`CREATE INDEX idx_review_decisions_task_id ON review_decisions (task_id)`,
+		},
+	},
+}
+
+// migrate applies every migration newer than the recorded schema version, each in its own
+// transaction. It first checks that this release can run against the schema, and backs up a
+// SQLite database that has data before changing it; a failed backup stops the migration.
+func (db *DB) migrate(ctx context.Context) error {
+	_, err := db.conn.ExecContext(ctx, db.dialect.ddl(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	);`))
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	if err := db.CheckSchema(ctx); err != nil {
+		return err
+	}
+	current, err := db.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	if current < LatestSchemaVersion() && MigrationBackup && db.SupportsSnapshots() {
+		if err := db.backupBeforeMigration(ctx, current); err != nil {
+			return fmt.Errorf("failed to back up the database before migrating, set TODO_MIGRATION_BACKUP=false to migrate without a backup: %w", err)
+		}
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		tx, err := db.conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		statements := m.statements
+		switch {
+		case db.dialect == dialectPostgres && m.postgres != nil:
+			statements = m.postgres
+		case db.dialect == dialectMySQL && m.mysql != nil:
+			statements = m.mysql
+		}
+		for _, stmt := range statements {
+			if _, err := tx.ExecContext(ctx, db.dialect.ddl(stmt)); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+			}
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+			m.version, m.name, time.Now().UTC())
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %d: %w", m.version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %w", m.version, err)
+		}
+
+		slog.InfoContext(ctx, "Applied database migration", "version", m.version, "name", m.name)
+	}
+
+	return nil
+}
+
+// SchemaVersion returns the highest applied migration version, 0 for a fresh database
+func (db *DB) SchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	err := db.conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// LatestSchemaVersion returns the schema version this release migrates the database to
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// CheckSchema verifies that this release can run against the database's schema: the
+// migrations recorded are this release's, without gaps, and none is newer than its latest.
+// A release rolled back after a newer one migrated the database gets ErrSchemaTooNew rather
+// than running against tables it does not know, and a database migrated by a diverging
+// build gets ErrSchemaMismatch.
+func (db *DB) CheckSchema(ctx context.Context) error {
+	rows, err := db.conn.QueryContext(ctx, `SELECT version, name FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return fmt.Errorf("failed to read schema migrations: %w", err)
+	}
+	defer rows.Close()
+
+	latest := LatestSchemaVersion()
+	var newer []string
+	previous := 0
+	for rows.Next() {
+		var version int
+		var name string
+		if err := rows.Scan(&version, &name); err != nil {
+			return err
+		}
+		if version > latest {
+			newer = append(newer, fmt.Sprintf("%d (%s)", version, name))
+			continue
+		}
+		if version != previous+1 {
+			return fmt.Errorf("%w: migration %d is not recorded, though %d is", ErrSchemaMismatch, previous+1, version)
+		}
+		previous = version
+		i := slices.IndexFunc(migrations, func(m migration) bool { return m.version == version })
+		if i < 0 || migrations[i].name != name {
+			expected := ""
+			if i >= 0 {
+				expected = migrations[i].name
+			}
+			return fmt.Errorf("%w: migration %d was applied as %q, but this release's is %q", ErrSchemaMismatch, version, name, expected)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(newer) > 0 {
+		return fmt.Errorf("%w: it has migrations %s, and this release only knows up to %d. A newer release migrated it; "+
+			"run that release, or restore the database from before the upgrade", ErrSchemaTooNew, strings.Join(newer, ", "), latest)
+	}
+	return nil
+}
+
+// backupBeforeMigration backs the database up into TODO_BACKUP_DIR before it is migrated from
+// version current, as pre-migrate-v<current>-<time>.db, which pruning keeps. A new, empty
+// database has nothing to keep.
+func (db *DB) backupBeforeMigration(ctx context.Context, current int) error {
+	if current == 0 {
+		var tasks int
+		if err := db.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM tasks`).Scan(&tasks); err != nil {
+			return err
+		}
+		if tasks == 0 {
+			return nil
+		}
+	}
+	backup, err := db.backupInto(ctx, backupDir(), fmt.Sprintf("%s%d-", preMigrationPrefix, current))
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Backed up the database before migrating",
+		"backup", backup.Name, "dir", backupDir(), "from_version", current, "to_version", LatestSchemaVersion())
+	return nil
+}
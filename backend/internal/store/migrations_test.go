@@ -0,0 +1,59 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"todo-app/internal/store"
+)
+
+func TestSetupRefusesNewerSchemaBeforeChangingIt(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	newTestDB(t, path)
+
+	// A newer release migrated the database, and dropped a table this release creates
+	raw, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	if _, err := raw.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'from_a_newer_release', ?)`,
+		store.LatestSchemaVersion()+1, time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := raw.ExecContext(ctx, `DROP TABLE lists`); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := store.NewDB(ctx, path)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	if err := db.Setup(ctx); !errors.Is(err, store.ErrSchemaTooNew) {
+		t.Fatalf("Setup = %v, want ErrSchemaTooNew", err)
+	}
+
+	rows, err := raw.QueryContext(ctx, `SELECT name FROM sqlite_master WHERE type = 'table'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, name)
+	}
+	if slices.Contains(tables, "lists") {
+		t.Error("Setup created the lists table in a database it then refused")
+	}
+}
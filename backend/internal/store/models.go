@@ -0,0 +1,94 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+type Task struct {
+	ID          int        `json:"id"`
+	UUID        string     `json:"uuid"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DueAt       *time.Time `json:"due_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	Position    int        `json:"position"`
+	ParentID    *int       `json:"parent_id"`
+	ListID      int        `json:"list_id"`
+	Tags        Tags       `json:"tags"`
+	// UpdatedAt is when the task last changed in any way it is returned, so it serves as
+	// its version: the ETag, the delta sync cursor and the If-Match check
+	UpdatedAt time.Time `json:"updated_at"`
+	// ClaimedBy is the user working on the task until ClaimExpiresAt, if anyone
+	ClaimedBy      *string    `json:"claimed_by"`
+	ClaimExpiresAt *time.Time `json:"claim_expires_at"`
+	// BlockedBy and Blocked are filled in where task dependencies are loaded
+	BlockedBy []int `json:"blocked_by,omitempty"`
+	Blocked   bool  `json:"blocked"`
+	// Links are filled in along with them, from both sides of each link
+	Links []TaskLink `json:"links,omitempty"`
+	// AgeDays is how many whole days the task has been open, up to its completion once
+	// completed. Stale is set on an open task unchanged for TODO_STALE_DAYS.
+	AgeDays int  `json:"age_days"`
+	Stale   bool `json:"stale"`
+}
+
+// NewTask holds the client-supplied fields of a task being created
+type NewTask struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	DueAt       *time.Time `json:"due_at"`
+	ListID      *int       `json:"list_id"` // default list when omitted
+	Tags        Tags       `json:"tags"`
+}
+
+// TaskUpdate describes a partial update; nil fields are left unchanged
+type TaskUpdate struct {
+	Title       *string      `json:"title"`
+	Description *string      `json:"description"`
+	Completed   *bool        `json:"completed"`
+	DueAt       OptionalTime `json:"due_at"`
+	ListID      *int         `json:"list_id"`
+	Tags        *Tags        `json:"tags"`
+	// IfUpdatedAt, when set, refuses the update with ErrTaskModified unless the task is
+	// still as it was then
+	IfUpdatedAt *time.Time `json:"-"`
+}
+
+// OptionalTime distinguishes an absent JSON field from an explicit null,
+// so a PATCH can clear a timestamp without touching it when omitted
+type OptionalTime struct {
+	Set   bool
+	Value *time.Time
+}
+
+func (o *OptionalTime) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	return json.Unmarshal(data, &o.Value)
+}
+
+// TaskQuery filters and orders the task list
+type TaskQuery struct {
+	Search string       // case- and diacritic-insensitive title substring
+	Sort   string       // SortCreated (default), SortTitle or SortPosition
+	Locale language.Tag // collation locale used for SortTitle
+	ListID *int         // only tasks in this list when set
+	Tag    string       // only tasks carrying this tag when set
+	Stale  bool         // only stale tasks when set
+	Offset int          // tasks to skip from the start of the ordered list
+	Limit  int          // most tasks to return; 0 returns them all
+}
+
+// page returns the tasks of the ordered list tasks selected by q's offset and limit
+func (q TaskQuery) page(tasks []Task) []Task {
+	tasks = tasks[min(q.Offset, len(tasks)):]
+	if q.Limit > 0 && len(tasks) > q.Limit {
+		tasks = tasks[:q.Limit]
+	}
+	return tasks
+}
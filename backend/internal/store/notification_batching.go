@@ -0,0 +1,273 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	_ "time/tzdata"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+) // quiet hours accept IANA time zones even where the host has no zoneinfo
+
+// maxBatchWindow caps a user's batch window
+const maxBatchWindow = 24 * time.Hour
+
+// quietHoursLayout is the format of quiet hours boundaries
+const quietHoursLayout = "15:04"
+
+// NotificationSettings are a user's delivery preferences for rule-based notifications.
+// During quiet hours notifications are held until the quiet hours end. With a batch
+// window, notifications are held for that long and everything that arrived for the same
+// notifier and target in the meantime is delivered as one digest.
+type NotificationSettings struct {
+	UserID             string    `json:"user_id"`
+	QuietHoursStart    string    `json:"quiet_hours_start"`
+	QuietHoursEnd      string    `json:"quiet_hours_end"`
+	Timezone           string    `json:"timezone"`
+	BatchWindowSeconds int       `json:"batch_window_seconds"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// defaultNotificationSettings delivers everything immediately
+func defaultNotificationSettings(userID string) *NotificationSettings {
+	return &NotificationSettings{UserID: userID, Timezone: "UTC"}
+}
+
+// Validate checks the settings and normalizes the quiet hours boundaries
+func (s *NotificationSettings) Validate() error {
+	if (s.QuietHoursStart == "") != (s.QuietHoursEnd == "") {
+		return errors.New("quiet_hours_start and quiet_hours_end must be set together")
+	}
+	if s.QuietHoursStart != "" {
+		start, err := time.Parse(quietHoursLayout, s.QuietHoursStart)
+		if err != nil {
+			return errors.New("quiet_hours_start must be HH:MM")
+		}
+		end, err := time.Parse(quietHoursLayout, s.QuietHoursEnd)
+		if err != nil {
+			return errors.New("quiet_hours_end must be HH:MM")
+		}
+		if start.Equal(end) {
+			return errors.New("quiet hours must not start and end at the same time")
+		}
+		s.QuietHoursStart, s.QuietHoursEnd = start.Format(quietHoursLayout), end.Format(quietHoursLayout)
+	}
+	if s.Timezone == "" {
+		s.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", s.Timezone)
+	}
+	if s.BatchWindowSeconds < 0 || time.Duration(s.BatchWindowSeconds)*time.Second > maxBatchWindow {
+		return fmt.Errorf("batch_window_seconds must be between 0 and %d", int(maxBatchWindow.Seconds()))
+	}
+	return nil
+}
+
+// QuietUntil reports whether now falls in the quiet hours and, if so, when they end.
+// Quiet hours may span midnight, e.g. 22:00 to 07:00.
+func (s *NotificationSettings) QuietUntil(now time.Time) (time.Time, bool) {
+	if s.QuietHoursStart == "" {
+		return time.Time{}, false
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	start, errStart := time.Parse(quietHoursLayout, s.QuietHoursStart)
+	end, errEnd := time.Parse(quietHoursLayout, s.QuietHoursEnd)
+	if errStart != nil || errEnd != nil {
+		return time.Time{}, false
+	}
+
+	local := now.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	at := func(day int, t time.Time) time.Time {
+		return midnight.AddDate(0, 0, day).Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute)
+	}
+
+	startToday, endToday := at(0, start), at(0, end)
+	if start.Before(end) {
+		if !local.Before(startToday) && local.Before(endToday) {
+			return endToday, true
+		}
+		return time.Time{}, false
+	}
+	// Spans midnight: quiet from start today until end tomorrow, or since start yesterday until end today
+	if !local.Before(startToday) {
+		return at(1, end), true
+	}
+	if local.Before(endToday) {
+		return endToday, true
+	}
+	return time.Time{}, false
+}
+
+const notificationSettingsColumns = `user_id, quiet_hours_start, quiet_hours_end, timezone, batch_window_seconds, updated_at`
+
+// GetNotificationSettings returns the user's settings, or the defaults if they never saved any
+func (db *DB) GetNotificationSettings(ctx context.Context, userID string) (*NotificationSettings, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetNotificationSettings",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_notification_settings"),
+			attribute.String("user.id", userID),
+		))
+	defer span.End()
+
+	s := &NotificationSettings{}
+	err := db.conn.QueryRowContext(ctx, `SELECT `+notificationSettingsColumns+` FROM notification_settings WHERE user_id = ?`, userID).
+		Scan(&s.UserID, &s.QuietHoursStart, &s.QuietHoursEnd, &s.Timezone, &s.BatchWindowSeconds, &s.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return defaultNotificationSettings(userID), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (db *DB) SaveNotificationSettings(ctx context.Context, s *NotificationSettings) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.SaveNotificationSettings",
+		trace.WithAttributes(
+			attribute.String("db.operation", "upsert_notification_settings"),
+			attribute.String("user.id", s.UserID),
+		))
+	defer span.End()
+
+	s.UpdatedAt = time.Now().UTC()
+	_, err := db.conn.ExecContext(ctx, `
+	INSERT INTO notification_settings (`+notificationSettingsColumns+`) VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT (user_id) DO UPDATE SET
+		quiet_hours_start = excluded.quiet_hours_start,
+		quiet_hours_end = excluded.quiet_hours_end,
+		timezone = excluded.timezone,
+		batch_window_seconds = excluded.batch_window_seconds,
+		updated_at = excluded.updated_at`,
+		s.UserID, s.QuietHoursStart, s.QuietHoursEnd, s.Timezone, s.BatchWindowSeconds, s.UpdatedAt)
+	return err
+}
+
+// QueuedNotification is a notification held back by quiet hours or a batch window.
+// The task is stored as it was when the event happened.
+type QueuedNotification struct {
+	ID           int
+	UserID       string
+	RuleID       int
+	Notifier     string
+	Target       string
+	Event        string
+	Task         Task
+	DeliverAfter time.Time
+}
+
+// NotificationGroup is everything queued for one user, notifier and target; a group is
+// delivered together as a digest
+type NotificationGroup struct {
+	UserID   string
+	Notifier string
+	Target   string
+}
+
+// QueueNotification holds a notification until deliverAfter
+func (db *DB) QueueNotification(ctx context.Context, n QueuedNotification) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.QueueNotification",
+		trace.WithAttributes(
+			attribute.String("db.operation", "insert_notification_queue"),
+			attribute.Int("notification.rule_id", n.RuleID),
+		))
+	defer span.End()
+
+	data, err := json.Marshal(n.Task)
+	if err != nil {
+		return err
+	}
+	task, err := SealField(fieldNotificationQueue, string(data))
+	if err != nil {
+		return err
+	}
+	_, err = db.conn.ExecContext(ctx, `
+	INSERT INTO notification_queue (user_id, rule_id, notifier, target, event, task, queued_at, deliver_after)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		n.UserID, n.RuleID, n.Notifier, n.Target, n.Event, task, time.Now().UTC(), n.DeliverAfter.UTC())
+	return err
+}
+
+// PendingDeliverAfter returns when the group's open batch is due, if it has one
+func (db *DB) PendingDeliverAfter(ctx context.Context, g NotificationGroup) (time.Time, bool, error) {
+	var deliverAfter time.Time
+	err := db.conn.QueryRowContext(ctx, `
+	SELECT deliver_after FROM notification_queue WHERE user_id = ? AND notifier = ? AND target = ?
+	ORDER BY deliver_after LIMIT 1`,
+		g.UserID, g.Notifier, g.Target).Scan(&deliverAfter)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return deliverAfter, true, nil
+}
+
+// DueNotificationGroups returns the groups holding at least one notification due by now
+func (db *DB) DueNotificationGroups(ctx context.Context, now time.Time) ([]NotificationGroup, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.DueNotificationGroups",
+		trace.WithAttributes(attribute.String("db.operation", "select_notification_queue")))
+	defer span.End()
+
+	query := `SELECT DISTINCT user_id, notifier, target FROM notification_queue WHERE deliver_after <= ?`
+	start := time.Now()
+	rows, err := db.conn.QueryContext(ctx, query, now.UTC())
+	db.checkSlowQuery(ctx, start, query, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []NotificationGroup
+	for rows.Next() {
+		var g NotificationGroup
+		if err := rows.Scan(&g.UserID, &g.Notifier, &g.Target); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// QueuedNotifications returns the group's notifications in arrival order
+func (db *DB) QueuedNotifications(ctx context.Context, g NotificationGroup) ([]QueuedNotification, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+	SELECT id, user_id, rule_id, notifier, target, event, task, deliver_after FROM notification_queue
+	WHERE user_id = ? AND notifier = ? AND target = ? ORDER BY id`, g.UserID, g.Notifier, g.Target)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var queued []QueuedNotification
+	for rows.Next() {
+		var n QueuedNotification
+		var task string
+		if err := rows.Scan(&n.ID, &n.UserID, &n.RuleID, &n.Notifier, &n.Target, &n.Event, sealedString{fieldNotificationQueue, &task}, &n.DeliverAfter); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(task), &n.Task); err != nil {
+			return nil, err
+		}
+		queued = append(queued, n)
+	}
+	return queued, rows.Err()
+}
+
+// DeleteQueuedNotifications removes delivered notifications, up to and including maxID
+func (db *DB) DeleteQueuedNotifications(ctx context.Context, g NotificationGroup, maxID int) error {
+	_, err := db.conn.ExecContext(ctx, `
+	DELETE FROM notification_queue WHERE user_id = ? AND notifier = ? AND target = ? AND id <= ?`,
+		g.UserID, g.Notifier, g.Target, maxID)
+	return err
+}
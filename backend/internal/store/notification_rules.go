@@ -0,0 +1,158 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NotificationRule routes one task event to a notifier, optionally only for tasks in a
+// list or carrying a tag, e.g. "email me when a #billing task is completed"
+type NotificationRule struct {
+	ID        int       `json:"id"`
+	UserID    string    `json:"user_id"`
+	Event     string    `json:"event"`
+	ListID    *int      `json:"list_id"`
+	Tag       *string   `json:"tag"`
+	Notifier  string    `json:"notifier"`
+	Target    string    `json:"target"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Matches reports whether the rule applies to event on task
+func (r *NotificationRule) Matches(event string, task *Task) bool {
+	if r.Event != event {
+		return false
+	}
+	if r.ListID != nil && *r.ListID != task.ListID {
+		return false
+	}
+	return r.Tag == nil || task.Tags.Has(*r.Tag)
+}
+
+const notificationRuleColumns = `id, user_id, event, list_id, tag, notifier, target, created_at`
+
+func scanNotificationRule(row rowScanner) (*NotificationRule, error) {
+	rule := &NotificationRule{}
+	err := row.Scan(&rule.ID, &rule.UserID, &rule.Event, &rule.ListID, &rule.Tag, &rule.Notifier, &rule.Target, &rule.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (db *DB) GetNotificationRules(ctx context.Context, userID string) ([]NotificationRule, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetNotificationRules",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_notification_rules"),
+			attribute.String("user.id", userID),
+		))
+	defer span.End()
+
+	query := `SELECT ` + notificationRuleColumns + ` FROM notification_rules WHERE user_id = ? ORDER BY id`
+	return db.queryNotificationRules(ctx, db.conn, query, userID)
+}
+
+// GetNotificationRulesFor returns every user's rules for event that apply to tasks in
+// listID. Tag conditions are left to NotificationRule.Matches.
+func (db *DB) GetNotificationRulesFor(ctx context.Context, event string, listID int) ([]NotificationRule, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetNotificationRulesFor",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_notification_rules"),
+			attribute.String("notification.event", event),
+		))
+	defer span.End()
+
+	query := `SELECT ` + notificationRuleColumns + ` FROM notification_rules
+	WHERE event = ? AND (list_id IS NULL OR list_id = ?) ORDER BY id`
+	return db.queryNotificationRules(ctx, db.conn, query, event, listID)
+}
+
+func (db *DB) queryNotificationRules(ctx context.Context, q queryer, query string, args ...any) ([]NotificationRule, error) {
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args...)
+	db.checkSlowQuery(ctx, start, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []NotificationRule{}
+	for rows.Next() {
+		rule, err := scanNotificationRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, rows.Err()
+}
+
+func (db *DB) CreateNotificationRule(ctx context.Context, rule NotificationRule) (*NotificationRule, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.CreateNotificationRule",
+		trace.WithAttributes(
+			attribute.String("db.operation", "insert_notification_rule"),
+			attribute.String("notification.event", rule.Event),
+			attribute.String("notification.notifier", rule.Notifier),
+		))
+	defer span.End()
+
+	if rule.ListID != nil {
+		if _, err := db.resolveListID(ctx, db.conn, rule.ListID); err != nil {
+			return nil, err
+		}
+	}
+
+	query := `INSERT INTO notification_rules (user_id, event, list_id, tag, notifier, target, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING ` + notificationRuleColumns
+	start := time.Now()
+	created, err := scanNotificationRule(db.queryReturning(ctx, db.conn, "notification_rules", 0, query,
+		rule.UserID, rule.Event, rule.ListID, rule.Tag, rule.Notifier, rule.Target, time.Now().UTC()))
+	db.checkSlowQuery(ctx, start, query)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return created, nil
+}
+
+// DeleteNotificationRule removes one of the user's rules, sql.ErrNoRows if there is none
+func (db *DB) DeleteNotificationRule(ctx context.Context, userID string, id int) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.DeleteNotificationRule",
+		trace.WithAttributes(
+			attribute.String("db.operation", "delete_notification_rule"),
+			attribute.Int("notification.rule_id", id),
+		))
+	defer span.End()
+
+	result, err := db.conn.ExecContext(ctx, `DELETE FROM notification_rules WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Events a notification can be about
+const (
+	EventTaskCreated   = "task.created"
+	EventTaskCompleted = "task.completed"
+	// EventTaskUncompleted is published when a completed task is reopened. Like
+	// EventTaskDeleted it goes to live subscribers and webhooks; notification rules do not
+	// fire on it.
+	EventTaskUncompleted = "task.uncompleted"
+	EventTaskDue         = "task.due"
+	// EventTaskStale is only sent by the stale tasks report, not to notification rules
+	EventTaskStale = "task.stale"
+)
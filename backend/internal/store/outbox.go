@@ -0,0 +1,218 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// OutboxInterval is how often the outbox is checked for notifications to deliver
+	OutboxInterval = config.EnvDuration("TODO_OUTBOX_INTERVAL", 2*time.Second)
+	// OutboxMaxAttempts is how many times a notification is tried before it becomes a dead letter
+	OutboxMaxAttempts = config.EnvInt("TODO_OUTBOX_MAX_ATTEMPTS", 5)
+	// OutboxBackoff is the wait after the first failed attempt; it doubles with each one
+	OutboxBackoff = config.EnvDuration("TODO_OUTBOX_BACKOFF", 30*time.Second)
+)
+
+const (
+	// OutboxBatchSize bounds the notifications handed to the outbound queue per run
+	OutboxBatchSize = 100
+	// outboxClaim is how long a notification handed to the outbound queue is left alone.
+	// It is tried again after that if its outcome was never recorded, e.g. because the
+	// replica stopped with it still queued.
+	outboxClaim = 5 * time.Minute
+)
+
+// OutboxEntry is a rule notification waiting in the outbox, with the task as it was when
+// the change was made
+type OutboxEntry struct {
+	ID        int
+	UserID    string
+	RuleID    int
+	Notifier  string
+	Target    string
+	Event     string
+	Task      Task
+	Attempts  int
+	CreatedAt time.Time
+	// TraceID and SpanID are the span of the change that queued the entry
+	TraceID string
+	SpanID  string
+}
+
+// queueOutbox writes an outbox entry for every rule matching event on each task. It runs
+// in the transaction making the change, so a notification is recorded if and only if the
+// change is.
+func (db *DB) queueOutbox(ctx context.Context, q queryer, event string, tasks ...*Task) error {
+	var traceID, spanID any
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		traceID, spanID = sc.TraceID().String(), sc.SpanID().String()
+	}
+	now := time.Now().UTC()
+	for _, task := range tasks {
+		rules, err := db.queryNotificationRules(ctx, q, `SELECT `+notificationRuleColumns+` FROM notification_rules
+		WHERE event = ? AND (list_id IS NULL OR list_id = ?) ORDER BY id`, event, task.ListID)
+		if err != nil {
+			return err
+		}
+		for _, rule := range rules {
+			if !rule.Matches(event, task) {
+				continue
+			}
+			data, err := json.Marshal(task)
+			if err != nil {
+				return err
+			}
+			sealed, err := SealField(fieldNotificationOutbox, string(data))
+			if err != nil {
+				return err
+			}
+			_, err = q.ExecContext(ctx, `
+			INSERT INTO notification_outbox (user_id, rule_id, notifier, target, event, task, next_attempt_at, trace_id, span_id, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				rule.UserID, rule.ID, rule.Notifier, rule.Target, event, sealed, now, traceID, spanID, now)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// outboxColumns are the columns scanOutboxEntries reads
+const outboxColumns = `id, user_id, rule_id, notifier, target, event, task, attempts, trace_id, span_id, created_at`
+
+// ClaimOutbox returns up to limit entries due by now, oldest first, and holds them for
+// outboxClaim so the next run does not pick them up while they are being delivered
+func (db *DB) ClaimOutbox(ctx context.Context, now time.Time, limit int) ([]OutboxEntry, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.ClaimOutbox",
+		trace.WithAttributes(attribute.String("db.operation", "claim_notification_outbox")))
+	defer span.End()
+
+	if db.dialect == dialectMySQL {
+		return db.claimOutboxLocked(ctx, now, limit)
+	}
+
+	query := `UPDATE notification_outbox SET next_attempt_at = ?
+	WHERE id IN (SELECT id FROM notification_outbox WHERE next_attempt_at <= ? ORDER BY next_attempt_at, id LIMIT ?)
+	RETURNING ` + outboxColumns
+	start := time.Now()
+	rows, err := db.conn.QueryContext(ctx, query, now.Add(outboxClaim).UTC(), now.UTC(), limit)
+	db.checkSlowQuery(ctx, start, query, now.Add(outboxClaim).UTC(), now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanOutboxEntries(rows)
+}
+
+// claimOutboxLocked claims entries for databases that can neither limit a subquery of
+// IN nor return updated rows, such as MySQL: the due entries are locked and read, then held
+func (db *DB) claimOutboxLocked(ctx context.Context, now time.Time, limit int) ([]OutboxEntry, error) {
+	var entries []OutboxEntry
+	err := db.WithTx(ctx, "claim_outbox", func(ctx context.Context, tx *sql.Tx) error {
+		query := `SELECT ` + outboxColumns + ` FROM notification_outbox WHERE next_attempt_at <= ?
+		ORDER BY next_attempt_at, id LIMIT ? FOR UPDATE`
+		start := time.Now()
+		rows, err := tx.QueryContext(ctx, query, now.UTC(), limit)
+		db.checkSlowQuery(ctx, start, query, now.UTC(), limit)
+		if err != nil {
+			return err
+		}
+		if entries, err = scanOutboxEntries(rows); err != nil || len(entries) == 0 {
+			return err
+		}
+
+		ids := make([]int, len(entries))
+		for i, e := range entries {
+			ids[i] = e.ID
+		}
+		idsJSON, _ := json.Marshal(ids)
+		_, err = tx.ExecContext(ctx, `UPDATE notification_outbox SET next_attempt_at = ? WHERE id IN (`+db.dialect.jsonIDs(`?`)+`)`,
+			now.Add(outboxClaim).UTC(), string(idsJSON))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// scanOutboxEntries reads and closes rows of outboxColumns
+func scanOutboxEntries(rows *sql.Rows) ([]OutboxEntry, error) {
+	defer rows.Close()
+
+	var entries []OutboxEntry
+	for rows.Next() {
+		var e OutboxEntry
+		var task string
+		var traceID, spanID sql.NullString
+		if err := rows.Scan(&e.ID, &e.UserID, &e.RuleID, &e.Notifier, &e.Target, &e.Event,
+			sealedString{fieldNotificationOutbox, &task}, &e.Attempts, &traceID, &spanID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(task), &e.Task); err != nil {
+			return nil, err
+		}
+		e.TraceID, e.SpanID = traceID.String, spanID.String
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// OutboxDepth returns how many notifications are waiting in the outbox and when the oldest
+// was written, the zero time if there are none
+func (db *DB) OutboxDepth(ctx context.Context) (int64, time.Time, error) {
+	var depth int64
+	if err := db.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM notification_outbox`).Scan(&depth); err != nil {
+		return 0, time.Time{}, err
+	}
+	var oldest time.Time
+	err := db.conn.QueryRowContext(ctx, `SELECT created_at FROM notification_outbox ORDER BY id LIMIT 1`).Scan(&oldest)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, time.Time{}, err
+	}
+	return depth, oldest, nil
+}
+
+// DeleteOutboxEntry removes an entry that has been delivered
+func (db *DB) DeleteOutboxEntry(ctx context.Context, id int) error {
+	_, err := db.conn.ExecContext(ctx, `DELETE FROM notification_outbox WHERE id = ?`, id)
+	return err
+}
+
+// RetryOutboxEntry records a failed attempt at an entry and when to make the next
+func (db *DB) RetryOutboxEntry(ctx context.Context, entry *OutboxEntry, next time.Time, cause error) error {
+	_, err := db.conn.ExecContext(ctx, `UPDATE notification_outbox SET attempts = ?, next_attempt_at = ?, last_error = ? WHERE id = ?`,
+		entry.Attempts, next, cause.Error(), entry.ID)
+	return err
+}
+
+// DeadLetterOutboxEntry moves an entry out of attempts to the failed notifications
+func (db *DB) DeadLetterOutboxEntry(ctx context.Context, entry *OutboxEntry, cause error) error {
+	return db.WithTx(ctx, "dead_letter_outbox_entry", func(ctx context.Context, tx *sql.Tx) error {
+		err := db.recordFailedNotification(ctx, tx, FailedNotification{
+			UserID:   entry.UserID,
+			RuleID:   entry.RuleID,
+			Notifier: entry.Notifier,
+			Target:   entry.Target,
+			Event:    entry.Event,
+			Task:     entry.Task,
+			Error:    cause.Error(),
+			Attempts: entry.Attempts,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM notification_outbox WHERE id = ?`, entry.ID)
+		return err
+	})
+}
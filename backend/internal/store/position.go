@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrInvalidPosition is returned when a move targets a position outside the task list
+var ErrInvalidPosition = errors.New("position out of range")
+
+// TaskMove is the body of POST /tasks/{id}/move
+type TaskMove struct {
+	// Position is the zero-based index the task should occupy in the manual order
+	Position *int `json:"position"`
+}
+
+// MoveTask places a task at the given zero-based index of the manual order.
+//
+// Positions are only sort keys: creating and deleting tasks leaves them sparse or
+// negative. A move therefore first reindexes every active task to 0..n-1 in its
+// current order, then shifts the tasks between the old and new index by one. Both
+// steps run in one transaction so concurrent moves never observe a half-shifted list.
+func (db *DB) MoveTask(ctx context.Context, id, position int) (*Task, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.MoveTask",
+		trace.WithAttributes(
+			attribute.String("db.operation", "move_task"),
+			attribute.Int("task.id", id),
+			attribute.Int("task.position", position),
+		))
+	defer span.End()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	count, err := db.reindexPositions(ctx, tx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	var current int
+	err = tx.QueryRowContext(ctx, `SELECT position FROM tasks WHERE id = ? AND deleted_at IS NULL`, id).Scan(&current)
+	if err != nil {
+		return nil, err
+	}
+	if position < 0 || position >= count {
+		return nil, ErrInvalidPosition
+	}
+	span.SetAttributes(attribute.Int("task.previous_position", current))
+
+	if position < current {
+		err = db.logTaskChangesWhere(ctx, tx, `deleted_at IS NULL AND position >= ? AND position < ?`, position, current)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, `UPDATE tasks SET position = position + 1
+			WHERE deleted_at IS NULL AND position >= ? AND position < ?`, position, current)
+		}
+	} else if position > current {
+		err = db.logTaskChangesWhere(ctx, tx, `deleted_at IS NULL AND position > ? AND position <= ?`, current, position)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, `UPDATE tasks SET position = position - 1
+			WHERE deleted_at IS NULL AND position > ? AND position <= ?`, current, position)
+		}
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	query := `UPDATE tasks SET position = ? WHERE id = ? RETURNING ` + taskColumns
+	args := []any{position, id}
+	if position != current {
+		query = `UPDATE tasks SET position = ?, updated_at = ? WHERE id = ? RETURNING ` + taskColumns
+		args = []any{position, time.Now().UTC(), id}
+	}
+	start := time.Now()
+	task, err := scanTask(db.queryReturning(ctx, tx, "tasks", int64(id), query, args...))
+	db.checkSlowQuery(ctx, start, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Positions are reindexed first, so record indexes rather than the stored sort keys
+	if position != current {
+		err = db.recordTaskEvent(ctx, tx, id, TaskEventMoved, map[string]FieldChange{"position": {From: current, To: position}})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return task, tx.Commit()
+}
+
+// reindexPositions rewrites the positions of active tasks as 0..n-1, keeping their
+// current order, and returns n. q must be a transaction.
+func (db *DB) reindexPositions(ctx context.Context, q queryer) (int, error) {
+	query := `SELECT id FROM tasks WHERE deleted_at IS NULL ORDER BY position, id`
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query)
+	db.checkSlowQuery(ctx, start, query)
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for i, id := range ids {
+		result, err := q.ExecContext(ctx, `UPDATE tasks SET position = ?, updated_at = ? WHERE id = ? AND position <> ?`, i, time.Now().UTC(), id, i)
+		if err != nil {
+			return 0, err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return 0, err
+		} else if n > 0 {
+			if err := db.logTaskChange(ctx, q, id); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return len(ids), nil
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/XSAM/otelsql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// openPostgres connects to the PostgreSQL database at dsn, a postgres:// URL or key=value
+// string. Statements are written for SQLite, so the connections rewrite their ? placeholders
+// to PostgreSQL's $1, $2, ... before sending them, and report failover errors to health.
+func openPostgres(dsn string, health *dbHealth, options ...otelsql.Option) (*sql.DB, error) {
+	cfg, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	connector := stdlib.GetConnector(*cfg, stdlib.OptionAfterConnect(func(ctx context.Context, conn *pgx.Conn) error {
+		// Timestamps come back in UTC, as they do from SQLite, rather than in the local zone
+		conn.TypeMap().RegisterType(&pgtype.Type{
+			Name:  "timestamptz",
+			OID:   pgtype.TimestamptzOID,
+			Codec: &pgtype.TimestamptzCodec{ScanLocation: time.UTC},
+		})
+		return nil
+	}))
+	return otelsql.OpenDB(rebindConnector{connector, health}, options...), nil
+}
+
+// postgresDatabase returns the database name in dsn, for telemetry
+func postgresDatabase(dsn string) string {
+	if cfg, err := pgx.ParseConfig(dsn); err == nil {
+		return cfg.Database
+	}
+	return ""
+}
+
+// rebindConnector hands out connections that rebind placeholders
+type rebindConnector struct {
+	driver.Connector
+	health *dbHealth
+}
+
+func (c rebindConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		if IsUnavailable(err) {
+			c.health.markDown(err)
+		}
+		return nil, err
+	}
+	return rebindConn{conn.(*stdlib.Conn), c.health}, nil
+}
+
+// rebindConn is a pgx connection taking SQLite-style placeholders
+type rebindConn struct {
+	*stdlib.Conn
+	health *dbHealth
+}
+
+func (c rebindConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(rebind(query))
+}
+
+func (c rebindConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.Conn.PrepareContext(ctx, rebind(query))
+}
+
+func (c rebindConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result, err := c.Conn.ExecContext(ctx, rebind(query), args)
+	return result, c.health.check(err, query)
+}
+
+func (c rebindConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := c.Conn.QueryContext(ctx, rebind(query), args)
+	return rows, c.health.check(err, query)
+}
+
+// rebind rewrites the ? and ?NNN placeholders of query as $1, $2, ...
+func rebind(query string) string {
+	return rewritePlaceholders(query, func(b *strings.Builder, n int) {
+		fmt.Fprintf(b, "$%d", n)
+	})
+}
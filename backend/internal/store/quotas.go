@@ -0,0 +1,193 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Quotas are checked when something is created; 0 means unlimited. They are soft: lowering
+// one leaves what already exists alone and only refuses new tasks, webhooks or attachments
+// until usage drops below it.
+var (
+	// MaxOpenTasksPerWorkspace caps the open (not completed or deleted) tasks across the lists
+	// of a workspace
+	MaxOpenTasksPerWorkspace = config.EnvInt("TODO_QUOTA_OPEN_TASKS", 0)
+	// MaxWebhooksPerUser caps the webhooks each user registers
+	MaxWebhooksPerUser = config.EnvInt("TODO_QUOTA_WEBHOOKS", 0)
+	// MaxAttachmentBytesPerWorkspace caps the total size of the attachments on the tasks of
+	// a workspace, trashed tasks included until they are purged
+	MaxAttachmentBytesPerWorkspace = config.EnvInt("TODO_QUOTA_ATTACHMENT_BYTES", 0)
+)
+
+// Quota names, for QuotaError.Quota
+const (
+	QuotaOpenTasks       = "open_tasks"
+	QuotaWebhooks        = "webhooks"
+	QuotaAttachmentBytes = "attachment_bytes"
+)
+
+// ErrQuotaExceeded is wrapped by every QuotaError
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// QuotaError is returned when creating something would go over a quota
+type QuotaError struct {
+	Quota string
+	Limit int
+}
+
+func (e *QuotaError) Error() string {
+	switch e.Quota {
+	case QuotaOpenTasks:
+		return fmt.Sprintf("quota exceeded: a workspace may have at most %d open tasks", e.Limit)
+	case QuotaWebhooks:
+		return fmt.Sprintf("quota exceeded: a user may register at most %d webhooks", e.Limit)
+	case QuotaAttachmentBytes:
+		return fmt.Sprintf("quota exceeded: the attachments of a workspace may total at most %d bytes", e.Limit)
+	}
+	return fmt.Sprintf("quota exceeded: %s is limited to %d", e.Quota, e.Limit)
+}
+
+func (e *QuotaError) Unwrap() error { return ErrQuotaExceeded }
+
+// QuotaUsage is how much of one quota is used. Limit is 0 when the quota is unlimited.
+type QuotaUsage struct {
+	Used  int `json:"used"`
+	Limit int `json:"limit"`
+}
+
+// WorkspaceUsage is a workspace's usage of the per-workspace quotas
+type WorkspaceUsage struct {
+	WorkspaceID     int        `json:"workspace_id"`
+	Name            string     `json:"name"`
+	OpenTasks       QuotaUsage `json:"open_tasks"`
+	AttachmentBytes QuotaUsage `json:"attachment_bytes"`
+}
+
+// Usage is a user's usage of the per-user quotas, and that of every workspace, as
+// membership does not limit which workspaces a user works in yet
+type Usage struct {
+	UserID     string           `json:"user_id"`
+	Webhooks   QuotaUsage       `json:"webhooks"`
+	Workspaces []WorkspaceUsage `json:"workspaces"`
+}
+
+// GetUsage reports a user's quota usage
+func (db *DB) GetUsage(ctx context.Context, userID string) (*Usage, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetUsage",
+		trace.WithAttributes(attribute.String("db.operation", "select_usage")))
+	defer span.End()
+
+	usage := &Usage{UserID: userID, Workspaces: []WorkspaceUsage{}}
+	webhooks, err := countWebhooks(ctx, db.conn, userID)
+	if err != nil {
+		return nil, err
+	}
+	usage.Webhooks = QuotaUsage{Used: webhooks, Limit: MaxWebhooksPerUser}
+
+	query := `SELECT workspaces.id, workspaces.name,
+		(SELECT COUNT(*) FROM tasks JOIN lists ON lists.id = tasks.list_id
+		WHERE lists.workspace_id = workspaces.id AND tasks.completed = FALSE AND tasks.deleted_at IS NULL),
+		(SELECT COALESCE(SUM(attachments.size), 0) FROM attachments
+		JOIN tasks ON tasks.id = attachments.task_id JOIN lists ON lists.id = tasks.list_id
+		WHERE lists.workspace_id = workspaces.id)
+	FROM workspaces
+	ORDER BY workspaces.is_default DESC, workspaces.name`
+	rows, err := db.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		w := WorkspaceUsage{
+			OpenTasks:       QuotaUsage{Limit: MaxOpenTasksPerWorkspace},
+			AttachmentBytes: QuotaUsage{Limit: MaxAttachmentBytesPerWorkspace},
+		}
+		if err := rows.Scan(&w.WorkspaceID, &w.Name, &w.OpenTasks.Used, &w.AttachmentBytes.Used); err != nil {
+			return nil, err
+		}
+		usage.Workspaces = append(usage.Workspaces, w)
+	}
+	return usage, rows.Err()
+}
+
+// checkOpenTaskQuota returns a QuotaError if the workspace of a list has no room for
+// another open task
+func checkOpenTaskQuota(ctx context.Context, q queryer, listID int) error {
+	if MaxOpenTasksPerWorkspace <= 0 {
+		return nil
+	}
+	var open int
+	err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM tasks
+	JOIN lists ON lists.id = tasks.list_id
+	WHERE lists.workspace_id = (SELECT workspace_id FROM lists WHERE id = ?)
+		AND tasks.completed = FALSE AND tasks.deleted_at IS NULL`, listID).Scan(&open)
+	if err != nil {
+		return err
+	}
+	if open >= MaxOpenTasksPerWorkspace {
+		return quotaExceeded(ctx, QuotaOpenTasks, MaxOpenTasksPerWorkspace)
+	}
+	return nil
+}
+
+// checkWebhookQuota returns a QuotaError if a user has no room for another webhook
+func checkWebhookQuota(ctx context.Context, q queryer, userID string) error {
+	if MaxWebhooksPerUser <= 0 {
+		return nil
+	}
+	count, err := countWebhooks(ctx, q, userID)
+	if err != nil {
+		return err
+	}
+	if count >= MaxWebhooksPerUser {
+		return quotaExceeded(ctx, QuotaWebhooks, MaxWebhooksPerUser)
+	}
+	return nil
+}
+
+// checkAttachmentQuota returns sql.ErrNoRows if the task does not exist or is in the
+// trash, and a QuotaError if its workspace has no room for size more bytes of attachments
+func checkAttachmentQuota(ctx context.Context, q queryer, taskID int, size int64) error {
+	if err := taskExists(ctx, q, taskID); err != nil {
+		return err
+	}
+	if MaxAttachmentBytesPerWorkspace <= 0 {
+		return nil
+	}
+	var used int64
+	err := q.QueryRowContext(ctx, `SELECT COALESCE(SUM(attachments.size), 0) FROM attachments
+	JOIN tasks ON tasks.id = attachments.task_id
+	JOIN lists ON lists.id = tasks.list_id
+	WHERE lists.workspace_id = (SELECT lists.workspace_id FROM tasks JOIN lists ON lists.id = tasks.list_id WHERE tasks.id = ?)`,
+		taskID).Scan(&used)
+	if err != nil {
+		return err
+	}
+	if used+size > int64(MaxAttachmentBytesPerWorkspace) {
+		return quotaExceeded(ctx, QuotaAttachmentBytes, MaxAttachmentBytesPerWorkspace)
+	}
+	return nil
+}
+
+func countWebhooks(ctx context.Context, q queryer, userID string) (int, error) {
+	var count int
+	err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM webhooks WHERE user_id = ?`, userID).Scan(&count)
+	return count, err
+}
+
+// quotaExceeded records a refusal on the span and returns its QuotaError
+func quotaExceeded(ctx context.Context, quota string, limit int) error {
+	trace.SpanFromContext(ctx).AddEvent("quota.exceeded",
+		trace.WithAttributes(
+			attribute.String("quota", quota),
+			attribute.Int("quota.limit", limit),
+		))
+	return &QuotaError{Quota: quota, Limit: limit}
+}
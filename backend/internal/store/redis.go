@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"todo-app/internal/config"
+
+	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// redisURL points at a Redis server shared by the replicas, e.g. redis://:password@host:6379/0;
+	// empty keeps the shared state in memory and the database
+	redisURL = config.EnvString("TODO_REDIS_URL", "")
+	// redisPrefix is put in front of every key, so several deployments can share a server
+	redisPrefix = config.EnvString("TODO_REDIS_PREFIX", "todo:")
+)
+
+// NewRedis connects to TODO_REDIS_URL, or returns nil when it is not set. Every command is
+// traced as a client span and measured by redisotel, so cache and rate limit lookups show
+// up in the request traces next to the database queries.
+func NewRedis(ctx context.Context) (*redis.Client, error) {
+	if redisURL == "" {
+		return nil, nil
+	}
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TODO_REDIS_URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+	if err := redisotel.InstrumentTracing(client); err != nil {
+		return nil, err
+	}
+	if err := redisotel.InstrumentMetrics(client); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connecting to Redis: %w", err)
+	}
+	return client, nil
+}
+
+// RedisKey builds a key from the prefix, a kind and parts that may contain any character
+func RedisKey(kind string, parts ...string) string {
+	key := redisPrefix + kind
+	for _, part := range parts {
+		key += ":" + strconv.Quote(part)
+	}
+	return key
+}
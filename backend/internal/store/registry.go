@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var errUnknownStore = errors.New("unknown store")
+
+// StoreFactory opens a TaskStore. db is the database the rest of the app uses, already
+// migrated; a store may keep what it needs there or ignore it. The server closes the store
+// on exit, unless it is db itself.
+type StoreFactory func(ctx context.Context, db *DB) (TaskStore, error)
+
+var (
+	storesMu sync.RWMutex
+	stores   = map[string]StoreFactory{}
+)
+
+// RegisterStore makes a storage backend selectable with TODO_STORE=name. It is meant to be
+// called from the init function of the backend's package, which the server then imports for
+// its side effect, as database/sql drivers are registered. It panics when name is taken.
+func RegisterStore(name string, factory StoreFactory) {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	if factory == nil {
+		panic("store: RegisterStore factory is nil")
+	}
+	if _, taken := stores[name]; taken {
+		panic("store: RegisterStore called twice for " + name)
+	}
+	stores[name] = factory
+}
+
+// OpenStore opens the store registered as name
+func OpenStore(ctx context.Context, name string, db *DB) (TaskStore, error) {
+	storesMu.RLock()
+	factory, ok := stores[name]
+	storesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q, expected one of %s", errUnknownStore, name, strings.Join(StoreNames(), ", "))
+	}
+	return factory(ctx, db)
+}
+
+// IsRegisteredStore reports whether a store is registered as name
+func IsRegisteredStore(name string) bool {
+	storesMu.RLock()
+	defer storesMu.RUnlock()
+	_, ok := stores[name]
+	return ok
+}
+
+// StoreNames lists the registered stores
+func StoreNames() []string {
+	storesMu.RLock()
+	defer storesMu.RUnlock()
+	names := make([]string, 0, len(stores))
+	for name := range stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// The built-in stores
+func init() {
+	RegisterStore(StoreSQL, func(_ context.Context, db *DB) (TaskStore, error) {
+		return db, nil
+	})
+	RegisterStore(StoreMemory, func(context.Context, *DB) (TaskStore, error) {
+		return NewMemoryStore()
+	})
+}
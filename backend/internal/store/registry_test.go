@@ -0,0 +1,46 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"todo-app/internal/store"
+	"todo-app/internal/store/storetest"
+)
+
+// sharedStore is a backend as a third party would register it, keeping its tasks in the
+// database the server hands its factory
+type sharedStore struct {
+	store.TaskStore
+}
+
+func init() {
+	store.RegisterStore("test_shared", func(_ context.Context, db *store.DB) (store.TaskStore, error) {
+		return sharedStore{db}, nil
+	})
+}
+
+func TestRegisteredStore(t *testing.T) {
+	if !slices.Contains(store.StoreNames(), "test_shared") {
+		t.Fatalf("StoreNames() = %v, want test_shared among them", store.StoreNames())
+	}
+	storetest.TestStore(t, func(t *testing.T) store.TaskStore {
+		db := newTestDB(t, filepath.Join(t.TempDir(), "tasks.db"))
+		s, err := store.OpenStore(context.Background(), "test_shared", db)
+		if err != nil {
+			t.Fatalf("OpenStore: %v", err)
+		}
+		if _, ok := s.(sharedStore); !ok {
+			t.Fatalf("OpenStore returned %T, want the registered sharedStore", s)
+		}
+		return s
+	})
+}
+
+func TestOpenUnknownStore(t *testing.T) {
+	if _, err := store.OpenStore(context.Background(), "no_such_store", nil); err == nil {
+		t.Fatal("OpenStore of an unregistered name succeeded")
+	}
+}
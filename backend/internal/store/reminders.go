@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ReminderLead is how far ahead of its due date a task is reminded about
+var ReminderLead = config.EnvDuration("TODO_REMINDER_LEAD", time.Hour)
+
+// ReminderInterval is how often the reminder job scans for tasks due soon
+var ReminderInterval = config.EnvDuration("TODO_REMINDER_INTERVAL", time.Minute)
+
+// ReminderBatchSize caps how many reminders a single run sends
+var ReminderBatchSize = config.EnvInt("TODO_REMINDER_BATCH_SIZE", 100)
+
+// GetTasksDueForReminder returns open tasks that have not been reminded about yet and are
+// due within ReminderLead of now, or past their own remind_at for those created with a
+// list's reminder offset, soonest due first
+func (db *DB) GetTasksDueForReminder(ctx context.Context, now time.Time, limit int) ([]Task, error) {
+	dueBefore := now.Add(ReminderLead)
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetTasksDueForReminder",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_due_tasks"),
+			attribute.String("reminder.due_before", dueBefore.UTC().Format(time.RFC3339)),
+		))
+	defer span.End()
+
+	query := `SELECT ` + taskColumns + ` FROM tasks
+	WHERE deleted_at IS NULL AND completed = FALSE AND due_at IS NOT NULL AND reminded_at IS NULL
+		AND ((remind_at IS NULL AND due_at <= ?) OR remind_at <= ?)
+	ORDER BY due_at LIMIT ?`
+	start := time.Now()
+	defer func() { db.checkSlowQuery(ctx, start, query, dueBefore.UTC(), now.UTC(), limit) }()
+	return db.selectTasks(ctx, db.conn, query, dueBefore.UTC(), now.UTC(), limit)
+}
+
+// MarkReminded records that a reminder was sent so the task is not reminded again
+// until its due date changes
+func (db *DB) MarkReminded(ctx context.Context, id int, at time.Time) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.MarkReminded",
+		trace.WithAttributes(
+			attribute.String("db.operation", "update_task"),
+			attribute.Int("task.id", id),
+		))
+	defer span.End()
+
+	_, err := db.conn.ExecContext(ctx, `UPDATE tasks SET reminded_at = ? WHERE id = ?`, at.UTC(), id)
+	return err
+}
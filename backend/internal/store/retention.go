@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Retention actions, for TODO_RETENTION_ACTION
+const (
+	// RetentionArchive moves old completed tasks to the trash, where they stay restorable
+	// until the trash purge removes them
+	RetentionArchive = "archive"
+	// RetentionDelete removes old completed tasks at once, leaving only their tombstones
+	RetentionDelete = "delete"
+)
+
+// RetentionDays is how many days a completed task is kept after it was completed; 0, the
+// default, keeps completed tasks for ever
+var RetentionDays = config.EnvInt("TODO_RETENTION_DAYS", 0)
+
+// RetentionAction is what the retention job does with old completed tasks
+var RetentionAction = config.EnvString("TODO_RETENTION_ACTION", RetentionArchive)
+
+// RetentionDryRun makes the retention job count the tasks it would remove without removing them
+var RetentionDryRun = config.EnvBool("TODO_RETENTION_DRY_RUN", false)
+
+// RetentionInterval is how often the retention job runs
+var RetentionInterval = config.EnvDuration("TODO_RETENTION_INTERVAL", time.Hour)
+
+// retentionWhere matches the live tasks completed before a cutoff
+const retentionWhere = `deleted_at IS NULL AND completed = TRUE AND completed_at IS NOT NULL AND completed_at < ?`
+
+// PurgeCompletedTasks archives or deletes, by action, the tasks completed before cutoff and
+// returns how many it removed. With dryRun it changes nothing and returns how many it would
+// have removed. Archived tasks go through the trash like any deleted task; a claimed task is
+// left for the next run.
+func (db *DB) PurgeCompletedTasks(ctx context.Context, cutoff time.Time, action string, dryRun bool) (int64, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.PurgeCompletedTasks",
+		trace.WithAttributes(
+			attribute.String("db.operation", "purge_completed_tasks"),
+			attribute.String("purge.cutoff", cutoff.UTC().Format(time.RFC3339)),
+			attribute.String("purge.action", action),
+			attribute.Bool("purge.dry_run", dryRun),
+		))
+	defer span.End()
+
+	if action != RetentionArchive && action != RetentionDelete {
+		return 0, fmt.Errorf("unknown retention action %q, expected %q or %q", action, RetentionArchive, RetentionDelete)
+	}
+	cutoff = cutoff.UTC()
+
+	var purged int64
+	err := db.WithTx(ctx, "purge_completed_tasks", func(ctx context.Context, tx *sql.Tx) error {
+		if dryRun {
+			return tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM tasks WHERE `+retentionWhere, cutoff).Scan(&purged)
+		}
+		if action == RetentionDelete {
+			// The changelog has to name the tasks while they still exist
+			if err := db.logTaskChangesWhere(ctx, tx, retentionWhere, cutoff); err != nil {
+				return err
+			}
+			var err error
+			purged, err = db.purgeTasksWhere(ctx, tx, retentionWhere, cutoff)
+			return err
+		}
+
+		ids, err := selectIDs(ctx, tx, `SELECT id FROM tasks WHERE `+retentionWhere+` ORDER BY id`, cutoff)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := db.deleteTask(ctx, tx, id); errors.Is(err, ErrTaskClaimed) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			purged++
+		}
+		return nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, err
+	}
+	span.SetAttributes(attribute.Int64("purge.count", purged))
+	return purged, nil
+}
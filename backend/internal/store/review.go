@@ -0,0 +1,238 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ReviewInterval is how long a task kept in a weekly review stays out of the queue, and
+	// how far a deferral without a date defers it
+	ReviewInterval = config.EnvDuration("TODO_REVIEW_INTERVAL", 7*24*time.Hour)
+	// reviewPriorityTags are the tags that give a task a priority; an open task with none of
+	// them is unprioritized
+	reviewPriorityTags = config.SplitList(config.EnvString("TODO_REVIEW_PRIORITY_TAGS", "p1,p2,p3"))
+)
+
+// Reasons a task needs attention in the review, in the order the queue presents them
+const (
+	ReviewOverdue       = "overdue"
+	ReviewStale         = "stale"
+	ReviewNoDueDate     = "no_due_date"
+	ReviewUnprioritized = "unprioritized"
+)
+
+var reviewReasons = []string{ReviewOverdue, ReviewStale, ReviewNoDueDate, ReviewUnprioritized}
+
+// Review decisions
+const (
+	ReviewKeep   = "keep"
+	ReviewDefer  = "defer"
+	ReviewDelete = "delete"
+)
+
+var (
+	// ErrInvalidReviewDecision is returned for a decision other than keep, defer or delete
+	ErrInvalidReviewDecision = errors.New("invalid review decision")
+	// ErrDeferInPast is returned when a task is deferred to a time that has passed
+	ErrDeferInPast = errors.New("a task can only be deferred to a future time")
+)
+
+// ReviewItem is a task in the review queue with the reasons it needs attention
+type ReviewItem struct {
+	Task    Task     `json:"task"`
+	Reasons []string `json:"reasons"`
+}
+
+// ReviewDecision is a user's decision on a task in the review. The task is out of their
+// queue until ReviewAfter.
+type ReviewDecision struct {
+	TaskID      int       `json:"task_id"`
+	Decision    string    `json:"decision"`
+	ReviewAfter time.Time `json:"review_after"`
+	DecidedAt   time.Time `json:"decided_at"`
+	// Task is the task after the decision, absent once deleted
+	Task *Task `json:"task,omitempty"`
+}
+
+// reviewReasonsOf returns why an open task needs attention as of now, in queue order
+func reviewReasonsOf(t Task, now time.Time) []string {
+	reasons := []string{}
+	if t.DueAt != nil && t.DueAt.Before(now) {
+		reasons = append(reasons, ReviewOverdue)
+	}
+	if t.Stale {
+		reasons = append(reasons, ReviewStale)
+	}
+	if t.DueAt == nil {
+		reasons = append(reasons, ReviewNoDueDate)
+	}
+	if !slices.ContainsFunc(t.Tags, func(tag string) bool { return slices.Contains(reviewPriorityTags, tag) }) {
+		reasons = append(reasons, ReviewUnprioritized)
+	}
+	return reasons
+}
+
+// GetReviewQueue returns up to limit open tasks needing attention that user has not decided
+// on since they were last due for review. The queue goes by its most pressing reason:
+// overdue tasks, the longest overdue first, then stale tasks, unchanged the longest first,
+// then tasks without a due date and unprioritized ones, oldest first.
+func (db *DB) GetReviewQueue(ctx context.Context, user string, limit int) ([]ReviewItem, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetReviewQueue",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_review_queue"),
+			attribute.String("user.id", user),
+		))
+	defer span.End()
+
+	now := time.Now().UTC()
+	query := `SELECT ` + prefixedTaskColumns("t") + ` FROM tasks t
+	LEFT JOIN review_decisions d ON d.task_id = t.id AND d.user_id = ?
+	WHERE t.deleted_at IS NULL AND t.completed = FALSE AND (d.task_id IS NULL OR d.review_after <= ?)
+	ORDER BY t.id`
+	start := time.Now()
+	tasks, err := db.selectTasks(ctx, db.conn, query, user, now)
+	db.checkSlowQuery(ctx, start, query, user, now)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	items := []ReviewItem{}
+	for _, task := range tasks {
+		if reasons := reviewReasonsOf(task, now); len(reasons) > 0 {
+			items = append(items, ReviewItem{Task: task, Reasons: reasons})
+		}
+	}
+	slices.SortStableFunc(items, func(a, b ReviewItem) int {
+		if c := slices.Index(reviewReasons, a.Reasons[0]) - slices.Index(reviewReasons, b.Reasons[0]); c != 0 {
+			return c
+		}
+		switch a.Reasons[0] {
+		case ReviewOverdue:
+			return a.Task.DueAt.Compare(*b.Task.DueAt)
+		case ReviewStale:
+			return a.Task.UpdatedAt.Compare(b.Task.UpdatedAt)
+		}
+		return a.Task.CreatedAt.Compare(b.Task.CreatedAt)
+	})
+	span.SetAttributes(attribute.Int("review.queue", len(items)))
+	items = items[:min(limit, len(items))]
+
+	page := make([]Task, len(items))
+	for i := range items {
+		page[i] = items[i].Task
+	}
+	if err := db.attachRelations(ctx, db.conn, page); err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].Task = page[i]
+	}
+	return items, nil
+}
+
+// DecideReview records user's decision on an open task, which takes it out of their review
+// queue: keep and delete until ReviewInterval has passed, defer until until (ReviewInterval
+// from now when nil). Deferring also moves a due date before until to until, so an overdue
+// task stops being overdue; deleting moves the task to the trash. sql.ErrNoRows if the task
+// is not open.
+func (db *DB) DecideReview(ctx context.Context, user string, id int, decision string, until *time.Time) (*ReviewDecision, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.DecideReview",
+		trace.WithAttributes(
+			attribute.String("db.operation", "upsert_review_decision"),
+			attribute.Int("task.id", id),
+			attribute.String("review.decision", decision),
+		))
+	defer span.End()
+
+	now := time.Now().UTC()
+	result := &ReviewDecision{TaskID: id, Decision: decision, DecidedAt: now}
+	switch decision {
+	case ReviewKeep, ReviewDelete:
+		result.ReviewAfter = now.Add(ReviewInterval)
+	case ReviewDefer:
+		result.ReviewAfter = now.Add(ReviewInterval)
+		if until != nil {
+			if !until.After(now) {
+				return nil, ErrDeferInPast
+			}
+			result.ReviewAfter = until.UTC()
+		}
+	default:
+		return nil, ErrInvalidReviewDecision
+	}
+
+	err := db.WithTx(ctx, "decide_review", func(ctx context.Context, tx *sql.Tx) error {
+		task, err := scanTask(tx.QueryRowContext(ctx, `SELECT `+taskColumns+` FROM tasks WHERE id = ? AND deleted_at IS NULL AND completed = FALSE`, id))
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case decision == ReviewDelete:
+			if err := db.deleteTask(ctx, tx, id); err != nil {
+				return err
+			}
+			task = nil
+		case decision == ReviewDefer && task.DueAt != nil && task.DueAt.Before(result.ReviewAfter):
+			if err := db.checkClaim(ctx, tx, id); err != nil {
+				return err
+			}
+			offset, err := taskReminderOffset(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+			before := *task
+			query := `UPDATE tasks SET due_at = ?, remind_at = ?, reminded_at = NULL, updated_at = ? WHERE id = ? RETURNING ` + taskColumns
+			args := []any{result.ReviewAfter, remindAt(&result.ReviewAfter, offset), now, id}
+			start := time.Now()
+			task, err = scanTask(db.queryReturning(ctx, tx, "tasks", int64(id), query, args...))
+			db.checkSlowQuery(ctx, start, query, args...)
+			if err != nil {
+				return err
+			}
+			if err := db.recordTaskEvent(ctx, tx, id, TaskEventUpdated, taskFieldChanges(before, *task)); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `
+		INSERT INTO review_decisions (user_id, task_id, decision, review_after, decided_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, task_id) DO UPDATE SET
+			decision = excluded.decision,
+			review_after = excluded.review_after,
+			decided_at = excluded.decided_at`, user, id, decision, result.ReviewAfter, now)
+		if err != nil {
+			return err
+		}
+
+		if task != nil {
+			tasks := []Task{*task}
+			if err := db.attachRelations(ctx, tx, tasks); err != nil {
+				return err
+			}
+			result.Task = &tasks[0]
+		}
+		return nil
+	})
+	if err != nil {
+		if err != sql.ErrNoRows {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return nil, err
+	}
+	return result, nil
+}
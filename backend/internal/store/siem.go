@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Audit event categories
+const (
+	AuditCategoryTask     = "audit"
+	AuditCategorySecurity = "security"
+)
+
+// AuditEvent is one audit or security event as sent to the SIEM. Task events name the
+// fields that changed but never their values, so task content stays in the app.
+type AuditEvent struct {
+	Time     time.Time         `json:"time"`
+	Category string            `json:"category"`
+	Type     string            `json:"type"`
+	Outcome  string            `json:"outcome"`
+	Severity int               `json:"severity"`
+	Actor    string            `json:"actor,omitempty"`
+	TaskID   int               `json:"task_id,omitempty"`
+	Fields   []string          `json:"fields,omitempty"`
+	TraceID  string            `json:"trace_id,omitempty"`
+	Details  map[string]string `json:"details,omitempty"`
+	// Position is a task event's place in the history, which AuditEventsAfter resumes
+	// from; zero for security events
+	Position int64 `json:"-"`
+}
+
+// AuditEventsAfterCursor returns up to limit task events after the named cursor, and the
+// cursor position after them. A new cursor starts at the latest event, so enabling the
+// export does not replay the whole history.
+func (db *DB) AuditEventsAfterCursor(ctx context.Context, name string, limit int) (int64, []AuditEvent, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.auditEventsAfterCursor",
+		trace.WithAttributes(attribute.String("db.operation", "select_audit_events")))
+	defer span.End()
+
+	var position int64
+	err := db.conn.QueryRowContext(ctx, `SELECT position FROM export_cursors WHERE name = ?`, name).Scan(&position)
+	if err == sql.ErrNoRows {
+		if position, err = db.LatestAuditPosition(ctx); err != nil {
+			return 0, nil, err
+		}
+		return position, nil, db.SetExportCursor(ctx, name, position)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	return db.AuditEventsAfter(ctx, position, limit)
+}
+
+// LatestAuditPosition returns the position of the latest task event, after which
+// AuditEventsAfter returns only new ones
+func (db *DB) LatestAuditPosition(ctx context.Context) (int64, error) {
+	var position int64
+	err := db.conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM task_events`).Scan(&position)
+	return position, err
+}
+
+// AuditEventsAfter returns up to limit task events after position, and the position after
+// them
+func (db *DB) AuditEventsAfter(ctx context.Context, position int64, limit int) (int64, []AuditEvent, error) {
+	query := `SELECT id, task_id, event, changes, actor, trace_id, created_at FROM task_events WHERE id > ? ORDER BY id LIMIT ?`
+	start := time.Now()
+	rows, err := db.conn.QueryContext(ctx, query, position, limit)
+	db.checkSlowQuery(ctx, start, query, position, limit)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	var events []AuditEvent
+	for rows.Next() {
+		var id int64
+		var e AuditEvent
+		var changes string
+		var traceID sql.NullString
+		err := rows.Scan(&id, &e.TaskID, &e.Type, sealedString{fieldTaskEventChanges, &changes}, &e.Actor, &traceID, &e.Time)
+		if err != nil {
+			return 0, nil, err
+		}
+		var fields map[string]FieldChange
+		if err := json.Unmarshal([]byte(changes), &fields); err != nil {
+			return 0, nil, err
+		}
+		for field := range fields {
+			e.Fields = append(e.Fields, field)
+		}
+		sort.Strings(e.Fields)
+		e.Category, e.Outcome, e.TraceID = AuditCategoryTask, "success", traceID.String
+		e.Type = "task." + e.Type
+		e.Severity = 3
+		if e.Type == "task."+TaskEventDeleted || e.Type == "task."+TaskEventPurged {
+			e.Severity = 5
+		}
+		e.Position, position = id, id
+		events = append(events, e)
+	}
+	return position, events, rows.Err()
+}
+
+// SetExportCursor records how far the named export has got
+func (db *DB) SetExportCursor(ctx context.Context, name string, position int64) error {
+	_, err := db.conn.ExecContext(ctx, `
+	INSERT INTO export_cursors (name, position, updated_at) VALUES (?, ?, ?)
+	ON CONFLICT (name) DO UPDATE SET position = excluded.position, updated_at = excluded.updated_at`,
+		name, position, time.Now().UTC())
+	return err
+}
+
+// ExportCursorTime returns when the named cursor was last set, zero when it never was
+func (db *DB) ExportCursorTime(ctx context.Context, name string) (time.Time, error) {
+	var updatedAt time.Time
+	err := db.conn.QueryRowContext(ctx, `SELECT updated_at FROM export_cursors WHERE name = ?`, name).Scan(&updatedAt)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return updatedAt, err
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// NonceStore remembers the nonces of accepted requests until their timestamps fall out of
+// the allowed skew, after which the timestamp check rejects a replay on its own
+type NonceStore interface {
+	// Add records a nonce and reports whether it was new
+	Add(ctx context.Context, key string, now time.Time) (bool, error)
+}
+
+// nonceCache is a NonceStore in memory, for a single replica
+type nonceCache struct {
+	mu     sync.Mutex
+	seen   map[string]time.Time
+	sweep  time.Time
+	maxAge time.Duration
+}
+
+func NewNonceCache(maxAge time.Duration) *nonceCache {
+	return &nonceCache{seen: map[string]time.Time{}, maxAge: maxAge}
+}
+
+func (c *nonceCache) Add(_ context.Context, key string, now time.Time) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.sweep) > c.maxAge {
+		for k, expires := range c.seen {
+			if now.After(expires) {
+				delete(c.seen, k)
+			}
+		}
+		c.sweep = now
+	}
+	if expires, ok := c.seen[key]; ok && now.Before(expires) {
+		return false, nil
+	}
+	// Timestamps up to maxAge in the future are accepted, so remember the nonce for both sides
+	c.seen[key] = now.Add(2 * c.maxAge)
+	return true, nil
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// explainTimeout bounds capturing the plan of a slow query
+const explainTimeout = 250 * time.Millisecond
+
+// checkSlowQuery captures the query plan when a query took longer than the slow
+// threshold and attaches it to the active span and the log, to help spot missing indexes.
+// Every query's duration also goes to the health report.
+func (db *DB) checkSlowQuery(ctx context.Context, start time.Time, query string, args ...any) {
+	elapsed := time.Since(start)
+	telemetry.Health.RecordQuery(query, elapsed)
+	if db.slowQueryThreshold <= 0 || elapsed < db.slowQueryThreshold || ctx.Err() != nil {
+		return
+	}
+
+	span := trace.SpanFromContext(ctx)
+	// The plan is read on another connection while the query's transaction may still hold
+	// the write lock; when the pool is exhausted by writers waiting for that lock, give up
+	// on the plan rather than hold the lock until they time out
+	explainCtx, cancel := context.WithTimeout(ctx, explainTimeout)
+	plan, err := db.explainQueryPlan(explainCtx, query, args...)
+	cancel()
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to capture query plan for slow query", "error", err, "query", query)
+		plan = "unavailable: " + err.Error()
+	}
+
+	span.SetAttributes(attribute.Bool("db.slow_query", true))
+	span.AddEvent("db.slow_query",
+		trace.WithAttributes(
+			attribute.String("db.statement", query),
+			attribute.Float64("duration_ms", float64(elapsed.Microseconds())/1000),
+			attribute.String("db.query_plan", plan),
+		),
+	)
+	slog.WarnContext(ctx, "Slow query detected",
+		"query", query,
+		"duration_ms", elapsed.Milliseconds(),
+		"threshold_ms", db.slowQueryThreshold.Milliseconds(),
+		"query_plan", plan)
+}
+
+// explainQueryPlan runs EXPLAIN QUERY PLAN and renders the result as an indented tree.
+// EXPLAIN only plans the statement, so it is safe to run for INSERT/UPDATE/DELETE too.
+func (db *DB) explainQueryPlan(ctx context.Context, query string, args ...any) (string, error) {
+	switch db.dialect {
+	case dialectPostgres:
+		return db.explainPostgres(ctx, query, args...)
+	case dialectMySQL:
+		return db.explainMySQL(ctx, query, args...)
+	}
+	rows, err := db.conn.QueryContext(ctx, "EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	depth := map[int]int{}
+	var lines []string
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			return "", err
+		}
+		depth[id] = depth[parent] + 1
+		lines = append(lines, fmt.Sprintf("%s%s", strings.Repeat("  ", depth[id]-1), detail))
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// explainPostgres runs EXPLAIN, which PostgreSQL already renders as an indented tree
+func (db *DB) explainPostgres(ctx context.Context, query string, args ...any) (string, error) {
+	rows, err := db.conn.QueryContext(ctx, "EXPLAIN "+query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// explainMySQL runs EXPLAIN and renders each row of its table, one per table read, as the
+// columns that have a value. MariaDB has no tree format, so the table is what both give.
+func (db *DB) explainMySQL(ctx context.Context, query string, args ...any) (string, error) {
+	rows, err := db.conn.QueryContext(ctx, "EXPLAIN "+query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return "", err
+		}
+		var fields []string
+		for i, value := range values {
+			if value.Valid {
+				fields = append(fields, columns[i]+"="+value.String)
+			}
+		}
+		lines = append(lines, strings.Join(fields, " "))
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
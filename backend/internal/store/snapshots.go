@@ -0,0 +1,411 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrSnapshotNameTaken is returned when a snapshot with the same name already exists
+var ErrSnapshotNameTaken = errors.New("snapshot name already exists")
+
+// Snapshot is a named, point-in-time copy of every task
+type Snapshot struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	TaskCount int       `json:"task_count"`
+}
+
+// TaskChange describes a task that exists both in a snapshot and now, but differs
+type TaskChange struct {
+	ID     int      `json:"id"`
+	Fields []string `json:"fields"`
+	Before Task     `json:"before"`
+	After  Task     `json:"after"`
+}
+
+// SnapshotDiff compares a snapshot (before) against the current tasks (after)
+type SnapshotDiff struct {
+	Added   []Task       `json:"added"`
+	Removed []Task       `json:"removed"`
+	Changed []TaskChange `json:"changed"`
+}
+
+// CreateSnapshot copies every current task into a new named snapshot.
+// Tasks are stored as JSON so snapshots keep working as the task schema grows.
+func (db *DB) CreateSnapshot(ctx context.Context, name string) (*Snapshot, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.CreateSnapshot",
+		trace.WithAttributes(
+			attribute.String("db.operation", "insert_snapshot"),
+			attribute.String("snapshot.name", name),
+		))
+	defer span.End()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM snapshots WHERE name = ?`, name).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if exists > 0 {
+		return nil, ErrSnapshotNameTaken
+	}
+
+	tasks, err := db.selectTasks(ctx, tx, `SELECT `+taskColumns+` FROM tasks WHERE deleted_at IS NULL ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{}
+	err = db.queryReturning(ctx, tx, "snapshots", 0,
+		`INSERT INTO snapshots (name, created_at, task_count) VALUES (?, ?, ?) RETURNING id, name, created_at, task_count`,
+		name, time.Now().UTC(), len(tasks),
+	).Scan(&snapshot.ID, &snapshot.Name, &snapshot.CreatedAt, &snapshot.TaskCount)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	for _, task := range tasks {
+		data, err := json.Marshal(task)
+		if err != nil {
+			return nil, err
+		}
+		sealed, err := SealField(fieldSnapshotTask, string(data))
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO snapshot_tasks (snapshot_id, task_id, data) VALUES (?, ?, ?)`,
+			snapshot.ID, task.ID, sealed)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("snapshot.id", snapshot.ID), attribute.Int("snapshot.task_count", snapshot.TaskCount))
+
+	return snapshot, nil
+}
+
+func (db *DB) GetSnapshots(ctx context.Context) ([]Snapshot, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetSnapshots",
+		trace.WithAttributes(attribute.String("db.operation", "select_snapshots")))
+	defer span.End()
+
+	rows, err := db.conn.QueryContext(ctx, `SELECT id, name, created_at, task_count FROM snapshots ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []Snapshot{}
+	for rows.Next() {
+		var s Snapshot
+		if err := rows.Scan(&s.ID, &s.Name, &s.CreatedAt, &s.TaskCount); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+
+	return snapshots, rows.Err()
+}
+
+func (db *DB) DeleteSnapshot(ctx context.Context, id int) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.DeleteSnapshot",
+		trace.WithAttributes(
+			attribute.String("db.operation", "delete_snapshot"),
+			attribute.Int("snapshot.id", id),
+		))
+	defer span.End()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM snapshot_tasks WHERE snapshot_id = ?`, id); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM snapshots WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return tx.Commit()
+}
+
+// DiffSnapshot compares snapshot id against the current tasks
+func (db *DB) DiffSnapshot(ctx context.Context, id int) (*SnapshotDiff, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.DiffSnapshot",
+		trace.WithAttributes(
+			attribute.String("db.operation", "diff_snapshot"),
+			attribute.Int("snapshot.id", id),
+		))
+	defer span.End()
+
+	before, err := db.snapshotTasks(ctx, db.conn, id)
+	if err != nil {
+		return nil, err
+	}
+	after, err := db.selectTasks(ctx, db.conn, `SELECT `+taskColumns+` FROM tasks WHERE deleted_at IS NULL ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := diffTasks(before, after)
+	span.SetAttributes(
+		attribute.Int("diff.added", len(diff.Added)),
+		attribute.Int("diff.removed", len(diff.Removed)),
+		attribute.Int("diff.changed", len(diff.Changed)),
+	)
+	return diff, nil
+}
+
+// RestoreSnapshot makes the current tasks match snapshot id exactly: tasks created since
+// are deleted, deleted tasks are recreated with their original IDs, and changed tasks are
+// reverted. It returns the diff that was undone.
+func (db *DB) RestoreSnapshot(ctx context.Context, id int) (*SnapshotDiff, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.RestoreSnapshot",
+		trace.WithAttributes(
+			attribute.String("db.operation", "restore_snapshot"),
+			attribute.Int("snapshot.id", id),
+		))
+	defer span.End()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := db.snapshotTasks(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	after, err := db.selectTasks(ctx, tx, `SELECT `+taskColumns+` FROM tasks WHERE deleted_at IS NULL ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	diff := diffTasks(before, after)
+
+	for _, task := range diff.Added {
+		if err := db.deleteTask(ctx, tx, task.ID); err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+	for _, task := range diff.Removed {
+		err := db.upsertTask(ctx, tx, task)
+		if err == nil {
+			err = db.recordTaskEvent(ctx, tx, task.ID, TaskEventRestored, nil)
+		}
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+	for _, change := range diff.Changed {
+		err := db.upsertTask(ctx, tx, change.Before)
+		if err == nil {
+			err = db.recordTaskEvent(ctx, tx, change.ID, TaskEventUpdated, taskFieldChanges(change.After, change.Before))
+		}
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(
+		attribute.Int("restore.deleted", len(diff.Added)),
+		attribute.Int("restore.recreated", len(diff.Removed)),
+		attribute.Int("restore.reverted", len(diff.Changed)),
+	)
+
+	return diff, nil
+}
+
+// snapshotTasks loads the tasks stored in a snapshot, or sql.ErrNoRows if it does not exist
+func (db *DB) snapshotTasks(ctx context.Context, q queryer, id int) ([]Task, error) {
+	var exists int
+	if err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM snapshots WHERE id = ?`, id).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	rows, err := q.QueryContext(ctx, `SELECT data FROM snapshot_tasks WHERE snapshot_id = ? ORDER BY task_id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []Task
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(sealedString{fieldSnapshotTask, &data}); err != nil {
+			return nil, err
+		}
+		var task Task
+		if err := json.Unmarshal([]byte(data), &task); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, rows.Err()
+}
+
+// selectTasks runs a query returning taskColumns and scans every row
+func (db *DB) selectTasks(ctx context.Context, q queryer, query string, args ...any) ([]Task, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *task)
+	}
+
+	return tasks, rows.Err()
+}
+
+// upsertTask writes task with its original ID and UUID, inserting it if it no longer
+// exists or taking it out of the trash. Tasks whose list is gone land in the default list. A recreated task is the same task coming back,
+// so its tombstone is removed.
+func (db *DB) upsertTask(ctx context.Context, q queryer, task Task) error {
+	if task.UUID == "" {
+		// Snapshots taken before tasks had UUIDs
+		task.UUID = db.ids.TaskUUID()
+	}
+	title, err := SealField(fieldTaskTitle, task.Title)
+	if err != nil {
+		return err
+	}
+	description, err := SealField(fieldTaskDescription, task.Description)
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx, `
+	INSERT INTO tasks (id, uuid, title, description, completed, created_at, due_at, completed_at, position, parent_id, tags, updated_at, list_id)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
+		COALESCE((SELECT id FROM lists WHERE id = ?), (SELECT id FROM lists WHERE is_default = TRUE)))
+	ON CONFLICT (id) DO UPDATE SET
+		title = excluded.title,
+		description = excluded.description,
+		completed = excluded.completed,
+		created_at = excluded.created_at,
+		due_at = excluded.due_at,
+		completed_at = excluded.completed_at,
+		position = excluded.position,
+		parent_id = excluded.parent_id,
+		list_id = excluded.list_id,
+		tags = excluded.tags,
+		updated_at = excluded.updated_at,
+		deleted_at = NULL`,
+		task.ID, task.UUID, title, description, task.Completed, task.CreatedAt.UTC(), utcTime(task.DueAt), utcTime(task.CompletedAt), task.Position, task.ParentID, task.Tags, time.Now().UTC(), task.ListID)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.ExecContext(ctx, `DELETE FROM task_tombstones WHERE uuid = ?`, task.UUID)
+	return err
+}
+
+// diffTasks computes what changed going from before to after, matching tasks by ID
+func diffTasks(before, after []Task) *SnapshotDiff {
+	diff := &SnapshotDiff{Added: []Task{}, Removed: []Task{}, Changed: []TaskChange{}}
+
+	current := make(map[int]Task, len(after))
+	for _, task := range after {
+		current[task.ID] = task
+	}
+
+	for _, old := range before {
+		now, ok := current[old.ID]
+		if !ok {
+			diff.Removed = append(diff.Removed, old)
+			continue
+		}
+		delete(current, old.ID)
+		if fields := changedTaskFields(old, now); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, TaskChange{ID: old.ID, Fields: fields, Before: old, After: now})
+		}
+	}
+
+	for _, task := range after {
+		if _, ok := current[task.ID]; ok {
+			diff.Added = append(diff.Added, task)
+		}
+	}
+
+	return diff
+}
+
+func changedTaskFields(a, b Task) []string {
+	var fields []string
+	if a.Title != b.Title {
+		fields = append(fields, "title")
+	}
+	if a.Description != b.Description {
+		fields = append(fields, "description")
+	}
+	if a.Completed != b.Completed {
+		fields = append(fields, "completed")
+	}
+	if !equalTimes(a.DueAt, b.DueAt) {
+		fields = append(fields, "due_at")
+	}
+	if !equalTimes(a.CompletedAt, b.CompletedAt) {
+		fields = append(fields, "completed_at")
+	}
+	if strings.Join(a.Tags, ",") != strings.Join(b.Tags, ",") {
+		fields = append(fields, "tags")
+	}
+	return fields
+}
+
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
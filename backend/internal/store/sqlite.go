@@ -0,0 +1,80 @@
+package store
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-app/internal/config"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// sqliteSettings are the pragmas every SQLite connection is opened with. WAL lets readers
+// run alongside a writer, and the busy timeout makes a writer wait for the lock instead of
+// failing at once with "database is locked", so concurrent writes queue up. Transactions
+// also take the write lock when they begin: one that read first and then tried to write
+// would fail without waiting if another had written in between.
+type sqliteSettings struct {
+	JournalMode string
+	BusyTimeout time.Duration
+	// ForeignKeys enforces the REFERENCES clauses. It is off by default because existing
+	// databases may hold rows that violate them.
+	ForeignKeys bool
+}
+
+func loadSQLiteSettings() sqliteSettings {
+	return sqliteSettings{
+		JournalMode: strings.ToUpper(config.EnvString("TODO_SQLITE_JOURNAL_MODE", "WAL")),
+		BusyTimeout: config.EnvDuration("TODO_SQLITE_BUSY_TIMEOUT", 5*time.Second),
+		ForeignKeys: config.EnvBool("TODO_SQLITE_FOREIGN_KEYS", false),
+	}
+}
+
+// sqliteInMemory reports whether dsn is a database in process memory, which has no journal file
+func sqliteInMemory(dsn string) bool {
+	return strings.Contains(dsn, ":memory:") || strings.Contains(dsn, "mode=memory")
+}
+
+// apply adds the settings to dsn as go-sqlite3 connection parameters, so every connection
+// in the pool gets them, and returns the attributes describing the result on the database's
+// spans and metrics. Parameters already in dsn win, and an in-memory database keeps its
+// journal.
+func (s sqliteSettings) apply(dsn string) (string, []attribute.KeyValue) {
+	base, rawQuery, _ := strings.Cut(dsn, "?")
+	params, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// Leave a DSN the driver will reject untouched, so its error names the problem
+		return dsn, nil
+	}
+	// param returns the value of key or an alias the driver also accepts, setting it to def
+	// when neither is present
+	param := func(key, def, alias string) string {
+		for _, k := range []string{key, alias} {
+			if k != "" && params.Has(k) {
+				return params.Get(k)
+			}
+		}
+		if def != "" {
+			params.Set(key, def)
+		}
+		return def
+	}
+
+	journalMode := "MEMORY"
+	if !sqliteInMemory(dsn) {
+		journalMode = strings.ToUpper(param("_journal_mode", s.JournalMode, "_journal"))
+	}
+	busyTimeout, _ := strconv.ParseInt(param("_busy_timeout", strconv.FormatInt(s.BusyTimeout.Milliseconds(), 10), "_timeout"), 10, 64)
+	param("_txlock", "immediate", "")
+	// The driver also takes yes and on
+	foreignKeys := slices.Contains([]string{"1", "true", "yes", "on"}, strings.ToLower(param("_foreign_keys", strconv.FormatBool(s.ForeignKeys), "_fk")))
+
+	return base + "?" + params.Encode(), []attribute.KeyValue{
+		attribute.String("db.sqlite.journal_mode", journalMode),
+		attribute.Int64("db.sqlite.busy_timeout_ms", busyTimeout),
+		attribute.Bool("db.sqlite.foreign_keys", foreignKeys),
+	}
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StaleDays is how many days an open task can go unchanged before it is stale; 0 never
+// marks a task stale
+var StaleDays = config.EnvInt("TODO_STALE_DAYS", 30)
+
+// staleCutoff returns the time before which an open task last changed is stale as of now,
+// and false when StaleDays turns stale tasks off
+func staleCutoff(now time.Time) (time.Time, bool) {
+	if StaleDays <= 0 {
+		return time.Time{}, false
+	}
+	return now.AddDate(0, 0, -StaleDays), true
+}
+
+// setAge fills in AgeDays and Stale as of now. A completed task's age stops at its
+// completion, so it is how long the task took.
+func (t *Task) setAge(now time.Time) {
+	end := now
+	if t.Completed && t.CompletedAt != nil {
+		end = *t.CompletedAt
+	}
+	t.AgeDays = max(int(end.Sub(t.CreatedAt)/(24*time.Hour)), 0)
+	cutoff, ok := staleCutoff(now)
+	t.Stale = ok && !t.Completed && t.UpdatedAt.Before(cutoff)
+}
+
+// GetStaleTasks returns up to limit stale tasks, those unchanged the longest first
+func (db *DB) GetStaleTasks(ctx context.Context, limit int) ([]Task, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetStaleTasks",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_stale_tasks"),
+			attribute.Int("stale.days", StaleDays),
+		))
+	defer span.End()
+
+	cutoff, ok := staleCutoff(time.Now())
+	if !ok {
+		return nil, nil
+	}
+	query := `SELECT ` + taskColumns + ` FROM tasks
+	WHERE deleted_at IS NULL AND completed = FALSE AND updated_at < ?
+	ORDER BY updated_at, id LIMIT ?`
+	start := time.Now()
+	rows, err := db.conn.QueryContext(ctx, query, cutoff.UTC(), limit)
+	db.checkSlowQuery(ctx, start, query, cutoff.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("stale.tasks", len(tasks)))
+	return tasks, nil
+}
@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// statsDayLayout is the format of the days in user_stats
+const statsDayLayout = "2006-01-02"
+
+// UserStats are a user's completion totals and streak. Days are counted in the time zone of
+// the user's notification settings, UTC by default. Every completion counts, so a task
+// uncompleted and completed again counts twice.
+type UserStats struct {
+	UserID         string `json:"user_id"`
+	CompletedTotal int    `json:"completed_total"`
+	CompletedToday int    `json:"completed_today"`
+	// CurrentStreak is how many days in a row, up to today or yesterday, the user completed
+	// a task; a streak not extended today is still current until the day is over
+	CurrentStreak int `json:"current_streak"`
+	LongestStreak int `json:"longest_streak"`
+	// LastCompletedOn is the day of the user's last completion, "" for none
+	LastCompletedOn string `json:"last_completed_on"`
+	Timezone        string `json:"timezone"`
+}
+
+// statsLocation returns the time zone the user's days are counted in
+func statsLocation(ctx context.Context, q queryer, userID string) (*time.Location, error) {
+	timezone := "UTC"
+	err := q.QueryRowContext(ctx, `SELECT timezone FROM notification_settings WHERE user_id = ?`, userID).Scan(&timezone)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.UTC, nil
+	}
+	return loc, nil
+}
+
+// selectUserStats reads the stored stats of a user, zero for a user who never completed a
+// task. lock is appended to the query, " FOR UPDATE" to hold the row until the transaction
+// ends.
+func selectUserStats(ctx context.Context, q queryer, userID, lock string) (*UserStats, error) {
+	stats := &UserStats{UserID: userID}
+	err := q.QueryRowContext(ctx, `
+	SELECT completed_total, completed_today, current_streak, longest_streak, last_completed_on
+	FROM user_stats WHERE user_id = ?`+lock, userID).
+		Scan(&stats.CompletedTotal, &stats.CompletedToday, &stats.CurrentStreak, &stats.LongestStreak, &stats.LastCompletedOn)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// recordCompletion adds a completion at the given time to the user's stats. It is called
+// with every completed event, in the transaction that completes the task, so the stats
+// never need a scan of the task history.
+//
+// The streak depends on the stored day, so the row is read and written back rather than
+// incremented in place. It is created first and then locked, so concurrent completions by
+// the same user on PostgreSQL or MySQL wait for each other instead of both reading the
+// same row and losing one; SQLite has a single writer and needs no lock.
+func (db *DB) recordCompletion(ctx context.Context, q queryer, userID string, at time.Time) error {
+	loc, err := statsLocation(ctx, q, userID)
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx, `
+	INSERT INTO user_stats (user_id, updated_at) VALUES (?, ?) ON CONFLICT (user_id) DO NOTHING`, userID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	lock := ""
+	if db.dialect != dialectSQLite {
+		lock = " FOR UPDATE"
+	}
+	stats, err := selectUserStats(ctx, q, userID, lock)
+	if err != nil {
+		return err
+	}
+
+	day := at.In(loc)
+	today := day.Format(statsDayLayout)
+	switch {
+	case stats.LastCompletedOn >= today:
+		// Also a day behind the last one, after the user moved to an earlier time zone
+		stats.CompletedToday++
+	case stats.LastCompletedOn == day.AddDate(0, 0, -1).Format(statsDayLayout):
+		stats.CurrentStreak++
+		stats.CompletedToday = 1
+		stats.LastCompletedOn = today
+	default:
+		stats.CurrentStreak = 1
+		stats.CompletedToday = 1
+		stats.LastCompletedOn = today
+	}
+	stats.CompletedTotal++
+	stats.LongestStreak = max(stats.LongestStreak, stats.CurrentStreak)
+
+	_, err = q.ExecContext(ctx, `
+	UPDATE user_stats SET completed_total = ?, completed_today = ?, current_streak = ?, longest_streak = ?, last_completed_on = ?, updated_at = ?
+	WHERE user_id = ?`,
+		stats.CompletedTotal, stats.CompletedToday, stats.CurrentStreak, stats.LongestStreak, stats.LastCompletedOn, time.Now().UTC(), userID)
+	return err
+}
+
+// GetUserStats returns a user's completion stats as of now. The stored streak and count for
+// the day are those of the last completion, so they are reset here once that day has passed.
+func (db *DB) GetUserStats(ctx context.Context, userID string) (*UserStats, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetUserStats",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_user_stats"),
+			attribute.String("user.id", userID),
+		))
+	defer span.End()
+
+	loc, err := statsLocation(ctx, db.conn, userID)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := selectUserStats(ctx, db.conn, userID, "")
+	if err != nil {
+		return nil, err
+	}
+	stats.Timezone = loc.String()
+
+	now := time.Now().In(loc)
+	if stats.LastCompletedOn < now.Format(statsDayLayout) {
+		stats.CompletedToday = 0
+		if stats.LastCompletedOn < now.AddDate(0, 0, -1).Format(statsDayLayout) {
+			stats.CurrentStreak = 0
+		}
+	}
+	span.SetAttributes(
+		attribute.Int("stats.completed_total", stats.CompletedTotal),
+		attribute.Int("stats.current_streak", stats.CurrentStreak),
+	)
+	return stats, nil
+}
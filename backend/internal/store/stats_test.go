@@ -0,0 +1,58 @@
+package store_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"todo-app/internal/requestctx"
+	"todo-app/internal/store"
+)
+
+// TestConcurrentCompletionsAllCount completes tasks of one user at once; each must be
+// counted, however the transactions interleave
+func TestConcurrentCompletionsAllCount(t *testing.T) {
+	sources := map[string]string{"sqlite": filepath.Join(t.TempDir(), "tasks.db")}
+	for name, env := range map[string]string{"postgres": "TODO_TEST_POSTGRES_URL", "mysql": "TODO_TEST_MYSQL_URL"} {
+		if dsn := os.Getenv(env); dsn != "" {
+			sources[name] = dsn
+		}
+	}
+	for name, dsn := range sources {
+		t.Run(name, func(t *testing.T) {
+			db := newTestDB(t, dsn)
+			ctx := requestctx.WithUser(context.Background(), "alice")
+
+			const n = 20
+			var ids []int
+			for range n {
+				task, err := db.CreateTask(ctx, store.NewTask{Title: "Count me"})
+				if err != nil {
+					t.Fatalf("CreateTask: %v", err)
+				}
+				ids = append(ids, task.ID)
+			}
+			var wg sync.WaitGroup
+			for _, id := range ids {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := db.CompleteTask(ctx, id); err != nil {
+						t.Errorf("CompleteTask(%d): %v", id, err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			stats, err := db.GetUserStats(ctx, "alice")
+			if err != nil {
+				t.Fatalf("GetUserStats: %v", err)
+			}
+			if stats.CompletedTotal != n || stats.CompletedToday != n || stats.CurrentStreak != 1 {
+				t.Errorf("stats = %+v, want %d completions today on a 1-day streak", stats, n)
+			}
+		})
+	}
+}
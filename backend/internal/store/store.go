@@ -0,0 +1,30 @@
+package store
+
+import "context"
+
+// TaskStore is the storage behind the task API: the REST and gRPC task routes read and
+// write tasks only through it. *DB implements it on SQLite and PostgreSQL; features beyond
+// the core task lifecycle (lists, snapshots, webhooks, ...) still use *DB directly.
+//
+// Implementations return sql.ErrNoRows for a missing task and ErrTaskDeleted, or a
+// *TaskMergedError, for a task UUID that no longer resolves, as *DB does.
+type TaskStore interface {
+	// GetAllTasks returns the tasks not in the trash that match q, in q's order
+	GetAllTasks(ctx context.Context, q TaskQuery) ([]Task, error)
+	// GetTask returns a task not in the trash
+	GetTask(ctx context.Context, id int) (*Task, error)
+	// TaskIDByUUID returns the numeric ID of the task with the given UUID
+	TaskIDByUUID(ctx context.Context, taskUUID string) (int, error)
+	CreateTask(ctx context.Context, input NewTask) (*Task, error)
+	// UpdateTask changes the fields set in update
+	UpdateTask(ctx context.Context, id int, update TaskUpdate) (*Task, error)
+	CompleteTask(ctx context.Context, id int) (*Task, error)
+	UncompleteTask(ctx context.Context, id int) (*Task, error)
+	// DeleteTask moves a task to the trash
+	DeleteTask(ctx context.Context, id int) error
+	// ChangeSeq returns the sequence number of the latest committed change to any task
+	ChangeSeq(ctx context.Context) (int64, error)
+	Close() error
+}
+
+var _ TaskStore = (*DB)(nil)
@@ -0,0 +1,568 @@
+// Package storetest checks that a store.TaskStore behaves as the task API expects: CRUD
+// semantics, a stable order, filters, concurrent use and the errors handlers map to status
+// codes. A storage backend registered with store.RegisterStore can so be tested against
+// the same contract as the built-in ones. Call TestStore from a test in the backend's package:
+//
+//	func TestFirestore(t *testing.T) {
+//		storetest.TestStore(t, func(t *testing.T) store.TaskStore {
+//			return newEmptyFirestore(t)
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"todo-app/internal/store"
+)
+
+// TestStore runs the conformance tests against stores made by newStore, which must return
+// an empty store each time and may register its cleanup with t.Cleanup
+func TestStore(t *testing.T, newStore func(t *testing.T) store.TaskStore) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s store.TaskStore)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"List", testList},
+		{"Update", testUpdate},
+		{"CompleteAndUncomplete", testCompleteAndUncomplete},
+		{"Delete", testDelete},
+		{"ChangeSeq", testChangeSeq},
+		{"Order", testOrder},
+		{"Filters", testFilters},
+		{"Pages", testPages},
+		{"ConcurrentCreates", testConcurrentCreates},
+		{"ConcurrentUpdates", testConcurrentUpdates},
+		{"MissingTasks", testMissingTasks},
+		{"DeletedTasks", testDeletedTasks},
+		{"UpdatedAt", testUpdatedAt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newStore(t))
+		})
+	}
+}
+
+func create(t *testing.T, s store.TaskStore, title string) *store.Task {
+	t.Helper()
+	task, err := s.CreateTask(context.Background(), store.NewTask{Title: title})
+	if err != nil {
+		t.Fatalf("CreateTask(%q): %v", title, err)
+	}
+	return task
+}
+
+func testCreateAndGet(t *testing.T, s store.TaskStore) {
+	ctx := context.Background()
+	due := time.Date(2030, 1, 2, 3, 4, 5, 0, time.FixedZone("", 3600))
+	created, err := s.CreateTask(ctx, store.NewTask{Title: "Buy milk", Description: "Oat", DueAt: &due, Tags: store.Tags{"home"}})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	if created.ID <= 0 || created.UUID == "" {
+		t.Errorf("created task has ID %d and UUID %q, want both set", created.ID, created.UUID)
+	}
+	if created.Title != "Buy milk" || created.Description != "Oat" || !slices.Equal(created.Tags, store.Tags{"home"}) {
+		t.Errorf("created task = %+v, want the title, description and tags given", created)
+	}
+	if created.Completed || created.CompletedAt != nil || created.DeletedAt != nil {
+		t.Errorf("created task = %+v, want it open and not deleted", created)
+	}
+	if created.CreatedAt.IsZero() || created.CreatedAt.Location() != time.UTC {
+		t.Errorf("CreatedAt = %v, want the creation time in UTC", created.CreatedAt)
+	}
+	if created.DueAt == nil || !created.DueAt.Equal(due) || created.DueAt.Location() != time.UTC {
+		t.Errorf("DueAt = %v, want %v in UTC", created.DueAt, due)
+	}
+
+	got, err := s.GetTask(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	if got.ID != created.ID || got.UUID != created.UUID || got.Title != created.Title ||
+		!got.CreatedAt.Equal(created.CreatedAt) || !slices.Equal(got.Tags, created.Tags) {
+		t.Errorf("GetTask = %+v, want %+v", got, created)
+	}
+
+	id, err := s.TaskIDByUUID(ctx, created.UUID)
+	if err != nil || id != created.ID {
+		t.Errorf("TaskIDByUUID = %d, %v, want %d", id, err, created.ID)
+	}
+
+	untagged := create(t, s, "No tags")
+	if untagged.Tags == nil {
+		t.Error("Tags of a task created without any is nil, want empty")
+	}
+}
+
+func testList(t *testing.T, s store.TaskStore) {
+	ctx := context.Background()
+	a := create(t, s, "A")
+	b := create(t, s, "B")
+
+	tasks, err := s.GetAllTasks(ctx, store.TaskQuery{})
+	if err != nil {
+		t.Fatalf("GetAllTasks: %v", err)
+	}
+	if ids := taskIDs(tasks); !slices.Contains(ids, a.ID) || !slices.Contains(ids, b.ID) || len(ids) != 2 {
+		t.Errorf("GetAllTasks = %v, want %d and %d", ids, a.ID, b.ID)
+	}
+
+	tasks, err = s.GetAllTasks(ctx, store.TaskQuery{ListID: &a.ListID})
+	if err != nil {
+		t.Fatalf("GetAllTasks in list %d: %v", a.ListID, err)
+	}
+	if len(tasks) != 2 {
+		t.Errorf("GetAllTasks in list %d returned %d tasks, want 2", a.ListID, len(tasks))
+	}
+}
+
+func testUpdate(t *testing.T, s store.TaskStore) {
+	ctx := context.Background()
+	due := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+	task, err := s.CreateTask(ctx, store.NewTask{Title: "Draft", Description: "Keep me", DueAt: &due})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+
+	title := "Final"
+	tags := store.Tags{"work"}
+	updated, err := s.UpdateTask(ctx, task.ID, store.TaskUpdate{Title: &title, Tags: &tags})
+	if err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+	if updated.Title != "Final" || !slices.Equal(updated.Tags, tags) {
+		t.Errorf("updated task = %+v, want the new title and tags", updated)
+	}
+	if updated.Description != "Keep me" || updated.DueAt == nil || !updated.DueAt.Equal(due) {
+		t.Errorf("updated task = %+v, want the fields not in the update unchanged", updated)
+	}
+
+	cleared, err := s.UpdateTask(ctx, task.ID, store.TaskUpdate{DueAt: store.OptionalTime{Set: true}})
+	if err != nil {
+		t.Fatalf("UpdateTask clearing the due date: %v", err)
+	}
+	if cleared.DueAt != nil {
+		t.Errorf("DueAt = %v after clearing it, want nil", cleared.DueAt)
+	}
+
+	got, err := s.GetTask(ctx, task.ID)
+	if err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	if got.Title != "Final" || got.DueAt != nil {
+		t.Errorf("GetTask after updates = %+v, want the updates kept", got)
+	}
+}
+
+// testUpdatedAt checks that every change moves UpdatedAt on, that reads return it as
+// written, and that an update conditional on an older UpdatedAt is refused
+func testUpdatedAt(t *testing.T, s store.TaskStore) {
+	ctx := context.Background()
+	task := create(t, s, "Versioned")
+	if task.UpdatedAt.IsZero() {
+		t.Fatalf("created task has no UpdatedAt")
+	}
+	got, err := s.GetTask(ctx, task.ID)
+	if err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	if !got.UpdatedAt.Equal(task.UpdatedAt) {
+		t.Errorf("GetTask UpdatedAt = %v, want %v as created", got.UpdatedAt, task.UpdatedAt)
+	}
+
+	last := task.UpdatedAt
+	changes := []struct {
+		name   string
+		change func() (*store.Task, error)
+	}{
+		{"UpdateTask", func() (*store.Task, error) {
+			title := "Versioned twice"
+			return s.UpdateTask(ctx, task.ID, store.TaskUpdate{Title: &title, IfUpdatedAt: &last})
+		}},
+		{"CompleteTask", func() (*store.Task, error) { return s.CompleteTask(ctx, task.ID) }},
+		{"UncompleteTask", func() (*store.Task, error) { return s.UncompleteTask(ctx, task.ID) }},
+	}
+	for _, c := range changes {
+		time.Sleep(time.Millisecond)
+		changed, err := c.change()
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !changed.UpdatedAt.After(last) {
+			t.Errorf("%s UpdatedAt = %v, want after %v", c.name, changed.UpdatedAt, last)
+		}
+		last = changed.UpdatedAt
+	}
+
+	title := "Lost update"
+	_, err = s.UpdateTask(ctx, task.ID, store.TaskUpdate{Title: &title, IfUpdatedAt: &task.UpdatedAt})
+	if !errors.Is(err, store.ErrTaskModified) {
+		t.Errorf("UpdateTask with a stale IfUpdatedAt error = %v, want store.ErrTaskModified", err)
+	}
+	got, err = s.GetTask(ctx, task.ID)
+	if err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	if got.Title != "Versioned twice" || !got.UpdatedAt.Equal(last) {
+		t.Errorf("GetTask after a refused update = %+v, want it unchanged", got)
+	}
+}
+
+func testCompleteAndUncomplete(t *testing.T, s store.TaskStore) {
+	ctx := context.Background()
+	task := create(t, s, "Finish")
+
+	completed, err := s.CompleteTask(ctx, task.ID)
+	if err != nil {
+		t.Fatalf("CompleteTask: %v", err)
+	}
+	if !completed.Completed || completed.CompletedAt == nil {
+		t.Fatalf("completed task = %+v, want Completed and CompletedAt set", completed)
+	}
+	again, err := s.CompleteTask(ctx, task.ID)
+	if err != nil {
+		t.Fatalf("CompleteTask again: %v", err)
+	}
+	if again.CompletedAt == nil || !again.CompletedAt.Equal(*completed.CompletedAt) {
+		t.Errorf("CompletedAt = %v after completing again, want the first completion %v", again.CompletedAt, completed.CompletedAt)
+	}
+
+	reopened, err := s.UncompleteTask(ctx, task.ID)
+	if err != nil {
+		t.Fatalf("UncompleteTask: %v", err)
+	}
+	if reopened.Completed || reopened.CompletedAt != nil {
+		t.Errorf("reopened task = %+v, want Completed and CompletedAt cleared", reopened)
+	}
+}
+
+func testDelete(t *testing.T, s store.TaskStore) {
+	ctx := context.Background()
+	task := create(t, s, "Trash me")
+	kept := create(t, s, "Keep me")
+
+	if err := s.DeleteTask(ctx, task.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if _, err := s.GetTask(ctx, task.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetTask of a deleted task: %v, want sql.ErrNoRows", err)
+	}
+	tasks, err := s.GetAllTasks(ctx, store.TaskQuery{})
+	if err != nil {
+		t.Fatalf("GetAllTasks: %v", err)
+	}
+	if ids := taskIDs(tasks); !slices.Equal(ids, []int{kept.ID}) {
+		t.Errorf("GetAllTasks after a delete = %v, want [%d]", ids, kept.ID)
+	}
+	// A deleted task's UUID still resolves, so clients get 410 rather than 404
+	if id, err := s.TaskIDByUUID(ctx, task.UUID); err != nil || id != task.ID {
+		t.Errorf("TaskIDByUUID of a deleted task = %d, %v, want %d", id, err, task.ID)
+	}
+}
+
+func testChangeSeq(t *testing.T, s store.TaskStore) {
+	ctx := context.Background()
+	last, err := s.ChangeSeq(ctx)
+	if err != nil {
+		t.Fatalf("ChangeSeq: %v", err)
+	}
+	advanced := func(what string) {
+		t.Helper()
+		seq, err := s.ChangeSeq(ctx)
+		if err != nil {
+			t.Fatalf("ChangeSeq after %s: %v", what, err)
+		}
+		if seq <= last {
+			t.Errorf("ChangeSeq = %d after %s, want more than %d", seq, what, last)
+		}
+		last = seq
+	}
+
+	task := create(t, s, "Counted")
+	advanced("CreateTask")
+	title := "Renamed"
+	if _, err := s.UpdateTask(ctx, task.ID, store.TaskUpdate{Title: &title}); err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+	advanced("UpdateTask")
+	if _, err := s.CompleteTask(ctx, task.ID); err != nil {
+		t.Fatalf("CompleteTask: %v", err)
+	}
+	advanced("CompleteTask")
+	if err := s.DeleteTask(ctx, task.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	advanced("DeleteTask")
+}
+
+// testOrder checks each sort order, and that a list reads the same until something
+// changes, so a client paging through it or caching it by change sequence sees no shuffling.
+// The tasks are created within the same second, so ties in created_at must be broken by ID.
+func testOrder(t *testing.T, s store.TaskStore) {
+	ctx := context.Background()
+	var created []int
+	for _, title := range []string{"banana", "Apple", "cherry", "apple"} {
+		created = append(created, create(t, s, title).ID)
+	}
+	newestFirst := slices.Clone(created)
+	slices.Reverse(newestFirst)
+
+	tests := []struct {
+		sort string
+		want []int
+	}{
+		{"", newestFirst},
+		{store.SortCreated, newestFirst},
+		// New tasks go to the top of the manual order
+		{store.SortPosition, newestFirst},
+		// Titles compare without case; equal ones keep the created order
+		{store.SortTitle, []int{created[3], created[1], created[0], created[2]}},
+	}
+	for _, tt := range tests {
+		for range 3 {
+			tasks, err := s.GetAllTasks(ctx, store.TaskQuery{Sort: tt.sort})
+			if err != nil {
+				t.Fatalf("GetAllTasks sorted by %q: %v", tt.sort, err)
+			}
+			if ids := taskIDs(tasks); !slices.Equal(ids, tt.want) {
+				t.Errorf("GetAllTasks sorted by %q = %v, want %v", tt.sort, ids, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func testFilters(t *testing.T, s store.TaskStore) {
+	ctx := context.Background()
+	cafe, err := s.CreateTask(ctx, store.NewTask{Title: "Meet at the Café", Tags: store.Tags{"social", "town"}})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	report, err := s.CreateTask(ctx, store.NewTask{Title: "Write report", Tags: store.Tags{"work"}})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query store.TaskQuery
+		want  []int
+	}{
+		{"search ignoring case and diacritics", store.TaskQuery{Search: "CAFE"}, []int{cafe.ID}},
+		{"search matching nothing", store.TaskQuery{Search: "dentist"}, []int{}},
+		{"tag", store.TaskQuery{Tag: "work"}, []int{report.ID}},
+		{"second tag", store.TaskQuery{Tag: "town"}, []int{cafe.ID}},
+		{"search and tag", store.TaskQuery{Search: "report", Tag: "social"}, []int{}},
+	}
+	for _, tt := range tests {
+		tasks, err := s.GetAllTasks(ctx, tt.query)
+		if err != nil {
+			t.Fatalf("GetAllTasks with %s: %v", tt.name, err)
+		}
+		if ids := taskIDs(tasks); !slices.Equal(ids, tt.want) {
+			t.Errorf("GetAllTasks with %s = %v, want %v", tt.name, ids, tt.want)
+		}
+	}
+}
+
+// testPages checks that a page of a searched, tag-filtered or title-sorted list is the
+// same slice of the whole list as one cut in SQL would be
+func testPages(t *testing.T, s store.TaskStore) {
+	ctx := context.Background()
+	for _, title := range []string{"pear", "Fig", "plum", "apple", "grape", "Peach", "kiwi", "papaya"} {
+		tags := store.Tags{"fruit"}
+		if strings.HasPrefix(strings.ToLower(title), "p") {
+			tags = append(tags, "p")
+		}
+		if _, err := s.CreateTask(ctx, store.NewTask{Title: title, Tags: tags}); err != nil {
+			t.Fatalf("CreateTask(%q): %v", title, err)
+		}
+	}
+
+	for _, query := range []store.TaskQuery{
+		{Search: "p"},
+		{Tag: "p"},
+		{Sort: store.SortTitle},
+		{Sort: store.SortTitle, Search: "a"},
+		{Sort: store.SortTitle, Tag: "fruit"},
+	} {
+		all, err := s.GetAllTasks(ctx, query)
+		if err != nil {
+			t.Fatalf("GetAllTasks(%+v): %v", query, err)
+		}
+		want := taskIDs(all)
+		for offset := 0; offset <= len(want); offset++ {
+			for limit := 1; limit <= 3; limit++ {
+				page := query
+				page.Offset, page.Limit = offset, limit
+				tasks, err := s.GetAllTasks(ctx, page)
+				if err != nil {
+					t.Fatalf("GetAllTasks(%+v): %v", page, err)
+				}
+				end := min(offset+limit, len(want))
+				if ids := taskIDs(tasks); !slices.Equal(ids, want[offset:end]) {
+					t.Errorf("GetAllTasks(%+v) = %v, want %v", page, ids, want[offset:end])
+				}
+			}
+		}
+	}
+}
+
+func testConcurrentCreates(t *testing.T, s store.TaskStore) {
+	ctx := context.Background()
+	const n = 20
+	before, err := s.ChangeSeq(ctx)
+	if err != nil {
+		t.Fatalf("ChangeSeq: %v", err)
+	}
+
+	tasks := make([]*store.Task, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tasks[i], errs[i] = s.CreateTask(ctx, store.NewTask{Title: fmt.Sprintf("Task %d", i)})
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		t.Fatalf("concurrent CreateTask: %v", err)
+	}
+
+	ids := map[int]bool{}
+	uuids := map[string]bool{}
+	for _, task := range tasks {
+		ids[task.ID] = true
+		uuids[task.UUID] = true
+	}
+	if len(ids) != n || len(uuids) != n {
+		t.Errorf("%d concurrent creates got %d IDs and %d UUIDs, want all distinct", n, len(ids), len(uuids))
+	}
+	listed, err := s.GetAllTasks(ctx, store.TaskQuery{})
+	if err != nil {
+		t.Fatalf("GetAllTasks: %v", err)
+	}
+	if len(listed) != n {
+		t.Errorf("GetAllTasks after %d concurrent creates returned %d tasks", n, len(listed))
+	}
+	after, err := s.ChangeSeq(ctx)
+	if err != nil {
+		t.Fatalf("ChangeSeq: %v", err)
+	}
+	if after-before < n {
+		t.Errorf("ChangeSeq advanced by %d over %d creates, want at least one per create", after-before, n)
+	}
+}
+
+// testConcurrentUpdates changes different fields of one task at once: none may be lost
+func testConcurrentUpdates(t *testing.T, s store.TaskStore) {
+	ctx := context.Background()
+	task := create(t, s, "Busy")
+
+	title, description, tags := "Renamed", "Described", store.Tags{"tagged"}
+	updates := []store.TaskUpdate{{Title: &title}, {Description: &description}, {Tags: &tags}}
+	errs := make([]error, len(updates)+1)
+	var wg sync.WaitGroup
+	for i, update := range updates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = s.UpdateTask(ctx, task.ID, update)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, errs[len(updates)] = s.CompleteTask(ctx, task.ID)
+	}()
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		t.Fatalf("concurrent updates: %v", err)
+	}
+
+	got, err := s.GetTask(ctx, task.ID)
+	if err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	if got.Title != title || got.Description != description || !slices.Equal(got.Tags, tags) || !got.Completed {
+		t.Errorf("task after concurrent updates = %+v, want every update applied", got)
+	}
+}
+
+// errorCalls calls each method that takes a task ID, for the error mapping tests
+func errorCalls(s store.TaskStore, id int) map[string]func() error {
+	ctx := context.Background()
+	title := "Missing"
+	return map[string]func() error{
+		"GetTask": func() error { _, err := s.GetTask(ctx, id); return err },
+		"UpdateTask": func() error {
+			_, err := s.UpdateTask(ctx, id, store.TaskUpdate{Title: &title})
+			return err
+		},
+		"CompleteTask":   func() error { _, err := s.CompleteTask(ctx, id); return err },
+		"UncompleteTask": func() error { _, err := s.UncompleteTask(ctx, id); return err },
+		"DeleteTask":     func() error { return s.DeleteTask(ctx, id) },
+	}
+}
+
+// testMissingTasks checks that tasks that never existed are sql.ErrNoRows, which the
+// handlers answer with 404
+func testMissingTasks(t *testing.T, s store.TaskStore) {
+	task := create(t, s, "Exists")
+	missing := task.ID + 1000
+	for name, call := range errorCalls(s, missing) {
+		if err := call(); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("%s of a missing task: %v, want sql.ErrNoRows", name, err)
+		}
+	}
+	if _, err := s.TaskIDByUUID(context.Background(), "00000000-0000-4000-8000-000000000000"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("TaskIDByUUID of an unknown UUID: %v, want sql.ErrNoRows", err)
+	}
+}
+
+// testDeletedTasks checks that a task in the trash answers like a missing one, and is left
+// as it was
+func testDeletedTasks(t *testing.T, s store.TaskStore) {
+	ctx := context.Background()
+	task := create(t, s, "Trashed")
+	if err := s.DeleteTask(ctx, task.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	before, err := s.ChangeSeq(ctx)
+	if err != nil {
+		t.Fatalf("ChangeSeq: %v", err)
+	}
+	for name, call := range errorCalls(s, task.ID) {
+		if err := call(); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("%s of a deleted task: %v, want sql.ErrNoRows", name, err)
+		}
+	}
+	after, err := s.ChangeSeq(ctx)
+	if err != nil {
+		t.Fatalf("ChangeSeq: %v", err)
+	}
+	if after != before {
+		t.Errorf("ChangeSeq moved from %d to %d on failed calls, want it unchanged", before, after)
+	}
+}
+
+func taskIDs(tasks []store.Task) []int {
+	ids := make([]int, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	return ids
+}
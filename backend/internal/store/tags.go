@@ -0,0 +1,244 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MaxTagsPerTask caps how many tags a task may carry
+var MaxTagsPerTask = config.EnvInt("TODO_MAX_TAGS_PER_TASK", 20)
+
+// maxTagLength is the maximum tag length in characters after normalization
+const maxTagLength = 50
+
+// Tags is a task's set of tags, stored as a JSON array in the tasks.tags column
+type Tags []string
+
+func (t Tags) Value() (driver.Value, error) {
+	if t == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal([]string(t))
+	return string(b), err
+}
+
+func (t *Tags) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*t = Tags{}
+		return nil
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return fmt.Errorf("cannot scan %T into Tags", src)
+	}
+	var tags []string
+	if err := json.Unmarshal(b, &tags); err != nil {
+		return err
+	}
+	*t = Tags(tags)
+	if *t == nil {
+		*t = Tags{}
+	}
+	return nil
+}
+
+// MarshalJSON renders a task without tags as [] rather than null
+func (t Tags) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]string(t))
+}
+
+// Has reports whether the set contains tag
+func (t Tags) Has(tag string) bool {
+	for _, v := range t {
+		if v == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeTag canonicalizes one tag like a title, lowercased, with a leading '#' dropped
+func NormalizeTag(raw string) (string, error) {
+	tag, err := NormalizeTitle(strings.TrimPrefix(strings.TrimSpace(raw), "#"))
+	if err != nil {
+		return "", fmt.Errorf("Tags must not be empty")
+	}
+	tag = strings.ToLower(tag)
+	if n := utf8.RuneCountInString(tag); n > maxTagLength {
+		return "", fmt.Errorf("Tags must be at most %d characters, got %d", maxTagLength, n)
+	}
+	return tag, nil
+}
+
+// NormalizeTags normalizes every tag, removes duplicates and sorts the result
+func NormalizeTags(raw []string) (Tags, error) {
+	seen := make(map[string]bool, len(raw))
+	tags := Tags{}
+	for _, r := range raw {
+		tag, err := NormalizeTag(r)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > MaxTagsPerTask {
+		return nil, fmt.Errorf("A task can have at most %d tags, got %d", MaxTagsPerTask, len(tags))
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// filterTasksByTag keeps the tasks carrying tag
+func filterTasksByTag(tasks []Task, tag string) []Task {
+	filtered := tasks[:0]
+	for _, task := range tasks {
+		if task.Tags.Has(tag) {
+			filtered = append(filtered, task)
+		}
+	}
+	return filtered
+}
+
+// Tag operations, for TagOperation.Operation
+const (
+	TagRename = "rename"
+	TagMerge  = "merge"
+)
+
+var (
+	// ErrTagNotFound is returned when no task, in the trash or not, carries the tag to rename
+	// or merge
+	ErrTagNotFound = errors.New("no task carries the tag")
+	// ErrTagExists is returned when renaming a tag to one a task already carries; that is a merge
+	ErrTagExists = errors.New("a task already carries the new tag, merge the tags instead")
+)
+
+// TagOperation is what renaming or merging a tag changed
+type TagOperation struct {
+	Operation string `json:"operation"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	// TaskIDs are the tasks that carried From, trashed ones included
+	TaskIDs []int `json:"task_ids"`
+	// NotificationRules is how many notification rules on From now match To
+	NotificationRules int64 `json:"notification_rules"`
+}
+
+// RenameTag renames the tag from to to on every task, trashed ones included, and in the
+// notification rules. It fails with ErrTagExists when a task already carries to.
+func (db *DB) RenameTag(ctx context.Context, from, to string) (*TagOperation, error) {
+	return db.replaceTag(ctx, TagRename, from, to)
+}
+
+// MergeTags replaces the tag from with into on every task, trashed ones included, and in
+// the notification rules. A task carrying both keeps into once.
+func (db *DB) MergeTags(ctx context.Context, from, into string) (*TagOperation, error) {
+	return db.replaceTag(ctx, TagMerge, from, into)
+}
+
+// replaceTag replaces the normalized tag from with to in one transaction. Each task changed
+// gets an updated event with its old and new tags, which is the operation's audit trail and
+// its change log entry.
+func (db *DB) replaceTag(ctx context.Context, operation, from, to string) (*TagOperation, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.ReplaceTag",
+		trace.WithAttributes(
+			attribute.String("db.operation", "replace_tag"),
+			attribute.String("tag.operation", operation),
+			attribute.String("tag.from", from),
+			attribute.String("tag.to", to),
+		))
+	defer span.End()
+
+	op := &TagOperation{Operation: operation, From: from, To: to, TaskIDs: []int{}}
+	hasTag := `? IN (` + db.dialect.jsonValues(`tasks.tags`) + `)`
+	err := db.WithTx(ctx, "tag_"+operation, func(ctx context.Context, tx *sql.Tx) error {
+		if operation == TagRename {
+			var carriers int
+			if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM tasks WHERE `+hasTag, to).Scan(&carriers); err != nil {
+				return err
+			}
+			if carriers > 0 {
+				return ErrTagExists
+			}
+		}
+
+		rows, err := tx.QueryContext(ctx, `SELECT `+taskColumns+` FROM tasks WHERE `+hasTag+` ORDER BY id`, from)
+		if err != nil {
+			return err
+		}
+		var tasks []Task
+		for rows.Next() {
+			task, err := scanTask(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			tasks = append(tasks, *task)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(tasks) == 0 {
+			return ErrTagNotFound
+		}
+
+		now := time.Now().UTC()
+		for _, task := range tasks {
+			replaced := make([]string, len(task.Tags))
+			for i, tag := range task.Tags {
+				if tag == from {
+					tag = to
+				}
+				replaced[i] = tag
+			}
+			tags, err := NormalizeTags(replaced)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, `UPDATE tasks SET tags = ?, updated_at = ? WHERE id = ?`, tags, now, task.ID); err != nil {
+				return err
+			}
+			if err := db.recordTaskEvent(ctx, tx, task.ID, TaskEventUpdated, map[string]FieldChange{"tags": {task.Tags, tags}}); err != nil {
+				return err
+			}
+			op.TaskIDs = append(op.TaskIDs, task.ID)
+		}
+
+		result, err := tx.ExecContext(ctx, `UPDATE notification_rules SET tag = ? WHERE tag = ?`, to, from)
+		if err != nil {
+			return err
+		}
+		op.NotificationRules, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("tag.tasks", len(op.TaskIDs)))
+	return op, nil
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+// taskStatsCache memoizes GetTaskStats so frequent metric collections stay cheap
+type taskStatsCache struct {
+	db  *DB
+	ttl time.Duration
+
+	mu        sync.Mutex
+	fetchedAt time.Time
+	stats     TaskStats
+}
+
+func (c *taskStatsCache) get(ctx context.Context) (TaskStats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.fetchedAt.IsZero() && time.Since(c.fetchedAt) < c.ttl {
+		return c.stats, nil
+	}
+
+	stats, err := c.db.GetTaskStats(ctx, time.Now())
+	if err != nil {
+		return c.stats, err
+	}
+	c.stats = stats
+	c.fetchedAt = time.Now()
+	return stats, nil
+}
+
+// RegisterTaskGauges exports business state (open, overdue, completed today) as observable gauges
+func RegisterTaskGauges(db *DB, isLeader func() bool) error {
+	meter := telemetry.GetMeter()
+	cache := &taskStatsCache{db: db, ttl: config.EnvDuration("TODO_TASK_STATS_TTL", 30*time.Second)}
+
+	openTasks, err := meter.Int64ObservableGauge("todo_app.tasks.open",
+		metric.WithDescription("Number of tasks that are not completed"),
+		metric.WithUnit("{task}"))
+	if err != nil {
+		return err
+	}
+
+	overdueTasks, err := meter.Int64ObservableGauge("todo_app.tasks.overdue",
+		metric.WithDescription("Number of open tasks whose due date has passed"),
+		metric.WithUnit("{task}"))
+	if err != nil {
+		return err
+	}
+
+	completedToday, err := meter.Int64ObservableGauge("todo_app.tasks.completed_today",
+		metric.WithDescription("Number of tasks completed since local midnight"),
+		metric.WithUnit("{task}"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		// The counts are the same from every replica; only the leader reports them so they
+		// are not summed across the cluster
+		if !isLeader() {
+			return nil
+		}
+		stats, err := cache.get(ctx)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to collect task gauges", "error", err)
+			return err
+		}
+		o.ObserveInt64(openTasks, stats.Open)
+		o.ObserveInt64(overdueTasks, stats.Overdue)
+		o.ObserveInt64(completedToday, stats.CompletedToday)
+		return nil
+	}, openTasks, overdueTasks, completedToday)
+	return err
+}
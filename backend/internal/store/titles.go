@@ -0,0 +1,111 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"todo-app/internal/config"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/text/unicode/norm"
+)
+
+var errTitleEmpty = errors.New("Title is required")
+
+// maxDescriptionLength is the maximum description length in characters after normalization
+var maxDescriptionLength = config.EnvInt("TODO_MAX_DESCRIPTION_LENGTH", 10000)
+
+// maxTitleLength is the maximum title length in characters after normalization
+var maxTitleLength = config.EnvInt("TODO_MAX_TITLE_LENGTH", 500)
+
+// isInvisibleFormatChar reports runes that render as nothing but make otherwise
+// identical titles differ, or reorder text in logs (bidi overrides). Zero-width
+// joiners are kept because emoji sequences and some scripts depend on them.
+func isInvisibleFormatChar(r rune) bool {
+	switch {
+	case r == '\u00AD', // soft hyphen
+		r == '\u200B',                  // zero width space
+		r == '\u2060',                  // word joiner
+		r == '\uFEFF',                  // byte order mark
+		r >= '\u202A' && r <= '\u202E', // bidi embedding/override
+		r >= '\u2066' && r <= '\u2069': // bidi isolates
+		return true
+	}
+	return false
+}
+
+// NormalizeTitle canonicalizes a user-supplied title: Unicode NFC, control and
+// invisible characters removed, whitespace runs collapsed to one space and trimmed.
+// It returns an error when the result is empty, longer than maxTitleLength or starts with
+// the prefix reserved for encrypted values.
+func NormalizeTitle(raw string) (string, error) {
+	var b strings.Builder
+	pendingSpace := false
+	for _, r := range norm.NFC.String(raw) {
+		switch {
+		case unicode.IsSpace(r):
+			pendingSpace = b.Len() > 0
+		case unicode.IsControl(r), isInvisibleFormatChar(r), r == utf8.RuneError:
+			// dropped
+		default:
+			if pendingSpace {
+				b.WriteByte(' ')
+				pendingSpace = false
+			}
+			b.WriteRune(r)
+		}
+	}
+
+	title := b.String()
+	if title == "" {
+		return "", errTitleEmpty
+	}
+	if n := utf8.RuneCountInString(title); n > maxTitleLength {
+		return "", fmt.Errorf("Title must be at most %d characters, got %d", maxTitleLength, n)
+	}
+	if strings.HasPrefix(title, encryptedPrefix) {
+		return "", fmt.Errorf("Title must not start with %q, which marks encrypted values", encryptedPrefix)
+	}
+	return title, nil
+}
+
+// NormalizeDescription canonicalizes a markdown description: Unicode NFC, Windows line
+// endings converted, control and invisible characters removed except newlines and tabs,
+// and surrounding blank space trimmed. Unlike titles, inner whitespace is kept because
+// it is significant in markdown. Like a title, it must not start with the prefix reserved
+// for encrypted values.
+func NormalizeDescription(raw string) (string, error) {
+	raw = strings.ReplaceAll(norm.NFC.String(raw), "\r\n", "\n")
+	description := strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) || isInvisibleFormatChar(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, raw))
+
+	if n := utf8.RuneCountInString(description); n > maxDescriptionLength {
+		return "", fmt.Errorf("Description must be at most %d characters, got %d", maxDescriptionLength, n)
+	}
+	if strings.HasPrefix(description, encryptedPrefix) {
+		return "", fmt.Errorf("Description must not start with %q, which marks encrypted values", encryptedPrefix)
+	}
+	return description, nil
+}
+
+// RecordTitleNormalization keeps the title as submitted on the span when normalization changed it
+func RecordTitleNormalization(span trace.Span, original, normalized string) {
+	if original == normalized {
+		return
+	}
+	span.SetAttributes(
+		attribute.String("task.title.original", original),
+		attribute.Bool("task.title.normalized", true),
+	)
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TrashRetention is how long deleted tasks stay restorable before the purge job removes them
+var TrashRetention = time.Duration(config.EnvInt("TODO_TRASH_RETENTION_DAYS", 30)) * 24 * time.Hour
+
+// TrashPurgeInterval is how often the purge job runs
+var TrashPurgeInterval = config.EnvDuration("TODO_TRASH_PURGE_INTERVAL", time.Hour)
+
+// GetTrash returns deleted tasks that have not been purged yet, most recently deleted first
+func (db *DB) GetTrash(ctx context.Context) ([]Task, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetTrash",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_trash"),
+		))
+	defer span.End()
+
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC`
+	start := time.Now()
+	defer func() { db.checkSlowQuery(ctx, start, query) }()
+	return db.selectTasks(ctx, db.conn, query)
+}
+
+// RestoreTask takes a task out of the trash, or returns sql.ErrNoRows if it is not in the trash
+func (db *DB) RestoreTask(ctx context.Context, id int) (*Task, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.RestoreTask",
+		trace.WithAttributes(
+			attribute.String("db.operation", "restore_task"),
+			attribute.Int("task.id", id),
+		))
+	defer span.End()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE tasks SET deleted_at = NULL, updated_at = ? WHERE id = ? AND deleted_at IS NOT NULL RETURNING ` + taskColumns
+	now := time.Now().UTC()
+	start := time.Now()
+	task, err := scanTask(db.queryReturning(ctx, tx, "tasks", int64(id), query, now, id))
+	db.checkSlowQuery(ctx, start, query, now, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.recordTaskEvent(ctx, tx, id, TaskEventRestored, nil); err != nil {
+		return nil, err
+	}
+	return task, tx.Commit()
+}
+
+// PurgeDeletedTasks permanently removes tasks deleted before cutoff. Each purged task
+// leaves a tombstone so its UUID keeps answering 410 Gone.
+func (db *DB) PurgeDeletedTasks(ctx context.Context, cutoff time.Time) (int64, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.PurgeDeletedTasks",
+		trace.WithAttributes(
+			attribute.String("db.operation", "purge_deleted_tasks"),
+			attribute.String("purge.cutoff", cutoff.UTC().Format(time.RFC3339)),
+		))
+	defer span.End()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	purged, err := db.purgeTasksWhere(ctx, tx, `deleted_at IS NOT NULL AND deleted_at < ?`, cutoff.UTC())
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, err
+	}
+	span.SetAttributes(attribute.Int64("purge.count", purged))
+
+	return purged, tx.Commit()
+}
+
+// purgeTasksWhere permanently removes the tasks matching where, with their dependencies and
+// hook links, and leaves a tombstone for each. A task still live when purged has its
+// tombstone dated now.
+func (db *DB) purgeTasksWhere(ctx context.Context, q queryer, where string, args ...any) (int64, error) {
+	now := time.Now().UTC()
+	actor, traceID, spanID := eventContext(ctx)
+	_, err := q.ExecContext(ctx, `
+	INSERT INTO task_events (task_id, event, changes, actor, trace_id, span_id, created_at)
+	SELECT id, ?, '{}', ?, ?, ?, ? FROM tasks WHERE `+where,
+		append([]any{TaskEventPurged, actor, traceID, spanID, now}, args...)...)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = q.ExecContext(ctx, `
+	INSERT INTO task_tombstones (uuid, task_id, deleted_at)
+	SELECT uuid, id, COALESCE(deleted_at, ?) FROM tasks WHERE `+where, append([]any{now}, args...)...)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = q.ExecContext(ctx, `
+	DELETE FROM task_dependencies WHERE task_id IN (SELECT id FROM tasks WHERE `+where+`)
+		OR blocked_by_id IN (SELECT id FROM tasks WHERE `+where+`)`, append(append([]any{}, args...), args...)...)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = q.ExecContext(ctx, `
+	DELETE FROM task_links WHERE task_id IN (SELECT id FROM tasks WHERE `+where+`)
+		OR linked_id IN (SELECT id FROM tasks WHERE `+where+`)`, append(append([]any{}, args...), args...)...)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = q.ExecContext(ctx, `
+	DELETE FROM review_decisions WHERE task_id IN (SELECT id FROM tasks WHERE `+where+`)`, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = q.ExecContext(ctx, `
+	DELETE FROM hook_tasks WHERE task_id IN (SELECT id FROM tasks WHERE `+where+`)`, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `DELETE FROM tasks WHERE ` + where
+	start := time.Now()
+	result, err := q.ExecContext(ctx, query, args...)
+	db.checkSlowQuery(ctx, start, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithTx runs fn in a transaction, committed when fn returns nil and rolled back when it
+// returns an error or panics. The transaction is traced as a db.Tx span carrying name as
+// db.transaction, with its commit or rollback as a span event; the statements fn runs are
+// its children. fn's error is returned as is, so callers can roll back on purpose with an
+// error of their own.
+func (db *DB) WithTx(ctx context.Context, name string, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.Tx",
+		trace.WithAttributes(attribute.String("db.transaction", name)))
+	defer span.End()
+
+	start := time.Now()
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			rollback(ctx, tx, start, fmt.Errorf("panic: %v", p))
+			panic(p)
+		}
+	}()
+
+	if err := fn(ctx, tx); err != nil {
+		rollback(ctx, tx, start, err)
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		span.AddEvent("db.tx.commit_failed", trace.WithAttributes(
+			attribute.String("error", err.Error()),
+			attribute.Int64("db.tx.duration_ms", time.Since(start).Milliseconds()),
+		))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	span.AddEvent("db.tx.commit", trace.WithAttributes(
+		attribute.Int64("db.tx.duration_ms", time.Since(start).Milliseconds()),
+	))
+	return nil
+}
+
+// rollback rolls tx back because of cause and records it on the transaction's span. The
+// rollback is not an error of the span: cause may be expected, and the caller decides.
+func rollback(ctx context.Context, tx *sql.Tx, start time.Time, cause error) {
+	span := trace.SpanFromContext(ctx)
+	attrs := []attribute.KeyValue{
+		attribute.String("db.tx.rollback_reason", cause.Error()),
+		attribute.Int64("db.tx.duration_ms", time.Since(start).Milliseconds()),
+	}
+	if err := tx.Rollback(); err != nil {
+		attrs = append(attrs, attribute.String("error", err.Error()))
+	}
+	span.AddEvent("db.tx.rollback", trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,245 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// MaxUploadSize caps the size of a file uploaded in chunks
+	MaxUploadSize = int64(config.EnvInt("TODO_MAX_UPLOAD_SIZE", 1<<30))
+	// UploadTTL is how long an upload may go without a chunk before it is abandoned
+	UploadTTL = config.EnvDuration("TODO_UPLOAD_TTL", 24*time.Hour)
+)
+
+// ErrUploadOffsetMismatch is returned when a chunk does not start where the upload is
+var ErrUploadOffsetMismatch = errors.New("chunk does not start at the upload's offset")
+
+// Upload is a file being uploaded to a task in chunks. Received bytes of Size are staged so
+// far; once all are, the upload becomes an attachment.
+type Upload struct {
+	ID          string    `json:"id"`
+	TaskID      int       `json:"task_id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Received    int64     `json:"received"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+const uploadColumns = `id, task_id, name, content_type, size, received, created_by, created_at, updated_at`
+
+func scanUpload(row rowScanner) (*Upload, error) {
+	u := &Upload{}
+	err := row.Scan(&u.ID, &u.TaskID, &u.Name, &u.ContentType, &u.Size, &u.Received, &u.CreatedBy, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	u.ExpiresAt = u.UpdatedAt.Add(UploadTTL)
+	return u, nil
+}
+
+// CreateUpload starts an upload, sql.ErrNoRows if the task does not exist or is in the
+// trash and a QuotaError if the whole file would not fit its workspace's quota
+func (db *DB) CreateUpload(ctx context.Context, u *Upload) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.CreateUpload",
+		trace.WithAttributes(
+			attribute.String("db.operation", "insert_upload"),
+			attribute.Int("task.id", u.TaskID),
+			attribute.Int64("upload.size", u.Size),
+		))
+	defer span.End()
+
+	err := db.WithTx(ctx, "create_upload", func(ctx context.Context, tx *sql.Tx) error {
+		if err := checkAttachmentQuota(ctx, tx, u.TaskID, u.Size); err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		query := `INSERT INTO uploads (id, task_id, name, content_type, size, received, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, 0, ?, ?, ?)`
+		start := time.Now()
+		_, err := tx.ExecContext(ctx, query, u.ID, u.TaskID, u.Name, u.ContentType, u.Size, u.CreatedBy, now, now)
+		db.checkSlowQuery(ctx, start, query)
+		if err != nil {
+			return err
+		}
+		u.Received, u.CreatedAt, u.UpdatedAt, u.ExpiresAt = 0, now, now, now.Add(UploadTTL)
+		return nil
+	})
+	if errors.Is(err, ErrQuotaExceeded) || errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// GetUpload returns an upload to a task, sql.ErrNoRows if there is none or it has expired
+func (db *DB) GetUpload(ctx context.Context, taskID int, id string) (*Upload, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetUpload",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_upload"),
+			attribute.Int("task.id", taskID),
+			attribute.String("upload.id", id),
+		))
+	defer span.End()
+
+	query := `SELECT ` + uploadColumns + ` FROM uploads WHERE id = ? AND task_id = ? AND updated_at > ?`
+	cutoff := time.Now().UTC().Add(-UploadTTL)
+	start := time.Now()
+	u, err := scanUpload(db.conn.QueryRowContext(ctx, query, id, taskID, cutoff))
+	db.checkSlowQuery(ctx, start, query, id, taskID, cutoff)
+	return u, err
+}
+
+// AdvanceUpload records that the bytes from offset to received are staged, as long as the
+// upload is still at offset; ErrUploadOffsetMismatch otherwise, as when another request
+// wrote the same chunk first
+func (db *DB) AdvanceUpload(ctx context.Context, id string, offset, received int64) (*Upload, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.AdvanceUpload",
+		trace.WithAttributes(
+			attribute.String("db.operation", "update_upload"),
+			attribute.String("upload.id", id),
+			attribute.Int64("upload.offset", offset),
+			attribute.Int64("upload.received", received),
+		))
+	defer span.End()
+
+	query := `UPDATE uploads SET received = ?, updated_at = ? WHERE id = ? AND received = ?`
+	start := time.Now()
+	result, err := db.conn.ExecContext(ctx, query, received, time.Now().UTC(), id, offset)
+	db.checkSlowQuery(ctx, start, query, received, id, offset)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrUploadOffsetMismatch
+	}
+	return scanUpload(db.conn.QueryRowContext(ctx, `SELECT `+uploadColumns+` FROM uploads WHERE id = ?`, id))
+}
+
+// CompleteUpload turns a fully staged upload into the attachment a, whose content is
+// already stored, and forgets the upload. sql.ErrNoRows means another request completed or
+// deleted it first.
+func (db *DB) CompleteUpload(ctx context.Context, id string, a *Attachment) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.CompleteUpload",
+		trace.WithAttributes(
+			attribute.String("db.operation", "complete_upload"),
+			attribute.String("upload.id", id),
+			attribute.Int("task.id", a.TaskID),
+		))
+	defer span.End()
+
+	err := db.WithTx(ctx, "complete_upload", func(ctx context.Context, tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `DELETE FROM uploads WHERE id = ? AND received = size`, id)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return sql.ErrNoRows
+		}
+		if err := checkAttachmentQuota(ctx, tx, a.TaskID, a.Size); err != nil {
+			return err
+		}
+		query := `INSERT INTO attachments (task_id, name, content_type, size, sha256, storage_key, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING ` + attachmentColumns
+		created, err := scanAttachment(db.queryReturning(ctx, tx, "attachments", 0, query,
+			a.TaskID, a.Name, a.ContentType, a.Size, a.SHA256, a.StorageKey, a.CreatedBy, time.Now().UTC()))
+		if err != nil {
+			return err
+		}
+		*a = *created
+		return nil
+	})
+	if errors.Is(err, ErrQuotaExceeded) || errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// DeleteUpload forgets an upload so its staging file can be removed, sql.ErrNoRows if there
+// is none
+func (db *DB) DeleteUpload(ctx context.Context, taskID int, id string) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.DeleteUpload",
+		trace.WithAttributes(
+			attribute.String("db.operation", "delete_upload"),
+			attribute.Int("task.id", taskID),
+			attribute.String("upload.id", id),
+		))
+	defer span.End()
+
+	result, err := db.conn.ExecContext(ctx, `DELETE FROM uploads WHERE id = ? AND task_id = ?`, id, taskID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// PurgeAbandonedUploads forgets uploads that received nothing since cutoff and returns
+// their IDs, for their staging files to be removed
+func (db *DB) PurgeAbandonedUploads(ctx context.Context, cutoff time.Time) ([]string, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.PurgeAbandonedUploads",
+		trace.WithAttributes(
+			attribute.String("db.operation", "purge_abandoned_uploads"),
+			attribute.String("purge.cutoff", cutoff.UTC().Format(time.RFC3339)),
+		))
+	defer span.End()
+
+	var ids []string
+	err := db.WithTx(ctx, "purge_abandoned_uploads", func(ctx context.Context, tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT id FROM uploads WHERE updated_at < ?`, cutoff.UTC())
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+		_, err = tx.ExecContext(ctx, `DELETE FROM uploads WHERE updated_at < ?`, cutoff.UTC())
+		return err
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("purge.count", len(ids)))
+	return ids, nil
+}
@@ -0,0 +1,339 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"todo-app/internal/config"
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// WebhookInterval is how often due webhook deliveries are sent
+	WebhookInterval = config.EnvDuration("TODO_WEBHOOK_INTERVAL", 5*time.Second)
+	// WebhookMaxAttempts is how many times a delivery is tried before it is marked failed
+	WebhookMaxAttempts = config.EnvInt("TODO_WEBHOOK_MAX_ATTEMPTS", 8)
+	// WebhookBackoff is the wait after the first failed attempt; it doubles with each one
+	WebhookBackoff = config.EnvDuration("TODO_WEBHOOK_BACKOFF", 30*time.Second)
+)
+
+const (
+	// maxRetryBackoff caps the wait between attempts
+	maxRetryBackoff = time.Hour
+	// WebhookBatchSize bounds the deliveries sent per run
+	WebhookBatchSize = 100
+	// WebhookRetention is how long delivered deliveries are kept for inspection. Failed
+	// ones are dead letters, kept until replayed or their webhook is deleted.
+	WebhookRetention = 7 * 24 * time.Hour
+	// MinWebhookSecretLength keeps chosen secrets from being guessable
+	MinWebhookSecretLength = 16
+)
+
+// Headers sent with every webhook delivery
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// Delivery statuses
+const (
+	webhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookFailed    = "failed"
+)
+
+// Webhook is a URL that receives task events. Secret is only returned when the webhook is
+// created; it signs every delivery.
+type Webhook struct {
+	ID        int       `json:"id"`
+	UserID    string    `json:"user_id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is one event sent, or to be sent, to a webhook
+type WebhookDelivery struct {
+	ID             int             `json:"id"`
+	WebhookID      int             `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code"`
+	LastError      *string         `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+	FailedAt       *time.Time      `json:"failed_at"`
+
+	// The webhook's URL and secret, and the span of the change, for delivering
+	URL     string `json:"-"`
+	Secret  string `json:"-"`
+	TraceID string `json:"-"`
+	SpanID  string `json:"-"`
+}
+
+// BackoffAfter is the wait after the attempts-th failure of a delivery or notification:
+// base doubled for each earlier failure, capped, with up to a fifth added at random so
+// deliveries that failed together do not all retry together
+func BackoffAfter(base time.Duration, attempts int) time.Duration {
+	wait := base
+	for i := 1; i < attempts && wait < maxRetryBackoff; i++ {
+		wait *= 2
+	}
+	wait = min(wait, maxRetryBackoff)
+	return wait + rand.N(wait/5+1)
+}
+
+const webhookColumns = `id, user_id, url, events, created_at`
+
+func scanWebhook(row rowScanner) (*Webhook, error) {
+	webhook := &Webhook{}
+	var events string
+	if err := row.Scan(&webhook.ID, &webhook.UserID, &webhook.URL, &events, &webhook.CreatedAt); err != nil {
+		return nil, err
+	}
+	webhook.Events = strings.Split(events, ",")
+	return webhook, nil
+}
+
+// GetWebhooks returns the user's webhooks, without their secrets
+func (db *DB) GetWebhooks(ctx context.Context, userID string) ([]Webhook, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetWebhooks",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_webhooks"),
+			attribute.String("user.id", userID),
+		))
+	defer span.End()
+
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE user_id = ? ORDER BY id`
+	start := time.Now()
+	rows, err := db.conn.QueryContext(ctx, query, userID)
+	db.checkSlowQuery(ctx, start, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// CreateWebhook stores a webhook with its secret sealed
+func (db *DB) CreateWebhook(ctx context.Context, webhook Webhook) (*Webhook, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.CreateWebhook",
+		trace.WithAttributes(attribute.String("db.operation", "insert_webhook")))
+	defer span.End()
+
+	secret, err := SealField(fieldWebhookSecret, webhook.Secret)
+	if err != nil {
+		return nil, err
+	}
+	var created *Webhook
+	err = db.WithTx(ctx, "create_webhook", func(ctx context.Context, tx *sql.Tx) error {
+		if err := checkWebhookQuota(ctx, tx, webhook.UserID); err != nil {
+			return err
+		}
+		query := `INSERT INTO webhooks (user_id, url, events, secret, created_at)
+		VALUES (?, ?, ?, ?, ?) RETURNING ` + webhookColumns
+		start := time.Now()
+		var err error
+		created, err = scanWebhook(db.queryReturning(ctx, tx, "webhooks", 0, query,
+			webhook.UserID, webhook.URL, strings.Join(webhook.Events, ","), secret, time.Now().UTC()))
+		db.checkSlowQuery(ctx, start, query)
+		return err
+	})
+	if errors.Is(err, ErrQuotaExceeded) {
+		return nil, err
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return created, nil
+}
+
+// DeleteWebhook removes one of the user's webhooks and its deliveries, sql.ErrNoRows if
+// there is none
+func (db *DB) DeleteWebhook(ctx context.Context, userID string, id int) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.DeleteWebhook",
+		trace.WithAttributes(
+			attribute.String("db.operation", "delete_webhook"),
+			attribute.Int("webhook.id", id),
+		))
+	defer span.End()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE webhook_id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// QueueWebhookDeliveries queues payload for every webhook subscribed to event and returns
+// how many were queued
+func (db *DB) QueueWebhookDeliveries(ctx context.Context, event string, payload []byte) (int64, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.QueueWebhookDeliveries",
+		trace.WithAttributes(
+			attribute.String("db.operation", "insert_webhook_deliveries"),
+			attribute.String("webhook.event", event),
+		))
+	defer span.End()
+
+	sealed, err := SealField(fieldWebhookPayload, string(payload))
+	if err != nil {
+		return 0, err
+	}
+	var traceID, spanID any
+	if sc := span.SpanContext(); sc.IsValid() {
+		traceID, spanID = sc.TraceID().String(), sc.SpanID().String()
+	}
+	now := time.Now().UTC()
+	query := `INSERT INTO webhook_deliveries (webhook_id, event, payload, next_attempt_at, trace_id, span_id, created_at)
+	SELECT id, ?, ?, ?, ?, ?, ? FROM webhooks WHERE ',' || events || ',' LIKE '%,' || ? || ',%'`
+	start := time.Now()
+	result, err := db.conn.ExecContext(ctx, query, event, sealed, now, traceID, spanID, now, event)
+	db.checkSlowQuery(ctx, start, query)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	span.SetAttributes(attribute.Int64("webhook.deliveries", n))
+	return n, err
+}
+
+// DueWebhookDeliveries returns up to limit pending deliveries due by now, oldest first,
+// with their webhook's URL and secret
+func (db *DB) DueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.DueWebhookDeliveries",
+		trace.WithAttributes(attribute.String("db.operation", "select_webhook_deliveries")))
+	defer span.End()
+
+	query := `SELECT d.id, d.webhook_id, d.event, d.payload, d.attempts, d.trace_id, d.span_id, d.created_at, w.url, w.secret
+	FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+	WHERE d.status = ? AND d.next_attempt_at <= ? ORDER BY d.next_attempt_at, d.id LIMIT ?`
+	start := time.Now()
+	rows, err := db.conn.QueryContext(ctx, query, webhookPending, now.UTC(), limit)
+	db.checkSlowQuery(ctx, start, query, webhookPending, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		var payload string
+		var traceID, spanID sql.NullString
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, sealedString{fieldWebhookPayload, &payload}, &d.Attempts,
+			&traceID, &spanID, &d.CreatedAt, &d.URL, sealedString{fieldWebhookSecret, &d.Secret}); err != nil {
+			return nil, err
+		}
+		d.Payload = json.RawMessage(payload)
+		d.Status = webhookPending
+		d.TraceID, d.SpanID = traceID.String, spanID.String
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// UpdateWebhookDelivery records the outcome of an attempt
+func (db *DB) UpdateWebhookDelivery(ctx context.Context, d *WebhookDelivery) error {
+	_, err := db.conn.ExecContext(ctx, `
+	UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = COALESCE(?, next_attempt_at),
+	last_status_code = ?, last_error = ?, delivered_at = ?, failed_at = ? WHERE id = ?`,
+		d.Status, d.Attempts, d.NextAttemptAt, d.LastStatusCode, d.LastError, d.DeliveredAt, d.FailedAt, d.ID)
+	return err
+}
+
+// GetWebhookDeliveries returns the most recent deliveries of one of the user's webhooks,
+// sql.ErrNoRows if the user has no such webhook
+func (db *DB) GetWebhookDeliveries(ctx context.Context, userID string, webhookID, limit int) ([]WebhookDelivery, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetWebhookDeliveries",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_webhook_deliveries"),
+			attribute.Int("webhook.id", webhookID),
+		))
+	defer span.End()
+
+	var exists bool
+	err := db.conn.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = ? AND user_id = ?)`,
+		webhookID, userID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+
+	rows, err := db.conn.QueryContext(ctx, `
+	SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at, failed_at
+	FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ?`, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		var payload string
+		var next time.Time
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, sealedString{fieldWebhookPayload, &payload}, &d.Status, &d.Attempts,
+			&next, &d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt, &d.FailedAt); err != nil {
+			return nil, err
+		}
+		d.Payload = json.RawMessage(payload)
+		if d.Status == webhookPending {
+			d.NextAttemptAt = &next
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// PurgeWebhookDeliveries removes deliveries delivered before cutoff
+func (db *DB) PurgeWebhookDeliveries(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := db.conn.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE status = ? AND delivered_at < ?`,
+		WebhookDelivered, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
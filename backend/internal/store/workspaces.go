@@ -0,0 +1,430 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"todo-app/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultWorkspaceName is the name of the workspace the workspaces migration sets up
+const DefaultWorkspaceName = "Personal"
+
+// Workspace member roles, for WorkspaceMember.Role
+const (
+	WorkspaceRoleOwner  = "owner"
+	WorkspaceRoleMember = "member"
+)
+
+var (
+	// ErrWorkspaceNameTaken is returned when a workspace with the same name already exists
+	ErrWorkspaceNameTaken = errors.New("workspace name already exists")
+	// ErrWorkspaceNotFound is returned when a list refers to a workspace that does not exist
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+	// ErrDefaultWorkspace is returned when trying to delete the default workspace
+	ErrDefaultWorkspace = errors.New("the default workspace cannot be deleted")
+	// ErrWorkspaceNotEmpty is returned when trying to delete a workspace that still has lists
+	ErrWorkspaceNotEmpty = errors.New("the workspace still has lists")
+)
+
+// Workspace groups lists, e.g. personal and work. Every list belongs to exactly one
+// workspace; lists from before workspaces are in the default one.
+type Workspace struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	IsDefault bool      `json:"is_default"`
+	CreatedAt time.Time `json:"created_at"`
+	ListCount int       `json:"list_count"`
+}
+
+// WorkspaceMember is a user's role in a workspace. Membership is recorded for team
+// deployments; requests are not yet limited to the workspaces their user belongs to.
+type WorkspaceMember struct {
+	WorkspaceID int       `json:"workspace_id"`
+	UserID      string    `json:"user_id"`
+	Role        string    `json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ValidWorkspaceRole reports whether role is one of the member roles
+func ValidWorkspaceRole(role string) bool {
+	return role == WorkspaceRoleOwner || role == WorkspaceRoleMember
+}
+
+// WorkspaceSettings are the defaults applied to the tasks created in any list of a
+// workspace. A list's own settings take precedence: its default tags are added to these,
+// and its reminder offset replaces this one.
+type WorkspaceSettings struct {
+	WorkspaceID           int       `json:"workspace_id"`
+	DefaultTags           Tags      `json:"default_tags"`
+	ReminderOffsetSeconds *int      `json:"reminder_offset_seconds"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+// Validate checks the settings and normalizes the default tags
+func (s *WorkspaceSettings) Validate() error {
+	tags, err := validateTaskDefaults(s.DefaultTags, s.ReminderOffsetSeconds)
+	s.DefaultTags = tags
+	return err
+}
+
+// workspaceColumns selects a workspace with the number of lists in it
+const workspaceColumns = `workspaces.id, workspaces.name, workspaces.is_default, workspaces.created_at,
+	(SELECT COUNT(*) FROM lists WHERE lists.workspace_id = workspaces.id)`
+
+func scanWorkspace(row rowScanner) (*Workspace, error) {
+	w := &Workspace{}
+	if err := row.Scan(&w.ID, &w.Name, &w.IsDefault, &w.CreatedAt, &w.ListCount); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// resolveWorkspaceID returns workspaceID if that workspace exists, or the default workspace
+// when workspaceID is nil
+func (db *DB) resolveWorkspaceID(ctx context.Context, q queryer, workspaceID *int) (int, error) {
+	var id int
+	var err error
+	if workspaceID == nil {
+		err = q.QueryRowContext(ctx, `SELECT id FROM workspaces WHERE is_default = TRUE`).Scan(&id)
+	} else {
+		err = q.QueryRowContext(ctx, `SELECT id FROM workspaces WHERE id = ?`, *workspaceID).Scan(&id)
+		if err == sql.ErrNoRows {
+			err = ErrWorkspaceNotFound
+		}
+	}
+	return id, err
+}
+
+func (db *DB) GetWorkspaces(ctx context.Context) ([]Workspace, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetWorkspaces",
+		trace.WithAttributes(attribute.String("db.operation", "select_workspaces")))
+	defer span.End()
+
+	query := `SELECT ` + workspaceColumns + ` FROM workspaces ORDER BY is_default DESC, name`
+	start := time.Now()
+	rows, err := db.conn.QueryContext(ctx, query)
+	db.checkSlowQuery(ctx, start, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workspaces := []Workspace{}
+	for rows.Next() {
+		w, err := scanWorkspace(rows)
+		if err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, *w)
+	}
+	return workspaces, rows.Err()
+}
+
+func (db *DB) GetWorkspace(ctx context.Context, id int) (*Workspace, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetWorkspace",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_workspace"),
+			attribute.Int("workspace.id", id),
+		))
+	defer span.End()
+
+	return scanWorkspace(db.conn.QueryRowContext(ctx, `SELECT `+workspaceColumns+` FROM workspaces WHERE id = ?`, id))
+}
+
+// CreateWorkspace creates a workspace with owner, when set, as its owner
+func (db *DB) CreateWorkspace(ctx context.Context, name, owner string) (*Workspace, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.CreateWorkspace",
+		trace.WithAttributes(
+			attribute.String("db.operation", "insert_workspace"),
+			attribute.String("workspace.name", name),
+		))
+	defer span.End()
+
+	var id int
+	err := db.WithTx(ctx, "create_workspace", func(ctx context.Context, tx *sql.Tx) error {
+		if taken, err := db.workspaceNameTaken(ctx, tx, name, 0); err != nil || taken {
+			if taken {
+				err = ErrWorkspaceNameTaken
+			}
+			return err
+		}
+		now := time.Now().UTC()
+		err := db.queryReturning(ctx, tx, "workspaces", 0, `INSERT INTO workspaces (name, created_at) VALUES (?, ?) RETURNING id`,
+			name, now).Scan(&id)
+		if err != nil || owner == "" {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO workspace_members (workspace_id, user_id, role, created_at) VALUES (?, ?, ?, ?)`,
+			id, owner, WorkspaceRoleOwner, now)
+		return err
+	})
+	if err != nil {
+		if !errors.Is(err, ErrWorkspaceNameTaken) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return nil, err
+	}
+	return db.GetWorkspace(ctx, id)
+}
+
+func (db *DB) RenameWorkspace(ctx context.Context, id int, name string) (*Workspace, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.RenameWorkspace",
+		trace.WithAttributes(
+			attribute.String("db.operation", "update_workspace"),
+			attribute.Int("workspace.id", id),
+			attribute.String("workspace.name", name),
+		))
+	defer span.End()
+
+	if taken, err := db.workspaceNameTaken(ctx, db.conn, name, id); err != nil || taken {
+		if taken {
+			err = ErrWorkspaceNameTaken
+		}
+		return nil, err
+	}
+
+	result, err := db.conn.ExecContext(ctx, `UPDATE workspaces SET name = ? WHERE id = ?`, name, id)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = sql.ErrNoRows
+		}
+		return nil, err
+	}
+	return db.GetWorkspace(ctx, id)
+}
+
+// workspaceNameTaken reports whether another workspace than exceptID is called name
+func (db *DB) workspaceNameTaken(ctx context.Context, q queryer, name string, exceptID int) (bool, error) {
+	var count int
+	err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM workspaces WHERE name = ? AND id <> ?`, name, exceptID).Scan(&count)
+	return count > 0, err
+}
+
+// DeleteWorkspace removes an empty workspace with its members and settings. Its lists have
+// to be deleted first, since moving them would mix two teams' tasks.
+func (db *DB) DeleteWorkspace(ctx context.Context, id int) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.DeleteWorkspace",
+		trace.WithAttributes(
+			attribute.String("db.operation", "delete_workspace"),
+			attribute.Int("workspace.id", id),
+		))
+	defer span.End()
+
+	return db.WithTx(ctx, "delete_workspace", func(ctx context.Context, tx *sql.Tx) error {
+		var isDefault bool
+		var lists int
+		err := tx.QueryRowContext(ctx, `SELECT is_default, (SELECT COUNT(*) FROM lists WHERE workspace_id = workspaces.id) FROM workspaces WHERE id = ?`, id).
+			Scan(&isDefault, &lists)
+		if err != nil {
+			return err
+		}
+		if isDefault {
+			return ErrDefaultWorkspace
+		}
+		if lists > 0 {
+			return ErrWorkspaceNotEmpty
+		}
+		for _, stmt := range []string{
+			`DELETE FROM workspace_members WHERE workspace_id = ?`,
+			`DELETE FROM workspace_settings WHERE workspace_id = ?`,
+			`DELETE FROM workspaces WHERE id = ?`,
+		} {
+			if _, err := tx.ExecContext(ctx, stmt, id); err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetWorkspaceLists returns the lists of a workspace, default list first. It returns
+// sql.ErrNoRows if the workspace does not exist.
+func (db *DB) GetWorkspaceLists(ctx context.Context, id int) ([]List, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetWorkspaceLists",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_lists"),
+			attribute.Int("workspace.id", id),
+		))
+	defer span.End()
+
+	if _, err := db.resolveWorkspaceID(ctx, db.conn, &id); err != nil {
+		if err == ErrWorkspaceNotFound {
+			err = sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	query := `SELECT ` + listColumns + ` FROM lists WHERE workspace_id = ? ORDER BY is_default DESC, name`
+	start := time.Now()
+	rows, err := db.conn.QueryContext(ctx, query, id)
+	db.checkSlowQuery(ctx, start, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []List{}
+	for rows.Next() {
+		list, err := scanList(rows)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, *list)
+	}
+	return lists, rows.Err()
+}
+
+// GetWorkspaceMembers returns the members of a workspace, owners first. It returns
+// sql.ErrNoRows if the workspace does not exist.
+func (db *DB) GetWorkspaceMembers(ctx context.Context, id int) ([]WorkspaceMember, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetWorkspaceMembers",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_workspace_members"),
+			attribute.Int("workspace.id", id),
+		))
+	defer span.End()
+
+	if _, err := db.resolveWorkspaceID(ctx, db.conn, &id); err != nil {
+		if err == ErrWorkspaceNotFound {
+			err = sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	rows, err := db.conn.QueryContext(ctx, `SELECT workspace_id, user_id, role, created_at FROM workspace_members
+	WHERE workspace_id = ? ORDER BY CASE WHEN role = ? THEN 0 ELSE 1 END, user_id`, id, WorkspaceRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []WorkspaceMember{}
+	for rows.Next() {
+		var m WorkspaceMember
+		if err := rows.Scan(&m.WorkspaceID, &m.UserID, &m.Role, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// SaveWorkspaceMember adds a user to a workspace or changes their role. It returns
+// sql.ErrNoRows if the workspace does not exist.
+func (db *DB) SaveWorkspaceMember(ctx context.Context, m *WorkspaceMember) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.SaveWorkspaceMember",
+		trace.WithAttributes(
+			attribute.String("db.operation", "upsert_workspace_member"),
+			attribute.Int("workspace.id", m.WorkspaceID),
+			attribute.String("user.id", m.UserID),
+			attribute.String("workspace.role", m.Role),
+		))
+	defer span.End()
+
+	if _, err := db.resolveWorkspaceID(ctx, db.conn, &m.WorkspaceID); err != nil {
+		if err == ErrWorkspaceNotFound {
+			err = sql.ErrNoRows
+		}
+		return err
+	}
+	m.CreatedAt = time.Now().UTC()
+	_, err := db.conn.ExecContext(ctx, `
+	INSERT INTO workspace_members (workspace_id, user_id, role, created_at) VALUES (?, ?, ?, ?)
+	ON CONFLICT (workspace_id, user_id) DO UPDATE SET role = excluded.role`,
+		m.WorkspaceID, m.UserID, m.Role, m.CreatedAt)
+	if err != nil {
+		return err
+	}
+	// A member who already belonged keeps their original join time
+	return db.conn.QueryRowContext(ctx, `SELECT created_at FROM workspace_members WHERE workspace_id = ? AND user_id = ?`,
+		m.WorkspaceID, m.UserID).Scan(&m.CreatedAt)
+}
+
+// RemoveWorkspaceMember removes a user from a workspace, returning sql.ErrNoRows if they
+// were not a member
+func (db *DB) RemoveWorkspaceMember(ctx context.Context, id int, userID string) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.RemoveWorkspaceMember",
+		trace.WithAttributes(
+			attribute.String("db.operation", "delete_workspace_member"),
+			attribute.Int("workspace.id", id),
+			attribute.String("user.id", userID),
+		))
+	defer span.End()
+
+	result, err := db.conn.ExecContext(ctx, `DELETE FROM workspace_members WHERE workspace_id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = sql.ErrNoRows
+		}
+		return err
+	}
+	return nil
+}
+
+// GetWorkspaceSettings returns a workspace's settings, or empty ones if none were saved. It
+// returns sql.ErrNoRows if the workspace does not exist.
+func (db *DB) GetWorkspaceSettings(ctx context.Context, id int) (*WorkspaceSettings, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.GetWorkspaceSettings",
+		trace.WithAttributes(
+			attribute.String("db.operation", "select_workspace_settings"),
+			attribute.Int("workspace.id", id),
+		))
+	defer span.End()
+
+	if _, err := db.resolveWorkspaceID(ctx, db.conn, &id); err != nil {
+		if err == ErrWorkspaceNotFound {
+			err = sql.ErrNoRows
+		}
+		return nil, err
+	}
+	s := &WorkspaceSettings{WorkspaceID: id, DefaultTags: Tags{}}
+	err := db.conn.QueryRowContext(ctx, `SELECT default_tags, reminder_offset, updated_at FROM workspace_settings WHERE workspace_id = ?`, id).
+		Scan(&s.DefaultTags, &s.ReminderOffsetSeconds, &s.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SaveWorkspaceSettings replaces a workspace's settings. It returns sql.ErrNoRows if the
+// workspace does not exist.
+func (db *DB) SaveWorkspaceSettings(ctx context.Context, s *WorkspaceSettings) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "db.SaveWorkspaceSettings",
+		trace.WithAttributes(
+			attribute.String("db.operation", "upsert_workspace_settings"),
+			attribute.Int("workspace.id", s.WorkspaceID),
+		))
+	defer span.End()
+
+	if _, err := db.resolveWorkspaceID(ctx, db.conn, &s.WorkspaceID); err != nil {
+		if err == ErrWorkspaceNotFound {
+			err = sql.ErrNoRows
+		}
+		return err
+	}
+	s.UpdatedAt = time.Now().UTC()
+	_, err := db.conn.ExecContext(ctx, `
+	INSERT INTO workspace_settings (workspace_id, default_tags, reminder_offset, updated_at) VALUES (?, ?, ?, ?)
+	ON CONFLICT (workspace_id) DO UPDATE SET
+		default_tags = excluded.default_tags,
+		reminder_offset = excluded.reminder_offset,
+		updated_at = excluded.updated_at`,
+		s.WorkspaceID, s.DefaultTags, s.ReminderOffsetSeconds, s.UpdatedAt)
+	return err
+}
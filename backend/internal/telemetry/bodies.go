@@ -0,0 +1,215 @@
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"todo-app/internal/config"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Request and response bodies recorded as span events, by the API's traced routes and by
+// outbound integration calls, are limited so they neither leak credentials nor bloat spans
+var (
+	// TraceBodies turns body events off entirely when false
+	TraceBodies = config.EnvBool("TODO_TRACE_BODIES", true)
+	// traceBodyMaxBytes is the most of a body an event holds; longer bodies are truncated
+	traceBodyMaxBytes = config.EnvInt("TODO_TRACE_BODY_MAX_BYTES", 4096)
+	// traceBodyContentTypes are the media types whose bodies are recorded; "text/*" matches
+	// a whole type and "+json" any structured JSON type
+	traceBodyContentTypes = config.SplitList(config.EnvString("TODO_TRACE_BODY_CONTENT_TYPES",
+		"application/json,+json,application/x-www-form-urlencoded,text/plain"))
+	// traceBodyRedactKeys are the JSON object keys and form fields, matched case-insensitively,
+	// whose values are masked wherever they appear
+	traceBodyRedactKeys = config.SplitList(config.EnvString("TODO_TRACE_BODY_REDACT_KEYS",
+		"password,secret,token,access_token,refresh_token,api_key,apikey,authorization,client_secret"))
+)
+
+// TraceBodyReadLimit is how much of a body to keep for its event. JSON and forms must be
+// parsed whole to be redacted, so more than traceBodyMaxBytes is kept; a body of a type
+// that needs redaction and is longer than this is left out of its event.
+const TraceBodyReadLimit = 64 << 10
+
+// ReadBodyHead reads the start of body for its event, up to TraceBodyReadLimit bytes, and
+// returns it with a reader that yields the whole body again. complete is set when head is
+// all of it.
+func ReadBodyHead(body io.Reader) (head []byte, whole io.Reader, complete bool, err error) {
+	head, err = io.ReadAll(io.LimitReader(body, TraceBodyReadLimit+1))
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if len(head) <= TraceBodyReadLimit {
+		return head, bytes.NewReader(head), true, nil
+	}
+	return head[:TraceBodyReadLimit], io.MultiReader(bytes.NewReader(head), body), false, nil
+}
+
+// BodyEventAttributes returns the attributes of an http.request.body or http.response.body
+// event. head is the start of the body, at most TraceBodyReadLimit bytes, and size its full
+// length, or -1 when unknown. The body is left out, with body.omitted saying why, when its
+// content type is not allowed or it is too long to redact; otherwise body.redacted counts
+// the masked values and body.truncated tells whether it was cut to TODO_TRACE_BODY_MAX_BYTES.
+func BodyEventAttributes(contentType string, head []byte, size int64) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{attribute.Int64("size", size)}
+	if contentType != "" {
+		attrs = append(attrs, attribute.String("content_type", contentType))
+	}
+	kind, allowed := classifyBody(contentType, head)
+	if !allowed {
+		return append(attrs, attribute.String("body.omitted", "content type not captured"))
+	}
+	complete := size >= 0 && int64(len(head)) >= size
+
+	redactedCount := 0
+	switch kind {
+	case bodyJSON, bodyForm:
+		if !complete {
+			return append(attrs, attribute.String("body.omitted", "too large to redact"))
+		}
+		var ok bool
+		if kind == bodyJSON {
+			head, redactedCount, ok = redactJSON(head)
+		} else {
+			head, redactedCount, ok = redactForm(head)
+		}
+		if !ok {
+			return append(attrs, attribute.String("body.omitted", "unparseable "+string(kind)))
+		}
+	}
+
+	body, truncated := truncateUTF8(head, traceBodyMaxBytes)
+	return append(attrs,
+		attribute.String("body", body),
+		attribute.Bool("body.truncated", truncated || !complete),
+		attribute.Int("body.redacted", redactedCount),
+	)
+}
+
+// bodyKind is how a body is redacted
+type bodyKind string
+
+const (
+	bodyJSON bodyKind = "json"
+	bodyForm bodyKind = "form"
+	bodyText bodyKind = "text"
+)
+
+// classifyBody tells how a body is redacted and whether its content type is captured at
+// all; a body without one is captured when it is JSON. Whatever the declared type, a body
+// that looks like JSON is redacted as JSON, since clients such as curl -d send JSON as a
+// form.
+func classifyBody(contentType string, head []byte) (bodyKind, bool) {
+	trimmed := bytes.TrimSpace(head)
+	looksJSON := len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if contentType == "" || err != nil {
+		if !looksJSON {
+			return "", false
+		}
+		mediaType = "application/json"
+	}
+	allowed := slices.ContainsFunc(traceBodyContentTypes, func(pattern string) bool {
+		if suffix, ok := strings.CutPrefix(pattern, "+"); ok {
+			return strings.HasSuffix(mediaType, "+"+suffix)
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+			return strings.HasPrefix(mediaType, prefix+"/")
+		}
+		return mediaType == pattern
+	})
+	switch {
+	case looksJSON || mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return bodyJSON, allowed
+	case mediaType == "application/x-www-form-urlencoded":
+		return bodyForm, allowed
+	}
+	return bodyText, allowed
+}
+
+// redactKey reports whether the value of key is masked
+func redactKey(key string) bool {
+	return slices.Contains(traceBodyRedactKeys, strings.ToLower(key))
+}
+
+// redactJSON masks the values of redacted keys at any depth. Numbers keep their precision;
+// the result is compact JSON with object keys in sorted order.
+func redactJSON(body []byte) ([]byte, int, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, 0, false
+	}
+	count := 0
+	var walk func(v any) any
+	walk = func(v any) any {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, child := range v {
+				if redactKey(k) {
+					v[k] = redacted
+					count++
+				} else {
+					v[k] = walk(child)
+				}
+			}
+		case []any:
+			for i, child := range v {
+				v[i] = walk(child)
+			}
+		}
+		return v
+	}
+	v = walk(v)
+	if count == 0 {
+		return body, 0, true
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return nil, 0, false
+	}
+	return out, count, true
+}
+
+// redactForm masks the values of redacted fields of a URL-encoded form
+func redactForm(body []byte) ([]byte, int, bool) {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, 0, false
+	}
+	count := 0
+	for k, values := range form {
+		if redactKey(k) {
+			for i := range values {
+				values[i] = redacted
+				count++
+			}
+		}
+	}
+	if count == 0 {
+		return body, 0, true
+	}
+	return []byte(form.Encode()), count, true
+}
+
+// truncateUTF8 cuts b to at most max bytes without splitting a UTF-8 sequence
+func truncateUTF8(b []byte, max int) (string, bool) {
+	if len(b) <= max {
+		return string(b), false
+	}
+	b = b[:max]
+	for i := 0; i < utf8.UTFMax-1 && len(b) > 0; i++ {
+		if r, size := utf8.DecodeLastRune(b); r != utf8.RuneError || size > 1 {
+			break
+		}
+		b = b[:len(b)-1]
+	}
+	return string(b), true
+}
@@ -0,0 +1,119 @@
+package telemetry
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"todo-app/internal/config"
+
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// RecentLogs keeps the latest warnings and errors for diagnostics bundles. InitTelemetry
+// adds it to the logger provider next to the exporter.
+var RecentLogs = newLogRing(config.EnvInt("TODO_DIAGNOSTICS_LOG_ENTRIES", 200))
+
+// sensitiveNames are substrings of config and log attribute names whose values are left out
+// of a diagnostics bundle: credentials, and task content or recipients that are user data
+var sensitiveNames = []string{
+	"key", "secret", "token", "password", "credential", "auth", "clients", "headers",
+	"title", "description", "recipient", "email_to",
+}
+
+const redacted = "[REDACTED]"
+
+// embeddedURL matches URLs inside free text such as error messages, whose query strings
+// can carry task content (the external API call sends the title)
+var embeddedURL = regexp.MustCompile(`https?://[^\s"']+`)
+
+// RedactValue returns value, or a placeholder when name looks sensitive. URLs keep their
+// host and path but lose any password and query string.
+func RedactValue(name, value string) string {
+	lower := strings.ToLower(name)
+	for _, s := range sensitiveNames {
+		if strings.Contains(lower, s) {
+			return redacted
+		}
+	}
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		value = u.Redacted()
+	}
+	return embeddedURL.ReplaceAllStringFunc(value, func(raw string) string {
+		if i := strings.IndexByte(raw, '?'); i >= 0 {
+			return raw[:i+1] + redacted
+		}
+		return raw
+	})
+}
+
+// diagnosticLogEntry is a warning or error log record as it appears in a bundle
+type diagnosticLogEntry struct {
+	Time       time.Time         `json:"time"`
+	Severity   string            `json:"severity"`
+	Message    string            `json:"message"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	TraceID    string            `json:"trace_id,omitempty"`
+}
+
+// logRing is a log processor that remembers the last warnings and errors
+type logRing struct {
+	mu      sync.Mutex
+	entries []diagnosticLogEntry
+	next    int
+	full    bool
+}
+
+func newLogRing(size int) *logRing {
+	return &logRing{entries: make([]diagnosticLogEntry, max(size, 1))}
+}
+
+// OnEmit implements sdklog.Processor. Attribute values are redacted as they are recorded,
+// so task content never sits in the ring.
+func (r *logRing) OnEmit(_ context.Context, record *sdklog.Record) error {
+	if record.Severity() < otellog.SeverityWarn {
+		return nil
+	}
+	entry := diagnosticLogEntry{
+		Time:     record.Timestamp().UTC(),
+		Severity: record.SeverityText(),
+		Message:  record.Body().String(),
+	}
+	if record.AttributesLen() > 0 {
+		entry.Attributes = make(map[string]string, record.AttributesLen())
+		record.WalkAttributes(func(kv otellog.KeyValue) bool {
+			entry.Attributes[kv.Key] = RedactValue(kv.Key, kv.Value.String())
+			return true
+		})
+	}
+	if record.TraceID().IsValid() {
+		entry.TraceID = record.TraceID().String()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+	return nil
+}
+
+func (r *logRing) Shutdown(context.Context) error { return nil }
+
+func (r *logRing) ForceFlush(context.Context) error { return nil }
+
+// Entries returns the remembered records, oldest first
+func (r *logRing) Entries() []diagnosticLogEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]diagnosticLogEntry{}, r.entries[:r.next]...)
+	}
+	return append(append([]diagnosticLogEntry{}, r.entries[r.next:]...), r.entries[:r.next]...)
+}
@@ -0,0 +1,120 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"todo-app/internal/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// exportFailureLogInterval limits how often export failures are written to stderr
+const exportFailureLogInterval = 30 * time.Second
+
+// exportFailureReporter counts failed exports per signal and logs them to stderr at a bounded rate
+type exportFailureReporter struct {
+	signal string
+
+	mu         sync.Mutex
+	lastLogged time.Time
+	suppressed int
+
+	counterOnce sync.Once
+	counter     metric.Int64Counter
+}
+
+func newExportFailureReporter(signal string) *exportFailureReporter {
+	return &exportFailureReporter{signal: signal}
+}
+
+func (r *exportFailureReporter) report(ctx context.Context, err error, items int) {
+	r.reportf(ctx, err, "failed to export %d %s", items, r.signal)
+}
+
+func (r *exportFailureReporter) reportf(ctx context.Context, err error, format string, args ...any) {
+	if err == nil {
+		return
+	}
+
+	// The meter provider may not be installed yet when the reporter is created,
+	// so resolve the counter lazily from the global delegating meter.
+	r.counterOnce.Do(func() {
+		r.counter, _ = GetMeter().Int64Counter("todo_app.telemetry.export_failures",
+			metric.WithDescription("Number of failed telemetry export attempts"),
+			metric.WithUnit("1"))
+	})
+	if r.counter != nil {
+		r.counter.Add(context.WithoutCancel(ctx), 1,
+			metric.WithAttributes(attribute.String("signal", r.signal)))
+	}
+	Health.RecordExportFailure(r.signal)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.lastLogged) < exportFailureLogInterval {
+		r.suppressed++
+		return
+	}
+	config.StderrLogger.Printf("%s (%d similar failures suppressed): %v",
+		fmt.Sprintf(format, args...), r.suppressed, err)
+	r.lastLogged = time.Now()
+	r.suppressed = 0
+}
+
+// failureReportingSpanExporter records failed span exports
+type failureReportingSpanExporter struct {
+	sdktrace.SpanExporter
+	reporter *exportFailureReporter
+}
+
+func (e *failureReportingSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	e.reporter.report(ctx, err, len(spans))
+	return err
+}
+
+// failureReportingMetricExporter records failed metric exports
+type failureReportingMetricExporter struct {
+	sdkmetric.Exporter
+	reporter *exportFailureReporter
+}
+
+func (e *failureReportingMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	err := e.Exporter.Export(ctx, rm)
+	e.reporter.report(ctx, err, len(rm.ScopeMetrics))
+	return err
+}
+
+// failureReportingLogExporter records failed log exports
+type failureReportingLogExporter struct {
+	sdklog.Exporter
+	reporter *exportFailureReporter
+}
+
+func (e *failureReportingLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	err := e.Exporter.Export(ctx, records)
+	e.reporter.report(ctx, err, len(records))
+	return err
+}
+
+// telemetryErrorHandler routes internal OpenTelemetry errors to stderr at a bounded rate
+type telemetryErrorHandler struct {
+	reporter *exportFailureReporter
+}
+
+func (h telemetryErrorHandler) Handle(err error) {
+	h.reporter.reportf(context.Background(), err, "opentelemetry error")
+}
+
+func installTelemetryErrorHandler() {
+	otel.SetErrorHandler(telemetryErrorHandler{reporter: newExportFailureReporter("internal")})
+}
@@ -0,0 +1,229 @@
+package telemetry
+
+import (
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// healthReportSamples is how many request durations are kept per route for the p95
+	healthReportSamples = 1024
+	// healthReportQueries is how many distinct statements are tracked for the slowest queries
+	healthReportQueries = 100
+	// healthReportTopQueries is how many of them the report lists
+	healthReportTopQueries = 5
+	// healthReportTopRoutes is how many routes the report lists
+	healthReportTopRoutes = 10
+)
+
+// Health accumulates the figures of the telemetry health report since the last one.
+// They are kept in process, so each replica reports on itself and a restart starts over.
+var Health = newHealthStats()
+
+// HealthStats counts requests, query durations and failed telemetry exports between reports,
+// for deployments that have no metrics backend to compute them from
+type HealthStats struct {
+	mu             sync.Mutex
+	since          time.Time
+	overall        *healthRoute
+	routes         map[string]*healthRoute
+	queries        map[string]*healthQuery
+	exportFailures map[string]int
+}
+
+// healthRoute counts one route's requests and keeps a uniform sample of their durations
+type healthRoute struct {
+	requests  int
+	errors    int
+	latencies []float64
+}
+
+type healthQuery struct {
+	count   int
+	totalMs float64
+	maxMs   float64
+}
+
+func newHealthStats() *HealthStats {
+	return &HealthStats{
+		since:          time.Now(),
+		overall:        &healthRoute{},
+		routes:         make(map[string]*healthRoute),
+		queries:        make(map[string]*healthQuery),
+		exportFailures: make(map[string]int),
+	}
+}
+
+// RecordRequest adds a completed request for route. Requests answered with a 5xx count as
+// errors, as for the SLOs.
+func (s *HealthStats) RecordRequest(route string, statusCode int, duration time.Duration) {
+	ms := float64(duration.Microseconds()) / 1000
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.routes[route]
+	if !ok {
+		r = &healthRoute{}
+		s.routes[route] = r
+	}
+	r.Add(statusCode >= 500, ms)
+	s.overall.Add(statusCode >= 500, ms)
+}
+
+func (r *healthRoute) Add(failed bool, ms float64) {
+	r.requests++
+	if failed {
+		r.errors++
+	}
+	// Reservoir sampling keeps every request of the period equally likely to be in the sample
+	if len(r.latencies) < healthReportSamples {
+		r.latencies = append(r.latencies, ms)
+	} else if i := rand.IntN(r.requests); i < healthReportSamples {
+		r.latencies[i] = ms
+	}
+}
+
+// p95 returns the 95th percentile of the sampled durations
+func (r *healthRoute) p95() float64 {
+	latencies := append([]float64(nil), r.latencies...)
+	sort.Float64s(latencies)
+	return percentile(latencies, 0.95)
+}
+
+// RecordQuery adds an execution of the statement query. Once healthReportQueries statements
+// are tracked, a new one replaces the one with the fastest worst case if it was slower.
+func (s *HealthStats) RecordQuery(query string, duration time.Duration) {
+	ms := float64(duration.Microseconds()) / 1000
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q, ok := s.queries[query]
+	if !ok {
+		if len(s.queries) >= healthReportQueries {
+			fastest := ""
+			for statement, other := range s.queries {
+				if fastest == "" || other.maxMs < s.queries[fastest].maxMs {
+					fastest = statement
+				}
+			}
+			if s.queries[fastest].maxMs >= ms {
+				return
+			}
+			delete(s.queries, fastest)
+		}
+		q = &healthQuery{}
+		s.queries[query] = q
+	}
+	q.count++
+	q.totalMs += ms
+	q.maxMs = max(q.maxMs, ms)
+}
+
+// RecordExportFailure counts a failed export of signal
+func (s *HealthStats) RecordExportFailure(signal string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exportFailures[signal]++
+}
+
+// HealthReport is the telemetry health of one replica over a period
+type HealthReport struct {
+	Instance       string
+	From           time.Time
+	To             time.Time
+	Requests       int
+	Errors         int
+	ErrorRate      float64
+	P95Ms          float64
+	Routes         []RouteHealth
+	SlowestQueries []QueryHealth
+	// ExportFailures counts failed exports by signal (spans, metrics, logs, internal)
+	ExportFailures map[string]int
+}
+
+// RouteHealth is one route's line in the report
+type RouteHealth struct {
+	Route     string
+	Requests  int
+	Errors    int
+	ErrorRate float64
+	P95Ms     float64
+}
+
+// QueryHealth is one of the slowest statements in the report
+type QueryHealth struct {
+	Statement string
+	Count     int
+	MaxMs     float64
+	AvgMs     float64
+}
+
+// Empty reports whether nothing happened in the period
+func (r *HealthReport) Empty() bool {
+	return r.Requests == 0 && len(r.ExportFailures) == 0
+}
+
+// Report summarizes the period up to now and starts a new one. The busiest routes are listed
+// with the most errors first, then the most requests.
+func (s *HealthStats) Report() *HealthReport {
+	s.mu.Lock()
+	now := time.Now()
+	report := &HealthReport{Instance: InstanceID, From: s.since, To: now, ExportFailures: s.exportFailures}
+	overall, routes, queries := s.overall, s.routes, s.queries
+	s.since = now
+	s.overall = &healthRoute{}
+	s.routes = make(map[string]*healthRoute)
+	s.queries = make(map[string]*healthQuery)
+	s.exportFailures = make(map[string]int)
+	s.mu.Unlock()
+
+	report.Requests, report.Errors, report.P95Ms = overall.requests, overall.errors, overall.p95()
+	if report.Requests > 0 {
+		report.ErrorRate = float64(report.Errors) / float64(report.Requests)
+	}
+	for route, r := range routes {
+		report.Routes = append(report.Routes, RouteHealth{
+			Route:     route,
+			Requests:  r.requests,
+			Errors:    r.errors,
+			ErrorRate: float64(r.errors) / float64(r.requests),
+			P95Ms:     r.p95(),
+		})
+	}
+	sort.Slice(report.Routes, func(i, j int) bool {
+		a, b := report.Routes[i], report.Routes[j]
+		if a.Errors != b.Errors {
+			return a.Errors > b.Errors
+		}
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		return a.Route < b.Route
+	})
+	if len(report.Routes) > healthReportTopRoutes {
+		report.Routes = report.Routes[:healthReportTopRoutes]
+	}
+
+	for statement, q := range queries {
+		report.SlowestQueries = append(report.SlowestQueries, QueryHealth{
+			// Statements span lines in the source; one line reads better in an email
+			Statement: strings.Join(strings.Fields(statement), " "),
+			Count:     q.count,
+			MaxMs:     q.maxMs,
+			AvgMs:     q.totalMs / float64(q.count),
+		})
+	}
+	sort.Slice(report.SlowestQueries, func(i, j int) bool {
+		return report.SlowestQueries[i].MaxMs > report.SlowestQueries[j].MaxMs
+	})
+	if len(report.SlowestQueries) > healthReportTopQueries {
+		report.SlowestQueries = report.SlowestQueries[:healthReportTopQueries]
+	}
+
+	return report
+}
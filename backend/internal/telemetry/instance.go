@@ -0,0 +1,21 @@
+package telemetry
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+)
+
+// InstanceID identifies this replica in the cluster tables and task history, and as the
+// service.instance.id resource attribute
+var InstanceID = newInstanceID()
+
+func newInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "todo-app"
+	}
+	b := make([]byte, 4)
+	rand.Read(b)
+	return host + "-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,72 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"todo-app/internal/requestctx"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// requestLogHandler adds the request ID, user, tenant and baggage of a record's context to it, so
+// every log line written while serving a request can be found by them
+type requestLogHandler struct {
+	slog.Handler
+}
+
+func (h requestLogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs := requestctx.LogAttrs(ctx); len(attrs) > 0 {
+		record = record.Clone()
+		record.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h requestLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestLogHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestLogHandler) WithGroup(name string) slog.Handler {
+	return requestLogHandler{h.Handler.WithGroup(name)}
+}
+
+// baggageSpanProcessor copies the baggage entries recorded for a request onto every span
+// started while serving it, such as the database spans, so they can be searched by them
+// without joining on the request span
+type baggageSpanProcessor struct{}
+
+func (baggageSpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	if attrs := requestctx.Baggage(parent); len(attrs) > 0 {
+		s.SetAttributes(attrs...)
+	}
+}
+
+func (baggageSpanProcessor) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (baggageSpanProcessor) Shutdown(context.Context) error   { return nil }
+func (baggageSpanProcessor) ForceFlush(context.Context) error { return nil }
+
+// Access log formats
+const (
+	AccessLogJSON = "json"
+	AccessLogText = "text"
+	AccessLogOff  = "off"
+)
+
+// NewAccessLogger returns the logger for access log lines, written to w as JSON or as
+// logfmt-style text; nil when format is off. Like the default logger it adds the request ID,
+// tenant and baggage of a record's context. It writes to w rather than through OpenTelemetry,
+// so the access log can be collected apart from the application logs.
+func NewAccessLogger(format string, w io.Writer) (*slog.Logger, error) {
+	switch format {
+	case AccessLogJSON:
+		return slog.New(requestLogHandler{slog.NewJSONHandler(w, nil)}), nil
+	case AccessLogText:
+		return slog.New(requestLogHandler{slog.NewTextHandler(w, nil)}), nil
+	case AccessLogOff:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown access log format %q, expected %s, %s or %s", format, AccessLogJSON, AccessLogText, AccessLogOff)
+}
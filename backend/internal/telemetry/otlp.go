@@ -0,0 +1,283 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"todo-app/internal/config"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/credentials"
+)
+
+// OTLP protocols, for OTEL_EXPORTER_OTLP_PROTOCOL. gRPC stays the default, as it was before
+// HTTP was supported, although the specification's default is http/protobuf.
+const (
+	otlpProtocolGRPC = "grpc"
+	otlpProtocolHTTP = "http/protobuf"
+)
+
+// otlpSignal is where and how one signal is exported over OTLP, read from the standard
+// OTEL_EXPORTER_OTLP_* variables, the signal's own (OTEL_EXPORTER_OTLP_TRACES_*, ...) taking
+// precedence
+type otlpSignal struct {
+	protocol string
+	// endpoint is a URL, or host:port for a plain-text gRPC connection. For HTTP it includes
+	// the signal's path.
+	endpoint string
+	headers  map[string]string
+	// plaintext is set for an http:// endpoint, or a gRPC host:port without TLS
+	plaintext bool
+	// certificate is a PEM bundle of the CAs trusted instead of the system's; clientCertificate
+	// and clientKey are a PEM key pair presented for mutual TLS
+	certificate       string
+	clientCertificate string
+	clientKey         string
+}
+
+// loadOTLPSignal reads the OTLP settings of signal, and reports false when no endpoint is set
+// for it, in which case it goes to the console. A signal's own endpoint is used as is; the
+// shared OTEL_EXPORTER_OTLP_ENDPOINT gets /v1/<signal> appended over HTTP, as the
+// specification has it. A gRPC host:port stays plain text, as before TLS was supported, unless
+// OTEL_EXPORTER_OTLP_INSECURE is false or a certificate is configured.
+func loadOTLPSignal(signal string) (otlpSignal, bool) {
+	prefix := "OTEL_EXPORTER_OTLP_" + strings.ToUpper(signal) + "_"
+	env := func(name string) string {
+		if v := strings.TrimSpace(os.Getenv(prefix + name)); v != "" {
+			return v
+		}
+		return strings.TrimSpace(os.Getenv("OTEL_EXPORTER_OTLP_" + name))
+	}
+
+	s := otlpSignal{protocol: env("PROTOCOL")}
+	switch s.protocol {
+	case "":
+		s.protocol = otlpProtocolGRPC
+	case otlpProtocolGRPC, otlpProtocolHTTP:
+	case "http/json":
+		config.StderrLogger.Printf("OTLP over http/json is not supported, exporting %s as http/protobuf", signal)
+		s.protocol = otlpProtocolHTTP
+	default:
+		config.StderrLogger.Printf("unknown OTLP protocol %q, exporting %s over grpc", s.protocol, signal)
+		s.protocol = otlpProtocolGRPC
+	}
+
+	s.endpoint = os.Getenv(prefix + "ENDPOINT")
+	if s.endpoint == "" {
+		s.endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if s.endpoint == "" {
+			return s, false
+		}
+		if s.protocol == otlpProtocolHTTP {
+			if !strings.Contains(s.endpoint, "://") {
+				s.endpoint = "http://" + s.endpoint
+			}
+			s.endpoint = strings.TrimSuffix(s.endpoint, "/") + "/v1/" + signal
+		}
+	} else if s.protocol == otlpProtocolHTTP && !strings.Contains(s.endpoint, "://") {
+		s.endpoint = "http://" + s.endpoint
+	}
+
+	s.headers = parseOTLPHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	for k, v := range parseOTLPHeaders(os.Getenv(prefix + "HEADERS")) {
+		s.headers[k] = v
+	}
+
+	s.certificate, s.clientCertificate, s.clientKey = env("CERTIFICATE"), env("CLIENT_CERTIFICATE"), env("CLIENT_KEY")
+	if s.hasScheme() {
+		s.plaintext = strings.HasPrefix(strings.ToLower(s.endpoint), "http://")
+	} else {
+		tlsConfigured := s.certificate != "" || s.clientCertificate != "" || s.clientKey != ""
+		s.plaintext = config.EnvBool(prefix+"INSECURE", config.EnvBool("OTEL_EXPORTER_OTLP_INSECURE", !tlsConfigured))
+	}
+	return s, true
+}
+
+// parseOTLPHeaders parses the OTEL_EXPORTER_OTLP_HEADERS format, comma-separated key=value
+// pairs with URL-encoded values, skipping malformed pairs
+func parseOTLPHeaders(raw string) map[string]string {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			if strings.TrimSpace(pair) != "" {
+				config.StderrLogger.Printf("ignoring malformed OTLP header %q", strings.TrimSpace(pair))
+			}
+			continue
+		}
+		if decoded, err := url.PathUnescape(strings.TrimSpace(value)); err == nil {
+			value = decoded
+		}
+		headers[key] = strings.TrimSpace(value)
+	}
+	return headers
+}
+
+// hasScheme reports whether a gRPC endpoint is a URL rather than host:port
+func (s otlpSignal) hasScheme() bool {
+	return strings.Contains(s.endpoint, "://")
+}
+
+// tlsConfig builds the TLS configuration of a secure connection from the certificate files
+func (s otlpSignal) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if s.certificate != "" {
+		pem, err := os.ReadFile(s.certificate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the OTLP CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificate found in %s", s.certificate)
+		}
+		cfg.RootCAs = pool
+	}
+	if s.clientCertificate != "" || s.clientKey != "" {
+		cert, err := tls.LoadX509KeyPair(s.clientCertificate, s.clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the OTLP client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// security describes the connection, for the startup message
+func (s otlpSignal) security() string {
+	switch {
+	case s.plaintext:
+		return "plain text"
+	case s.clientCertificate != "":
+		return "mutual TLS"
+	default:
+		return "TLS"
+	}
+}
+
+func (s otlpSignal) traceExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	var tlsConfig *tls.Config
+	if !s.plaintext {
+		var err error
+		if tlsConfig, err = s.tlsConfig(); err != nil {
+			return nil, err
+		}
+	}
+	if s.protocol == otlpProtocolHTTP {
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpointURL(s.endpoint),
+			otlptracehttp.WithHeaders(s.headers),
+			otlptracehttp.WithTimeout(exportTimeout),
+			otlptracehttp.WithRetry(otlptracehttp.RetryConfig(exportRetryConfig)),
+		}
+		if tlsConfig != nil {
+			opts = append(opts, otlptracehttp.WithTLSClientConfig(tlsConfig))
+		}
+		return otlptracehttp.New(ctx, opts...)
+	}
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithHeaders(s.headers),
+		otlptracegrpc.WithTimeout(exportTimeout),
+		otlptracegrpc.WithRetry(exportRetryConfig),
+	}
+	if s.hasScheme() {
+		opts = append(opts, otlptracegrpc.WithEndpointURL(s.endpoint))
+	} else {
+		opts = append(opts, otlptracegrpc.WithEndpoint(s.endpoint))
+	}
+	if tlsConfig != nil {
+		opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	return otlptracegrpc.New(ctx, opts...)
+}
+
+func (s otlpSignal) metricExporter(ctx context.Context) (sdkmetric.Exporter, error) {
+	var tlsConfig *tls.Config
+	if !s.plaintext {
+		var err error
+		if tlsConfig, err = s.tlsConfig(); err != nil {
+			return nil, err
+		}
+	}
+	if s.protocol == otlpProtocolHTTP {
+		opts := []otlpmetrichttp.Option{
+			otlpmetrichttp.WithEndpointURL(s.endpoint),
+			otlpmetrichttp.WithHeaders(s.headers),
+			otlpmetrichttp.WithTimeout(exportTimeout),
+			otlpmetrichttp.WithRetry(otlpmetrichttp.RetryConfig(exportRetryConfig)),
+		}
+		if tlsConfig != nil {
+			opts = append(opts, otlpmetrichttp.WithTLSClientConfig(tlsConfig))
+		}
+		return otlpmetrichttp.New(ctx, opts...)
+	}
+	opts := []otlpmetricgrpc.Option{
+		otlpmetricgrpc.WithHeaders(s.headers),
+		otlpmetricgrpc.WithTimeout(exportTimeout),
+		otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetryConfig(exportRetryConfig)),
+	}
+	if s.hasScheme() {
+		opts = append(opts, otlpmetricgrpc.WithEndpointURL(s.endpoint))
+	} else {
+		opts = append(opts, otlpmetricgrpc.WithEndpoint(s.endpoint))
+	}
+	if tlsConfig != nil {
+		opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	}
+	return otlpmetricgrpc.New(ctx, opts...)
+}
+
+func (s otlpSignal) logExporter(ctx context.Context) (log.Exporter, error) {
+	var tlsConfig *tls.Config
+	if !s.plaintext {
+		var err error
+		if tlsConfig, err = s.tlsConfig(); err != nil {
+			return nil, err
+		}
+	}
+	if s.protocol == otlpProtocolHTTP {
+		opts := []otlploghttp.Option{
+			otlploghttp.WithEndpointURL(s.endpoint),
+			otlploghttp.WithHeaders(s.headers),
+			otlploghttp.WithTimeout(exportTimeout),
+			otlploghttp.WithRetry(otlploghttp.RetryConfig(exportRetryConfig)),
+		}
+		if tlsConfig != nil {
+			opts = append(opts, otlploghttp.WithTLSClientConfig(tlsConfig))
+		}
+		return otlploghttp.New(ctx, opts...)
+	}
+	opts := []otlploggrpc.Option{
+		otlploggrpc.WithHeaders(s.headers),
+		otlploggrpc.WithTimeout(exportTimeout),
+		otlploggrpc.WithRetry(otlploggrpc.RetryConfig(exportRetryConfig)),
+	}
+	if s.hasScheme() {
+		opts = append(opts, otlploggrpc.WithEndpointURL(s.endpoint))
+	} else {
+		opts = append(opts, otlploggrpc.WithEndpoint(s.endpoint))
+	}
+	if tlsConfig != nil {
+		opts = append(opts, otlploggrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		opts = append(opts, otlploggrpc.WithInsecure())
+	}
+	return otlploggrpc.New(ctx, opts...)
+}
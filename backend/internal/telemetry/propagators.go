@@ -0,0 +1,59 @@
+package telemetry
+
+import (
+	"slices"
+	"strings"
+
+	"todo-app/internal/config"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// defaultPropagators is OTEL_PROPAGATORS when unset, as the specification asks
+const defaultPropagators = "tracecontext,baggage"
+
+// propagatorsByName are the OTEL_PROPAGATORS values supported. b3 is the single b3 header
+// Istio and Envoy send, b3multi the X-B3-* headers of older Zipkin tracers, and jaeger the
+// uber-trace-id header.
+var propagatorsByName = map[string]func() propagation.TextMapPropagator{
+	"tracecontext": func() propagation.TextMapPropagator { return propagation.TraceContext{} },
+	"baggage":      func() propagation.TextMapPropagator { return propagation.Baggage{} },
+	"b3":           func() propagation.TextMapPropagator { return b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)) },
+	"b3multi":      func() propagation.TextMapPropagator { return b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)) },
+	"jaeger":       func() propagation.TextMapPropagator { return jaeger.Jaeger{} },
+}
+
+// newPropagator builds the composite propagator OTEL_PROPAGATORS names, in its order: each
+// one extracts incoming context it finds, and all of them inject into outgoing requests, so
+// the server can sit between tracers of different formats. "none" turns propagation off.
+// Unknown names are reported and skipped.
+func newPropagator() (propagation.TextMapPropagator, []string) {
+	var names []string
+	var propagators []propagation.TextMapPropagator
+	for _, name := range config.SplitList(config.EnvString("OTEL_PROPAGATORS", defaultPropagators)) {
+		if name == "none" {
+			return propagation.NewCompositeTextMapPropagator(), nil
+		}
+		newPropagator, ok := propagatorsByName[name]
+		if !ok {
+			config.StderrLogger.Printf("ignoring unsupported OTEL_PROPAGATORS entry %q", name)
+			continue
+		}
+		if slices.Contains(names, name) {
+			continue
+		}
+		names = append(names, name)
+		propagators = append(propagators, newPropagator())
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...), names
+}
+
+// propagatorNames describes the propagators in use for the startup banner
+func propagatorNames(names []string) string {
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
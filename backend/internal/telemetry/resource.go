@@ -0,0 +1,122 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"todo-app/internal/config"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.27.0"
+)
+
+// ServiceVersion is reported in telemetry resources and audit records. It comes from the
+// build: the module version of a go install, otherwise the VCS revision the binary was built
+// from, and "devel" when neither is known (go run, tests).
+var ServiceVersion = buildVersion()
+
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "devel"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	var revision string
+	var modified bool
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if revision == "" {
+		return "devel"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// serviceAccountNamespace is where Kubernetes mounts the namespace of a pod's service account
+const serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// newResource describes the process in every span, metric and log: the service, then what
+// the host, OS, process runtime, container and Kubernetes detectors find, and last
+// OTEL_RESOURCE_ATTRIBUTES and OTEL_SERVICE_NAME, which override anything detected. Command
+// line arguments and the executable path are left out, as they may carry secrets. A detector
+// that fails only leaves its attributes out.
+func newResource(ctx context.Context) (*resource.Resource, error) {
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			semconv.ServiceName("todo-app"),
+			semconv.ServiceVersion(ServiceVersion),
+			semconv.ServiceInstanceID(InstanceID),
+		),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithOS(),
+		resource.WithProcessPID(),
+		resource.WithProcessExecutableName(),
+		resource.WithProcessOwner(),
+		resource.WithProcessRuntimeName(),
+		resource.WithProcessRuntimeVersion(),
+		resource.WithProcessRuntimeDescription(),
+		resource.WithContainer(),
+		resource.WithDetectors(kubernetesDetector{}),
+		resource.WithFromEnv(),
+	)
+	if errors.Is(err, resource.ErrPartialResource) || errors.Is(err, resource.ErrSchemaURLConflict) {
+		config.StderrLogger.Printf("some resource attributes could not be detected: %v", err)
+		return res, nil
+	}
+	return res, err
+}
+
+// kubernetesDetector finds the pod the server runs in. The pod name defaults to the host
+// name, which Kubernetes sets to it; the namespace comes from the service account mount.
+// K8S_POD_NAME, K8S_POD_UID, K8S_NAMESPACE_NAME and K8S_NODE_NAME, set from the downward
+// API, take precedence.
+type kubernetesDetector struct{}
+
+func (kubernetesDetector) Detect(context.Context) (*resource.Resource, error) {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return resource.Empty(), nil
+	}
+
+	var attrs []attribute.KeyValue
+	pod := os.Getenv("K8S_POD_NAME")
+	if pod == "" {
+		pod, _ = os.Hostname()
+	}
+	if pod != "" {
+		attrs = append(attrs, semconv.K8SPodName(pod))
+	}
+	if uid := os.Getenv("K8S_POD_UID"); uid != "" {
+		attrs = append(attrs, semconv.K8SPodUID(uid))
+	}
+	namespace := os.Getenv("K8S_NAMESPACE_NAME")
+	if namespace == "" {
+		if b, err := os.ReadFile(serviceAccountNamespace); err == nil {
+			namespace = strings.TrimSpace(string(b))
+		}
+	}
+	if namespace != "" {
+		attrs = append(attrs, semconv.K8SNamespaceName(namespace))
+	}
+	if node := os.Getenv("K8S_NODE_NAME"); node != "" {
+		attrs = append(attrs, semconv.K8SNodeName(node))
+	}
+	return resource.NewSchemaless(attrs...), nil
+}
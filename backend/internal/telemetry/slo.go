@@ -0,0 +1,142 @@
+package telemetry
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// sloSample is a single completed request observed by the SLOTracker
+type sloSample struct {
+	at       time.Time
+	duration time.Duration
+	status   int
+}
+
+// sloRing is a fixed-size ring buffer of the most recent samples for one route
+type sloRing struct {
+	samples []sloSample
+	next    int
+	full    bool
+}
+
+func (r *sloRing) Add(s sloSample) {
+	r.samples[r.next] = s
+	r.next = (r.next + 1) % len(r.samples)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *sloRing) recent(since time.Time) []sloSample {
+	n := r.next
+	if r.full {
+		n = len(r.samples)
+	}
+	out := make([]sloSample, 0, n)
+	for _, s := range r.samples[:n] {
+		if !s.at.Before(since) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// RouteSLO summarizes the recent success rate and latency of one route
+type RouteSLO struct {
+	Route       string  `json:"route"`
+	Requests    int     `json:"requests"`
+	Errors      int     `json:"errors"`
+	SuccessRate float64 `json:"success_rate"`
+	P50Ms       float64 `json:"p50_ms"`
+	P90Ms       float64 `json:"p90_ms"`
+	P95Ms       float64 `json:"p95_ms"`
+	P99Ms       float64 `json:"p99_ms"`
+}
+
+// SLOTracker keeps per-route ring buffers of recent requests so error budgets can be
+// inspected without a metrics backend. Requests answered with a 5xx count as errors.
+type SLOTracker struct {
+	mu       sync.Mutex
+	capacity int
+	window   time.Duration
+	routes   map[string]*sloRing
+}
+
+func NewSLOTracker(capacity int, window time.Duration) *SLOTracker {
+	return &SLOTracker{
+		capacity: capacity,
+		window:   window,
+		routes:   make(map[string]*sloRing),
+	}
+}
+
+// Record adds a completed request for route
+func (t *SLOTracker) Record(route string, statusCode int, duration time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ring, ok := t.routes[route]
+	if !ok {
+		ring = &sloRing{samples: make([]sloSample, t.capacity)}
+		t.routes[route] = ring
+	}
+	ring.Add(sloSample{at: time.Now(), duration: duration, status: statusCode})
+}
+
+// Window returns the rolling window summaries are computed over
+func (t *SLOTracker) Window() time.Duration {
+	return t.window
+}
+
+// Summary computes per-route statistics over the samples inside the rolling window
+func (t *SLOTracker) Summary() []RouteSLO {
+	since := time.Now().Add(-t.window)
+
+	t.mu.Lock()
+	recent := make(map[string][]sloSample, len(t.routes))
+	for route, ring := range t.routes {
+		recent[route] = ring.recent(since)
+	}
+	t.mu.Unlock()
+
+	summaries := make([]RouteSLO, 0, len(recent))
+	for route, samples := range recent {
+		if len(samples) == 0 {
+			continue
+		}
+
+		summary := RouteSLO{Route: route, Requests: len(samples)}
+		durations := make([]float64, len(samples))
+		for i, s := range samples {
+			if s.status >= 500 {
+				summary.Errors++
+			}
+			durations[i] = float64(s.duration.Microseconds()) / 1000
+		}
+		sort.Float64s(durations)
+
+		summary.SuccessRate = float64(summary.Requests-summary.Errors) / float64(summary.Requests)
+		summary.P50Ms = percentile(durations, 0.50)
+		summary.P90Ms = percentile(durations, 0.90)
+		summary.P95Ms = percentile(durations, 0.95)
+		summary.P99Ms = percentile(durations, 0.99)
+		summaries = append(summaries, summary)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Route < summaries[j].Route })
+	return summaries
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
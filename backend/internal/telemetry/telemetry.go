@@ -0,0 +1,235 @@
+// Package telemetry sets up the OpenTelemetry traces, metrics and logs the server exports,
+// and keeps the SLOs and the health report computed from them in process.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"todo-app/internal/config"
+
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutlog"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// exportTimeout bounds a single OTLP export attempt including retries
+const exportTimeout = 10 * time.Second
+
+// exportRetryConfig bounds retries so a down collector cannot stall the batch processors indefinitely
+var exportRetryConfig = otlptracegrpc.RetryConfig{
+	Enabled:         true,
+	InitialInterval: 1 * time.Second,
+	MaxInterval:     5 * time.Second,
+	MaxElapsedTime:  exportTimeout,
+}
+
+// BatchProcessorConfig tunes a trace or log batch processor. The queue bounds how
+// much telemetry is buffered in memory; anything beyond it is dropped.
+type BatchProcessorConfig struct {
+	MaxQueueSize       int
+	MaxExportBatchSize int
+	ExportTimeout      time.Duration
+	ScheduleDelay      time.Duration
+}
+
+// loadBatchProcessorConfig reads the standard OTEL_BSP_* / OTEL_BLRP_* variables for the given prefix.
+// The queue and batch defaults are larger than the SDK's so that bursts of body-capture-heavy
+// requests are not dropped. The export timeout defaults to exportTimeout, the exporters' own
+// budget for an attempt and its retries, rather than the SDK's 30s, so a down collector holds
+// up a batch no longer than the exporter keeps trying.
+func loadBatchProcessorConfig(prefix string, scheduleDelay time.Duration) BatchProcessorConfig {
+	cfg := BatchProcessorConfig{
+		MaxQueueSize:       config.EnvInt(prefix+"_MAX_QUEUE_SIZE", 8192),
+		MaxExportBatchSize: config.EnvInt(prefix+"_MAX_EXPORT_BATCH_SIZE", 1024),
+		ExportTimeout:      config.EnvMillis(prefix+"_EXPORT_TIMEOUT", exportTimeout),
+		ScheduleDelay:      config.EnvMillis(prefix+"_SCHEDULE_DELAY", scheduleDelay),
+	}
+	if cfg.MaxExportBatchSize > cfg.MaxQueueSize {
+		config.StderrLogger.Printf("%s_MAX_EXPORT_BATCH_SIZE exceeds the queue size, capping it at %d", prefix, cfg.MaxQueueSize)
+		cfg.MaxExportBatchSize = cfg.MaxQueueSize
+	}
+	return cfg
+}
+
+func InitTelemetry(ctx context.Context) (shutdown func(context.Context) error, err error) {
+	var shutdownFuncs []func(context.Context) error
+
+	shutdown = func(ctx context.Context) error {
+		var err error
+		for _, fn := range shutdownFuncs {
+			err = fn(ctx)
+		}
+		return err
+	}
+
+	res, err := newResource(ctx)
+	if err != nil {
+		return shutdown, fmt.Errorf("failed to create resource: %w", err)
+	}
+
+	installTelemetryErrorHandler()
+
+	// Exporter setup never fails initialization: if the collector cannot be configured
+	// the signal falls back to the console exporter so the app keeps serving traffic.
+	traceBatch := loadBatchProcessorConfig("OTEL_BSP", 5*time.Second)
+	logBatch := loadBatchProcessorConfig("OTEL_BLRP", 1*time.Second)
+
+	// Set up trace exporter based on environment
+	traceExporter, err := newTraceExporter()
+	if err != nil {
+		return shutdown, err
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(
+			&failureReportingSpanExporter{SpanExporter: traceExporter, reporter: newExportFailureReporter("spans")},
+			sdktrace.WithMaxQueueSize(traceBatch.MaxQueueSize),
+			sdktrace.WithMaxExportBatchSize(traceBatch.MaxExportBatchSize),
+			sdktrace.WithExportTimeout(traceBatch.ExportTimeout),
+			sdktrace.WithBatchTimeout(traceBatch.ScheduleDelay),
+		),
+		sdktrace.WithSpanProcessor(baggageSpanProcessor{}),
+		sdktrace.WithResource(res),
+	)
+	shutdownFuncs = append(shutdownFuncs, tracerProvider.Shutdown)
+	otel.SetTracerProvider(tracerProvider)
+	// By default baggage travels with the trace context, both into the spans of a request and
+	// out to the services it calls
+	propagator, propagators := newPropagator()
+	otel.SetTextMapPropagator(propagator)
+	fmt.Printf("Propagating context as %s\n", propagatorNames(propagators))
+
+	// Set up metric exporter based on environment
+	metricExporter, err := newMetricExporter()
+	if err != nil {
+		return shutdown, err
+	}
+
+	meterProvider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(
+			&failureReportingMetricExporter{Exporter: metricExporter, reporter: newExportFailureReporter("metrics")},
+			sdkmetric.WithTimeout(exportTimeout),
+		)),
+		sdkmetric.WithResource(res),
+	)
+	shutdownFuncs = append(shutdownFuncs, meterProvider.Shutdown)
+	otel.SetMeterProvider(meterProvider)
+
+	// Set up log exporter based on environment
+	logExporter, err := newLogExporter()
+	if err != nil {
+		return shutdown, err
+	}
+
+	loggerProvider := log.NewLoggerProvider(
+		log.WithProcessor(log.NewBatchProcessor(
+			&failureReportingLogExporter{Exporter: logExporter, reporter: newExportFailureReporter("logs")},
+			log.WithMaxQueueSize(logBatch.MaxQueueSize),
+			log.WithExportMaxBatchSize(logBatch.MaxExportBatchSize),
+			log.WithExportTimeout(logBatch.ExportTimeout),
+			log.WithExportInterval(logBatch.ScheduleDelay),
+		)),
+		// Keep recent warnings and errors for diagnostics bundles
+		log.WithProcessor(RecentLogs),
+		log.WithResource(res),
+	)
+	shutdownFuncs = append(shutdownFuncs, loggerProvider.Shutdown)
+	global.SetLoggerProvider(loggerProvider)
+
+	// Set up slog with OpenTelemetry bridge
+	logger := slog.New(requestLogHandler{otelslog.NewHandler("todo-app")})
+	slog.SetDefault(logger)
+
+	return shutdown, nil
+}
+
+// newTraceExporter creates the OTLP span exporter, falling back to the console exporter
+func newTraceExporter() (sdktrace.SpanExporter, error) {
+	if otlp, ok := loadOTLPSignal("traces"); ok {
+		// Use OTLP exporter for production
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		exporter, err := otlp.traceExporter(ctx)
+		if err == nil {
+			fmt.Printf("Exporting traces over OTLP (%s, %s) to %s\n", otlp.protocol, otlp.security(), otlp.endpoint)
+			return exporter, nil
+		}
+		config.StderrLogger.Printf("failed to create OTLP trace exporter, falling back to console: %v", err)
+	}
+
+	// Use console exporter for development
+	exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout trace exporter: %w", err)
+	}
+	return exporter, nil
+}
+
+// newMetricExporter creates the OTLP metric exporter, falling back to the console exporter
+func newMetricExporter() (sdkmetric.Exporter, error) {
+	if otlp, ok := loadOTLPSignal("metrics"); ok {
+		// Use OTLP exporter for production
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		exporter, err := otlp.metricExporter(ctx)
+		if err == nil {
+			fmt.Printf("Exporting metrics over OTLP (%s, %s) to %s\n", otlp.protocol, otlp.security(), otlp.endpoint)
+			return exporter, nil
+		}
+		config.StderrLogger.Printf("failed to create OTLP metric exporter, falling back to console: %v", err)
+	}
+
+	// Use console exporter for development
+	exporter, err := stdoutmetric.New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout metric exporter: %w", err)
+	}
+	return exporter, nil
+}
+
+// newLogExporter creates the OTLP log exporter, falling back to the console exporter
+func newLogExporter() (log.Exporter, error) {
+	if otlp, ok := loadOTLPSignal("logs"); ok {
+		// Use OTLP exporter for production
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		exporter, err := otlp.logExporter(ctx)
+		if err == nil {
+			fmt.Printf("Exporting logs over OTLP (%s, %s) to %s\n", otlp.protocol, otlp.security(), otlp.endpoint)
+			return exporter, nil
+		}
+		config.StderrLogger.Printf("failed to create OTLP log exporter, falling back to console: %v", err)
+	}
+
+	// Use console exporter for development
+	exporter, err := stdoutlog.New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout log exporter: %w", err)
+	}
+	return exporter, nil
+}
+
+// GetTracer returns the OpenTelemetry tracer for the todo-app
+func GetTracer() trace.Tracer {
+	return otel.Tracer("todo-app")
+}
+
+// GetMeter returns the OpenTelemetry meter for the todo-app
+func GetMeter() metric.Meter {
+	return otel.Meter("todo-app")
+}
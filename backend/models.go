@@ -1,10 +0,0 @@
-package main
-
-import "time"
-
-type Task struct {
-	ID        int       `json:"id"`
-	Title     string    `json:"title"`
-	Completed bool      `json:"completed"`
-	CreatedAt time.Time `json:"created_at"`
-}
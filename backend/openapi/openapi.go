@@ -0,0 +1,310 @@
+// Package openapi builds an OpenAPI 3 document in code. Routes describe themselves as they
+// are registered, and request and response schemas are derived from the Go types the
+// handlers encode and decode, so the document follows the code instead of drifting from it.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Version is the OpenAPI version of the documents built here
+const Version = "3.0.3"
+
+// Document is an OpenAPI document, limited to the parts this API uses
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+	Tags       []Tag               `json:"tags,omitempty"`
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Tag groups operations in viewers such as Swagger UI
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps lowercase HTTP methods to operations
+type PathItem map[string]*Operation
+
+// Operation describes one method on one path
+type Operation struct {
+	Summary     string              `json:"summary,omitempty"`
+	Description string              `json:"description,omitempty"`
+	OperationID string              `json:"operationId,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+// Parameter is a path, query or header parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+// RequestBody describes the body an operation accepts
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// Response describes one response of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body in one content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is a JSON Schema object as used by OpenAPI 3.0
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+}
+
+// Components holds the named schemas operations refer to
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// String, Integer, Boolean and Number return schemas of the JSON primitive types
+func String() *Schema  { return &Schema{Type: "string"} }
+func Integer() *Schema { return &Schema{Type: "integer"} }
+func Boolean() *Schema { return &Schema{Type: "boolean"} }
+func Number() *Schema  { return &Schema{Type: "number"} }
+
+// ArrayOf returns an array schema with items of the given schema
+func ArrayOf(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+// Builder assembles a Document. It is safe for concurrent use.
+type Builder struct {
+	mu        sync.Mutex
+	doc       Document
+	overrides map[reflect.Type]*Schema
+}
+
+// NewBuilder starts a document for the API with the given title and version
+func NewBuilder(title, version, description string) *Builder {
+	return &Builder{
+		doc: Document{
+			OpenAPI:    Version,
+			Info:       Info{Title: title, Version: version, Description: description},
+			Paths:      map[string]PathItem{},
+			Components: Components{Schemas: map[string]*Schema{}},
+		},
+		overrides: map[reflect.Type]*Schema{
+			reflect.TypeOf(time.Time{}):        {Type: "string", Format: "date-time"},
+			reflect.TypeOf(json.RawMessage{}):  {},
+			reflect.TypeOf((*any)(nil)).Elem(): {},
+		},
+	}
+}
+
+// Tag declares a tag and its description
+func (b *Builder) Tag(name, description string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.doc.Tags = append(b.doc.Tags, Tag{Name: name, Description: description})
+}
+
+// Define sets the schema used for every value of v's type, for types whose JSON form
+// reflection cannot see (custom MarshalJSON/UnmarshalJSON)
+func (b *Builder) Define(v any, schema *Schema) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.overrides[reflect.TypeOf(v)] = schema
+}
+
+// Add documents the route pattern, given as for http.ServeMux ("GET /tasks/{id}"). Path
+// parameters in the pattern are declared automatically unless op already declares them.
+func (b *Builder) Add(pattern string, op Operation) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		panic(fmt.Sprintf("openapi: pattern %q has no method", pattern))
+	}
+
+	for _, segment := range strings.Split(path, "/") {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			continue
+		}
+		name := strings.TrimSuffix(strings.Trim(segment, "{}"), "...")
+		declared := false
+		for _, p := range op.Parameters {
+			declared = declared || (p.In == "path" && p.Name == name)
+		}
+		if !declared {
+			op.Parameters = append(op.Parameters, Parameter{Name: name, In: "path", Required: true, Schema: String()})
+		}
+	}
+	if len(op.Responses) == 0 {
+		op.Responses = map[string]Response{"200": {Description: "OK"}}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	item := b.doc.Paths[path]
+	if item == nil {
+		item = PathItem{}
+		b.doc.Paths[path] = item
+	}
+	item[strings.ToLower(method)] = &op
+}
+
+// Document returns the document built so far
+func (b *Builder) Document() *Document {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	doc := b.doc
+	return &doc
+}
+
+// Handler serves the document as JSON
+func (b *Builder) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(b.Document())
+	})
+}
+
+// JSON returns a request body or response content of v's schema as application/json
+func (b *Builder) JSON(v any) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: b.SchemaOf(v)}}
+}
+
+// Body returns a required JSON request body of v's schema
+func (b *Builder) Body(v any) *RequestBody {
+	return &RequestBody{Required: true, Content: b.JSON(v)}
+}
+
+// Returns returns a response with a JSON body of v's schema
+func (b *Builder) Returns(description string, v any) Response {
+	return Response{Description: description, Content: b.JSON(v)}
+}
+
+// SchemaOf derives a schema from v's type using its json struct tags. Named struct types
+// are added to the components and referenced; v may also be a *Schema, returned as is.
+func (b *Builder) SchemaOf(v any) *Schema {
+	if s, ok := v.(*Schema); ok {
+		return s
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.schemaOf(reflect.TypeOf(v))
+}
+
+func (b *Builder) schemaOf(t reflect.Type) *Schema {
+	if s, ok := b.overrides[t]; ok {
+		copied := *s
+		return &copied
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := b.schemaOf(t.Elem())
+		if s.Ref != "" {
+			// $ref cannot carry siblings in OpenAPI 3.0
+			return &Schema{OneOf: []*Schema{s}, Nullable: true}
+		}
+		s.Nullable = true
+		return s
+	case reflect.String:
+		return String()
+	case reflect.Bool:
+		return Boolean()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s := Integer()
+		if t.Kind() == reflect.Int64 || t.Kind() == reflect.Uint64 {
+			s.Format = "int64"
+		}
+		return s
+	case reflect.Float32, reflect.Float64:
+		return Number()
+	case reflect.Slice, reflect.Array:
+		return ArrayOf(b.schemaOf(t.Elem()))
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schemaOf(t.Elem())}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		name := t.Name()
+		if _, ok := b.doc.Components.Schemas[name]; !ok {
+			// Reserve the name first so recursive types terminate
+			b.doc.Components.Schemas[name] = &Schema{}
+			b.doc.Components.Schemas[name] = b.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+func (b *Builder) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			// Embedded structs are flattened into the outer object, as encoding/json does
+			embedded := b.schemaOf(field.Type)
+			if embedded.Ref != "" {
+				embedded = b.doc.Components.Schemas[strings.TrimPrefix(embedded.Ref, "#/components/schemas/")]
+			}
+			for k, v := range embedded.Properties {
+				s.Properties[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		s.Properties[name] = b.schemaOf(field.Type)
+	}
+	return s
+}
@@ -0,0 +1,145 @@
+package openapi
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ValidationError is a value that does not match its schema, at a JSON path such as
+// $.issue.labels[0].name
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// Validate checks a value decoded by encoding/json against the schema: types, nullability,
+// enums, required and additional properties, array items, oneOf and the date-time format.
+// References are not followed, so the schema must be self-contained. The first mismatch
+// is returned as a *ValidationError.
+func (s *Schema) Validate(v any) error {
+	return s.validate("$", v)
+}
+
+func (s *Schema) validate(path string, v any) error {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		return &ValidationError{path, "schema references are not supported"}
+	}
+	if v == nil {
+		if s.Nullable || s.Type == "" {
+			return nil
+		}
+		return &ValidationError{path, "must not be null"}
+	}
+	if len(s.OneOf) > 0 {
+		matches := 0
+		for _, alt := range s.OneOf {
+			if alt.validate(path, v) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return &ValidationError{path, fmt.Sprintf("must match exactly one schema, matched %d", matches)}
+		}
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return reflect.DeepEqual(e, v) }) {
+		return &ValidationError{path, fmt.Sprintf("must be one of %v", s.Enum)}
+	}
+
+	switch s.Type {
+	case "":
+		return nil
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return typeError(path, s.Type, v)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return &ValidationError{path, "must be an RFC 3339 date-time"}
+			}
+		}
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) {
+			return typeError(path, s.Type, v)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return typeError(path, s.Type, v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return typeError(path, s.Type, v)
+		}
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			return typeError(path, s.Type, v)
+		}
+		for i, item := range items {
+			if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return typeError(path, s.Type, v)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return &ValidationError{path + "." + name, "is required"}
+			}
+		}
+		// Sorted, so the same payload always reports the same mismatch
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				prop = s.AdditionalProperties
+			}
+			if err := prop.validate(path+"."+name, obj[name]); err != nil {
+				return err
+			}
+		}
+	default:
+		return &ValidationError{path, fmt.Sprintf("unsupported schema type %q", s.Type)}
+	}
+	return nil
+}
+
+// typeError describes a value of the wrong JSON type
+func typeError(path, want string, v any) error {
+	got := "object"
+	switch v.(type) {
+	case string:
+		got = "string"
+	case float64:
+		got = "number"
+	case bool:
+		got = "boolean"
+	case []any:
+		got = "array"
+	}
+	article := "a"
+	if strings.ContainsAny(want[:1], "aeiou") {
+		article = "an"
+	}
+	return &ValidationError{path, fmt.Sprintf("must be %s %s, not %s", article, want, got)}
+}
@@ -5,7 +5,7 @@ let tasks = [];
 
 async function fetchTasks() {
     try {
-        const response = await fetch(`${API_URL}/tasks`);
+        const response = await fetch(`${API_URL}/tasks?sort=position`);
         if (!response.ok) {
             throw new Error('Failed to fetch tasks');
         }
@@ -51,7 +51,7 @@ async function deleteTask(id) {
             throw new Error('Failed to delete task');
         }
 
-        tasks = tasks.filter(task => task.id !== id);
+        tasks = tasks.filter(task => task.uuid !== id);
         renderTasks();
     } catch (error) {
         console.error('Error deleting task:', error);
@@ -65,22 +65,67 @@ async function completeTask(id) {
             method: 'POST',
         });
 
+        if (response.status === 409) {
+            const problem = await response.json();
+            alert(problem.detail);
+            renderTasks();
+            return;
+        }
         if (!response.ok) {
             throw new Error('Failed to complete task');
         }
 
-        const updatedTask = await response.json();
-        const taskIndex = tasks.findIndex(task => task.id === id);
-        if (taskIndex !== -1) {
-            tasks[taskIndex] = updatedTask;
-            renderTasks();
-        }
+        // Completing a task may unblock others, so reload the list
+        await fetchTasks();
     } catch (error) {
         console.error('Error completing task:', error);
         alert('Failed to complete task');
     }
 }
 
+async function uncompleteTask(id) {
+    try {
+        const response = await fetch(`${API_URL}/tasks/${id}/uncomplete`, {
+            method: 'POST',
+        });
+
+        if (!response.ok) {
+            throw new Error('Failed to reopen task');
+        }
+
+        // Reopening a task may block others again, so reload the list
+        await fetchTasks();
+    } catch (error) {
+        console.error('Error reopening task:', error);
+        alert('Failed to reopen task');
+    }
+}
+
+async function moveTask(id, position) {
+    try {
+        const response = await fetch(`${API_URL}/tasks/${id}/move`, {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+            },
+            body: JSON.stringify({ position }),
+        });
+
+        if (!response.ok) {
+            throw new Error('Failed to move task');
+        }
+
+        const movedTask = await response.json();
+        tasks = tasks.filter(task => task.uuid !== id);
+        tasks.splice(position, 0, movedTask);
+        renderTasks();
+    } catch (error) {
+        console.error('Error moving task:', error);
+        alert('Failed to move task');
+        fetchTasks();
+    }
+}
+
 function renderTasks() {
     const taskList = document.getElementById('taskList');
     taskList.innerHTML = '';
@@ -90,12 +135,37 @@ function renderTasks() {
         return;
     }
 
-    tasks.forEach(task => {
+    tasks.forEach((task, index) => {
         const li = document.createElement('li');
         li.className = 'task-item';
         if (task.completed) {
             li.classList.add('completed');
         }
+        if (task.blocked) {
+            li.classList.add('blocked');
+            li.title = 'Blocked by incomplete tasks';
+        }
+
+        // Drag and drop to reorder; dropping on a task takes its place
+        li.draggable = true;
+        li.addEventListener('dragstart', (e) => {
+            e.dataTransfer.setData('text/plain', task.uuid);
+            li.classList.add('dragging');
+        });
+        li.addEventListener('dragend', () => li.classList.remove('dragging'));
+        li.addEventListener('dragover', (e) => {
+            e.preventDefault();
+            li.classList.add('drop-target');
+        });
+        li.addEventListener('dragleave', () => li.classList.remove('drop-target'));
+        li.addEventListener('drop', (e) => {
+            e.preventDefault();
+            li.classList.remove('drop-target');
+            const id = e.dataTransfer.getData('text/plain');
+            if (id && id !== task.uuid) {
+                moveTask(id, index);
+            }
+        });
 
         const taskContent = document.createElement('div');
         taskContent.className = 'task-content';
@@ -103,10 +173,12 @@ function renderTasks() {
         const checkbox = document.createElement('input');
         checkbox.type = 'checkbox';
         checkbox.checked = task.completed;
-        checkbox.disabled = task.completed;
+        checkbox.disabled = task.blocked && !task.completed;
         checkbox.addEventListener('change', () => {
-            if (!task.completed) {
-                completeTask(task.id);
+            if (task.completed) {
+                uncompleteTask(task.uuid);
+            } else {
+                completeTask(task.uuid);
             }
         });
 
@@ -120,7 +192,7 @@ function renderTasks() {
         const deleteButton = document.createElement('button');
         deleteButton.className = 'delete-button';
         deleteButton.textContent = 'Delete';
-        deleteButton.addEventListener('click', () => deleteTask(task.id));
+        deleteButton.addEventListener('click', () => deleteTask(task.uuid));
 
         li.appendChild(taskContent);
         li.appendChild(deleteButton);
@@ -18,4 +18,4 @@ echo "  OTEL_SERVICE_VERSION=$OTEL_SERVICE_VERSION"
 echo ""
 
 # Run the app
-go run .
\ No newline at end of file
+go run ./cmd/server
\ No newline at end of file